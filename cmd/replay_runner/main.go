@@ -0,0 +1,94 @@
+// main.go 是无界面回放命令的入口。
+// 它不启动 HTTP 服务，直接装配 replay.Service、quotes.ReplaySink、回放模拟盘 trade.Service 和 strategy.Manager，
+// 按规格文件跑完一次回放并把报告写到磁盘；回放失败、超时或被中断时以非零状态退出，便于 cron 定时跑回归回放。
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"ctp-future-kline/internal/config"
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitTimeout = 3
+)
+
+func main() {
+	configPath := flag.String("config", filepath.Join("config", "config.json"), "config file path")
+	specPath := flag.String("spec", "", "replay spec file (.json, .yaml or .yml)")
+	outDir := flag.String("out", "", "report root directory, overrides output_dir in the spec")
+	logPath := flag.String("log", "", "optional log file path")
+	flag.Parse()
+
+	if *specPath == "" {
+		fmt.Fprintln(os.Stderr, "-spec is required")
+		os.Exit(exitUsage)
+	}
+	spec, err := loadRunSpec(*specPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config failed: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if *logPath != "" {
+		_ = os.MkdirAll(filepath.Dir(*logPath), 0o755)
+		if err := logger.InitFile(*logPath); err != nil {
+			fmt.Fprintf(os.Stderr, "init log file failed: %v\n", err)
+		}
+	}
+	if err := logger.SetLevel(cfg.Log.Level); err != nil {
+		logger.Error("set log level failed", "level", cfg.Log.Level, "error", err)
+	}
+	if *outDir != "" {
+		spec.OutputDir = *outDir
+	}
+	if spec.OutputDir == "" {
+		spec.OutputDir = filepath.Join(cfg.CTP.FlowPath, "replay_runs")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, cfg, spec)
+	stop()
+	_ = logger.Close()
+	os.Exit(code)
+}
+
+// run 执行一次回放并写报告，返回进程退出码。
+func run(ctx context.Context, cfg config.AppConfig, spec runSpec) int {
+	r, err := newRunner(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "init replay runner failed: %v\n", err)
+		return exitFailed
+	}
+	defer r.Close()
+
+	result := r.Run(ctx, spec)
+	dir, err := writeReports(spec, result, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "write replay reports failed: %v\n", err)
+		return exitFailed
+	}
+	fmt.Println(dir)
+	if result.Error != "" {
+		fmt.Fprintf(os.Stderr, "replay %s failed: %s\n", spec.Name, result.Error)
+		if result.TimedOut {
+			return exitTimeout
+		}
+		return exitFailed
+	}
+	return exitOK
+}
@@ -0,0 +1,117 @@
+// reports.go 负责把一次无界面回放的结果写到报告目录。
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ctp-future-kline/internal/trade"
+)
+
+// reportTradeLimit 是写入报告的委托和成交条数上限。
+const reportTradeLimit = 5000
+
+// writeReports 在 <output_dir>/<name>-<时间> 下写出：
+//
+//	summary.json   运行结果、回放任务快照和各实例报告运行记录
+//	spec.json      实际使用的规格（已补默认值）
+//	paper.json     回放模拟账户的资金、持仓、委托和成交，子账户账本在 sub_accounts 下
+//	compare.json   A/B 对比报告（规格配置了 compare 时）
+//	runs/          各实例回放报告归档的副本
+//
+// 即使回放失败也会尽量写出已有内容，返回报告目录。
+func writeReports(spec runSpec, result runResult, r *runner) (string, error) {
+	dir := filepath.Join(spec.OutputDir, spec.Name+"-"+result.StartedAt.Format("20060102_150405"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := writeJSONFile(filepath.Join(dir, "spec.json"), spec); err != nil {
+		return dir, err
+	}
+
+	paper := paperLedgerReport(r.paper)
+	if len(r.papers) > 1 {
+		subs := make([]map[string]any, 0, len(r.papers)-1)
+		for _, svc := range r.papers[1:] {
+			subs = append(subs, paperLedgerReport(svc))
+		}
+		paper["sub_accounts"] = subs
+	}
+	if err := writeJSONFile(filepath.Join(dir, "paper.json"), paper); err != nil {
+		return dir, err
+	}
+
+	if result.CompareID != "" {
+		report, err := r.strategy.ReplayComparisonReport(result.CompareID)
+		if err != nil {
+			return dir, fmt.Errorf("load replay comparison %s: %w", result.CompareID, err)
+		}
+		if err := writeJSONFile(filepath.Join(dir, "compare.json"), report); err != nil {
+			return dir, err
+		}
+	}
+
+	for i, run := range result.Runs {
+		if run.OutputPath == "" {
+			continue
+		}
+		target := filepath.Join(dir, "runs", filepath.Base(run.OutputPath))
+		if err := copyFile(run.OutputPath, target); err != nil {
+			return dir, fmt.Errorf("copy replay report %s: %w", run.RunID, err)
+		}
+		result.Runs[i].OutputPath = target
+	}
+	return dir, writeJSONFile(filepath.Join(dir, "summary.json"), result)
+}
+
+// paperLedgerReport 汇总一个回放模拟账本的资金、持仓、委托和成交。
+func paperLedgerReport(svc *trade.Service) map[string]any {
+	out := map[string]any{"account_id": svc.AccountID(), "generated_at": time.Now()}
+	if account, err := svc.Account(); err == nil {
+		out["account"] = account
+	} else {
+		out["account_error"] = err.Error()
+	}
+	if positions, err := svc.Positions(); err == nil {
+		out["positions"] = positions
+	}
+	if orders, err := svc.Orders(reportTradeLimit); err == nil {
+		out["orders"] = orders
+	}
+	if trades, err := svc.Trades(reportTradeLimit); err == nil {
+		out["trades"] = trades
+	}
+	return out
+}
+
+func writeJSONFile(path string, v any) error {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(body, '\n'), 0o644)
+}
+
+func copyFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
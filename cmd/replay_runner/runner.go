@@ -0,0 +1,346 @@
+// runner.go 负责在没有 web.Server 的情况下装配回放链路，装配方式与 web.NewServer 中默认回放会话一致。
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/backtest"
+	"ctp-future-kline/internal/bus"
+	"ctp-future-kline/internal/config"
+	dbx "ctp-future-kline/internal/db"
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/quotes"
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/searchindex"
+	"ctp-future-kline/internal/strategy"
+	"ctp-future-kline/internal/trade"
+)
+
+// stopGracePeriod 是超时或中断后等待回放任务收尾（落最后一根 bar、结算报告）的时间。
+const stopGracePeriod = 30 * time.Second
+
+type runner struct {
+	cfg       config.AppConfig
+	sharedDSN string
+	replay    *replay.Service
+	sink      *quotes.ReplaySink
+	paper     *trade.Service
+	strategy  *strategy.Manager
+	query     *klinequery.Service
+	finished  chan replay.TaskSnapshot
+	closers   []func() error
+	// papers 是全部回放模拟账本：主账户在前，其后是启用的子账户，与 web 默认回放会话喂给撮合的集合一致。
+	papers []*trade.Service
+}
+
+// runResult 是一次回放的结果，写入 summary.json。
+type runResult struct {
+	OK           bool                   `json:"ok"`
+	Error        string                 `json:"error,omitempty"`
+	TimedOut     bool                   `json:"timed_out,omitempty"`
+	StartedAt    time.Time              `json:"started_at"`
+	FinishedAt   time.Time              `json:"finished_at"`
+	Task         replay.TaskSnapshot    `json:"task"`
+	Instances    []string               `json:"instances,omitempty"`
+	CompareID    string                 `json:"compare_id,omitempty"`
+	Runs         []strategy.StrategyRun `json:"runs,omitempty"`
+	InstanceErrs map[string]string      `json:"instance_errors,omitempty"`
+}
+
+func newRunner(cfg config.AppConfig) (*runner, error) {
+	sharedDSN := dbx.DSNForRole(cfg.DB, dbx.RoleSharedMeta)
+	realtimeDSN := dbx.DSNForRole(cfg.DB, dbx.RoleMarketRealtime)
+	replayDSN := dbx.DSNForRole(cfg.DB, dbx.RoleMarketReplay)
+	tradeLiveDSN := dbx.DSNForRole(cfg.DB, dbx.RoleTradeLive)
+	tradePaperLiveDSN := dbx.DSNForRole(cfg.DB, dbx.RoleTradePaperLive)
+	tradePaperReplayDSN := dbx.DSNForRole(cfg.DB, dbx.RoleTradePaperReplay)
+	cfg.CTP.DBDSN = realtimeDSN
+	cfg.CTP.SharedMetaDSN = sharedDSN
+
+	r := &runner{cfg: cfg, sharedDSN: sharedDSN, finished: make(chan replay.TaskSnapshot, 1)}
+	if count, err := quotes.DefaultProductExchangeCache().EnsureLoadedFromDSN(sharedDSN); err != nil {
+		logger.Error("load product exchange cache failed", "error", err)
+	} else {
+		logger.Info("product exchange cache ready", "source", "replay_runner", "product_exchange_count", count)
+	}
+	status := quotes.NewRuntimeStatusCenter(time.Duration(cfg.Web.MarketOpenStaleSeconds) * time.Second)
+
+	var busLog *bus.FileLog
+	if cfg.CTP.IsBusEnabled() {
+		busPath := strings.TrimSpace(cfg.CTP.BusLogPath)
+		if busPath == "" {
+			busPath = filepath.Join(cfg.CTP.FlowPath, "bus")
+		}
+		busLog = bus.NewFileLog(busPath, time.Duration(cfg.CTP.BusFlushMS)*time.Millisecond)
+	}
+	db, err := dbx.Open(replayDSN)
+	if err != nil {
+		return nil, fmt.Errorf("open replay dedup db: %w", err)
+	}
+	r.closers = append(r.closers, db.Close)
+	store, err := bus.NewConsumerStore(db)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("init replay dedup store: %w", err)
+	}
+	r.replay = replay.NewService(busLog, store, cfg.CTP.IsReplayAllowOrderCommandDispatch())
+
+	replayCfg := cfg.CTP
+	replayCfg.DBDSN = replayDSN
+	if r.sink, err = quotes.NewReplaySink(replayCfg, status); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("init replay quotes sink: %w", err)
+	}
+	r.closers = append(r.closers, r.sink.Close)
+	r.replay.RegisterConsumer("quotes.replay_sink", r.sink.ConsumeBusEvent)
+
+	if r.paper, err = trade.NewPaperService(cfg.Trade, "paper_replay", tradePaperReplayDSN, status.QueueRegistry()); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("init paper replay trade service: %w", err)
+	}
+	r.closers = append(r.closers, r.paper.Close)
+	if err := r.paper.Start(); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("start paper replay trade service: %w", err)
+	}
+	r.replay.RegisterConsumer("trade.paper_replay", r.paper.ConsumeBusEvent)
+	r.papers = append(r.papers, r.paper)
+	for _, item := range cfg.Trade.Accounts {
+		if !item.IsEnabled() {
+			continue
+		}
+		paperReplayID := trade.PaperAccountID(trade.PaperReplayAccountID, item.AccountID)
+		svc, err := trade.NewPaperService(cfg.Trade.ForAccount(item), paperReplayID, tradePaperReplayDSN, status.QueueRegistry())
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("init paper replay trade service for sub account %s: %w", item.AccountID, err)
+		}
+		r.closers = append(r.closers, svc.Close)
+		if err := svc.Start(); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("start paper replay trade service for sub account %s: %w", item.AccountID, err)
+		}
+		r.replay.RegisterConsumer("trade."+paperReplayID, svc.ConsumeBusEvent)
+		r.papers = append(r.papers, svc)
+	}
+
+	// 合约乘数取自实盘模拟账户的合约元数据，口径与 web 端组合回测和 A/B 回放一致。
+	contracts, err := trade.NewPaperServiceWithMeta(cfg.Trade, cfg.CTP, "paper_live", tradePaperLiveDSN, status.QueueRegistry())
+	if err != nil {
+		logger.Warn("init paper live contract metadata failed; volume multiples default to 1", "error", err)
+	} else {
+		r.closers = append(r.closers, contracts.Close)
+	}
+	contract := func(symbol string) (string, float64) {
+		exchangeID, _ := quotes.DefaultProductExchangeCache().InferExchangeByProduct(symbol)
+		if contracts == nil {
+			return exchangeID, 1
+		}
+		return exchangeID, contracts.ContractVolumeMultiple(symbol, exchangeID)
+	}
+
+	if r.strategy, err = strategy.NewManager(cfg.Strategy, tradeLiveDSN, status.QueueRegistry()); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("init strategy manager: %w", err)
+	}
+	r.closers = append(r.closers, r.strategy.Close)
+	r.strategy.SetBacktestMarketDSN(realtimeDSN)
+	r.strategy.SetMarketDataDSNs(realtimeDSN, replayDSN, sharedDSN)
+	r.strategy.SetReplayLedgerFactory(backtest.NewReplayLedgerFactory(contract))
+	r.strategy.SetContractMultiplier(func(symbol string) float64 {
+		_, multiple := contract(symbol)
+		return multiple
+	})
+
+	r.query = klinequery.NewServiceWithSessionDB(realtimeDSN, sharedDSN, searchindex.NewManager(realtimeDSN, 30*time.Second))
+	r.replay.RegisterKlineReplayHandler(r)
+	r.replay.RegisterTaskLifecycle("replay_runner.lifecycle", r)
+	return r, nil
+}
+
+func (r *runner) Close() {
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		if err := r.closers[i](); err != nil {
+			logger.Warn("replay runner close failed", "error", err)
+		}
+	}
+	r.closers = nil
+}
+
+// Run 启动规格中的策略实例和 A/B 对比，跑完回放任务后停止实例并收集报告。
+func (r *runner) Run(ctx context.Context, spec runSpec) runResult {
+	result := runResult{StartedAt: time.Now(), Instances: spec.instanceIDs()}
+	finish := func(err error) runResult {
+		result.FinishedAt = time.Now()
+		if err != nil && result.Error == "" {
+			result.Error = err.Error()
+		}
+		result.OK = result.Error == ""
+		return result
+	}
+	if !r.cfg.Strategy.IsEnabled() && len(result.Instances) > 0 {
+		return finish(errors.New("strategy is disabled in config"))
+	}
+	if err := r.strategy.Start(); err != nil {
+		return finish(fmt.Errorf("start strategy service: %w", err))
+	}
+	for _, inst := range spec.Instances {
+		inst.Status = strategy.InstanceStatusStopped
+		if err := r.strategy.SaveInstance(inst); err != nil {
+			return finish(fmt.Errorf("save strategy instance %s: %w", inst.InstanceID, err))
+		}
+	}
+	started := make([]string, 0, len(result.Instances))
+	defer func() {
+		for _, id := range started {
+			if err := r.strategy.StopInstance(id); err != nil {
+				logger.Warn("stop replay strategy instance failed", "instance_id", id, "error", err)
+			}
+		}
+	}()
+	for _, id := range result.Instances {
+		inst, err := r.instance(id)
+		if err != nil {
+			return finish(fmt.Errorf("load strategy instance %s: %w", id, err))
+		}
+		if !strings.EqualFold(strings.TrimSpace(inst.Mode), strategy.RunTypeReplay) {
+			return finish(fmt.Errorf("strategy instance %s is not a replay instance", id))
+		}
+		if err := r.strategy.StartInstance(id); err != nil {
+			return finish(fmt.Errorf("start strategy instance %s: %w", id, err))
+		}
+		started = append(started, id)
+	}
+	if spec.Compare != nil {
+		cmp, err := r.strategy.StartReplayComparison(*spec.Compare)
+		if err != nil {
+			return finish(fmt.Errorf("start replay comparison: %w", err))
+		}
+		result.CompareID = cmp.CompareID
+	}
+
+	req := spec.Replay.WithDefaults(replay.StartDefaults{
+		Mode:          r.cfg.CTP.ReplayDefaultMode,
+		Speed:         r.cfg.CTP.ReplayDefaultSpeed,
+		FlowPath:      r.cfg.CTP.FlowPath,
+		SharedMetaDSN: r.sharedDSN,
+	})
+	prepares := []replay.StartPrepareFunc{
+		func(context.Context, replay.StartRequest) error {
+			for _, svc := range r.papers {
+				if err := svc.ResetPaperReplay(); err != nil {
+					return fmt.Errorf("reset replay paper account %s failed: %w", svc.AccountID(), err)
+				}
+			}
+			return nil
+		},
+		func(_ context.Context, req replay.StartRequest) error {
+			if err := r.sink.PrepareReplayWindow(req); err != nil {
+				return fmt.Errorf("prepare replay window failed: %w", err)
+			}
+			return nil
+		},
+	}
+	task, err := r.replay.StartWithPrepare(req, prepares)
+	if err != nil {
+		return finish(fmt.Errorf("start replay: %w", err))
+	}
+	logger.Info("headless replay started", "name", spec.Name, "task_id", task.TaskID, "mode", task.Mode, "speed", task.Speed, "instances", strings.Join(result.Instances, ","))
+	result.Task = task
+
+	timer := time.NewTimer(spec.timeout)
+	defer timer.Stop()
+	select {
+	case snap := <-r.finished:
+		result.Task = snap
+	case <-timer.C:
+		result.TimedOut = true
+		result.Error = fmt.Sprintf("replay did not finish within %s", spec.timeout)
+		result.Task = r.stopAndWait()
+	case <-ctx.Done():
+		result.Error = "replay interrupted"
+		result.Task = r.stopAndWait()
+	}
+
+	switch {
+	case result.Task.Status != replay.StatusDone:
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("replay finished with status %s: %s", result.Task.Status, result.Task.LastError)
+		}
+	case result.Task.Errors > spec.MaxErrors:
+		result.Error = fmt.Sprintf("replay reported %d errors (max %d), last: %s", result.Task.Errors, spec.MaxErrors, result.Task.LastError)
+	}
+	result.InstanceErrs = make(map[string]string)
+	for _, id := range result.Instances {
+		if inst, err := r.instance(id); err == nil && inst.Status == strategy.InstanceStatusError {
+			result.InstanceErrs[id] = inst.LastError
+		}
+		if run, err := r.strategy.GetRun(strategy.ReplayReportRunID(result.Task.TaskID, id)); err == nil {
+			result.Runs = append(result.Runs, run)
+		} else {
+			logger.Warn("replay report run missing", "task_id", result.Task.TaskID, "instance_id", id, "error", err)
+		}
+	}
+	if len(result.InstanceErrs) > 0 && result.Error == "" {
+		result.Error = fmt.Sprintf("%d strategy instance(s) ended with errors", len(result.InstanceErrs))
+	}
+	return finish(nil)
+}
+
+func (r *runner) instance(id string) (strategy.StrategyInstance, error) {
+	items, err := r.strategy.ListInstances()
+	if err != nil {
+		return strategy.StrategyInstance{}, err
+	}
+	for _, item := range items {
+		if item.InstanceID == id {
+			return item, nil
+		}
+	}
+	return strategy.StrategyInstance{}, fmt.Errorf("strategy instance not found")
+}
+
+// stopAndWait 停止回放任务，并等待生命周期钩子完成报告结算。
+func (r *runner) stopAndWait() replay.TaskSnapshot {
+	snap, err := r.replay.Stop()
+	if err != nil {
+		logger.Warn("stop replay failed", "error", err)
+	}
+	select {
+	case finished := <-r.finished:
+		return finished
+	case <-time.After(stopGracePeriod):
+		return snap
+	}
+}
+
+// LoadKlineChunk 从实时库读取 K 线回放数据，与 web 回放会话共用 quotes.LoadKlineReplayChunk。
+func (r *runner) LoadKlineChunk(_ context.Context, req replay.KlineStartRequest, afterAdjusted time.Time, limit int) (replay.KlineChunk, error) {
+	return quotes.LoadKlineReplayChunk(r.query, req, afterAdjusted, limit)
+}
+
+// ConsumeKlineBar 把回放 K 线发布给策略（经 ReplaySink）并交给全部回放模拟账本撮合。
+func (r *runner) ConsumeKlineBar(_ context.Context, taskID string, req replay.KlineStartRequest, bar replay.KlineBar) error {
+	ledgers := make([]quotes.ReplayKlineConsumer, 0, len(r.papers))
+	for _, svc := range r.papers {
+		ledgers = append(ledgers, svc)
+	}
+	return quotes.ConsumeKlineReplayBar(r.sink, "", taskID, req, bar, ledgers)
+}
+
+// OnTaskFinished 补落最后一根 bar、结算策略回放报告，然后通知 Run 任务已结束。
+func (r *runner) OnTaskFinished(ctx context.Context, snap replay.TaskSnapshot) error {
+	err := r.sink.OnTaskFinished(ctx, snap)
+	r.strategy.FinalizeReplayReports(snap.TaskID, snap.Status)
+	select {
+	case r.finished <- snap:
+	default:
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+// spec.go 负责读取和校验无界面回放的规格文件（JSON 或 YAML）。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/strategy"
+)
+
+const defaultRunTimeout = 6 * time.Hour
+
+// runSpec 描述一次无界面回放：回放参数、参与的策略实例、可选的 A/B 对比和报告输出位置。
+type runSpec struct {
+	// Name 是这次回放的名称，用作报告目录前缀。
+	Name string `json:"name"`
+	// Replay 与 POST /api/replay/start 的请求体相同。
+	Replay replay.StartRequest `json:"replay"`
+	// Instances 是回放前写入并启动的回放策略实例，mode 为空时按 replay 处理。
+	Instances []strategy.StrategyInstance `json:"instances,omitempty"`
+	// InstanceIDs 是已存在、直接启动的回放策略实例。
+	InstanceIDs []string `json:"instance_ids,omitempty"`
+	// Compare 非空时对参与实例做 A/B 对比，instance_ids 为空时取全部实例。
+	Compare *strategy.ReplayCompareRequest `json:"compare,omitempty"`
+	// OutputDir 是报告根目录，每次运行写到其下的 <name>-<时间> 子目录。
+	OutputDir string `json:"output_dir,omitempty"`
+	// Timeout 是整次回放的最长时长，例如 "90m"，默认 6h。
+	Timeout string `json:"timeout,omitempty"`
+	// MaxErrors 是允许的回放分发错误数，超过时以失败退出。
+	MaxErrors int64 `json:"max_errors,omitempty"`
+
+	timeout time.Duration
+}
+
+// loadRunSpec 按扩展名读取规格文件，.yaml/.yml 走内置 YAML 子集解析，其它按 JSON 处理。
+func loadRunSpec(path string) (runSpec, error) {
+	var spec runSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		tree, err := parseYAML(data)
+		if err != nil {
+			return spec, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if data, err = json.Marshal(tree); err != nil {
+			return spec, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&spec); err != nil {
+		return spec, fmt.Errorf("decode %s: %w", path, err)
+	}
+	if err := spec.normalize(); err != nil {
+		return spec, fmt.Errorf("invalid spec %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+func (s *runSpec) normalize() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		s.Name = "replay"
+	}
+	if strings.ContainsAny(s.Name, `/\`) {
+		return fmt.Errorf("name must not contain path separators")
+	}
+	s.timeout = defaultRunTimeout
+	if strings.TrimSpace(s.Timeout) != "" {
+		d, err := time.ParseDuration(strings.TrimSpace(s.Timeout))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", s.Timeout)
+		}
+		s.timeout = d
+	}
+	if s.MaxErrors < 0 {
+		return fmt.Errorf("max_errors must be >= 0")
+	}
+	seen := make(map[string]struct{})
+	for i := range s.Instances {
+		inst := &s.Instances[i]
+		inst.InstanceID = strings.TrimSpace(inst.InstanceID)
+		if inst.InstanceID == "" || strings.TrimSpace(inst.StrategyID) == "" {
+			return fmt.Errorf("instances[%d]: instance_id and strategy_id are required", i)
+		}
+		if strings.TrimSpace(inst.Mode) == "" {
+			inst.Mode = strategy.RunTypeReplay
+		}
+		if !strings.EqualFold(strings.TrimSpace(inst.Mode), strategy.RunTypeReplay) {
+			return fmt.Errorf("instances[%d]: mode must be %s", i, strategy.RunTypeReplay)
+		}
+		if _, dup := seen[inst.InstanceID]; dup {
+			return fmt.Errorf("duplicate instance %s", inst.InstanceID)
+		}
+		seen[inst.InstanceID] = struct{}{}
+	}
+	for _, id := range s.InstanceIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			return fmt.Errorf("instance_ids must not contain empty ids")
+		}
+		if _, dup := seen[id]; dup {
+			return fmt.Errorf("duplicate instance %s", id)
+		}
+		seen[id] = struct{}{}
+	}
+	if s.Compare != nil && len(s.Compare.InstanceIDs) == 0 {
+		s.Compare.InstanceIDs = s.instanceIDs()
+	}
+	return nil
+}
+
+// instanceIDs 返回规格中全部参与实例，新建实例在前。
+func (s runSpec) instanceIDs() []string {
+	out := make([]string, 0, len(s.Instances)+len(s.InstanceIDs))
+	for _, inst := range s.Instances {
+		out = append(out, inst.InstanceID)
+	}
+	for _, id := range s.InstanceIDs {
+		out = append(out, strings.TrimSpace(id))
+	}
+	return out
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRunSpecYAMLMatchesJSON(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "nightly.yaml")
+	jsonPath := filepath.Join(dir, "nightly.json")
+	if err := os.WriteFile(yamlPath, []byte(`---
+# 夜间回归回放
+name: nightly-rb
+replay:
+  mode: tick
+  speed: 0
+  trading_day_from: "20260330"
+  trading_day_to: '20260401'
+  fast_forward_gaps: true
+  topics: [tick, "bar"]
+instances:
+  - instance_id: ma20-a   # 基准
+    strategy_id: ma20_weak
+    symbols:
+    - rb2605
+    timeframe: 1m
+    params: {fast: 5, note: "a: b # c", ratio: 0.5, off: null}
+  - instance_id: ma20-b
+    strategy_id: ma20_weak
+    symbols: [rb2605]
+    timeframe: 1m
+instance_ids:
+  - existing
+compare:
+  label: "A vs B"
+timeout: 90m
+max_errors: 3
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, []byte(`{
+  "name": "nightly-rb",
+  "replay": {"mode": "tick", "speed": 0, "trading_day_from": "20260330", "trading_day_to": "20260401", "fast_forward_gaps": true, "topics": ["tick", "bar"]},
+  "instances": [
+    {"instance_id": "ma20-a", "strategy_id": "ma20_weak", "symbols": ["rb2605"], "timeframe": "1m", "params": {"fast": 5, "note": "a: b # c", "ratio": 0.5, "off": null}},
+    {"instance_id": "ma20-b", "strategy_id": "ma20_weak", "symbols": ["rb2605"], "timeframe": "1m"}
+  ],
+  "instance_ids": ["existing"],
+  "compare": {"label": "A vs B"},
+  "timeout": "90m",
+  "max_errors": 3
+}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fromYAML, err := loadRunSpec(yamlPath)
+	if err != nil {
+		t.Fatalf("load yaml spec: %v", err)
+	}
+	fromJSON, err := loadRunSpec(jsonPath)
+	if err != nil {
+		t.Fatalf("load json spec: %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Fatalf("yaml spec = %+v\njson spec = %+v", fromYAML, fromJSON)
+	}
+	if fromYAML.timeout != 90*time.Minute || fromYAML.Instances[0].Mode != "replay" || fromYAML.Instances[0].Params["note"] != "a: b # c" {
+		t.Fatalf("normalized spec = %+v", fromYAML)
+	}
+	if got := fromYAML.Compare.InstanceIDs; !reflect.DeepEqual(got, []string{"ma20-a", "ma20-b", "existing"}) {
+		t.Fatalf("compare instance ids = %v", got)
+	}
+}
+
+func TestLoadRunSpecRejectsInvalidSpecs(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"unknown.json":     `{"name": "x", "replya": {}}`,
+		"bad_indent.yaml":  "name: x\n  replay: {}\n",
+		"dup.yaml":         "name: x\nname: y\n",
+		"live_mode.yaml":   "instances:\n  - instance_id: a\n    strategy_id: s\n    mode: realtime\n",
+		"dup_inst.yaml":    "instances:\n  - {instance_id: a, strategy_id: s}\ninstance_ids: [a]\n",
+		"timeout.json":     `{"timeout": "soon"}`,
+		"block_text.yaml":  "name: |\n  x\n",
+		"unterminated.yml": "name: \"x\n",
+	}
+	dir := t.TempDir()
+	for name, body := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRunSpec(path); err == nil {
+			t.Errorf("loadRunSpec(%s) should fail", name)
+		}
+	}
+}
+
+func TestParseYAMLRejectsUnsupportedSyntax(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"anchor":          "base: &b {fast: 5}\n",
+		"alias_in_flow":   "symbols: [*rb]\n",
+		"tag":             "speed: !!int 0\n",
+		"merge_key":       "params:\n  <<: {fast: 5}\n",
+		"folded_text":     "name: >\n  x\n",
+		"plain_multiline": "name: nightly\n  rb\n",
+		"flow_multiline":  "topics: [tick,\n  bar]\n",
+		"seq_multiline":   "symbols:\n  - rb2605\n    rb2610\n",
+		"complex_key":     "? name\n: x\n",
+		"directive":       "%YAML 1.2\n---\nname: x\n",
+		"second_doc":      "name: x\n---\nname: y\n",
+		"reserved":        "name: @nightly\n",
+		"inline_seq":      "symbols: - rb2605\n",
+		"flow_dup_key":    "params: {fast: 5, fast: 6}\n",
+	}
+	for name, body := range cases {
+		_, err := parseYAML([]byte(body))
+		if err == nil {
+			t.Errorf("%s: parseYAML(%q) should fail", name, body)
+			continue
+		}
+		if name != "flow_dup_key" && !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("%s: error = %v, want explicit \"not supported\"", name, err)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+// yaml.go 负责解析回放规格文件使用的 YAML 子集。
+// 仓库没有引入 YAML 依赖，这里只支持规格文件需要的写法：
+//   - 空格缩进的块状 map 和列表，列表可与父级 key 同列；
+//   - 单行的行内 [..] / {..}，可嵌套；
+//   - 单双引号字符串、行尾 # 注释、开头一个 "---"；
+//   - 标量按 YAML 1.2 core schema 识别 null/true/false/整数/小数，其余按字符串，
+//     yes/no/on/off 和日期都是字符串。
+//
+// 子集之外的写法一律报 "not supported" 错误，不会按字符串静默吞掉：锚点/别名/标签、
+// 合并键 <<、|/> 块文本、跨行的普通标量或行内集合、? 复杂键、% 指令、多文档、
+// 以 @ 或 ` 开头的保留标量，以及行内 map 的重复键。
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type yamlLine struct {
+	no     int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML 把 YAML 文本解析成 map[string]any / []any / 标量组成的树，结构与 encoding/json 解码结果一致。
+func parseYAML(data []byte) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t")
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tab indentation is not allowed", i+1)
+		}
+		if len(lines) == 0 && trimmed == "---" {
+			continue
+		}
+		switch {
+		case trimmed == "---" || trimmed == "...":
+			return nil, fmt.Errorf("yaml line %d: multiple documents are not supported", i+1)
+		case strings.HasPrefix(trimmed, "%"):
+			return nil, fmt.Errorf("yaml line %d: directives are not supported", i+1)
+		case trimmed == "?" || strings.HasPrefix(trimmed, "? "):
+			return nil, fmt.Errorf("yaml line %d: complex keys are not supported", i+1)
+		}
+		lines = append(lines, yamlLine{no: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	p := &yamlParser{lines: lines}
+	out, err := p.parseBlock(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("yaml line %d: unexpected indentation", p.lines[p.pos].no)
+	}
+	return out, nil
+}
+
+func (p *yamlParser) parseBlock(indent int) (any, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	out := make(map[string]any)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isYAMLSeqItem(line.text) {
+			return nil, fmt.Errorf("yaml line %d: unexpected list item in mapping", line.no)
+		}
+		key, rest, ok, err := splitYAMLKey(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %w", line.no, err)
+		}
+		if !ok {
+			return nil, fmt.Errorf("yaml line %d: expected \"key: value\"", line.no)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("yaml line %d: duplicate key %q", line.no, key)
+		}
+		p.pos++
+		var value any
+		if rest != "" {
+			value, err = parseYAMLValue(rest)
+			if err != nil {
+				return nil, fmt.Errorf("yaml line %d: %w", line.no, err)
+			}
+			if err := p.checkNoContinuation(indent); err != nil {
+				return nil, err
+			}
+		} else if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			// 列表允许和父级 key 同一缩进，这是 YAML 的常见写法。
+			if next.indent > indent || (next.indent == indent && isYAMLSeqItem(next.text)) {
+				value, err = p.parseBlock(next.indent)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		out[key] = value
+	}
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("yaml line %d: unexpected indentation", p.lines[p.pos].no)
+	}
+	return out, nil
+}
+
+func (p *yamlParser) parseSeq(indent int) ([]any, error) {
+	out := make([]any, 0)
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		body := strings.TrimLeft(line.text[1:], " ")
+		if body == "" {
+			p.pos++
+			var value any
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				var err error
+				if value, err = p.parseBlock(p.lines[p.pos].indent); err != nil {
+					return nil, err
+				}
+			}
+			out = append(out, value)
+			continue
+		}
+		if _, _, isKey, _ := splitYAMLKey(body); isKey && !strings.HasPrefix(body, "[") && !strings.HasPrefix(body, "{") {
+			// "- key: value" 开启一个 map，后续键按 "- " 之后的列对齐。
+			p.lines[p.pos] = yamlLine{no: line.no, indent: indent + len(line.text) - len(body), text: body}
+			value, err := p.parseMap(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, value)
+			continue
+		}
+		if isYAMLSeqItem(body) {
+			return nil, fmt.Errorf("yaml line %d: nested inline list items are not supported", line.no)
+		}
+		value, err := parseYAMLValue(body)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %w", line.no, err)
+		}
+		p.pos++
+		if err := p.checkNoContinuation(indent); err != nil {
+			return nil, err
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
+
+// checkNoContinuation 拒绝单行值之后更深缩进的续行，即跨行的普通标量或行内集合。
+func (p *yamlParser) checkNoContinuation(indent int) error {
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return fmt.Errorf("yaml line %d: multi-line values are not supported", p.lines[p.pos].no)
+	}
+	return nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey 拆出 "key: value" 的 key 和剩余文本；ok=false 表示这一行不是 map 项。
+func splitYAMLKey(text string) (string, string, bool, error) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		key, n, err := readYAMLQuoted(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		rest := strings.TrimLeft(text[n:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false, nil
+		}
+		rest = rest[1:]
+		if rest != "" && rest[0] != ' ' {
+			return "", "", false, nil
+		}
+		return key, strings.TrimSpace(rest), true, nil
+	}
+	idx := strings.Index(text, ": ")
+	if idx < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		idx = len(text) - 1
+	}
+	key := strings.TrimSpace(text[:idx])
+	if key == "" || strings.ContainsAny(key[:1], "[{") {
+		return "", "", false, nil
+	}
+	if key == "<<" {
+		return "", "", false, fmt.Errorf("merge keys are not supported")
+	}
+	if err := checkYAMLPlain(key); err != nil {
+		return "", "", false, err
+	}
+	return key, strings.TrimSpace(text[idx+1:]), true, nil
+}
+
+func parseYAMLValue(text string) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		value, n, err := parseYAMLFlow(text, 0)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text[n:]) != "" {
+			return nil, fmt.Errorf("unexpected text after %q", text[:n])
+		}
+		return value, nil
+	case strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'"):
+		value, n, err := readYAMLQuoted(text)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text[n:]) != "" {
+			return nil, fmt.Errorf("unexpected text after quoted string")
+		}
+		return value, nil
+	}
+	if err := checkYAMLPlain(text); err != nil {
+		return nil, err
+	}
+	return yamlPlainScalar(text), nil
+}
+
+// checkYAMLPlain 拒绝以子集外指示符开头的普通标量，避免把它们当成字符串。
+func checkYAMLPlain(text string) error {
+	switch {
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return fmt.Errorf("block scalars are not supported")
+	case strings.HasPrefix(text, "&") || strings.HasPrefix(text, "*") || strings.HasPrefix(text, "!"):
+		return fmt.Errorf("anchors, aliases and tags are not supported")
+	case strings.HasPrefix(text, "@") || strings.HasPrefix(text, "`") || strings.HasPrefix(text, "%"):
+		return fmt.Errorf("scalars starting with %q are not supported", text[:1])
+	case isYAMLSeqItem(text):
+		return fmt.Errorf("block list items after a key on the same line are not supported")
+	}
+	return nil
+}
+
+// parseYAMLFlow 解析从 start 开始的行内 [..] 或 {..}，返回值和结束位置。
+func parseYAMLFlow(text string, start int) (any, int, error) {
+	open := text[start]
+	closer := byte(']')
+	if open == '{' {
+		closer = '}'
+	}
+	var list []any
+	obj := make(map[string]any)
+	i := start + 1
+	for {
+		i = skipYAMLSpaces(text, i)
+		if i >= len(text) {
+			return nil, i, fmt.Errorf("unterminated %q: multi-line flow collections are not supported", string(open))
+		}
+		if text[i] == closer {
+			i++
+			break
+		}
+		var key string
+		if open == '{' {
+			k, n, err := readYAMLFlowItem(text, i, ":")
+			if err != nil {
+				return nil, n, err
+			}
+			s, ok := k.(string)
+			if !ok || n >= len(text) || text[n] != ':' {
+				return nil, n, fmt.Errorf("expected \"key: value\" inside {}")
+			}
+			key, i = s, skipYAMLSpaces(text, n+1)
+		}
+		value, n, err := readYAMLFlowItem(text, i, "")
+		if err != nil {
+			return nil, n, err
+		}
+		if open == '{' {
+			if _, dup := obj[key]; dup {
+				return nil, n, fmt.Errorf("duplicate key %q", key)
+			}
+			obj[key] = value
+		} else {
+			list = append(list, value)
+		}
+		i = skipYAMLSpaces(text, n)
+		if i < len(text) && text[i] == ',' {
+			i++
+			continue
+		}
+		if i < len(text) && text[i] == closer {
+			i++
+			break
+		}
+		return nil, i, fmt.Errorf("expected ',' or %q", string(closer))
+	}
+	if open == '{' {
+		return obj, i, nil
+	}
+	if list == nil {
+		list = []any{}
+	}
+	return list, i, nil
+}
+
+// readYAMLFlowItem 读取行内集合中的一个元素，extraStops 用于 {} 中以冒号结束 key。
+func readYAMLFlowItem(text string, i int, extraStops string) (any, int, error) {
+	if i >= len(text) {
+		return nil, i, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch text[i] {
+	case '[', '{':
+		return parseYAMLFlow(text, i)
+	case '"', '\'':
+		value, n, err := readYAMLQuoted(text[i:])
+		return value, i + n, err
+	}
+	end := i
+	for end < len(text) && !strings.ContainsRune(",]}"+extraStops, rune(text[end])) {
+		end++
+	}
+	raw := strings.TrimSpace(text[i:end])
+	if raw == "" {
+		return nil, end, fmt.Errorf("empty item in flow collection")
+	}
+	if err := checkYAMLPlain(raw); err != nil {
+		return nil, end, err
+	}
+	return yamlPlainScalar(raw), end, nil
+}
+
+func skipYAMLSpaces(text string, i int) int {
+	for i < len(text) && text[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// readYAMLQuoted 读取开头的引号字符串，返回内容和消耗的字节数。
+func readYAMLQuoted(text string) (string, int, error) {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case quote == '"' && text[i] == '\\':
+			i++
+		case text[i] == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == quote:
+			if quote == '\'' {
+				return strings.ReplaceAll(text[1:i], "''", "'"), i + 1, nil
+			}
+			value, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", i + 1, fmt.Errorf("invalid quoted string %s", text[:i+1])
+			}
+			return value, i + 1, nil
+		}
+	}
+	return "", len(text), fmt.Errorf("unterminated quoted string")
+}
+
+func yamlPlainScalar(text string) any {
+	switch text {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n
+	}
+	// 排除 inf/nan/十六进制等 ParseFloat 能接受但 JSON 无法表示的写法。
+	if f, err := strconv.ParseFloat(text, 64); err == nil && !strings.ContainsAny(strings.ToLower(text), "xpin_") {
+		return f
+	}
+	return text
+}
+
+// stripYAMLComment 去掉引号之外以 "#" 开头的注释。
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" [{,:-", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
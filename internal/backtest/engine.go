@@ -0,0 +1,421 @@
+// Package backtest 实现 Go 事件驱动的组合回测引擎。
+// 多个合约的历史 K 线按 adjusted_time 归并成一条时间线逐根推给策略运行时（Go 或 Python），
+// 信号经与实盘相同的 strategy.ExecutionEngine 风控规划后拆成"先平后开"的委托交给 trade.PaperBook 撮合，
+// 输出权益曲线、持仓与成交。撮合口径与 replay_paper/live_paper 一致，三者结果可以直接比较。
+package backtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/perf"
+	"ctp-future-kline/internal/strategy"
+	"ctp-future-kline/internal/trade"
+)
+
+// pageSize 是每次向 klinequery 拉取的 K 线数量，与 BarsFrom 的单页上限一致。
+const pageSize = 300
+
+// accountID 是回测账本写入委托和成交记录的账户标识。
+const accountID = "backtest"
+
+// BarSource 按时间向后分页读取历史 K 线，klinequery.Service 满足该接口。
+type BarSource interface {
+	BarsFrom(symbol string, kind string, variety string, timeframe string, afterAdjusted time.Time, limit int) (klinequery.BarsResponse, error)
+}
+
+// ContractResolver 返回合约的交易所代码与合约乘数；乘数 <=0 时按 1 计算。
+type ContractResolver func(symbol string) (exchangeID string, volumeMultiple float64)
+
+// EquityPoint 是权益曲线上的一个点，每个时间戳的所有合约 K 线处理完后记录一次。
+type EquityPoint struct {
+	// Time 是 K 线 adjusted_time。
+	Time time.Time `json:"time"`
+	// Balance 是动态权益。
+	Balance float64 `json:"balance"`
+	// Available 是可用资金。
+	Available float64 `json:"available"`
+	// Margin 是持仓占用保证金。
+	Margin float64 `json:"margin"`
+	// PositionProfit 是浮动盈亏。
+	PositionProfit float64 `json:"position_profit"`
+	// CloseProfit 是累计平仓盈亏。
+	CloseProfit float64 `json:"close_profit"`
+	// Commission 是累计手续费。
+	Commission float64 `json:"commission"`
+	// Drawdown 是相对历史最高权益的回撤金额，非正数。
+	Drawdown float64 `json:"drawdown"`
+}
+
+// Result 是组合回测的完整输出。
+type Result struct {
+	// Symbols 是实际参与回测的合约。
+	Symbols []string `json:"symbols"`
+	// Bars 是处理的 K 线总数。
+	Bars int `json:"bars"`
+	// Signals 是策略返回的有效信号数。
+	Signals int `json:"signals"`
+	// Blocked 是被执行风控拦截的信号数。
+	Blocked int `json:"blocked"`
+	// InitialBalance 是初始权益。
+	InitialBalance float64 `json:"initial_balance"`
+	// Performance 是由成交和逐根 K 线盯市计算的绩效报告，与回放报告口径一致。
+	Performance perf.Report `json:"performance"`
+	// Account 是回测结束时的账户快照。
+	Account trade.TradingAccountSnapshot `json:"account"`
+	// Equity 是权益曲线。
+	Equity []EquityPoint `json:"equity"`
+	// Positions 是回测结束时的持仓。
+	Positions []trade.PositionSnapshot `json:"positions"`
+	// Fills 是全部成交。
+	Fills []trade.TradeRecord `json:"fills"`
+	// PendingOrders 是回测结束时仍未成交的挂单。
+	PendingOrders []trade.OrderRecord `json:"pending_orders"`
+	// Journal 是 FIFO 配对后的逐笔平仓交易。
+	Journal []trade.JournalEntry `json:"journal"`
+}
+
+// fillHandler 是可接收回测成交回报的运行时，Go 运行时实现该接口。
+type fillHandler interface {
+	OnFill(context.Context, strategy.FillEvent) error
+}
+
+// barCursor 顺序读取单个合约的 K 线，耗尽当前页后按最后一根的时间继续翻页。
+type barCursor struct {
+	source    BarSource
+	symbol    string
+	timeframe string
+	end       time.Time
+	after     time.Time
+	page      []klinequery.KlineBar
+	pos       int
+	done      bool
+}
+
+func (c *barCursor) peek() (klinequery.KlineBar, bool, error) {
+	if c.done {
+		return klinequery.KlineBar{}, false, nil
+	}
+	if c.pos >= len(c.page) {
+		resp, err := c.source.BarsFrom(c.symbol, "", "", c.timeframe, c.after, pageSize)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && len(resp.Bars) == 0) {
+			c.done = true
+			return klinequery.KlineBar{}, false, nil
+		}
+		if err != nil {
+			return klinequery.KlineBar{}, false, fmt.Errorf("load backtest bars for %s failed: %w", c.symbol, err)
+		}
+		c.page = resp.Bars
+		c.pos = 0
+		c.after = time.Unix(resp.Bars[len(resp.Bars)-1].AdjustedTime, 0)
+	}
+	bar := c.page[c.pos]
+	if !c.end.IsZero() && time.Unix(bar.AdjustedTime, 0).After(c.end) {
+		c.done = true
+		return klinequery.KlineBar{}, false, nil
+	}
+	return bar, true, nil
+}
+
+// Engine 持有回测的数据源与合约信息，可以并发执行多次回测。
+type Engine struct {
+	source   BarSource
+	resolver ContractResolver
+}
+
+// NewEngine 创建组合回测引擎；resolver 为空时交易所留空、合约乘数按 1。
+func NewEngine(source BarSource, resolver ContractResolver) *Engine {
+	if resolver == nil {
+		resolver = func(string) (string, float64) { return "", 1 }
+	}
+	return &Engine{source: source, resolver: resolver}
+}
+
+// RunBacktest 执行回测并转换为 strategy.BacktestResponse，签名与 strategy.PortfolioBacktester 一致。
+func (e *Engine) RunBacktest(ctx context.Context, rt strategy.BacktestRuntime, cfg strategy.PortfolioBacktestConfig) (strategy.BacktestResponse, error) {
+	result, err := e.Run(ctx, rt, cfg)
+	if err != nil {
+		return strategy.BacktestResponse{}, err
+	}
+	return result.Response(cfg.Instance.InstanceID), nil
+}
+
+// Run 在 rt 上启动 cfg.Instance，按时间顺序回放全部合约的 K 线并撮合策略委托。
+// 运行时返回错误或 ctx 结束时中止回测；实例在返回前总会被停止。
+func (e *Engine) Run(ctx context.Context, rt strategy.BacktestRuntime, cfg strategy.PortfolioBacktestConfig) (Result, error) {
+	if rt == nil {
+		return Result{}, fmt.Errorf("strategy runtime is not available")
+	}
+	if e.source == nil {
+		return Result{}, fmt.Errorf("backtest bar source is not configured")
+	}
+	symbols := strategy.NormalizeBacktestSymbols(cfg.Symbols)
+	if len(symbols) == 0 {
+		return Result{}, fmt.Errorf("backtest symbols are required")
+	}
+	inst := cfg.Instance
+	if strings.TrimSpace(inst.InstanceID) == "" {
+		return Result{}, fmt.Errorf("backtest instance_id is required")
+	}
+	inst.Mode = strategy.RunTypeBacktest
+	inst.Symbols = symbols
+	inst.Timeframe = cfg.Timeframe
+	if strings.TrimSpace(inst.Timeframe) == "" {
+		inst.Timeframe = "1m"
+	}
+	exchanges := make(map[string]string, len(symbols))
+	multiples := make(map[string]float64, len(symbols))
+	for _, symbol := range symbols {
+		exchanges[symbol], multiples[symbol] = e.resolver(symbol)
+	}
+	book := trade.NewPaperBook(trade.PaperBookConfig{
+		AccountID:      accountID,
+		InitialBalance: cfg.InitialBalance,
+		VolumeMultiple: func(symbol string, _ string) float64 { return multiples[strings.ToLower(symbol)] },
+	})
+	result := Result{Symbols: symbols, InitialBalance: book.Account().StaticBalance}
+
+	if err := rt.LoadStrategy(ctx, strategy.LoadStrategyRequest{StrategyID: inst.StrategyID}); err != nil {
+		return result, err
+	}
+	if err := rt.StartInstance(ctx, strategy.StartInstanceRequest{Instance: inst}); err != nil {
+		return result, err
+	}
+	defer func() {
+		_ = rt.StopInstance(context.Background(), strategy.StopInstanceRequest{InstanceID: inst.InstanceID})
+	}()
+
+	cursors := make([]*barCursor, 0, len(symbols))
+	for _, symbol := range symbols {
+		cursors = append(cursors, &barCursor{source: e.source, symbol: symbol, timeframe: inst.Timeframe, end: cfg.End, after: cfg.Start})
+	}
+	exec := strategy.NewExecutionEngine()
+	fills, _ := rt.(fillHandler)
+	tracker := perf.NewTracker(result.InitialBalance)
+	peak := result.InitialBalance
+	var lastTime time.Time
+	recordEquity := func(at time.Time) {
+		account := book.Account()
+		peak = math.Max(peak, account.Balance)
+		point := EquityPoint{
+			Time:           at,
+			Balance:        account.Balance,
+			Available:      account.Available,
+			Margin:         account.Margin,
+			PositionProfit: account.PositionProfit,
+			CloseProfit:    account.CloseProfit,
+			Commission:     account.Commission,
+			Drawdown:       account.Balance - peak,
+		}
+		result.Equity = append(result.Equity, point)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		cursor, bar, err := nextBar(cursors)
+		if err != nil {
+			return result, err
+		}
+		if cursor == nil {
+			break
+		}
+		cursor.pos++
+		at := time.Unix(bar.AdjustedTime, 0)
+		if !lastTime.IsZero() && at.After(lastTime) {
+			recordEquity(lastTime)
+		}
+		lastTime = at
+		result.Bars++
+		symbol := cursor.symbol
+		exchangeID := exchanges[symbol]
+
+		for _, fill := range book.OnBar(trade.PaperMarketBar{
+			Symbol:       symbol,
+			ExchangeID:   exchangeID,
+			Timeframe:    inst.Timeframe,
+			AdjustedTime: at,
+			DataTime:     time.Unix(bar.DataTime, 0),
+			Open:         bar.Open,
+			High:         bar.High,
+			Low:          bar.Low,
+			Close:        bar.Close,
+		}) {
+			tracker.Fill(perf.Fill{
+				Symbol:         fill.Symbol,
+				Direction:      fill.Direction,
+				Price:          fill.Price,
+				Volume:         fill.Volume,
+				VolumeMultiple: multiples[symbol],
+				Commission:     trade.PaperCommission(fill),
+				Time:           fill.TradeTime,
+			})
+			if fills == nil {
+				continue
+			}
+			if err := fills.OnFill(ctx, fillEvent(inst.InstanceID, fill, multiples[symbol])); err != nil {
+				return result, err
+			}
+		}
+		tracker.Mark(symbol, bar.Close, at)
+
+		account := book.Account()
+		decision, err := rt.OnReplayBar(ctx, strategy.DecisionRequest{
+			Instance:        inst,
+			Symbol:          symbol,
+			EventTime:       at.Format(time.RFC3339),
+			Mode:            strategy.RunTypeBacktest,
+			CurrentPosition: float64(book.NetPosition(symbol)),
+			Account: map[string]any{
+				"balance":   account.Balance,
+				"available": account.Available,
+				"margin":    account.Margin,
+			},
+			Bar: &strategy.BarEvent{
+				InstrumentID: symbol,
+				Exchange:     exchangeID,
+				DataTime:     time.Unix(bar.DataTime, 0),
+				AdjustedTime: at,
+				Period:       inst.Timeframe,
+				Open:         bar.Open,
+				High:         bar.High,
+				Low:          bar.Low,
+				Close:        bar.Close,
+				Volume:       bar.Volume,
+				OpenInterest: bar.OpenInterest,
+			},
+		})
+		if err != nil {
+			return result, fmt.Errorf("strategy decision at %s %s failed: %w", symbol, at.Format(time.RFC3339), err)
+		}
+		if decision.NoSignal {
+			continue
+		}
+		result.Signals++
+		book.CancelPending(symbol)
+		current := float64(book.NetPosition(symbol))
+		plan := exec.PlanWithCurrent(inst, current, decision.TargetPosition, strategy.RunTypeBacktest)
+		if plan.RiskStatus != strategy.RiskStatusAllowed {
+			result.Blocked++
+			continue
+		}
+		for _, leg := range orderLegs(int(current), int(math.Round(plan.TargetPosition))) {
+			if _, err := book.Submit(trade.SubmitOrderRequest{
+				AccountID:  accountID,
+				Symbol:     symbol,
+				ExchangeID: exchangeID,
+				Direction:  leg.direction,
+				OffsetFlag: leg.offsetFlag,
+				LimitPrice: bar.Close,
+				Volume:     leg.volume,
+				Reason:     "strategy",
+				ClientTag:  trade.StrategyClientTag(inst.InstanceID),
+			}); err != nil {
+				return result, err
+			}
+		}
+	}
+	if !lastTime.IsZero() {
+		recordEquity(lastTime)
+	}
+	result.Account = book.Account()
+	result.Positions = book.Positions()
+	result.Fills = book.Trades()
+	result.PendingOrders = book.PendingOrders()
+	result.Journal = book.Journal()
+	result.Performance = tracker.Report()
+	return result, nil
+}
+
+// nextBar 返回时间最早的下一根 K 线；同一时间戳按合约顺序处理，保证结果可复现。
+func nextBar(cursors []*barCursor) (*barCursor, klinequery.KlineBar, error) {
+	var best *barCursor
+	var bestBar klinequery.KlineBar
+	for _, cursor := range cursors {
+		bar, ok, err := cursor.peek()
+		if err != nil {
+			return nil, klinequery.KlineBar{}, err
+		}
+		if ok && (best == nil || bar.AdjustedTime < bestBar.AdjustedTime) {
+			best, bestBar = cursor, bar
+		}
+	}
+	return best, bestBar, nil
+}
+
+type orderLeg struct {
+	direction  string
+	offsetFlag string
+	volume     int
+}
+
+// orderLegs 把净持仓从 current 调到 target，跨零时与实盘一样拆成先平后开两笔。
+func orderLegs(current int, target int) []orderLeg {
+	var legs []orderLeg
+	if current > 0 && target < current {
+		legs = append(legs, orderLeg{direction: "sell", offsetFlag: "close", volume: current - max(target, 0)})
+	}
+	if current < 0 && target > current {
+		legs = append(legs, orderLeg{direction: "buy", offsetFlag: "close", volume: min(target, 0) - current})
+	}
+	if target > max(current, 0) {
+		legs = append(legs, orderLeg{direction: "buy", offsetFlag: "open", volume: target - max(current, 0)})
+	}
+	if target < min(current, 0) {
+		legs = append(legs, orderLeg{direction: "sell", offsetFlag: "open", volume: min(current, 0) - target})
+	}
+	return legs
+}
+
+func fillEvent(instanceID string, tr trade.TradeRecord, volumeMultiple float64) strategy.FillEvent {
+	return strategy.FillEvent{
+		InstanceID:     instanceID,
+		AccountID:      tr.AccountID,
+		Symbol:         tr.Symbol,
+		ExchangeID:     tr.ExchangeID,
+		TradeID:        tr.TradeID,
+		OrderRef:       tr.OrderRef,
+		Direction:      tr.Direction,
+		OffsetFlag:     tr.OffsetFlag,
+		Price:          tr.Price,
+		Volume:         tr.Volume,
+		TradeTime:      tr.TradeTime,
+		Commission:     trade.PaperCommission(tr),
+		VolumeMultiple: volumeMultiple,
+	}
+}
+
+// Response 把组合回测结果转换为与 Python 回测相同的 strategy.BacktestResponse 结构，便于归档和前端展示。
+// 摘要在绩效标量指标之外补充回测计数，参数优化按其中的指标排序。
+func (r Result) Response(runID string) strategy.BacktestResponse {
+	summary := r.Performance.Summary()
+	summary["engine"] = strategy.BacktestEnginePortfolio
+	summary["symbols"] = r.Symbols
+	summary["bars"] = r.Bars
+	summary["signals"] = r.Signals
+	summary["blocked"] = r.Blocked
+	summary["fills"] = len(r.Fills)
+	summary["final_balance"] = r.Account.Balance
+	summary["commission"] = r.Account.Commission
+	return strategy.BacktestResponse{
+		RunID:   runID,
+		Status:  "done",
+		Summary: summary,
+		Result: map[string]any{
+			"account":        r.Account,
+			"equity":         r.Equity,
+			"positions":      r.Positions,
+			"fills":          r.Fills,
+			"pending_orders": r.PendingOrders,
+			"journal":        r.Journal,
+			"performance":    r.Performance,
+		},
+	}
+}
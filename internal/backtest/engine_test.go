@@ -0,0 +1,181 @@
+package backtest
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/strategy"
+)
+
+// fakeBarSource 按 BarsFrom 的语义（严格大于 after、升序、无数据返回 sql.ErrNoRows）提供内存 K 线。
+type fakeBarSource struct {
+	bars  map[string][]klinequery.KlineBar
+	calls int
+}
+
+func (f *fakeBarSource) BarsFrom(symbol string, _ string, _ string, _ string, after time.Time, limit int) (klinequery.BarsResponse, error) {
+	f.calls++
+	var out []klinequery.KlineBar
+	for _, bar := range f.bars[symbol] {
+		if bar.AdjustedTime > after.Unix() && len(out) < min(limit, 2) {
+			out = append(out, bar)
+		}
+	}
+	if len(out) == 0 {
+		return klinequery.BarsResponse{}, sql.ErrNoRows
+	}
+	return klinequery.BarsResponse{Bars: out}, nil
+}
+
+// scriptedRuntime 按合约返回预设的目标仓位序列，并记录事件顺序和成交回报。
+type scriptedRuntime struct {
+	targets map[string][]float64
+	events  []string
+	fills   []strategy.FillEvent
+	started bool
+	stopped bool
+}
+
+func (r *scriptedRuntime) LoadStrategy(context.Context, strategy.LoadStrategyRequest) error {
+	return nil
+}
+
+func (r *scriptedRuntime) StartInstance(context.Context, strategy.StartInstanceRequest) error {
+	r.started = true
+	return nil
+}
+
+func (r *scriptedRuntime) StopInstance(context.Context, strategy.StopInstanceRequest) error {
+	r.stopped = true
+	return nil
+}
+
+func (r *scriptedRuntime) OnReplayBar(_ context.Context, req strategy.DecisionRequest) (strategy.SignalDecision, error) {
+	r.events = append(r.events, req.Symbol+"@"+req.Bar.AdjustedTime.Format("15:04"))
+	queue := r.targets[req.Symbol]
+	if len(queue) == 0 {
+		return strategy.SignalDecision{NoSignal: true}, nil
+	}
+	r.targets[req.Symbol] = queue[1:]
+	if math.IsNaN(queue[0]) {
+		return strategy.SignalDecision{NoSignal: true}, nil
+	}
+	return strategy.SignalDecision{TargetPosition: queue[0]}, nil
+}
+
+func (r *scriptedRuntime) OnFill(_ context.Context, fill strategy.FillEvent) error {
+	r.fills = append(r.fills, fill)
+	return nil
+}
+
+func testBars(base time.Time, closes ...float64) []klinequery.KlineBar {
+	out := make([]klinequery.KlineBar, 0, len(closes))
+	for i, c := range closes {
+		ts := base.Add(time.Duration(i) * time.Minute).Unix()
+		out = append(out, klinequery.KlineBar{AdjustedTime: ts, DataTime: ts, Open: c, High: c + 1, Low: c - 1, Close: c})
+	}
+	return out
+}
+
+func TestEngineRunMergesSymbolsAndReversesThroughPaperBook(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	source := &fakeBarSource{bars: map[string][]klinequery.KlineBar{
+		"rb2405": testBars(base, 100, 101, 102, 103, 104),
+		"i2405":  testBars(base.Add(time.Minute), 50, 51),
+	}}
+	skip := math.NaN()
+	rt := &scriptedRuntime{targets: map[string][]float64{
+		"rb2405": {1, skip, -1},
+	}}
+	engine := NewEngine(source, func(symbol string) (string, float64) {
+		if symbol == "rb2405" {
+			return "SHFE", 10
+		}
+		return "DCE", 100
+	})
+	result, err := engine.Run(context.Background(), rt, strategy.PortfolioBacktestConfig{
+		Instance:       strategy.StrategyInstance{InstanceID: "bt-1", StrategyID: "s"},
+		Symbols:        []string{"RB2405", "i2405", "rb2405"},
+		End:            base.Add(3 * time.Minute),
+		InitialBalance: 10_000,
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !rt.started || !rt.stopped {
+		t.Fatalf("runtime started=%v stopped=%v", rt.started, rt.stopped)
+	}
+	wantEvents := []string{"rb2405@09:00", "i2405@09:01", "rb2405@09:01", "i2405@09:02", "rb2405@09:02", "rb2405@09:03"}
+	if len(rt.events) != len(wantEvents) {
+		t.Fatalf("events = %v, want %v", rt.events, wantEvents)
+	}
+	for i := range wantEvents {
+		if rt.events[i] != wantEvents[i] {
+			t.Fatalf("events = %v, want %v", rt.events, wantEvents)
+		}
+	}
+	if result.Bars != 6 || result.Signals != 2 || len(result.Equity) != 4 {
+		t.Fatalf("bars=%d signals=%d equity=%d", result.Bars, result.Signals, len(result.Equity))
+	}
+	// 09:00 开多 1 手限价 100，09:01 成交；09:02 反手到 -1 拆成平多+开空限价 102，09:03 成交。
+	if len(result.Fills) != 3 || len(rt.fills) != 3 {
+		t.Fatalf("fills = %+v, runtime fills = %+v", result.Fills, rt.fills)
+	}
+	legs := []string{result.Fills[1].Direction + "/" + result.Fills[1].OffsetFlag, result.Fills[2].Direction + "/" + result.Fills[2].OffsetFlag}
+	if legs[0] != "sell/close" || legs[1] != "sell/open" || result.Fills[1].ExchangeID != "SHFE" {
+		t.Fatalf("reversal legs = %v fills=%+v", legs, result.Fills)
+	}
+	if len(result.Positions) != 1 || result.Positions[0].Direction != "short" || result.Positions[0].Position != 1 {
+		t.Fatalf("positions = %+v", result.Positions)
+	}
+	if math.Abs(result.Account.CloseProfit-20) > 1e-9 {
+		t.Fatalf("CloseProfit = %v, want (102-100)*10", result.Account.CloseProfit)
+	}
+	last := result.Equity[len(result.Equity)-1]
+	if !last.Time.Equal(base.Add(3*time.Minute)) || last.Balance != result.Account.Balance {
+		t.Fatalf("last equity = %+v, account = %+v", last, result.Account)
+	}
+	perf := result.Performance
+	if math.Abs(perf.FinalEquity-result.Account.Balance) > 1e-9 || len(perf.Equity) != len(result.Equity) {
+		t.Fatalf("performance final=%v equity=%d, account=%v", perf.FinalEquity, len(perf.Equity), result.Account.Balance)
+	}
+	if perf.Trades != len(result.Journal) || perf.Trades != 1 || perf.WinRate != 1 || perf.Exposure <= 0 || perf.Turnover <= 0 {
+		t.Fatalf("performance = %+v", perf)
+	}
+	resp := result.Response("run-1")
+	if resp.RunID != "run-1" || resp.Status != "done" || resp.Summary["fills"] != 3 || resp.Summary["sortino"] == nil {
+		t.Fatalf("response = %+v", resp)
+	}
+}
+
+func TestOrderLegsSplitReversalIntoCloseThenOpen(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		current, target int
+		want            []orderLeg
+	}{
+		{0, 2, []orderLeg{{"buy", "open", 2}}},
+		{2, 1, []orderLeg{{"sell", "close", 1}}},
+		{2, -3, []orderLeg{{"sell", "close", 2}, {"sell", "open", 3}}},
+		{-1, 2, []orderLeg{{"buy", "close", 1}, {"buy", "open", 2}}},
+		{-2, -2, nil},
+	}
+	for _, tc := range cases {
+		got := orderLegs(tc.current, tc.target)
+		if len(got) != len(tc.want) {
+			t.Fatalf("orderLegs(%d,%d) = %+v, want %+v", tc.current, tc.target, got, tc.want)
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Fatalf("orderLegs(%d,%d) = %+v, want %+v", tc.current, tc.target, got, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package backtest
+
+import (
+	"strings"
+
+	"ctp-future-kline/internal/strategy"
+	"ctp-future-kline/internal/trade"
+)
+
+// replayLedger 用 trade.PaperBook 实现 A/B 回放的实例账本，撮合和拆单规则与组合回测相同。
+type replayLedger struct {
+	instanceID string
+	resolver   ContractResolver
+	book       *trade.PaperBook
+	// multiples 缓存合约乘数，避免每笔成交都查合约信息。
+	multiples map[string]float64
+}
+
+// NewReplayLedgerFactory 返回为 A/B 回放实例创建内存模拟账本的工厂。
+func NewReplayLedgerFactory(resolver ContractResolver) strategy.ReplayLedgerFactory {
+	return func(inst strategy.StrategyInstance, initialBalance float64) strategy.ReplayLedger {
+		l := &replayLedger{instanceID: inst.InstanceID, resolver: resolver, multiples: make(map[string]float64)}
+		l.book = trade.NewPaperBook(trade.PaperBookConfig{
+			AccountID:      accountID + ":" + inst.InstanceID,
+			InitialBalance: initialBalance,
+			VolumeMultiple: func(symbol string, _ string) float64 { return l.volumeMultiple(symbol) },
+		})
+		return l
+	}
+}
+
+func (l *replayLedger) OnBar(bar strategy.BarEvent) []strategy.FillEvent {
+	symbol := strings.ToLower(strings.TrimSpace(bar.InstrumentID))
+	trades := l.book.OnBar(trade.PaperMarketBar{
+		Symbol:       symbol,
+		ExchangeID:   bar.Exchange,
+		Timeframe:    bar.Period,
+		AdjustedTime: bar.AdjustedTime,
+		DataTime:     bar.DataTime,
+		Open:         bar.Open,
+		High:         bar.High,
+		Low:          bar.Low,
+		Close:        bar.Close,
+	})
+	out := make([]strategy.FillEvent, 0, len(trades))
+	for _, tr := range trades {
+		out = append(out, fillEvent(l.instanceID, tr, l.volumeMultiple(tr.Symbol)))
+	}
+	return out
+}
+
+func (l *replayLedger) Rebalance(symbol string, target int, bar strategy.BarEvent) ([]strategy.ReplayLedgerOrder, error) {
+	symbol = strings.ToLower(strings.TrimSpace(symbol))
+	l.book.CancelPending(symbol)
+	exchangeID := bar.Exchange
+	if exchangeID == "" && l.resolver != nil {
+		exchangeID, _ = l.resolver(symbol)
+	}
+	var out []strategy.ReplayLedgerOrder
+	for _, leg := range orderLegs(l.book.NetPosition(symbol), target) {
+		rec, err := l.book.Submit(trade.SubmitOrderRequest{
+			AccountID:  accountID,
+			Symbol:     symbol,
+			ExchangeID: exchangeID,
+			Direction:  leg.direction,
+			OffsetFlag: leg.offsetFlag,
+			LimitPrice: bar.Close,
+			Volume:     leg.volume,
+			Reason:     "strategy",
+			ClientTag:  trade.StrategyClientTag(l.instanceID),
+		})
+		if err != nil {
+			return out, err
+		}
+		out = append(out, strategy.ReplayLedgerOrder{
+			EventTime:  bar.AdjustedTime,
+			Symbol:     rec.Symbol,
+			Direction:  rec.Direction,
+			OffsetFlag: rec.OffsetFlag,
+			Volume:     rec.VolumeTotalOriginal,
+			LimitPrice: rec.LimitPrice,
+		})
+	}
+	return out, nil
+}
+
+func (l *replayLedger) NetPosition(symbol string) int {
+	return l.book.NetPosition(strings.ToLower(strings.TrimSpace(symbol)))
+}
+
+func (l *replayLedger) volumeMultiple(symbol string) float64 {
+	symbol = strings.ToLower(symbol)
+	if multiple, ok := l.multiples[symbol]; ok {
+		return multiple
+	}
+	multiple := 1.0
+	if l.resolver != nil {
+		if _, resolved := l.resolver(symbol); resolved > 0 {
+			multiple = resolved
+		}
+	}
+	l.multiples[symbol] = multiple
+	return multiple
+}
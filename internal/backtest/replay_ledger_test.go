@@ -0,0 +1,40 @@
+package backtest
+
+import (
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/strategy"
+)
+
+func TestReplayLedgerRebalancesAcrossZero(t *testing.T) {
+	t.Parallel()
+
+	factory := NewReplayLedgerFactory(func(string) (string, float64) { return "SHFE", 10 })
+	ledger := factory(strategy.StrategyInstance{InstanceID: "a"}, 50_000)
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	bar := func(minute int, open, high, low, close float64) strategy.BarEvent {
+		return strategy.BarEvent{InstrumentID: "RB2405", Period: "1m", AdjustedTime: base.Add(time.Duration(minute) * time.Minute), Open: open, High: high, Low: low, Close: close}
+	}
+
+	orders, err := ledger.Rebalance("rb2405", 2, bar(0, 100, 100, 100, 100))
+	if err != nil || len(orders) != 1 || orders[0].Direction != "buy" || orders[0].OffsetFlag != "open" || orders[0].Volume != 2 {
+		t.Fatalf("open orders = %+v, err=%v", orders, err)
+	}
+	fills := ledger.OnBar(bar(1, 99, 101, 98, 100))
+	if len(fills) != 1 || fills[0].InstanceID != "a" || fills[0].Volume != 2 || fills[0].VolumeMultiple != 10 {
+		t.Fatalf("fills = %+v", fills)
+	}
+	if got := ledger.NetPosition("RB2405"); got != 2 {
+		t.Fatalf("net position = %d, want 2", got)
+	}
+
+	orders, err = ledger.Rebalance("rb2405", -1, bar(1, 99, 101, 98, 100))
+	if err != nil || len(orders) != 2 || orders[0].OffsetFlag != "close" || orders[0].Volume != 2 || orders[1].OffsetFlag != "open" || orders[1].Volume != 1 {
+		t.Fatalf("reversal orders = %+v, err=%v", orders, err)
+	}
+	ledger.OnBar(bar(2, 101, 102, 100, 101))
+	if got := ledger.NetPosition("rb2405"); got != -1 {
+		t.Fatalf("net position after reversal = %d, want -1", got)
+	}
+}
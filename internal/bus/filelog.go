@@ -89,6 +89,9 @@ func (l *FileLog) Iterate(ctx context.Context, opts ReadOptions, handler EventHa
 			return ctx.Err()
 		default:
 		}
+		if opts.StartTime != nil && fileEndsBefore(p, *opts.StartTime) {
+			continue
+		}
 		if err := l.iterateFile(ctx, p, opts, handler); err != nil {
 			return err
 		}
@@ -206,6 +209,18 @@ func (l *FileLog) iterateFile(ctx context.Context, path string, opts ReadOptions
 	return nil
 }
 
+// fileEndsBefore 用文件名里的日期做粗粒度索引：文件按写入日期切分，事件时间可能略早于写入时间，
+// 因此只跳过比 start 所在日期早两天及以上的文件。
+func fileEndsBefore(path string, start time.Time) bool {
+	name := filepath.Base(path)
+	day, err := time.ParseInLocation("20060102", strings.TrimSuffix(strings.TrimPrefix(name, "events-"), ".log"), start.Location())
+	if err != nil {
+		return false
+	}
+	startDay := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	return day.AddDate(0, 0, 1).Before(startDay)
+}
+
 func sameFilePath(a string, b string) bool {
 	return filepath.Clean(a) == filepath.Clean(b)
 }
@@ -14,8 +14,18 @@ const (
 	TopicBar          = "bar"
 	TopicOrderCommand = "order_command"
 	TopicOrderStatus  = "order_status"
+	// TopicTradingDaySettle 由多交易日回放在每个交易日结束时发出，模拟账户据此做日终结算。
+	TopicTradingDaySettle = "trading_day_settle"
 )
 
+// TradingDaySettlePayload 是交易日结算事件的载荷。
+type TradingDaySettlePayload struct {
+	// TradingDay 是结算的交易日，格式 YYYYMMDD。
+	TradingDay string `json:"trading_day"`
+	// Prices 是各合约（小写）的结算价，取当日最后一笔 tick 的结算价，没有时取最新价。
+	Prices map[string]float64 `json:"prices"`
+}
+
 type BusEvent struct {
 	// EventID 是总线事件唯一标识。
 	EventID string `json:"event_id"`
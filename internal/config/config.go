@@ -250,10 +250,31 @@ type StrategyConfig struct {
 	HealthcheckIntervalMS int `json:"healthcheck_interval_ms"`
 	// RequestTimeoutMS 是策略 HTTP 请求超时时间。
 	RequestTimeoutMS int `json:"request_timeout_ms"`
+	// Transport 是行情事件推送方式：stream 使用 WebSocket 长连接并在不可用时回落 HTTP，http 只用 HTTP push/poll。
+	Transport string `json:"transport"`
 	// BacktestOutputDir 是策略回测结果输出目录。
 	BacktestOutputDir string `json:"backtest_output_dir"`
+	// CheckpointIntervalBars 是实盘实例每处理多少根 K 线保存一次状态检查点，0 使用默认值，负数关闭。
+	CheckpointIntervalBars int `json:"checkpoint_interval_bars"`
+	// AccountLimits 是账户级单合约限额，同账户同合约的实例目标合计超限时按比例缩放。
+	AccountLimits []StrategyAccountLimit `json:"account_limits"`
 }
 
+// StrategyAccountLimit 是单个账户的组合分配限额，字段为 0 表示不限制。
+type StrategyAccountLimit struct {
+	// AccountID 是限额适用的账户，为空或 * 表示默认限额。
+	AccountID string `json:"account_id"`
+	// MaxLotsPerSymbol 是该账户单合约所有实例目标手数绝对值之和的上限。
+	MaxLotsPerSymbol float64 `json:"max_lots_per_symbol"`
+	// MaxNotionalPerSymbol 是该账户单合约所有实例目标名义价值之和的上限。
+	MaxNotionalPerSymbol float64 `json:"max_notional_per_symbol"`
+}
+
+const (
+	StrategyTransportStream = "stream"
+	StrategyTransportHTTP   = "http"
+)
+
 type TradeConfig struct {
 	// Enabled 控制是否启用实盘交易子系统。
 	Enabled *bool `json:"enabled"`
@@ -281,6 +302,33 @@ type TradeConfig struct {
 	QueryTimeoutMS int `json:"query_timeout_ms"`
 	// RateProbeSymbol 是费率补齐优先探测合约。
 	RateProbeSymbol string `json:"rate_probe_symbol"`
+	// ReconcileIntervalMS 是持仓三方对账的定时间隔。
+	ReconcileIntervalMS int `json:"reconcile_interval_ms"`
+	// LotMatching 是平仓匹配开仓批次的顺序：fifo（先开先平）或 lifo（后开先平）。
+	LotMatching string `json:"lot_matching"`
+	// Accounts 是同一进程内额外托管的子账户；AccountID 对应的主账户不需要重复配置。
+	Accounts []TradeAccountConfig `json:"accounts"`
+}
+
+type TradeAccountConfig struct {
+	// AccountID 是子账户在系统内的标识，REST/WebSocket 与策略实例都按它路由。
+	AccountID string `json:"account_id"`
+	// Enabled 控制是否启用该子账户，未配置时默认启用。
+	Enabled *bool `json:"enabled"`
+	// BrokerID 覆盖 ctp.broker_id，留空沿用主账户配置。
+	BrokerID string `json:"broker_id"`
+	// UserID 是该子账户的 CTP 投资者代码。
+	UserID string `json:"user_id"`
+	// Password 是该子账户的 CTP 登录密码。
+	Password string `json:"password"`
+	// AppID 覆盖 ctp.app_id，留空沿用主账户配置。
+	AppID string `json:"app_id"`
+	// AuthCode 覆盖 ctp.auth_code，留空沿用主账户配置。
+	AuthCode string `json:"auth_code"`
+	// MaxOrderVolume 是该子账户单笔下单上限，0 表示沿用 trade.max_order_volume。
+	MaxOrderVolume int `json:"max_order_volume"`
+	// AllowedSymbols 是该子账户的品种白名单，未配置时沿用 trade.allowed_symbols。
+	AllowedSymbols []string `json:"allowed_symbols"`
 }
 
 func Load(path string) (AppConfig, error) {
@@ -725,6 +773,16 @@ func (c *AppConfig) Validate() error {
 	if c.Strategy.BacktestOutputDir == "" {
 		c.Strategy.BacktestOutputDir = "flow/strategy_backtests"
 	}
+	if c.Strategy.CheckpointIntervalBars == 0 {
+		c.Strategy.CheckpointIntervalBars = 10
+	}
+	c.Strategy.Transport = strings.ToLower(stringsTrim(c.Strategy.Transport))
+	if c.Strategy.Transport == "" {
+		c.Strategy.Transport = StrategyTransportStream
+	}
+	if c.Strategy.Transport != StrategyTransportStream && c.Strategy.Transport != StrategyTransportHTTP {
+		return errors.New("strategy.transport must be stream or http")
+	}
 	if c.Strategy.HealthcheckIntervalMS <= 0 {
 		return errors.New("strategy.healthcheck_interval_ms must be > 0")
 	}
@@ -777,6 +835,40 @@ func (c *AppConfig) Validate() error {
 		c.Trade.QueryTimeoutMS = 5000
 	}
 	c.Trade.RateProbeSymbol = stringsTrim(c.Trade.RateProbeSymbol)
+	if c.Trade.ReconcileIntervalMS <= 0 {
+		c.Trade.ReconcileIntervalMS = 60000
+	}
+	c.Trade.LotMatching = strings.ToLower(stringsTrim(c.Trade.LotMatching))
+	switch c.Trade.LotMatching {
+	case "":
+		c.Trade.LotMatching = "fifo"
+	case "fifo", "lifo":
+	default:
+		return fmt.Errorf("trade.lot_matching must be fifo or lifo")
+	}
+	seenTradeAccounts := map[string]struct{}{strings.ToLower(c.Trade.AccountID): {}}
+	for i := range c.Trade.Accounts {
+		item := &c.Trade.Accounts[i]
+		item.AccountID = stringsTrim(item.AccountID)
+		item.UserID = stringsTrim(item.UserID)
+		if item.AccountID == "" {
+			return fmt.Errorf("trade.accounts[%d].account_id is required", i)
+		}
+		if strings.HasPrefix(strings.ToLower(item.AccountID), "paper") || strings.Contains(item.AccountID, ":") {
+			return fmt.Errorf("trade.accounts[%d].account_id %q is reserved", i, item.AccountID)
+		}
+		key := strings.ToLower(item.AccountID)
+		if _, ok := seenTradeAccounts[key]; ok {
+			return fmt.Errorf("trade.accounts[%d].account_id %q is duplicated", i, item.AccountID)
+		}
+		seenTradeAccounts[key] = struct{}{}
+		if item.MaxOrderVolume < 0 {
+			return fmt.Errorf("trade.accounts[%d].max_order_volume must be >= 0", i)
+		}
+		if item.IsEnabled() && (item.UserID == "" || item.Password == "") {
+			return fmt.Errorf("trade.accounts[%d] requires user_id and password", i)
+		}
+	}
 	if c.Trade.MaxOrderVolume <= 0 {
 		return errors.New("trade.max_order_volume must be > 0")
 	}
@@ -894,6 +986,71 @@ func (c TradeConfig) IsEnabled() bool {
 	return *c.Enabled
 }
 
+// AccountIDs 返回主账户和全部启用子账户的标识，主账户排在第一位。
+func (c TradeConfig) AccountIDs() []string {
+	out := []string{c.AccountID}
+	for _, item := range c.Accounts {
+		if item.IsEnabled() {
+			out = append(out, item.AccountID)
+		}
+	}
+	return out
+}
+
+// Account 按标识查找启用的子账户配置，主账户不在其中。
+func (c TradeConfig) Account(accountID string) (TradeAccountConfig, bool) {
+	accountID = strings.TrimSpace(accountID)
+	for _, item := range c.Accounts {
+		if item.IsEnabled() && strings.EqualFold(item.AccountID, accountID) {
+			return item, true
+		}
+	}
+	return TradeAccountConfig{}, false
+}
+
+// ForAccount 生成子账户使用的交易配置：沿用主配置的查询节奏等公共项，覆盖账户标识和风控限额。
+func (c TradeConfig) ForAccount(item TradeAccountConfig) TradeConfig {
+	out := c
+	out.AccountID = item.AccountID
+	out.Accounts = nil
+	if item.MaxOrderVolume > 0 {
+		out.MaxOrderVolume = item.MaxOrderVolume
+	}
+	if item.AllowedSymbols != nil {
+		out.AllowedSymbols = append([]string(nil), item.AllowedSymbols...)
+	}
+	return out
+}
+
+func (c TradeAccountConfig) IsEnabled() bool {
+	if c.Enabled == nil {
+		return true
+	}
+	return *c.Enabled
+}
+
+// ForTradeAccount 生成子账户使用的 CTP 配置；每个子账户使用独立的 flow 目录，避免会话文件互相覆盖。
+func (c CTPConfig) ForTradeAccount(item TradeAccountConfig) CTPConfig {
+	out := c
+	if v := stringsTrim(item.BrokerID); v != "" {
+		out.BrokerID = v
+	}
+	if v := stringsTrim(item.AppID); v != "" {
+		out.AppID = v
+	}
+	if v := stringsTrim(item.AuthCode); v != "" {
+		out.AuthCode = v
+	}
+	out.UserID = item.UserID
+	out.Password = item.Password
+	base := stringsTrim(c.FlowPath)
+	if base == "" {
+		base = "."
+	}
+	out.FlowPath = filepath.Join(base, "accounts", item.AccountID)
+	return out
+}
+
 func (c TradeConfig) IsAutoConfirmSettlement() bool {
 	if c.AutoConfirmSettlement == nil {
 		return true
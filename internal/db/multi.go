@@ -1,6 +1,10 @@
 package db
 
-import "ctp-future-kline/internal/config"
+import (
+	"fmt"
+
+	"ctp-future-kline/internal/config"
+)
 
 const (
 	RoleSharedMeta        = "shared_meta"
@@ -47,6 +51,41 @@ func DSNForRole(cfg config.DBConfig, role string) string {
 	return BuildDSN(ConfigForRole(cfg, role))
 }
 
+// ReplaySessionConfig 返回回放会话专用的逻辑库配置：sessionID 为空时沿用角色库，否则在角色库名后追加 _s_<sessionID>。
+func ReplaySessionConfig(cfg config.DBConfig, role string, sessionID string) config.DBConfig {
+	out := ConfigForRole(cfg, role)
+	if sessionID != "" {
+		out.Database = out.Database + "_s_" + sessionID
+	}
+	return out
+}
+
+// EnsureReplaySessionDatabase 创建回放会话逻辑库并补齐该角色的 schema，返回会话库 DSN。
+func EnsureReplaySessionDatabase(cfg config.DBConfig, role string, sessionID string) (string, error) {
+	roleCfg := ReplaySessionConfig(cfg, role, sessionID)
+	if err := EnsureDatabase(roleCfg); err != nil {
+		return "", err
+	}
+	dsn := BuildDSN(roleCfg)
+	db, err := Open(dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	if err := EnsureDatabaseAndSchemaForRole(roleCfg, role, db); err != nil {
+		return "", err
+	}
+	return dsn, nil
+}
+
+// DropReplaySessionDatabase 删除回放会话逻辑库；sessionID 为空时拒绝执行，避免误删角色主库。
+func DropReplaySessionDatabase(cfg config.DBConfig, role string, sessionID string) error {
+	if sessionID == "" {
+		return fmt.Errorf("replay session id is required to drop session database")
+	}
+	return DropDatabase(ReplaySessionConfig(cfg, role, sessionID))
+}
+
 func EnsureAllLogicalDatabases(cfg config.DBConfig) error {
 	roles := []string{
 		RoleSharedMeta,
@@ -83,3 +83,14 @@ func EnsureDatabase(cfg config.DBConfig) error {
 	_, err = admin.Exec(ddl)
 	return err
 }
+
+// DropDatabase 删除 cfg.Database 指定的逻辑库，库不存在时不报错。
+func DropDatabase(cfg config.DBConfig) error {
+	admin, err := Open(BuildAdminDSN(cfg))
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	_, err = admin.Exec(fmt.Sprintf(`DROP DATABASE IF EXISTS "%s"`, cfg.Database))
+	return err
+}
@@ -271,9 +271,20 @@ func tradeSchemaStatements() []string {
   display_name VARCHAR(191) NOT NULL,
   entry_script VARCHAR(255) NOT NULL,
   version VARCHAR(64) NOT NULL,
+  code_hash VARCHAR(64) NOT NULL DEFAULT '',
   default_params_json JSON NOT NULL,
   updated_at DATETIME NOT NULL,
   PRIMARY KEY (strategy_id)
+)`,
+		`CREATE TABLE IF NOT EXISTS strategy_definition_versions (
+  strategy_id VARCHAR(128) NOT NULL,
+  version VARCHAR(64) NOT NULL,
+  code_hash VARCHAR(64) NOT NULL,
+  display_name VARCHAR(191) NOT NULL,
+  entry_script VARCHAR(255) NOT NULL,
+  default_params_json JSON NOT NULL,
+  first_seen_at DATETIME NOT NULL,
+  PRIMARY KEY (strategy_id, version, code_hash)
 )`,
 		`CREATE TABLE IF NOT EXISTS strategy_instances (
   instance_id VARCHAR(128) NOT NULL,
@@ -289,6 +300,11 @@ func tradeSchemaStatements() []string {
   last_started_at DATETIME NULL,
   last_target_position DOUBLE NOT NULL DEFAULT 0,
   last_error TEXT NULL,
+  definition_version VARCHAR(64) NOT NULL DEFAULT '',
+  code_hash VARCHAR(64) NOT NULL DEFAULT '',
+  execution_mode VARCHAR(16) NOT NULL DEFAULT 'auto',
+  replay_session VARCHAR(32) NOT NULL DEFAULT '',
+  risk_pause_json JSON NULL,
   updated_at DATETIME NOT NULL,
   created_at DATETIME NOT NULL,
   PRIMARY KEY (instance_id)
@@ -335,6 +351,49 @@ func tradeSchemaStatements() []string {
 )`,
 		`CREATE INDEX idx_strategy_traces_instance_time ON strategy_traces(instance_id, event_time DESC)`,
 		`CREATE INDEX idx_strategy_traces_symbol_time ON strategy_traces(symbol, event_time DESC)`,
+		`CREATE TABLE IF NOT EXISTS strategy_latency_spans (
+  latency_trace_id VARCHAR(64) NOT NULL,
+  instance_id VARCHAR(128) NOT NULL,
+  strategy_id VARCHAR(128) NOT NULL,
+  symbol VARCHAR(64) NOT NULL,
+  mode VARCHAR(32) NOT NULL,
+  event_time DATETIME NOT NULL,
+  order_ref VARCHAR(64) NOT NULL DEFAULT '',
+  stages_json JSON NOT NULL,
+  segments_json JSON NOT NULL,
+  total_ms DOUBLE NOT NULL,
+  complete TINYINT(1) NOT NULL DEFAULT 0,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (latency_trace_id, instance_id)
+)`,
+		`CREATE INDEX idx_strategy_latency_spans_instance_time ON strategy_latency_spans(instance_id, event_time DESC)`,
+		`CREATE TABLE IF NOT EXISTS strategy_signal_approvals (
+  approval_id VARCHAR(64) NOT NULL,
+  instance_id VARCHAR(128) NOT NULL,
+  strategy_id VARCHAR(128) NOT NULL,
+  symbol VARCHAR(64) NOT NULL,
+  timeframe VARCHAR(32) NOT NULL,
+  mode VARCHAR(32) NOT NULL,
+  signal_id BIGINT NOT NULL,
+  event_time DATETIME NOT NULL,
+  target_position DOUBLE NOT NULL,
+  current_position DOUBLE NOT NULL,
+  planned_delta DOUBLE NOT NULL,
+  decision_json JSON NOT NULL,
+  latency_trace_id VARCHAR(64) NOT NULL DEFAULT '',
+  status VARCHAR(32) NOT NULL,
+  expires_at DATETIME NOT NULL,
+  decided_at DATETIME NULL,
+  decided_by VARCHAR(128) NOT NULL DEFAULT '',
+  note TEXT NULL,
+  approved_volume DOUBLE NOT NULL DEFAULT 0,
+  approved_price DOUBLE NOT NULL DEFAULT 0,
+  result_json JSON NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (approval_id)
+)`,
+		`CREATE INDEX idx_strategy_signal_approvals_instance_created ON strategy_signal_approvals(instance_id, created_at DESC)`,
+		`CREATE INDEX idx_strategy_signal_approvals_status ON strategy_signal_approvals(status, expires_at)`,
 		`CREATE TABLE IF NOT EXISTS strategy_runs (
   run_id VARCHAR(128) NOT NULL,
   instance_id VARCHAR(128) NOT NULL,
@@ -348,9 +407,22 @@ func tradeSchemaStatements() []string {
   started_at DATETIME NOT NULL,
   finished_at DATETIME NULL,
   last_error TEXT NULL,
+  definition_version VARCHAR(64) NOT NULL DEFAULT '',
+  code_hash VARCHAR(64) NOT NULL DEFAULT '',
   PRIMARY KEY (run_id)
 )`,
 		`CREATE INDEX idx_strategy_runs_instance_started ON strategy_runs(instance_id, started_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS strategy_checkpoints (
+  instance_id VARCHAR(128) NOT NULL,
+  strategy_id VARCHAR(128) NOT NULL,
+  mode VARCHAR(32) NOT NULL,
+  fingerprint VARCHAR(64) NOT NULL,
+  last_bar_time DATETIME NOT NULL,
+  symbol_bar_times_json JSON NOT NULL,
+  state_blob LONGBLOB NOT NULL,
+  updated_at DATETIME NOT NULL,
+  PRIMARY KEY (instance_id)
+)`,
 		`CREATE TABLE IF NOT EXISTS order_audit_logs (
   id BIGINT NOT NULL AUTO_INCREMENT,
   instance_id VARCHAR(128) NOT NULL,
@@ -435,6 +507,7 @@ func tradeSchemaStatements() []string {
   order_status VARCHAR(32) NOT NULL,
   submit_status VARCHAR(32) NOT NULL,
   status_msg TEXT NOT NULL,
+  client_tag VARCHAR(128) NOT NULL DEFAULT '',
   inserted_at DATETIME NOT NULL,
   updated_at DATETIME NOT NULL,
   PRIMARY KEY (command_id)
@@ -499,6 +572,40 @@ func tradeSchemaStatements() []string {
   updated_at DATETIME NOT NULL,
   PRIMARY KEY (account_id)
 )`,
+		`CREATE TABLE IF NOT EXISTS trade_reconcile_reports (
+  report_id VARCHAR(64) NOT NULL,
+  account_id VARCHAR(128) NOT NULL,
+  trading_day VARCHAR(16) NOT NULL,
+  trigger_source VARCHAR(32) NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  severity VARCHAR(16) NOT NULL,
+  report_json JSON NOT NULL,
+  resolution VARCHAR(32) NOT NULL,
+  resolved_at DATETIME NULL,
+  created_at DATETIME NOT NULL,
+  PRIMARY KEY (report_id)
+)`,
+		`CREATE INDEX idx_trade_reconcile_reports_account_time ON trade_reconcile_reports(account_id, created_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS trade_settlement_statements (
+  account_id VARCHAR(128) NOT NULL,
+  trading_day VARCHAR(16) NOT NULL,
+  content MEDIUMTEXT NOT NULL,
+  statement_json JSON NOT NULL,
+  reconcile_status VARCHAR(16) NOT NULL,
+  fetched_at DATETIME NOT NULL,
+  PRIMARY KEY (account_id, trading_day)
+)`,
+		`CREATE TABLE IF NOT EXISTS trade_parent_orders (
+  parent_id VARCHAR(64) PRIMARY KEY,
+  account_id VARCHAR(128) NOT NULL,
+  symbol VARCHAR(64) NOT NULL,
+  style VARCHAR(16) NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  parent_json JSON NOT NULL,
+  created_at DATETIME NOT NULL,
+  updated_at DATETIME NOT NULL
+)`,
+		`CREATE INDEX idx_trade_parent_orders_account_time ON trade_parent_orders(account_id, created_at DESC)`,
 	}
 }
 
@@ -0,0 +1,287 @@
+// Package indicators 提供 K 线技术指标的统一实现：MA、EMA、MACD、ATR、BOLL、RSI、KDJ、ZigZag 和 Donchian。
+// 每个指标都是流式实现，逐根提交已收盘 K 线；批量计算复用同一套流式逻辑，
+// 保证 /api/kline/indicators、图表实时推送和策略回测看到的数值完全一致。
+// 包内只依赖标准库，klinequery、quotes 和 strategy 都可以直接引用。
+package indicators
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Bar 是指标计算所需的 K 线字段。
+type Bar struct {
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Value 是某根 K 线上的指标输出，键为指标线名（如 dif、dea、hist）；nil 表示样本不足尚未就绪。
+type Value map[string]float64
+
+// Indicator 是流式指标。
+type Indicator interface {
+	// Update 提交一根已收盘 K 线并返回最新值；样本不足时返回 nil。
+	Update(bar Bar) Value
+	// Clone 深拷贝当前状态，用于在未收盘 K 线上预览而不污染状态。
+	Clone() Indicator
+}
+
+// Preview 计算把 bar 作为下一根 K 线时的指标值，不改变 ind 的状态。
+func Preview(ind Indicator, bar Bar) Value {
+	return ind.Clone().Update(bar)
+}
+
+// Batch 用流式实现批量计算整段 K 线，返回与 bars 一一对应的结果。
+func Batch(ind Indicator, bars []Bar) []Value {
+	out := make([]Value, len(bars))
+	for i, bar := range bars {
+		out[i] = ind.Update(bar)
+	}
+	return out
+}
+
+// Spec 描述一个带参数的指标，例如 macd(12,26,9)。
+type Spec struct {
+	Name   string
+	Params []float64
+}
+
+type definition struct {
+	defaults []float64
+	lines    []string
+	build    func(p []float64) Indicator
+	warmup   func(p []float64) int
+}
+
+var registry = map[string]definition{
+	"ma": {
+		defaults: []float64{20},
+		lines:    []string{"ma"},
+		build:    func(p []float64) Indicator { return NewMA(int(p[0])) },
+		warmup:   func(p []float64) int { return int(p[0]) },
+	},
+	"ema": {
+		defaults: []float64{20},
+		lines:    []string{"ema"},
+		build:    func(p []float64) Indicator { return NewEMA(int(p[0])) },
+		warmup:   func(p []float64) int { return int(p[0]) * 4 },
+	},
+	"macd": {
+		defaults: []float64{12, 26, 9},
+		lines:    []string{"dif", "dea", "hist"},
+		build:    func(p []float64) Indicator { return NewMACD(int(p[0]), int(p[1]), int(p[2])) },
+		warmup:   func(p []float64) int { return (int(p[1]) + int(p[2])) * 4 },
+	},
+	"atr": {
+		defaults: []float64{14},
+		lines:    []string{"atr"},
+		build:    func(p []float64) Indicator { return NewATR(int(p[0])) },
+		warmup:   func(p []float64) int { return int(p[0]) },
+	},
+	"boll": {
+		defaults: []float64{20, 2},
+		lines:    []string{"mid", "upper", "lower"},
+		build:    func(p []float64) Indicator { return NewBOLL(int(p[0]), p[1]) },
+		warmup:   func(p []float64) int { return int(p[0]) },
+	},
+	"rsi": {
+		defaults: []float64{14},
+		lines:    []string{"rsi"},
+		build:    func(p []float64) Indicator { return NewRSI(int(p[0])) },
+		warmup:   func(p []float64) int { return int(p[0]) * 5 },
+	},
+	"kdj": {
+		defaults: []float64{9, 3, 3},
+		lines:    []string{"k", "d", "j"},
+		build:    func(p []float64) Indicator { return NewKDJ(int(p[0]), int(p[1]), int(p[2])) },
+		warmup:   func(p []float64) int { return int(p[0]) + (int(p[1])+int(p[2]))*4 },
+	},
+	"zigzag": {
+		defaults: []float64{26, 2, 5},
+		lines:    []string{"atr", "peak_confirmed", "trough_confirmed", "pivot_price", "pivot_bars_ago"},
+		build:    func(p []float64) Indicator { return NewZigZag(int(p[0]), p[1], int(p[2])) },
+		warmup:   func(p []float64) int { return int(p[0]) * 10 },
+	},
+	"donchian": {
+		defaults: []float64{20},
+		lines:    []string{"upper", "lower", "mid"},
+		build:    func(p []float64) Indicator { return NewDonchian(int(p[0])) },
+		warmup:   func(p []float64) int { return int(p[0]) },
+	},
+}
+
+// Names 返回已注册的指标名，按字母序排列。
+func Names() []string {
+	out := make([]string, 0, len(registry))
+	for name := range registry {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseSpec 解析 "macd(12,26,9)" 或 "boll" 这样的指标描述，缺省参数用默认值补齐。
+func ParseSpec(raw string) (Spec, error) {
+	text := strings.ToLower(strings.TrimSpace(raw))
+	name, args := text, ""
+	if open := strings.Index(text, "("); open >= 0 {
+		if !strings.HasSuffix(text, ")") {
+			return Spec{}, fmt.Errorf("invalid indicator %q", raw)
+		}
+		name, args = strings.TrimSpace(text[:open]), text[open+1:len(text)-1]
+	}
+	def, ok := registry[name]
+	if !ok {
+		return Spec{}, fmt.Errorf("unknown indicator %q", name)
+	}
+	spec := Spec{Name: name, Params: append([]float64(nil), def.defaults...)}
+	if strings.TrimSpace(args) != "" {
+		parts := strings.Split(args, ",")
+		if len(parts) > len(def.defaults) {
+			return Spec{}, fmt.Errorf("indicator %s takes at most %d params", name, len(def.defaults))
+		}
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil || v <= 0 {
+				return Spec{}, fmt.Errorf("invalid param %q for indicator %s", strings.TrimSpace(part), name)
+			}
+			spec.Params[i] = v
+		}
+	}
+	return spec, nil
+}
+
+// ParseSpecs 解析逗号或分号分隔的指标列表，括号内的逗号属于参数。
+func ParseSpecs(raw string) ([]Spec, error) {
+	var out []Spec
+	depth, start := 0, 0
+	flush := func(end int) error {
+		if item := strings.TrimSpace(raw[start:end]); item != "" {
+			spec, err := ParseSpec(item)
+			if err != nil {
+				return err
+			}
+			out = append(out, spec)
+		}
+		start = end + 1
+		return nil
+	}
+	for i, r := range raw {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case (r == ',' || r == ';') && depth == 0:
+			if err := flush(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(len(raw)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// String 返回规范化后的指标描述，作为结果和推送中的键。
+func (s Spec) String() string {
+	parts := make([]string, len(s.Params))
+	for i, p := range s.Params {
+		parts[i] = strconv.FormatFloat(p, 'f', -1, 64)
+	}
+	return s.Name + "(" + strings.Join(parts, ",") + ")"
+}
+
+// Lines 返回指标输出的线名。
+func (s Spec) Lines() []string {
+	return append([]string(nil), registry[s.Name].lines...)
+}
+
+// Warmup 返回建议的预热 K 线数，批量计算时多取这么多历史再截掉。
+func (s Spec) Warmup() int {
+	def, ok := registry[s.Name]
+	if !ok {
+		return 0
+	}
+	return def.warmup(s.Params)
+}
+
+// New 按 Spec 创建流式指标。
+func New(spec Spec) (Indicator, error) {
+	def, ok := registry[spec.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown indicator %q", spec.Name)
+	}
+	if len(spec.Params) != len(def.defaults) {
+		return nil, fmt.Errorf("indicator %s expects %d params", spec.Name, len(def.defaults))
+	}
+	return def.build(spec.Params), nil
+}
+
+// window 是按时间顺序保存最近 size 个值的滑动窗口。
+// 求和按从旧到新的顺序逐项累加，与逐根重算的结果逐位一致。
+type window struct {
+	size   int
+	values []float64
+}
+
+func newWindow(size int) window {
+	if size < 1 {
+		size = 1
+	}
+	return window{size: size, values: make([]float64, 0, size)}
+}
+
+func (w *window) push(v float64) {
+	w.values = append(w.values, v)
+	if len(w.values) > w.size {
+		w.values = append(w.values[:0], w.values[1:]...)
+	}
+}
+
+func (w *window) full() bool {
+	return len(w.values) >= w.size
+}
+
+func (w *window) mean() float64 {
+	if len(w.values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range w.values {
+		sum += v
+	}
+	return sum / float64(len(w.values))
+}
+
+func (w *window) max() float64 {
+	out := w.values[0]
+	for _, v := range w.values[1:] {
+		if v > out {
+			out = v
+		}
+	}
+	return out
+}
+
+func (w *window) min() float64 {
+	out := w.values[0]
+	for _, v := range w.values[1:] {
+		if v < out {
+			out = v
+		}
+	}
+	return out
+}
+
+func (w window) clone() window {
+	values := make([]float64, len(w.values), w.size)
+	copy(values, w.values)
+	return window{size: w.size, values: values}
+}
@@ -0,0 +1,150 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func closes(values ...float64) []Bar {
+	out := make([]Bar, len(values))
+	for i, v := range values {
+		out[i] = Bar{Open: v, High: v, Low: v, Close: v}
+	}
+	return out
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseSpecsFillsDefaultsAndSplitsTopLevel(t *testing.T) {
+	specs, err := ParseSpecs(" MA(5), macd(12, 26 ,9);boll,kdj(9) ")
+	if err != nil {
+		t.Fatalf("ParseSpecs() error = %v", err)
+	}
+	want := []string{"ma(5)", "macd(12,26,9)", "boll(20,2)", "kdj(9,3,3)"}
+	if len(specs) != len(want) {
+		t.Fatalf("specs = %v", specs)
+	}
+	for i, spec := range specs {
+		if spec.String() != want[i] {
+			t.Fatalf("spec[%d] = %s, want %s", i, spec, want[i])
+		}
+	}
+	for _, bad := range []string{"foo", "ma(0)", "ma(1,2)", "ma(20"} {
+		if _, err := ParseSpec(bad); err == nil {
+			t.Fatalf("ParseSpec(%q) should fail", bad)
+		}
+	}
+	if len(Names()) != 9 {
+		t.Fatalf("Names() = %v", Names())
+	}
+}
+
+func TestMovingAveragesAndPreviewDoesNotMutate(t *testing.T) {
+	ma := NewMA(3)
+	out := Batch(ma, closes(1, 2, 3, 4))
+	if out[1] != nil || out[2]["ma"] != 2 || out[3]["ma"] != 3 {
+		t.Fatalf("ma = %v", out)
+	}
+	if got := Preview(ma, Bar{Close: 10})["ma"]; !near(got, 17.0/3) {
+		t.Fatalf("preview ma = %v", got)
+	}
+	if got := ma.Update(Bar{Close: 5})["ma"]; got != 4 {
+		t.Fatalf("ma after preview = %v, want 4", got)
+	}
+
+	ema := NewEMA(3)
+	vals := Batch(ema, closes(10, 20))
+	if vals[0]["ema"] != 10 || vals[1]["ema"] != 15 {
+		t.Fatalf("ema = %v", vals)
+	}
+	macd := NewMACD(12, 26, 9)
+	first := macd.Update(Bar{Close: 100})
+	if first["dif"] != 0 || first["dea"] != 0 || first["hist"] != 0 {
+		t.Fatalf("macd first = %v", first)
+	}
+	if next := macd.Update(Bar{Close: 110}); next["dif"] <= 0 || !near(next["hist"], (next["dif"]-next["dea"])*2) {
+		t.Fatalf("macd next = %v", next)
+	}
+}
+
+func TestBandsAndChannels(t *testing.T) {
+	boll := Batch(NewBOLL(4, 2), closes(2, 4, 4, 6))
+	if v := boll[3]; v["mid"] != 4 || !near(v["upper"], 4+2*math.Sqrt(2)) || !near(v["lower"], 4-2*math.Sqrt(2)) {
+		t.Fatalf("boll = %v", v)
+	}
+	bars := []Bar{{High: 5, Low: 1, Close: 3}, {High: 8, Low: 2, Close: 6}, {High: 7, Low: 4, Close: 5}}
+	don := Batch(NewDonchian(2), bars)
+	if don[0] != nil || don[2]["upper"] != 8 || don[2]["lower"] != 2 || don[2]["mid"] != 5 {
+		t.Fatalf("donchian = %v", don)
+	}
+	atr := Batch(NewATR(2), bars)
+	// TR: 4, max(6,|8-3|,|2-3|)=6, max(3,|7-6|,|4-6|)=3
+	if atr[0] != nil || atr[1]["atr"] != 5 || atr[2]["atr"] != 4.5 {
+		t.Fatalf("atr = %v", atr)
+	}
+}
+
+func TestOscillators(t *testing.T) {
+	rsi := Batch(NewRSI(2), closes(1, 2, 3, 2))
+	if rsi[1] != nil || rsi[2]["rsi"] != 100 {
+		t.Fatalf("rsi warmup = %v", rsi)
+	}
+	// avgGain=(1*1+0)/2=0.5, avgLoss=(0+1)/2=0.5
+	if !near(rsi[3]["rsi"], 50) {
+		t.Fatalf("rsi = %v", rsi[3])
+	}
+	kdj := NewKDJ(3, 3, 3)
+	v := kdj.Update(Bar{High: 10, Low: 0, Close: 10})
+	// RSV=100, K=(2*50+100)/3, D=(2*50+K)/3
+	k := 200.0 / 3
+	d := (100 + k) / 3
+	if !near(v["k"], k) || !near(v["d"], d) || !near(v["j"], 3*k-2*d) {
+		t.Fatalf("kdj = %v", v)
+	}
+}
+
+func TestZigZagConfirmsAlternatingPivots(t *testing.T) {
+	var bars []Bar
+	path := []float64{}
+	for i := 0; i < 12; i++ {
+		path = append(path, 100+float64(i)*2)
+	}
+	for i := 0; i < 12; i++ {
+		path = append(path, 122-float64(i)*2)
+	}
+	for i := 0; i < 12; i++ {
+		path = append(path, 100+float64(i)*2)
+	}
+	for i := 0; i < 12; i++ {
+		path = append(path, 122-float64(i)*2)
+	}
+	for _, p := range path {
+		bars = append(bars, Bar{Open: p, High: p + 0.5, Low: p - 0.5, Close: p})
+	}
+	zz := NewZigZag(3, 2, 3)
+	var peaks, troughs int
+	for i, v := range Batch(zz, bars) {
+		if v["peak_confirmed"] > 0 {
+			peaks++
+			if v["pivot_price"] != 122.5 || v["pivot_bars_ago"] <= 0 {
+				t.Fatalf("bar %d peak = %v", i, v)
+			}
+		}
+		if v["trough_confirmed"] > 0 {
+			troughs++
+			if v["pivot_price"] != 99.5 {
+				t.Fatalf("bar %d trough = %v", i, v)
+			}
+		}
+	}
+	if peaks == 0 || troughs == 0 {
+		t.Fatalf("peaks=%d troughs=%d, want both confirmed", peaks, troughs)
+	}
+	clone := zz.Clone().(*ZigZag)
+	clone.Update(Bar{High: 200, Low: 199, Close: 200})
+	if zz.index == clone.index {
+		t.Fatal("clone should not share state with the original")
+	}
+}
@@ -0,0 +1,133 @@
+package indicators
+
+import "math"
+
+// trueRange 计算真实波幅；首根 K 线没有前收盘价时以自身收盘价代替。
+type trueRange struct {
+	prevClose float64
+	started   bool
+}
+
+func (t *trueRange) push(bar Bar) float64 {
+	prevClose := bar.Close
+	if t.started {
+		prevClose = t.prevClose
+	}
+	t.prevClose = bar.Close
+	t.started = true
+	return math.Max(bar.High-bar.Low, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
+}
+
+// ATR 是真实波幅的简单移动平均，与 MA20 回测和 ZigZag 的口径一致。
+type ATR struct {
+	tr  trueRange
+	win window
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{win: newWindow(period)}
+}
+
+func (a *ATR) Update(bar Bar) Value {
+	a.win.push(a.tr.push(bar))
+	if !a.win.full() {
+		return nil
+	}
+	return Value{"atr": a.win.mean()}
+}
+
+func (a *ATR) Clone() Indicator {
+	return &ATR{tr: a.tr, win: a.win.clone()}
+}
+
+// RSI 使用 Wilder 平滑：前 period 个涨跌幅取简单平均作为初值，之后按 1/period 递推。
+type RSI struct {
+	period    int
+	prevClose float64
+	count     int
+	avgGain   float64
+	avgLoss   float64
+}
+
+func NewRSI(period int) *RSI {
+	if period < 1 {
+		period = 1
+	}
+	return &RSI{period: period}
+}
+
+func (r *RSI) Update(bar Bar) Value {
+	if r.count == 0 {
+		r.prevClose = bar.Close
+		r.count = 1
+		return nil
+	}
+	change := bar.Close - r.prevClose
+	r.prevClose = bar.Close
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+	n := float64(r.period)
+	if r.count <= r.period {
+		r.avgGain += gain / n
+		r.avgLoss += loss / n
+		r.count++
+		if r.count <= r.period {
+			return nil
+		}
+	} else {
+		r.avgGain = (r.avgGain*(n-1) + gain) / n
+		r.avgLoss = (r.avgLoss*(n-1) + loss) / n
+	}
+	if r.avgLoss == 0 {
+		if r.avgGain == 0 {
+			return Value{"rsi": 50}
+		}
+		return Value{"rsi": 100}
+	}
+	return Value{"rsi": 100 - 100/(1+r.avgGain/r.avgLoss)}
+}
+
+func (r *RSI) Clone() Indicator {
+	copied := *r
+	return &copied
+}
+
+// KDJ 是随机指标：RSV 取 n 根内的高低区间，K、D 分别按 m1、m2 平滑，初值 50，J=3K-2D。
+// 样本不足 n 根时用已有 K 线计算，与常见行情软件一致。
+type KDJ struct {
+	highs window
+	lows  window
+	m1    float64
+	m2    float64
+	k     float64
+	d     float64
+}
+
+func NewKDJ(n, m1, m2 int) *KDJ {
+	if m1 < 1 {
+		m1 = 1
+	}
+	if m2 < 1 {
+		m2 = 1
+	}
+	return &KDJ{highs: newWindow(n), lows: newWindow(n), m1: float64(m1), m2: float64(m2), k: 50, d: 50}
+}
+
+func (s *KDJ) Update(bar Bar) Value {
+	s.highs.push(bar.High)
+	s.lows.push(bar.Low)
+	high, low := s.highs.max(), s.lows.min()
+	rsv := 50.0
+	if high > low {
+		rsv = (bar.Close - low) / (high - low) * 100
+	}
+	s.k = ((s.m1-1)*s.k + rsv) / s.m1
+	s.d = ((s.m2-1)*s.d + s.k) / s.m2
+	return Value{"k": s.k, "d": s.d, "j": 3*s.k - 2*s.d}
+}
+
+func (s *KDJ) Clone() Indicator {
+	copied := *s
+	copied.highs = s.highs.clone()
+	copied.lows = s.lows.clone()
+	return &copied
+}
@@ -0,0 +1,140 @@
+package indicators
+
+import "math"
+
+// MA 是收盘价简单移动平均，窗口未满时不输出。
+type MA struct {
+	win window
+}
+
+func NewMA(period int) *MA {
+	return &MA{win: newWindow(period)}
+}
+
+func (m *MA) Update(bar Bar) Value {
+	m.win.push(bar.Close)
+	if !m.win.full() {
+		return nil
+	}
+	return Value{"ma": m.win.mean()}
+}
+
+func (m *MA) Clone() Indicator {
+	return &MA{win: m.win.clone()}
+}
+
+// ema 是以首个样本为初值的指数移动平均，MACD 与 EMA 共用。
+type ema struct {
+	k     float64
+	value float64
+	ready bool
+}
+
+func newEMA(period int) ema {
+	if period < 1 {
+		period = 1
+	}
+	return ema{k: 2.0 / float64(period+1)}
+}
+
+func (e *ema) push(v float64) float64 {
+	if !e.ready {
+		e.value = v
+		e.ready = true
+	} else {
+		e.value = v*e.k + e.value*(1-e.k)
+	}
+	return e.value
+}
+
+// EMA 是收盘价指数移动平均，首根 K 线即输出。
+type EMA struct {
+	ema ema
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{ema: newEMA(period)}
+}
+
+func (e *EMA) Update(bar Bar) Value {
+	return Value{"ema": e.ema.push(bar.Close)}
+}
+
+func (e *EMA) Clone() Indicator {
+	copied := *e
+	return &copied
+}
+
+// MACD 输出 dif、dea 和 hist，hist 按国内行情软件习惯取 (dif-dea)*2。
+type MACD struct {
+	short  ema
+	long   ema
+	signal ema
+}
+
+func NewMACD(shortPeriod, longPeriod, signalPeriod int) *MACD {
+	return &MACD{short: newEMA(shortPeriod), long: newEMA(longPeriod), signal: newEMA(signalPeriod)}
+}
+
+func (m *MACD) Update(bar Bar) Value {
+	dif := m.short.push(bar.Close) - m.long.push(bar.Close)
+	dea := m.signal.push(dif)
+	return Value{"dif": dif, "dea": dea, "hist": (dif - dea) * 2}
+}
+
+func (m *MACD) Clone() Indicator {
+	copied := *m
+	return &copied
+}
+
+// BOLL 是布林带：中轨为简单移动平均，上下轨为中轨加减 multiple 倍总体标准差。
+type BOLL struct {
+	win      window
+	multiple float64
+}
+
+func NewBOLL(period int, multiple float64) *BOLL {
+	return &BOLL{win: newWindow(period), multiple: multiple}
+}
+
+func (b *BOLL) Update(bar Bar) Value {
+	b.win.push(bar.Close)
+	if !b.win.full() {
+		return nil
+	}
+	mid := b.win.mean()
+	variance := 0.0
+	for _, v := range b.win.values {
+		variance += (v - mid) * (v - mid)
+	}
+	std := math.Sqrt(variance / float64(len(b.win.values)))
+	return Value{"mid": mid, "upper": mid + b.multiple*std, "lower": mid - b.multiple*std}
+}
+
+func (b *BOLL) Clone() Indicator {
+	return &BOLL{win: b.win.clone(), multiple: b.multiple}
+}
+
+// Donchian 是唐奇安通道，上下轨为含当前 K 线在内 period 根的最高价与最低价。
+type Donchian struct {
+	highs window
+	lows  window
+}
+
+func NewDonchian(period int) *Donchian {
+	return &Donchian{highs: newWindow(period), lows: newWindow(period)}
+}
+
+func (d *Donchian) Update(bar Bar) Value {
+	d.highs.push(bar.High)
+	d.lows.push(bar.Low)
+	if !d.highs.full() {
+		return nil
+	}
+	upper, lower := d.highs.max(), d.lows.min()
+	return Value{"upper": upper, "lower": lower, "mid": (upper + lower) / 2}
+}
+
+func (d *Donchian) Clone() Indicator {
+	return &Donchian{highs: d.highs.clone(), lows: d.lows.clone()}
+}
@@ -0,0 +1,169 @@
+package indicators
+
+// zigZagPivot 是 ZigZag 的候选或已确认拐点。
+type zigZagPivot struct {
+	peak  bool
+	index int
+	high  float64
+	low   float64
+}
+
+// ZigZag 是按 ATR 倍数判定反转的拐点指标：ATR 取真实波幅的 atrPeriod 根简单平均，
+// 价格从当前极值反向走出 atrMultiple 倍 ATR、且相邻拐点间隔不少于 minBars 根时确认上一个拐点。
+// 每根 K 线输出 atr；确认拐点的那根 K 线额外输出 peak_confirmed/trough_confirmed、
+// pivot_price（波峰取最高价、波谷取最低价）和 pivot_bars_ago（拐点距当前 K 线的根数）。
+type ZigZag struct {
+	tr        trueRange
+	trs       window
+	multiple  float64
+	minBars   int
+	index     int
+	running   bool
+	initCache []zigZagPivot
+	lastFixed *zigZagPivot
+	curr      *zigZagPivot
+	next      *zigZagPivot
+}
+
+func NewZigZag(atrPeriod int, atrMultiple float64, minBars int) *ZigZag {
+	if atrPeriod <= 0 {
+		atrPeriod = 26
+	}
+	if atrMultiple <= 0 {
+		atrMultiple = 2
+	}
+	if minBars <= 0 {
+		minBars = 5
+	}
+	return &ZigZag{trs: newWindow(atrPeriod), multiple: atrMultiple, minBars: minBars, index: -1}
+}
+
+func (z *ZigZag) Update(bar Bar) Value {
+	z.index++
+	i := z.index
+	z.trs.push(z.tr.push(bar))
+	if !z.trs.full() {
+		return nil
+	}
+	atr := z.trs.mean()
+	if atr <= 0 {
+		return nil
+	}
+	out := Value{"atr": atr}
+	reversal := atr * z.multiple
+	point := func(peak bool) *zigZagPivot {
+		return &zigZagPivot{peak: peak, index: i, high: bar.High, low: bar.Low}
+	}
+	if !z.running {
+		z.initCache = append(z.initCache, zigZagPivot{index: i, high: bar.High, low: bar.Low})
+		hi, lo := z.initExtremes()
+		if hi.high-lo.low < reversal {
+			return out
+		}
+		hiPoint, loPoint := hi, lo
+		hiPoint.peak = true
+		loPoint.peak = false
+		if lo.index < hi.index {
+			z.lastFixed, z.curr = &loPoint, &hiPoint
+		} else {
+			z.lastFixed, z.curr = &hiPoint, &loPoint
+		}
+		z.running = true
+		z.initCache = nil
+		return out
+	}
+	if z.lastFixed.peak {
+		if z.next == nil {
+			if bar.Low < z.curr.low {
+				z.curr = point(false)
+			} else if bar.High-z.curr.low >= reversal {
+				z.next = point(true)
+			}
+			return out
+		}
+		if bar.Low < z.curr.low {
+			z.curr = point(false)
+			z.next = nil
+			return out
+		}
+		if bar.High > z.next.high {
+			z.next = point(true)
+		}
+		if z.next.high-bar.Low >= reversal && z.spacingOK() {
+			z.confirm(out, i)
+			z.next = point(false)
+		}
+		return out
+	}
+	if z.next == nil {
+		if bar.High > z.curr.high {
+			z.curr = point(true)
+		} else if z.curr.high-bar.Low >= reversal {
+			z.next = point(false)
+		}
+		return out
+	}
+	if bar.High > z.curr.high {
+		z.curr = point(true)
+		z.next = nil
+		return out
+	}
+	if bar.Low < z.next.low {
+		z.next = point(false)
+	}
+	if bar.High-z.next.low >= reversal && z.spacingOK() {
+		z.confirm(out, i)
+		z.next = point(true)
+	}
+	return out
+}
+
+// confirm 把当前拐点固定下来，候选拐点成为新的当前拐点。
+func (z *ZigZag) confirm(out Value, i int) {
+	fixed := z.curr
+	if fixed.peak {
+		out["peak_confirmed"] = 1
+		out["pivot_price"] = fixed.high
+	} else {
+		out["trough_confirmed"] = 1
+		out["pivot_price"] = fixed.low
+	}
+	out["pivot_bars_ago"] = float64(i - fixed.index)
+	z.lastFixed = fixed
+	z.curr = z.next
+}
+
+func (z *ZigZag) initExtremes() (zigZagPivot, zigZagPivot) {
+	hi, lo := z.initCache[0], z.initCache[0]
+	for _, item := range z.initCache[1:] {
+		if item.high > hi.high {
+			hi = item
+		}
+		if item.low < lo.low {
+			lo = item
+		}
+	}
+	return hi, lo
+}
+
+func (z *ZigZag) spacingOK() bool {
+	return z.curr.index-z.lastFixed.index >= z.minBars && z.next.index-z.curr.index >= z.minBars
+}
+
+func (z *ZigZag) Clone() Indicator {
+	copied := *z
+	copied.trs = z.trs.clone()
+	copied.initCache = append([]zigZagPivot(nil), z.initCache...)
+	copied.lastFixed = clonePivot(z.lastFixed)
+	copied.curr = clonePivot(z.curr)
+	copied.next = clonePivot(z.next)
+	return &copied
+}
+
+func clonePivot(p *zigZagPivot) *zigZagPivot {
+	if p == nil {
+		return nil
+	}
+	copied := *p
+	return &copied
+}
@@ -167,6 +167,21 @@ func BuildBarTimes(tradingDay time.Time, hhmm int, provider PrevTradingDayProvid
 	return base, adjusted, nil
 }
 
+// TradingDayOf 返回连续时间轴（AdjustedTime）上某一时刻所属的交易日，是 BuildBarTimes 的逆运算：
+// 日盘落在当天，夜盘和凌晨时段归到下一个工作日。不查交易日历，与 prevWorkingDay 一样只跳过周末。
+func TradingDayOf(ts time.Time) time.Time {
+	ts = ts.In(time.Local)
+	hhmm := HHMMFromTime(ts)
+	day := normalizeDay(ts)
+	if hhmm >= 800 && hhmm <= 1600 {
+		return day
+	}
+	if hhmm < 800 {
+		day = day.AddDate(0, 0, -1)
+	}
+	return nextWorkingDay(day)
+}
+
 func HHMMFromTime(ts time.Time) int {
 	return ts.Hour()*100 + ts.Minute()
 }
@@ -187,6 +202,18 @@ func normalizeDay(day time.Time) time.Time {
 	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
 }
 
+func nextWorkingDay(day time.Time) time.Time {
+	d := normalizeDay(day).AddDate(0, 0, 1)
+	switch d.Weekday() {
+	case time.Saturday:
+		return d.AddDate(0, 0, 2)
+	case time.Sunday:
+		return d.AddDate(0, 0, 1)
+	default:
+		return d
+	}
+}
+
 func prevWorkingDay(day time.Time) time.Time {
 	d := normalizeDay(day).AddDate(0, 0, -1)
 	switch d.Weekday() {
@@ -0,0 +1,101 @@
+package klinequery
+
+import (
+	"fmt"
+	"time"
+
+	"ctp-future-kline/internal/indicators"
+)
+
+// maxIndicatorBars 是单次指标查询连同预热最多读取的 K 线数，与 BarsByEnd 的上限一致。
+const maxIndicatorBars = 5000
+
+// IndicatorSeries 是一个指标在各根 K 线上的输出，Lines 中每条线与 IndicatorsResponse.Times 等长，未就绪处为 null。
+type IndicatorSeries struct {
+	Spec  string                `json:"spec"`
+	Name  string                `json:"name"`
+	Lines map[string][]*float64 `json:"lines"`
+}
+
+// IndicatorsResponse 是 /api/kline/indicators 的返回结果。
+type IndicatorsResponse struct {
+	Meta       BarsMeta          `json:"meta"`
+	Times      []int64           `json:"times"`
+	Indicators []IndicatorSeries `json:"indicators"`
+}
+
+// IndicatorsByEnd 计算截止 end 的最近 limit 根 K 线上的指标；会额外读取预热所需的历史 K 线，返回前截掉。
+func (s *Service) IndicatorsByEnd(symbol string, kind string, variety string, timeframe string, end time.Time, limit int, specs []indicators.Spec) (IndicatorsResponse, error) {
+	if len(specs) == 0 {
+		return IndicatorsResponse{}, fmt.Errorf("indicators is required")
+	}
+	if limit <= 0 {
+		limit = 2000
+	}
+	warmup := 0
+	for _, spec := range specs {
+		if w := spec.Warmup(); w > warmup {
+			warmup = w
+		}
+	}
+	if warmup > maxIndicatorBars/2 {
+		warmup = maxIndicatorBars / 2
+	}
+	if limit+warmup > maxIndicatorBars {
+		limit = maxIndicatorBars - warmup
+	}
+	resp, err := s.BarsByEnd(symbol, kind, variety, timeframe, end, limit+warmup)
+	if err != nil {
+		return IndicatorsResponse{}, err
+	}
+	series, err := ComputeIndicators(resp.Bars, specs)
+	if err != nil {
+		return IndicatorsResponse{}, err
+	}
+	skip := len(resp.Bars) - limit
+	if skip < 0 {
+		skip = 0
+	}
+	times := make([]int64, 0, len(resp.Bars)-skip)
+	for _, bar := range resp.Bars[skip:] {
+		times = append(times, bar.AdjustedTime)
+	}
+	for i := range series {
+		for line, values := range series[i].Lines {
+			series[i].Lines[line] = values[skip:]
+		}
+	}
+	return IndicatorsResponse{Meta: resp.Meta, Times: times, Indicators: series}, nil
+}
+
+// ComputeIndicators 在整段 K 线上批量计算指标。
+func ComputeIndicators(bars []KlineBar, specs []indicators.Spec) ([]IndicatorSeries, error) {
+	out := make([]IndicatorSeries, 0, len(specs))
+	for _, spec := range specs {
+		ind, err := indicators.New(spec)
+		if err != nil {
+			return nil, err
+		}
+		lines := make(map[string][]*float64)
+		for _, line := range spec.Lines() {
+			lines[line] = make([]*float64, len(bars))
+		}
+		for i, bar := range bars {
+			for line, v := range ind.Update(klineIndicatorBar(bar)) {
+				if values, ok := lines[line]; ok {
+					values[i] = floatPtr(v)
+				}
+			}
+		}
+		out = append(out, IndicatorSeries{Spec: spec.String(), Name: spec.Name, Lines: lines})
+	}
+	return out, nil
+}
+
+func klineIndicatorBar(bar KlineBar) indicators.Bar {
+	return indicators.Bar{Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: float64(bar.Volume)}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	dbx "ctp-future-kline/internal/db"
+	"ctp-future-kline/internal/indicators"
 	"ctp-future-kline/internal/logger"
 	"ctp-future-kline/internal/mmkline"
 	"ctp-future-kline/internal/searchindex"
@@ -362,19 +363,15 @@ LIMIT ?`, adjustedExpr, timeExpr, adjustedExpr, queryTable, adjustedExpr)
 	logDuplicateAdjustedTimes(bars, symbol, kind, item.Variety, queryTable, queryPeriod)
 	logger.Info("kline pipeline", "stage", "load_done", "symbol", symbol, "kind", kind, "variety", item.Variety, "timeframe", tf, "period", queryPeriod, "table", queryTable, "rows", len(bars), "first_time", time.Unix(bars[0].AdjustedTime, 0).Format("2006-01-02 15:04:05"), "last_time", time.Unix(bars[len(bars)-1].AdjustedTime, 0).Format("2006-01-02 15:04:05"))
 
-	var closes []float64
-	for _, bar := range bars {
-		closes = append(closes, bar.Close)
-	}
-
-	dif, dea, hist := calcMACD(closes, 12, 26, 9)
+	macdIndicator := indicators.NewMACD(12, 26, 9)
 	macd := make([]MACDPoint, len(bars))
-	for i := range bars {
+	for i, bar := range bars {
+		value := macdIndicator.Update(klineIndicatorBar(bar))
 		macd[i] = MACDPoint{
-			Time: bars[i].AdjustedTime,
-			DIF:  dif[i],
-			DEA:  dea[i],
-			Hist: hist[i],
+			Time: bar.AdjustedTime,
+			DIF:  floatPtr(value["dif"]),
+			DEA:  floatPtr(value["dea"]),
+			Hist: floatPtr(value["hist"]),
 		}
 	}
 	logger.Info("kline pipeline", "stage", "bars_done", "symbol", symbol, "kind", kind, "variety", item.Variety, "timeframe", tf, "bar_count", len(bars))
@@ -1088,47 +1085,6 @@ func logDuplicateAdjustedTimes(bars []KlineBar, symbol, kind, variety, table, pe
 	)
 }
 
-func calcMACD(closes []float64, shortPeriod, longPeriod, signalPeriod int) ([]*float64, []*float64, []*float64) {
-	n := len(closes)
-	dif := make([]*float64, n)
-	dea := make([]*float64, n)
-	hist := make([]*float64, n)
-	if n == 0 {
-		return dif, dea, hist
-	}
-
-	shortK := 2.0 / float64(shortPeriod+1)
-	longK := 2.0 / float64(longPeriod+1)
-	signalK := 2.0 / float64(signalPeriod+1)
-
-	var shortEMA, longEMA, signalEMA float64
-	for i, close := range closes {
-		if i == 0 {
-			shortEMA = close
-			longEMA = close
-			signalEMA = 0
-		} else {
-			shortEMA = close*shortK + shortEMA*(1-shortK)
-			longEMA = close*longK + longEMA*(1-longK)
-		}
-		d := shortEMA - longEMA
-		if i == 0 {
-			signalEMA = d
-		} else {
-			signalEMA = d*signalK + signalEMA*(1-signalK)
-		}
-		h := (d - signalEMA) * 2
-
-		dCopy := d
-		sCopy := signalEMA
-		hCopy := h
-		dif[i] = &dCopy
-		dea[i] = &sCopy
-		hist[i] = &hCopy
-	}
-	return dif, dea, hist
-}
-
 func displaySymbol(symbol string, kind string) string {
 	_ = kind
 	return strings.ToLower(strings.TrimSpace(symbol))
@@ -107,22 +107,10 @@ func RebuildAndUpsertWithSessionDB(db *sql.DB, sessionDB *sql.DB, req RebuildReq
 		return nil, nil, err
 	}
 
-	periods := []struct {
-		Label   string
-		Minutes int
-	}{
-		{Label: "5m", Minutes: 5},
-		{Label: "15m", Minutes: 15},
-		{Label: "30m", Minutes: 30},
-		{Label: "1h", Minutes: 60},
-		{Label: "1d", Minutes: 1440},
-	}
+	periods := rebuildPeriods
 	selected := selectedPeriods(req.Periods)
 	if len(selected) > 0 {
-		filtered := make([]struct {
-			Label   string
-			Minutes int
-		}, 0, len(periods))
+		filtered := make([]rebuildPeriod, 0, len(periods))
 		for _, p := range periods {
 			if selected[p.Label] {
 				filtered = append(filtered, p)
@@ -134,19 +122,10 @@ func RebuildAndUpsertWithSessionDB(db *sql.DB, sessionDB *sql.DB, req RebuildReq
 	written := make(map[string]int, len(periods))
 	var allStats []klineagg.BucketStat
 	for _, p := range periods {
-		if p.Label == "1d" {
-			out, stats := aggregateToDaily(bars, p.Label, sessions)
-			if len(out) == 0 {
-				continue
-			}
-			if err := upsertMMBars(db, dstTable, out); err != nil {
-				return nil, nil, err
-			}
-			written[p.Label] = len(out)
-			allStats = append(allStats, stats...)
-			continue
+		out, stats, err := AggregatePeriod(bars, sessions, p.Label)
+		if err != nil {
+			return nil, nil, err
 		}
-		out, stats := klineagg.Aggregate(bars, sessions, p.Label, p.Minutes, klineagg.Options{CrossSessionFor30m1h: true, ClampToSessionEnd: true, ComputeBucketStats: true})
 		if len(out) == 0 {
 			continue
 		}
@@ -159,6 +138,37 @@ func RebuildAndUpsertWithSessionDB(db *sql.DB, sessionDB *sql.DB, req RebuildReq
 	return written, allStats, nil
 }
 
+type rebuildPeriod struct {
+	Label   string
+	Minutes int
+}
+
+// rebuildPeriods 是 mm 重建支持的周期，按从小到大排列。
+var rebuildPeriods = []rebuildPeriod{
+	{Label: "5m", Minutes: 5},
+	{Label: "15m", Minutes: 15},
+	{Label: "30m", Minutes: 30},
+	{Label: "1h", Minutes: 60},
+	{Label: "1d", Minutes: 1440},
+}
+
+// AggregatePeriod 把一段 1m bar 按交易时段聚合成指定周期（5m/15m/30m/1h/1d）。
+// 它是重建链路里不访问数据库的纯计算部分，回归测试直接用它对照 golden 输出。
+func AggregatePeriod(bars []klineagg.MinuteBar, sessions []klineagg.SessionRange, period string) ([]klineagg.AggBar, []klineagg.BucketStat, error) {
+	for _, p := range rebuildPeriods {
+		if p.Label != period {
+			continue
+		}
+		if p.Label == "1d" {
+			out, stats := aggregateToDaily(bars, p.Label, sessions)
+			return out, stats, nil
+		}
+		out, stats := klineagg.Aggregate(bars, sessions, p.Label, p.Minutes, klineagg.Options{CrossSessionFor30m1h: true, ClampToSessionEnd: true, ComputeBucketStats: true})
+		return out, stats, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported mm period: %q", period)
+}
+
 func selectedPeriods(items []string) map[string]bool {
 	if len(items) == 0 {
 		return nil
@@ -0,0 +1,16 @@
+package order
+
+// ExecutionStyle 是算法执行参数：策略信号用它描述希望的执行方式，交易侧母单按它切片下单。
+// 放在这个叶子包里，trade 和 strategy 都能引用同一个类型而不互相依赖。
+type ExecutionStyle struct {
+	// Style 是执行算法：twap、vwap 或 iceberg。
+	Style string `json:"style"`
+	// DurationSec 是 TWAP/VWAP 的执行窗口秒数，窗口结束时未成交部分不再补单。
+	DurationSec int `json:"duration_sec,omitempty"`
+	// Slices 是 TWAP 切片数，0 表示每分钟一片。
+	Slices int `json:"slices,omitempty"`
+	// DisplayVolume 是冰山单每次挂出的显示手数。
+	DisplayVolume int `json:"display_volume,omitempty"`
+	// RepriceAfterSec 是子单价格落后最优价多少秒后撤单重挂，0 使用默认值。
+	RepriceAfterSec int `json:"reprice_after_sec,omitempty"`
+}
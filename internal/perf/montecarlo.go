@@ -0,0 +1,256 @@
+package perf
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// MonteCarloBootstrap 是逐笔有放回重抽样，打乱交易之间的先后关系。
+	MonteCarloBootstrap = "bootstrap"
+	// MonteCarloBlockBootstrap 是按连续交易块循环重抽样，保留块内的连亏连赢结构。
+	MonteCarloBlockBootstrap = "block_bootstrap"
+
+	// DefaultMonteCarloIterations 是未指定路径数时的默认值。
+	DefaultMonteCarloIterations = 1000
+	// MaxMonteCarloIterations 是单个方法的路径数上限。
+	MaxMonteCarloIterations = 100_000
+	// DefaultMonteCarloConfidence 是默认置信水平。
+	DefaultMonteCarloConfidence = 0.95
+)
+
+// MonteCarloConfig 是一次蒙特卡洛稳健性分析的参数。
+type MonteCarloConfig struct {
+	// Method 是 MonteCarloBootstrap 或 MonteCarloBlockBootstrap。
+	Method string
+	// Iterations 是模拟路径数，<=0 时使用 DefaultMonteCarloIterations。
+	Iterations int
+	// BlockSize 是块重抽样的块长，<=0 时取交易数的立方根（至少 2）。
+	BlockSize int
+	// SlippagePoints 是每次成交的最大不利滑点（价格点），每笔交易开平两次成交各自在 [0, SlippagePoints] 上均匀抽取。
+	SlippagePoints float64
+	// InitialBalance 是路径起点权益，只用于计算回撤基准，<=0 时使用 DefaultInitialBalance。
+	InitialBalance float64
+	// Confidence 是置信区间水平，取值 (0,1)，越界时使用 DefaultMonteCarloConfidence。
+	Confidence float64
+	// Seed 是随机数种子，相同种子和输入得到相同结果。
+	Seed int64
+}
+
+// PathStats 是一条交易路径的终值与回撤统计。
+type PathStats struct {
+	// FinalPnL 是路径全部交易的净盈亏之和。
+	FinalPnL float64 `json:"final_pnl"`
+	// MaxDrawdown 是相对历史最高权益的最大回撤，非正数。
+	MaxDrawdown float64 `json:"max_drawdown"`
+	// TimeToRecover 是最长一段水下期跨越的交易笔数：从创出高点到重新回到该高点；到结尾仍未回到时计到最后一笔。
+	TimeToRecover int `json:"time_to_recover_trades"`
+	// Recovered 表示路径结束时权益不低于历史最高点。
+	Recovered bool `json:"recovered"`
+}
+
+// Distribution 是一个指标在全部模拟路径上的分布摘要。
+type Distribution struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+	// CILow 和 CIHigh 是按置信水平取的双侧分位数区间。
+	CILow  float64 `json:"ci_low"`
+	CIHigh float64 `json:"ci_high"`
+}
+
+// MonteCarloResult 是一种重抽样方法的模拟结果。
+type MonteCarloResult struct {
+	Method         string  `json:"method"`
+	Iterations     int     `json:"iterations"`
+	BlockSize      int     `json:"block_size,omitempty"`
+	Trades         int     `json:"trades"`
+	SlippagePoints float64 `json:"slippage_points"`
+	Confidence     float64 `json:"confidence"`
+	Seed           int64   `json:"seed"`
+	// Original 是原始交易顺序、不加滑点时的路径统计，用来和分布对照。
+	Original      PathStats    `json:"original"`
+	FinalPnL      Distribution `json:"final_pnl"`
+	MaxDrawdown   Distribution `json:"max_drawdown"`
+	TimeToRecover Distribution `json:"time_to_recover_trades"`
+	// LossProbability 是终值净盈亏为负的路径占比。
+	LossProbability float64 `json:"loss_probability"`
+	// UnrecoveredProbability 是结束时仍处于回撤中的路径占比。
+	UnrecoveredProbability float64 `json:"unrecovered_probability"`
+}
+
+// MonteCarlo 按配置重抽样交易序列并叠加随机滑点，返回终值盈亏、最大回撤和回撤恢复笔数的分布。
+// 没有交易时只返回原始路径统计。
+func MonteCarlo(trades []ClosedTrade, cfg MonteCarloConfig) MonteCarloResult {
+	cfg = normalizeMonteCarloConfig(cfg, len(trades))
+	out := MonteCarloResult{
+		Method:         cfg.Method,
+		Iterations:     cfg.Iterations,
+		Trades:         len(trades),
+		SlippagePoints: cfg.SlippagePoints,
+		Confidence:     cfg.Confidence,
+		Seed:           cfg.Seed,
+	}
+	if cfg.Method == MonteCarloBlockBootstrap {
+		out.BlockSize = cfg.BlockSize
+	}
+	pnls := make([]float64, len(trades))
+	for i, trade := range trades {
+		pnls[i] = trade.PnL
+	}
+	out.Original = PathOf(cfg.InitialBalance, pnls)
+	if len(trades) == 0 {
+		return out
+	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	finals := make([]float64, cfg.Iterations)
+	drawdowns := make([]float64, cfg.Iterations)
+	recovers := make([]float64, cfg.Iterations)
+	path := make([]float64, len(trades))
+	losses, unrecovered := 0, 0
+	for i := 0; i < cfg.Iterations; i++ {
+		resample(rng, trades, path, cfg)
+		stats := PathOf(cfg.InitialBalance, path)
+		finals[i] = stats.FinalPnL
+		drawdowns[i] = stats.MaxDrawdown
+		recovers[i] = float64(stats.TimeToRecover)
+		if stats.FinalPnL < 0 {
+			losses++
+		}
+		if !stats.Recovered {
+			unrecovered++
+		}
+	}
+	out.FinalPnL = distributionOf(finals, cfg.Confidence)
+	out.MaxDrawdown = distributionOf(drawdowns, cfg.Confidence)
+	out.TimeToRecover = distributionOf(recovers, cfg.Confidence)
+	out.LossProbability = float64(losses) / float64(cfg.Iterations)
+	out.UnrecoveredProbability = float64(unrecovered) / float64(cfg.Iterations)
+	return out
+}
+
+// PathOf 计算一条按顺序排列的逐笔净盈亏路径的统计。
+func PathOf(initialBalance float64, pnls []float64) PathStats {
+	equity := initialBalance
+	peak := equity
+	peakIndex := -1
+	stats := PathStats{Recovered: true}
+	for i, pnl := range pnls {
+		equity += pnl
+		if equity >= peak {
+			if peakIndex < i-1 {
+				stats.TimeToRecover = max(stats.TimeToRecover, i-peakIndex)
+			}
+			peak = equity
+			peakIndex = i
+			continue
+		}
+		stats.MaxDrawdown = math.Min(stats.MaxDrawdown, equity-peak)
+	}
+	stats.FinalPnL = equity - initialBalance
+	if peakIndex < len(pnls)-1 {
+		stats.Recovered = false
+		stats.TimeToRecover = max(stats.TimeToRecover, len(pnls)-1-peakIndex)
+	}
+	return stats
+}
+
+func normalizeMonteCarloConfig(cfg MonteCarloConfig, trades int) MonteCarloConfig {
+	if cfg.Method != MonteCarloBlockBootstrap {
+		cfg.Method = MonteCarloBootstrap
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = DefaultMonteCarloIterations
+	}
+	cfg.Iterations = min(cfg.Iterations, MaxMonteCarloIterations)
+	if cfg.BlockSize <= 0 {
+		cfg.BlockSize = max(2, int(math.Round(math.Cbrt(float64(trades)))))
+	}
+	if trades > 0 {
+		cfg.BlockSize = min(cfg.BlockSize, trades)
+	}
+	cfg.SlippagePoints = math.Max(cfg.SlippagePoints, 0)
+	if cfg.InitialBalance <= 0 {
+		cfg.InitialBalance = DefaultInitialBalance
+	}
+	if cfg.Confidence <= 0 || cfg.Confidence >= 1 {
+		cfg.Confidence = DefaultMonteCarloConfidence
+	}
+	return cfg
+}
+
+// resample 按方法抽出一条与原序列等长的路径写入 path，每笔交易的开平两次成交各扣一次随机滑点。
+func resample(rng *rand.Rand, trades []ClosedTrade, path []float64, cfg MonteCarloConfig) {
+	n := len(trades)
+	for i := 0; i < n; {
+		if cfg.Method != MonteCarloBlockBootstrap {
+			path[i] = slipped(rng, trades[rng.Intn(n)], cfg.SlippagePoints)
+			i++
+			continue
+		}
+		// 循环块重抽样：块从任意位置起，越过末尾时接回开头。
+		start := rng.Intn(n)
+		for j := 0; j < cfg.BlockSize && i < n; j++ {
+			path[i] = slipped(rng, trades[(start+j)%n], cfg.SlippagePoints)
+			i++
+		}
+	}
+}
+
+func slipped(rng *rand.Rand, trade ClosedTrade, points float64) float64 {
+	if points <= 0 {
+		return trade.PnL
+	}
+	multiple := trade.VolumeMultiple
+	if multiple <= 0 {
+		multiple = 1
+	}
+	volume := max(trade.Volume, 1)
+	slip := (rng.Float64() + rng.Float64()) * points
+	return trade.PnL - slip*float64(volume)*multiple
+}
+
+func distributionOf(values []float64, confidence float64) Distribution {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mean := 0.0
+	for _, v := range sorted {
+		mean += v
+	}
+	mean /= float64(len(sorted))
+	variance := 0.0
+	for _, v := range sorted {
+		variance += (v - mean) * (v - mean)
+	}
+	if len(sorted) > 1 {
+		variance /= float64(len(sorted) - 1)
+	}
+	tail := (1 - confidence) / 2
+	return Distribution{
+		Mean:   mean,
+		StdDev: math.Sqrt(variance),
+		Min:    sorted[0],
+		P5:     quantile(sorted, 0.05),
+		Median: quantile(sorted, 0.5),
+		P95:    quantile(sorted, 0.95),
+		Max:    sorted[len(sorted)-1],
+		CILow:  quantile(sorted, tail),
+		CIHigh: quantile(sorted, 1-tail),
+	}
+}
+
+// quantile 在已排序样本上按线性插值取分位数。
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := min(lo+1, len(sorted)-1)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,81 @@
+package perf
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPathOfDrawdownAndRecovery(t *testing.T) {
+	t.Parallel()
+
+	// 权益：+10, -5, -10, +20(收复), -3(未收复)。
+	stats := PathOf(1000, []float64{10, -5, -10, 20, -3})
+	if stats.FinalPnL != 12 || stats.MaxDrawdown != -15 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats.TimeToRecover != 3 || stats.Recovered {
+		t.Fatalf("recovery = %d recovered=%v, want 3 trades and still under water", stats.TimeToRecover, stats.Recovered)
+	}
+	if got := PathOf(1000, []float64{-1, -1, -1, -1}); got.TimeToRecover != 4 || got.Recovered {
+		t.Fatalf("never recovered path = %+v", got)
+	}
+	if got := PathOf(1000, nil); got.FinalPnL != 0 || !got.Recovered || got.TimeToRecover != 0 {
+		t.Fatalf("empty path = %+v", got)
+	}
+}
+
+func TestMonteCarloIsReproducibleAndSlippageHurts(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	var trades []ClosedTrade
+	for i, pnl := range []float64{120, -80, 60, -40, 200, -150, 90, -30, 50, -20, 70, -110} {
+		trades = append(trades, ClosedTrade{Symbol: "rb2405", ExitTime: base.Add(time.Duration(i) * time.Minute), Volume: 2, VolumeMultiple: 10, PnL: pnl})
+	}
+	cfg := MonteCarloConfig{Method: MonteCarloBlockBootstrap, Iterations: 500, Seed: 7}
+	first := MonteCarlo(trades, cfg)
+	if !reflect.DeepEqual(first, MonteCarlo(trades, cfg)) {
+		t.Fatal("same seed should give the same result")
+	}
+	if first.BlockSize != 2 || first.Trades != 12 || first.Iterations != 500 || first.Confidence != DefaultMonteCarloConfidence {
+		t.Fatalf("config echo = %+v", first)
+	}
+	// 块重抽样不加滑点时每条路径仍是原交易的组合，终值均值应接近原始净盈亏。
+	if math.Abs(first.FinalPnL.Mean-first.Original.FinalPnL) > 60 || first.Original.FinalPnL != 160 {
+		t.Fatalf("final pnl mean = %v original = %v", first.FinalPnL.Mean, first.Original.FinalPnL)
+	}
+	d := first.FinalPnL
+	if !(d.Min <= d.CILow && d.CILow <= d.Median && d.Median <= d.CIHigh && d.CIHigh <= d.Max) {
+		t.Fatalf("distribution not ordered: %+v", d)
+	}
+	if first.MaxDrawdown.Max > 0 || first.TimeToRecover.Min < 0 {
+		t.Fatalf("drawdown=%+v recover=%+v", first.MaxDrawdown, first.TimeToRecover)
+	}
+
+	cfg.SlippagePoints = 1
+	slipped := MonteCarlo(trades, cfg)
+	// 每笔 2 手、乘数 10，开平各至多 1 点：单笔成本在 [0, 40]，12 笔的平均成本约 240。
+	cost := first.FinalPnL.Mean - slipped.FinalPnL.Mean
+	if cost < 180 || cost > 300 {
+		t.Fatalf("slippage cost = %v", cost)
+	}
+	if slipped.LossProbability <= first.LossProbability {
+		t.Fatalf("loss probability %v should exceed %v with slippage", slipped.LossProbability, first.LossProbability)
+	}
+}
+
+func TestTrackerReportsClosedTrades(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	tr := NewTracker(10_000)
+	tr.Fill(Fill{Symbol: "RB2405", Direction: "buy", Price: 100, Volume: 2, VolumeMultiple: 10, Commission: 2, Time: base})
+	tr.Fill(Fill{Symbol: "rb2405", Direction: "sell", Price: 105, Volume: 2, VolumeMultiple: 10, Commission: 2, Time: base.Add(time.Minute)})
+	got := tr.Report().ClosedTrades
+	want := []ClosedTrade{{Symbol: "rb2405", ExitTime: base.Add(time.Minute), Volume: 2, VolumeMultiple: 10, PnL: 96}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("closed trades = %+v", got)
+	}
+}
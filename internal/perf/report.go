@@ -0,0 +1,335 @@
+// Package perf 计算策略绩效指标：权益曲线、回撤序列、夏普、索提诺、卡玛、胜率、盈亏比、
+// 期望收益、持仓暴露、换手率与逐日盈亏，以及按逐笔交易重抽样的蒙特卡洛稳健性分析。
+// 包内除交易日换算（klineclock）外只依赖标准库，回测（internal/backtest）与回放报告（internal/strategy）用同一套口径，
+// 结果可以直接对比，也可以导出为 CSV。
+package perf
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"ctp-future-kline/internal/klineclock"
+)
+
+const (
+	// DefaultInitialBalance 是未指定初始资金时的默认值，与 replay_paper 模拟账户一致。
+	DefaultInitialBalance = 100_000
+	// tradingDaysPerYear 是年化使用的年交易日数。
+	tradingDaysPerYear = 252
+	// maxRatio 是只有盈利交易时盈亏比类指标的上限，避免 +Inf 无法 JSON 编码并让排序可比。
+	maxRatio = 100
+)
+
+// Sample 是一个权益采样点。
+type Sample struct {
+	// Time 是采样时间。
+	Time time.Time
+	// Equity 是动态权益。
+	Equity float64
+	// Exposure 是持仓名义价值绝对值之和。
+	Exposure float64
+}
+
+// Input 是 Analyze 的输入。
+type Input struct {
+	// InitialBalance 是初始权益，<=0 时使用 DefaultInitialBalance。
+	InitialBalance float64
+	// Samples 是按时间升序的权益采样。
+	Samples []Sample
+	// TradePnLs 是每笔平仓交易扣除手续费后的净盈亏。
+	TradePnLs []float64
+	// ClosedTrades 是逐笔平仓交易明细，原样写入报告，供蒙特卡洛分析重抽样。
+	ClosedTrades []ClosedTrade
+	// TradedNotional 是全部成交的名义金额之和。
+	TradedNotional float64
+}
+
+// EquityPoint 是权益曲线与回撤序列上的一个点。
+type EquityPoint struct {
+	// Time 是采样时间。
+	Time time.Time `json:"time"`
+	// Equity 是动态权益。
+	Equity float64 `json:"equity"`
+	// Drawdown 是相对历史最高权益的回撤金额，非正数。
+	Drawdown float64 `json:"drawdown"`
+	// DrawdownPct 是回撤占历史最高权益的比例，非正数。
+	DrawdownPct float64 `json:"drawdown_pct"`
+	// Exposure 是持仓名义价值。
+	Exposure float64 `json:"exposure"`
+}
+
+// ClosedTrade 是一笔按 FIFO 配对出的平仓交易。
+type ClosedTrade struct {
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// ExitTime 是平仓成交时间。
+	ExitTime time.Time `json:"exit_time"`
+	// Volume 是平仓手数。
+	Volume int `json:"volume"`
+	// VolumeMultiple 是合约乘数。
+	VolumeMultiple float64 `json:"volume_multiple"`
+	// PnL 是扣除开平仓手续费后的净盈亏。
+	PnL float64 `json:"pnl"`
+}
+
+// DailyPnL 是一个交易日的盈亏，夜盘计入下一交易日。
+type DailyPnL struct {
+	// Date 是交易日，格式 2006-01-02。
+	Date string `json:"date"`
+	// PnL 是当日收盘权益减前一日收盘权益（首日相对初始权益）。
+	PnL float64 `json:"pnl"`
+	// Return 是当日收益率。
+	Return float64 `json:"return"`
+	// Equity 是当日最后一个采样点的权益。
+	Equity float64 `json:"equity"`
+}
+
+// Report 是完整的绩效报告。
+type Report struct {
+	InitialBalance float64 `json:"initial_balance"`
+	FinalEquity    float64 `json:"final_equity"`
+	NetProfit      float64 `json:"net_profit"`
+	Return         float64 `json:"return"`
+	// AnnualReturn 是按交易日数线性年化的收益率。
+	AnnualReturn float64 `json:"annual_return"`
+	// MaxDrawdown 是最大回撤金额，非正数。
+	MaxDrawdown float64 `json:"max_drawdown"`
+	// MaxDrawdownPct 是最大回撤比例，非正数。
+	MaxDrawdownPct float64 `json:"max_drawdown_pct"`
+	// Sharpe 按采样点收益计算并按每日采样数年化，无风险利率按 0。
+	Sharpe float64 `json:"sharpe"`
+	// Sortino 与 Sharpe 口径相同，分母只计下行波动。
+	Sortino float64 `json:"sortino"`
+	// Calmar 是年化收益除以最大回撤比例的绝对值。
+	Calmar float64 `json:"calmar"`
+	Trades int     `json:"trades"`
+	// WinRate 是净盈亏为正的平仓交易占比。
+	WinRate float64 `json:"win_rate"`
+	// PayoffRatio 是平均盈利除以平均亏损的绝对值。
+	PayoffRatio float64 `json:"payoff_ratio"`
+	// ProfitFactor 是盈利交易净盈亏之和除以亏损交易净亏损之和。
+	ProfitFactor float64 `json:"profit_factor"`
+	// Expectancy 是每笔平仓交易的平均净盈亏。
+	Expectancy float64 `json:"expectancy"`
+	// Exposure 是有持仓的时间占比，按采样间隔加权。
+	Exposure float64 `json:"exposure"`
+	// Turnover 是成交名义金额除以平均权益。
+	Turnover float64 `json:"turnover"`
+	// TradingDays 是采样覆盖的交易日数。
+	TradingDays int           `json:"trading_days"`
+	Equity      []EquityPoint `json:"equity"`
+	Daily       []DailyPnL    `json:"daily"`
+	// ClosedTrades 是逐笔平仓交易，按平仓时间先后排列。
+	ClosedTrades []ClosedTrade `json:"closed_trades,omitempty"`
+}
+
+// Analyze 根据权益采样和逐笔交易计算绩效报告。
+func Analyze(in Input) Report {
+	initial := in.InitialBalance
+	if initial <= 0 {
+		initial = DefaultInitialBalance
+	}
+	out := Report{InitialBalance: initial, FinalEquity: initial, Equity: []EquityPoint{}, Daily: []DailyPnL{}}
+	peak := initial
+	prev := initial
+	equitySum := 0.0
+	var returns []float64
+	var daily []DailyPnL
+	var inMarket, total time.Duration
+	inMarketSamples := 0
+	for i, s := range in.Samples {
+		peak = math.Max(peak, s.Equity)
+		point := EquityPoint{Time: s.Time, Equity: s.Equity, Drawdown: s.Equity - peak, Exposure: s.Exposure}
+		if peak > 0 {
+			point.DrawdownPct = point.Drawdown / peak
+		}
+		out.MaxDrawdown = math.Min(out.MaxDrawdown, point.Drawdown)
+		out.MaxDrawdownPct = math.Min(out.MaxDrawdownPct, point.DrawdownPct)
+		out.Equity = append(out.Equity, point)
+		if prev > 0 {
+			returns = append(returns, s.Equity/prev-1)
+		}
+		prev = s.Equity
+		equitySum += s.Equity
+		if s.Exposure > 0 {
+			inMarketSamples++
+		}
+		if i+1 < len(in.Samples) {
+			gap := in.Samples[i+1].Time.Sub(s.Time)
+			total += gap
+			if s.Exposure > 0 {
+				inMarket += gap
+			}
+		}
+		// 夜盘和凌晨的采样归到所属交易日，而不是自然日。
+		date := klineclock.TradingDayOf(s.Time).Format("2006-01-02")
+		if n := len(daily); n > 0 && daily[n-1].Date == date {
+			daily[n-1].Equity = s.Equity
+		} else {
+			daily = append(daily, DailyPnL{Date: date, Equity: s.Equity})
+		}
+	}
+	if n := len(in.Samples); n > 0 {
+		out.FinalEquity = in.Samples[n-1].Equity
+		switch {
+		case total > 0:
+			out.Exposure = float64(inMarket) / float64(total)
+		default:
+			out.Exposure = float64(inMarketSamples) / float64(n)
+		}
+		if avg := equitySum / float64(n); avg > 0 {
+			out.Turnover = in.TradedNotional / avg
+		}
+	}
+	out.NetProfit = out.FinalEquity - initial
+	out.Return = out.NetProfit / initial
+	dayClose := initial
+	for i := range daily {
+		daily[i].PnL = daily[i].Equity - dayClose
+		if dayClose > 0 {
+			daily[i].Return = daily[i].PnL / dayClose
+		}
+		dayClose = daily[i].Equity
+	}
+	if daily != nil {
+		out.Daily = daily
+	}
+	out.TradingDays = len(daily)
+	if out.TradingDays > 0 {
+		out.AnnualReturn = out.Return * tradingDaysPerYear / float64(out.TradingDays)
+		periodsPerDay := float64(len(in.Samples)) / float64(out.TradingDays)
+		out.Sharpe, out.Sortino = riskAdjusted(returns, periodsPerDay)
+	}
+	if out.MaxDrawdownPct < 0 {
+		out.Calmar = out.AnnualReturn / -out.MaxDrawdownPct
+	}
+	out.analyzeTrades(in.TradePnLs)
+	out.ClosedTrades = in.ClosedTrades
+	return out
+}
+
+// riskAdjusted 返回年化夏普和索提诺；样本不足或无波动时为 0。
+func riskAdjusted(returns []float64, periodsPerDay float64) (float64, float64) {
+	if len(returns) < 2 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	variance /= float64(len(returns) - 1)
+	downside /= float64(len(returns))
+	annualize := math.Sqrt(tradingDaysPerYear * periodsPerDay)
+	var sharpe, sortino float64
+	if variance > 0 {
+		sharpe = mean / math.Sqrt(variance) * annualize
+	}
+	switch {
+	case downside > 0:
+		sortino = mean / math.Sqrt(downside) * annualize
+	case mean > 0:
+		sortino = maxRatio
+	}
+	return sharpe, sortino
+}
+
+func (r *Report) analyzeTrades(pnls []float64) {
+	var grossWin, grossLoss float64
+	wins, losses := 0, 0
+	for _, pnl := range pnls {
+		if pnl > 0 {
+			wins++
+			grossWin += pnl
+		} else {
+			losses++
+			grossLoss -= pnl
+		}
+	}
+	r.Trades = len(pnls)
+	if r.Trades == 0 {
+		return
+	}
+	r.WinRate = float64(wins) / float64(r.Trades)
+	r.Expectancy = (grossWin - grossLoss) / float64(r.Trades)
+	switch {
+	case grossLoss > 0:
+		r.ProfitFactor = math.Min(grossWin/grossLoss, maxRatio)
+	case grossWin > 0:
+		r.ProfitFactor = maxRatio
+	}
+	switch {
+	case wins > 0 && losses > 0 && grossLoss > 0:
+		r.PayoffRatio = math.Min((grossWin/float64(wins))/(grossLoss/float64(losses)), maxRatio)
+	case wins > 0:
+		r.PayoffRatio = maxRatio
+	}
+}
+
+// Summary 返回不含序列的标量指标，键名与 JSON 字段一致，用于写入运行记录摘要。
+func (r Report) Summary() map[string]any {
+	return map[string]any{
+		"initial_balance":  r.InitialBalance,
+		"final_equity":     r.FinalEquity,
+		"net_profit":       r.NetProfit,
+		"return":           r.Return,
+		"annual_return":    r.AnnualReturn,
+		"max_drawdown":     r.MaxDrawdown,
+		"max_drawdown_pct": r.MaxDrawdownPct,
+		"sharpe":           r.Sharpe,
+		"sortino":          r.Sortino,
+		"calmar":           r.Calmar,
+		"trades":           r.Trades,
+		"win_rate":         r.WinRate,
+		"payoff_ratio":     r.PayoffRatio,
+		"profit_factor":    r.ProfitFactor,
+		"expectancy":       r.Expectancy,
+		"exposure":         r.Exposure,
+		"turnover":         r.Turnover,
+		"trading_days":     r.TradingDays,
+	}
+}
+
+// WriteEquityCSV 以 CSV 导出权益曲线与回撤序列。
+func (r Report) WriteEquityCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "equity", "drawdown", "drawdown_pct", "exposure"}); err != nil {
+		return err
+	}
+	for _, p := range r.Equity {
+		if err := cw.Write([]string{p.Time.Format(time.RFC3339), formatFloat(p.Equity), formatFloat(p.Drawdown), formatFloat(p.DrawdownPct), formatFloat(p.Exposure)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDailyCSV 以 CSV 导出逐日盈亏。
+func (r Report) WriteDailyCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"date", "pnl", "return", "equity"}); err != nil {
+		return err
+	}
+	for _, d := range r.Daily {
+		if err := cw.Write([]string{d.Date, formatFloat(d.PnL), formatFloat(d.Return), formatFloat(d.Equity)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,147 @@
+package perf
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackerPairsFIFOAndMarksToMarket(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	tr := NewTracker(10_000)
+	tr.Fill(Fill{Symbol: "RB2405", Direction: "buy", Price: 100, Volume: 2, VolumeMultiple: 10, Commission: 2, Time: base})
+	tr.Mark("rb2405", 103, base.Add(time.Minute))
+	if equity, exposure := tr.Equity(); equity != 10_000-2+60 || exposure != 2*103*10 {
+		t.Fatalf("equity=%v exposure=%v", equity, exposure)
+	}
+	// 卖 3 手：平掉 2 手多头（FIFO 一笔交易），再开 1 手空头。
+	tr.Fill(Fill{Symbol: "rb2405", Direction: "sell", Price: 105, Volume: 3, VolumeMultiple: 10, Commission: 3, Time: base.Add(2 * time.Minute)})
+	if tr.NetPosition("rb2405") != -1 {
+		t.Fatalf("NetPosition = %d", tr.NetPosition("rb2405"))
+	}
+	tr.Mark("rb2405", 107, base.Add(3*time.Minute))
+	tr.Fill(Fill{Symbol: "rb2405", Direction: "buy", Price: 107, Volume: 1, VolumeMultiple: 10, Commission: 1, Time: base.Add(3 * time.Minute)})
+
+	report := tr.Report()
+	// 第一笔：(105-100)*2*10 - 开仓 2 - 平仓 2 = 96；第二笔：(105-107)*1*10 - 1 - 1 = -22。
+	if report.Trades != 2 || math.Abs(report.Expectancy-(96-22)/2.0) > 1e-9 {
+		t.Fatalf("trades=%d expectancy=%v", report.Trades, report.Expectancy)
+	}
+	if report.WinRate != 0.5 || math.Abs(report.PayoffRatio-96.0/22) > 1e-9 || math.Abs(report.ProfitFactor-96.0/22) > 1e-9 {
+		t.Fatalf("report = %+v", report)
+	}
+	if math.Abs(report.FinalEquity-(10_000+96-22)) > 1e-9 || math.Abs(report.NetProfit-74) > 1e-9 {
+		t.Fatalf("final equity = %v", report.FinalEquity)
+	}
+	// 采样：09:00 开仓、09:01 盯市、09:02 反手、09:03 盯市与平仓合并为一个点。
+	if len(report.Equity) != 4 || !report.Equity[3].Time.Equal(base.Add(3*time.Minute)) || report.Equity[3].Exposure != 0 {
+		t.Fatalf("equity = %+v", report.Equity)
+	}
+	if report.Exposure != 1 {
+		t.Fatalf("Exposure = %v, want always in market before the last sample", report.Exposure)
+	}
+	wantTurnover := (2*100 + 3*105 + 1*107) * 10 / ((9_998 + 10_058 + 10_095 + 10_074) / 4.0)
+	if math.Abs(report.Turnover-wantTurnover) > 1e-9 {
+		t.Fatalf("Turnover = %v, want %v", report.Turnover, wantTurnover)
+	}
+	if report.MaxDrawdown != -21 || math.Abs(report.MaxDrawdownPct-(-21.0/10_095)) > 1e-12 {
+		t.Fatalf("drawdown = %v (%v)", report.MaxDrawdown, report.MaxDrawdownPct)
+	}
+}
+
+func TestAnalyzeRiskAdjustedAndDaily(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	report := Analyze(Input{
+		InitialBalance: 1_000,
+		Samples: []Sample{
+			{Time: day1, Equity: 1_010},
+			{Time: day1.Add(time.Hour), Equity: 990},
+			{Time: day2, Equity: 1_020, Exposure: 500},
+			{Time: day2.Add(time.Hour), Equity: 1_030},
+		},
+		TradePnLs: []float64{50, -20},
+	})
+	if len(report.Daily) != 2 || report.Daily[0].PnL != -10 || report.Daily[1].PnL != 40 || report.Daily[1].Equity != 1_030 {
+		t.Fatalf("daily = %+v", report.Daily)
+	}
+	if report.TradingDays != 2 || math.Abs(report.AnnualReturn-0.03*252/2) > 1e-12 {
+		t.Fatalf("annual return = %v days=%d", report.AnnualReturn, report.TradingDays)
+	}
+	if report.Sharpe <= 0 || report.Sortino <= report.Sharpe {
+		t.Fatalf("sharpe=%v sortino=%v", report.Sharpe, report.Sortino)
+	}
+	wantDD := 990.0/1_010 - 1
+	if math.Abs(report.MaxDrawdownPct-wantDD) > 1e-12 || math.Abs(report.Calmar-report.AnnualReturn/-wantDD) > 1e-9 {
+		t.Fatalf("calmar=%v dd=%v", report.Calmar, report.MaxDrawdownPct)
+	}
+	// 采样间隔共 1h+23h+1h，只有第三个点之后的 1h 有持仓。
+	if math.Abs(report.Exposure-1.0/25) > 1e-12 {
+		t.Fatalf("Exposure = %v", report.Exposure)
+	}
+
+	empty := Analyze(Input{})
+	if empty.InitialBalance != DefaultInitialBalance || empty.Sharpe != 0 || empty.Equity == nil || empty.Daily == nil {
+		t.Fatalf("empty report = %+v", empty)
+	}
+	if onlyWins := Analyze(Input{TradePnLs: []float64{10}}); onlyWins.ProfitFactor != maxRatio || onlyWins.PayoffRatio != maxRatio {
+		t.Fatalf("only wins = %+v", onlyWins)
+	}
+}
+
+func TestAnalyzeDailyBucketsNightSessionByTradingDay(t *testing.T) {
+	t.Parallel()
+
+	// 2026-03-06 是周五：周五夜盘和周六凌晨属于 03-09（周一）交易日。
+	friday := time.Date(2026, 3, 6, 14, 0, 0, 0, time.Local)
+	report := Analyze(Input{
+		InitialBalance: 1_000,
+		Samples: []Sample{
+			{Time: friday, Equity: 1_010},
+			{Time: friday.Add(7 * time.Hour), Equity: 1_020},
+			{Time: friday.Add(11 * time.Hour), Equity: 1_030},
+			{Time: time.Date(2026, 3, 9, 10, 0, 0, 0, time.Local), Equity: 1_050},
+			{Time: time.Date(2026, 3, 9, 21, 30, 0, 0, time.Local), Equity: 1_040},
+		},
+	})
+	want := []DailyPnL{
+		{Date: "2026-03-06", PnL: 10, Equity: 1_010},
+		{Date: "2026-03-09", PnL: 40, Equity: 1_050},
+		{Date: "2026-03-10", PnL: -10, Equity: 1_040},
+	}
+	if len(report.Daily) != len(want) || report.TradingDays != len(want) {
+		t.Fatalf("daily = %+v, want %d trading days", report.Daily, len(want))
+	}
+	for i, w := range want {
+		got := report.Daily[i]
+		if got.Date != w.Date || got.PnL != w.PnL || got.Equity != w.Equity {
+			t.Fatalf("daily[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestReportCSVExport(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	report := Analyze(Input{InitialBalance: 100, Samples: []Sample{{Time: at, Equity: 101.5}}})
+	var equity, daily bytes.Buffer
+	if err := report.WriteEquityCSV(&equity); err != nil {
+		t.Fatalf("WriteEquityCSV() error = %v", err)
+	}
+	if err := report.WriteDailyCSV(&daily); err != nil {
+		t.Fatalf("WriteDailyCSV() error = %v", err)
+	}
+	if got := strings.Split(strings.TrimSpace(equity.String()), "\n"); len(got) != 2 || got[1] != "2026-03-02T09:00:00Z,101.5,0,0,0" {
+		t.Fatalf("equity csv = %q", equity.String())
+	}
+	if got := strings.Split(strings.TrimSpace(daily.String()), "\n"); len(got) != 2 || !strings.HasSuffix(got[1], ",1.5,0.015,101.5") {
+		t.Fatalf("daily csv = %q", daily.String())
+	}
+}
@@ -0,0 +1,170 @@
+package perf
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Fill 是一笔成交。方向按净持仓处理：buy 增加、sell 减少，开平标志不参与计算。
+type Fill struct {
+	Symbol    string
+	Direction string
+	Price     float64
+	Volume    int
+	// VolumeMultiple 是合约乘数，<=0 时按 1 计算。
+	VolumeMultiple float64
+	// Commission 是这笔成交的手续费。
+	Commission float64
+	Time       time.Time
+}
+
+// lot 是一笔未平仓的开仓批次，qty 多头为正、空头为负。
+type lot struct {
+	qty        int
+	price      float64
+	commission float64
+}
+
+type trackedPosition struct {
+	lots     []lot
+	multiple float64
+}
+
+func (p *trackedPosition) net() int {
+	net := 0
+	for _, l := range p.lots {
+		net += l.qty
+	}
+	return net
+}
+
+// Tracker 按成交和行情标记累积权益采样，平仓按 FIFO 配对成逐笔交易。
+// 不加锁，由调用方保证串行访问。
+type Tracker struct {
+	initial    float64
+	positions  map[string]*trackedPosition
+	marks      map[string]float64
+	realized   float64
+	commission float64
+	notional   float64
+	tradePnLs  []float64
+	trades     []ClosedTrade
+	samples    []Sample
+}
+
+// NewTracker 创建空仓的绩效跟踪器，initialBalance<=0 时使用 DefaultInitialBalance。
+func NewTracker(initialBalance float64) *Tracker {
+	if initialBalance <= 0 {
+		initialBalance = DefaultInitialBalance
+	}
+	return &Tracker{initial: initialBalance, positions: make(map[string]*trackedPosition), marks: make(map[string]float64)}
+}
+
+// Fill 记一笔成交并以成交价盯市，平掉的部分按 FIFO 计入逐笔交易，开仓与平仓手续费按手数分摊到交易上。
+func (t *Tracker) Fill(f Fill) {
+	if f.Volume <= 0 || f.Price <= 0 {
+		return
+	}
+	symbol := strings.ToLower(strings.TrimSpace(f.Symbol))
+	multiple := f.VolumeMultiple
+	if multiple <= 0 {
+		multiple = 1
+	}
+	pos := t.positions[symbol]
+	if pos == nil {
+		pos = &trackedPosition{}
+		t.positions[symbol] = pos
+	}
+	pos.multiple = multiple
+	sign := 1
+	if strings.EqualFold(strings.TrimSpace(f.Direction), "sell") {
+		sign = -1
+	}
+	t.commission += f.Commission
+	t.notional += f.Price * float64(f.Volume) * multiple
+	perLot := f.Commission / float64(f.Volume)
+	remaining := f.Volume
+	for remaining > 0 && len(pos.lots) > 0 && pos.lots[0].qty*sign < 0 {
+		head := &pos.lots[0]
+		size := min(remaining, abs(head.qty))
+		entryCommission := head.commission * float64(size) / float64(abs(head.qty))
+		// 多头批次被卖出平仓时 sign=-1，盈亏为 (平仓价-开仓价)*手数。
+		gross := (f.Price - head.price) * float64(size) * multiple * float64(-sign)
+		t.realized += gross
+		net := gross - entryCommission - perLot*float64(size)
+		t.tradePnLs = append(t.tradePnLs, net)
+		t.trades = append(t.trades, ClosedTrade{Symbol: symbol, ExitTime: f.Time, Volume: size, VolumeMultiple: multiple, PnL: net})
+		head.commission -= entryCommission
+		head.qty += sign * size
+		remaining -= size
+		if head.qty == 0 {
+			pos.lots = pos.lots[1:]
+		}
+	}
+	if remaining > 0 {
+		pos.lots = append(pos.lots, lot{qty: sign * remaining, price: f.Price, commission: perLot * float64(remaining)})
+	}
+	t.marks[symbol] = f.Price
+	t.sample(f.Time)
+}
+
+// Mark 用最新价格盯市并记录一个采样点；同一时间的多次标记只保留最后一次。
+func (t *Tracker) Mark(symbol string, price float64, at time.Time) {
+	if price <= 0 {
+		return
+	}
+	t.marks[strings.ToLower(strings.TrimSpace(symbol))] = price
+	t.sample(at)
+}
+
+// NetPosition 返回合约的净持仓手数。
+func (t *Tracker) NetPosition(symbol string) int {
+	if pos := t.positions[strings.ToLower(strings.TrimSpace(symbol))]; pos != nil {
+		return pos.net()
+	}
+	return 0
+}
+
+// Equity 返回当前盯市权益和持仓名义价值。
+func (t *Tracker) Equity() (float64, float64) {
+	equity := t.initial + t.realized - t.commission
+	exposure := 0.0
+	for symbol, pos := range t.positions {
+		mark := t.marks[symbol]
+		for _, l := range pos.lots {
+			equity += (mark - l.price) * float64(l.qty) * pos.multiple
+			exposure += math.Abs(float64(l.qty)) * mark * pos.multiple
+		}
+	}
+	return equity, exposure
+}
+
+func (t *Tracker) sample(at time.Time) {
+	equity, exposure := t.Equity()
+	s := Sample{Time: at, Equity: equity, Exposure: exposure}
+	if n := len(t.samples); n > 0 && !at.After(t.samples[n-1].Time) {
+		s.Time = t.samples[n-1].Time
+		t.samples[n-1] = s
+		return
+	}
+	t.samples = append(t.samples, s)
+}
+
+// Report 计算当前累积数据的绩效报告。
+func (t *Tracker) Report() Report {
+	return Analyze(Input{
+		InitialBalance: t.initial,
+		Samples:        t.samples,
+		TradePnLs:      t.tradePnLs,
+		ClosedTrades:   t.trades,
+		TradedNotional: t.notional,
+	})
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
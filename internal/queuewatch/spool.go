@@ -86,28 +86,34 @@ func (s *JSONSpool[T]) Dequeue() (T, bool, int64, error) {
 	path := filepath.Join(s.dir, name)
 	payload, err := os.ReadFile(path)
 	if err != nil {
+		s.forget(name)
 		return zero, false, 0, err
 	}
 	var out T
 	if err := json.Unmarshal(payload, &out); err != nil {
+		_ = os.Remove(path)
+		s.forget(name)
 		return zero, false, 0, err
 	}
-	if err := os.Remove(path); err != nil {
-		return zero, false, 0, err
+	// 删除失败时也移出队列，否则同一条记录会被反复取出重复处理。
+	removeErr := os.Remove(path)
+	s.forget(name)
+	if removeErr != nil {
+		return zero, false, 0, removeErr
 	}
+	return out, true, int64(len(payload)), nil
+}
+
+// forget 把读不出、解不开或删不掉的记录移出待处理列表，避免 Pending 永远不归零、同一条记录被无限重试。
+func (s *JSONSpool[T]) forget(name string) {
 	s.mu.Lock()
-	if len(s.files) > 0 && s.files[0] == name {
-		s.files = s.files[1:]
-	} else {
-		for i := range s.files {
-			if s.files[i] == name {
-				s.files = append(s.files[:i], s.files[i+1:]...)
-				break
-			}
+	defer s.mu.Unlock()
+	for i := range s.files {
+		if s.files[i] == name {
+			s.files = append(s.files[:i], s.files[i+1:]...)
+			return
 		}
 	}
-	s.mu.Unlock()
-	return out, true, int64(len(payload)), nil
 }
 
 func (s *JSONSpool[T]) Pending() int {
@@ -1,6 +1,10 @@
 package queuewatch
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
 
 func TestJSONSpoolPersistsAndRecoversFIFO(t *testing.T) {
 	t.Parallel()
@@ -47,3 +51,37 @@ func TestJSONSpoolPersistsAndRecoversFIFO(t *testing.T) {
 		t.Fatalf("pending after drain = %d, want 0", got)
 	}
 }
+
+func TestJSONSpoolDropsUndecodableEntry(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	spool, err := NewJSONSpool[string](root, "bad_queue")
+	if err != nil {
+		t.Fatalf("NewJSONSpool() error = %v", err)
+	}
+	if _, err := spool.Enqueue("good"); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bad_queue", "00000000000000000000-000000.json"), []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spool, err = NewJSONSpool[string](root, "bad_queue")
+	if err != nil {
+		t.Fatalf("reload spool failed: %v", err)
+	}
+	if got := spool.Pending(); got != 2 {
+		t.Fatalf("pending after reload = %d, want 2", got)
+	}
+
+	if _, ok, _, err := spool.Dequeue(); err == nil || ok {
+		t.Fatalf("dequeue broken entry: ok=%v err=%v, want decode error", ok, err)
+	}
+	if got := spool.Pending(); got != 1 {
+		t.Fatalf("pending after broken entry = %d, want 1", got)
+	}
+	got, ok, _, err := spool.Dequeue()
+	if err != nil || !ok || got != "good" {
+		t.Fatalf("dequeue after broken entry = %q ok=%v err=%v", got, ok, err)
+	}
+}
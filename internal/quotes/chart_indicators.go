@@ -0,0 +1,171 @@
+// chart_indicators.go 负责图表订阅上的实时指标。
+// 订阅可以附带 indicators 列表（如 ["ma(20)", "macd"]），同一订阅键下的指标按规范化后的描述共享并引用计数；
+// 已收盘 K 线推进指标状态，未收盘 K 线只在状态副本上预览，推送时随 ChartBarUpdate.Indicators 下发。
+package quotes
+
+import (
+	"fmt"
+	"strings"
+
+	"ctp-future-kline/internal/indicators"
+)
+
+// chartIndicatorItem 是一个订阅键下的单个流式指标。
+type chartIndicatorItem struct {
+	spec     indicators.Spec
+	ind      indicators.Indicator
+	refs     int
+	lastTime int64
+	last     indicators.Value
+}
+
+// normalizeChartIndicators 校验并规范化订阅里的指标描述，去重后保持原有顺序。
+func normalizeChartIndicators(items []string) ([]string, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+	out := make([]string, 0, len(items))
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		spec, err := indicators.ParseSpec(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid indicator: %w", err)
+		}
+		key := spec.String()
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, key)
+	}
+	return out, nil
+}
+
+func (s *ChartStream) addIndicatorsLocked(key string, specs []string) {
+	if len(specs) == 0 {
+		return
+	}
+	if s.indicatorSets == nil {
+		s.indicatorSets = make(map[string]map[string]*chartIndicatorItem)
+	}
+	set := s.indicatorSets[key]
+	if set == nil {
+		set = make(map[string]*chartIndicatorItem)
+		s.indicatorSets[key] = set
+	}
+	for _, raw := range specs {
+		if item := set[raw]; item != nil {
+			item.refs++
+			continue
+		}
+		spec, err := indicators.ParseSpec(raw)
+		if err != nil {
+			continue
+		}
+		ind, err := indicators.New(spec)
+		if err != nil {
+			continue
+		}
+		set[raw] = &chartIndicatorItem{spec: spec, ind: ind, refs: 1}
+	}
+}
+
+func (s *ChartStream) removeIndicatorsLocked(key string, specs []string) {
+	set := s.indicatorSets[key]
+	if set == nil {
+		return
+	}
+	for _, raw := range specs {
+		if item := set[raw]; item != nil {
+			if item.refs--; item.refs <= 0 {
+				delete(set, raw)
+			}
+		}
+	}
+	if len(set) == 0 || s.interests[key] == 0 {
+		delete(s.indicatorSets, key)
+	}
+}
+
+// SeedIndicators 用历史 K 线预热订阅上的指标，bars 需按时间升序；已经处理过的 K 线会被跳过，
+// 因此后加入的指标可以单独补齐历史而不影响已有指标。
+func (s *ChartStream) SeedIndicators(raw ChartSubscription, bars []ChartBar) {
+	if s == nil || len(bars) == 0 {
+		return
+	}
+	sub, err := NormalizeChartSubscription(raw)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.indicatorSets[ChartSubscriptionKey(sub)] {
+		for _, bar := range bars {
+			if bar.AdjustedTime > item.lastTime {
+				item.last = item.ind.Update(chartIndicatorBar(bar))
+				item.lastTime = bar.AdjustedTime
+			}
+		}
+	}
+}
+
+// indicatorValuesLocked 在订阅键的指标上应用一根 K 线：final 推进状态，partial 只预览。
+// K 线时间回退（例如回放重新开始）时重建指标，避免沿用旧状态。
+func (s *ChartStream) indicatorValuesLocked(key string, bar ChartBar, final bool) map[string]indicators.Value {
+	set := s.indicatorSets[key]
+	if len(set) == 0 {
+		return nil
+	}
+	out := make(map[string]indicators.Value, len(set))
+	for name, item := range set {
+		if bar.AdjustedTime < item.lastTime {
+			if ind, err := indicators.New(item.spec); err == nil {
+				item.ind, item.lastTime, item.last = ind, 0, nil
+			}
+		}
+		switch {
+		case bar.AdjustedTime == item.lastTime:
+			out[name] = item.last
+		case final:
+			item.last = item.ind.Update(chartIndicatorBar(bar))
+			item.lastTime = bar.AdjustedTime
+			out[name] = item.last
+		default:
+			out[name] = indicators.Preview(item.ind, chartIndicatorBar(bar))
+		}
+	}
+	return out
+}
+
+func (s *ChartStream) indicatorSnapshotLocked(key string) map[string]indicators.Value {
+	set := s.indicatorSets[key]
+	if len(set) == 0 {
+		return nil
+	}
+	out := make(map[string]indicators.Value, len(set))
+	for name, item := range set {
+		out[name] = item.last
+	}
+	return out
+}
+
+// resetReplayIndicatorsLocked 清空回放订阅上的指标状态，保留引用计数，供新一轮回放重新预热。
+func (s *ChartStream) resetReplayIndicatorsLocked() {
+	for key, set := range s.indicatorSets {
+		if !strings.HasSuffix(key, "|replay") {
+			continue
+		}
+		for _, item := range set {
+			if ind, err := indicators.New(item.spec); err == nil {
+				item.ind, item.lastTime, item.last = ind, 0, nil
+			}
+		}
+	}
+}
+
+func chartIndicatorBar(bar ChartBar) indicators.Bar {
+	return indicators.Bar{Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: float64(bar.Volume)}
+}
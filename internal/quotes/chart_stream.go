@@ -8,6 +8,7 @@ import (
 	"time"
 
 	dbx "ctp-future-kline/internal/db"
+	"ctp-future-kline/internal/indicators"
 	"ctp-future-kline/internal/klineclock"
 	"ctp-future-kline/internal/queuewatch"
 )
@@ -18,6 +19,10 @@ type ChartSubscription struct {
 	Variety   string `json:"variety"`
 	Timeframe string `json:"timeframe"`
 	DataMode  string `json:"data_mode"`
+	// ReplaySession 是回放会话标识，为空表示默认会话；非默认会话的图表状态彼此隔离。
+	ReplaySession string `json:"replay_session,omitempty"`
+	// Indicators 是订阅附带的实时指标描述，例如 ma(20)、macd(12,26,9)；不参与订阅键。
+	Indicators []string `json:"indicators,omitempty"`
 }
 
 type ChartBar struct {
@@ -36,6 +41,8 @@ type ChartBarUpdate struct {
 	Phase        string            `json:"phase"`
 	Source       string            `json:"source"`
 	Bar          ChartBar          `json:"bar"`
+	// Indicators 是订阅指标在这根 K 线上的值，键为规范化后的指标描述。
+	Indicators map[string]indicators.Value `json:"indicators,omitempty"`
 }
 
 type ChartQuoteSnapshot struct {
@@ -166,8 +173,12 @@ type ChartStream struct {
 	subscribers map[chan ChartBarUpdate]struct{}
 	quoteSubs   map[chan ChartQuoteUpdate]struct{}
 	roots       map[string]*chartRootState
-	queueHandle *queuewatch.QueueHandle
-	queueCap    int
+	// indicatorSets 按订阅键保存实时指标，内层键为规范化后的指标描述。
+	indicatorSets map[string]map[string]*chartIndicatorItem
+	queueHandle   *queuewatch.QueueHandle
+	queueCap      int
+	// borrowedDB 表示 db 借自父图表流（回放会话命名空间），Close 时不关闭。
+	borrowedDB bool
 }
 
 var (
@@ -209,8 +220,29 @@ func NewChartStream(dsn string, registry *queuewatch.Registry) (*ChartStream, er
 	return stream, nil
 }
 
+// NewReplaySessionChartStream 为回放会话创建独立的图表命名空间：复用父图表流的数据库、交易日历和时段解析，
+// 但根状态、订阅兴趣和订阅者各自独立，会话之间的最新 bar/tick 互不可见。
+func NewReplaySessionChartStream(parent *ChartStream) *ChartStream {
+	if parent == nil {
+		return nil
+	}
+	return &ChartStream{
+		db:              parent.db,
+		store:           parent.store,
+		clock:           parent.clock,
+		sessionResolver: parent.sessionResolver,
+		interests:       make(map[string]int),
+		quoteKeys:       make(map[string]int),
+		subscribers:     make(map[chan ChartBarUpdate]struct{}),
+		quoteSubs:       make(map[chan ChartQuoteUpdate]struct{}),
+		roots:           make(map[string]*chartRootState),
+		queueCap:        parent.queueCap,
+		borrowedDB:      true,
+	}
+}
+
 func (s *ChartStream) Close() error {
-	if s == nil || s.db == nil {
+	if s == nil || s.db == nil || s.borrowedDB {
 		return nil
 	}
 	return s.db.Close()
@@ -224,6 +256,7 @@ func (s *ChartStream) ResetReplayState() {
 	}
 	s.mu.Lock()
 	s.roots = make(map[string]*chartRootState)
+	s.resetReplayIndicatorsLocked()
 	s.mu.Unlock()
 }
 
@@ -277,6 +310,9 @@ func NormalizeChartSubscription(raw ChartSubscription) (ChartSubscription, error
 		Timeframe: strings.ToLower(strings.TrimSpace(raw.Timeframe)),
 		DataMode:  strings.ToLower(strings.TrimSpace(raw.DataMode)),
 	}
+	if session := strings.ToLower(strings.TrimSpace(raw.ReplaySession)); session != "default" {
+		sub.ReplaySession = session
+	}
 	if sub.Symbol == "" {
 		return ChartSubscription{}, fmt.Errorf("symbol is required")
 	}
@@ -310,17 +346,27 @@ func NormalizeChartSubscription(raw ChartSubscription) (ChartSubscription, error
 	if sub.Variety == "" {
 		return ChartSubscription{}, fmt.Errorf("variety is required")
 	}
+	specs, err := normalizeChartIndicators(raw.Indicators)
+	if err != nil {
+		return ChartSubscription{}, err
+	}
+	sub.Indicators = specs
 	return sub, nil
 }
 
 func ChartSubscriptionKey(sub ChartSubscription) string {
-	return strings.Join([]string{
+	parts := []string{
 		strings.ToLower(strings.TrimSpace(sub.Symbol)),
 		strings.ToLower(strings.TrimSpace(sub.Type)),
 		strings.ToLower(strings.TrimSpace(sub.Variety)),
 		strings.ToLower(strings.TrimSpace(sub.Timeframe)),
 		strings.ToLower(strings.TrimSpace(sub.DataMode)),
-	}, "|")
+	}
+	// 默认会话不带会话段，保持原有订阅键不变。
+	if session := strings.ToLower(strings.TrimSpace(sub.ReplaySession)); session != "" && session != "default" {
+		parts = append(parts, session)
+	}
+	return strings.Join(parts, "|")
 }
 
 func (s *ChartStream) AddInterest(raw ChartSubscription) (ChartSubscription, error) {
@@ -328,8 +374,10 @@ func (s *ChartStream) AddInterest(raw ChartSubscription) (ChartSubscription, err
 	if err != nil {
 		return ChartSubscription{}, err
 	}
+	key := ChartSubscriptionKey(sub)
 	s.mu.Lock()
-	s.interests[ChartSubscriptionKey(sub)] += 1
+	s.interests[key] += 1
+	s.addIndicatorsLocked(key, sub.Indicators)
 	s.mu.Unlock()
 	return sub, nil
 }
@@ -346,6 +394,7 @@ func (s *ChartStream) RemoveInterest(raw ChartSubscription) {
 	} else {
 		delete(s.interests, key)
 	}
+	s.removeIndicatorsLocked(key, sub.Indicators)
 	s.mu.Unlock()
 }
 
@@ -488,11 +537,14 @@ func (s *ChartStream) SnapshotBar(raw ChartSubscription) (ChartBarUpdate, bool)
 	if phase == "" {
 		phase = "final"
 	}
+	key := ChartSubscriptionKey(sub)
+	sub.Indicators = nil
 	return ChartBarUpdate{
 		Subscription: sub,
 		Phase:        phase,
 		Source:       sub.DataMode,
 		Bar:          chartBarFromMinuteBar(bar),
+		Indicators:   s.indicatorSnapshotLocked(key),
 	}, true
 }
 
@@ -692,17 +744,20 @@ func (s *ChartStream) HandleFinalBar(bar minuteBar, replay bool) {
 		if timeframe != strings.ToLower(strings.TrimSpace(bar.Period)) {
 			continue
 		}
+		sub := ChartSubscription{
+			Symbol:    symbol,
+			Type:      kind,
+			Variety:   variety,
+			Timeframe: timeframe,
+			DataMode:  dataMode,
+		}
+		chartBar := chartBarFromMinuteBar(bar)
 		updates = append(updates, ChartBarUpdate{
-			Subscription: ChartSubscription{
-				Symbol:    symbol,
-				Type:      kind,
-				Variety:   variety,
-				Timeframe: timeframe,
-				DataMode:  dataMode,
-			},
-			Phase:  "final",
-			Source: chartSourceLabel(replay),
-			Bar:    chartBarFromMinuteBar(bar),
+			Subscription: sub,
+			Phase:        "final",
+			Source:       chartSourceLabel(replay),
+			Bar:          chartBar,
+			Indicators:   s.indicatorValuesLocked(ChartSubscriptionKey(sub), chartBar, true),
 		})
 	}
 	s.mu.Unlock()
@@ -741,17 +796,20 @@ func (s *ChartStream) HandlePartialBar(bar minuteBar, replay bool) {
 	frames := s.interestedTimeframesLocked(symbol, kind, variety, dataMode)
 	for _, timeframe := range frames {
 		if timeframe == strings.ToLower(strings.TrimSpace(bar.Period)) {
+			sub := ChartSubscription{
+				Symbol:    symbol,
+				Type:      kind,
+				Variety:   variety,
+				Timeframe: timeframe,
+				DataMode:  dataMode,
+			}
+			chartBar := chartBarFromMinuteBar(bar)
 			updates = append(updates, ChartBarUpdate{
-				Subscription: ChartSubscription{
-					Symbol:    symbol,
-					Type:      kind,
-					Variety:   variety,
-					Timeframe: timeframe,
-					DataMode:  dataMode,
-				},
-				Phase:  "partial",
-				Source: chartSourceLabel(replay),
-				Bar:    chartBarFromMinuteBar(bar),
+				Subscription: sub,
+				Phase:        "partial",
+				Source:       chartSourceLabel(replay),
+				Bar:          chartBar,
+				Indicators:   s.indicatorValuesLocked(ChartSubscriptionKey(sub), chartBar, false),
 			})
 		}
 	}
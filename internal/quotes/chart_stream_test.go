@@ -35,6 +35,27 @@ func TestChartSubscriptionKeyStable(t *testing.T) {
 	}
 }
 
+func TestChartSubscriptionKeySeparatesReplaySessions(t *testing.T) {
+	base := ChartSubscription{Symbol: "agl9", Type: "l9", Variety: "ag", Timeframe: "1m", DataMode: "replay"}
+	withDefault := base
+	withDefault.ReplaySession = "Default"
+	if ChartSubscriptionKey(base) != ChartSubscriptionKey(withDefault) {
+		t.Fatalf("default session should share the legacy key: %s vs %s", ChartSubscriptionKey(base), ChartSubscriptionKey(withDefault))
+	}
+	alice := base
+	alice.ReplaySession = "Alice"
+	if ChartSubscriptionKey(base) == ChartSubscriptionKey(alice) {
+		t.Fatalf("session subscription should have its own key: %s", ChartSubscriptionKey(alice))
+	}
+	sub, err := NormalizeChartSubscription(alice)
+	if err != nil {
+		t.Fatalf("NormalizeChartSubscription error: %v", err)
+	}
+	if sub.ReplaySession != "alice" {
+		t.Fatalf("ReplaySession = %q, want alice", sub.ReplaySession)
+	}
+}
+
 func TestSnapshotQuoteContractIncludesRecentTicks(t *testing.T) {
 	stream := &ChartStream{
 		roots: make(map[string]*chartRootState),
@@ -189,3 +210,49 @@ func TestSnapshotBarUsesLatestCachedPhase(t *testing.T) {
 		t.Fatalf("adjusted_time=%d want %d", update.Bar.AdjustedTime, time.Date(2026, 4, 5, 21, 5, 0, 0, time.Local).Unix())
 	}
 }
+
+func TestChartStreamIndicatorsAdvanceOnFinalAndPreviewPartial(t *testing.T) {
+	t.Parallel()
+
+	stream := &ChartStream{
+		interests:   make(map[string]int),
+		subscribers: make(map[chan ChartBarUpdate]struct{}),
+		roots:       make(map[string]*chartRootState),
+	}
+	sub, err := stream.AddInterest(ChartSubscription{Symbol: "ag2605", Timeframe: "5m", Indicators: []string{"MA(2)", "ma(2)", "macd"}})
+	if err != nil {
+		t.Fatalf("AddInterest error: %v", err)
+	}
+	if len(sub.Indicators) != 2 || sub.Indicators[0] != "ma(2)" || sub.Indicators[1] != "macd(12,26,9)" {
+		t.Fatalf("normalized indicators = %v", sub.Indicators)
+	}
+	if _, err := NormalizeChartSubscription(ChartSubscription{Symbol: "ag2605", Timeframe: "5m", Indicators: []string{"nope"}}); err == nil {
+		t.Fatal("unknown indicator should be rejected")
+	}
+	base := time.Date(2026, 4, 5, 21, 0, 0, 0, time.Local)
+	stream.SeedIndicators(sub, []ChartBar{
+		{AdjustedTime: base.Unix(), Close: 10},
+		{AdjustedTime: base.Add(5 * time.Minute).Unix(), Close: 20},
+	})
+	ch, cancel := stream.Subscribe()
+	defer cancel()
+
+	bar := minuteBar{InstrumentID: "ag2605", Variety: "ag", Period: "5m", MinuteTime: base.Add(10 * time.Minute), AdjustedTime: base.Add(10 * time.Minute), Close: 40}
+	stream.HandlePartialBar(bar, false)
+	if got := (<-ch).Indicators["ma(2)"]["ma"]; got != 30 {
+		t.Fatalf("partial ma = %v, want 30", got)
+	}
+	bar.Close = 30
+	stream.HandleFinalBar(bar, false)
+	if got := (<-ch).Indicators["ma(2)"]["ma"]; got != 25 {
+		t.Fatalf("final ma = %v, want 25 (partial preview must not advance state)", got)
+	}
+	if update, ok := stream.SnapshotBar(sub); !ok || update.Indicators["ma(2)"]["ma"] != 25 {
+		t.Fatalf("snapshot = %+v ok=%v", update, ok)
+	}
+
+	stream.RemoveInterest(sub)
+	if len(stream.indicatorSets) != 0 {
+		t.Fatalf("indicator sets should be released, got %v", stream.indicatorSets)
+	}
+}
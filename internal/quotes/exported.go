@@ -41,6 +41,9 @@ func NewKlineStore(path string) (*klineStore, error) {
 }
 
 func (s *klineStore) DB() *sql.DB {
+	if s == nil {
+		return nil
+	}
 	return s.db
 }
 
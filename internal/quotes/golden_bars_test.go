@@ -0,0 +1,441 @@
+// golden_bars_test.go 是 bar 生成链路的 golden 回归测试。
+// testdata/golden_bars 下每个子目录是一个用例：ticks/ 放 tick CSV，case.json 给出品种交易时段和比对容差，
+// expected/ 放期望输出。测试走真实的 replay.Service tick_dir 回放 -> ReplaySink -> marketDataRuntime 链路
+// 生成合约和 L9 的 1m/5m/15m/30m/1h/1d，再把生成的 1m 交给 mmkline 重建链路重新聚合，两份结果分别与 golden 比对。
+// 修改 ResolveLabelMinute、交易时段或聚合逻辑后，先确认差异符合预期，再用
+//
+//	go test ./internal/quotes -run TestGoldenBars -update-golden
+//
+// 重写 expected/ 并随改动一起提交。
+package quotes
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/bus"
+	"ctp-future-kline/internal/klineagg"
+	"ctp-future-kline/internal/klinesettings"
+	"ctp-future-kline/internal/mmkline"
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/sessiontime"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite testdata/golden_bars/*/expected from the current pipeline output")
+
+const goldenTimeLayout = "2006-01-02 15:04:05"
+
+var goldenHeader = []string{"instrument_id", "period", "data_time", "adjusted_time", "open", "high", "low", "close", "volume", "open_interest"}
+
+type goldenCase struct {
+	Description string `json:"description"`
+	// Sessions 是品种到交易时段文本的映射，例如 "21:00-23:00,09:00-10:15"，未配置的品种按默认时段处理。
+	Sessions  map[string]string `json:"sessions"`
+	Tolerance goldenTolerance   `json:"tolerance"`
+}
+
+type goldenTolerance struct {
+	Price        float64 `json:"price"`
+	Volume       int64   `json:"volume"`
+	OpenInterest float64 `json:"open_interest"`
+}
+
+type goldenBar struct {
+	InstrumentID string
+	Period       string
+	DataTime     time.Time
+	AdjustedTime time.Time
+	Open         float64
+	High         float64
+	Low          float64
+	Close        float64
+	Volume       int64
+	OpenInterest float64
+}
+
+func (b goldenBar) key() string {
+	return b.InstrumentID + "|" + b.Period + "|" + b.DataTime.Format(goldenTimeLayout)
+}
+
+// goldenReplayHook 把任务结束交给 ReplaySink.OnTaskFinished 封口各合约最后一根 bar，完成后通知测试。
+// 回放服务按 map 顺序调用生命周期钩子，所以通知必须排在 sink 封口之后，不能单独注册。
+type goldenReplayHook struct {
+	sink *ReplaySink
+	done chan replay.TaskSnapshot
+}
+
+func (h goldenReplayHook) OnTaskFinished(ctx context.Context, snap replay.TaskSnapshot) error {
+	err := h.sink.OnTaskFinished(ctx, snap)
+	h.done <- snap
+	return err
+}
+
+// drainGoldenRuntime 等此前入队的 tick 在各 shard 处理完，再等由此触发的 L9 计算结束，
+// 让多合约之间的处理顺序与 tick 时间顺序一致。它不封口当前分钟。
+func drainGoldenRuntime(rt *marketDataRuntime) error {
+	for _, shard := range rt.shards {
+		done := make(chan struct{})
+		shard.in <- drainRequest{done: done}
+		<-done
+	}
+	if !rt.l9Async.waitIdle(10 * time.Second) {
+		return fmt.Errorf("l9 tasks still running after drain")
+	}
+	return nil
+}
+
+func TestGoldenBars(t *testing.T) {
+	root := filepath.Join("testdata", "golden_bars")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("read %s: %v", root, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		t.Run(entry.Name(), func(t *testing.T) {
+			t.Parallel()
+			runGoldenCase(t, dir)
+		})
+	}
+}
+
+func runGoldenCase(t *testing.T, dir string) {
+	var gc goldenCase
+	raw, err := os.ReadFile(filepath.Join(dir, "case.json"))
+	if err != nil {
+		t.Fatalf("read case.json: %v", err)
+	}
+	if err := json.Unmarshal(raw, &gc); err != nil {
+		t.Fatalf("decode case.json: %v", err)
+	}
+	sessions := make(map[string][]sessiontime.Range, len(gc.Sessions))
+	for variety, text := range gc.Sessions {
+		ranges, err := sessiontime.ParseSessionText(text)
+		if err != nil {
+			t.Fatalf("parse sessions of %s: %v", variety, err)
+		}
+		sessions[variety] = ranges
+	}
+	resolver := newStaticSessionResolver(sessions)
+
+	runtimeBars := replayGoldenTicks(t, filepath.Join(dir, "ticks"), resolver)
+	rebuildBars := rebuildGoldenBars(t, runtimeBars, resolver)
+	checkGoldenBars(t, filepath.Join(dir, "expected", "runtime.csv"), runtimeBars, gc.Tolerance)
+	checkGoldenBars(t, filepath.Join(dir, "expected", "rebuild.csv"), rebuildBars, gc.Tolerance)
+}
+
+// replayGoldenTicks 用 replay.Service 回放 tickDir，返回 runtime 交给落库队列的全部 bar。
+// 同一 bar 可能被多次落库（例如 L9 在各合约封口时重算），这里和库表主键一样按最后一次为准。
+// 每个 tick 处理完都等 shard 和 L9 计算排空，保证多合约之间的处理顺序与 tick 时间顺序一致。
+func replayGoldenTicks(t *testing.T, tickDir string, resolver *sessionResolver) []goldenBar {
+	t.Helper()
+	var mu sync.Mutex
+	persisted := make(map[string]goldenBar)
+	l9 := newL9AsyncCalculator(nil, nil, nil, true, 1, nil)
+	l9.sessions = resolver
+	sink := newReplaySink(nil, nil, l9, nil, "", nil, mdSpiOptions{
+		enableMultiMinute: true,
+		generation:        klinesettings.Default(),
+		onPersistTask: func(task persistTask) {
+			bar := goldenBar{
+				InstrumentID: task.Bar.InstrumentID,
+				Period:       task.Bar.Period,
+				DataTime:     task.Bar.MinuteTime,
+				AdjustedTime: task.Bar.AdjustedTime,
+				Open:         task.Bar.Open,
+				High:         task.Bar.High,
+				Low:          task.Bar.Low,
+				Close:        task.Bar.Close,
+				Volume:       task.Bar.Volume,
+				OpenInterest: task.Bar.OpenInterest,
+			}
+			mu.Lock()
+			persisted[bar.key()] = bar
+			mu.Unlock()
+		},
+	})
+	rt := sink.spi.runtime
+	rt.sessionResolver = resolver
+	t.Cleanup(func() {
+		rt.closed.Store(true)
+		for _, shard := range rt.shards {
+			done := make(chan struct{})
+			shard.in <- stopRequest{done: done}
+			<-done
+		}
+	})
+	svc := replay.NewService(nil, nil, false)
+	svc.RegisterConsumer("golden_bars", func(ctx context.Context, ev bus.BusEvent) error {
+		if err := sink.ConsumeBusEvent(ctx, ev); err != nil {
+			return err
+		}
+		return drainGoldenRuntime(rt)
+	})
+	finished := make(chan replay.TaskSnapshot, 1)
+	svc.RegisterTaskLifecycle("golden_bars", goldenReplayHook{sink: sink, done: finished})
+	if _, err := svc.Start(replay.StartRequest{Mode: "realtime", Speed: 1e9, TickDir: tickDir}); err != nil {
+		t.Fatalf("start replay: %v", err)
+	}
+	var snap replay.TaskSnapshot
+	select {
+	case snap = <-finished:
+	case <-time.After(time.Minute):
+		t.Fatalf("replay of %s did not finish", tickDir)
+	}
+	if snap.Status != replay.StatusDone || snap.Errors != 0 || snap.ProcessedTicks == 0 {
+		t.Fatalf("replay finished with status=%s errors=%d processed=%d last_error=%q", snap.Status, snap.Errors, snap.ProcessedTicks, snap.LastError)
+	}
+	// 回放结束后 L9 计算器关闭时才会封口 L9 的未完成高周期 bar。
+	l9.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]goldenBar, 0, len(persisted))
+	for _, bar := range persisted {
+		out = append(out, bar)
+	}
+	sortGoldenBars(out)
+	return out
+}
+
+// rebuildGoldenBars 把 runtime 生成的 1m 按合约（含 L9）交给 mmkline 重建链路，生成 5m/15m/30m/1h/1d。
+func rebuildGoldenBars(t *testing.T, runtimeBars []goldenBar, resolver *sessionResolver) []goldenBar {
+	t.Helper()
+	byInstrument := make(map[string][]klineagg.MinuteBar)
+	for _, bar := range runtimeBars {
+		if bar.Period != "1m" {
+			continue
+		}
+		byInstrument[bar.InstrumentID] = append(byInstrument[bar.InstrumentID], klineagg.MinuteBar{
+			InstrumentID: bar.InstrumentID,
+			DataTime:     bar.DataTime,
+			AdjustedTime: bar.AdjustedTime,
+			Open:         bar.Open,
+			High:         bar.High,
+			Low:          bar.Low,
+			Close:        bar.Close,
+			Volume:       bar.Volume,
+			OpenInterest: bar.OpenInterest,
+		})
+	}
+	var out []goldenBar
+	for instrumentID, bars := range byInstrument {
+		sort.Slice(bars, func(i, j int) bool { return bars[i].AdjustedTime.Before(bars[j].AdjustedTime) })
+		sessions, err := resolver.Sessions(normalizeVariety(strings.TrimSuffix(instrumentID, "l9")))
+		if err != nil {
+			t.Fatalf("sessions of %s: %v", instrumentID, err)
+		}
+		for _, period := range klinesettings.SupportedTimeframes {
+			if period == "1m" {
+				continue
+			}
+			aggBars, _, err := mmkline.AggregatePeriod(bars, toKlineAggSessions(sessions), period)
+			if err != nil {
+				t.Fatalf("rebuild %s %s: %v", instrumentID, period, err)
+			}
+			for _, bar := range aggBars {
+				out = append(out, goldenBar{
+					InstrumentID: bar.InstrumentID,
+					Period:       bar.Period,
+					DataTime:     bar.DataTime,
+					AdjustedTime: bar.AdjustedTime,
+					Open:         bar.Open,
+					High:         bar.High,
+					Low:          bar.Low,
+					Close:        bar.Close,
+					Volume:       bar.Volume,
+					OpenInterest: bar.OpenInterest,
+				})
+			}
+		}
+	}
+	sortGoldenBars(out)
+	return out
+}
+
+func sortGoldenBars(bars []goldenBar) {
+	periodRank := make(map[string]int, len(klinesettings.SupportedTimeframes))
+	for i, period := range klinesettings.SupportedTimeframes {
+		periodRank[period] = i
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		a, b := bars[i], bars[j]
+		if a.InstrumentID != b.InstrumentID {
+			return a.InstrumentID < b.InstrumentID
+		}
+		if a.Period != b.Period {
+			return periodRank[a.Period] < periodRank[b.Period]
+		}
+		if !a.AdjustedTime.Equal(b.AdjustedTime) {
+			return a.AdjustedTime.Before(b.AdjustedTime)
+		}
+		return a.DataTime.Before(b.DataTime)
+	})
+}
+
+// checkGoldenBars 按 合约|周期|data_time 对齐 golden 和实际输出，逐字段按容差比对。
+// -update-golden 时改为把实际输出写回 path。
+func checkGoldenBars(t *testing.T, path string, got []goldenBar, tol goldenTolerance) {
+	t.Helper()
+	if *updateGolden {
+		if err := writeGoldenBars(path, got); err != nil {
+			t.Fatalf("write golden %s: %v", path, err)
+		}
+		t.Logf("updated %s (%d bars)", path, len(got))
+		return
+	}
+	want, err := readGoldenBars(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v (run with -update-golden to create it)", path, err)
+	}
+	gotByKey := make(map[string]goldenBar, len(got))
+	for _, bar := range got {
+		gotByKey[bar.key()] = bar
+	}
+	var diffs []string
+	wantKeys := make(map[string]struct{}, len(want))
+	for _, w := range want {
+		wantKeys[w.key()] = struct{}{}
+		g, ok := gotByKey[w.key()]
+		if !ok {
+			diffs = append(diffs, "missing "+w.key())
+			continue
+		}
+		if d := diffGoldenBar(w, g, tol); d != "" {
+			diffs = append(diffs, w.key()+": "+d)
+		}
+	}
+	for _, g := range got {
+		if _, ok := wantKeys[g.key()]; !ok {
+			diffs = append(diffs, "unexpected "+g.key())
+		}
+	}
+	if len(diffs) == 0 {
+		return
+	}
+	const maxReported = 30
+	for i, d := range diffs {
+		if i == maxReported {
+			t.Errorf("... and %d more differences", len(diffs)-maxReported)
+			break
+		}
+		t.Errorf("%s: %s", filepath.Base(path), d)
+	}
+}
+
+func diffGoldenBar(want goldenBar, got goldenBar, tol goldenTolerance) string {
+	if !want.AdjustedTime.Equal(got.AdjustedTime) {
+		return fmt.Sprintf("adjusted_time=%s want %s", got.AdjustedTime.Format(goldenTimeLayout), want.AdjustedTime.Format(goldenTimeLayout))
+	}
+	prices := []struct {
+		name      string
+		want, got float64
+	}{
+		{"open", want.Open, got.Open},
+		{"high", want.High, got.High},
+		{"low", want.Low, got.Low},
+		{"close", want.Close, got.Close},
+	}
+	for _, p := range prices {
+		if math.Abs(p.want-p.got) > tol.Price {
+			return fmt.Sprintf("%s=%v want %v", p.name, p.got, p.want)
+		}
+	}
+	if delta := want.Volume - got.Volume; delta > tol.Volume || -delta > tol.Volume {
+		return fmt.Sprintf("volume=%d want %d", got.Volume, want.Volume)
+	}
+	if math.Abs(want.OpenInterest-got.OpenInterest) > tol.OpenInterest {
+		return fmt.Sprintf("open_interest=%v want %v", got.OpenInterest, want.OpenInterest)
+	}
+	return ""
+}
+
+func writeGoldenBars(path string, bars []goldenBar) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write(goldenHeader)
+	for _, bar := range bars {
+		_ = w.Write([]string{
+			bar.InstrumentID,
+			bar.Period,
+			bar.DataTime.Format(goldenTimeLayout),
+			bar.AdjustedTime.Format(goldenTimeLayout),
+			formatGoldenFloat(bar.Open),
+			formatGoldenFloat(bar.High),
+			formatGoldenFloat(bar.Low),
+			formatGoldenFloat(bar.Close),
+			strconv.FormatInt(bar.Volume, 10),
+			formatGoldenFloat(bar.OpenInterest),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readGoldenBars(path string) ([]goldenBar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty golden file")
+	}
+	out := make([]goldenBar, 0, len(records)-1)
+	for i, rec := range records[1:] {
+		if len(rec) != len(goldenHeader) {
+			return nil, fmt.Errorf("line %d: %d fields, want %d", i+2, len(rec), len(goldenHeader))
+		}
+		bar := goldenBar{InstrumentID: rec[0], Period: rec[1]}
+		var errs [8]error
+		bar.DataTime, errs[0] = time.ParseInLocation(goldenTimeLayout, rec[2], time.Local)
+		bar.AdjustedTime, errs[1] = time.ParseInLocation(goldenTimeLayout, rec[3], time.Local)
+		bar.Open, errs[2] = strconv.ParseFloat(rec[4], 64)
+		bar.High, errs[3] = strconv.ParseFloat(rec[5], 64)
+		bar.Low, errs[4] = strconv.ParseFloat(rec[6], 64)
+		bar.Close, errs[5] = strconv.ParseFloat(rec[7], 64)
+		bar.Volume, errs[6] = strconv.ParseInt(rec[8], 10, 64)
+		bar.OpenInterest, errs[7] = strconv.ParseFloat(rec[9], 64)
+		for _, err := range errs {
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+2, err)
+			}
+		}
+		out = append(out, bar)
+	}
+	return out, nil
+}
+
+// formatGoldenFloat 保留 6 位小数，避免 L9 加权价的浮点尾数让 golden 文件无谓抖动。
+func formatGoldenFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', -1, 64)
+}
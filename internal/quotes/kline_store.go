@@ -49,6 +49,7 @@ type minuteBar struct {
 	AdjustedTime         time.Time
 	SourceReceivedAt     time.Time
 	FlushStartedAt       time.Time
+	ClosedAt             time.Time
 	SideEffectEnqueuedAt time.Time
 	Period               string
 	Open                 float64
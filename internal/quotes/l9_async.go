@@ -38,6 +38,12 @@ type l9AsyncCalculator struct {
 	trackers          map[string]*timeframeTracker
 	restoredDays      map[string]string
 	persistSink       func([]persistTask)
+	// sessions 非空时替代按库查询的交易时段解析器。
+	sessions *sessionResolver
+	// inflight 是已投递到任务通道或已从 spool 取出、但尚未计算完成的任务数，由 idleMu 保护，归零时通过 idle 唤醒 waitIdle。
+	idleMu   sync.Mutex
+	idle     *sync.Cond
+	inflight int
 }
 
 func newL9AsyncCalculator(store *klineStore, metaDB *sql.DB, status *RuntimeStatusCenter, enabled bool, workers int, expectedByVariety map[string][]string) *l9AsyncCalculator {
@@ -62,6 +68,7 @@ func newL9AsyncCalculator(store *klineStore, metaDB *sql.DB, status *RuntimeStat
 		trackers:          make(map[string]*timeframeTracker),
 		restoredDays:      make(map[string]string),
 	}
+	c.idle = sync.NewCond(&c.idleMu)
 	c.enabled.Store(enabled)
 	if registry != nil {
 		c.queueHandle = registry.Register(queuewatch.QueueSpec{
@@ -166,9 +173,11 @@ func (c *l9AsyncCalculator) Submit(variety string, minuteTime time.Time) {
 		return
 	}
 	// 这里只负责投递异步任务；真正的加权和落库在 worker 线程中完成。
+	c.beginTask()
 	select {
 	case c.tasks <- task:
 	default:
+		c.endTask()
 		logger.Warn("l9 task queue full, dropping task", "variety", task.variety, "minute", task.minuteTime.Format("2006-01-02 15:04:00"))
 	}
 }
@@ -184,6 +193,7 @@ func (c *l9AsyncCalculator) Close() {
 			if !ok {
 				break
 			}
+			c.beginTask()
 			c.tasks <- task
 			depth := len(c.tasks) + c.spool.Pending()
 			if c.queueHandle != nil {
@@ -197,6 +207,42 @@ func (c *l9AsyncCalculator) Close() {
 	c.flushTrackers()
 }
 
+func (c *l9AsyncCalculator) beginTask() {
+	c.idleMu.Lock()
+	c.inflight++
+	c.idleMu.Unlock()
+}
+
+func (c *l9AsyncCalculator) endTask() {
+	c.idleMu.Lock()
+	c.inflight--
+	if c.inflight == 0 {
+		c.idle.Broadcast()
+	}
+	c.idleMu.Unlock()
+}
+
+// waitIdle 阻塞到已投递的任务全部计算完成，最多等 timeout，返回是否等到空闲。
+// spool 中积压的任务不计入：它们可能因坏文件永远取不出，worker 退出后也不会再有人唤醒。
+func (c *l9AsyncCalculator) waitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		c.idleMu.Lock()
+		c.idle.Broadcast()
+		c.idleMu.Unlock()
+	})
+	defer timer.Stop()
+	c.idleMu.Lock()
+	defer c.idleMu.Unlock()
+	for c.inflight > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		c.idle.Wait()
+	}
+	return true
+}
+
 func (c *l9AsyncCalculator) worker() {
 	defer c.wg.Done()
 	for {
@@ -215,8 +261,10 @@ func (c *l9AsyncCalculator) worker() {
 			if err := c.computeAndStore(task.variety, task.minuteTime); err != nil {
 				logger.Error("compute l9 failed", "variety", task.variety, "minute", task.minuteTime.Format("2006-01-02 15:04:00"), "error", err)
 			}
+			c.endTask()
 		default:
 			if c.spool != nil {
+				c.beginTask()
 				if task, ok, _, err := c.spool.Dequeue(); err == nil && ok {
 					depth := len(c.tasks) + c.spool.Pending()
 					if c.queueHandle != nil {
@@ -226,10 +274,12 @@ func (c *l9AsyncCalculator) worker() {
 					if err := c.computeAndStore(task.variety, task.minuteTime); err != nil {
 						logger.Error("compute l9 failed", "variety", task.variety, "minute", task.minuteTime.Format("2006-01-02 15:04:00"), "error", err)
 					}
+					c.endTask()
 					continue
 				} else if err != nil {
 					logger.Error("dequeue l9 spool failed", "error", err)
 				}
+				c.endTask()
 			}
 			time.Sleep(5 * time.Millisecond)
 		}
@@ -376,7 +426,7 @@ func (c *l9AsyncCalculator) restoreTrackerForTradingDay(variety string, tracker
 		return nil
 	}
 	tradingDay := tradingDayKey(current)
-	if tradingDay == "" || tradingDay == restoredDay {
+	if tradingDay == "" || tradingDay == restoredDay || c.store == nil {
 		return nil
 	}
 	bars, err := c.store.QueryMinuteBarsForTradingDay(variety, current.InstrumentID, true, tradingDay)
@@ -421,7 +471,7 @@ func (c *l9AsyncCalculator) flushTrackers() {
 		sortTimeframeBars(bars)
 		if sink == nil {
 			tableName, err := l9MMTableName(variety)
-			if err != nil {
+			if err != nil || c.store == nil {
 				continue
 			}
 			for _, bar := range bars {
@@ -527,6 +577,9 @@ func (c *l9AsyncCalculator) adjustedMinuteTime(minuteTime time.Time) time.Time {
 }
 
 func (c *l9AsyncCalculator) loadSessions(variety string) ([]sessiontime.Range, error) {
+	if c.sessions != nil {
+		return c.sessions.Sessions(variety)
+	}
 	resolver := newSessionResolver(preferMetaDB(c.metaDB, c.store.DB()))
 	return resolver.Sessions(variety)
 }
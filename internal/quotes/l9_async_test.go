@@ -1,6 +1,7 @@
 package quotes
 
 import (
+	"sync"
 	"testing"
 	"time"
 )
@@ -44,3 +45,30 @@ func TestSnapshotBarsForMinuteDoesNotBackfillMissingContracts(t *testing.T) {
 		t.Fatalf("snapshotBarsForMinute() instrument=%s want ag2605", bars[0].InstrumentID)
 	}
 }
+
+func TestL9WaitIdleIsBoundedAndIgnoresSpoolBacklog(t *testing.T) {
+	t.Parallel()
+
+	c := &l9AsyncCalculator{}
+	c.idle = sync.NewCond(&c.idleMu)
+	if !c.waitIdle(time.Second) {
+		t.Fatal("waitIdle() with no inflight tasks should return true")
+	}
+
+	c.beginTask()
+	start := time.Now()
+	if c.waitIdle(50 * time.Millisecond) {
+		t.Fatal("waitIdle() with a stuck task should time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waitIdle() timeout took %v", elapsed)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		c.endTask()
+	}()
+	if !c.waitIdle(5 * time.Second) {
+		t.Fatal("waitIdle() should return once the task finishes")
+	}
+}
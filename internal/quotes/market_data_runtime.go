@@ -41,6 +41,9 @@ const (
 	invalidTickSessionGapMinutes  = 3
 )
 
+// l9FlushWaitTimeout 是 flush 等每个 shard 触发的 L9 计算完成的上限，超时只告警，不让坏任务卡住收盘和退出。
+const l9FlushWaitTimeout = 5 * time.Second
+
 type runtimeOptions struct {
 	// tickDedupWindow 是重复 tick 的判定窗口。
 	tickDedupWindow time.Duration
@@ -122,6 +125,11 @@ type flushRequest struct {
 	done chan error
 }
 
+// drainRequest 是 shard 队列里的屏障，shard 处理到它时说明之前入队的 tick 都已处理完；它不封口当前分钟。
+type drainRequest struct {
+	done chan struct{}
+}
+
 type stopRequest struct {
 	// done 用于通知 shard 已完成停止流程。
 	done chan struct{}
@@ -426,7 +434,10 @@ func newMarketDataRuntime(store *klineStore, metaDB *sql.DB, l9Async *l9AsyncCal
 	}
 	shardCount := defaultMarketDataShardCount
 	rt.shardQueueDepthGauge = make([]int64, shardCount)
-	rt.dbWriter = newDBBatchWriter(store, status, opts.dbWriterCount, queueCfg.PersistCapacity, opts.dbFlushBatch, opts.dbFlushInterval, opts.mmDeferredInterval, opts.mmDeferredBatch)
+	if store != nil {
+		// 没有 store 时只跑聚合链路，结果经 onPersistTask 旁路给调用方，不落库。
+		rt.dbWriter = newDBBatchWriter(store, status, opts.dbWriterCount, queueCfg.PersistCapacity, opts.dbFlushBatch, opts.dbFlushInterval, opts.mmDeferredInterval, opts.mmDeferredBatch)
+	}
 	if l9Async != nil {
 		l9Async.SetPersistSink(rt.enqueuePersistTasks)
 	}
@@ -656,6 +667,11 @@ func (r *marketDataRuntime) flush() error {
 			firstErr = err
 		}
 		atomic.StoreInt64(&r.shardQueueDepthGauge[shard.id], int64(len(shard.in)))
+		// 封口会为各品种投递 L9 计算。逐个 shard 等它们算完再封口下一个 shard，
+		// L9 看到的合约集合就只取决于 shard 顺序而不是 worker 调度，flush 返回时 L9 的 bar 也已进入落库队列。
+		if r.l9Async != nil && !r.l9Async.waitIdle(l9FlushWaitTimeout) {
+			logger.Warn("l9 tasks still running after flush wait", "shard_id", shard.id, "timeout", l9FlushWaitTimeout.String())
+		}
 	}
 	if err := r.dbWriter.Flush(); err != nil && firstErr == nil {
 		firstErr = err
@@ -811,6 +827,8 @@ func (s *marketDataShard) handleMessage(msg any) bool {
 		s.processTick(m)
 	case flushRequest:
 		m.done <- s.flush()
+	case drainRequest:
+		close(m.done)
 	case stopRequest:
 		close(m.done)
 		return true
@@ -1048,6 +1066,7 @@ func (s *marketDataShard) processTick(t runtimeTick) {
 		if s.runtime.opts.onBar != nil {
 			for _, task := range persisted {
 				if task.Bar.Period == "1m" && !task.IsL9 {
+					task.Bar.ClosedAt = task.Trace.MinuteClosedAt
 					task.Bar.SideEffectEnqueuedAt = time.Now()
 					s.runtime.opts.onBar(task.Bar)
 				}
@@ -1172,7 +1191,7 @@ func (s *marketDataShard) restoreTimeframeState(state *instrumentRuntimeState, i
 	if s == nil || state == nil || state.tracker == nil {
 		return nil
 	}
-	if strings.TrimSpace(tradingDay) == "" || state.restoredTradingDay == tradingDay {
+	if strings.TrimSpace(tradingDay) == "" || state.restoredTradingDay == tradingDay || s.runtime.store == nil {
 		return nil
 	}
 	bars, err := s.runtime.store.QueryMinuteBarsForTradingDay(variety, instrumentID, false, tradingDay)
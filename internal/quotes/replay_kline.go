@@ -0,0 +1,110 @@
+// replay_kline.go 负责 K 线回放的数据读取和分发。
+// web 的各回放会话和无界面 replay_runner 都通过这里读取实时库 K 线、发布到会话行情/策略并喂给回放模拟账户，
+// 两边的分块大小、字段归一和撮合口径因此保持一致。
+package quotes
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/strategy"
+)
+
+// klineReplayChunkLimit 是每次从实时库读取的 K 线回放根数上限。
+const klineReplayChunkLimit = 300
+
+// KlineBarsSource 是 K 线回放的数据来源，klinequery.Service 满足该接口。
+type KlineBarsSource interface {
+	BarsFrom(symbol string, kind string, variety string, timeframe string, afterAdjusted time.Time, limit int) (klinequery.BarsResponse, error)
+}
+
+// ReplayKlineConsumer 是回放 K 线的撮合端，回放模拟账户 trade.Service 实现它。
+type ReplayKlineConsumer interface {
+	ConsumeReplayKlineBar(bar ReplayKlineBar) error
+}
+
+// LoadKlineReplayChunk 从 source 读取 afterAdjusted 之后的一块 K 线，limit 超出 (0, 300] 时取 300；没有数据时返回 Exhausted。
+func LoadKlineReplayChunk(source KlineBarsSource, req replay.KlineStartRequest, afterAdjusted time.Time, limit int) (replay.KlineChunk, error) {
+	if limit <= 0 || limit > klineReplayChunkLimit {
+		limit = klineReplayChunkLimit
+	}
+	resp, err := source.BarsFrom(req.Symbol, req.Type, req.Variety, req.Timeframe, afterAdjusted, limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return replay.KlineChunk{Exhausted: true}, nil
+		}
+		return replay.KlineChunk{}, err
+	}
+	out := make([]replay.KlineBar, 0, len(resp.Bars))
+	for _, bar := range resp.Bars {
+		out = append(out, replay.KlineBar{
+			Symbol:       strings.ToLower(strings.TrimSpace(resp.Meta.Symbol)),
+			Type:         strings.ToLower(strings.TrimSpace(resp.Meta.Type)),
+			Variety:      strings.ToLower(strings.TrimSpace(resp.Meta.Variety)),
+			Timeframe:    strings.ToLower(strings.TrimSpace(req.Timeframe)),
+			AdjustedTime: time.Unix(bar.AdjustedTime, 0),
+			DataTime:     time.Unix(bar.DataTime, 0),
+			Open:         bar.Open,
+			High:         bar.High,
+			Low:          bar.Low,
+			Close:        bar.Close,
+			Volume:       bar.Volume,
+			OpenInterest: bar.OpenInterest,
+		})
+	}
+	return replay.KlineChunk{Bars: out, Exhausted: len(out) < limit}, nil
+}
+
+// ConsumeKlineReplayBar 把一根回放 K 线发布到 sink（会话图表和策略），sink 为空时直接发到策略回放总线，
+// 然后交给每个回放模拟账户撮合。
+func ConsumeKlineReplayBar(sink *ReplaySink, session string, taskID string, req replay.KlineStartRequest, bar replay.KlineBar, ledgers []ReplayKlineConsumer) error {
+	item := ReplayKlineBar{
+		ReplayTaskID: taskID,
+		Symbol:       firstNonEmpty(strings.TrimSpace(bar.Symbol), req.Symbol),
+		Type:         firstNonEmpty(strings.TrimSpace(bar.Type), req.Type),
+		Variety:      firstNonEmpty(strings.TrimSpace(bar.Variety), req.Variety),
+		Exchange:     bar.Exchange,
+		Timeframe:    firstNonEmpty(strings.TrimSpace(bar.Timeframe), req.Timeframe),
+		AdjustedTime: bar.AdjustedTime,
+		DataTime:     bar.DataTime,
+		Open:         bar.Open,
+		High:         bar.High,
+		Low:          bar.Low,
+		Close:        bar.Close,
+		Volume:       bar.Volume,
+		OpenInterest: bar.OpenInterest,
+	}
+	if sink != nil {
+		sub := ChartSubscription{Symbol: item.Symbol, Type: item.Type, Variety: item.Variety, Timeframe: item.Timeframe, DataMode: "realtime"}
+		if err := sink.PublishKlineReplayBar(sub, item); err != nil {
+			return err
+		}
+	} else {
+		strategy.PublishReplayBar(strategy.BarEvent{
+			ReplayTaskID:  taskID,
+			ReplaySession: session,
+			Variety:       item.Variety,
+			InstrumentID:  item.Symbol,
+			Exchange:      item.Exchange,
+			DataTime:      item.DataTime,
+			AdjustedTime:  item.AdjustedTime,
+			Period:        item.Timeframe,
+			Open:          item.Open,
+			High:          item.High,
+			Low:           item.Low,
+			Close:         item.Close,
+			Volume:        item.Volume,
+			OpenInterest:  item.OpenInterest,
+		})
+	}
+	for _, ledger := range ledgers {
+		if err := ledger.ConsumeReplayKlineBar(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
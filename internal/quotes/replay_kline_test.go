@@ -0,0 +1,78 @@
+package quotes
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/replay"
+)
+
+type fakeKlineBarsSource struct {
+	resp  klinequery.BarsResponse
+	err   error
+	limit int
+}
+
+func (f *fakeKlineBarsSource) BarsFrom(_ string, _ string, _ string, _ string, _ time.Time, limit int) (klinequery.BarsResponse, error) {
+	f.limit = limit
+	return f.resp, f.err
+}
+
+type fakeReplayLedger struct {
+	bars []ReplayKlineBar
+}
+
+func (f *fakeReplayLedger) ConsumeReplayKlineBar(bar ReplayKlineBar) error {
+	f.bars = append(f.bars, bar)
+	return nil
+}
+
+func TestLoadKlineReplayChunkNormalizesAndClampsLimit(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 30, 9, 1, 0, 0, time.Local)
+	source := &fakeKlineBarsSource{resp: klinequery.BarsResponse{
+		Meta: klinequery.BarsMeta{Symbol: " RB2605 ", Type: "Contract", Variety: "RB"},
+		Bars: []klinequery.KlineBar{{AdjustedTime: at.Unix(), DataTime: at.Unix(), Open: 1, High: 2, Low: 1, Close: 2, Volume: 3}},
+	}}
+	req := replay.KlineStartRequest{Symbol: "rb2605", Timeframe: "1M"}
+	chunk, err := LoadKlineReplayChunk(source, req, at, 1000)
+	if err != nil {
+		t.Fatalf("LoadKlineReplayChunk() error = %v", err)
+	}
+	if source.limit != klineReplayChunkLimit || !chunk.Exhausted || len(chunk.Bars) != 1 {
+		t.Fatalf("limit=%d chunk=%+v, want clamped limit and exhausted single bar", source.limit, chunk)
+	}
+	bar := chunk.Bars[0]
+	if bar.Symbol != "rb2605" || bar.Type != "contract" || bar.Variety != "rb" || bar.Timeframe != "1m" || !bar.AdjustedTime.Equal(at) {
+		t.Fatalf("bar = %+v", bar)
+	}
+
+	source.err = sql.ErrNoRows
+	if chunk, err := LoadKlineReplayChunk(source, req, at, 0); err != nil || !chunk.Exhausted {
+		t.Fatalf("no rows chunk = %+v err=%v, want exhausted", chunk, err)
+	}
+}
+
+func TestConsumeKlineReplayBarFeedsEveryLedger(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 30, 9, 1, 0, 0, time.Local)
+	main, sub := &fakeReplayLedger{}, &fakeReplayLedger{}
+	req := replay.KlineStartRequest{Symbol: "rb2605", Type: "contract", Variety: "rb", Timeframe: "1m"}
+	bar := replay.KlineBar{Exchange: "SHFE", AdjustedTime: at, DataTime: at, Open: 1, High: 2, Low: 1, Close: 2}
+	if err := ConsumeKlineReplayBar(nil, "s1", "task-1", req, bar, []ReplayKlineConsumer{main, sub}); err != nil {
+		t.Fatalf("ConsumeKlineReplayBar() error = %v", err)
+	}
+	for name, ledger := range map[string]*fakeReplayLedger{"main": main, "sub": sub} {
+		if len(ledger.bars) != 1 {
+			t.Fatalf("%s ledger got %d bars, want 1", name, len(ledger.bars))
+		}
+		got := ledger.bars[0]
+		if got.Symbol != "rb2605" || got.Exchange != "SHFE" || got.Timeframe != "1m" || got.ReplayTaskID != "task-1" || got.Close != 2 {
+			t.Fatalf("%s ledger bar = %+v", name, got)
+		}
+	}
+}
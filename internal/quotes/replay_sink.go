@@ -33,6 +33,10 @@ type ReplaySink struct {
 	seenFirstConsume map[string]struct{}
 	clearedKlines    map[string]struct{}
 	currentTaskID    string
+	// session 是所属回放会话标识，默认会话为空；它会带到策略事件上，让策略实例只接收本会话的行情。
+	session string
+	// chart 是会话自有的图表命名空间，为空时发布到全局默认图表流。
+	chart *ChartStream
 }
 
 type ReplayKlineBar struct {
@@ -54,6 +58,12 @@ type ReplayKlineBar struct {
 
 // NewReplaySink 为回放模式创建独立的 store、L9 计算器和 mdSpi。
 func NewReplaySink(cfg config.CTPConfig, status *RuntimeStatusCenter) (*ReplaySink, error) {
+	return NewReplaySessionSink(cfg, status, "", nil)
+}
+
+// NewReplaySessionSink 为指定回放会话创建 sink：cfg.DBDSN 应指向会话自有的回放行情库，
+// 图表更新发布到 chart（为空时用默认图表流），策略事件带上会话标识。
+func NewReplaySessionSink(cfg config.CTPConfig, status *RuntimeStatusCenter, session string, chart *ChartStream) (*ReplaySink, error) {
 	dbPath, err := resolveStoreDSN(cfg)
 	if err != nil {
 		return nil, err
@@ -72,65 +82,78 @@ func NewReplaySink(cfg config.CTPConfig, status *RuntimeStatusCenter) (*ReplaySi
 	if cfg.IsL9AsyncEnabled() && generation.AnyEnabled("l9") {
 		l9Calc = newL9AsyncCalculator(store, metaDB, status, true, 1, nil)
 	}
-	sink := &ReplaySink{
-		store:            store,
-		metaDB:           metaDB,
-		seenFirstConsume: make(map[string]struct{}),
-		clearedKlines:    make(map[string]struct{}),
-	}
-	spi := newMdSpiWithStatusAndOptions(store, metaDB, l9Calc, status, mdSpiOptions{
+	return newReplaySink(store, metaDB, l9Calc, status, session, chart, mdSpiOptions{
 		tickDedupWindow:   time.Duration(cfg.TickDedupWindowSeconds) * time.Second,
 		driftThreshold:    time.Duration(cfg.DriftThresholdSeconds) * time.Second,
 		driftResumeTicks:  cfg.DriftResumeTicks,
 		enableMultiMinute: generation.AnyHigherEnabled("contract"),
 		flowPath:          cfg.FlowPath,
 		generation:        generation,
-		onTick: func(t tickEvent) {
-			PublishReplayChartTick(t)
-			strategy.PublishReplayTick(strategy.TickEvent{
-				ReplayTaskID:    sink.currentReplayTaskID(),
-				InstrumentID:    t.InstrumentID,
-				ExchangeID:      t.ExchangeID,
-				ActionDay:       t.ActionDay,
-				TradingDay:      t.TradingDay,
-				UpdateTime:      t.UpdateTime,
-				UpdateMillisec:  t.UpdateMillisec,
-				ReceivedAt:      t.ReceivedAt,
-				LastPrice:       t.LastPrice,
-				Volume:          t.Volume,
-				OpenInterest:    t.OpenInterest,
-				SettlementPrice: t.SettlementPrice,
-				BidPrice1:       t.BidPrice1,
-				AskPrice1:       t.AskPrice1,
-			})
-		},
-		onBar: func(bar minuteBar) {
-			strategy.PublishReplayBar(strategy.BarEvent{
-				ReplayTaskID:    sink.currentReplayTaskID(),
-				Variety:         bar.Variety,
-				InstrumentID:    bar.InstrumentID,
-				Exchange:        bar.Exchange,
-				DataTime:        bar.MinuteTime,
-				AdjustedTime:    bar.AdjustedTime,
-				Period:          bar.Period,
-				Open:            bar.Open,
-				High:            bar.High,
-				Low:             bar.Low,
-				Close:           bar.Close,
-				Volume:          bar.Volume,
-				OpenInterest:    bar.OpenInterest,
-				SettlementPrice: bar.SettlementPrice,
-			})
-		},
-		onPartialBar: func(bar minuteBar) {
-			PublishChartPartialBar(bar, true)
-		},
-		onPersistTask: func(task persistTask) {
-			PublishChartFinalBar(task.Bar, task.Replay)
-		},
-	})
-	sink.spi = spi
-	return sink, nil
+	}), nil
+}
+
+// newReplaySink 在已打开的 store 和 L9 计算器上装配 sink 和 mdSpi，把 tick、bar 和封口结果接到图表与策略事件上。
+// opts 里调用方已设置的 onPersistTask 在 sink 发布图表最终 bar 之后调用。
+func newReplaySink(store *klineStore, metaDB *sql.DB, l9Calc *l9AsyncCalculator, status *RuntimeStatusCenter, session string, chart *ChartStream, opts mdSpiOptions) *ReplaySink {
+	sink := &ReplaySink{
+		store:            store,
+		metaDB:           metaDB,
+		seenFirstConsume: make(map[string]struct{}),
+		clearedKlines:    make(map[string]struct{}),
+		session:          session,
+		chart:            chart,
+	}
+	onPersistTask := opts.onPersistTask
+	opts.onTick = func(t tickEvent) {
+		sink.publishChartTick(t)
+		strategy.PublishReplayTick(strategy.TickEvent{
+			ReplayTaskID:    sink.currentReplayTaskID(),
+			ReplaySession:   sink.session,
+			InstrumentID:    t.InstrumentID,
+			ExchangeID:      t.ExchangeID,
+			ActionDay:       t.ActionDay,
+			TradingDay:      t.TradingDay,
+			UpdateTime:      t.UpdateTime,
+			UpdateMillisec:  t.UpdateMillisec,
+			ReceivedAt:      t.ReceivedAt,
+			LastPrice:       t.LastPrice,
+			Volume:          t.Volume,
+			OpenInterest:    t.OpenInterest,
+			SettlementPrice: t.SettlementPrice,
+			BidPrice1:       t.BidPrice1,
+			AskPrice1:       t.AskPrice1,
+		})
+	}
+	opts.onBar = func(bar minuteBar) {
+		strategy.PublishReplayBar(strategy.BarEvent{
+			ReplayTaskID:    sink.currentReplayTaskID(),
+			ReplaySession:   sink.session,
+			Variety:         bar.Variety,
+			InstrumentID:    bar.InstrumentID,
+			Exchange:        bar.Exchange,
+			DataTime:        bar.MinuteTime,
+			AdjustedTime:    bar.AdjustedTime,
+			Period:          bar.Period,
+			Open:            bar.Open,
+			High:            bar.High,
+			Low:             bar.Low,
+			Close:           bar.Close,
+			Volume:          bar.Volume,
+			OpenInterest:    bar.OpenInterest,
+			SettlementPrice: bar.SettlementPrice,
+		})
+	}
+	opts.onPartialBar = func(bar minuteBar) {
+		sink.publishChartPartialBar(bar, true)
+	}
+	opts.onPersistTask = func(task persistTask) {
+		sink.publishChartFinalBar(task.Bar, task.Replay)
+		if onPersistTask != nil {
+			onPersistTask(task)
+		}
+	}
+	sink.spi = newMdSpiWithStatusAndOptions(store, metaDB, l9Calc, status, opts)
+	return sink
 }
 
 func (s *ReplaySink) PrepareReplayWindow(req replay.StartRequest) error {
@@ -174,21 +197,22 @@ func (s *ReplaySink) PublishKlineReplayBar(sub ChartSubscription, item ReplayKli
 	if sub.Type == "l9" && !strings.HasSuffix(strings.ToLower(bar.InstrumentID), "l9") {
 		bar.InstrumentID = sub.Variety + "l9"
 	}
-	PublishChartFinalBar(bar, false)
+	s.publishChartFinalBar(bar, false)
 	strategy.PublishReplayBar(strategy.BarEvent{
-		ReplayTaskID: item.ReplayTaskID,
-		Variety:      bar.Variety,
-		InstrumentID: bar.InstrumentID,
-		Exchange:     bar.Exchange,
-		DataTime:     bar.MinuteTime,
-		AdjustedTime: bar.AdjustedTime,
-		Period:       bar.Period,
-		Open:         bar.Open,
-		High:         bar.High,
-		Low:          bar.Low,
-		Close:        bar.Close,
-		Volume:       bar.Volume,
-		OpenInterest: bar.OpenInterest,
+		ReplayTaskID:  item.ReplayTaskID,
+		ReplaySession: s.session,
+		Variety:       bar.Variety,
+		InstrumentID:  bar.InstrumentID,
+		Exchange:      bar.Exchange,
+		DataTime:      bar.MinuteTime,
+		AdjustedTime:  bar.AdjustedTime,
+		Period:        bar.Period,
+		Open:          bar.Open,
+		High:          bar.High,
+		Low:           bar.Low,
+		Close:         bar.Close,
+		Volume:        bar.Volume,
+		OpenInterest:  bar.OpenInterest,
 	})
 	// logger.Debug(
 	// 	"kline replay bar published without replay db write",
@@ -270,6 +294,38 @@ func (s *ReplaySink) OnTaskFinished(_ context.Context, snap replay.TaskSnapshot)
 	return err
 }
 
+// Session 返回 sink 所属的回放会话标识，默认会话为空。
+func (s *ReplaySink) Session() string {
+	if s == nil {
+		return ""
+	}
+	return s.session
+}
+
+func (s *ReplaySink) publishChartTick(ev tickEvent) {
+	if s.chart != nil {
+		s.chart.HandleTick(ev, true)
+		return
+	}
+	PublishReplayChartTick(ev)
+}
+
+func (s *ReplaySink) publishChartPartialBar(bar minuteBar, replay bool) {
+	if s.chart != nil {
+		s.chart.HandlePartialBar(bar, replay)
+		return
+	}
+	PublishChartPartialBar(bar, replay)
+}
+
+func (s *ReplaySink) publishChartFinalBar(bar minuteBar, replay bool) {
+	if s.chart != nil {
+		s.chart.HandleFinalBar(bar, replay)
+		return
+	}
+	PublishChartFinalBar(bar, replay)
+}
+
 func (s *ReplaySink) currentReplayTaskID() string {
 	if s == nil {
 		return ""
@@ -387,7 +443,12 @@ func (s *ReplaySink) prepareInstrumentKlinesLocked(taskID string, tick tickEvent
 	if variety == "" {
 		return nil
 	}
-	subs := DefaultReplaySubscriptionsForSymbol(instrumentID, "contract", variety)
+	var subs []ChartSubscription
+	if s.chart != nil {
+		subs = s.chart.ReplaySubscriptionsForSymbol(instrumentID, "contract", variety)
+	} else {
+		subs = DefaultReplaySubscriptionsForSymbol(instrumentID, "contract", variety)
+	}
 	if len(subs) == 0 {
 		return nil
 	}
@@ -515,6 +515,7 @@ func (s *Service) initMarketData(queriedInstruments []instrumentInfo, status *Ru
 				Volume:          bar.Volume,
 				OpenInterest:    bar.OpenInterest,
 				SettlementPrice: bar.SettlementPrice,
+				ClosedAt:        bar.ClosedAt,
 			})
 			if busLog == nil {
 				return
@@ -27,6 +27,18 @@ func newSessionResolver(db *sql.DB) *sessionResolver {
 	}
 }
 
+// newStaticSessionResolver 返回只使用给定交易时段、不查库的解析器，未配置的品种按默认时段处理。
+// 它用于离线回归等没有 shared_meta 库的场景。
+func newStaticSessionResolver(sessions map[string][]sessiontime.Range) *sessionResolver {
+	r := &sessionResolver{cache: make(map[string][]sessiontime.Range, len(sessions))}
+	for variety, ranges := range sessions {
+		if v := normalizeVariety(variety); v != "" {
+			r.cache[v] = append([]sessiontime.Range(nil), ranges...)
+		}
+	}
+	return r
+}
+
 func preferMetaDB(metaDB *sql.DB, fallback *sql.DB) *sql.DB {
 	if metaDB != nil {
 		return metaDB
@@ -39,7 +51,7 @@ func (r *sessionResolver) Sessions(variety string) ([]sessiontime.Range, error)
 	if variety == "" {
 		return nil, nil
 	}
-	if r == nil || r.db == nil {
+	if r == nil {
 		logger.Debug("trading sessions resolved", "variety", variety, "database", "<nil>", "source", "default", "reason", "no_db", "session_text", sessiontime.DefaultSessionText)
 		return sessiontime.DefaultRanges(), nil
 	}
@@ -49,6 +61,10 @@ func (r *sessionResolver) Sessions(variety string) ([]sessiontime.Range, error)
 		return append([]sessiontime.Range(nil), cached...), nil
 	}
 	r.mu.Unlock()
+	if r.db == nil {
+		logger.Debug("trading sessions resolved", "variety", variety, "database", "<nil>", "source", "default", "reason", "no_db", "session_text", sessiontime.DefaultSessionText)
+		return sessiontime.DefaultRanges(), nil
+	}
 
 	var raw string
 	var completed bool
@@ -0,0 +1,161 @@
+// control.go 实现回放任务的单步与跳转控制。
+// 单步只在暂停时生效：每次放行 N 条事件（tick 目录和 bus 回放按事件计，K线回放按 bar 计），放行完仍保持暂停。
+// 跳转（seek）在运行或暂停时都可发起，由回放主循环在下一条事件前处理：先重新执行启动时的准备步骤
+// （重置回放纸面账户、回放 K 线窗口和图表状态），向前跳转时清空去重记录，再按索引定位到目标时间的第一条事件。
+// 跳过的事件不会分发，下游状态从目标时间重新开始累积。
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+// MaxStepEvents 是一次单步请求允许放行的最大事件数。
+const MaxStepEvents = 10000
+
+// errSeekRequested 表示主循环需要先处理挂起的跳转请求。
+var errSeekRequested = errors.New("replay seek requested")
+
+// Step 在暂停状态下放行 n 条事件，n<=0 时按 1 处理；多次调用会累加。
+func (s *Service) Step(n int) (TaskSnapshot, error) {
+	if n <= 0 {
+		n = 1
+	}
+	if n > MaxStepEvents {
+		return s.Status(), fmt.Errorf("replay step count %d exceeds %d", n, MaxStepEvents)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshot.Status != StatusPaused {
+		return s.snapshot, fmt.Errorf("replay task not paused")
+	}
+	s.snapshot.PendingSteps = min(s.snapshot.PendingSteps+n, MaxStepEvents)
+	return s.snapshot, nil
+}
+
+// Seek 请求把当前任务跳转到 target；目标必须落在任务请求的 start_time/end_time 范围内。
+// 跳转不改变运行/暂停状态，并会清除尚未消耗的单步额度。
+func (s *Service) Seek(target time.Time) (TaskSnapshot, error) {
+	if target.IsZero() {
+		return s.Status(), fmt.Errorf("replay seek time is required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.activeID == "" || (s.snapshot.Status != StatusRunning && s.snapshot.Status != StatusPaused) {
+		return s.snapshot, fmt.Errorf("replay task not active")
+	}
+	if start := s.activeReq.StartTime; start != nil && target.Before(*start) {
+		return s.snapshot, fmt.Errorf("replay seek time %s is before task start %s", target.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+	if end := s.activeReq.EndTime; end != nil && target.After(*end) {
+		return s.snapshot, fmt.Errorf("replay seek time %s is after task end %s", target.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	ts := target
+	s.snapshot.SeekTarget = &ts
+	s.snapshot.PendingSteps = 0
+	return s.snapshot, nil
+}
+
+// waitTurn 在每条事件分发前调用：暂停时阻塞，直到恢复、单步放行、收到跳转请求或任务结束。
+// 返回 true 表示本条事件消耗了一次单步额度。
+func (s *Service) waitTurn(ctx context.Context, taskID string) (bool, error) {
+	for {
+		s.mu.Lock()
+		active := s.snapshot.TaskID == taskID
+		status := s.snapshot.Status
+		seeking := s.snapshot.SeekTarget != nil
+		stepped := false
+		if active && status == StatusPaused && !seeking && s.snapshot.PendingSteps > 0 {
+			s.snapshot.PendingSteps--
+			stepped = true
+		}
+		s.mu.Unlock()
+
+		if !active || status == StatusStopped {
+			return false, context.Canceled
+		}
+		if seeking {
+			return false, errSeekRequested
+		}
+		if stepped || status != StatusPaused {
+			return stepped, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// pacingInterrupted 供节奏等待使用：与 waitIfPaused 一样在暂停时阻塞（恢复后由调用方按原逻辑重新计时），
+// 但遇到挂起的跳转或暂停中的单步额度时立即返回 true，交给 waitTurn 处理，不再等完剩余间隔。
+func (s *Service) pacingInterrupted(ctx context.Context, taskID string) (bool, error) {
+	for {
+		s.mu.Lock()
+		active := s.snapshot.TaskID == taskID
+		status := s.snapshot.Status
+		interrupted := s.snapshot.SeekTarget != nil || (status == StatusPaused && s.snapshot.PendingSteps > 0)
+		s.mu.Unlock()
+
+		if !active || status == StatusStopped {
+			return false, context.Canceled
+		}
+		if interrupted {
+			return true, nil
+		}
+		if status != StatusPaused {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// takeSeek 取出挂起的跳转目标并重置下游状态；返回的请求以目标时间为起点，供重新执行准备步骤。
+func (s *Service) takeSeek(ctx context.Context, taskID string) (time.Time, error) {
+	s.mu.Lock()
+	if s.snapshot.TaskID != taskID || s.snapshot.SeekTarget == nil {
+		s.mu.Unlock()
+		return time.Time{}, fmt.Errorf("replay seek target missing")
+	}
+	target := *s.snapshot.SeekTarget
+	s.snapshot.SeekTarget = nil
+	rewind := s.snapshot.CurrentSimTime != nil && target.Before(*s.snapshot.CurrentSimTime)
+	req := s.activeReq
+	prepares := append([]StartPrepareFunc(nil), s.prepares...)
+	s.mu.Unlock()
+
+	startedAt := time.Now()
+	logger.Info("replay seek begin", "task_id", taskID, "target", target, "rewind", rewind)
+	if rewind && s.dedup != nil {
+		if err := s.dedup.ClearAll(); err != nil {
+			return target, err
+		}
+	}
+	req.StartTime = &target
+	for _, prepare := range prepares {
+		if prepare == nil {
+			continue
+		}
+		if err := prepare(ctx, req); err != nil {
+			return target, fmt.Errorf("replay seek reset failed: %w", err)
+		}
+	}
+	s.mu.Lock()
+	if s.snapshot.TaskID == taskID {
+		s.snapshot.CurrentSimTime = &target
+		s.snapshot.LastCursor = nil
+		s.snapshot.Seeks++
+	}
+	s.mu.Unlock()
+	logger.Info("replay seek reset done", "task_id", taskID, "target", target, "elapsed_ms", time.Since(startedAt).Milliseconds())
+	return target, nil
+}
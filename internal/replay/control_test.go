@@ -0,0 +1,139 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/bus"
+)
+
+func waitReplayCondition(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplayStepAndSeekOverTickDir(t *testing.T) {
+	dir := t.TempDir()
+	content := "ReceivedAt,InstrumentID,ExchangeID,TradingDay,ActionDay,UpdateTime,UpdateMillisec,LastPrice,Volume,OpenInterest,SettlementPrice,BidPrice1,AskPrice1\n" +
+		"2026-03-27 21:00:00.000,ag2606,SHFE,20260330,20260327,21:00:00,0,100,1,10,0,99,101\n" +
+		"2026-03-27 21:01:00.000,ag2606,SHFE,20260330,20260327,21:01:00,0,101,2,11,0,100,102\n" +
+		"2026-03-27 21:02:00.000,ag2606,SHFE,20260330,20260327,21:02:00,0,102,3,12,0,101,103\n" +
+		"2026-03-27 21:03:00.000,ag2606,SHFE,20260330,20260327,21:03:00,0,103,4,13,0,102,104\n"
+	if err := os.WriteFile(filepath.Join(dir, "ag2606.csv"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write tick csv failed: %v", err)
+	}
+
+	svc := NewService(nil, nil, false)
+	var mu sync.Mutex
+	var seen []time.Time
+	svc.RegisterConsumer("test", func(_ context.Context, ev bus.BusEvent) error {
+		mu.Lock()
+		seen = append(seen, ev.OccurredAt)
+		mu.Unlock()
+		return nil
+	})
+	dispatched := func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), seen...)
+	}
+
+	var prepared atomic.Int32
+	entered := make(chan struct{}, 4)
+	release := make(chan struct{})
+	var seekStart atomic.Value
+	prepare := func(_ context.Context, req StartRequest) error {
+		if prepared.Add(1) == 1 {
+			entered <- struct{}{}
+			<-release
+		} else if req.StartTime != nil {
+			seekStart.Store(*req.StartTime)
+		}
+		return nil
+	}
+	if _, err := svc.StartWithPrepare(StartRequest{Mode: "realtime", Speed: 1e9, TickDir: dir}, []StartPrepareFunc{prepare}); err != nil {
+		t.Fatalf("StartWithPrepare error: %v", err)
+	}
+	<-entered
+	if _, err := svc.Pause(); err != nil {
+		t.Fatalf("Pause error: %v", err)
+	}
+	close(release)
+
+	if _, err := svc.Step(2); err != nil {
+		t.Fatalf("Step error: %v", err)
+	}
+	waitReplayCondition(t, "two stepped ticks", func() bool { return len(dispatched()) == 2 && svc.Status().PendingSteps == 0 })
+	time.Sleep(50 * time.Millisecond)
+	if got := dispatched(); len(got) != 2 || svc.Status().Status != StatusPaused {
+		t.Fatalf("step should stop after 2 ticks: seen=%v status=%s", got, svc.Status().Status)
+	}
+
+	first := time.Date(2026, 3, 27, 21, 0, 0, 0, time.Local)
+	if _, err := svc.Seek(first.Add(30 * time.Second)); err != nil {
+		t.Fatalf("Seek error: %v", err)
+	}
+	waitReplayCondition(t, "seek handled", func() bool { return svc.Status().Seeks == 1 })
+	if prepared.Load() != 2 {
+		t.Fatalf("seek should rerun prepare steps once, got %d runs", prepared.Load())
+	}
+	if got, _ := seekStart.Load().(time.Time); !got.Equal(first.Add(30 * time.Second)) {
+		t.Fatalf("prepare start_time = %v", got)
+	}
+	if _, err := svc.Step(1); err != nil {
+		t.Fatalf("Step after seek error: %v", err)
+	}
+	waitReplayCondition(t, "tick after rewind", func() bool { return len(dispatched()) == 3 })
+	if got := dispatched()[2]; !got.Equal(first.Add(time.Minute)) {
+		t.Fatalf("first tick after seek = %v, want 21:01", got)
+	}
+
+	if _, err := svc.Resume(); err != nil {
+		t.Fatalf("Resume error: %v", err)
+	}
+	waitReplayCondition(t, "task done", func() bool { return svc.Status().Status == StatusDone })
+	if got := dispatched(); len(got) != 5 || !got[4].Equal(first.Add(3*time.Minute)) {
+		t.Fatalf("dispatched after resume = %v", got)
+	}
+}
+
+func TestReplayStepRequiresPausedTask(t *testing.T) {
+	svc := NewService(nil, nil, false)
+	if _, err := svc.Step(1); err == nil {
+		t.Fatal("step without paused task should fail")
+	}
+	if _, err := svc.Seek(time.Now()); err == nil {
+		t.Fatal("seek without active task should fail")
+	}
+	if got := tickCSVEventIndexAt([]tickCSVEvent{{Time: time.Unix(10, 0)}, {Time: time.Unix(20, 0)}}, time.Unix(15, 0)); got != 1 {
+		t.Fatalf("tickCSVEventIndexAt = %d, want 1", got)
+	}
+}
+
+func TestStartRequestWithDefaults(t *testing.T) {
+	t.Parallel()
+
+	defaults := StartDefaults{Mode: "realtime", Speed: 2, FlowPath: "/data/flow", SharedMetaDSN: " shared "}
+	got := StartRequest{}.WithDefaults(defaults)
+	if got.Mode != "realtime" || got.Speed != 2 || got.TickDir != filepath.Join("/data/flow", "ticks") || got.SharedMetaDSN != "shared" {
+		t.Fatalf("empty request defaults = %+v", got)
+	}
+	got = StartRequest{Mode: "fast", Speed: 5, TradingDayFrom: "20260330", SharedMetaDSN: "client"}.WithDefaults(defaults)
+	if got.Mode != "kline" || got.Speed != 5 || got.TickArchiveDir != "/data/flow" || got.TickDir != "" || got.SharedMetaDSN != "shared" {
+		t.Fatalf("multi-day request defaults = %+v", got)
+	}
+	if got := (StartRequest{}).WithDefaults(StartDefaults{}); got.Mode != "kline" || got.TickDir != "" {
+		t.Fatalf("kline fallback = %+v", got)
+	}
+}
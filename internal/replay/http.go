@@ -1,5 +1,7 @@
 package replay
 
+import "time"
+
 type StartResponse struct {
 	// OK 表示启动请求是否成功。
 	OK bool `json:"ok"`
@@ -23,3 +25,34 @@ type StatusResponse struct {
 	// Task 是当前回放任务快照。
 	Task TaskSnapshot `json:"task"`
 }
+
+type StepRequest struct {
+	// Count 是本次放行的事件数，<=0 时按 1 处理。
+	Count int `json:"count"`
+}
+
+type SeekRequest struct {
+	// Time 是跳转目标模拟时间。
+	Time *time.Time `json:"time,omitempty"`
+	// Bookmark 是跳转目标书签名，Time 为空时使用。
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+type SessionCreateRequest struct {
+	// SessionID 是新会话标识，为空时自动生成。
+	SessionID string `json:"session_id"`
+	// Label 是会话展示名。
+	Label string `json:"label"`
+}
+
+type SessionResponse struct {
+	// OK 表示会话创建或删除是否成功。
+	OK bool `json:"ok"`
+	// Session 是动作执行后的会话快照。
+	Session SessionSnapshot `json:"session"`
+}
+
+type SessionListResponse struct {
+	// Items 是全部回放会话，default 会话在前。
+	Items []SessionSnapshot `json:"items"`
+}
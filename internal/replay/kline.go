@@ -2,6 +2,7 @@ package replay
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -49,7 +50,20 @@ func (s *Service) runKline(ctx context.Context, taskID string, req StartRequest)
 
 	processed := 0
 	for {
-		if err := s.waitIfPaused(ctx, taskID); err != nil {
+		if _, err := s.waitTurn(ctx, taskID); err != nil {
+			if errors.Is(err, errSeekRequested) {
+				// K 线按调整时间从库里分块加载，跳转时丢弃已预取的队列，从目标时间重新加载。
+				target, seekErr := s.takeSeek(ctx, taskID)
+				if seekErr != nil {
+					s.failTask(taskID, seekErr)
+					return
+				}
+				queue = queue[:0]
+				sourceExhausted = false
+				lastLoaded = target.Add(-time.Nanosecond)
+				processed = 0
+				continue
+			}
 			if err != context.Canceled {
 				s.failTask(taskID, err)
 			}
@@ -75,7 +89,9 @@ func (s *Service) runKline(ctx context.Context, taskID string, req StartRequest)
 				logger.Info("kline replay completed without more source rows", "task_id", taskID, "processed_bars", processed)
 				return
 			}
-			continue
+			if len(queue) == 0 {
+				continue
+			}
 		}
 		if !sourceExhausted && len(queue) <= defaultKlinePrefetchBelow {
 			chunk, err := handler.LoadKlineChunk(ctx, kreq, lastLoaded, kreq.ChunkSize)
@@ -160,7 +176,7 @@ func (s *Service) waitKlineInterval(ctx context.Context, taskID string, fallback
 	remaining := fallback
 	_, resumeSeq := s.currentKlineWaitState(taskID, fallback, 0)
 	for remaining > 0 {
-		if err := s.waitIfPaused(ctx, taskID); err != nil {
+		if interrupted, err := s.pacingInterrupted(ctx, taskID); err != nil || interrupted {
 			return err
 		}
 		interval, nextResumeSeq := s.currentKlineWaitState(taskID, fallback, resumeSeq)
@@ -6,7 +6,9 @@ package replay
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -44,6 +46,14 @@ type StartRequest struct {
 	FromCursor *bus.FileCursor `json:"from_cursor"`
 	// TickDir 指定 tick CSV 回放目录。
 	TickDir string `json:"tick_dir"`
+	// TradingDayFrom/TradingDayTo 指定多交易日 tick 回放的交易日范围（YYYYMMDD），非空时忽略 TickDir，
+	// 按交易日历逐日读取 TickArchiveDir 下的 ticks-<交易日> 归档目录。
+	TradingDayFrom string `json:"trading_day_from,omitempty"`
+	TradingDayTo   string `json:"trading_day_to,omitempty"`
+	// TickArchiveDir 是 tick 归档根目录。
+	TickArchiveDir string `json:"tick_archive_dir,omitempty"`
+	// FastForwardGaps 表示 realtime 模式下跳过非交易时段（午休、夜盘到日盘、跨交易日）的等待。
+	FastForwardGaps bool `json:"fast_forward_gaps,omitempty"`
 	// FullReplay 表示开始前是否清空去重记录并做全量回放。
 	FullReplay bool `json:"full_replay"`
 	// Kline 指定 K线回放参数。仅 mode=kline 时使用。
@@ -53,6 +63,40 @@ type StartRequest struct {
 	SharedMetaDSN string `json:"-"`
 }
 
+// StartDefaults 是启动请求未指定时补齐的默认值，取自 CTP 配置；web 回放接口和 replay_runner 共用。
+type StartDefaults struct {
+	// Mode 是默认回放模式，为空或 fast 时按 kline 处理。
+	Mode string
+	// Speed 是默认回放速度倍率。
+	Speed float64
+	// FlowPath 是行情流目录，多交易日回放读取其下的 ticks-<交易日> 归档，单目录 tick 回放默认读取其下的 ticks。
+	FlowPath string
+	// SharedMetaDSN 是 shared_meta 库 DSN，总是覆盖请求中的值。
+	SharedMetaDSN string
+}
+
+// WithDefaults 按 d 补齐请求中未指定的模式、速度和 tick 目录，并注入 shared_meta DSN。
+func (req StartRequest) WithDefaults(d StartDefaults) StartRequest {
+	if strings.TrimSpace(req.Mode) == "" {
+		req.Mode = d.Mode
+	}
+	if strings.TrimSpace(req.Mode) == "" || strings.EqualFold(strings.TrimSpace(req.Mode), "fast") {
+		req.Mode = "kline"
+	}
+	if req.Speed == 0 {
+		req.Speed = d.Speed
+	}
+	if strings.TrimSpace(req.TradingDayFrom) != "" {
+		if strings.TrimSpace(req.TickArchiveDir) == "" {
+			req.TickArchiveDir = d.FlowPath
+		}
+	} else if req.Mode != "kline" && strings.TrimSpace(req.TickDir) == "" {
+		req.TickDir = filepath.Join(d.FlowPath, "ticks")
+	}
+	req.SharedMetaDSN = strings.TrimSpace(d.SharedMetaDSN)
+	return req
+}
+
 type KlineStartRequest struct {
 	Symbol             string    `json:"symbol"`
 	Type               string    `json:"type"`
@@ -142,6 +186,20 @@ type TaskSnapshot struct {
 	StartedAt time.Time `json:"started_at"`
 	// FinishedAt 是任务结束时间。
 	FinishedAt time.Time `json:"finished_at"`
+	// PendingSteps 是暂停状态下尚未放行的单步事件数。
+	PendingSteps int `json:"pending_steps"`
+	// SeekTarget 是已请求、尚未被主循环处理的跳转目标时间。
+	SeekTarget *time.Time `json:"seek_target,omitempty"`
+	// Seeks 是本任务已完成的跳转次数。
+	Seeks int `json:"seeks"`
+	// TradingDays 是多交易日回放按交易日历解析出的交易日列表。
+	TradingDays []string `json:"trading_days,omitempty"`
+	// MissingTradingDays 是缺少 tick 归档目录而被跳过的交易日。
+	MissingTradingDays []string `json:"missing_trading_days,omitempty"`
+	// CurrentTradingDay 是正在回放的交易日。
+	CurrentTradingDay string `json:"current_trading_day,omitempty"`
+	// SettledTradingDays 是已完成日终结算的交易日数。
+	SettledTradingDays int `json:"settled_trading_days,omitempty"`
 }
 
 type ConsumerFunc func(ctx context.Context, ev bus.BusEvent) error
@@ -187,6 +245,9 @@ type Service struct {
 	seenFirstDispatch map[string]struct{}
 	// klineResumeSeq 用于在 kline 模式下标记“恢复后重置等待轮次”。
 	klineResumeSeq uint64
+	// activeReq 和 prepares 是当前任务的启动请求和准备步骤，跳转时用来重置下游状态。
+	activeReq StartRequest
+	prepares  []StartPrepareFunc
 }
 
 // NewService 初始化 replay service。
@@ -258,7 +319,11 @@ func (s *Service) StartWithPrepare(req StartRequest, prepares []StartPrepareFunc
 	if speed <= 0 {
 		return TaskSnapshot{}, fmt.Errorf("invalid replay speed: %v", speed)
 	}
-	if mode == "kline" {
+	if req.TradingDayFrom != "" {
+		if err := validateTradingDayRange(&req, mode); err != nil {
+			return TaskSnapshot{}, err
+		}
+	} else if mode == "kline" {
 		if err := validateKlineStartRequest(req.Kline); err != nil {
 			return TaskSnapshot{}, err
 		}
@@ -271,6 +336,8 @@ func (s *Service) StartWithPrepare(req StartRequest, prepares []StartPrepareFunc
 		"mode", mode,
 		"speed", speed,
 		"tick_dir", req.TickDir,
+		"trading_day_from", req.TradingDayFrom,
+		"trading_day_to", req.TradingDayTo,
 		"full_replay", req.FullReplay,
 		"topics", strings.Join(req.Topics, ","),
 		"sources", strings.Join(req.Sources, ","),
@@ -279,7 +346,7 @@ func (s *Service) StartWithPrepare(req StartRequest, prepares []StartPrepareFunc
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if mode == "kline" && s.kline == nil {
+	if mode == "kline" && req.TradingDayFrom == "" && s.kline == nil {
 		return TaskSnapshot{}, fmt.Errorf("kline replay handler is not registered")
 	}
 	if s.activeID != "" && (s.snapshot.Status == StatusRunning || s.snapshot.Status == StatusPaused) {
@@ -309,6 +376,8 @@ func (s *Service) StartWithPrepare(req StartRequest, prepares []StartPrepareFunc
 		s.klineResumeSeq++
 	}
 	prepares = append([]StartPrepareFunc(nil), prepares...)
+	s.activeReq = req
+	s.prepares = prepares
 	go s.run(ctx, taskID, req, mode, speed, prepares)
 	logger.Info("replay service task created", "task_id", taskID, "status", s.snapshot.Status, "elapsed_ms", time.Since(startedAt).Milliseconds())
 	return s.snapshot, nil
@@ -316,7 +385,12 @@ func (s *Service) StartWithPrepare(req StartRequest, prepares []StartPrepareFunc
 
 // normalizeStartRequest 在启动前补齐 sources/topics 等默认值。
 func normalizeStartRequest(req StartRequest) StartRequest {
-	if strings.TrimSpace(req.TickDir) == "" {
+	req.TradingDayFrom = strings.TrimSpace(req.TradingDayFrom)
+	req.TradingDayTo = strings.TrimSpace(req.TradingDayTo)
+	if req.TradingDayFrom != "" {
+		req.TickDir = ""
+	}
+	if strings.TrimSpace(req.TickDir) == "" && req.TradingDayFrom == "" {
 		return req
 	}
 	req.Topics = normalizeList(req.Topics)
@@ -400,6 +474,7 @@ func (s *Service) Resume() (TaskSnapshot, error) {
 		return s.snapshot, fmt.Errorf("replay task not paused")
 	}
 	s.snapshot.Status = StatusRunning
+	s.snapshot.PendingSteps = 0
 	if s.snapshot.Mode == "kline" {
 		s.klineResumeSeq++
 	}
@@ -449,8 +524,10 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 	logger.Info("replay task goroutine started", "task_id", taskID, "mode", mode, "speed", speed, "tick_dir", req.TickDir, "prepare_count", len(prepares))
 	defer func() {
 		s.mu.Lock()
-		if s.snapshot.TaskID == taskID && s.snapshot.Status == StatusRunning {
+		if s.snapshot.TaskID == taskID && (s.snapshot.Status == StatusRunning || s.snapshot.Status == StatusPaused) {
 			s.snapshot.Status = StatusDone
+			s.snapshot.PendingSteps = 0
+			s.snapshot.SeekTarget = nil
 			s.snapshot.FinishedAt = time.Now()
 		}
 		snapshot := s.snapshot
@@ -460,6 +537,7 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 		}
 		s.activeID = ""
 		s.cancel = nil
+		s.prepares = nil
 		s.mu.Unlock()
 		logger.Info("replay task goroutine finished", "task_id", taskID, "status", snapshot.Status, "processed_ticks", snapshot.ProcessedTicks, "dispatched", snapshot.Dispatched, "skipped", snapshot.Skipped, "errors", snapshot.Errors, "elapsed_ms", time.Since(startedAt).Milliseconds())
 		for _, hook := range hooks {
@@ -493,6 +571,11 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 	}
 	logger.Info("replay task prepare done", "task_id", taskID, "elapsed_ms", time.Since(prepareStartedAt).Milliseconds())
 
+	if req.TradingDayFrom != "" {
+		logger.Info("replay task entering trading day replay", "task_id", taskID, "trading_day_from", req.TradingDayFrom, "trading_day_to", req.TradingDayTo, "tick_archive_dir", req.TickArchiveDir)
+		s.runTradingDays(ctx, taskID, req, mode, speed)
+		return
+	}
 	if req.TickDir != "" {
 		logger.Info("replay task entering tick_dir replay", "task_id", taskID, "tick_dir", req.TickDir)
 		s.runTickDir(ctx, taskID, req, mode, speed)
@@ -513,10 +596,7 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 		FromCursor: req.FromCursor,
 	}
 	var prevOccurred time.Time
-	err := s.reader.Iterate(ctx, opts, func(iterCtx context.Context, ev bus.BusEvent, cursor bus.FileCursor) error {
-		if err := s.waitIfPaused(iterCtx, taskID); err != nil {
-			return err
-		}
+	iterate := func(iterCtx context.Context, ev bus.BusEvent, cursor bus.FileCursor) error {
 		if mode == "realtime" {
 			if !prevOccurred.IsZero() && !ev.OccurredAt.IsZero() {
 				delta := ev.OccurredAt.Sub(prevOccurred)
@@ -528,6 +608,9 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 				prevOccurred = ev.OccurredAt
 			}
 		}
+		if _, err := s.waitTurn(iterCtx, taskID); err != nil {
+			return err
+		}
 
 		replayEvent := ev
 		replayEvent.Replay = true
@@ -540,10 +623,26 @@ func (s *Service) run(ctx context.Context, taskID string, req StartRequest, mode
 			s.snapshot.Skipped += skipped
 			cur := cursor
 			s.snapshot.LastCursor = &cur
+			if ts := replayEvent.OccurredAt; !ts.IsZero() {
+				s.snapshot.CurrentSimTime = &ts
+			}
 		}
 		s.mu.Unlock()
 		return err
-	})
+	}
+	err := s.reader.Iterate(ctx, opts, iterate)
+	for errors.Is(err, errSeekRequested) {
+		// bus 日志按天分文件，跳转时以目标时间为起点重新迭代，FileLog 会跳过目标日期之前的文件。
+		target, seekErr := s.takeSeek(ctx, taskID)
+		if seekErr != nil {
+			err = seekErr
+			break
+		}
+		opts.StartTime = &target
+		opts.FromCursor = nil
+		prevOccurred = time.Time{}
+		err = s.reader.Iterate(ctx, opts, iterate)
+	}
 	if err != nil && err != context.Canceled {
 		logger.Info("replay bus replay failed", "task_id", taskID, "error", err)
 		s.mu.Lock()
@@ -645,7 +744,7 @@ func (s *Service) waitRealtimeDelta(ctx context.Context, taskID string, delta ti
 	remaining := delta
 	const maxSleepSlice = 100 * time.Millisecond
 	for remaining > 0 {
-		if err := s.waitIfPaused(ctx, taskID); err != nil {
+		if interrupted, err := s.pacingInterrupted(ctx, taskID); err != nil || interrupted {
 			return err
 		}
 		speed := s.currentSpeed(taskID, fallbackSpeed)
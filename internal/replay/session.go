@@ -0,0 +1,266 @@
+// session.go 实现回放会话管理。
+// 每个会话持有独立的 Service（即独立任务、暂停/单步/跳转状态），以及由创建方挂载的下游资源
+// （回放行情库、图表命名空间、模拟账户等）。default 会话对应进程启动时创建的全局回放服务，始终存在且不可删除；
+// 其它会话按需创建，允许多个分析员或多组参数在同一服务上并行回放不同交易日。
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+// DefaultSessionID 是兼容旧接口的默认会话标识，未指定 session_id 的请求都落到它上面。
+const DefaultSessionID = "default"
+
+// DefaultMaxSessions 是同时存在的会话数上限（含 default 会话）。
+const DefaultMaxSessions = 8
+
+// maxSessionIDLength 限制会话标识长度；会话标识会拼进逻辑库名，MySQL 库名上限为 64 字符。
+const maxSessionIDLength = 24
+
+// SessionFactory 为新会话创建回放服务和挂载资源，返回的 closeFn 在删除会话时调用。
+type SessionFactory func(sessionID string) (svc *Service, env any, closeFn func() error, err error)
+
+// Session 是一个独立的回放会话。
+type Session struct {
+	// ID 是会话标识，只含小写字母、数字和下划线。
+	ID string
+	// Label 是会话展示名。
+	Label string
+	// Owner 是创建会话的用户。
+	Owner string
+	// CreatedAt 是会话创建时间。
+	CreatedAt time.Time
+	// Service 是会话自有的回放调度服务。
+	Service *Service
+	// Env 是创建方挂载的会话资源，replay 包不解释其内容。
+	Env any
+
+	closeFn func() error
+}
+
+// SessionSnapshot 是会话的可观测状态。
+type SessionSnapshot struct {
+	SessionID string       `json:"session_id"`
+	Label     string       `json:"label,omitempty"`
+	Owner     string       `json:"owner,omitempty"`
+	Default   bool         `json:"default"`
+	CreatedAt time.Time    `json:"created_at"`
+	Task      TaskSnapshot `json:"task"`
+}
+
+// Snapshot 返回会话元信息和当前任务快照。
+func (s *Session) Snapshot() SessionSnapshot {
+	out := SessionSnapshot{
+		SessionID: s.ID,
+		Label:     s.Label,
+		Owner:     s.Owner,
+		Default:   s.ID == DefaultSessionID,
+		CreatedAt: s.CreatedAt,
+	}
+	if s.Service != nil {
+		out.Task = s.Service.Status()
+	}
+	return out
+}
+
+// SessionManager 管理全部回放会话。
+type SessionManager struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	factory     SessionFactory
+	maxSessions int
+}
+
+// NewSessionManager 用全局回放服务登记 default 会话；factory 为空时只支持 default 会话。
+func NewSessionManager(defaultService *Service, defaultEnv any, factory SessionFactory, maxSessions int) *SessionManager {
+	if maxSessions <= 0 {
+		maxSessions = DefaultMaxSessions
+	}
+	return &SessionManager{
+		sessions: map[string]*Session{
+			DefaultSessionID: {
+				ID:        DefaultSessionID,
+				Label:     DefaultSessionID,
+				CreatedAt: time.Now(),
+				Service:   defaultService,
+				Env:       defaultEnv,
+			},
+		},
+		factory:     factory,
+		maxSessions: maxSessions,
+	}
+}
+
+// NormalizeSessionID 归一会话标识：空值和 default 都归到 DefaultSessionID，其它值转小写后校验字符集与长度。
+func NormalizeSessionID(raw string) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(raw))
+	if id == "" || id == DefaultSessionID {
+		return DefaultSessionID, nil
+	}
+	if len(id) > maxSessionIDLength {
+		return "", fmt.Errorf("replay session id %q exceeds %d characters", raw, maxSessionIDLength)
+	}
+	for _, r := range id {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return "", fmt.Errorf("replay session id %q may only contain letters, digits and underscore", raw)
+		}
+	}
+	return id, nil
+}
+
+// Create 创建新会话；sessionID 为空时自动生成。
+func (m *SessionManager) Create(sessionID string, label string, owner string) (SessionSnapshot, error) {
+	if strings.TrimSpace(sessionID) == "" {
+		sessionID = fmt.Sprintf("s%d", time.Now().UnixNano()%1_000_000_000_000)
+	}
+	id, err := NormalizeSessionID(sessionID)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	if id == DefaultSessionID {
+		return SessionSnapshot{}, fmt.Errorf("replay session %q already exists", id)
+	}
+	m.mu.Lock()
+	if m.factory == nil {
+		m.mu.Unlock()
+		return SessionSnapshot{}, fmt.Errorf("replay sessions are not supported")
+	}
+	if _, ok := m.sessions[id]; ok {
+		m.mu.Unlock()
+		return SessionSnapshot{}, fmt.Errorf("replay session %q already exists", id)
+	}
+	if len(m.sessions) >= m.maxSessions {
+		m.mu.Unlock()
+		return SessionSnapshot{}, fmt.Errorf("replay session limit %d reached", m.maxSessions)
+	}
+	// 先占位再在锁外创建资源，避免并发创建同名会话。
+	m.sessions[id] = nil
+	factory := m.factory
+	m.mu.Unlock()
+
+	startedAt := time.Now()
+	svc, env, closeFn, err := factory(id)
+	if err == nil && svc == nil {
+		err = fmt.Errorf("replay session factory returned no service")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		delete(m.sessions, id)
+		logger.Error("replay session create failed", "session_id", id, "error", err)
+		return SessionSnapshot{}, err
+	}
+	sess := &Session{
+		ID:        id,
+		Label:     firstNonEmptyString(strings.TrimSpace(label), id),
+		Owner:     strings.TrimSpace(owner),
+		CreatedAt: time.Now(),
+		Service:   svc,
+		Env:       env,
+		closeFn:   closeFn,
+	}
+	m.sessions[id] = sess
+	logger.Info("replay session created", "session_id", id, "owner", sess.Owner, "elapsed_ms", time.Since(startedAt).Milliseconds())
+	return sess.Snapshot(), nil
+}
+
+// Get 返回会话；sessionID 为空时返回 default 会话。
+func (m *SessionManager) Get(sessionID string) (*Session, error) {
+	id, err := NormalizeSessionID(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sess := m.sessions[id]
+	if sess == nil {
+		return nil, fmt.Errorf("replay session %q not found", id)
+	}
+	return sess, nil
+}
+
+// List 返回全部会话快照，default 会话在前，其余按创建时间排序。
+func (m *SessionManager) List() []SessionSnapshot {
+	m.mu.Lock()
+	items := make([]*Session, 0, len(m.sessions))
+	for _, sess := range m.sessions {
+		if sess != nil {
+			items = append(items, sess)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(items, func(i, j int) bool {
+		if (items[i].ID == DefaultSessionID) != (items[j].ID == DefaultSessionID) {
+			return items[i].ID == DefaultSessionID
+		}
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].ID < items[j].ID
+	})
+	out := make([]SessionSnapshot, 0, len(items))
+	for _, sess := range items {
+		out = append(out, sess.Snapshot())
+	}
+	return out
+}
+
+// Remove 停止会话中的活动任务、等待主循环退出后释放会话资源；default 会话不可删除。
+func (m *SessionManager) Remove(sessionID string) (SessionSnapshot, error) {
+	id, err := NormalizeSessionID(sessionID)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	if id == DefaultSessionID {
+		return SessionSnapshot{}, fmt.Errorf("default replay session cannot be removed")
+	}
+	m.mu.Lock()
+	sess := m.sessions[id]
+	if sess == nil {
+		m.mu.Unlock()
+		return SessionSnapshot{}, fmt.Errorf("replay session %q not found", id)
+	}
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	_, _ = sess.Service.Stop()
+	sess.Service.waitIdle(5 * time.Second)
+	snap := sess.Snapshot()
+	if sess.closeFn != nil {
+		if err := sess.closeFn(); err != nil {
+			logger.Error("replay session close failed", "session_id", id, "error", err)
+			return snap, err
+		}
+	}
+	logger.Info("replay session removed", "session_id", id, "task_status", snap.Task.Status)
+	return snap, nil
+}
+
+// waitIdle 等待主循环退出，超时后直接返回。
+func (s *Service) waitIdle(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		idle := s.activeID == ""
+		s.mu.Unlock()
+		if idle || time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,97 @@
+package replay
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeSessionID(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"":          DefaultSessionID,
+		" Default ": DefaultSessionID,
+		"Alice_01":  "alice_01",
+	}
+	for raw, want := range cases {
+		got, err := NormalizeSessionID(raw)
+		if err != nil || got != want {
+			t.Fatalf("NormalizeSessionID(%q) = %q, %v, want %q", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"a-b", "x y", "abcdefghijklmnopqrstuvwxyz"} {
+		if _, err := NormalizeSessionID(raw); err == nil {
+			t.Fatalf("NormalizeSessionID(%q) should fail", raw)
+		}
+	}
+}
+
+func TestSessionManagerLifecycle(t *testing.T) {
+	t.Parallel()
+
+	closed := map[string]int{}
+	factory := func(id string) (*Service, any, func() error, error) {
+		if id == "broken" {
+			return nil, nil, nil, errors.New("boom")
+		}
+		return NewService(nil, nil, false), id + "-env", func() error {
+			closed[id]++
+			return nil
+		}, nil
+	}
+	defaultSvc := NewService(nil, nil, false)
+	mgr := NewSessionManager(defaultSvc, "default-env", factory, 3)
+
+	if sess, err := mgr.Get(""); err != nil || sess.Service != defaultSvc || sess.Env != "default-env" {
+		t.Fatalf("Get(\"\") = %+v, %v, want default session", sess, err)
+	}
+	if _, err := mgr.Create("default", "", ""); err == nil {
+		t.Fatal("Create(default) should fail")
+	}
+	if _, err := mgr.Create("broken", "", ""); err == nil {
+		t.Fatal("Create should surface factory error")
+	}
+	if _, err := mgr.Get("broken"); err == nil {
+		t.Fatal("failed session should not stay registered")
+	}
+	snap, err := mgr.Create("Alice", "Alice day", "alice")
+	if err != nil {
+		t.Fatalf("Create(Alice) error: %v", err)
+	}
+	if snap.SessionID != "alice" || snap.Label != "Alice day" || snap.Owner != "alice" || snap.Default {
+		t.Fatalf("unexpected session snapshot: %+v", snap)
+	}
+	if _, err := mgr.Create("alice", "", ""); err == nil {
+		t.Fatal("duplicate Create should fail")
+	}
+	if _, err := mgr.Create("bob", "", ""); err != nil {
+		t.Fatalf("Create(bob) error: %v", err)
+	}
+	if _, err := mgr.Create("carol", "", ""); err == nil {
+		t.Fatal("Create beyond the session limit should fail")
+	}
+
+	items := mgr.List()
+	if len(items) != 3 || !items[0].Default || items[1].SessionID != "alice" || items[2].SessionID != "bob" {
+		t.Fatalf("unexpected session list: %+v", items)
+	}
+	if sess, err := mgr.Get("ALICE"); err != nil || sess.Env != "alice-env" || sess.Service == defaultSvc {
+		t.Fatalf("Get(ALICE) = %+v, %v", sess, err)
+	}
+
+	if _, err := mgr.Remove(""); err == nil {
+		t.Fatal("default session should not be removable")
+	}
+	if _, err := mgr.Remove("alice"); err != nil {
+		t.Fatalf("Remove(alice) error: %v", err)
+	}
+	if closed["alice"] != 1 {
+		t.Fatalf("alice close count = %d, want 1", closed["alice"])
+	}
+	if _, err := mgr.Get("alice"); err == nil {
+		t.Fatal("removed session should not be found")
+	}
+	if _, err := mgr.Create("carol", "", ""); err != nil {
+		t.Fatalf("Create(carol) after removal error: %v", err)
+	}
+}
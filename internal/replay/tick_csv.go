@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -23,6 +24,8 @@ import (
 const (
 	tickCSVSource                   = "replay.tickcsv"
 	invalidTickSessionGapMinutesCSV = 1
+	// fastForwardGapThreshold 是 fast_forward_gaps 生效的最小事件间隔；交易时段内的正常 tick 间隔远小于它。
+	fastForwardGapThreshold = 5 * time.Minute
 )
 
 // tickCSVEvent 表示从 CSV 中读出并标准化后的回放事件。
@@ -165,24 +168,69 @@ func (s *Service) runTickDir(ctx context.Context, taskID string, req StartReques
 	}
 	s.mu.Unlock()
 
-	replayStartedAt := time.Now()
-	logger.Info("replay tick_dir dispatch begin", "task_id", taskID, "event_count", len(result.Events))
 	var prevOccurred time.Time
-	for _, item := range result.Events {
-		if err := s.waitIfPaused(ctx, taskID); err != nil {
-			logger.Info("replay tick_dir dispatch stopped", "task_id", taskID, "error", err, "processed_ticks", s.Status().ProcessedTicks, "elapsed_ms", time.Since(replayStartedAt).Milliseconds())
-			break
-		}
+	s.dispatchTickCSVEvents(ctx, taskID, req, result.Events, mode, speed, &prevOccurred, 0, true)
+
+	s.mu.Lock()
+	if s.snapshot.TaskID == taskID && s.snapshot.Status == StatusStopped {
+		s.snapshot.FinishedAt = time.Now()
+	}
+	s.mu.Unlock()
+}
+
+// tickDispatchOutcome 是一段 tick 事件分发的结果。
+type tickDispatchOutcome struct {
+	// stopped 表示任务已停止、取消或失败，调用方应直接返回。
+	stopped bool
+	// seekTarget 是落在本段事件范围之外、需要调用方重新定位的跳转目标；跳转准备步骤已执行。
+	seekTarget *time.Time
+}
+
+// dispatchTickCSVEvents 按回放模式推进并分发一段已排序的 tick 事件，prevOccurred 在多段之间延续节奏。
+// bounded 为 true 时所有跳转都在本段内定位；否则只处理落在本段时间范围内的跳转，其余交给调用方。
+// processedBase 是本段之前已计入的 tick 数，用于跳转后修正 ProcessedTicks。
+func (s *Service) dispatchTickCSVEvents(ctx context.Context, taskID string, req StartRequest, events []tickCSVEvent, mode string, speed float64, prevOccurred *time.Time, processedBase int64, bounded bool) tickDispatchOutcome {
+	replayStartedAt := time.Now()
+	logger.Info("replay tick_dir dispatch begin", "task_id", taskID, "event_count", len(events))
+	for i := 0; i < len(events); i++ {
+		item := events[i]
 		if mode == "realtime" {
 			if !prevOccurred.IsZero() && !item.Time.IsZero() {
-				delta := item.Time.Sub(prevOccurred)
+				delta := item.Time.Sub(*prevOccurred)
+				if req.FastForwardGaps && delta > fastForwardGapThreshold {
+					logger.Info("replay fast-forward non-trading gap", "task_id", taskID, "from", *prevOccurred, "to", item.Time, "gap", delta.String())
+					delta = 0
+				}
 				if err := s.waitRealtimeDelta(ctx, taskID, delta, speed); err != nil {
-					return
+					return tickDispatchOutcome{stopped: true}
 				}
 			}
-			if !item.Time.IsZero() {
-				prevOccurred = item.Time
+		}
+		if _, err := s.waitTurn(ctx, taskID); err != nil {
+			if errors.Is(err, errSeekRequested) {
+				target, seekErr := s.takeSeek(ctx, taskID)
+				if seekErr != nil {
+					s.failTask(taskID, seekErr)
+					return tickDispatchOutcome{stopped: true}
+				}
+				*prevOccurred = time.Time{}
+				if !bounded && (len(events) == 0 || target.Before(events[0].Time) || target.After(events[len(events)-1].Time)) {
+					return tickDispatchOutcome{seekTarget: &target}
+				}
+				next := tickCSVEventIndexAt(events, target)
+				s.mu.Lock()
+				if s.snapshot.TaskID == taskID {
+					s.snapshot.ProcessedTicks = processedBase + int64(next)
+				}
+				s.mu.Unlock()
+				i = next - 1
+				continue
 			}
+			logger.Info("replay tick_dir dispatch stopped", "task_id", taskID, "error", err, "processed_ticks", s.Status().ProcessedTicks, "elapsed_ms", time.Since(replayStartedAt).Milliseconds())
+			return tickDispatchOutcome{stopped: true}
+		}
+		if mode == "realtime" && !item.Time.IsZero() {
+			*prevOccurred = item.Time
 		}
 
 		replayEvent := item.Event
@@ -206,24 +254,17 @@ func (s *Service) runTickDir(ctx context.Context, taskID string, req StartReques
 		s.mu.Unlock()
 		if dispatchErr != nil {
 			logger.Info("replay tick_dir dispatch failed", "task_id", taskID, "instrument_id", item.InstrumentID, "error", dispatchErr, "elapsed_ms", time.Since(replayStartedAt).Milliseconds())
-			s.mu.Lock()
-			if s.snapshot.TaskID == taskID {
-				s.snapshot.Status = StatusError
-				s.snapshot.LastError = dispatchErr.Error()
-				s.snapshot.Errors++
-				s.snapshot.FinishedAt = time.Now()
-			}
-			s.mu.Unlock()
-			return
+			s.failTask(taskID, dispatchErr)
+			return tickDispatchOutcome{stopped: true}
 		}
 	}
 	logger.Info("replay tick_dir dispatch completed", "task_id", taskID, "processed_ticks", s.Status().ProcessedTicks, "elapsed_ms", time.Since(replayStartedAt).Milliseconds())
+	return tickDispatchOutcome{stopped: s.Status().Status == StatusStopped}
+}
 
-	s.mu.Lock()
-	if s.snapshot.TaskID == taskID && s.snapshot.Status == StatusStopped {
-		s.snapshot.FinishedAt = time.Now()
-	}
-	s.mu.Unlock()
+// tickCSVEventIndexAt 返回已排序事件中第一条不早于 target 的下标，全部早于 target 时返回 len(events)。
+func tickCSVEventIndexAt(events []tickCSVEvent, target time.Time) int {
+	return sort.Search(len(events), func(i int) bool { return !events[i].Time.Before(target) })
 }
 
 // loadTickCSVEvents 扫描 tick_dir 下所有 CSV，解析并合并成全局时间有序的事件列表。
@@ -0,0 +1,327 @@
+// trading_days.go 实现跨交易日的 tick 回放。
+// 交易日范围按 shared_meta 的交易日历展开（跳过节假日，日历缺失时退化为周一到周五），
+// 每个交易日读取 tick 归档根目录下的 ticks-<交易日> 目录，目录缺失的交易日记入快照后跳过。
+// 同一任务内各交易日连续分发，模拟账户和持仓跨日延续；每个交易日分发完后发出一条
+// trading_day_settle 事件，由模拟账户完成日终结算（撤销当日挂单、今仓转昨仓、按结算价盯市并生成日结单）。
+package replay
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/bus"
+	dbx "ctp-future-kline/internal/db"
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	tradingDayLayout = "20060102"
+	// maxReplayTradingDaySpan 限制一次多交易日回放覆盖的自然日跨度。
+	maxReplayTradingDaySpan = 366
+	// tickArchiveDirPrefix 与行情侧按交易日归档 tick 目录时使用的前缀一致。
+	tickArchiveDirPrefix = "ticks-"
+)
+
+// validateTradingDayRange 归一并校验多交易日回放的交易日范围，TradingDayTo 为空时只回放 TradingDayFrom 一天。
+func validateTradingDayRange(req *StartRequest, mode string) error {
+	from, err := parseTradingDay(req.TradingDayFrom)
+	if err != nil {
+		return fmt.Errorf("invalid trading_day_from: %w", err)
+	}
+	to := from
+	if req.TradingDayTo != "" {
+		if to, err = parseTradingDay(req.TradingDayTo); err != nil {
+			return fmt.Errorf("invalid trading_day_to: %w", err)
+		}
+	}
+	if to.Before(from) {
+		return fmt.Errorf("trading_day_to %s is before trading_day_from %s", to.Format(tradingDayLayout), from.Format(tradingDayLayout))
+	}
+	if to.Sub(from) > maxReplayTradingDaySpan*24*time.Hour {
+		return fmt.Errorf("trading day range exceeds %d days", maxReplayTradingDaySpan)
+	}
+	if strings.TrimSpace(req.TickArchiveDir) == "" {
+		return fmt.Errorf("tick_archive_dir is required for trading day replay")
+	}
+	if mode != "kline" && mode != "realtime" {
+		return fmt.Errorf("invalid replay mode: %s", mode)
+	}
+	req.TradingDayFrom = from.Format(tradingDayLayout)
+	req.TradingDayTo = to.Format(tradingDayLayout)
+	return nil
+}
+
+func parseTradingDay(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range []string{tradingDayLayout, "2006-01-02"} {
+		if day, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return day, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported trading day %q", raw)
+}
+
+// resolveReplayTradingDays 展开交易日范围；交易日历没有覆盖该范围时按工作日处理。
+func resolveReplayTradingDays(req StartRequest) ([]string, error) {
+	from, err := parseTradingDay(req.TradingDayFrom)
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseTradingDay(req.TradingDayTo)
+	if err != nil {
+		return nil, err
+	}
+	if dsn := strings.TrimSpace(req.SharedMetaDSN); dsn != "" {
+		days, covered, err := loadCalendarTradingDays(dsn, from, to)
+		if err != nil {
+			logger.Warn("replay trading calendar unavailable, fallback to weekdays", "from", req.TradingDayFrom, "to", req.TradingDayTo, "error", err)
+		} else if covered {
+			return days, nil
+		} else {
+			logger.Warn("replay trading calendar has no rows in range, fallback to weekdays", "from", req.TradingDayFrom, "to", req.TradingDayTo)
+		}
+	}
+	return weekdayTradingDays(from, to), nil
+}
+
+// loadCalendarTradingDays 查询范围内的开市日；covered 表示交易日历在该范围内至少有一行记录。
+func loadCalendarTradingDays(dsn string, from time.Time, to time.Time) ([]string, bool, error) {
+	db, err := dbx.Open(dsn)
+	if err != nil {
+		return nil, false, err
+	}
+	defer db.Close()
+	rows, err := db.Query(
+		`SELECT DATE_FORMAT(trade_date, '%Y%m%d'), is_open FROM trading_calendar WHERE trade_date BETWEEN ? AND ? ORDER BY trade_date`,
+		from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+	var days []string
+	covered := false
+	for rows.Next() {
+		var day string
+		var open sql.NullInt64
+		if err := rows.Scan(&day, &open); err != nil {
+			return nil, false, err
+		}
+		covered = true
+		if open.Int64 == 1 {
+			days = append(days, day)
+		}
+	}
+	return days, covered, rows.Err()
+}
+
+func weekdayTradingDays(from time.Time, to time.Time) []string {
+	var days []string
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		days = append(days, day.Format(tradingDayLayout))
+	}
+	return days
+}
+
+// tickArchiveDirForDay 返回交易日的 tick 归档目录。
+func tickArchiveDirForDay(root string, day string) string {
+	return filepath.Join(root, tickArchiveDirPrefix+day)
+}
+
+// splitTradingDayCursor 拆出多交易日游标中的交易日前缀，例如 20260330/ag2606.csv。
+func splitTradingDayCursor(cursor *bus.FileCursor) (string, *bus.FileCursor) {
+	if cursor == nil {
+		return "", nil
+	}
+	day, file, ok := strings.Cut(cursor.File, "/")
+	if !ok {
+		return "", cursor
+	}
+	return day, &bus.FileCursor{File: file, Offset: cursor.Offset}
+}
+
+// tagTradingDayEvents 给单日事件的事件 ID 和游标加上交易日前缀；不同交易日的 CSV 同名，
+// 不加前缀会让去重记录把后面交易日的 tick 当成已分发。
+func tagTradingDayEvents(events []tickCSVEvent, day string) {
+	for i := range events {
+		events[i].Cursor.File = day + "/" + events[i].Cursor.File
+		events[i].Event.EventID = fmt.Sprintf("tickcsv:%s:%d", events[i].Cursor.File, events[i].Cursor.Offset)
+	}
+}
+
+// runTradingDays 逐个交易日加载并分发 tick，交易日之间发出结算事件。
+func (s *Service) runTradingDays(ctx context.Context, taskID string, req StartRequest, mode string, speed float64) {
+	days, err := resolveReplayTradingDays(req)
+	if err != nil {
+		s.failTask(taskID, err)
+		return
+	}
+	logger.Info("replay trading days resolved", "task_id", taskID, "from", req.TradingDayFrom, "to", req.TradingDayTo, "days", len(days))
+	s.mu.Lock()
+	if s.snapshot.TaskID == taskID {
+		s.snapshot.TradingDays = append([]string(nil), days...)
+	}
+	s.mu.Unlock()
+
+	cursorDay, dayCursor := splitTradingDayCursor(req.FromCursor)
+	instruments := make(map[string]struct{})
+	var prevOccurred time.Time
+	var seekTarget *time.Time
+	var processed int64
+	for i := 0; i < len(days); i++ {
+		day := days[i]
+		if cursorDay != "" && day < cursorDay && seekTarget == nil {
+			continue
+		}
+		dir := tickArchiveDirForDay(req.TickArchiveDir, day)
+		if info, statErr := os.Stat(dir); statErr != nil || !info.IsDir() {
+			logger.Warn("replay trading day tick archive missing", "task_id", taskID, "trading_day", day, "dir", dir)
+			s.mu.Lock()
+			if s.snapshot.TaskID == taskID && !containsString(s.snapshot.MissingTradingDays, day) {
+				s.snapshot.MissingTradingDays = append(s.snapshot.MissingTradingDays, day)
+			}
+			s.mu.Unlock()
+			continue
+		}
+		dayReq := req
+		dayReq.TickDir = dir
+		dayReq.FromCursor = nil
+		if day == cursorDay && seekTarget == nil {
+			dayReq.FromCursor = dayCursor
+		}
+		result, err := loadTickCSVEvents(dayReq)
+		if err != nil {
+			s.failTask(taskID, fmt.Errorf("load trading day %s ticks failed: %w", day, err))
+			return
+		}
+		tagTradingDayEvents(result.Events, day)
+		events := result.Events
+		if seekTarget != nil {
+			if len(events) == 0 || events[len(events)-1].Time.Before(*seekTarget) {
+				continue
+			}
+			events = events[tickCSVEventIndexAt(events, *seekTarget):]
+			seekTarget = nil
+		}
+		for _, item := range events {
+			if item.InstrumentID != "" {
+				instruments[item.InstrumentID] = struct{}{}
+			}
+		}
+		s.mu.Lock()
+		if s.snapshot.TaskID == taskID {
+			s.snapshot.CurrentTradingDay = day
+			s.snapshot.TickFiles += result.FileCount
+			s.snapshot.Instruments = len(instruments)
+			s.snapshot.TotalTicks = processed + int64(len(events))
+			if s.snapshot.FirstSimTime == nil {
+				s.snapshot.FirstSimTime = result.FirstTime
+			}
+			if result.LastTime != nil {
+				s.snapshot.LastSimTime = result.LastTime
+			}
+		}
+		s.mu.Unlock()
+		logger.Info("replay trading day begin", "task_id", taskID, "trading_day", day, "event_count", len(events), "file_count", result.FileCount)
+
+		outcome := s.dispatchTickCSVEvents(ctx, taskID, req, events, mode, speed, &prevOccurred, processed, false)
+		if outcome.stopped {
+			return
+		}
+		if outcome.seekTarget != nil {
+			seekTarget = outcome.seekTarget
+			if len(events) > 0 && seekTarget.Before(events[0].Time) {
+				// 向前跳出当日范围：从第一个交易日重新定位，准备步骤已重置下游状态。
+				i = -1
+				processed = 0
+				cursorDay = ""
+			}
+			continue
+		}
+		processed += int64(len(events))
+		if err := s.settleTradingDay(ctx, taskID, day, events); err != nil {
+			s.failTask(taskID, fmt.Errorf("settle trading day %s failed: %w", day, err))
+			return
+		}
+	}
+	logger.Info("replay trading days completed", "task_id", taskID, "processed_ticks", s.Status().ProcessedTicks)
+}
+
+// settleTradingDay 在交易日最后一条 tick 之后分发结算事件，结算价取各合约最后一笔 tick。
+func (s *Service) settleTradingDay(ctx context.Context, taskID string, day string, events []tickCSVEvent) error {
+	payload := bus.TradingDaySettlePayload{TradingDay: day, Prices: tradingDaySettlePrices(events)}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	occurredAt := time.Now()
+	if len(events) > 0 {
+		occurredAt = events[len(events)-1].Time
+	}
+	ev := bus.BusEvent{
+		EventID:      "tradingday_settle:" + day,
+		Topic:        bus.TopicTradingDaySettle,
+		Source:       tickCSVSource,
+		OccurredAt:   occurredAt,
+		ProducedAt:   time.Now(),
+		Replay:       true,
+		ReplayTaskID: taskID,
+		Payload:      raw,
+	}
+	dispatched, skipped, err := s.dispatch(ctx, ev)
+	s.mu.Lock()
+	if s.snapshot.TaskID == taskID {
+		s.snapshot.Dispatched += dispatched
+		s.snapshot.Skipped += skipped
+		if err == nil {
+			s.snapshot.SettledTradingDays++
+		}
+	}
+	s.mu.Unlock()
+	logger.Info("replay trading day settled", "task_id", taskID, "trading_day", day, "instruments", len(payload.Prices), "dispatched", dispatched, "error", err)
+	return err
+}
+
+// tradingDaySettlePrices 从后往前取每个合约最后一笔 tick，优先用结算价，没有时用最新价。
+func tradingDaySettlePrices(events []tickCSVEvent) map[string]float64 {
+	out := make(map[string]float64)
+	for i := len(events) - 1; i >= 0; i-- {
+		symbol := events[i].InstrumentID
+		if symbol == "" {
+			continue
+		}
+		if _, ok := out[symbol]; ok {
+			continue
+		}
+		var tick tickPayload
+		if err := json.Unmarshal(events[i].Event.Payload, &tick); err != nil {
+			continue
+		}
+		price := tick.SettlementPrice
+		if price <= 0 || price > 1e15 {
+			price = tick.LastPrice
+		}
+		if price > 0 {
+			out[symbol] = price
+		}
+	}
+	return out
+}
+
+func containsString(items []string, want string) bool {
+	for _, item := range items {
+		if item == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/bus"
+)
+
+func writeTradingDayTicks(t *testing.T, root string, day string, rows ...string) {
+	t.Helper()
+	dir := tickArchiveDirForDay(root, day)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir tick archive failed: %v", err)
+	}
+	content := "ReceivedAt,InstrumentID,ExchangeID,TradingDay,ActionDay,UpdateTime,UpdateMillisec,LastPrice,Volume,OpenInterest,SettlementPrice,BidPrice1,AskPrice1\n" +
+		strings.Join(rows, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "ag2606.csv"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write tick csv failed: %v", err)
+	}
+}
+
+func TestReplayTradingDaysSettlesEachDayAndSkipsMissing(t *testing.T) {
+	root := t.TempDir()
+	writeTradingDayTicks(t, root, "20260330",
+		"2026-03-30 09:00:00.000,ag2606,SHFE,20260330,20260330,09:00:00,0,100,1,10,0,99,101",
+		"2026-03-30 09:01:00.000,ag2606,SHFE,20260330,20260330,09:01:00,0,101,2,11,0,100,102",
+	)
+	writeTradingDayTicks(t, root, "20260401",
+		"2026-04-01 09:00:00.000,ag2606,SHFE,20260401,20260401,09:00:00,0,110,1,10,0,109,111",
+		"2026-04-01 09:01:00.000,ag2606,SHFE,20260401,20260401,09:01:00,0,112,2,11,115,111,113",
+	)
+
+	svc := NewService(nil, nil, false)
+	var mu sync.Mutex
+	var seen []bus.BusEvent
+	svc.RegisterConsumer("test", func(_ context.Context, ev bus.BusEvent) error {
+		mu.Lock()
+		seen = append(seen, ev)
+		mu.Unlock()
+		return nil
+	})
+	// 03-28/29 是周末，03-31 没有归档目录。
+	req := StartRequest{Mode: "realtime", Speed: 1e9, TradingDayFrom: "2026-03-28", TradingDayTo: "20260401", TickArchiveDir: root}
+	if _, err := svc.StartWithPrepare(req, nil); err != nil {
+		t.Fatalf("StartWithPrepare error: %v", err)
+	}
+	waitReplayCondition(t, "task done", func() bool { return svc.Status().Status == StatusDone })
+
+	status := svc.Status()
+	if want := []string{"20260330", "20260331", "20260401"}; !reflect.DeepEqual(status.TradingDays, want) {
+		t.Fatalf("TradingDays = %v, want %v", status.TradingDays, want)
+	}
+	if want := []string{"20260331"}; !reflect.DeepEqual(status.MissingTradingDays, want) {
+		t.Fatalf("MissingTradingDays = %v, want %v", status.MissingTradingDays, want)
+	}
+	if status.SettledTradingDays != 2 {
+		t.Fatalf("SettledTradingDays = %d, want 2", status.SettledTradingDays)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var ids []string
+	for _, ev := range seen {
+		ids = append(ids, ev.EventID)
+	}
+	wantIDs := []string{
+		"tickcsv:20260330/ag2606.csv:2",
+		"tickcsv:20260330/ag2606.csv:3",
+		"tradingday_settle:20260330",
+		"tickcsv:20260401/ag2606.csv:2",
+		"tickcsv:20260401/ag2606.csv:3",
+		"tradingday_settle:20260401",
+	}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Fatalf("event ids = %v, want %v", ids, wantIDs)
+	}
+	wantPrices := []float64{101, 115}
+	for i, idx := range []int{2, 5} {
+		ev := seen[idx]
+		if ev.Topic != bus.TopicTradingDaySettle || !ev.Replay {
+			t.Fatalf("settle event = %+v", ev)
+		}
+		var payload bus.TradingDaySettlePayload
+		if err := json.Unmarshal(ev.Payload, &payload); err != nil {
+			t.Fatalf("decode settle payload failed: %v", err)
+		}
+		if got := payload.Prices["ag2606"]; got != wantPrices[i] {
+			t.Fatalf("settle price for %s = %v, want %v", payload.TradingDay, got, wantPrices[i])
+		}
+	}
+}
+
+func TestValidateTradingDayRange(t *testing.T) {
+	req := StartRequest{TradingDayFrom: "2026-03-30", TickArchiveDir: "/tmp/flow"}
+	if err := validateTradingDayRange(&req, "kline"); err != nil {
+		t.Fatalf("validateTradingDayRange error: %v", err)
+	}
+	if req.TradingDayFrom != "20260330" || req.TradingDayTo != "20260330" {
+		t.Fatalf("normalized range = %s..%s", req.TradingDayFrom, req.TradingDayTo)
+	}
+	cases := []StartRequest{
+		{TradingDayFrom: "2026/03/30", TickArchiveDir: "/tmp/flow"},
+		{TradingDayFrom: "20260401", TradingDayTo: "20260330", TickArchiveDir: "/tmp/flow"},
+		{TradingDayFrom: "20250101", TradingDayTo: "20260330", TickArchiveDir: "/tmp/flow"},
+		{TradingDayFrom: "20260330"},
+	}
+	for _, c := range cases {
+		if err := validateTradingDayRange(&c, "kline"); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestWeekdayTradingDaysAndCursor(t *testing.T) {
+	from := time.Date(2026, 3, 27, 0, 0, 0, 0, time.Local)
+	got := weekdayTradingDays(from, from.AddDate(0, 0, 4))
+	if want := []string{"20260327", "20260330", "20260331"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("weekdayTradingDays = %v, want %v", got, want)
+	}
+	day, cursor := splitTradingDayCursor(&bus.FileCursor{File: "20260330/ag2606.csv", Offset: 7})
+	if day != "20260330" || cursor.File != "ag2606.csv" || cursor.Offset != 7 {
+		t.Fatalf("splitTradingDayCursor = %s %+v", day, cursor)
+	}
+	if day, cursor := splitTradingDayCursor(&bus.FileCursor{File: "ag2606.csv"}); day != "" || cursor.File != "ag2606.csv" {
+		t.Fatalf("plain cursor split = %s %+v", day, cursor)
+	}
+}
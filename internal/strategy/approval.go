@@ -0,0 +1,400 @@
+// approval.go 负责实例的确认执行模式（execution_mode=confirm）。
+// 该模式下实盘和纸面信号不直接下单，而是生成带有效期的待审批请求，连同信号的判断快照推送到前端；
+// 人工批准（可改手数或委托价）后才进入风控和下单，拒绝、过期或被同合约新信号替代时不下单。
+// 创建和每次处理都会写一条订单审计和 approval trace。回放模式不受影响，始终自动执行。
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	ApprovalStatusPending    = "pending"
+	ApprovalStatusApproved   = "approved"
+	ApprovalStatusRejected   = "rejected"
+	ApprovalStatusExpired    = "expired"
+	ApprovalStatusSuperseded = "superseded"
+
+	// ConfirmExpiryParamKey 是实例参数中审批有效期（秒）的键。
+	ConfirmExpiryParamKey = "confirm_expiry_sec"
+	// DefaultConfirmExpiry 是未配置有效期时的默认值。
+	DefaultConfirmExpiry = 2 * time.Minute
+)
+
+// SignalApproval 是确认模式下一条信号的审批请求。
+type SignalApproval struct {
+	ApprovalID string    `json:"approval_id"`
+	InstanceID string    `json:"instance_id"`
+	StrategyID string    `json:"strategy_id"`
+	Symbol     string    `json:"symbol"`
+	Timeframe  string    `json:"timeframe"`
+	Mode       string    `json:"mode"`
+	SignalID   int64     `json:"signal_id"`
+	EventTime  time.Time `json:"event_time"`
+	// TargetPosition 是策略要求的实例目标仓位。
+	TargetPosition float64 `json:"target_position"`
+	// CurrentPosition 是生成请求时实例的当前仓位，PlannedDelta 是据此预估的调仓手数；批准时会按最新仓位重新计算。
+	CurrentPosition float64 `json:"current_position"`
+	PlannedDelta    float64 `json:"planned_delta"`
+	// Decision 是策略返回的完整信号，含判断过程快照 trace。
+	Decision SignalDecision `json:"decision"`
+	// LatencyTraceID 是触发信号的实盘 bar 延迟追踪 ID。
+	LatencyTraceID string     `json:"latency_trace_id,omitempty"`
+	Status         string     `json:"status"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	DecidedAt      *time.Time `json:"decided_at,omitempty"`
+	DecidedBy      string     `json:"decided_by,omitempty"`
+	Note           string     `json:"note,omitempty"`
+	// ApprovedVolume 和 ApprovedPrice 是批准时改过的手数和委托价，0 表示沿用信号。
+	ApprovedVolume float64 `json:"approved_volume,omitempty"`
+	ApprovedPrice  float64 `json:"approved_price,omitempty"`
+	// Result 是批准后执行的订单计划结果。
+	Result    map[string]any `json:"result,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ApprovalAction 是人工批准或拒绝时提交的内容。
+type ApprovalAction struct {
+	// Volume 是改过的调仓手数（正数），方向仍按信号，0 表示按信号目标仓位执行。
+	Volume float64 `json:"volume,omitempty"`
+	// Price 是改过的限价，0 表示按行情定价。
+	Price    float64 `json:"price,omitempty"`
+	Operator string  `json:"operator,omitempty"`
+	Note     string  `json:"note,omitempty"`
+}
+
+func normalizeExecutionMode(mode string) string {
+	if strings.EqualFold(strings.TrimSpace(mode), ExecutionModeConfirm) {
+		return ExecutionModeConfirm
+	}
+	return ExecutionModeAuto
+}
+
+func validateExecutionMode(mode string) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", ExecutionModeAuto, ExecutionModeConfirm:
+		return nil
+	default:
+		return fmt.Errorf("unsupported execution_mode %q", mode)
+	}
+}
+
+// requiresApproval 判断实例在该运行模式下的信号是否需要人工确认。
+func requiresApproval(inst StrategyInstance, mode string) bool {
+	return normalizeExecutionMode(inst.ExecutionMode) == ExecutionModeConfirm && !strings.EqualFold(strings.TrimSpace(mode), RunTypeReplay)
+}
+
+func approvalExpiry(params map[string]any) time.Duration {
+	if sec := ma20ParamInt(params, ConfirmExpiryParamKey, 0); sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return DefaultConfirmExpiry
+}
+
+// approvedTarget 按批准手数重新计算目标仓位：方向取信号目标相对当前仓位的方向，volume<=0 时沿用信号目标。
+// 信号本身不反手时，批准手数过大也只平到 0，不会越过零轴开出反向仓位。
+func approvedTarget(signalTarget float64, current float64, volume float64) float64 {
+	if volume <= 0 {
+		return signalTarget
+	}
+	delta := signalTarget - current
+	if delta == 0 {
+		return current
+	}
+	target := current + math.Copysign(volume, delta)
+	if signalTarget*current >= 0 && target*current < 0 {
+		return 0
+	}
+	return target
+}
+
+// requestSignalApproval 把信号转成待审批请求；同实例同合约尚未处理的旧请求会被替代。
+func (m *Manager) requestSignalApproval(inst StrategyInstance, sig SignalRecord, decision SignalDecision, latencyTraceID string) SignalApproval {
+	current := 0.0
+	if m.exec != nil {
+		current, _ = m.exec.instancePositionSnapshot(inst, sig.Symbol, sig.Mode)
+	}
+	now := time.Now()
+	approval := SignalApproval{
+		ApprovalID:      mustRunID("approval"),
+		InstanceID:      inst.InstanceID,
+		StrategyID:      inst.StrategyID,
+		Symbol:          sig.Symbol,
+		Timeframe:       inst.Timeframe,
+		Mode:            sig.Mode,
+		SignalID:        sig.ID,
+		EventTime:       sig.EventTime,
+		TargetPosition:  decision.TargetPosition,
+		CurrentPosition: current,
+		PlannedDelta:    decision.TargetPosition - current,
+		Decision:        decision,
+		LatencyTraceID:  latencyTraceID,
+		Status:          ApprovalStatusPending,
+		ExpiresAt:       now.Add(approvalExpiry(inst.Params)),
+		CreatedAt:       now,
+	}
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	if pending, err := m.store.ListApprovals(inst.InstanceID, ApprovalStatusPending, 500); err == nil {
+		for _, old := range pending {
+			if strings.EqualFold(old.Symbol, approval.Symbol) {
+				m.closeApprovalLocked(inst, old, ApprovalStatusSuperseded, ApprovalAction{Note: "superseded by " + approval.ApprovalID})
+			}
+		}
+	}
+	if err := m.store.SaveApproval(approval); err != nil {
+		m.setError(err)
+		return approval
+	}
+	m.scheduleApprovalExpiryLocked(approval)
+	m.auditApproval(inst, approval)
+	m.broadcast("strategy_approval_request", approval)
+	return approval
+}
+
+// ListApprovals 返回审批请求；已过期但未被定时器处理的请求会先标为过期。
+func (m *Manager) ListApprovals(instanceID string, status string, limit int) ([]SignalApproval, error) {
+	if m.store == nil {
+		return nil, fmt.Errorf("strategy store not configured")
+	}
+	items, err := m.store.ListApprovals(strings.TrimSpace(instanceID), strings.TrimSpace(status), limit)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	out := items[:0]
+	for _, item := range items {
+		if item.Status == ApprovalStatusPending && !now.Before(item.ExpiresAt) {
+			item = m.expireApproval(item.ApprovalID)
+			if status != "" && item.Status != status {
+				continue
+			}
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// ApproveSignal 批准一条待审批请求，按最新实例仓位和批准参数重新走风控并下单。
+func (m *Manager) ApproveSignal(approvalID string, action ApprovalAction) (SignalApproval, error) {
+	if action.Volume < 0 || action.Price < 0 {
+		return SignalApproval{}, fmt.Errorf("approved volume and price must not be negative")
+	}
+	if action.Volume != math.Trunc(action.Volume) {
+		return SignalApproval{}, fmt.Errorf("approved volume %.4f is not an integer", action.Volume)
+	}
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	approval, inst, err := m.pendingApprovalLocked(approvalID)
+	if err != nil {
+		return approval, err
+	}
+	if inst.Status != InstanceStatusRunning {
+		return approval, fmt.Errorf("strategy instance %s is not running", inst.InstanceID)
+	}
+	current := 0.0
+	if m.exec != nil {
+		current, _ = m.exec.instancePositionSnapshot(inst, approval.Symbol, approval.Mode)
+	}
+	decision := approval.Decision
+	decision.TargetPosition = approvedTarget(approval.TargetPosition, current, action.Volume)
+	now := time.Now()
+	approval.Status = ApprovalStatusApproved
+	approval.DecidedAt = &now
+	approval.DecidedBy = strings.TrimSpace(action.Operator)
+	approval.Note = strings.TrimSpace(action.Note)
+	approval.ApprovedVolume = action.Volume
+	approval.ApprovedPrice = action.Price
+	m.stopApprovalTimerLocked(approval.ApprovalID)
+	// 下单延迟不计入决策链路：人工等待时间会淹没分位数。
+	audit := m.executeSignal(inst, approval.Symbol, approval.Mode, approval.EventTime, decision, nil, "", &approval)
+	approval.Result = map[string]any{
+		"audit_id":         audit.ID,
+		"target_position":  decision.TargetPosition,
+		"current_position": audit.CurrentPosition,
+		"planned_delta":    audit.PlannedDelta,
+		"risk_status":      audit.RiskStatus,
+		"risk_reason":      audit.RiskReason,
+		"order_status":     audit.OrderStatus,
+		"external_order":   audit.Audit["external_order"],
+	}
+	if err := m.store.SaveApproval(approval); err != nil {
+		return approval, err
+	}
+	m.traceApproval(inst, approval)
+	target := decision.TargetPosition
+	m.markInstanceSignal(inst, &target)
+	m.broadcast("order_audit_update", audit)
+	m.broadcast("strategy_approval_update", approval)
+	return approval, nil
+}
+
+// RejectSignal 拒绝一条待审批请求，不下单。
+func (m *Manager) RejectSignal(approvalID string, action ApprovalAction) (SignalApproval, error) {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	approval, inst, err := m.pendingApprovalLocked(approvalID)
+	if err != nil {
+		return approval, err
+	}
+	return m.closeApprovalLocked(inst, approval, ApprovalStatusRejected, action), nil
+}
+
+// pendingApprovalLocked 取出仍可处理的审批请求及其实例；已到期的请求会先标为过期再报错。
+func (m *Manager) pendingApprovalLocked(approvalID string) (SignalApproval, StrategyInstance, error) {
+	if m.store == nil {
+		return SignalApproval{}, StrategyInstance{}, fmt.Errorf("strategy store not configured")
+	}
+	approval, err := m.store.GetApproval(strings.TrimSpace(approvalID))
+	if err != nil {
+		return approval, StrategyInstance{}, err
+	}
+	if approval.Status != ApprovalStatusPending {
+		return approval, StrategyInstance{}, fmt.Errorf("approval %s is already %s", approval.ApprovalID, approval.Status)
+	}
+	inst := m.approvalInstance(approval)
+	if !time.Now().Before(approval.ExpiresAt) {
+		approval = m.closeApprovalLocked(inst, approval, ApprovalStatusExpired, ApprovalAction{})
+		return approval, inst, fmt.Errorf("approval %s expired at %s", approval.ApprovalID, approval.ExpiresAt.Format(time.RFC3339))
+	}
+	return approval, inst, nil
+}
+
+func (m *Manager) approvalInstance(approval SignalApproval) StrategyInstance {
+	m.mu.RLock()
+	inst, ok := m.instances[approval.InstanceID]
+	m.mu.RUnlock()
+	if ok {
+		return inst
+	}
+	if stored, err := m.store.GetInstance(approval.InstanceID); err == nil {
+		return stored
+	}
+	return StrategyInstance{InstanceID: approval.InstanceID, StrategyID: approval.StrategyID, Timeframe: approval.Timeframe, Mode: approval.Mode}
+}
+
+// expireApproval 把到期仍未处理的请求标为过期，返回最新状态。
+func (m *Manager) expireApproval(approvalID string) SignalApproval {
+	m.approvalMu.Lock()
+	defer m.approvalMu.Unlock()
+	approval, err := m.store.GetApproval(approvalID)
+	if err != nil || approval.Status != ApprovalStatusPending {
+		m.stopApprovalTimerLocked(approvalID)
+		return approval
+	}
+	return m.closeApprovalLocked(m.approvalInstance(approval), approval, ApprovalStatusExpired, ApprovalAction{})
+}
+
+// closeApprovalLocked 以不下单的终态结束请求，写审计和 trace 并推送。
+func (m *Manager) closeApprovalLocked(inst StrategyInstance, approval SignalApproval, status string, action ApprovalAction) SignalApproval {
+	now := time.Now()
+	approval.Status = status
+	approval.DecidedAt = &now
+	approval.DecidedBy = strings.TrimSpace(action.Operator)
+	approval.Note = strings.TrimSpace(action.Note)
+	m.stopApprovalTimerLocked(approval.ApprovalID)
+	if err := m.store.SaveApproval(approval); err != nil {
+		logger.Warn("save strategy approval failed", "approval_id", approval.ApprovalID, "status", status, "error", err)
+	}
+	m.auditApproval(inst, approval)
+	m.broadcast("strategy_approval_update", approval)
+	return approval
+}
+
+func (m *Manager) scheduleApprovalExpiryLocked(approval SignalApproval) {
+	if m.approvalTimers == nil {
+		m.approvalTimers = make(map[string]*time.Timer)
+	}
+	id := approval.ApprovalID
+	m.approvalTimers[id] = time.AfterFunc(time.Until(approval.ExpiresAt), func() { m.expireApproval(id) })
+}
+
+func (m *Manager) stopApprovalTimerLocked(approvalID string) {
+	if timer, ok := m.approvalTimers[approvalID]; ok {
+		timer.Stop()
+		delete(m.approvalTimers, approvalID)
+	}
+}
+
+// auditApproval 为未下单的审批状态（待审批、拒绝、过期、替代）写订单审计和 trace；批准后的审计由 executeSignal 写入。
+func (m *Manager) auditApproval(inst StrategyInstance, approval SignalApproval) {
+	orderStatus := approval.Status
+	if orderStatus == ApprovalStatusPending {
+		orderStatus = OrderStatusPendingApproval
+	}
+	audit := OrderAuditRecord{
+		InstanceID:      approval.InstanceID,
+		StrategyID:      approval.StrategyID,
+		Symbol:          approval.Symbol,
+		Mode:            approval.Mode,
+		EventTime:       approval.EventTime,
+		TargetPosition:  approval.TargetPosition,
+		CurrentPosition: approval.CurrentPosition,
+		PlannedDelta:    approval.PlannedDelta,
+		OrderStatus:     orderStatus,
+		Audit: map[string]any{
+			"approval_id": approval.ApprovalID,
+			"signal_id":   approval.SignalID,
+			"reason":      approval.Decision.Reason,
+			"confidence":  approval.Decision.Confidence,
+			"expires_at":  approval.ExpiresAt,
+			"decided_by":  approval.DecidedBy,
+			"note":        approval.Note,
+		},
+		CreatedAt: time.Now(),
+	}
+	if id, err := m.store.AppendOrderAudit(audit); err == nil {
+		audit.ID = id
+	}
+	m.traceApproval(inst, approval)
+	m.broadcast("order_audit_update", audit)
+}
+
+var approvalStepLabels = map[string]string{
+	ApprovalStatusPending:    "等待人工确认",
+	ApprovalStatusApproved:   "人工批准",
+	ApprovalStatusRejected:   "人工拒绝",
+	ApprovalStatusExpired:    "审批过期",
+	ApprovalStatusSuperseded: "被新信号替代",
+}
+
+func (m *Manager) traceApproval(inst StrategyInstance, approval SignalApproval) {
+	metrics := map[string]any{
+		"approval_id":      approval.ApprovalID,
+		"signal_id":        approval.SignalID,
+		"target_position":  approval.TargetPosition,
+		"current_position": approval.CurrentPosition,
+		"planned_delta":    approval.PlannedDelta,
+		"expires_at":       approval.ExpiresAt,
+	}
+	if approval.DecidedBy != "" {
+		metrics["decided_by"] = approval.DecidedBy
+	}
+	if approval.ApprovedVolume > 0 {
+		metrics["approved_volume"] = approval.ApprovedVolume
+	}
+	if approval.ApprovedPrice > 0 {
+		metrics["approved_price"] = approval.ApprovedPrice
+	}
+	if approval.Result != nil {
+		metrics["result"] = approval.Result
+	}
+	if approval.LatencyTraceID != "" {
+		metrics["latency_trace_id"] = approval.LatencyTraceID
+	}
+	m.persistTrace(inst, approval.Symbol, approval.Mode, approval.EventTime, StrategyTraceRecord{
+		EventType: "approval",
+		StepKey:   "approval_" + approval.Status,
+		StepLabel: approvalStepLabels[approval.Status],
+		StepIndex: 5,
+		StepTotal: 5,
+		Status:    approval.Status,
+		Reason:    approval.Note,
+		Metrics:   metrics,
+	})
+}
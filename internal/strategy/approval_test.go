@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApprovedTargetKeepsSignalDirection(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		target, current, volume, want float64
+	}{
+		{target: -3, current: 0, volume: 0, want: -3},
+		{target: -3, current: 0, volume: 1, want: -1},
+		{target: 2, current: 1, volume: 4, want: 5},
+		{target: 0, current: -2, volume: 1, want: -1},
+		{target: 1, current: 1, volume: 2, want: 1},
+		// 平仓信号批准手数超过持仓时只平到 0。
+		{target: 0, current: -2, volume: 5, want: 0},
+		{target: 1, current: 3, volume: 5, want: 0},
+		// 信号本身反手时按批准手数执行。
+		{target: 3, current: -2, volume: 2, want: 0},
+		{target: 3, current: -2, volume: 8, want: 6},
+	}
+	for _, tc := range cases {
+		if got := approvedTarget(tc.target, tc.current, tc.volume); got != tc.want {
+			t.Fatalf("approvedTarget(%v, %v, %v) = %v, want %v", tc.target, tc.current, tc.volume, got, tc.want)
+		}
+	}
+}
+
+func TestExecutionModeConfirmOnlyOutsideReplay(t *testing.T) {
+	t.Parallel()
+
+	if err := validateExecutionMode("manual"); err == nil {
+		t.Fatal("unknown execution mode should be rejected")
+	}
+	if err := validateExecutionMode(" Confirm "); err != nil {
+		t.Fatalf("validateExecutionMode(confirm) error = %v", err)
+	}
+	if got := normalizeExecutionMode(""); got != ExecutionModeAuto {
+		t.Fatalf("default execution mode = %q", got)
+	}
+	inst := StrategyInstance{ExecutionMode: "CONFIRM"}
+	if !requiresApproval(inst, RunTypeRealtime) || requiresApproval(inst, RunTypeReplay) {
+		t.Fatal("confirm mode should gate realtime signals only")
+	}
+	if requiresApproval(StrategyInstance{}, RunTypeRealtime) {
+		t.Fatal("auto mode should not require approval")
+	}
+}
+
+func TestApprovalExpiryFromParams(t *testing.T) {
+	t.Parallel()
+
+	if got := approvalExpiry(nil); got != DefaultConfirmExpiry {
+		t.Fatalf("default expiry = %v", got)
+	}
+	if got := approvalExpiry(map[string]any{ConfirmExpiryParamKey: float64(30)}); got != 30*time.Second {
+		t.Fatalf("expiry = %v, want 30s", got)
+	}
+	if got := approvalExpiry(map[string]any{ConfirmExpiryParamKey: -5}); got != DefaultConfirmExpiry {
+		t.Fatalf("negative expiry = %v, want default", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
@@ -12,6 +13,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"ctp-future-kline/internal/perf"
 )
 
 const (
@@ -158,14 +161,22 @@ func writeStrategyRunArchive(base string, run StrategyRun, req any, resp Backtes
 	if err := writeStrategyRunCSV(csvPath, resp.Result); err != nil {
 		return "", "", err
 	}
-	body, err := json.MarshalIndent(map[string]any{
+	archive := map[string]any{
 		"type":        "strategy_run",
 		"run":         run,
 		"request":     req,
 		"result":      resp.Result,
 		"csv_path":    csvPath,
 		"archived_at": time.Now(),
-	}, "", "  ")
+	}
+	if report, ok := resp.Result["performance"].(perf.Report); ok {
+		paths, err := writeStrategyPerformanceCSV(csvPath, report)
+		if err != nil {
+			return "", "", err
+		}
+		archive["performance_csv_paths"] = paths
+	}
+	body, err := json.MarshalIndent(archive, "", "  ")
 	if err != nil {
 		return "", "", err
 	}
@@ -377,6 +388,36 @@ func writeStrategyRunCSV(path string, result map[string]any) error {
 	return w.Error()
 }
 
+// writeStrategyPerformanceCSV 在运行 CSV 旁写出权益/回撤序列和逐日盈亏两份 CSV。
+func writeStrategyPerformanceCSV(csvPath string, report perf.Report) (map[string]string, error) {
+	stem := strings.TrimSuffix(csvPath, filepath.Ext(csvPath))
+	paths := map[string]string{
+		"equity": stem + "_equity.csv",
+		"daily":  stem + "_daily.csv",
+	}
+	writers := map[string]func(io.Writer) error{
+		"equity": report.WriteEquityCSV,
+		"daily":  report.WriteDailyCSV,
+	}
+	for table, path := range paths {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		err = writeUTF8BOM(f)
+		if err == nil {
+			err = writers[table](f)
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
 func strategyTradeCSVHeader(withRole bool) []string {
 	header := []string{"时间", "合约", "买卖", "开平", "价格", "持仓", "盈亏", "原因", "目标", "原仓", "变化", "风控", "订单", "状态", "MA20", "MA60", "Bar"}
 	if withRole {
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"ctp-future-kline/internal/perf"
 )
 
 func TestWriteStrategyRunArchiveCreatesMatchingJSONAndCSV(t *testing.T) {
@@ -163,3 +165,35 @@ func TestComboArchiveMergesInstancesAndRuns(t *testing.T) {
 		t.Fatalf("combo csv missing role rows:\n%s", string(csvBody))
 	}
 }
+
+func TestWriteStrategyRunArchiveExportsPerformanceCSV(t *testing.T) {
+	dir := t.TempDir()
+	tracker := perf.NewTracker(1_000)
+	at := time.Date(2026, 5, 19, 21, 0, 0, 0, time.Local)
+	tracker.Fill(perf.Fill{Symbol: "rb2601", Direction: "buy", Price: 100, Volume: 1, Time: at})
+	tracker.Mark("rb2601", 105, at.Add(time.Minute))
+	run := StrategyRun{RunID: "backtest-1", RunType: RunTypeBacktest}
+	jsonPath, csvPath, err := writeStrategyRunArchive(dir, run, map[string]any{}, BacktestResponse{
+		RunID:  run.RunID,
+		Result: map[string]any{"performance": tracker.Report()},
+	})
+	if err != nil {
+		t.Fatalf("writeStrategyRunArchive() error = %v", err)
+	}
+	equityPath := strings.TrimSuffix(csvPath, ".csv") + "_equity.csv"
+	body, err := os.ReadFile(equityPath)
+	if err != nil {
+		t.Fatalf("read equity csv: %v", err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF}))).ReadAll()
+	if err != nil || len(rows) != 3 || rows[0][0] != "time" || rows[2][1] != "1005" {
+		t.Fatalf("equity csv rows = %v err=%v", rows, err)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(csvPath, ".csv") + "_daily.csv"); err != nil {
+		t.Fatalf("daily csv missing: %v", err)
+	}
+	archive := readJSONMap(jsonPath)
+	if paths, ok := archive["performance_csv_paths"].(map[string]any); !ok || paths["equity"] != equityPath {
+		t.Fatalf("performance_csv_paths = %v", archive["performance_csv_paths"])
+	}
+}
@@ -0,0 +1,178 @@
+// backtest_portfolio.go 负责把回测请求接到 Go 组合回测引擎。
+// 引擎本体在 internal/backtest（它依赖 trade 的模拟撮合，而 trade 间接依赖本包），
+// 由 web 层通过 SetPortfolioBacktester 注入；这里只负责选择运行时、整理实例参数和保存回测记录。
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BacktestEnginePortfolio 是 BacktestRequest.Parameters["engine"] 选择 Go 组合回测引擎的取值。
+const BacktestEnginePortfolio = "portfolio"
+
+// BacktestRuntime 是组合回测驱动策略所需的运行时方法，Go 运行时与 Python HTTP 客户端都满足该接口。
+type BacktestRuntime interface {
+	LoadStrategy(context.Context, LoadStrategyRequest) error
+	StartInstance(context.Context, StartInstanceRequest) error
+	StopInstance(context.Context, StopInstanceRequest) error
+	OnReplayBar(context.Context, DecisionRequest) (SignalDecision, error)
+}
+
+// PortfolioBacktestConfig 是组合回测的运行参数。
+type PortfolioBacktestConfig struct {
+	// Instance 是回测使用的策略实例，InstanceID 在运行时内必须唯一。
+	Instance StrategyInstance
+	// Symbols 是参与回测的合约列表。
+	Symbols []string
+	// Timeframe 是 K 线周期，默认 1m。
+	Timeframe string
+	// Start 是回测起始时间（不含），零值表示从最早的数据开始。
+	Start time.Time
+	// End 是回测结束时间（含），零值表示读到数据末尾。
+	End time.Time
+	// InitialBalance 是初始权益，<=0 时使用模拟盘默认初始资金。
+	InitialBalance float64
+}
+
+// PortfolioBacktester 在给定运行时上执行一次组合回测并返回归档用的结果。
+type PortfolioBacktester func(ctx context.Context, rt BacktestRuntime, cfg PortfolioBacktestConfig) (BacktestResponse, error)
+
+// SetPortfolioBacktester 注入 Go 组合回测引擎。
+func (m *Manager) SetPortfolioBacktester(fn PortfolioBacktester) {
+	m.mu.Lock()
+	m.portfolioBacktester = fn
+	m.mu.Unlock()
+}
+
+// NormalizeBacktestSymbols 去空、去重、统一小写并排序，保证同一组合的回测结果可复现。
+func NormalizeBacktestSymbols(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		symbol := strings.ToLower(strings.TrimSpace(item))
+		if symbol == "" {
+			continue
+		}
+		if _, dup := seen[symbol]; dup {
+			continue
+		}
+		seen[symbol] = struct{}{}
+		out = append(out, symbol)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// shouldRunPortfolioBacktest 判断是否走 Go 组合回测：显式指定 engine=portfolio，或策略本身是 Go 策略。
+func shouldRunPortfolioBacktest(req BacktestRequest) bool {
+	if IsNativeStrategyID(req.Instance.StrategyID) {
+		return true
+	}
+	return strings.EqualFold(strings.TrimSpace(ma20ParamString(req.Parameters, "engine", "")), BacktestEnginePortfolio)
+}
+
+// backtestSymbolsFromRequest 依次取 parameters.symbols、逗号分隔的 symbol 和实例绑定的合约。
+func backtestSymbolsFromRequest(req BacktestRequest) []string {
+	if items := ma20ParamStringList(req.Parameters, "symbols", nil); len(items) > 0 {
+		return items
+	}
+	if symbol := strings.TrimSpace(req.Symbol); symbol != "" {
+		return strings.Split(symbol, ",")
+	}
+	return req.Instance.Symbols
+}
+
+// portfolioBacktestConfig 把回测请求整理成引擎参数；回测实例以 run_id 作为 instance_id，
+// 避免与同一运行时中的实盘/回放实例冲突，请求参数覆盖实例参数。
+func portfolioBacktestConfig(req BacktestRequest) (PortfolioBacktestConfig, error) {
+	start, _, err := parseBacktestOptionalTime(req.StartTime)
+	if err != nil {
+		return PortfolioBacktestConfig{}, err
+	}
+	end, _, err := parseBacktestOptionalTime(req.EndTime)
+	if err != nil {
+		return PortfolioBacktestConfig{}, err
+	}
+	inst := req.Instance
+	inst.InstanceID = req.RunID
+	inst.Params = cloneStrategyParams(inst.Params)
+	if inst.Params == nil && len(req.Parameters) > 0 {
+		inst.Params = make(map[string]any, len(req.Parameters))
+	}
+	for key, value := range req.Parameters {
+		inst.Params[key] = value
+	}
+	return PortfolioBacktestConfig{
+		Instance:       inst,
+		Symbols:        NormalizeBacktestSymbols(backtestSymbolsFromRequest(req)),
+		Timeframe:      firstNonEmpty(req.Timeframe, req.Instance.Timeframe, "1m"),
+		Start:          start,
+		End:            end,
+		InitialBalance: ma20ParamFloat(req.Parameters, "initial_balance", 0),
+	}, nil
+}
+
+func (m *Manager) runPortfolioBacktest(req BacktestRequest) (StrategyRun, error) {
+	if strings.TrimSpace(req.RunID) == "" {
+		req.RunID = mustRunID("backtest")
+	}
+	cfg, cfgErr := portfolioBacktestConfig(req)
+	run := StrategyRun{
+		RunID:      req.RunID,
+		InstanceID: req.Instance.InstanceID,
+		StrategyID: req.Instance.StrategyID,
+		RunType:    RunTypeBacktest,
+		Status:     "running",
+		Symbol:     strings.Join(cfg.Symbols, ","),
+		Timeframe:  cfg.Timeframe,
+		StartedAt:  time.Now(),
+		Summary:    map[string]any{},
+	}
+	if err := m.store.SaveRun(run); err != nil {
+		return StrategyRun{}, err
+	}
+	fail := func(err error) (StrategyRun, error) {
+		run.Status = InstanceStatusError
+		run.LastError = err.Error()
+		finished := time.Now()
+		run.FinishedAt = &finished
+		_ = m.store.SaveRun(run)
+		return run, err
+	}
+	if cfgErr != nil {
+		return fail(cfgErr)
+	}
+	m.mu.RLock()
+	backtester := m.portfolioBacktester
+	m.mu.RUnlock()
+	if backtester == nil {
+		return fail(fmt.Errorf("portfolio backtest engine is not configured"))
+	}
+	rt := m.runtimeFor(req.Instance.StrategyID)
+	if rt == nil {
+		return fail(fmt.Errorf("strategy http client not connected"))
+	}
+	resp, err := backtester(context.Background(), rt, cfg)
+	if err != nil {
+		return fail(err)
+	}
+	resp.RunID = req.RunID
+	run.Status = resp.Status
+	run.Summary = resp.Summary
+	finished := time.Now()
+	run.FinishedAt = &finished
+	outputPath, err := m.writeBacktestOutput(run, req, resp)
+	if err != nil {
+		return run, err
+	}
+	run.OutputPath = outputPath
+	if err := m.store.SaveRun(run); err != nil {
+		return StrategyRun{}, err
+	}
+	m.broadcast("strategy_backtest_done", run)
+	return run, nil
+}
@@ -0,0 +1,49 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPortfolioBacktestConfigFromRequest(t *testing.T) {
+	t.Parallel()
+
+	req := BacktestRequest{
+		RunID:     "backtest-1",
+		Instance:  StrategyInstance{InstanceID: "live-1", StrategyID: "sample.momentum", Timeframe: "5m", Params: map[string]any{"threshold": 0.2}},
+		Symbol:    "rb2405",
+		StartTime: "2026-03-02 09:00:00",
+		Parameters: map[string]any{
+			"engine":          BacktestEnginePortfolio,
+			"symbols":         []any{"RB2405", "i2405", "rb2405"},
+			"initial_balance": 200000.0,
+			"threshold":       0.5,
+		},
+	}
+	if !shouldRunPortfolioBacktest(req) {
+		t.Fatal("engine=portfolio should select portfolio backtest")
+	}
+	if !shouldRunPortfolioBacktest(BacktestRequest{Instance: StrategyInstance{StrategyID: NativeSampleMomentumID}}) {
+		t.Fatal("native strategy should select portfolio backtest")
+	}
+	if shouldRunPortfolioBacktest(BacktestRequest{Instance: StrategyInstance{StrategyID: "sample.momentum"}}) {
+		t.Fatal("python strategy without engine should keep python backtest")
+	}
+
+	cfg, err := portfolioBacktestConfig(req)
+	if err != nil {
+		t.Fatalf("portfolioBacktestConfig() error = %v", err)
+	}
+	if cfg.Instance.InstanceID != "backtest-1" || cfg.Timeframe != "5m" || cfg.InitialBalance != 200000 {
+		t.Fatalf("cfg = %+v", cfg)
+	}
+	if len(cfg.Symbols) != 2 || cfg.Symbols[0] != "i2405" || cfg.Symbols[1] != "rb2405" {
+		t.Fatalf("symbols = %v", cfg.Symbols)
+	}
+	if !cfg.Start.Equal(time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)) || !cfg.End.IsZero() {
+		t.Fatalf("start=%v end=%v", cfg.Start, cfg.End)
+	}
+	if cfg.Instance.Params["threshold"] != 0.5 || req.Instance.Params["threshold"] != 0.2 {
+		t.Fatalf("params = %v, original = %v", cfg.Instance.Params, req.Instance.Params)
+	}
+}
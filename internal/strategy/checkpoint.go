@@ -0,0 +1,342 @@
+// checkpoint.go 负责策略实例状态检查点与热重启。
+// 实盘实例每处理若干根 K 线就让运行时导出一份不透明状态，连同已处理的最后 K 线时间写入 strategy_checkpoints；
+// 进程或 Python 服务重启时把状态交还运行时，只补放检查点之后的 K 线，避免从 warmup 重新推导出不同的状态机阶段。
+package strategy
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/searchindex"
+)
+
+// maxCheckpointReplayBars 是热重启时最多补放的 K 线数，停机太久时检查点作废，回落到完整 warmup。
+const maxCheckpointReplayBars = 3000
+
+// runtimeCheckpointer 是支持状态检查点的运行时：能导出实例状态，恢复后能逐根补放 K 线。
+type runtimeCheckpointer interface {
+	SnapshotInstance(context.Context, SnapshotInstanceRequest) (SnapshotInstanceResponse, error)
+	OnBar(context.Context, DecisionRequest) (SignalDecision, error)
+}
+
+// checkpointCursor 记录实例自上次检查点以来处理的 K 线数，以及各合约已处理的最后 K 线时间。
+type checkpointCursor struct {
+	bars        int
+	symbolTimes map[string]time.Time
+}
+
+// checkpointRestore 描述一次热重启的结果，写入恢复 trace。
+type checkpointRestore struct {
+	LastBarTime time.Time
+	Replayed    int
+}
+
+// checkpointFingerprint 对策略、合约、周期和参数做摘要；warmup_* 是启动时临时补齐的参数，不参与。
+func checkpointFingerprint(inst StrategyInstance) string {
+	params := make(map[string]any, len(inst.Params))
+	for key, value := range inst.Params {
+		if strings.HasPrefix(key, "warmup_") {
+			continue
+		}
+		params[key] = value
+	}
+	symbols := make([]string, 0, len(inst.Symbols))
+	for _, symbol := range inst.Symbols {
+		symbols = append(symbols, strings.ToLower(strings.TrimSpace(symbol)))
+	}
+	sort.Strings(symbols)
+	raw, _ := json.Marshal(map[string]any{
+		"strategy_id": strings.TrimSpace(inst.StrategyID),
+		"symbols":     symbols,
+		"timeframe":   strings.ToLower(strings.TrimSpace(inst.Timeframe)),
+		"params":      params,
+	})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// advanceCheckpointCursor 记下一根已处理的 K 线；到达检查点间隔时返回各合约时间快照和 true。
+func (m *Manager) advanceCheckpointCursor(instanceID string, symbol string, barTime time.Time) (map[string]time.Time, bool) {
+	interval := m.cfg.CheckpointIntervalBars
+	if interval <= 0 {
+		return nil, false
+	}
+	m.checkpointMu.Lock()
+	defer m.checkpointMu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]*checkpointCursor)
+	}
+	cursor := m.checkpoints[instanceID]
+	if cursor == nil {
+		cursor = &checkpointCursor{symbolTimes: make(map[string]time.Time)}
+		m.checkpoints[instanceID] = cursor
+	}
+	cursor.symbolTimes[strings.ToLower(strings.TrimSpace(symbol))] = barTime
+	cursor.bars++
+	if cursor.bars < interval {
+		return nil, false
+	}
+	cursor.bars = 0
+	out := make(map[string]time.Time, len(cursor.symbolTimes))
+	for key, value := range cursor.symbolTimes {
+		out[key] = value
+	}
+	return out, true
+}
+
+// resetCheckpointCursor 用恢复后的各合约时间重置游标；symbolTimes 为空时删除游标。
+func (m *Manager) resetCheckpointCursor(instanceID string, symbolTimes map[string]time.Time) {
+	m.checkpointMu.Lock()
+	defer m.checkpointMu.Unlock()
+	if len(symbolTimes) == 0 {
+		delete(m.checkpoints, instanceID)
+		return
+	}
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]*checkpointCursor)
+	}
+	cursor := &checkpointCursor{symbolTimes: make(map[string]time.Time, len(symbolTimes))}
+	for key, value := range symbolTimes {
+		cursor.symbolTimes[key] = value
+	}
+	m.checkpoints[instanceID] = cursor
+}
+
+// checkpointAfterBar 在实盘 K 线的信号和下单都处理完后推进游标，到达间隔时导出并保存检查点。
+// 同一实例的 K 线串行处理，导出发生在下一根 K 线之前，状态与 LastBarTime 一致。
+func (m *Manager) checkpointAfterBar(client strategyRuntime, inst StrategyInstance, symbol string, barTime time.Time) {
+	if m == nil || m.store == nil {
+		return
+	}
+	checkpointer, ok := client.(runtimeCheckpointer)
+	if !ok {
+		return
+	}
+	symbolTimes, due := m.advanceCheckpointCursor(inst.InstanceID, symbol, barTime)
+	if !due {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.requestTimeout())
+	defer cancel()
+	resp, err := checkpointer.SnapshotInstance(ctx, SnapshotInstanceRequest{InstanceID: inst.InstanceID, Mode: inst.Mode})
+	if err != nil {
+		logger.Warn("strategy checkpoint snapshot failed", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "error", err)
+		return
+	}
+	if !resp.Supported {
+		return
+	}
+	cp := StrategyCheckpoint{
+		InstanceID:     inst.InstanceID,
+		StrategyID:     inst.StrategyID,
+		Mode:           inst.Mode,
+		Fingerprint:    checkpointFingerprint(inst),
+		SymbolBarTimes: symbolTimes,
+		State:          resp.State,
+		UpdatedAt:      time.Now(),
+	}
+	for _, at := range symbolTimes {
+		if at.After(cp.LastBarTime) {
+			cp.LastBarTime = at
+		}
+	}
+	if err := m.store.SaveCheckpoint(cp); err != nil {
+		logger.Warn("strategy checkpoint save failed", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "error", err)
+	}
+}
+
+// dropCheckpoint 在实例被手动启动或停止时清除检查点，之后的重启不能再回到旧状态。
+func (m *Manager) dropCheckpoint(instanceID string) {
+	m.resetCheckpointCursor(instanceID, nil)
+	if m.store == nil {
+		return
+	}
+	if err := m.store.DeleteCheckpoint(instanceID); err != nil {
+		logger.Warn("strategy checkpoint delete failed", "instance_id", instanceID, "error", err)
+	}
+}
+
+// loadCheckpoint 返回可用于恢复实例的检查点；回放实例、配置已变化或运行时不支持时不使用。
+func (m *Manager) loadCheckpoint(client runtimeStartClient, inst StrategyInstance) (StrategyCheckpoint, bool) {
+	if m.store == nil || strings.EqualFold(strings.TrimSpace(inst.Mode), RunTypeReplay) {
+		return StrategyCheckpoint{}, false
+	}
+	if _, ok := client.(runtimeCheckpointer); !ok {
+		return StrategyCheckpoint{}, false
+	}
+	cp, err := m.store.GetCheckpoint(inst.InstanceID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Warn("strategy checkpoint load failed", "instance_id", inst.InstanceID, "error", err)
+		}
+		return StrategyCheckpoint{}, false
+	}
+	if cp.StrategyID != inst.StrategyID || cp.Fingerprint != checkpointFingerprint(inst) || len(cp.State) == 0 {
+		logger.Info("strategy checkpoint ignored after config change", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID)
+		return StrategyCheckpoint{}, false
+	}
+	return cp, true
+}
+
+// restoreRuntimeInstance 优先用检查点热重启实例，检查点不可用或恢复失败时回落到完整 warmup 启动。
+func (m *Manager) restoreRuntimeInstance(ctx context.Context, client runtimeStartClient, inst StrategyInstance) (*checkpointRestore, error) {
+	if cp, ok := m.loadCheckpoint(client, inst); ok {
+		bars, err := m.fetchCheckpointBars(inst, cp)
+		if err == nil {
+			var restore checkpointRestore
+			restore, err = m.startFromCheckpoint(ctx, client, inst, cp, bars)
+			if err == nil {
+				return &restore, nil
+			}
+		}
+		logger.Warn("strategy checkpoint restore failed; fallback to warmup start", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "error", err)
+	}
+	_, err := m.startRuntimeInstance(ctx, client, inst)
+	return nil, err
+}
+
+// startFromCheckpoint 用检查点状态启动实例，再按时间顺序补放检查点之后的 K 线。
+// 补放只推进策略状态：决策不落库、不下单，因为这些 K 线对应的时刻已经过去。
+func (m *Manager) startFromCheckpoint(ctx context.Context, client runtimeStartClient, inst StrategyInstance, cp StrategyCheckpoint, bars []BarEvent) (checkpointRestore, error) {
+	checkpointer, ok := client.(runtimeCheckpointer)
+	if !ok {
+		return checkpointRestore{}, fmt.Errorf("strategy runtime does not support checkpoints")
+	}
+	if err := client.LoadStrategy(ctx, LoadStrategyRequest{StrategyID: inst.StrategyID}); err != nil {
+		return checkpointRestore{}, err
+	}
+	runtimeInst := runtimeStrategyInstance(inst)
+	runtimeInst.Params = cloneStrategyParams(inst.Params)
+	if err := client.StartInstance(ctx, StartInstanceRequest{Instance: runtimeInst, Checkpoint: &cp}); err != nil {
+		return checkpointRestore{}, err
+	}
+	symbolTimes := make(map[string]time.Time, len(cp.SymbolBarTimes))
+	for key, value := range cp.SymbolBarTimes {
+		symbolTimes[key] = value
+	}
+	for i := range bars {
+		bar := bars[i]
+		barCtx, cancel := context.WithTimeout(ctx, m.requestTimeout())
+		_, err := checkpointer.OnBar(barCtx, DecisionRequest{
+			Instance:        runtimeInst,
+			Symbol:          bar.InstrumentID,
+			EventTime:       strategyBarEventTime(bar).Format(time.RFC3339Nano),
+			Mode:            inst.Mode,
+			CurrentPosition: m.currentExecutionPosition(inst.AccountID, bar.InstrumentID),
+			Account:         map[string]any{"account_id": inst.AccountID},
+			Bar:             &bar,
+		})
+		cancel()
+		if err != nil {
+			return checkpointRestore{}, fmt.Errorf("replay bar %s %s after checkpoint failed: %w", bar.InstrumentID, strategyBarEventTime(bar).Format(time.RFC3339), err)
+		}
+		symbolTimes[strings.ToLower(bar.InstrumentID)] = strategyBarEventTime(bar)
+	}
+	m.resetCheckpointCursor(inst.InstanceID, symbolTimes)
+	logger.Info("strategy instance restored from checkpoint", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "checkpoint_bar_time", cp.LastBarTime, "replayed_bars", len(bars))
+	return checkpointRestore{LastBarTime: cp.LastBarTime, Replayed: len(bars)}, nil
+}
+
+// fetchCheckpointBars 按合约查询检查点之后的 K 线并按时间归并；数量超过上限时报错，由调用方回落到 warmup。
+func (m *Manager) fetchCheckpointBars(inst StrategyInstance, cp StrategyCheckpoint) ([]BarEvent, error) {
+	m.mu.RLock()
+	realtimeDSN := strings.TrimSpace(m.marketRealtimeDSN)
+	replayDSN := strings.TrimSpace(m.marketReplayDSN)
+	sharedMetaDSN := strings.TrimSpace(m.sharedMetaDSN)
+	m.mu.RUnlock()
+	sources := warmupQuerySources(inst, realtimeDSN, replayDSN)
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("strategy checkpoint market DSN is not configured for mode=%s", inst.Mode)
+	}
+	timeframe := strings.TrimSpace(inst.Timeframe)
+	if timeframe == "" {
+		timeframe = "1m"
+	}
+	symbols := inst.Symbols
+	if len(symbols) == 0 {
+		symbols = []string{firstSymbol(inst.Symbols)}
+	}
+	var out []BarEvent
+	for _, raw := range symbols {
+		scope := inst
+		if len(symbols) > 1 {
+			// 多合约实例的 chart_anchor 只描述一个合约，逐合约查询时按合约代码推断类型。
+			scope = StrategyInstance{InstanceID: inst.InstanceID, Mode: inst.Mode, Symbols: []string{raw}}
+		}
+		symbol, kind, variety := inferWarmupScope(scope)
+		if symbol == "" {
+			continue
+		}
+		after, ok := cp.SymbolBarTimes[symbol]
+		if !ok {
+			after = cp.LastBarTime
+		}
+		bars, err := fetchBarsAfter(sources, sharedMetaDSN, symbol, kind, variety, timeframe, after, maxCheckpointReplayBars-len(out))
+		if err != nil {
+			return nil, err
+		}
+		for _, bar := range bars {
+			out = append(out, BarEvent{
+				Variety:      variety,
+				InstrumentID: symbol,
+				DataTime:     time.Unix(bar.DataTime, 0),
+				AdjustedTime: time.Unix(bar.AdjustedTime, 0),
+				Period:       timeframe,
+				Open:         bar.Open,
+				High:         bar.High,
+				Low:          bar.Low,
+				Close:        bar.Close,
+				Volume:       bar.Volume,
+				OpenInterest: bar.OpenInterest,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].AdjustedTime.Before(out[j].AdjustedTime) })
+	return out, nil
+}
+
+// fetchBarsAfter 从第一个可用行情源分页读取 after 之后的 K 线，超过 limit 根时报错。
+func fetchBarsAfter(sources []warmupQuerySource, sharedMetaDSN string, symbol string, kind string, variety string, timeframe string, after time.Time, limit int) ([]klinequery.KlineBar, error) {
+	var lastErr error
+	for _, source := range sources {
+		querySvc := klinequery.NewServiceWithSessionDB(source.dsn, sharedMetaDSN, searchindex.NewManager(source.dsn, 0))
+		var out []klinequery.KlineBar
+		cursor := after
+		var err error
+		for {
+			var resp klinequery.BarsResponse
+			resp, err = querySvc.BarsFrom(symbol, kind, variety, timeframe, cursor, 300)
+			if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+				break
+			}
+			if err != nil || len(resp.Bars) == 0 {
+				break
+			}
+			out = append(out, resp.Bars...)
+			if len(out) > limit {
+				return nil, fmt.Errorf("too many bars since checkpoint: symbol=%s after=%s limit=%d", symbol, after.Format(time.RFC3339), limit)
+			}
+			if len(resp.Bars) < 300 {
+				break
+			}
+			cursor = time.Unix(resp.Bars[len(resp.Bars)-1].AdjustedTime, 0)
+		}
+		if err == nil {
+			return out, nil
+		}
+		lastErr = err
+		logger.Warn("strategy checkpoint bar source failed", "source", source.name, "symbol", symbol, "timeframe", timeframe, "error", err)
+	}
+	return nil, fmt.Errorf("fetch bars since checkpoint failed after trying %s: %w", warmupSourceNames(sources), lastErr)
+}
@@ -0,0 +1,126 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/config"
+)
+
+func TestNativeRuntimeSnapshotAndRestore(t *testing.T) {
+	ctx := context.Background()
+	rt := NewNativeRuntime()
+	inst := StrategyInstance{InstanceID: "ckpt-go", StrategyID: NativeSampleMomentumID, Mode: RunTypeRealtime}
+	if err := rt.StartInstance(ctx, StartInstanceRequest{Instance: inst}); err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	for _, id := range []string{"t1", "t2"} {
+		_ = rt.OnFill(ctx, FillEvent{InstanceID: inst.InstanceID, TradeID: id})
+	}
+	snap, err := rt.SnapshotInstance(ctx, SnapshotInstanceRequest{InstanceID: inst.InstanceID})
+	if err != nil || !snap.Supported || len(snap.State) == 0 {
+		t.Fatalf("SnapshotInstance() = %+v err=%v", snap, err)
+	}
+
+	restored := NewNativeRuntime()
+	if err := restored.StartInstance(ctx, StartInstanceRequest{Instance: inst, Checkpoint: &StrategyCheckpoint{State: snap.State}}); err != nil {
+		t.Fatalf("StartInstance(checkpoint) error = %v", err)
+	}
+	decision, err := restored.OnBar(ctx, DecisionRequest{Instance: inst, Symbol: "rb2601", Bar: &BarEvent{Open: 1, Close: 2}})
+	if err != nil || decision.Metrics["fills"] != 2 {
+		t.Fatalf("restored decision = %+v err=%v", decision, err)
+	}
+
+	recorder := StrategyInstance{InstanceID: "ckpt-rec", StrategyID: testNativeRecorderID}
+	if err := rt.StartInstance(ctx, StartInstanceRequest{Instance: recorder}); err != nil {
+		t.Fatalf("StartInstance(recorder) error = %v", err)
+	}
+	if snap, err := rt.SnapshotInstance(ctx, SnapshotInstanceRequest{InstanceID: recorder.InstanceID}); err != nil || snap.Supported {
+		t.Fatalf("recorder snapshot = %+v err=%v, want unsupported", snap, err)
+	}
+	if err := rt.StartInstance(ctx, StartInstanceRequest{Instance: recorder, Checkpoint: &StrategyCheckpoint{State: []byte("{}")}}); err == nil {
+		t.Fatal("restoring a strategy without checkpoint support should fail")
+	}
+}
+
+func TestCheckpointFingerprintIgnoresWarmupParams(t *testing.T) {
+	inst := StrategyInstance{StrategyID: "ma20.state_diagram_short", Symbols: []string{"RB2601", "ag2601"}, Timeframe: "1m", Params: map[string]any{"ma_period": 20}}
+	base := checkpointFingerprint(inst)
+
+	warm := inst
+	warm.Symbols = []string{"ag2601", "rb2601"}
+	warm.Params = map[string]any{"ma_period": 20, "warmup_bars": []any{1, 2}, "warmup_count": 2}
+	if checkpointFingerprint(warm) != base {
+		t.Fatal("warmup params and symbol order should not change the fingerprint")
+	}
+	changed := inst
+	changed.Params = map[string]any{"ma_period": 30}
+	if checkpointFingerprint(changed) == base {
+		t.Fatal("param change should invalidate the checkpoint")
+	}
+}
+
+func TestAdvanceCheckpointCursorEveryInterval(t *testing.T) {
+	m := &Manager{cfg: config.StrategyConfig{CheckpointIntervalBars: 3}}
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	var due []int
+	for i := 0; i < 7; i++ {
+		symbol := "rb2601"
+		if i%2 == 1 {
+			symbol = "AG2601"
+		}
+		if times, ok := m.advanceCheckpointCursor("inst-1", symbol, base.Add(time.Duration(i)*time.Minute)); ok {
+			due = append(due, i)
+			if i == 2 && (!times["rb2601"].Equal(base.Add(2*time.Minute)) || !times["ag2601"].Equal(base.Add(time.Minute))) {
+				t.Fatalf("symbol times = %v", times)
+			}
+		}
+	}
+	if len(due) != 2 || due[0] != 2 || due[1] != 5 {
+		t.Fatalf("checkpoints due at %v, want [2 5]", due)
+	}
+
+	m.cfg.CheckpointIntervalBars = -1
+	if _, ok := m.advanceCheckpointCursor("inst-1", "rb2601", base); ok {
+		t.Fatal("negative interval should disable checkpoints")
+	}
+}
+
+func TestStartFromCheckpointReplaysBarsWithoutExecuting(t *testing.T) {
+	m := &Manager{cfg: config.StrategyConfig{RequestTimeoutMS: 3000, CheckpointIntervalBars: 10}, exec: NewExecutionEngine(), native: NewNativeRuntime()}
+	inst := StrategyInstance{InstanceID: "ckpt-warm", StrategyID: NativeSampleMomentumID, Mode: RunTypeRealtime, Symbols: []string{"rb2601"}, Timeframe: "1m"}
+	state, _ := (&nativeSampleMomentum{fills: 3}).SnapshotState()
+	last := time.Date(2026, 3, 2, 9, 30, 0, 0, time.Local)
+	cp := StrategyCheckpoint{InstanceID: inst.InstanceID, StrategyID: inst.StrategyID, LastBarTime: last, SymbolBarTimes: map[string]time.Time{"rb2601": last}, State: state}
+	bars := []BarEvent{
+		{InstrumentID: "rb2601", AdjustedTime: last.Add(time.Minute), Open: 100, Close: 101},
+		{InstrumentID: "rb2601", AdjustedTime: last.Add(2 * time.Minute), Open: 101, Close: 99},
+	}
+
+	restore, err := m.startFromCheckpoint(context.Background(), m.native, inst, cp, bars)
+	if err != nil {
+		t.Fatalf("startFromCheckpoint() error = %v", err)
+	}
+	if restore.Replayed != 2 || !restore.LastBarTime.Equal(last) {
+		t.Fatalf("restore = %+v", restore)
+	}
+	if pos := m.exec.CurrentPosition("rb2601"); pos != 0 {
+		t.Fatalf("replayed bars should not execute, position = %v", pos)
+	}
+	decision, err := m.native.OnBar(context.Background(), DecisionRequest{Instance: inst, Symbol: "rb2601", Bar: &bars[1]})
+	if err != nil || decision.Metrics["fills"] != 3 {
+		t.Fatalf("restored state decision = %+v err=%v", decision, err)
+	}
+	m.checkpointMu.Lock()
+	cursor := m.checkpoints[inst.InstanceID]
+	m.checkpointMu.Unlock()
+	if cursor == nil || !cursor.symbolTimes["rb2601"].Equal(last.Add(2*time.Minute)) || cursor.bars != 0 {
+		t.Fatalf("cursor after replay = %+v", cursor)
+	}
+
+	m.dropCheckpoint(inst.InstanceID)
+	if _, ok := m.checkpoints[inst.InstanceID]; ok {
+		t.Fatal("dropCheckpoint should clear the cursor")
+	}
+}
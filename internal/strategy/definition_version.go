@@ -0,0 +1,222 @@
+// definition_version.go 负责策略定义的版本历史、实例与运行记录的版本固定，以及版本间参数结构对比。
+// 每次同步策略定义都把 (策略, 版本号, 代码摘要) 记入 strategy_definition_versions；
+// 实例启动、恢复或热重载时记下运行时实际加载的版本，运行记录首次保存时记下当时的版本，之后不再改写。
+package strategy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// nativeBuildRevision 是编译时写入的 VCS 修订号；Go 策略随二进制发布，代码变化体现在修订号上。
+var nativeBuildRevision = sync.OnceValue(func() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	revision, modified := "", ""
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return info.Main.Version
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+})
+
+// nativeCodeHash 对 Go 策略的构建修订号、版本号和默认参数做摘要。
+func nativeCodeHash(def StrategyDefinition) string {
+	raw, _ := json.Marshal(map[string]any{
+		"revision":       nativeBuildRevision(),
+		"strategy_id":    def.StrategyID,
+		"version":        def.Version,
+		"default_params": def.DefaultParams,
+	})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// rememberDefinitions 缓存最近一次同步到的定义，并写入版本历史；历史写入失败只记日志。
+func (m *Manager) rememberDefinitions(items []StrategyDefinition) error {
+	cache := make(map[string]StrategyDefinition, len(items))
+	for _, item := range items {
+		cache[item.StrategyID] = item
+	}
+	m.mu.Lock()
+	m.definitions = cache
+	m.mu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+	return m.store.RecordDefinitionVersions(items)
+}
+
+// definitionPin 返回策略当前可加载的版本号和代码摘要：Go 策略取编译期注册，Python 策略取最近一次同步结果。
+func (m *Manager) definitionPin(strategyID string) (string, string) {
+	if item, ok := lookupNativeStrategy(strategyID); ok {
+		return item.definition.Version, nativeCodeHash(item.definition)
+	}
+	m.mu.RLock()
+	def, ok := m.definitions[strategyID]
+	m.mu.RUnlock()
+	if ok {
+		return def.Version, def.CodeHash
+	}
+	if m.store == nil {
+		return "", ""
+	}
+	return m.store.definitionPin(strategyID)
+}
+
+// ListDefinitionVersions 返回策略的版本历史，按首次出现时间升序。
+func (m *Manager) ListDefinitionVersions(strategyID string) ([]StrategyDefinitionVersion, error) {
+	if m.store == nil {
+		return nil, fmt.Errorf("strategy store not configured")
+	}
+	items, err := m.store.ListDefinitionVersions(strings.TrimSpace(strategyID))
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []StrategyDefinitionVersion{}
+	}
+	return items, nil
+}
+
+// DiffDefinitionVersions 对比策略两个版本的参数结构。from/to 可以是版本号或代码摘要前缀；
+// to 为空时取最新版本，from 为空时取 to 之前的一个版本。
+func (m *Manager) DiffDefinitionVersions(strategyID string, from string, to string) (DefinitionDiff, error) {
+	items, err := m.ListDefinitionVersions(strategyID)
+	if err != nil {
+		return DefinitionDiff{}, err
+	}
+	if len(items) == 0 {
+		return DefinitionDiff{}, fmt.Errorf("strategy %s has no version history", strategyID)
+	}
+	toIndex := len(items) - 1
+	if strings.TrimSpace(to) != "" {
+		if toIndex = findDefinitionVersion(items, to); toIndex < 0 {
+			return DefinitionDiff{}, fmt.Errorf("strategy %s version %q not found", strategyID, to)
+		}
+	}
+	fromIndex := toIndex - 1
+	if strings.TrimSpace(from) != "" {
+		if fromIndex = findDefinitionVersion(items, from); fromIndex < 0 {
+			return DefinitionDiff{}, fmt.Errorf("strategy %s version %q not found", strategyID, from)
+		}
+	}
+	if fromIndex < 0 {
+		return DefinitionDiff{}, fmt.Errorf("strategy %s has no version before %s", strategyID, items[toIndex].Version)
+	}
+	return diffDefinitionVersions(items[fromIndex], items[toIndex]), nil
+}
+
+// findDefinitionVersion 按版本号精确匹配（同版本号多次出现时取最近一次），匹配不到时按代码摘要前缀匹配。
+func findDefinitionVersion(items []StrategyDefinitionVersion, ref string) int {
+	ref = strings.TrimSpace(ref)
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Version == ref {
+			return i
+		}
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].CodeHash != "" && strings.HasPrefix(items[i].CodeHash, strings.ToLower(ref)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// diffDefinitionVersions 计算两个版本默认参数的新增、删除和变化项，结果按参数键排序。
+func diffDefinitionVersions(from StrategyDefinitionVersion, to StrategyDefinitionVersion) DefinitionDiff {
+	diff := DefinitionDiff{
+		StrategyID:  to.StrategyID,
+		From:        from,
+		To:          to,
+		CodeChanged: from.CodeHash != to.CodeHash,
+		Added:       []ParamSchemaChange{},
+		Removed:     []ParamSchemaChange{},
+		Changed:     []ParamSchemaChange{},
+	}
+	before := flattenParamSchema("", from.DefaultParams, map[string]any{})
+	after := flattenParamSchema("", to.DefaultParams, map[string]any{})
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		oldValue, hadOld := before[key]
+		newValue, hasNew := after[key]
+		switch {
+		case !hadOld:
+			diff.Added = append(diff.Added, ParamSchemaChange{Key: key, To: newValue, ToType: paramTypeName(newValue)})
+		case !hasNew:
+			diff.Removed = append(diff.Removed, ParamSchemaChange{Key: key, From: oldValue, FromType: paramTypeName(oldValue)})
+		case !reflect.DeepEqual(oldValue, newValue):
+			diff.Changed = append(diff.Changed, ParamSchemaChange{
+				Key:      key,
+				From:     oldValue,
+				To:       newValue,
+				FromType: paramTypeName(oldValue),
+				ToType:   paramTypeName(newValue),
+			})
+		}
+	}
+	return diff
+}
+
+// flattenParamSchema 把嵌套参数展开成点号分隔的键；数组按整体比较，不逐项展开。
+func flattenParamSchema(prefix string, params map[string]any, out map[string]any) map[string]any {
+	for key, value := range params {
+		name := key
+		if prefix != "" {
+			name = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			flattenParamSchema(name, nested, out)
+			continue
+		}
+		out[name] = value
+	}
+	return out
+}
+
+func paramTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case float64, float32, int, int64, int32, uint, uint64, uint32, json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,116 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/config"
+)
+
+func TestDiffDefinitionVersionsReportsParamSchemaChanges(t *testing.T) {
+	items := []StrategyDefinitionVersion{
+		{StrategyID: "ma20.state", Version: "1.0.0", CodeHash: "aaa111", DefaultParams: map[string]any{
+			"ma_period": 20.0, "stop_atr": 2.0, "legacy": true, "risk": map[string]any{"max_lots": 1.0},
+		}},
+		{StrategyID: "ma20.state", Version: "1.1.0", CodeHash: "bbb222", DefaultParams: map[string]any{
+			"ma_period": "20", "stop_atr": 2.0, "take_atr": 3.0, "risk": map[string]any{"max_lots": 2.0},
+		}},
+	}
+	if got := findDefinitionVersion(items, "1.0.0"); got != 0 {
+		t.Fatalf("find by version = %d", got)
+	}
+	if got := findDefinitionVersion(items, "BBB2"); got != 1 {
+		t.Fatalf("find by hash prefix = %d", got)
+	}
+	if got := findDefinitionVersion(items, "2.0.0"); got != -1 {
+		t.Fatalf("find unknown = %d", got)
+	}
+
+	diff := diffDefinitionVersions(items[0], items[1])
+	if !diff.CodeChanged || diff.StrategyID != "ma20.state" {
+		t.Fatalf("diff header = %+v", diff)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Key != "take_atr" || diff.Added[0].ToType != "number" {
+		t.Fatalf("added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Key != "legacy" || diff.Removed[0].FromType != "bool" {
+		t.Fatalf("removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 2 || diff.Changed[0].Key != "ma_period" || diff.Changed[0].FromType != "number" || diff.Changed[0].ToType != "string" {
+		t.Fatalf("changed = %+v", diff.Changed)
+	}
+	if diff.Changed[1].Key != "risk.max_lots" || diff.Changed[1].From != 1.0 || diff.Changed[1].To != 2.0 {
+		t.Fatalf("nested change = %+v", diff.Changed[1])
+	}
+}
+
+func TestNativeDefinitionsCarryCodeHashAndPin(t *testing.T) {
+	var def StrategyDefinition
+	for _, item := range NativeDefinitions() {
+		if item.StrategyID == NativeSampleMomentumID {
+			def = item
+		}
+	}
+	if len(def.CodeHash) != 64 {
+		t.Fatalf("native code hash = %q", def.CodeHash)
+	}
+	m := &Manager{}
+	if version, hash := m.definitionPin(NativeSampleMomentumID); version != def.Version || hash != def.CodeHash {
+		t.Fatalf("definitionPin() = %q %q, want %q %q", version, hash, def.Version, def.CodeHash)
+	}
+	changed := def
+	changed.Version = def.Version + "-next"
+	if nativeCodeHash(changed) == def.CodeHash {
+		t.Fatal("version change should change the native code hash")
+	}
+}
+
+// reloadFailingRuntime 模拟新代码导入失败的运行时。
+type reloadFailingRuntime struct {
+	*NativeRuntime
+	stops int
+}
+
+func (r *reloadFailingRuntime) LoadStrategy(ctx context.Context, req LoadStrategyRequest) error {
+	if req.Reload {
+		return errors.New("syntax error in new strategy code")
+	}
+	return r.NativeRuntime.LoadStrategy(ctx, req)
+}
+
+func (r *reloadFailingRuntime) StopInstance(ctx context.Context, req StopInstanceRequest) error {
+	r.stops++
+	return r.NativeRuntime.StopInstance(ctx, req)
+}
+
+func TestPendingReloadKeepsOldInstanceWhenNewCodeFails(t *testing.T) {
+	rt := &reloadFailingRuntime{NativeRuntime: NewNativeRuntime()}
+	m := &Manager{cfg: config.StrategyConfig{RequestTimeoutMS: 3000}}
+	inst := StrategyInstance{InstanceID: "reload-1", StrategyID: NativeSampleMomentumID, Mode: RunTypeRealtime, Symbols: []string{"rb2601"}, Status: InstanceStatusRunning, DefinitionVersion: "1.0.0"}
+	if err := rt.StartInstance(context.Background(), StartInstanceRequest{Instance: inst}); err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+
+	if got, ok := m.applyPendingReload(inst, rt, time.Now()); !ok || got.DefinitionVersion != "1.0.0" {
+		t.Fatalf("no pending reload should pass through, got %+v ok=%v", got, ok)
+	}
+	m.reloads = map[string]time.Time{inst.InstanceID: time.Now()}
+	if !m.reloadPending(inst.InstanceID) {
+		t.Fatal("reload should be pending")
+	}
+	got, ok := m.applyPendingReload(inst, rt, time.Now())
+	if !ok || got.DefinitionVersion != "1.0.0" {
+		t.Fatalf("failed reload should keep the old version, got %+v ok=%v", got, ok)
+	}
+	if rt.stops != 0 {
+		t.Fatalf("old instance stopped %d times before the new code loaded", rt.stops)
+	}
+	if m.reloadPending(inst.InstanceID) {
+		t.Fatal("pending reload should be consumed")
+	}
+	if _, err := rt.OnBar(context.Background(), DecisionRequest{Instance: inst, Symbol: "rb2601", Bar: &BarEvent{Open: 1, Close: 2}}); err != nil {
+		t.Fatalf("old instance should keep running: %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package strategy
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type MarketEventSink interface {
 	HandleRealtimeTick(TickEvent)
@@ -45,6 +48,10 @@ func publishBar(ev BarEvent, replay bool) {
 		sink.HandleReplayBar(ev)
 		return
 	}
+	if ev.LatencyTraceID == "" {
+		ev.LatencyTraceID = newLatencyTraceID()
+	}
+	ev.PublishedAt = time.Now()
 	sink.HandleRealtimeBar(ev)
 }
 
@@ -5,6 +5,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"ctp-future-kline/internal/config"
 )
 
 type ExecutionEngine struct {
@@ -13,12 +15,21 @@ type ExecutionEngine struct {
 	subPositions map[strategySubPositionKey]float64
 	lastAuditAt  *time.Time
 	paused       bool
+	// rawTargets 是各实例请求的原始目标，subPositions 是按账户限额分配后的目标。
+	rawTargets    map[strategySubPositionKey]float64
+	accountLimits map[string]config.StrategyAccountLimit
+	risk          map[string]*instanceRiskState
+	marks         map[string]float64
+	riskDay       string
+	multiplier    func(symbol string) float64
+	multiples     map[string]float64
 }
 
 func NewExecutionEngine() *ExecutionEngine {
 	return &ExecutionEngine{
 		positions:    make(map[string]float64),
 		subPositions: make(map[strategySubPositionKey]float64),
+		rawTargets:   make(map[strategySubPositionKey]float64),
 	}
 }
 
@@ -42,6 +53,12 @@ type InstanceExecutionPlan struct {
 	InstanceTargetPosition  float64
 	NetCurrentTarget        float64
 	NetTargetPosition       float64
+	// RequestedTargetPosition 是策略给出的原始目标，InstanceTargetPosition 是账户限额分配后的目标。
+	RequestedTargetPosition float64
+	// AllocationScale 是账户限额对同合约实例目标的缩放比例，1 表示未缩放。
+	AllocationScale float64
+	// Breach 非空表示本次计划新触发了实例风险预算，实例已自动暂停。
+	Breach *RiskBreach
 }
 
 func (e *ExecutionEngine) CurrentPosition(symbol string) float64 {
@@ -69,16 +86,41 @@ func (e *ExecutionEngine) Plan(instance StrategyInstance, symbol string, target
 	return e.PlanWithCurrent(instance, current, target, mode)
 }
 
+// PlanInstanceTarget 为实例新目标生成净持仓订单计划；非回放模式下先检查实例风险预算，
+// 预算突破会把实例置为暂停，再按账户限额分配同合约各实例的目标。
 func (e *ExecutionEngine) PlanInstanceTarget(instance StrategyInstance, symbol string, target float64, mode string, currentPosition float64) InstanceExecutionPlan {
+	subKey := subPositionKey(instance, symbol, mode)
+	netKey := subPositionNetKey(subKey)
 	currentInstance, currentNet := e.instancePositionSnapshot(instance, symbol, mode)
-	netTarget := currentNet - currentInstance + target
+	instanceTarget, netTarget, scale := target, currentNet-currentInstance+target, 1.0
+	var breach *RiskBreach
+	var blockedReason string
+	if mode != RunTypeReplay {
+		e.mu.Lock()
+		breach, blockedReason = e.checkInstanceBudgetLocked(instance, symbol, target, currentInstance)
+		var allocated map[strategySubPositionKey]float64
+		allocated, scale = e.allocateLocked(netKey, &subKey, target)
+		e.mu.Unlock()
+		instanceTarget, netTarget = allocated[subKey], 0
+		for _, value := range allocated {
+			netTarget += value
+		}
+	}
 	plan := e.PlanWithCurrent(instance, currentPosition, netTarget, mode)
+	if blockedReason != "" && plan.RiskStatus == RiskStatusAllowed {
+		plan.RiskStatus = RiskStatusBlocked
+		plan.RiskReason = blockedReason
+		plan.OrderStatus = OrderStatusBlocked
+	}
 	return InstanceExecutionPlan{
 		Plan:                    plan,
 		InstanceCurrentPosition: currentInstance,
-		InstanceTargetPosition:  target,
+		InstanceTargetPosition:  instanceTarget,
 		NetCurrentTarget:        currentNet,
 		NetTargetPosition:       netTarget,
+		RequestedTargetPosition: target,
+		AllocationScale:         scale,
+		Breach:                  breach,
 	}
 }
 
@@ -129,24 +171,52 @@ func (e *ExecutionEngine) Apply(symbol string, plan ExecutionPlan) {
 	e.lastAuditAt = &now
 }
 
-func (e *ExecutionEngine) ApplyInstanceTarget(instance StrategyInstance, symbol string, target float64, mode string, plan ExecutionPlan) {
+// ApplyInstanceTarget 记录实例原始目标并按账户限额重新分配同合约子持仓，返回各实例的持仓变化。
+func (e *ExecutionEngine) ApplyInstanceTarget(instance StrategyInstance, symbol string, target float64, mode string, plan ExecutionPlan) map[string]float64 {
 	if plan.RiskStatus != RiskStatusAllowed {
-		return
+		return nil
 	}
 	if plan.OrderStatus != OrderStatusSimulated && plan.OrderStatus != OrderStatusNoop {
-		return
+		return nil
 	}
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	key := subPositionKey(instance, symbol, mode)
+	netKey := subPositionNetKey(key)
+	if e.rawTargets == nil {
+		e.rawTargets = make(map[strategySubPositionKey]float64)
+	}
 	if math.Abs(target) < 1e-9 {
-		delete(e.subPositions, key)
+		delete(e.rawTargets, key)
 	} else {
-		e.subPositions[key] = target
+		e.rawTargets[key] = target
+	}
+	allocated, _ := e.allocateLocked(netKey, nil, 0)
+	deltas := make(map[string]float64)
+	for k, old := range e.subPositions {
+		if _, ok := allocated[k]; !ok && subPositionNetKey(k) == netKey {
+			delete(e.subPositions, k)
+			deltas[k.InstanceID] -= old
+		}
+	}
+	for k, value := range allocated {
+		old := e.subPositions[k]
+		if math.Abs(value) < 1e-9 {
+			delete(e.subPositions, k)
+		} else {
+			e.subPositions[k] = value
+		}
+		if value != old {
+			deltas[k.InstanceID] += value - old
+		}
+	}
+	if state := e.risk[key.InstanceID]; state != nil && plan.OrderStatus == OrderStatusSimulated {
+		state.orders++
 	}
 	e.positions[symbol] = plan.TargetPosition
 	now := time.Now()
 	e.lastAuditAt = &now
+	return deltas
 }
 
 func (e *ExecutionEngine) Status() OrdersStatus {
@@ -161,10 +231,29 @@ func (e *ExecutionEngine) Status() OrdersStatus {
 		AutoExecutionPaused: e.paused,
 		Positions:           positions,
 		LastAuditAt:         e.lastAuditAt,
+		PausedInstances:     e.pausedInstancesLocked(),
 		UpdatedAt:           time.Now(),
 	}
 }
 
+// SubPositions 返回所有非零的实例子持仓快照。
+func (e *ExecutionEngine) SubPositions() []StrategySubPosition {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]StrategySubPosition, 0, len(e.subPositions))
+	for key, value := range e.subPositions {
+		out = append(out, StrategySubPosition{
+			Mode:       key.Mode,
+			AccountID:  key.AccountID,
+			Symbol:     key.Symbol,
+			Timeframe:  key.Timeframe,
+			InstanceID: key.InstanceID,
+			Position:   value,
+		})
+	}
+	return out
+}
+
 func (e *ExecutionEngine) instancePositionSnapshot(instance StrategyInstance, symbol string, mode string) (float64, float64) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
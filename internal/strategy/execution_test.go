@@ -148,7 +148,7 @@ func TestSubmitExternalOrderFailureBlocksPlan(t *testing.T) {
 	result := m.submitExternalOrderIfNeeded(StrategyInstance{InstanceID: "inst-1"}, "rb2601", RunTypeRealtime, time.Now(), SignalDecision{
 		TargetPosition: -1,
 		Reason:         "test signal",
-	}, &plan)
+	}, &plan, "", 0)
 
 	if plan.RiskStatus != RiskStatusBlocked || plan.OrderStatus != OrderStatusBlocked {
 		t.Fatalf("plan after submit failure = %+v, want blocked", plan)
@@ -164,7 +164,7 @@ func TestSubmitExternalOrderFailureBlocksPlan(t *testing.T) {
 
 type failingStrategyOrderExecutor struct{}
 
-func (f failingStrategyOrderExecutor) CurrentPosition(string) (float64, error) {
+func (f failingStrategyOrderExecutor) CurrentPosition(string, string) (float64, error) {
 	return 0, nil
 }
 
@@ -0,0 +1,180 @@
+// hot_reload.go 负责运行中策略实例的热重载。
+// 请求重载时实例只被标记，切换发生在该实例下一根 K 线分发之前，即 K 线边界：
+// 先导出旧代码的实例状态，让运行时重新导入策略代码，再停止旧实例、用导出的状态启动新版本；
+// 新代码导入失败时旧实例继续运行，状态恢复失败时回落到完整 warmup 启动。完成后实例固定到新的版本号和代码摘要。
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+// ReloadInstance 标记运行中的实例在下一根 K 线边界热重载。
+func (m *Manager) ReloadInstance(instanceID string) (StrategyInstance, error) {
+	inst, err := m.store.GetInstance(instanceID)
+	if err != nil {
+		return inst, err
+	}
+	if inst.Status != InstanceStatusRunning {
+		return inst, fmt.Errorf("strategy instance %s is not running", inst.InstanceID)
+	}
+	m.reloadMu.Lock()
+	if m.reloads == nil {
+		m.reloads = make(map[string]time.Time)
+	}
+	m.reloads[inst.InstanceID] = time.Now()
+	m.reloadMu.Unlock()
+	inst.ReloadPending = true
+	logger.Info("strategy instance hot reload requested", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "definition_version", inst.DefinitionVersion)
+	m.broadcast("strategy_status_update", m.Status())
+	return inst, nil
+}
+
+func (m *Manager) reloadPending(instanceID string) bool {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	_, ok := m.reloads[instanceID]
+	return ok
+}
+
+func (m *Manager) clearPendingReload(instanceID string) {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	delete(m.reloads, instanceID)
+}
+
+func (m *Manager) takePendingReload(instanceID string) bool {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	if _, ok := m.reloads[instanceID]; !ok {
+		return false
+	}
+	delete(m.reloads, instanceID)
+	return true
+}
+
+// applyPendingReload 在 K 线分发前执行挂起的热重载，返回之后应使用的实例；
+// 返回 false 表示实例已无法继续运行，本根 K 线不再分发。
+func (m *Manager) applyPendingReload(inst StrategyInstance, client strategyRuntime, barTime time.Time) (StrategyInstance, bool) {
+	if !m.takePendingReload(inst.InstanceID) {
+		return inst, true
+	}
+	reloaded, err := m.reloadRuntimeInstance(inst, client, barTime)
+	if err != nil {
+		logger.Warn("strategy instance hot reload failed", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "error", err)
+		m.setInstanceError(inst.InstanceID, fmt.Errorf("hot reload failed: %w", err))
+		return inst, false
+	}
+	return reloaded, true
+}
+
+// reloadRuntimeInstance 按“导出状态、重新导入、停止、恢复启动”的顺序切换到新代码。
+// 返回的 error 表示旧实例已经停止而新实例没有起来。
+func (m *Manager) reloadRuntimeInstance(inst StrategyInstance, client strategyRuntime, barTime time.Time) (StrategyInstance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.runtimeStartTimeout())
+	defer cancel()
+	var state []byte
+	if checkpointer, ok := client.(runtimeCheckpointer); ok {
+		resp, err := checkpointer.SnapshotInstance(ctx, SnapshotInstanceRequest{InstanceID: inst.InstanceID, Mode: inst.Mode})
+		if err != nil {
+			logger.Warn("strategy snapshot before hot reload failed; new version will warm up", "instance_id", inst.InstanceID, "error", err)
+		} else if resp.Supported {
+			state = resp.State
+		}
+	}
+	if err := client.LoadStrategy(ctx, LoadStrategyRequest{StrategyID: inst.StrategyID, Reload: true}); err != nil {
+		// 新代码导入失败时运行时仍持有旧实例，保持运行并记录失败原因。
+		m.persistReloadTrace(inst, inst, barTime, false, err)
+		return inst, nil
+	}
+	if !IsNativeStrategyID(inst.StrategyID) {
+		if _, err := m.syncDefinitions(); err != nil {
+			logger.Warn("strategy definition sync after hot reload failed", "instance_id", inst.InstanceID, "error", err)
+		}
+	}
+	if err := client.StopInstance(ctx, StopInstanceRequest{InstanceID: inst.InstanceID}); err != nil {
+		return inst, err
+	}
+	restored := false
+	if len(state) > 0 {
+		cp := StrategyCheckpoint{
+			InstanceID:     inst.InstanceID,
+			StrategyID:     inst.StrategyID,
+			Mode:           inst.Mode,
+			Fingerprint:    checkpointFingerprint(inst),
+			SymbolBarTimes: m.checkpointSymbolTimes(inst.InstanceID),
+			State:          state,
+			UpdatedAt:      time.Now(),
+		}
+		if _, err := m.startFromCheckpoint(ctx, client, inst, cp, nil); err != nil {
+			logger.Warn("strategy state restore after hot reload failed; fallback to warmup start", "instance_id", inst.InstanceID, "error", err)
+		} else {
+			restored = true
+		}
+	}
+	if !restored {
+		if _, err := m.startRuntimeInstance(ctx, client, inst); err != nil {
+			return inst, err
+		}
+	}
+	next := inst
+	next.DefinitionVersion, next.CodeHash = m.definitionPin(inst.StrategyID)
+	now := time.Now()
+	next.LastStartedAt = &now
+	next.LastError = ""
+	if err := m.store.SaveInstance(next); err != nil {
+		return inst, err
+	}
+	m.mu.Lock()
+	m.instances[next.InstanceID] = next
+	m.mu.Unlock()
+	logger.Info("strategy instance hot reloaded",
+		"instance_id", next.InstanceID,
+		"strategy_id", next.StrategyID,
+		"from_version", inst.DefinitionVersion,
+		"to_version", next.DefinitionVersion,
+		"state_restored", restored,
+	)
+	m.persistReloadTrace(inst, next, barTime, restored, nil)
+	m.broadcast("strategy_status_update", m.Status())
+	return next, nil
+}
+
+// checkpointSymbolTimes 返回实例各合约已处理的最后 K 线时间副本。
+func (m *Manager) checkpointSymbolTimes(instanceID string) map[string]time.Time {
+	m.checkpointMu.Lock()
+	defer m.checkpointMu.Unlock()
+	cursor := m.checkpoints[instanceID]
+	if cursor == nil {
+		return nil
+	}
+	out := make(map[string]time.Time, len(cursor.symbolTimes))
+	for key, value := range cursor.symbolTimes {
+		out[key] = value
+	}
+	return out
+}
+
+func (m *Manager) persistReloadTrace(from StrategyInstance, to StrategyInstance, barTime time.Time, restored bool, reloadErr error) {
+	status, reason := "done", "strategy code reloaded at bar boundary"
+	if reloadErr != nil {
+		status, reason = "failed", "strategy code reload failed, previous version keeps running: "+reloadErr.Error()
+	}
+	m.persistTrace(to, firstSymbol(to.Symbols), to.Mode, barTime, StrategyTraceRecord{
+		EventType: "hot_reload",
+		StepKey:   "hot_reload",
+		StepLabel: "策略热重载",
+		Status:    status,
+		Reason:    reason,
+		Metrics: map[string]any{
+			"from_version":   from.DefinitionVersion,
+			"from_code_hash": from.CodeHash,
+			"to_version":     to.DefinitionVersion,
+			"to_code_hash":   to.CodeHash,
+			"state_restored": restored,
+		},
+	})
+}
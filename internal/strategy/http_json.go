@@ -35,11 +35,29 @@ type ListStrategiesResponse struct {
 type LoadStrategyRequest struct {
 	// StrategyID 是待加载策略定义 ID。
 	StrategyID string `json:"strategy_id"`
+	// Reload 为 true 时运行时重新导入策略代码再注册，供实例热重载使用。
+	Reload bool `json:"reload,omitempty"`
 }
 
 type StartInstanceRequest struct {
 	// Instance 是待启动的策略实例配置。
 	Instance StrategyInstance `json:"instance"`
+	// Checkpoint 非空时运行时用检查点状态恢复实例，而不是从 warmup_bars 重新推导。
+	Checkpoint *StrategyCheckpoint `json:"checkpoint,omitempty"`
+}
+
+type SnapshotInstanceRequest struct {
+	// InstanceID 是待导出状态的策略实例 ID。
+	InstanceID string `json:"instance_id"`
+	// Mode 是实例运行模式，Python 按 mode+instance_id 定位运行实例。
+	Mode string `json:"mode"`
+}
+
+type SnapshotInstanceResponse struct {
+	// Supported 表示策略是否支持状态检查点；不支持时 State 为空。
+	Supported bool `json:"supported"`
+	// State 是策略导出的不透明状态。
+	State []byte `json:"state,omitempty"`
 }
 
 type StartRequirementsRequest struct {
@@ -99,6 +117,8 @@ type SignalDecision struct {
 	Metrics map[string]any `json:"metrics"`
 	// Trace 是本次策略判断过程快照。无信号时也可返回，用于策略执行可视化。
 	Trace *StrategyTraceRecord `json:"trace,omitempty"`
+	// Execution 是可选的算法执行方式，大额调仓可要求 TWAP/VWAP/冰山拆单。
+	Execution *ExecutionStyle `json:"execution,omitempty"`
 }
 
 type BacktestRequest struct {
@@ -147,6 +167,40 @@ type ParameterSweepRequest struct {
 	StartTime string `json:"start_time"`
 	// EndTime 是优化结束时间。
 	EndTime string `json:"end_time"`
+	// Engine 是优化执行方，python 表示转发给 Python 服务，默认使用 Go 优化器。
+	Engine string `json:"engine,omitempty"`
+	// RunID 是优化任务 ID，为空时自动生成。
+	RunID string `json:"run_id,omitempty"`
+	// Symbols 是组合优化的合约列表，为空时使用 Symbol。
+	Symbols []string `json:"symbols,omitempty"`
+	// BaseParams 是每个试验共用的固定参数，Grid 中的同名参数会覆盖它。
+	BaseParams map[string]any `json:"base_params,omitempty"`
+	// Method 是搜索方式：grid、random 或 bayesian，默认 grid。
+	Method string `json:"method,omitempty"`
+	// MaxTrials 是每个样本内窗口的试验上限，random/bayesian 必填，grid 下用于拒绝过大的网格。
+	MaxTrials int `json:"max_trials,omitempty"`
+	// Seed 是随机搜索种子，为 0 时在创建任务时生成并随任务保存，保证续跑时候选序列一致。
+	Seed int64 `json:"seed,omitempty"`
+	// Objective 是排序目标：sharpe、sortino、calmar、profit_factor、max_drawdown、net_profit、return 或 win_rate，默认 sharpe。
+	Objective string `json:"objective,omitempty"`
+	// Workers 是并行回测数，默认 1。
+	Workers int `json:"workers,omitempty"`
+	// WalkForward 是滚动样本内/样本外窗口设置，为空时整段区间只做样本内优化。
+	WalkForward *WalkForwardConfig `json:"walk_forward,omitempty"`
+	// InitialBalance 是每个试验的初始权益。
+	InitialBalance float64 `json:"initial_balance,omitempty"`
+	// HeatmapParams 是稳健性热力图的两个参数名，为空时取网格中前两个参数。
+	HeatmapParams []string `json:"heatmap_params,omitempty"`
+}
+
+// WalkForwardConfig 描述滚动优化的窗口切分方式。
+type WalkForwardConfig struct {
+	// Windows 是窗口数量。
+	Windows int `json:"windows"`
+	// OutOfSampleRatio 是每个窗口中样本外区间的占比，默认 0.25。
+	OutOfSampleRatio float64 `json:"out_of_sample_ratio,omitempty"`
+	// Anchored 为 true 时样本内区间始终从整体起点开始（扩张窗口），否则随窗口滚动。
+	Anchored bool `json:"anchored,omitempty"`
 }
 
 type ParameterSweepResponse struct {
@@ -161,6 +215,8 @@ type ParameterSweepResponse struct {
 type StrategyServiceClient struct {
 	baseURL    string
 	httpClient *http.Client
+	// stream 是可选的 WebSocket 流通道；为空或未连接时行情事件走 HTTP push/poll。
+	stream *StrategyStream
 }
 
 const strategyHTTPIdleConnTimeout = time.Second
@@ -201,6 +257,30 @@ func NewStrategyServiceClient(addr string, httpClient *http.Client) *StrategySer
 	return &StrategyServiceClient{baseURL: normalizeStrategyHTTPBaseURL(addr), httpClient: httpClient}
 }
 
+// EnableStream 为客户端开启 WebSocket 流通道，heartbeat 为心跳周期。
+func (c *StrategyServiceClient) EnableStream(heartbeat time.Duration) {
+	if c == nil || c.stream != nil {
+		return
+	}
+	c.stream = NewStrategyStream(c.baseURL, heartbeat)
+}
+
+// Stream 返回流通道，未开启时为 nil。
+func (c *StrategyServiceClient) Stream() *StrategyStream {
+	if c == nil {
+		return nil
+	}
+	return c.stream
+}
+
+// Close 关闭流通道；HTTP 连接由 Transport 自行回收。
+func (c *StrategyServiceClient) Close() error {
+	if c == nil || c.stream == nil {
+		return nil
+	}
+	return c.stream.Close()
+}
+
 func newStrategyHTTPClient() *http.Client {
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	// Uvicorn closes idle HTTP/1.1 connections after 5s by default. Replay bars
@@ -262,18 +342,25 @@ func (c *StrategyServiceClient) StopInstance(ctx context.Context, req StopInstan
 	return err
 }
 
+// SnapshotInstance 导出 Python 实例的状态检查点。
+func (c *StrategyServiceClient) SnapshotInstance(ctx context.Context, req SnapshotInstanceRequest) (SnapshotInstanceResponse, error) {
+	var out SnapshotInstanceResponse
+	err := c.postJSON(ctx, "/runtime/snapshot", req, &out)
+	return out, err
+}
+
 func (c *StrategyServiceClient) OnTick(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
-	return c.pushAndWait(ctx, "/runtime/on_tick", req)
+	return c.decide(ctx, "OnTick", "/runtime/on_tick", req)
 }
 
 func (c *StrategyServiceClient) OnBar(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
-	decision, err := c.pushAndWait(ctx, "/runtime/on_bar", req)
+	decision, err := c.decide(ctx, "OnBar", "/runtime/on_bar", req)
 	logger.Info("OnBar call", "req", req, "out", decision, "err", err)
 	return decision, err
 }
 
 func (c *StrategyServiceClient) OnReplayBar(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
-	decision, err := c.pushAndWait(ctx, "/runtime/on_replay_bar", req)
+	decision, err := c.decide(ctx, "OnReplayBar", "/runtime/on_replay_bar", req)
 	logger.Info("OnReplayBar call", "req", req, "out", decision, "err", err)
 	return decision, err
 }
@@ -299,6 +386,17 @@ func (c *StrategyServiceClient) RunParameterSweep(ctx context.Context, req Param
 	return out, err
 }
 
+// decide 优先走流通道；只有流未连接（事件尚未发出）时才回落到 HTTP，避免同一事件被执行两次。
+func (c *StrategyServiceClient) decide(ctx context.Context, method string, path string, req DecisionRequest) (SignalDecision, error) {
+	if c != nil && c.stream != nil {
+		decision, err := c.stream.Decide(ctx, method, req)
+		if !errors.Is(err, errStreamUnavailable) {
+			return decision, err
+		}
+	}
+	return c.pushAndWait(ctx, path, req)
+}
+
 func (c *StrategyServiceClient) pushAndWait(ctx context.Context, path string, req DecisionRequest) (SignalDecision, error) {
 	var push asyncPushResponse
 	if err := c.postJSON(ctx, path, req, &push); err != nil {
@@ -0,0 +1,423 @@
+// latency.go 负责实盘 bar 从行情分片封口到柜台成交回报的决策链路延迟追踪。
+// 分发入口给每根实盘 bar 分配追踪 ID，被它触发的每个实例各自形成一个 span，依次记下
+// 封口、进入分发、策略调用、下单提交、报单回报（OnRtnOrder）和成交回报（OnRtnTrade）的时间。
+// span 在决策结束时写入 strategy_latency_spans，下过单的 span 收到柜台回报后再更新同一条记录；
+// 各区间最近若干次耗时保存在滑动窗口里，分位数随策略状态一起返回。
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	// LatencyStageBarClosed 是行情分片封口 bar 的时间。
+	LatencyStageBarClosed = "bar_closed"
+	// LatencyStagePublished 是 bar 进入策略分发的时间。
+	LatencyStagePublished = "published"
+	// LatencyStageStrategyCall 是发起策略决策调用的时间。
+	LatencyStageStrategyCall = "strategy_call"
+	// LatencyStageStrategyReturn 是策略决策返回的时间。
+	LatencyStageStrategyReturn = "strategy_return"
+	// LatencyStageOrderSubmit 是开始向执行器提交委托的时间。
+	LatencyStageOrderSubmit = "order_submit"
+	// LatencyStageOrderSubmitted 是执行器接受委托的时间。
+	LatencyStageOrderSubmitted = "order_submitted"
+	// LatencyStageBrokerOrder 是收到第一条柜台报单回报的时间。
+	LatencyStageBrokerOrder = "broker_order"
+	// LatencyStageBrokerTrade 是收到第一条柜台成交回报的时间。
+	LatencyStageBrokerTrade = "broker_trade"
+)
+
+const (
+	// latencyWindowSize 是每个区间保留用于计算分位数的最近样本数。
+	latencyWindowSize = 1024
+	// latencyOpenTTL 是下单后等待柜台回报的最长时间，超时的 span 不再更新。
+	latencyOpenTTL = 10 * time.Minute
+)
+
+// latencySegments 是参与分位数统计的区间，按链路顺序排列。
+var latencySegments = []struct {
+	name string
+	from string
+	to   string
+}{
+	{"publish", LatencyStageBarClosed, LatencyStagePublished},
+	{"dispatch", LatencyStagePublished, LatencyStageStrategyCall},
+	{"strategy", LatencyStageStrategyCall, LatencyStageStrategyReturn},
+	{"decision", LatencyStageStrategyReturn, LatencyStageOrderSubmit},
+	{"order_submit", LatencyStageOrderSubmit, LatencyStageOrderSubmitted},
+	{"broker_ack", LatencyStageOrderSubmitted, LatencyStageBrokerOrder},
+	{"broker_fill", LatencyStageOrderSubmitted, LatencyStageBrokerTrade},
+}
+
+var latencyTraceSeq atomic.Uint64
+
+// newLatencyTraceID 生成按时间有序的延迟追踪 ID。
+func newLatencyTraceID() string {
+	return fmt.Sprintf("lt-%s-%08x", time.Now().UTC().Format("20060102T150405.000000"), latencyTraceSeq.Add(1))
+}
+
+func latencySpanKey(traceID string, instanceID string) string {
+	if strings.TrimSpace(traceID) == "" || strings.TrimSpace(instanceID) == "" {
+		return ""
+	}
+	return traceID + "/" + instanceID
+}
+
+// latencyOrderKey 是报单索引键。报单引用只在单个账户（单条交易会话）内唯一，多账户并行下单时必须带上账户区分。
+func latencyOrderKey(accountID string, orderRef string) string {
+	orderRef = strings.TrimSpace(orderRef)
+	if orderRef == "" {
+		return ""
+	}
+	return strings.TrimSpace(accountID) + "/" + orderRef
+}
+
+// latencySpanState 是追踪中的 span 及其已计入窗口的区间。
+type latencySpanState struct {
+	span     LatencySpan
+	recorded map[string]bool
+	// orderKeys 是 span 绑定的报单索引键，auto_close 拆单时有多个，未下单时为空。
+	orderKeys []string
+}
+
+// latencyTracker 维护进行中的 span、报单引用索引和各区间的耗时窗口；零值可用。
+type latencyTracker struct {
+	mu   sync.Mutex
+	open map[string]*latencySpanState
+	// orders 以账户和报单引用为键索引 span。
+	orders map[string]string
+	// early 缓存报单引用还没绑定时就先到达的柜台回报，绑定时补记。
+	early   map[string]map[string]time.Time
+	windows map[string][]float64
+}
+
+// begin 为带追踪 ID 的 bar 创建实例的 span，返回 span 键；bar 没有追踪 ID 时返回空串。
+func (t *latencyTracker) begin(inst StrategyInstance, symbol string, mode string, bar BarEvent) string {
+	key := latencySpanKey(bar.LatencyTraceID, inst.InstanceID)
+	if key == "" {
+		return ""
+	}
+	now := time.Now()
+	span := LatencySpan{
+		TraceID:    bar.LatencyTraceID,
+		InstanceID: inst.InstanceID,
+		StrategyID: inst.StrategyID,
+		Symbol:     symbol,
+		Mode:       mode,
+		EventTime:  strategyBarEventTime(bar),
+		Stages:     make(map[string]time.Time, len(latencySegments)+1),
+		UpdatedAt:  now,
+	}
+	if !bar.ClosedAt.IsZero() {
+		span.Stages[LatencyStageBarClosed] = bar.ClosedAt
+	}
+	if !bar.PublishedAt.IsZero() {
+		span.Stages[LatencyStagePublished] = bar.PublishedAt
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sweepLocked(now)
+	if t.open == nil {
+		t.open = make(map[string]*latencySpanState)
+	}
+	t.open[key] = &latencySpanState{span: span, recorded: make(map[string]bool)}
+	return key
+}
+
+// stamp 记下阶段时间；同一阶段只记第一次。
+func (t *latencyTracker) stamp(key string, stage string, at time.Time) {
+	if key == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state := t.open[key]; state != nil {
+		stampLatencyStage(state, stage, at)
+	}
+}
+
+// bindOrder 把账户下的报单引用关联到 span，并补记绑定前已经到达的柜台回报。
+// 一次下单拆成多笔时传入全部报单引用，任一笔的回报都会计入同一个 span。
+func (t *latencyTracker) bindOrder(key string, accountID string, orderRefs ...string) {
+	if key == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.open[key]
+	if state == nil {
+		return
+	}
+	for _, orderRef := range orderRefs {
+		orderKey := latencyOrderKey(accountID, orderRef)
+		if orderKey == "" || slices.Contains(state.orderKeys, orderKey) {
+			continue
+		}
+		if state.span.OrderRef == "" {
+			state.span.OrderRef = strings.TrimSpace(orderRef)
+		}
+		state.orderKeys = append(state.orderKeys, orderKey)
+		if t.orders == nil {
+			t.orders = make(map[string]string)
+		}
+		t.orders[orderKey] = key
+		for stage, at := range t.early[orderKey] {
+			stampLatencyStage(state, stage, at)
+		}
+		delete(t.early, orderKey)
+	}
+}
+
+// finishDecision 在一次决策处理完后结算 span。已下单的 span 继续等待柜台回报，其余 span 就此结束。
+func (t *latencyTracker) finishDecision(key string) (LatencySpan, bool) {
+	if key == "" {
+		return LatencySpan{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.open[key]
+	if state == nil {
+		return LatencySpan{}, false
+	}
+	_, traded := state.span.Stages[LatencyStageBrokerTrade]
+	if state.span.OrderRef == "" || traded {
+		t.completeLocked(key, state)
+	} else {
+		t.settleLocked(state)
+	}
+	return cloneLatencySpan(state.span), true
+}
+
+// brokerEvent 记下柜台回报阶段并返回需要持久化的 span；账户下的报单引用还未绑定时先缓存。
+func (t *latencyTracker) brokerEvent(accountID string, orderRef string, stage string, at time.Time) (LatencySpan, bool) {
+	orderKey := latencyOrderKey(accountID, orderRef)
+	if orderKey == "" {
+		return LatencySpan{}, false
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key, ok := t.orders[orderKey]
+	if !ok {
+		if t.early == nil {
+			t.early = make(map[string]map[string]time.Time)
+		}
+		if t.early[orderKey] == nil {
+			t.early[orderKey] = make(map[string]time.Time, 2)
+		}
+		if _, seen := t.early[orderKey][stage]; !seen {
+			t.early[orderKey][stage] = at
+		}
+		return LatencySpan{}, false
+	}
+	state := t.open[key]
+	if state == nil {
+		delete(t.orders, orderKey)
+		return LatencySpan{}, false
+	}
+	if !stampLatencyStage(state, stage, at) {
+		return LatencySpan{}, false
+	}
+	if stage == LatencyStageBrokerTrade {
+		t.completeLocked(key, state)
+	} else {
+		t.settleLocked(state)
+	}
+	return cloneLatencySpan(state.span), true
+}
+
+// percentiles 返回各区间滑动窗口的分位数，没有样本的区间不出现。
+func (t *latencyTracker) percentiles() map[string]LatencyPercentiles {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.windows) == 0 {
+		return nil
+	}
+	out := make(map[string]LatencyPercentiles, len(t.windows))
+	for name, samples := range t.windows {
+		if len(samples) == 0 {
+			continue
+		}
+		sorted := append([]float64(nil), samples...)
+		sort.Float64s(sorted)
+		out[name] = LatencyPercentiles{
+			Count: len(sorted),
+			P50:   latencyPercentile(sorted, 0.50),
+			P90:   latencyPercentile(sorted, 0.90),
+			P99:   latencyPercentile(sorted, 0.99),
+			Max:   sorted[len(sorted)-1],
+		}
+	}
+	return out
+}
+
+// settleLocked 重新计算区间耗时，并把新出现的区间计入窗口。
+func (t *latencyTracker) settleLocked(state *latencySpanState) {
+	span := &state.span
+	span.SegmentsMS = make(map[string]float64, len(latencySegments))
+	for _, seg := range latencySegments {
+		from, okFrom := span.Stages[seg.from]
+		to, okTo := span.Stages[seg.to]
+		if !okFrom || !okTo {
+			continue
+		}
+		ms := latencyMillis(to.Sub(from))
+		span.SegmentsMS[seg.name] = ms
+		if !state.recorded[seg.name] {
+			state.recorded[seg.name] = true
+			t.recordLocked(seg.name, ms)
+		}
+	}
+	var first, last time.Time
+	for _, at := range span.Stages {
+		if first.IsZero() || at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	if !first.IsZero() {
+		span.TotalMS = latencyMillis(last.Sub(first))
+	}
+}
+
+// completeLocked 结束 span：计入总耗时窗口并移出追踪。
+func (t *latencyTracker) completeLocked(key string, state *latencySpanState) {
+	t.settleLocked(state)
+	state.span.Complete = true
+	if !state.recorded["total"] {
+		state.recorded["total"] = true
+		t.recordLocked("total", state.span.TotalMS)
+	}
+	delete(t.open, key)
+	for _, orderKey := range state.orderKeys {
+		delete(t.orders, orderKey)
+	}
+}
+
+func (t *latencyTracker) recordLocked(name string, ms float64) {
+	if t.windows == nil {
+		t.windows = make(map[string][]float64)
+	}
+	samples := append(t.windows[name], ms)
+	if len(samples) > latencyWindowSize {
+		samples = samples[len(samples)-latencyWindowSize:]
+	}
+	t.windows[name] = samples
+}
+
+// sweepLocked 丢弃等待柜台回报超时的 span 和无人认领的早到回报。
+func (t *latencyTracker) sweepLocked(now time.Time) {
+	for key, state := range t.open {
+		if now.Sub(state.span.UpdatedAt) > latencyOpenTTL {
+			delete(t.open, key)
+			for _, orderKey := range state.orderKeys {
+				delete(t.orders, orderKey)
+			}
+		}
+	}
+	for orderKey, stages := range t.early {
+		expired := true
+		for _, at := range stages {
+			if now.Sub(at) <= latencyOpenTTL {
+				expired = false
+			}
+		}
+		if expired {
+			delete(t.early, orderKey)
+		}
+	}
+}
+
+func stampLatencyStage(state *latencySpanState, stage string, at time.Time) bool {
+	if _, ok := state.span.Stages[stage]; ok {
+		return false
+	}
+	state.span.Stages[stage] = at
+	state.span.UpdatedAt = time.Now()
+	return true
+}
+
+func cloneLatencySpan(span LatencySpan) LatencySpan {
+	stages := make(map[string]time.Time, len(span.Stages))
+	for key, value := range span.Stages {
+		stages[key] = value
+	}
+	segments := make(map[string]float64, len(span.SegmentsMS))
+	for key, value := range span.SegmentsMS {
+		segments[key] = value
+	}
+	span.Stages = stages
+	span.SegmentsMS = segments
+	return span
+}
+
+func latencyMillis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// latencyPercentile 按最近秩法取已排序样本的分位数。
+func latencyPercentile(sorted []float64, p float64) float64 {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// finishLatencySpan 结算一次决策的 span 并持久化。
+func (m *Manager) finishLatencySpan(key string) {
+	if span, ok := m.latency.finishDecision(key); ok {
+		m.saveLatencySpan(span)
+	}
+}
+
+// HandleOrderUpdate 接收策略委托的柜台报单回报，记入对应决策的延迟 span。
+func (m *Manager) HandleOrderUpdate(ev OrderUpdateEvent) {
+	if m == nil || strings.TrimSpace(ev.InstanceID) == "" {
+		return
+	}
+	if span, ok := m.latency.brokerEvent(ev.AccountID, ev.OrderRef, LatencyStageBrokerOrder, ev.ReceivedAt); ok {
+		m.saveLatencySpan(span)
+	}
+}
+
+func (m *Manager) saveLatencySpan(span LatencySpan) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveLatencySpan(span); err != nil {
+		logger.Warn("strategy latency span persist failed", "latency_trace_id", span.TraceID, "instance_id", span.InstanceID, "error", err)
+	}
+}
+
+// ListLatencySpans 返回最近的决策链路延迟 span。
+func (m *Manager) ListLatencySpans(instanceID string, limit int) ([]LatencySpan, error) {
+	if m.store == nil {
+		return nil, fmt.Errorf("strategy store not configured")
+	}
+	items, err := m.store.ListLatencySpans(strings.TrimSpace(instanceID), limit)
+	if err != nil {
+		return nil, err
+	}
+	if items == nil {
+		items = []LatencySpan{}
+	}
+	return items, nil
+}
@@ -0,0 +1,119 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTrackerFollowsDecisionToBrokerFill(t *testing.T) {
+	var tracker latencyTracker
+	base := time.Date(2026, 3, 2, 9, 31, 0, 0, time.Local)
+	inst := StrategyInstance{InstanceID: "inst-1", StrategyID: "ma20.state"}
+	bar := BarEvent{InstrumentID: "rb2601", AdjustedTime: base.Add(-time.Minute), LatencyTraceID: "lt-1", ClosedAt: base, PublishedAt: base.Add(2 * time.Millisecond)}
+
+	if key := tracker.begin(inst, "rb2601", RunTypeRealtime, BarEvent{}); key != "" {
+		t.Fatalf("bar without trace id should not open a span, got %q", key)
+	}
+	key := tracker.begin(inst, "rb2601", RunTypeRealtime, bar)
+	tracker.stamp(key, LatencyStageStrategyCall, base.Add(3*time.Millisecond))
+	tracker.stamp(key, LatencyStageStrategyReturn, base.Add(13*time.Millisecond))
+	tracker.stamp(key, LatencyStageStrategyReturn, base.Add(99*time.Millisecond))
+	tracker.stamp(key, LatencyStageOrderSubmit, base.Add(15*time.Millisecond))
+	tracker.stamp(key, LatencyStageOrderSubmitted, base.Add(20*time.Millisecond))
+	// 柜台回报可能先于下单调用返回到达。
+	if _, ok := tracker.brokerEvent("acct-a", "ref-9", LatencyStageBrokerOrder, base.Add(24*time.Millisecond)); ok {
+		t.Fatal("unbound broker event should be buffered, not persisted")
+	}
+	tracker.bindOrder(key, "acct-a", "ref-9")
+
+	span, ok := tracker.finishDecision(key)
+	if !ok || span.Complete || span.OrderRef != "ref-9" {
+		t.Fatalf("span after decision = %+v ok=%v, want open and bound", span, ok)
+	}
+	if span.SegmentsMS["strategy"] != 10 || span.SegmentsMS["broker_ack"] != 4 || span.SegmentsMS["publish"] != 2 {
+		t.Fatalf("segments = %+v", span.SegmentsMS)
+	}
+
+	span, ok = tracker.brokerEvent("acct-a", "ref-9", LatencyStageBrokerTrade, base.Add(40*time.Millisecond))
+	if !ok || !span.Complete || span.TotalMS != 40 || span.SegmentsMS["broker_fill"] != 20 {
+		t.Fatalf("span after fill = %+v ok=%v", span, ok)
+	}
+	if _, ok := tracker.brokerEvent("acct-a", "ref-9", LatencyStageBrokerTrade, base.Add(50*time.Millisecond)); ok {
+		t.Fatal("second fill should not reopen a completed span")
+	}
+
+	stats := tracker.percentiles()
+	if stats["strategy"].Count != 1 || stats["total"].P99 != 40 {
+		t.Fatalf("percentiles = %+v", stats)
+	}
+}
+
+func TestLatencyTrackerKeysOrdersByAccount(t *testing.T) {
+	var tracker latencyTracker
+	base := time.Now()
+	keyA := tracker.begin(StrategyInstance{InstanceID: "inst-a"}, "rb2601", RunTypeRealtime, BarEvent{LatencyTraceID: "lt-2", PublishedAt: base})
+	keyB := tracker.begin(StrategyInstance{InstanceID: "inst-b"}, "rb2601", RunTypeRealtime, BarEvent{LatencyTraceID: "lt-2", PublishedAt: base})
+	// 两个账户各自的交易会话可能分配出相同的报单引用。
+	tracker.bindOrder(keyA, "acct-a", "1")
+	tracker.bindOrder(keyB, "acct-b", "1")
+	tracker.finishDecision(keyA)
+	tracker.finishDecision(keyB)
+
+	span, ok := tracker.brokerEvent("acct-b", "1", LatencyStageBrokerTrade, base.Add(5*time.Millisecond))
+	if !ok || span.InstanceID != "inst-b" || !span.Complete {
+		t.Fatalf("acct-b fill span = %+v ok=%v, want inst-b completed", span, ok)
+	}
+	span, ok = tracker.brokerEvent("acct-a", "1", LatencyStageBrokerTrade, base.Add(8*time.Millisecond))
+	if !ok || span.InstanceID != "inst-a" || !span.Complete {
+		t.Fatalf("acct-a fill span = %+v ok=%v, want inst-a completed", span, ok)
+	}
+}
+
+func TestLatencyTrackerCompletesSpanWithoutOrder(t *testing.T) {
+	var tracker latencyTracker
+	base := time.Now()
+	for i := 1; i <= 100; i++ {
+		inst := StrategyInstance{InstanceID: "inst-1"}
+		key := tracker.begin(inst, "rb2601", RunTypeRealtime, BarEvent{LatencyTraceID: newLatencyTraceID(), PublishedAt: base})
+		tracker.stamp(key, LatencyStageStrategyCall, base)
+		tracker.stamp(key, LatencyStageStrategyReturn, base.Add(time.Duration(i)*time.Millisecond))
+		span, ok := tracker.finishDecision(key)
+		if !ok || !span.Complete {
+			t.Fatalf("decision without order should complete, got %+v", span)
+		}
+	}
+	if len(tracker.open) != 0 {
+		t.Fatalf("open spans = %d, want 0", len(tracker.open))
+	}
+	got := tracker.percentiles()["strategy"]
+	if got.Count != 100 || got.P50 != 50 || got.P90 != 90 || got.P99 != 99 || got.Max != 100 {
+		t.Fatalf("strategy percentiles = %+v", got)
+	}
+}
+
+func TestLatencyTrackerBindsEverySplitLeg(t *testing.T) {
+	var tracker latencyTracker
+	base := time.Now()
+	key := tracker.begin(StrategyInstance{InstanceID: "inst-a"}, "rb2601", RunTypeRealtime, BarEvent{LatencyTraceID: "lt-3", PublishedAt: base})
+	// close_today 那一笔先回报，仍应计入同一个 span。
+	tracker.brokerEvent("acct-a", "8", LatencyStageBrokerOrder, base.Add(3*time.Millisecond))
+	tracker.bindOrder(key, "acct-a", "7", "8", "7")
+	span, ok := tracker.finishDecision(key)
+	if !ok || span.OrderRef != "7" || span.Complete {
+		t.Fatalf("span after bind = %+v ok=%v, want open with first leg ref", span, ok)
+	}
+	if _, seen := span.Stages[LatencyStageBrokerOrder]; !seen {
+		t.Fatalf("early ack of second leg was not stamped: %+v", span.Stages)
+	}
+
+	span, ok = tracker.brokerEvent("acct-a", "8", LatencyStageBrokerTrade, base.Add(9*time.Millisecond))
+	if !ok || !span.Complete {
+		t.Fatalf("second leg fill span = %+v ok=%v, want completed", span, ok)
+	}
+	tracker.mu.Lock()
+	left := len(tracker.orders)
+	tracker.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("completed span should release every leg ref, %d left", left)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"ctp-future-kline/internal/indicators"
 )
 
 const (
@@ -456,19 +458,13 @@ func calcMA20Indicators(bars []MA20BacktestBar, cfg MA20BacktestConfig) ma20Indi
 		MA120Slope:   make([]float64, n),
 		LastSwingLow: make([]float64, n),
 	}
-	closes := make([]float64, n)
-	tr := make([]float64, n)
+	ma20, ma60, ma120, atr14 := indicators.NewMA(20), indicators.NewMA(60), indicators.NewMA(120), indicators.NewATR(14)
 	for i, b := range bars {
-		closes[i] = b.Close
-		prevClose := b.Close
-		if i > 0 {
-			prevClose = bars[i-1].Close
-		}
-		tr[i] = math.Max(b.High-b.Low, math.Max(math.Abs(b.High-prevClose), math.Abs(b.Low-prevClose)))
-		out.MA20[i] = rollingAvg(closes, i, 20)
-		out.MA60[i] = rollingAvg(closes, i, 60)
-		out.MA120[i] = rollingAvg(closes, i, 120)
-		out.ATR14[i] = rollingAvg(tr, i, 14)
+		bar := ma20IndicatorBar(b)
+		out.MA20[i] = ma20.Update(bar)["ma"]
+		out.MA60[i] = ma60.Update(bar)["ma"]
+		out.MA120[i] = ma120.Update(bar)["ma"]
+		out.ATR14[i] = atr14.Update(bar)["atr"]
 		if i >= cfg.SlopeLookbackBars {
 			out.MA20Slope[i] = slopeValue(out.MA20, i, cfg.SlopeLookbackBars)
 			out.MA60Slope[i] = slopeValue(out.MA60, i, cfg.SlopeLookbackBars)
@@ -922,153 +918,17 @@ func scoreMetrics(ind ma20Indicators, i int, bull float64, bear float64) map[str
 	return m
 }
 
-type ma20ZigZagPoint struct {
-	Typ   string
-	Index int
-	High  float64
-	Low   float64
-}
-
 func calcZigZagTroughConfirmations(bars []MA20BacktestBar, cfg MA20BacktestConfig) []bool {
 	out := make([]bool, len(bars))
-	atrPeriod := cfg.ZigZagATRPeriod
-	if atrPeriod <= 0 {
-		atrPeriod = 26
-	}
-	atrMultiple := cfg.ZigZagATRMultiple
-	if atrMultiple <= 0 {
-		atrMultiple = 2
-	}
-	minBars := cfg.ZigZagMinBars
-	if minBars <= 0 {
-		minBars = 5
-	}
-	trs := make([]float64, len(bars))
-	initCache := make([]int, 0, atrPeriod+minBars)
-	stage := "INIT"
-	var lastFixed *ma20ZigZagPoint
-	var curr *ma20ZigZagPoint
-	var next *ma20ZigZagPoint
+	zigzag := indicators.NewZigZag(cfg.ZigZagATRPeriod, cfg.ZigZagATRMultiple, cfg.ZigZagMinBars)
 	for i, bar := range bars {
-		prevClose := bar.Close
-		if i > 0 {
-			prevClose = bars[i-1].Close
-		}
-		trs[i] = math.Max(bar.High-bar.Low, math.Max(math.Abs(bar.High-prevClose), math.Abs(bar.Low-prevClose)))
-		atr := rollingAvg(trs, i, atrPeriod)
-		if atr <= 0 {
-			continue
-		}
-		reversal := atr * atrMultiple
-		if stage == "INIT" {
-			initCache = append(initCache, i)
-			hiIdx, loIdx := zigZagInitExtremes(bars, initCache)
-			if hiIdx < 0 || loIdx < 0 || bars[hiIdx].High-bars[loIdx].Low < reversal {
-				continue
-			}
-			if loIdx < hiIdx {
-				lastFixed = zigZagPoint("TROUGH", loIdx, bars[loIdx])
-				curr = zigZagPoint("PEAK", hiIdx, bars[hiIdx])
-			} else {
-				lastFixed = zigZagPoint("PEAK", hiIdx, bars[hiIdx])
-				curr = zigZagPoint("TROUGH", loIdx, bars[loIdx])
-			}
-			stage = "RUNNING"
-			continue
-		}
-		if lastFixed == nil || curr == nil {
-			continue
-		}
-		switch lastFixed.Typ {
-		case "TROUGH":
-			if next == nil {
-				if bar.High > curr.High {
-					curr = zigZagPoint("PEAK", i, bar)
-				} else if curr.High-bar.Low >= reversal {
-					next = zigZagPoint("TROUGH", i, bar)
-				}
-				continue
-			}
-			if bar.High > curr.High {
-				curr = zigZagPoint("PEAK", i, bar)
-				next = nil
-				continue
-			}
-			if bar.Low < next.Low {
-				next = zigZagPoint("TROUGH", i, bar)
-			}
-			if bar.High-next.Low >= reversal && zigZagSpacingOK(lastFixed, curr, next, minBars) {
-				lastFixed = curr
-				curr = next
-				next = zigZagPoint("PEAK", i, bar)
-			}
-		case "PEAK":
-			if next == nil {
-				if bar.Low < curr.Low {
-					curr = zigZagPoint("TROUGH", i, bar)
-				} else if bar.High-curr.Low >= reversal {
-					next = zigZagPoint("PEAK", i, bar)
-				}
-				continue
-			}
-			if bar.Low < curr.Low {
-				curr = zigZagPoint("TROUGH", i, bar)
-				next = nil
-				continue
-			}
-			if bar.High > next.High {
-				next = zigZagPoint("PEAK", i, bar)
-			}
-			if next.High-bar.Low >= reversal && zigZagSpacingOK(lastFixed, curr, next, minBars) {
-				if curr.Typ == "TROUGH" {
-					out[i] = true
-				}
-				lastFixed = curr
-				curr = next
-				next = zigZagPoint("TROUGH", i, bar)
-			}
-		}
+		out[i] = zigzag.Update(ma20IndicatorBar(bar))["trough_confirmed"] > 0
 	}
 	return out
 }
 
-func zigZagPoint(typ string, index int, bar MA20BacktestBar) *ma20ZigZagPoint {
-	return &ma20ZigZagPoint{Typ: typ, Index: index, High: bar.High, Low: bar.Low}
-}
-
-func zigZagInitExtremes(bars []MA20BacktestBar, indexes []int) (int, int) {
-	if len(indexes) == 0 {
-		return -1, -1
-	}
-	hiIdx := indexes[0]
-	loIdx := indexes[0]
-	for _, idx := range indexes[1:] {
-		if bars[idx].High > bars[hiIdx].High {
-			hiIdx = idx
-		}
-		if bars[idx].Low < bars[loIdx].Low {
-			loIdx = idx
-		}
-	}
-	return hiIdx, loIdx
-}
-
-func zigZagSpacingOK(lastFixed *ma20ZigZagPoint, curr *ma20ZigZagPoint, next *ma20ZigZagPoint, minBars int) bool {
-	if lastFixed == nil || curr == nil || next == nil {
-		return false
-	}
-	return curr.Index-lastFixed.Index >= minBars && next.Index-curr.Index >= minBars
-}
-
-func rollingAvg(values []float64, i int, period int) float64 {
-	if period <= 0 || i+1 < period {
-		return 0
-	}
-	sum := 0.0
-	for j := i - period + 1; j <= i; j++ {
-		sum += values[j]
-	}
-	return sum / float64(period)
+func ma20IndicatorBar(bar MA20BacktestBar) indicators.Bar {
+	return indicators.Bar{Open: bar.Open, High: bar.High, Low: bar.Low, Close: bar.Close, Volume: float64(bar.Volume)}
 }
 
 func slopeValue(values []float64, i int, lookback int) float64 {
@@ -66,6 +66,7 @@ type Manager struct {
 	lastError   string
 	lastHealth  time.Time
 	client      *StrategyServiceClient
+	native      *NativeRuntime
 	connClose   func() error
 	events      map[chan EventEnvelope]struct{}
 	instances   map[string]StrategyInstance
@@ -75,10 +76,32 @@ type Manager struct {
 	reportMu    sync.Mutex
 	reports     map[string]*ReplayReport
 
-	backtestMarketDSN string
-	marketRealtimeDSN string
-	marketReplayDSN   string
-	sharedMetaDSN     string
+	checkpointMu sync.Mutex
+	checkpoints  map[string]*checkpointCursor
+
+	// definitions 是最近一次同步到的策略定义，用于固定实例和运行记录的版本。
+	definitions map[string]StrategyDefinition
+	reloadMu    sync.Mutex
+	reloads     map[string]time.Time
+
+	// latency 追踪实盘 bar 到柜台成交回报的决策链路耗时。
+	latency latencyTracker
+
+	// approvalMu 串行化确认模式下审批请求的创建和处理，approvalTimers 是待审批请求的过期定时器。
+	approvalMu     sync.Mutex
+	approvalTimers map[string]*time.Timer
+
+	backtestMarketDSN   string
+	portfolioBacktester PortfolioBacktester
+	optimizing          map[string]struct{}
+	marketRealtimeDSN   string
+	marketReplayDSN     string
+	sharedMetaDSN       string
+
+	// compareMu 保护 A/B 回放对比；replayLedgerFactory 由 web 层注入，为对比实例创建独立账本。
+	compareMu           sync.Mutex
+	comparisons         map[string]*replayComparison
+	replayLedgerFactory ReplayLedgerFactory
 }
 
 func NewManager(cfg config.StrategyConfig, dsn string, registry *queuewatch.Registry) (*Manager, error) {
@@ -94,12 +117,14 @@ func NewManager(cfg config.StrategyConfig, dsn string, registry *queuewatch.Regi
 		cfg:       cfg,
 		store:     store,
 		exec:      NewExecutionEngine(),
+		native:    NewNativeRuntime(),
 		features:  make(map[strategyFeatureKey][]map[string]any),
 		events:    make(map[chan EventEnvelope]struct{}),
 		instances: make(map[string]StrategyInstance),
 		reports:   make(map[string]*ReplayReport),
 		queueCap:  queueCfg.StrategyEventCapacity,
 	}
+	m.exec.SetAccountLimits(cfg.AccountLimits)
 	if registry != nil {
 		m.queueHandle = registry.Register(queuewatch.QueueSpec{
 			Name:        "strategy_event_subscribers",
@@ -113,6 +138,9 @@ func NewManager(cfg config.StrategyConfig, dsn string, registry *queuewatch.Regi
 	if items, err := store.ListInstances(); err == nil {
 		for _, item := range items {
 			m.instances[item.InstanceID] = item
+			if item.RiskPause != nil {
+				m.exec.RestoreInstancePause(item.InstanceID, *item.RiskPause)
+			}
 		}
 	}
 	SetDefaultSink(m)
@@ -169,6 +197,9 @@ func (m *Manager) Start() error {
 		m.setError(err)
 		logger.Warn("strategy http unavailable on startup; health loop will keep reconnecting", "error", err, "http_addr", m.strategyHTTPAddr())
 	}
+	if err := m.restoreNativeInstances("startup"); err != nil {
+		logger.Warn("restore native strategy instances failed on startup", "error", err)
+	}
 	go m.healthLoop()
 	return nil
 }
@@ -234,7 +265,8 @@ func (m *Manager) StopService() error {
 		return err
 	}
 	for _, item := range items {
-		if item.Status != InstanceStatusRunning {
+		// Go 策略不依赖 Python 进程，停止 Python 服务时保持运行。
+		if item.Status != InstanceStatusRunning || IsNativeStrategyID(item.StrategyID) {
 			continue
 		}
 		item.Status = InstanceStatusStopped
@@ -279,8 +311,10 @@ func (m *Manager) healthLoop() {
 		}
 		m.mu.RLock()
 		ready := m.connReady
+		stream := m.client.Stream()
 		m.mu.RUnlock()
 		if ready {
+			m.checkStreamHealth(stream)
 			continue
 		}
 		if err := m.connect(); err != nil {
@@ -289,6 +323,26 @@ func (m *Manager) healthLoop() {
 	}
 }
 
+// checkStreamHealth 用流通道心跳刷新健康时间；心跳中断时再用 HTTP ping 确认服务是否仍可用。
+func (m *Manager) checkStreamHealth(stream *StrategyStream) {
+	if stream == nil {
+		return
+	}
+	if stream.Healthy() {
+		m.mu.Lock()
+		m.lastHealth = stream.LastHeartbeat()
+		m.mu.Unlock()
+		return
+	}
+	if _, err := m.ping(); err != nil {
+		m.mu.Lock()
+		m.connReady = false
+		m.mu.Unlock()
+		m.setError(err)
+		m.broadcast("strategy_status_update", m.Status())
+	}
+}
+
 func (m *Manager) strategyHTTPAddr() string {
 	addr := strings.TrimSpace(m.cfg.HTTPAddr)
 	if addr == "" {
@@ -570,6 +624,9 @@ func (m *Manager) connect() error {
 		// logger.Warn("strategy http ping failed", "http_addr", addr, "error", err)
 		return err
 	}
+	if m.cfg.Transport != config.StrategyTransportHTTP {
+		client.EnableStream(time.Duration(m.cfg.HealthcheckIntervalMS) * time.Millisecond)
+	}
 	m.mu.Lock()
 	becameReady := !m.connReady
 	if m.connClose != nil {
@@ -577,7 +634,7 @@ func (m *Manager) connect() error {
 	}
 	m.client = client
 	m.connReady = true
-	m.connClose = nil
+	m.connClose = client.Close
 	m.lastError = ""
 	m.lastHealth = time.Now()
 	m.mu.Unlock()
@@ -641,9 +698,13 @@ func (m *Manager) syncDefinitions() ([]StrategyDefinition, error) {
 		}
 		items = append(items, item)
 	}
+	items = mergeNativeDefinitions(items, now)
 	if err := m.store.ReplaceDefinitions(items); err != nil {
 		return nil, err
 	}
+	if err := m.rememberDefinitions(items); err != nil {
+		logger.Warn("strategy definition version history update failed", "error", err)
+	}
 	return items, nil
 }
 
@@ -657,6 +718,10 @@ func (m *Manager) Status() ManagerStatus {
 	defer m.mu.RUnlock()
 	running := 0
 	httpAddr := m.strategyHTTPAddr()
+	transport := config.StrategyTransportHTTP
+	if m.client.Stream().Healthy() {
+		transport = config.StrategyTransportStream
+	}
 	for _, inst := range m.instances {
 		if inst.Status == InstanceStatusRunning {
 			running++
@@ -681,6 +746,8 @@ func (m *Manager) Status() ManagerStatus {
 		AuditCount:          audits,
 		BacktestRunCount:    runs,
 		AutoExecutionPaused: m.exec != nil && m.exec.Paused(),
+		Transport:           transport,
+		Latency:             m.latency.percentiles(),
 	}
 }
 
@@ -698,9 +765,9 @@ func (m *Manager) ListDefinitions() ([]StrategyDefinition, error) {
 		}
 		if err != nil {
 			// 策略列表必须反映 Python ListStrategies 当前导出的算法，而不是 Go 侧兜底定义。
-			// Python 未连接时返回空列表，避免数据库旧行继续展示当前 Python 服务未必提供的策略。
-			logger.Warn("strategy definitions unavailable from python; returning empty list", "error", err)
-			return []StrategyDefinition{}, nil
+			// Python 未连接时只返回编译期注册的 Go 策略，避免数据库旧行继续展示当前 Python 服务未必提供的策略。
+			logger.Warn("strategy definitions unavailable from python; returning native strategies only", "error", err)
+			return mergeNativeDefinitions(nil, time.Now()), nil
 		}
 	}
 	return items, nil
@@ -721,6 +788,7 @@ func (m *Manager) ListInstances() ([]StrategyInstance, error) {
 	out := make([]StrategyInstance, len(items))
 	for i, item := range items {
 		out[i] = sanitizeStrategyInstanceForList(item)
+		out[i].ReloadPending = m.reloadPending(item.InstanceID)
 	}
 	return out, nil
 }
@@ -775,6 +843,12 @@ func (m *Manager) SaveInstance(inst StrategyInstance) error {
 			if inst.CreatedAt.IsZero() {
 				inst.CreatedAt = existing.CreatedAt
 			}
+			if strings.TrimSpace(inst.ExecutionMode) == "" {
+				inst.ExecutionMode = existing.ExecutionMode
+			}
+			if strings.TrimSpace(inst.ReplaySession) == "" {
+				inst.ReplaySession = existing.ReplaySession
+			}
 			if inst.LastSignalAt == nil {
 				inst.LastSignalAt = existing.LastSignalAt
 			}
@@ -804,6 +878,14 @@ func (m *Manager) SaveInstance(inst StrategyInstance) error {
 	if strings.TrimSpace(inst.Status) == "" {
 		inst.Status = InstanceStatusStopped
 	}
+	if err := validateExecutionMode(inst.ExecutionMode); err != nil {
+		return err
+	}
+	inst.ExecutionMode = normalizeExecutionMode(inst.ExecutionMode)
+	if err := validateReplaySession(inst.ReplaySession); err != nil {
+		return err
+	}
+	inst.ReplaySession = normalizeReplaySession(inst.ReplaySession)
 	if err := m.store.SaveInstance(inst); err != nil {
 		return err
 	}
@@ -833,8 +915,11 @@ func (m *Manager) StartInstance(instanceID string) error {
 		_ = m.store.SaveInstance(inst)
 		return err
 	}
+	m.dropCheckpoint(inst.InstanceID)
+	m.clearPendingReload(inst.InstanceID)
 	inst.Status = InstanceStatusRunning
 	inst.LastError = ""
+	inst.DefinitionVersion, inst.CodeHash = m.definitionPin(inst.StrategyID)
 	now := time.Now()
 	inst.LastStartedAt = &now
 	if err := m.store.SaveInstance(inst); err != nil {
@@ -849,9 +934,7 @@ func (m *Manager) StartInstance(instanceID string) error {
 }
 
 func (m *Manager) callStartInstance(inst StrategyInstance) error {
-	m.mu.RLock()
-	client := m.client
-	m.mu.RUnlock()
+	client := m.runtimeFor(inst.StrategyID)
 	if client == nil {
 		return fmt.Errorf("strategy http client not connected")
 	}
@@ -885,10 +968,7 @@ func (m *Manager) StopInstance(instanceID string) error {
 	if err != nil {
 		return err
 	}
-	m.mu.RLock()
-	client := m.client
-	m.mu.RUnlock()
-	if client != nil {
+	if client := m.runtimeFor(inst.StrategyID); client != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cfg.RequestTimeoutMS)*time.Millisecond)
 		_ = client.StopInstance(ctx, StopInstanceRequest{InstanceID: instanceID})
 		cancel()
@@ -898,6 +978,8 @@ func (m *Manager) StopInstance(instanceID string) error {
 	if err := m.store.SaveInstance(inst); err != nil {
 		return err
 	}
+	m.dropCheckpoint(inst.InstanceID)
+	m.clearPendingReload(inst.InstanceID)
 	m.mu.Lock()
 	m.instances[inst.InstanceID] = inst
 	m.mu.Unlock()
@@ -1005,7 +1087,17 @@ func (m *Manager) restoreRunningInstances(reason string) error {
 	return m.restoreRunningInstancesWithClient(context.Background(), client, reason)
 }
 
+// restoreNativeInstances 在进程启动时重新拉起数据库中处于 running 的 Go 策略实例。
+func (m *Manager) restoreNativeInstances(reason string) error {
+	return m.restoreRunningInstancesFor(context.Background(), m.native, reason, true)
+}
+
 func (m *Manager) restoreRunningInstancesWithClient(ctx context.Context, client runtimeStartClient, reason string) error {
+	return m.restoreRunningInstancesFor(ctx, client, reason, false)
+}
+
+// restoreRunningInstancesFor 恢复 native 指定运行时下的 running 实例；Python 重连只恢复 Python 实例，反之亦然。
+func (m *Manager) restoreRunningInstancesFor(ctx context.Context, client runtimeStartClient, reason string, native bool) error {
 	m.restoreMu.Lock()
 	defer m.restoreMu.Unlock()
 	if m == nil || m.store == nil {
@@ -1018,7 +1110,7 @@ func (m *Manager) restoreRunningInstancesWithClient(ctx context.Context, client
 	var firstErr error
 	restored := 0
 	for _, inst := range items {
-		if inst.Status != InstanceStatusRunning {
+		if inst.Status != InstanceStatusRunning || IsNativeStrategyID(inst.StrategyID) != native {
 			continue
 		}
 		// Python runtime 是进程内状态，Go 重启或 Python 重启后不会自动拥有数据库里的 running 实例。
@@ -1029,7 +1121,7 @@ func (m *Manager) restoreRunningInstancesWithClient(ctx context.Context, client
 		if _, ok := startCtx.Deadline(); !ok {
 			startCtx, cancel = context.WithTimeout(startCtx, m.runtimeStartTimeout())
 		}
-		_, startErr := m.startRuntimeInstance(startCtx, client, inst)
+		restore, startErr := m.restoreRuntimeInstance(startCtx, client, inst)
 		cancel()
 		if startErr != nil {
 			logger.Warn("restore running strategy instance failed", "instance_id", inst.InstanceID, "strategy_id", inst.StrategyID, "reason", reason, "error", startErr)
@@ -1042,6 +1134,7 @@ func (m *Manager) restoreRunningInstancesWithClient(ctx context.Context, client
 		now := time.Now()
 		inst.LastStartedAt = &now
 		inst.LastError = ""
+		inst.DefinitionVersion, inst.CodeHash = m.definitionPin(inst.StrategyID)
 		if saveErr := m.store.SaveInstance(inst); saveErr != nil {
 			if firstErr == nil {
 				firstErr = saveErr
@@ -1052,7 +1145,7 @@ func (m *Manager) restoreRunningInstancesWithClient(ctx context.Context, client
 		m.mu.Lock()
 		m.instances[inst.InstanceID] = inst
 		m.mu.Unlock()
-		m.persistInstanceRestoreTrace(inst, reason)
+		m.persistInstanceRestoreTrace(inst, reason, restore)
 		restored++
 	}
 	if restored > 0 {
@@ -1066,10 +1159,17 @@ func (m *Manager) OrdersStatus() OrdersStatus {
 	return m.exec.Status()
 }
 
+func (m *Manager) SubPositions() []StrategySubPosition {
+	return m.exec.SubPositions()
+}
+
 func (m *Manager) RunBacktest(req BacktestRequest) (StrategyRun, error) {
 	if shouldRunLocalMA20Backtest(req) {
 		return m.runLocalMA20Backtest(req)
 	}
+	if shouldRunPortfolioBacktest(req) {
+		return m.runPortfolioBacktest(req)
+	}
 	m.mu.RLock()
 	client := m.client
 	m.mu.RUnlock()
@@ -1368,27 +1468,35 @@ func ma20ParamFloat(params map[string]any, key string, fallback float64) float64
 	if !ok {
 		return fallback
 	}
+	if v, ok := numericValue(raw); ok {
+		return v
+	}
+	return fallback
+}
+
+// numericValue 把 JSON 解码或手工构造的数值（含数字字符串）转成 float64。
+func numericValue(raw any) (float64, bool) {
 	switch v := raw.(type) {
 	case float64:
-		return v
+		return v, true
 	case float32:
-		return float64(v)
+		return float64(v), true
 	case int:
-		return float64(v)
+		return float64(v), true
 	case int64:
-		return float64(v)
+		return float64(v), true
 	case json.Number:
 		n, err := v.Float64()
 		if err == nil {
-			return n
+			return n, true
 		}
 	case string:
 		var out float64
 		if _, err := fmt.Sscanf(strings.TrimSpace(v), "%f", &out); err == nil {
-			return out
+			return out, true
 		}
 	}
-	return fallback
+	return 0, false
 }
 
 func parseBacktestOptionalTime(raw string) (time.Time, bool, error) {
@@ -1415,36 +1523,6 @@ func parseBacktestOptionalTime(raw string) (time.Time, bool, error) {
 	return time.Time{}, false, fmt.Errorf("invalid backtest time: %s", raw)
 }
 
-func (m *Manager) RunParameterSweep(req ParameterSweepRequest) (StrategyRun, error) {
-	m.mu.RLock()
-	client := m.client
-	m.mu.RUnlock()
-	if client == nil {
-		return StrategyRun{}, fmt.Errorf("strategy http client not connected")
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
-	resp, err := client.RunParameterSweep(ctx, req)
-	if err != nil {
-		return StrategyRun{}, err
-	}
-	run := StrategyRun{
-		RunID:      resp.RunID,
-		StrategyID: req.StrategyID,
-		RunType:    "optimize",
-		Status:     resp.Status,
-		Symbol:     req.Symbol,
-		Timeframe:  req.Timeframe,
-		Summary:    resp.Summary,
-		StartedAt:  time.Now(),
-	}
-	if err := m.store.SaveRun(run); err != nil {
-		return StrategyRun{}, err
-	}
-	m.broadcast("strategy_backtest_done", run)
-	return run, nil
-}
-
 func (m *Manager) writeBacktestOutput(run StrategyRun, req any, resp BacktestResponse) (string, error) {
 	jsonPath, _, err := writeStrategyRunArchive(m.cfg.BacktestOutputDir, run, req, resp)
 	return jsonPath, err
@@ -1452,11 +1530,21 @@ func (m *Manager) writeBacktestOutput(run StrategyRun, req any, resp BacktestRes
 
 func (m *Manager) HandleRealtimeTick(ev TickEvent) { m.handleTick(ev, RunTypeRealtime) }
 func (m *Manager) HandleReplayTick(ev TickEvent)   { m.handleTick(ev, RunTypeReplay) }
-func (m *Manager) HandleRealtimeBar(ev BarEvent)   { m.handleBar(ev, RunTypeRealtime) }
-func (m *Manager) HandleReplayBar(ev BarEvent)     { m.handleBar(ev, RunTypeReplay) }
+func (m *Manager) HandleRealtimeBar(ev BarEvent) {
+	m.markRiskBudgets(ev)
+	m.handleBar(ev, RunTypeRealtime)
+}
+func (m *Manager) HandleReplayBar(ev BarEvent) {
+	m.markReplayReports(ev)
+	m.applyReplayComparisonBar(ev)
+	m.handleBar(ev, RunTypeReplay)
+}
 
 func (m *Manager) handleTick(ev TickEvent, mode string) {
 	m.forEachMatchingInstance(ev.InstrumentID, "", mode, func(inst StrategyInstance) {
+		if !replaySessionMatches(inst, mode, ev.ReplaySession) {
+			return
+		}
 		m.callDecision(inst, ev.InstrumentID, mode, ev.ReplayTaskID, ev.ReceivedAt, &ev, nil)
 	})
 }
@@ -1468,7 +1556,7 @@ func (m *Manager) handleBar(ev BarEvent, mode string) {
 	// 	"mode", mode,
 	// 	"event_time", strategyBarEventTime(ev),
 	// )
-	indicators, trading := splitMatchingInstances(m.matchingInstances(ev.InstrumentID, ev.Period, mode))
+	indicators, trading := splitMatchingInstances(filterReplaySession(m.matchingInstances(ev.InstrumentID, ev.Period, mode), mode, ev.ReplaySession))
 	for _, inst := range indicators {
 		m.callDecision(inst, ev.InstrumentID, mode, ev.ReplayTaskID, strategyBarEventTime(ev), nil, &ev)
 	}
@@ -1509,15 +1597,15 @@ func (m *Manager) matchingInstances(symbol string, timeframe string, mode string
 	return out
 }
 
-func (m *Manager) currentExecutionPosition(symbol string) float64 {
+func (m *Manager) currentExecutionPosition(accountID string, symbol string) float64 {
 	m.mu.RLock()
 	executor := m.orderExecutor
 	m.mu.RUnlock()
 	if executor != nil {
-		if pos, err := executor.CurrentPosition(symbol); err == nil {
+		if pos, err := executor.CurrentPosition(accountID, symbol); err == nil {
 			return pos
 		} else {
-			logger.Warn("strategy external position unavailable; fallback to in-memory position", "symbol", symbol, "error", err)
+			logger.Warn("strategy external position unavailable; fallback to in-memory position", "account_id", accountID, "symbol", symbol, "error", err)
 		}
 	}
 	if m.exec == nil {
@@ -1545,30 +1633,41 @@ func isIndicatorStrategyID(strategyID string) bool {
 
 func (m *Manager) callDecision(inst StrategyInstance, symbol string, mode string, replayTaskID string, eventTime time.Time, tick *TickEvent, bar *BarEvent) {
 	m.touchReplayReport(replayTaskID, inst)
-	m.mu.RLock()
-	client := m.client
-	m.mu.RUnlock()
+	client := m.runtimeFor(inst.StrategyID)
 	if client == nil {
 		return
 	}
 	if bar != nil {
 		eventTime = strategyBarEventTime(*bar)
+		var ok bool
+		if inst, ok = m.applyPendingReload(inst, client, eventTime); !ok {
+			return
+		}
+	}
+	var latencyKey string
+	if bar != nil && mode != RunTypeReplay {
+		latencyKey = m.latency.begin(inst, symbol, mode, *bar)
+		defer m.finishLatencySpan(latencyKey)
 	}
 	req := DecisionRequest{
 		Instance:        runtimeStrategyInstance(inst),
 		Symbol:          symbol,
 		EventTime:       eventTime.Format(time.RFC3339Nano),
 		Mode:            mode,
-		CurrentPosition: m.currentExecutionPosition(symbol),
+		CurrentPosition: m.currentExecutionPosition(inst.AccountID, symbol),
 		Account:         map[string]any{"account_id": inst.AccountID},
 		Features:        m.featuresFor(inst, symbol, mode),
 		Tick:            tick,
 		Bar:             bar,
 	}
+	if pos, ok := m.replayComparePosition(inst, symbol, mode); ok {
+		req.CurrentPosition = pos
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cfg.RequestTimeoutMS)*time.Millisecond)
 	defer cancel()
 	var decision SignalDecision
 	var err error
+	m.latency.stamp(latencyKey, LatencyStageStrategyCall, time.Now())
 	switch {
 	case tick != nil:
 		decision, err = client.OnTick(ctx, req)
@@ -1591,12 +1690,23 @@ func (m *Manager) callDecision(inst StrategyInstance, symbol string, mode string
 		)
 		decision, err = client.OnBar(ctx, req)
 	}
+	m.latency.stamp(latencyKey, LatencyStageStrategyReturn, time.Now())
 	if err != nil {
 		m.setInstanceError(inst.InstanceID, err)
 		return
 	}
+	if bar != nil && mode != RunTypeReplay {
+		// 检查点导出放到信号、订单计划和下单之后，不占用这根 K 线的下单路径。
+		defer m.checkpointAfterBar(client, inst, symbol, eventTime)
+	}
 	if decision.Trace != nil {
 		trace := normalizeStrategyTrace(inst, symbol, mode, eventTime, *decision.Trace)
+		if latencyKey != "" {
+			if trace.Metrics == nil {
+				trace.Metrics = map[string]any{}
+			}
+			trace.Metrics["latency_trace_id"] = bar.LatencyTraceID
+		}
 		m.persistTrace(inst, symbol, mode, eventTime, trace)
 		m.updateFeatureCacheFromTrace(trace)
 	}
@@ -2010,11 +2120,12 @@ func metricInt(value any) (int, bool) {
 }
 
 func (m *Manager) persistTrace(inst StrategyInstance, symbol string, mode string, eventTime time.Time, trace StrategyTraceRecord) {
-	if m == nil || m.store == nil {
+	if m == nil {
 		return
 	}
 	trace = normalizeStrategyTrace(inst, symbol, mode, eventTime, trace)
-	if !isPersistableTraceEventType(trace.EventType) {
+	m.recordReplayComparisonTrace(trace)
+	if m.store == nil || !isPersistableTraceEventType(trace.EventType) {
 		return
 	}
 	id, err := m.store.AppendTrace(trace)
@@ -2052,7 +2163,7 @@ func normalizeStrategyTrace(inst StrategyInstance, symbol string, mode string, e
 
 func isPersistableTraceEventType(eventType string) bool {
 	switch strings.TrimSpace(eventType) {
-	case "bar", "key_tick", "signal", "order_plan", "order_result":
+	case "bar", "key_tick", "signal", "order_plan", "order_result", "risk_budget", "hot_reload", "approval":
 		return true
 	default:
 		return false
@@ -2179,9 +2290,41 @@ func (m *Manager) persistDecision(inst StrategyInstance, symbol string, mode str
 			"signal_id":       id,
 		},
 	})
-	instancePlan := m.exec.PlanInstanceTarget(inst, symbol, decision.TargetPosition, mode, m.currentExecutionPosition(symbol))
+	latencyTraceID := ""
+	if bar != nil && mode != RunTypeReplay {
+		latencyTraceID = bar.LatencyTraceID
+	}
+	if requiresApproval(inst, mode) {
+		m.requestSignalApproval(inst, sig, decision, latencyTraceID)
+		m.markInstanceSignal(inst, nil)
+		m.broadcast("strategy_signal", sig)
+		return
+	}
+	audit := m.executeSignal(inst, symbol, mode, eventTime, decision, bar, latencyTraceID, nil)
+	target := decision.TargetPosition
+	m.markInstanceSignal(inst, &target)
+	m.broadcast("strategy_signal", sig)
+	m.broadcast("order_audit_update", audit)
+	m.appendReplayReport(replayTaskID, inst, sig, audit)
+	m.recordReplayComparisonSignal(inst, mode, sig, audit)
+}
+
+// executeSignal 对一次目标仓位走风控、下单和审计；approval 非空时表示人工批准后的执行，
+// 审计里带上审批信息，并使用审批指定的委托价。A/B 回放的对比实例只在自己的账本里执行。
+func (m *Manager) executeSignal(inst StrategyInstance, symbol string, mode string, eventTime time.Time, decision SignalDecision, bar *BarEvent, latencyTraceID string, approval *SignalApproval) OrderAuditRecord {
+	if audit, ok := m.executeReplayComparisonSignal(inst, symbol, mode, eventTime, decision, bar); ok {
+		return audit
+	}
+	instancePlan := m.exec.PlanInstanceTarget(inst, symbol, decision.TargetPosition, mode, m.currentExecutionPosition(inst.AccountID, symbol))
 	plan := instancePlan.Plan
-	externalResult := m.submitExternalOrderIfNeeded(inst, symbol, mode, eventTime, decision, &plan)
+	if instancePlan.Breach != nil {
+		m.persistRiskBreach(inst, symbol, mode, eventTime, *instancePlan.Breach)
+	}
+	limitPrice := 0.0
+	if approval != nil {
+		limitPrice = approval.ApprovedPrice
+	}
+	externalResult := m.submitExternalOrderIfNeeded(inst, symbol, mode, eventTime, decision, &plan, latencyTraceID, limitPrice)
 	m.appendSignalEventLog(inst, symbol, mode, eventTime, decision, plan, bar)
 	m.persistTrace(inst, symbol, mode, eventTime, StrategyTraceRecord{
 		EventType: "order_plan",
@@ -2201,9 +2344,14 @@ func (m *Manager) persistDecision(inst StrategyInstance, symbol string, mode str
 			"instance_target_position":  instancePlan.InstanceTargetPosition,
 			"net_current_target":        instancePlan.NetCurrentTarget,
 			"net_target_position":       instancePlan.NetTargetPosition,
+			"requested_target_position": instancePlan.RequestedTargetPosition,
+			"allocation_scale":          instancePlan.AllocationScale,
 		},
 	})
-	m.exec.ApplyInstanceTarget(inst, symbol, decision.TargetPosition, mode, plan)
+	deltas := m.exec.ApplyInstanceTarget(inst, symbol, decision.TargetPosition, mode, plan)
+	if externalResult == nil {
+		m.exec.FillSimulated(symbol, deltas)
+	}
 	audit := OrderAuditRecord{
 		InstanceID:      inst.InstanceID,
 		StrategyID:      inst.StrategyID,
@@ -2225,9 +2373,18 @@ func (m *Manager) persistDecision(inst StrategyInstance, symbol string, mode str
 			"instance_target_position":  instancePlan.InstanceTargetPosition,
 			"net_current_target":        instancePlan.NetCurrentTarget,
 			"net_target_position":       instancePlan.NetTargetPosition,
+			"requested_target_position": instancePlan.RequestedTargetPosition,
+			"allocation_scale":          instancePlan.AllocationScale,
 		},
 		CreatedAt: time.Now(),
 	}
+	if approval != nil {
+		audit.Audit["approval_id"] = approval.ApprovalID
+		audit.Audit["approved_by"] = approval.DecidedBy
+		audit.Audit["approved_volume"] = approval.ApprovedVolume
+		audit.Audit["approved_price"] = approval.ApprovedPrice
+		audit.Audit["signal_target_position"] = approval.TargetPosition
+	}
 	auditID, _ := m.store.AppendOrderAudit(audit)
 	audit.ID = auditID
 	m.persistTrace(inst, symbol, mode, eventTime, StrategyTraceRecord{
@@ -2250,11 +2407,20 @@ func (m *Manager) persistDecision(inst StrategyInstance, symbol string, mode str
 			"instance_target_position":  instancePlan.InstanceTargetPosition,
 			"net_current_target":        instancePlan.NetCurrentTarget,
 			"net_target_position":       instancePlan.NetTargetPosition,
+			"requested_target_position": instancePlan.RequestedTargetPosition,
+			"allocation_scale":          instancePlan.AllocationScale,
 		},
 	})
+	return audit
+}
+
+// markInstanceSignal 记录实例最近一次信号时间；target 非空时同时更新最近目标仓位。
+func (m *Manager) markInstanceSignal(inst StrategyInstance, target *float64) {
 	now := time.Now()
 	inst.LastSignalAt = &now
-	inst.LastTargetPosition = decision.TargetPosition
+	if target != nil {
+		inst.LastTargetPosition = *target
+	}
 	inst.LastError = ""
 	_ = m.store.SaveInstance(inst)
 	m.mu.Lock()
@@ -2263,12 +2429,9 @@ func (m *Manager) persistDecision(inst StrategyInstance, symbol string, mode str
 	}
 	m.instances[inst.InstanceID] = inst
 	m.mu.Unlock()
-	m.broadcast("strategy_signal", sig)
-	m.broadcast("order_audit_update", audit)
-	m.appendReplayReport(replayTaskID, inst, sig, audit)
 }
 
-func (m *Manager) submitExternalOrderIfNeeded(inst StrategyInstance, symbol string, mode string, eventTime time.Time, decision SignalDecision, plan *ExecutionPlan) map[string]any {
+func (m *Manager) submitExternalOrderIfNeeded(inst StrategyInstance, symbol string, mode string, eventTime time.Time, decision SignalDecision, plan *ExecutionPlan, latencyTraceID string, limitPrice float64) map[string]any {
 	if plan == nil || plan.RiskStatus != RiskStatusAllowed || plan.OrderStatus != OrderStatusSimulated {
 		return nil
 	}
@@ -2283,6 +2446,8 @@ func (m *Manager) submitExternalOrderIfNeeded(inst StrategyInstance, symbol stri
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), m.requestTimeout())
 	defer cancel()
+	latencyKey := latencySpanKey(latencyTraceID, inst.InstanceID)
+	m.latency.stamp(latencyKey, LatencyStageOrderSubmit, time.Now())
 	result, err := executor.SubmitStrategyOrder(ctx, StrategyOrderRequest{
 		Instance:        inst,
 		Symbol:          symbol,
@@ -2294,7 +2459,14 @@ func (m *Manager) submitExternalOrderIfNeeded(inst StrategyInstance, symbol stri
 		Reason:          decision.Reason,
 		Confidence:      decision.Confidence,
 		Metrics:         decision.Metrics,
+		Execution:       decision.Execution,
+		LatencyTraceID:  latencyTraceID,
+		LimitPrice:      limitPrice,
 	})
+	if err == nil {
+		m.latency.stamp(latencyKey, LatencyStageOrderSubmitted, time.Now())
+		m.latency.bindOrder(latencyKey, result.AccountID, append([]string{result.OrderRef}, result.LegOrderRefs...)...)
+	}
 	out := map[string]any{
 		"status": result.Status,
 		"reason": result.Reason,
@@ -2302,6 +2474,12 @@ func (m *Manager) submitExternalOrderIfNeeded(inst StrategyInstance, symbol stri
 	if strings.TrimSpace(result.OrderID) != "" {
 		out["order_id"] = result.OrderID
 	}
+	if strings.TrimSpace(result.OrderRef) != "" {
+		out["order_ref"] = result.OrderRef
+	}
+	if latencyTraceID != "" {
+		out["latency_trace_id"] = latencyTraceID
+	}
 	if len(result.Details) > 0 {
 		out["details"] = result.Details
 	}
@@ -2738,6 +2916,49 @@ type runtimeStartClient interface {
 	StartInstance(context.Context, StartInstanceRequest) error
 }
 
+// strategyRuntime 是 Python HTTP 服务与 Go 进程内运行时共同实现的实例生命周期和决策接口。
+type strategyRuntime interface {
+	runtimeStartClient
+	StopInstance(context.Context, StopInstanceRequest) error
+	OnTick(context.Context, DecisionRequest) (SignalDecision, error)
+	OnBar(context.Context, DecisionRequest) (SignalDecision, error)
+	OnReplayBar(context.Context, DecisionRequest) (SignalDecision, error)
+}
+
+// runtimeFor 返回承载策略的运行时；Python 未连接时返回 nil。
+func (m *Manager) runtimeFor(strategyID string) strategyRuntime {
+	if m.native != nil && IsNativeStrategyID(strategyID) {
+		return m.native
+	}
+	m.mu.RLock()
+	client := m.client
+	m.mu.RUnlock()
+	if client == nil {
+		return nil
+	}
+	return client
+}
+
+// HandleFill 把策略实例委托的成交回报记入回放报告并推给 Go 策略；Python 策略不消费成交回调。
+func (m *Manager) HandleFill(fill FillEvent) {
+	if m == nil || strings.TrimSpace(fill.InstanceID) == "" {
+		return
+	}
+	m.recordReplayFill(fill)
+	m.recordRiskFill(fill)
+	if span, ok := m.latency.brokerEvent(fill.AccountID, fill.OrderRef, LatencyStageBrokerTrade, fill.ReceivedAt); ok {
+		m.saveLatencySpan(span)
+	}
+	if m.native == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.requestTimeout())
+	defer cancel()
+	if err := m.native.OnFill(ctx, fill); err != nil {
+		m.setInstanceError(fill.InstanceID, err)
+	}
+}
+
 type RuntimeStartPlan struct {
 	Instance     StrategyInstance
 	Requirements StartRequirementsResponse
@@ -3028,7 +3249,7 @@ func (m *Manager) persistInstanceStartTrace(inst StrategyInstance) {
 	m.persistTrace(inst, firstSymbol(inst.Symbols), inst.Mode, trace.EventTime, trace)
 }
 
-func (m *Manager) persistInstanceRestoreTrace(inst StrategyInstance, reason string) {
+func (m *Manager) persistInstanceRestoreTrace(inst StrategyInstance, reason string, restore *checkpointRestore) {
 	trace, ok := initialTraceForInstance(inst)
 	if !ok {
 		return
@@ -3037,6 +3258,11 @@ func (m *Manager) persistInstanceRestoreTrace(inst StrategyInstance, reason stri
 	trace.Reason = "python runtime restored after http reconnect"
 	trace.Metrics["restore_reason"] = strings.TrimSpace(reason)
 	trace.Metrics["restored_at"] = trace.EventTime.Format(time.RFC3339Nano)
+	if restore != nil {
+		trace.Reason = "runtime restored from checkpoint"
+		trace.Metrics["checkpoint_bar_time"] = restore.LastBarTime.Format(time.RFC3339Nano)
+		trace.Metrics["checkpoint_replayed_bars"] = restore.Replayed
+	}
 	m.persistTrace(inst, firstSymbol(inst.Symbols), inst.Mode, trace.EventTime, trace)
 }
 
@@ -0,0 +1,187 @@
+// montecarlo.go 负责对已完成运行的逐笔交易做蒙特卡洛稳健性分析。
+// 交易取自被分析运行的归档：优先用绩效报告里的 closed_trades（金额口径），
+// 没有时退回 MA20 回测的 attempts 明细（价格点口径，每笔按 1 手、乘数 1 计）。
+// 每次分析是一条 monte_carlo 运行记录，instance_id 指向被分析的运行，各方法的分布写入 summary 和归档。
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/perf"
+)
+
+const (
+	// MonteCarloUnitMoney 表示交易盈亏按金额计。
+	MonteCarloUnitMoney = "money"
+	// MonteCarloUnitPoints 表示交易盈亏按价格点计。
+	MonteCarloUnitPoints = "points"
+)
+
+// RunMonteCarlo 对一次运行的逐笔交易启动蒙特卡洛分析，立即返回 running 状态的分析记录。
+func (m *Manager) RunMonteCarlo(sourceRunID string, req MonteCarloRequest) (StrategyRun, error) {
+	if m.store == nil {
+		return StrategyRun{}, fmt.Errorf("strategy store not configured")
+	}
+	methods, err := normalizeMonteCarloMethods(req.Methods)
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	req.Methods = methods
+	source, err := m.store.GetRun(strings.TrimSpace(sourceRunID))
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	jsonPath, _ := strategyArchiveRunPaths(m.cfg.BacktestOutputDir, source)
+	trades, unit, initial := monteCarloTrades(archiveMap(readJSONMap(firstNonEmpty(source.OutputPath, jsonPath))["result"]))
+	if len(trades) == 0 {
+		return StrategyRun{}, fmt.Errorf("strategy run %s has no closed trades", source.RunID)
+	}
+	if req.Seed == 0 {
+		req.Seed = time.Now().UnixNano()
+	}
+	run := StrategyRun{
+		RunID:      mustRunID("montecarlo"),
+		InstanceID: source.RunID,
+		StrategyID: source.StrategyID,
+		RunType:    RunTypeMonteCarlo,
+		Status:     "running",
+		Symbol:     source.Symbol,
+		Timeframe:  source.Timeframe,
+		StartedAt:  time.Now(),
+		Summary: map[string]any{
+			"source_run_id": source.RunID,
+			"request":       req,
+			"unit":          unit,
+			"trades":        len(trades),
+		},
+		DefinitionVersion: source.DefinitionVersion,
+		CodeHash:          source.CodeHash,
+	}
+	if err := m.store.SaveRun(run); err != nil {
+		return StrategyRun{}, err
+	}
+	go m.runMonteCarlo(run, req, trades, unit, initial)
+	return run, nil
+}
+
+// ListMonteCarloRuns 返回某次运行的全部蒙特卡洛分析记录，按开始时间升序。
+func (m *Manager) ListMonteCarloRuns(sourceRunID string) ([]StrategyRun, error) {
+	runs, err := m.store.ListRunsByInstance(strings.TrimSpace(sourceRunID))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StrategyRun, 0, len(runs))
+	for _, run := range runs {
+		if run.RunType == RunTypeMonteCarlo {
+			out = append(out, run)
+		}
+	}
+	return out, nil
+}
+
+func (m *Manager) runMonteCarlo(run StrategyRun, req MonteCarloRequest, trades []perf.ClosedTrade, unit string, initial float64) {
+	results := runMonteCarloMethods(trades, req, initial)
+	run.Status = "done"
+	run.Summary = map[string]any{
+		"source_run_id": run.InstanceID,
+		"request":       req,
+		"unit":          unit,
+		"trades":        len(trades),
+		"results":       results,
+	}
+	finished := time.Now()
+	run.FinishedAt = &finished
+	resp := BacktestResponse{
+		RunID:   run.RunID,
+		Status:  run.Status,
+		Summary: run.Summary,
+		Result: map[string]any{
+			"unit":          unit,
+			"results":       results,
+			"closed_trades": trades,
+		},
+	}
+	if outputPath, err := m.writeBacktestOutput(run, req, resp); err == nil {
+		run.OutputPath = outputPath
+	} else {
+		run.LastError = err.Error()
+	}
+	_ = m.store.SaveRun(run)
+	m.broadcast("strategy_backtest_done", run)
+}
+
+// runMonteCarloMethods 按请求的方法逐一模拟，所有方法共用同一个种子，结果按请求顺序排列。
+func runMonteCarloMethods(trades []perf.ClosedTrade, req MonteCarloRequest, initial float64) []perf.MonteCarloResult {
+	out := make([]perf.MonteCarloResult, 0, len(req.Methods))
+	for _, method := range req.Methods {
+		out = append(out, perf.MonteCarlo(trades, perf.MonteCarloConfig{
+			Method:         method,
+			Iterations:     req.Iterations,
+			BlockSize:      req.BlockSize,
+			SlippagePoints: req.SlippagePoints,
+			InitialBalance: initial,
+			Confidence:     req.Confidence,
+			Seed:           req.Seed,
+		}))
+	}
+	return out
+}
+
+func normalizeMonteCarloMethods(methods []string) ([]string, error) {
+	if len(methods) == 0 {
+		return []string{perf.MonteCarloBootstrap, perf.MonteCarloBlockBootstrap}, nil
+	}
+	out := make([]string, 0, len(methods))
+	seen := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		method = strings.ToLower(strings.TrimSpace(method))
+		switch method {
+		case perf.MonteCarloBootstrap, perf.MonteCarloBlockBootstrap:
+		default:
+			return nil, fmt.Errorf("unsupported monte carlo method %q", method)
+		}
+		if !seen[method] {
+			seen[method] = true
+			out = append(out, method)
+		}
+	}
+	return out, nil
+}
+
+// monteCarloTrades 从运行结果中取出逐笔交易、盈亏口径和初始权益。
+func monteCarloTrades(result map[string]any) ([]perf.ClosedTrade, string, float64) {
+	if raw, ok := result["performance"]; ok {
+		var report perf.Report
+		if body, err := json.Marshal(raw); err == nil && json.Unmarshal(body, &report) == nil && len(report.ClosedTrades) > 0 {
+			return report.ClosedTrades, MonteCarloUnitMoney, report.InitialBalance
+		}
+	}
+	var attempts []MA20AttemptRecord
+	if body, err := json.Marshal(result["attempts"]); err != nil || json.Unmarshal(body, &attempts) != nil {
+		return nil, "", 0
+	}
+	trades := make([]perf.ClosedTrade, 0, len(attempts))
+	for _, attempt := range attempts {
+		if attempt.Outcome != MA20OutcomeSuccess && attempt.Outcome != MA20OutcomeFailure {
+			continue
+		}
+		exit := attempt.StartTime
+		if attempt.OutcomeTime != nil {
+			exit = *attempt.OutcomeTime
+		}
+		trades = append(trades, perf.ClosedTrade{
+			Symbol:         attempt.InstrumentID,
+			ExitTime:       exit,
+			Volume:         1,
+			VolumeMultiple: 1,
+			PnL:            attempt.ProfitPoints,
+		})
+	}
+	// attempts 按合约分组输出，块重抽样需要按平仓时间排成一条路径。
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].ExitTime.Before(trades[j].ExitTime) })
+	return trades, MonteCarloUnitPoints, 0
+}
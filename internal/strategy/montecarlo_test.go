@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/perf"
+)
+
+// archivedResult 模拟从归档 JSON 读回的 result。
+func archivedResult(t *testing.T, value map[string]any) map[string]any {
+	t.Helper()
+	body, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestMonteCarloTradesPreferPerformanceReport(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	report := perf.Report{InitialBalance: 50_000, ClosedTrades: []perf.ClosedTrade{
+		{Symbol: "rb2405", ExitTime: base, Volume: 1, VolumeMultiple: 10, PnL: 30},
+		{Symbol: "rb2405", ExitTime: base.Add(time.Minute), Volume: 1, VolumeMultiple: 10, PnL: -12},
+	}}
+	trades, unit, initial := monteCarloTrades(archivedResult(t, map[string]any{"performance": report}))
+	if unit != MonteCarloUnitMoney || initial != 50_000 || len(trades) != 2 || trades[1].PnL != -12 || !trades[0].ExitTime.Equal(base) {
+		t.Fatalf("trades=%+v unit=%q initial=%v", trades, unit, initial)
+	}
+}
+
+func TestMonteCarloTradesFallBackToMA20Attempts(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	late, early := base.Add(2*time.Hour), base.Add(time.Hour)
+	attempts := []MA20AttemptRecord{
+		{InstrumentID: "rb2405", Outcome: MA20OutcomeSuccess, OutcomeTime: &late, ProfitPoints: 12},
+		{InstrumentID: "rb2405", Outcome: MA20OutcomeFiltered},
+		{InstrumentID: "hc2405", Outcome: MA20OutcomeFailure, OutcomeTime: &early, ProfitPoints: -7},
+		{InstrumentID: "hc2405", Outcome: MA20OutcomeUnresolved, ProfitPoints: 3},
+	}
+	trades, unit, _ := monteCarloTrades(archivedResult(t, map[string]any{"attempts": attempts}))
+	if unit != MonteCarloUnitPoints || len(trades) != 2 {
+		t.Fatalf("trades=%+v unit=%q", trades, unit)
+	}
+	if trades[0].Symbol != "hc2405" || trades[0].PnL != -7 || trades[1].PnL != 12 || trades[1].VolumeMultiple != 1 {
+		t.Fatalf("trades should be ordered by exit time: %+v", trades)
+	}
+	if got, _, _ := monteCarloTrades(map[string]any{}); len(got) != 0 {
+		t.Fatalf("empty result trades = %+v", got)
+	}
+}
+
+func TestRunMonteCarloMethodsDefaultsAndValidation(t *testing.T) {
+	methods, err := normalizeMonteCarloMethods(nil)
+	if err != nil || len(methods) != 2 || methods[0] != perf.MonteCarloBootstrap || methods[1] != perf.MonteCarloBlockBootstrap {
+		t.Fatalf("default methods = %v err=%v", methods, err)
+	}
+	if _, err := normalizeMonteCarloMethods([]string{"jackknife"}); err == nil {
+		t.Fatal("unknown method should be rejected")
+	}
+	trades := []perf.ClosedTrade{{PnL: 10}, {PnL: -4}, {PnL: 6}}
+	results := runMonteCarloMethods(trades, MonteCarloRequest{Methods: methods, Iterations: 50, Seed: 3}, 0)
+	if len(results) != 2 || results[0].Method != perf.MonteCarloBootstrap || results[1].BlockSize != 2 || results[1].Iterations != 50 {
+		t.Fatalf("results = %+v", results)
+	}
+	if results[0].Original.FinalPnL != 12 {
+		t.Fatalf("original path = %+v", results[0].Original)
+	}
+}
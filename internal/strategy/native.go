@@ -0,0 +1,332 @@
+// native.go 负责进程内 Go 策略运行时。
+// 延迟敏感的策略可以直接用 Go 实现并在编译期注册，Manager 用与 Python HTTP 服务相同的
+// DecisionRequest/SignalDecision 契约调用它们，trace、signal 与下单链路保持一致，但省去 HTTP 往返。
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NativeEntryScriptPrefix 标记 Go 策略定义的 entry_script，便于页面区分运行时。
+const NativeEntryScriptPrefix = "go:"
+
+// NativeStrategy 是进程内 Go 策略需要实现的生命周期接口。
+// 同一实例的回调由 Manager 串行调用，实现内部不需要额外加锁。
+type NativeStrategy interface {
+	// OnStart 在实例启动时调用；inst.Params 中已按 warmup 要求补齐 warmup_bars。
+	OnStart(ctx context.Context, inst StrategyInstance) error
+	// OnBar 处理 K 线事件，realtime 与 replay 共用。
+	OnBar(ctx context.Context, req DecisionRequest) (SignalDecision, error)
+	// OnTick 处理 tick 事件。
+	OnTick(ctx context.Context, req DecisionRequest) (SignalDecision, error)
+	// OnFill 在实例委托成交后调用。
+	OnFill(ctx context.Context, fill FillEvent) error
+	// OnStop 在实例停止时调用。
+	OnStop(ctx context.Context) error
+}
+
+// NativeWarmupRequirer 是 Go 策略可选实现的接口，用于声明启动前需要预热的 K 线数量。
+type NativeWarmupRequirer interface {
+	StartRequirements(inst StrategyInstance) StartRequirementsResponse
+}
+
+// NativeCheckpointer 是 Go 策略可选实现的接口，用于导出和恢复实例内部状态。
+// 进程重启时运行时先 OnStart（不带 warmup_bars），再 RestoreState，随后只补放检查点之后的 K 线。
+type NativeCheckpointer interface {
+	SnapshotState() ([]byte, error)
+	RestoreState(state []byte) error
+}
+
+// NativeStrategyFactory 为每个策略实例创建一个新的策略对象。
+type NativeStrategyFactory func() NativeStrategy
+
+// FillEvent 是推送给 Go 策略的成交回报。
+type FillEvent struct {
+	// InstanceID 是成交所属策略实例。
+	InstanceID string `json:"instance_id"`
+	// AccountID 是成交账户。
+	AccountID string `json:"account_id"`
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// ExchangeID 是交易所代码。
+	ExchangeID string `json:"exchange_id"`
+	// TradeID 是成交编号。
+	TradeID string `json:"trade_id"`
+	// OrderRef 是关联的报单引用。
+	OrderRef string `json:"order_ref"`
+	// Direction 是买卖方向。
+	Direction string `json:"direction"`
+	// OffsetFlag 是开平标志。
+	OffsetFlag string `json:"offset_flag"`
+	// Price 是成交价。
+	Price float64 `json:"price"`
+	// Volume 是成交手数。
+	Volume int `json:"volume"`
+	// TradeTime 是成交时间。
+	TradeTime time.Time `json:"trade_time"`
+	// Commission 是这笔成交的手续费。
+	Commission float64 `json:"commission,omitempty"`
+	// VolumeMultiple 是合约乘数，回放报告按它计算盈亏。
+	VolumeMultiple float64 `json:"volume_multiple,omitempty"`
+	// ReceivedAt 是系统收到成交回报的时间，用于决策链路延迟追踪。
+	ReceivedAt time.Time `json:"received_at,omitempty"`
+}
+
+type nativeRegistration struct {
+	definition StrategyDefinition
+	factory    NativeStrategyFactory
+}
+
+var (
+	nativeRegistryMu sync.RWMutex
+	nativeRegistry   = make(map[string]nativeRegistration)
+)
+
+// RegisterNativeStrategy 注册一个 Go 策略，通常在实现包的 init 中调用。
+// 策略 ID 为空、factory 为空或重复注册时 panic，与 database/sql.Register 的约定一致。
+func RegisterNativeStrategy(def StrategyDefinition, factory NativeStrategyFactory) {
+	id := strings.TrimSpace(def.StrategyID)
+	if id == "" {
+		panic("strategy: RegisterNativeStrategy with empty strategy_id")
+	}
+	if factory == nil {
+		panic("strategy: RegisterNativeStrategy factory is nil for " + id)
+	}
+	def.StrategyID = id
+	if strings.TrimSpace(def.DisplayName) == "" {
+		def.DisplayName = id
+	}
+	if strings.TrimSpace(def.EntryScript) == "" {
+		def.EntryScript = NativeEntryScriptPrefix + id
+	}
+	nativeRegistryMu.Lock()
+	defer nativeRegistryMu.Unlock()
+	if _, dup := nativeRegistry[id]; dup {
+		panic("strategy: RegisterNativeStrategy called twice for " + id)
+	}
+	nativeRegistry[id] = nativeRegistration{definition: def, factory: factory}
+}
+
+// NativeDefinitions 返回已注册的 Go 策略定义，按策略 ID 排序。
+func NativeDefinitions() []StrategyDefinition {
+	nativeRegistryMu.RLock()
+	out := make([]StrategyDefinition, 0, len(nativeRegistry))
+	for _, item := range nativeRegistry {
+		def := item.definition
+		if def.CodeHash == "" {
+			def.CodeHash = nativeCodeHash(def)
+		}
+		def.DefaultParams = cloneStrategyParams(def.DefaultParams)
+		out = append(out, def)
+	}
+	nativeRegistryMu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StrategyID < out[j].StrategyID })
+	return out
+}
+
+func lookupNativeStrategy(strategyID string) (nativeRegistration, bool) {
+	nativeRegistryMu.RLock()
+	defer nativeRegistryMu.RUnlock()
+	item, ok := nativeRegistry[strings.TrimSpace(strategyID)]
+	return item, ok
+}
+
+// IsNativeStrategyID 判断策略 ID 是否由 Go 运行时承载。
+func IsNativeStrategyID(strategyID string) bool {
+	_, ok := lookupNativeStrategy(strategyID)
+	return ok
+}
+
+// mergeNativeDefinitions 把 Go 策略定义并入 Python 列表；同 ID 以 Go 注册为准。
+func mergeNativeDefinitions(items []StrategyDefinition, now time.Time) []StrategyDefinition {
+	native := NativeDefinitions()
+	if len(native) == 0 {
+		return items
+	}
+	out := make([]StrategyDefinition, 0, len(items)+len(native))
+	for _, item := range items {
+		if !IsNativeStrategyID(item.StrategyID) {
+			out = append(out, item)
+		}
+	}
+	for _, def := range native {
+		if def.UpdatedAt.IsZero() {
+			def.UpdatedAt = now
+		}
+		out = append(out, def)
+	}
+	return out
+}
+
+// nativeInstance 是一个运行中的 Go 策略实例；mu 保证同一实例回调串行执行。
+type nativeInstance struct {
+	mu       sync.Mutex
+	strategy NativeStrategy
+}
+
+// NativeRuntime 承载所有运行中的 Go 策略实例，方法集与 StrategyServiceClient 对齐，
+// 使 Manager 的启动、预热、决策和停止流程可以不区分运行时。
+type NativeRuntime struct {
+	mu        sync.RWMutex
+	instances map[string]*nativeInstance
+}
+
+// NewNativeRuntime 创建空的 Go 策略运行时。
+func NewNativeRuntime() *NativeRuntime {
+	return &NativeRuntime{instances: make(map[string]*nativeInstance)}
+}
+
+// LoadStrategy 校验策略已注册；Go 策略随二进制编译，Reload 不会换入新代码，热重载只按同一代码重建实例。
+func (r *NativeRuntime) LoadStrategy(_ context.Context, req LoadStrategyRequest) error {
+	if _, ok := lookupNativeStrategy(req.StrategyID); !ok {
+		return fmt.Errorf("native strategy not registered: %s", req.StrategyID)
+	}
+	return nil
+}
+
+// GetStartRequirements 返回策略声明的预热要求，未实现 NativeWarmupRequirer 时无需预热。
+func (r *NativeRuntime) GetStartRequirements(_ context.Context, req StartRequirementsRequest) (StartRequirementsResponse, error) {
+	reg, ok := lookupNativeStrategy(req.Instance.StrategyID)
+	if !ok {
+		return StartRequirementsResponse{}, fmt.Errorf("native strategy not registered: %s", req.Instance.StrategyID)
+	}
+	if requirer, ok := reg.factory().(NativeWarmupRequirer); ok {
+		return requirer.StartRequirements(req.Instance), nil
+	}
+	return StartRequirementsResponse{}, nil
+}
+
+// StartInstance 创建新的策略对象并调用 OnStart；同 ID 实例已在运行时先停止旧实例。
+func (r *NativeRuntime) StartInstance(ctx context.Context, req StartInstanceRequest) error {
+	reg, ok := lookupNativeStrategy(req.Instance.StrategyID)
+	if !ok {
+		return fmt.Errorf("native strategy not registered: %s", req.Instance.StrategyID)
+	}
+	instanceID := strings.TrimSpace(req.Instance.InstanceID)
+	if instanceID == "" {
+		return fmt.Errorf("instance_id is required")
+	}
+	_ = r.StopInstance(ctx, StopInstanceRequest{InstanceID: instanceID})
+	item := &nativeInstance{strategy: reg.factory()}
+	if err := item.strategy.OnStart(ctx, req.Instance); err != nil {
+		return err
+	}
+	if req.Checkpoint != nil {
+		checkpointer, ok := item.strategy.(NativeCheckpointer)
+		if !ok {
+			return fmt.Errorf("native strategy does not support checkpoint restore: %s", req.Instance.StrategyID)
+		}
+		if err := checkpointer.RestoreState(req.Checkpoint.State); err != nil {
+			return fmt.Errorf("restore native strategy checkpoint failed: %w", err)
+		}
+	}
+	r.mu.Lock()
+	r.instances[instanceID] = item
+	r.mu.Unlock()
+	return nil
+}
+
+// StopInstance 调用 OnStop 并移除实例；实例不存在时忽略。
+func (r *NativeRuntime) StopInstance(ctx context.Context, req StopInstanceRequest) error {
+	r.mu.Lock()
+	item, ok := r.instances[req.InstanceID]
+	delete(r.instances, req.InstanceID)
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item.strategy.OnStop(ctx)
+}
+
+func (r *NativeRuntime) instance(instanceID string) (*nativeInstance, error) {
+	r.mu.RLock()
+	item, ok := r.instances[instanceID]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("native runtime instance not started: %s", instanceID)
+	}
+	return item, nil
+}
+
+// Running 判断实例是否已在 Go 运行时中启动。
+func (r *NativeRuntime) Running(instanceID string) bool {
+	_, err := r.instance(instanceID)
+	return err == nil
+}
+
+// SnapshotInstance 导出实例状态；策略未实现 NativeCheckpointer 时返回 Supported=false。
+func (r *NativeRuntime) SnapshotInstance(_ context.Context, req SnapshotInstanceRequest) (SnapshotInstanceResponse, error) {
+	item, err := r.instance(req.InstanceID)
+	if err != nil {
+		return SnapshotInstanceResponse{}, err
+	}
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	checkpointer, ok := item.strategy.(NativeCheckpointer)
+	if !ok {
+		return SnapshotInstanceResponse{}, nil
+	}
+	state, err := checkpointer.SnapshotState()
+	if err != nil {
+		return SnapshotInstanceResponse{}, err
+	}
+	return SnapshotInstanceResponse{Supported: true, State: state}, nil
+}
+
+// OnTick 把 tick 决策请求交给实例。
+func (r *NativeRuntime) OnTick(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
+	item, err := r.instance(req.Instance.InstanceID)
+	if err != nil {
+		return SignalDecision{}, err
+	}
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	decision, err := item.strategy.OnTick(ctx, req)
+	return normalizeNativeDecision(req, decision, err)
+}
+
+// OnBar 把实盘 K 线决策请求交给实例。
+func (r *NativeRuntime) OnBar(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
+	item, err := r.instance(req.Instance.InstanceID)
+	if err != nil {
+		return SignalDecision{}, err
+	}
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	decision, err := item.strategy.OnBar(ctx, req)
+	return normalizeNativeDecision(req, decision, err)
+}
+
+// OnReplayBar 与 OnBar 相同；Go 策略通过 req.Mode 区分回放。
+func (r *NativeRuntime) OnReplayBar(ctx context.Context, req DecisionRequest) (SignalDecision, error) {
+	return r.OnBar(ctx, req)
+}
+
+// OnFill 把成交回报推给实例；实例未运行时忽略。
+func (r *NativeRuntime) OnFill(ctx context.Context, fill FillEvent) error {
+	item, err := r.instance(fill.InstanceID)
+	if err != nil {
+		return nil
+	}
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item.strategy.OnFill(ctx, fill)
+}
+
+// normalizeNativeDecision 补齐策略未填写的实例、合约和事件时间，与 Python 服务返回保持一致。
+func normalizeNativeDecision(req DecisionRequest, decision SignalDecision, err error) (SignalDecision, error) {
+	if err != nil {
+		return SignalDecision{}, err
+	}
+	decision.InstanceID = firstNonEmpty(decision.InstanceID, req.Instance.InstanceID)
+	decision.Symbol = firstNonEmpty(decision.Symbol, req.Symbol)
+	decision.EventTime = firstNonEmpty(decision.EventTime, req.EventTime)
+	return decision, nil
+}
@@ -0,0 +1,101 @@
+// native_sample.go 是 Go 运行时的示例动量策略，与 python/strategy_sample.py 的判断规则一致，
+// 用于验证 Go 策略注册、决策、trace 和成交回调链路，不是生产算法。
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// NativeSampleMomentumID 是 Go 示例动量策略的策略 ID。
+const NativeSampleMomentumID = "go.sample.momentum"
+
+func init() {
+	RegisterNativeStrategy(StrategyDefinition{
+		StrategyID:    NativeSampleMomentumID,
+		DisplayName:   "Go Sample Momentum",
+		Kind:          "primary",
+		Version:       "1.0.0",
+		DefaultParams: map[string]any{"threshold": 0.2},
+	}, func() NativeStrategy { return &nativeSampleMomentum{} })
+}
+
+// nativeSampleMomentum 在 K 线收阳时目标仓位 1、收阴时 -1；tick 按最新价相对一档盘口判断。
+type nativeSampleMomentum struct {
+	fills int
+}
+
+func (s *nativeSampleMomentum) OnStart(context.Context, StrategyInstance) error { return nil }
+
+func (s *nativeSampleMomentum) OnStop(context.Context) error { return nil }
+
+func (s *nativeSampleMomentum) OnFill(_ context.Context, _ FillEvent) error {
+	s.fills++
+	return nil
+}
+
+// nativeSampleMomentumState 是示例策略的检查点内容。
+type nativeSampleMomentumState struct {
+	Fills int `json:"fills"`
+}
+
+func (s *nativeSampleMomentum) SnapshotState() ([]byte, error) {
+	return json.Marshal(nativeSampleMomentumState{Fills: s.fills})
+}
+
+func (s *nativeSampleMomentum) RestoreState(state []byte) error {
+	var st nativeSampleMomentumState
+	if err := json.Unmarshal(state, &st); err != nil {
+		return err
+	}
+	s.fills = st.Fills
+	return nil
+}
+
+func (s *nativeSampleMomentum) OnBar(_ context.Context, req DecisionRequest) (SignalDecision, error) {
+	if req.Bar == nil {
+		return SignalDecision{NoSignal: true}, nil
+	}
+	delta := req.Bar.Close - req.Bar.Open
+	decision := s.decision(sampleDirection(delta), "bar momentum", map[string]any{"bar_delta": delta})
+	decision.Trace = &StrategyTraceRecord{EventType: "bar", Reason: decision.Reason, Metrics: decision.Metrics}
+	return decision, nil
+}
+
+func (s *nativeSampleMomentum) OnTick(_ context.Context, req DecisionRequest) (SignalDecision, error) {
+	if req.Tick == nil {
+		return SignalDecision{NoSignal: true}, nil
+	}
+	last := req.Tick.LastPrice
+	bid, ask := req.Tick.BidPrice1, req.Tick.AskPrice1
+	if bid <= 0 {
+		bid = last
+	}
+	if ask <= 0 {
+		ask = last
+	}
+	target := 0.0
+	switch {
+	case last >= ask:
+		target = 1
+	case last <= bid:
+		target = -1
+	}
+	return s.decision(target, "tick momentum", map[string]any{"spread": ask - bid}), nil
+}
+
+func (s *nativeSampleMomentum) decision(target float64, reason string, metrics map[string]any) SignalDecision {
+	metrics["fills"] = s.fills
+	return SignalDecision{TargetPosition: target, Confidence: 0.5, Reason: reason, Metrics: metrics}
+}
+
+func sampleDirection(delta float64) float64 {
+	switch {
+	case delta > 0:
+		return 1
+	case delta < 0:
+		return -1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,141 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+const testNativeRecorderID = "test.native.recorder"
+
+type recordingNativeStrategy struct {
+	events []string
+}
+
+func (s *recordingNativeStrategy) OnStart(_ context.Context, inst StrategyInstance) error {
+	s.events = append(s.events, "start:"+inst.InstanceID)
+	return nil
+}
+
+func (s *recordingNativeStrategy) OnBar(_ context.Context, req DecisionRequest) (SignalDecision, error) {
+	s.events = append(s.events, "bar:"+req.Mode)
+	return SignalDecision{TargetPosition: 1}, nil
+}
+
+func (s *recordingNativeStrategy) OnTick(context.Context, DecisionRequest) (SignalDecision, error) {
+	s.events = append(s.events, "tick")
+	return SignalDecision{NoSignal: true}, nil
+}
+
+func (s *recordingNativeStrategy) OnFill(_ context.Context, fill FillEvent) error {
+	s.events = append(s.events, "fill:"+fill.TradeID)
+	return nil
+}
+
+func (s *recordingNativeStrategy) OnStop(context.Context) error {
+	s.events = append(s.events, "stop")
+	return nil
+}
+
+func (s *recordingNativeStrategy) StartRequirements(StrategyInstance) StartRequirementsResponse {
+	return StartRequirementsResponse{WarmupTarget: 3, RequiresAnchorTime: true}
+}
+
+var lastRecordingStrategy *recordingNativeStrategy
+
+func init() {
+	RegisterNativeStrategy(StrategyDefinition{StrategyID: testNativeRecorderID}, func() NativeStrategy {
+		lastRecordingStrategy = &recordingNativeStrategy{}
+		return lastRecordingStrategy
+	})
+}
+
+func TestNativeRuntimeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	rt := NewNativeRuntime()
+	inst := StrategyInstance{InstanceID: "inst-go", StrategyID: testNativeRecorderID}
+
+	if err := rt.LoadStrategy(ctx, LoadStrategyRequest{StrategyID: "missing"}); err == nil {
+		t.Fatal("LoadStrategy(missing) error = nil")
+	}
+	req, err := rt.GetStartRequirements(ctx, StartRequirementsRequest{Instance: inst})
+	if err != nil || req.WarmupTarget != 3 || !req.RequiresAnchorTime {
+		t.Fatalf("GetStartRequirements() = %+v, %v", req, err)
+	}
+	if _, err := rt.OnBar(ctx, DecisionRequest{Instance: inst}); err == nil {
+		t.Fatal("OnBar before start error = nil")
+	}
+	if err := rt.StartInstance(ctx, StartInstanceRequest{Instance: inst}); err != nil {
+		t.Fatalf("StartInstance() error = %v", err)
+	}
+	recorder := lastRecordingStrategy
+	decision, err := rt.OnReplayBar(ctx, DecisionRequest{Instance: inst, Symbol: "rb2405", EventTime: "2026-03-02T09:00:00+08:00", Mode: RunTypeReplay})
+	if err != nil {
+		t.Fatalf("OnReplayBar() error = %v", err)
+	}
+	if decision.InstanceID != "inst-go" || decision.Symbol != "rb2405" || decision.EventTime == "" || decision.TargetPosition != 1 {
+		t.Fatalf("decision = %+v", decision)
+	}
+	if err := rt.OnFill(ctx, FillEvent{InstanceID: "inst-go", TradeID: "t1"}); err != nil {
+		t.Fatalf("OnFill() error = %v", err)
+	}
+	if err := rt.OnFill(ctx, FillEvent{InstanceID: "other", TradeID: "t2"}); err != nil {
+		t.Fatalf("OnFill(other) error = %v", err)
+	}
+	if err := rt.StopInstance(ctx, StopInstanceRequest{InstanceID: "inst-go"}); err != nil {
+		t.Fatalf("StopInstance() error = %v", err)
+	}
+	if rt.Running("inst-go") {
+		t.Fatal("instance still running after stop")
+	}
+	want := []string{"start:inst-go", "bar:replay", "fill:t1", "stop"}
+	if len(recorder.events) != len(want) {
+		t.Fatalf("events = %v, want %v", recorder.events, want)
+	}
+	for i := range want {
+		if recorder.events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", recorder.events, want)
+		}
+	}
+}
+
+func TestMergeNativeDefinitionsOverridesPythonDuplicates(t *testing.T) {
+	t.Parallel()
+
+	items := mergeNativeDefinitions([]StrategyDefinition{
+		{StrategyID: "sample.momentum", EntryScript: "python/strategy_service.py"},
+		{StrategyID: NativeSampleMomentumID, EntryScript: "python/stale.py"},
+	}, time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local))
+	seen := map[string]StrategyDefinition{}
+	for _, item := range items {
+		if _, dup := seen[item.StrategyID]; dup {
+			t.Fatalf("duplicate definition %q in %+v", item.StrategyID, items)
+		}
+		seen[item.StrategyID] = item
+	}
+	if _, ok := seen["sample.momentum"]; !ok {
+		t.Fatalf("python definition dropped: %+v", items)
+	}
+	if got := seen[NativeSampleMomentumID].EntryScript; got != NativeEntryScriptPrefix+NativeSampleMomentumID {
+		t.Fatalf("native entry_script = %q", got)
+	}
+	if seen[testNativeRecorderID].UpdatedAt.IsZero() {
+		t.Fatal("native definition updated_at not filled")
+	}
+}
+
+func TestManagerRuntimeForRoutesNativeStrategies(t *testing.T) {
+	t.Parallel()
+
+	m := &Manager{native: NewNativeRuntime()}
+	if rt := m.runtimeFor(NativeSampleMomentumID); rt != strategyRuntime(m.native) {
+		t.Fatalf("runtimeFor(native) = %T", rt)
+	}
+	if rt := m.runtimeFor("sample.momentum"); rt != nil {
+		t.Fatalf("runtimeFor(python) without client = %T, want nil", rt)
+	}
+	m.client = NewStrategyServiceClient("127.0.0.1:1", nil)
+	if _, ok := m.runtimeFor("sample.momentum").(*StrategyServiceClient); !ok {
+		t.Fatal("runtimeFor(python) should return http client")
+	}
+}
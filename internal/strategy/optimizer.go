@@ -0,0 +1,738 @@
+// optimizer.go 负责 Go 侧的参数优化任务：把区间切成滚动的样本内/样本外窗口，
+// 在每个样本内窗口用网格、随机或贝叶斯搜索挑出目标最优的参数，再到紧随其后的样本外区间检验。
+// 每个试验都是一次组合回测，结果作为 optimize_trial 运行记录保存（instance_id 指向优化任务），
+// 任务中断后按“窗口+区间+参数”匹配已完成的试验即可续跑。
+package strategy
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OptimizeMethodGrid     = "grid"
+	OptimizeMethodRandom   = "random"
+	OptimizeMethodBayesian = "bayesian"
+
+	OptimizeObjectiveSharpe       = "sharpe"
+	OptimizeObjectiveProfitFactor = "profit_factor"
+	OptimizeObjectiveMaxDrawdown  = "max_drawdown"
+	OptimizeObjectiveNetProfit    = "net_profit"
+	OptimizeObjectiveReturn       = "return"
+	OptimizeObjectiveWinRate      = "win_rate"
+	OptimizeObjectiveSortino      = "sortino"
+	OptimizeObjectiveCalmar       = "calmar"
+
+	// OptimizeEnginePython 是 ParameterSweepRequest.Engine 转发给 Python 服务的取值。
+	OptimizeEnginePython = "python"
+
+	optimizeSegmentInSample    = "is"
+	optimizeSegmentOutOfSample = "oos"
+
+	// maxGridTrials 是网格搜索单个窗口允许的组合数上限。
+	maxGridTrials = 10000
+	// defaultRandomTrials 是随机/贝叶斯搜索未指定 max_trials 时的试验数。
+	defaultRandomTrials = 30
+	// maxBayesianTrials 是贝叶斯搜索单个窗口的试验上限，高斯过程拟合的代价随观测数立方增长。
+	maxBayesianTrials = 500
+	// maxOptimizeWorkers 是并行回测数上限。
+	maxOptimizeWorkers = 16
+	// defaultOutOfSampleRatio 是滚动窗口默认的样本外占比。
+	defaultOutOfSampleRatio = 0.25
+)
+
+// optimizeObjectives 是可选的排序目标，键名与 perf.Report.Summary 一致：
+// Go 组合回测的摘要来自 perf 绩效分析，Python 回测摘要也需要给出同名字段。
+var optimizeObjectives = []string{
+	OptimizeObjectiveSharpe,
+	OptimizeObjectiveSortino,
+	OptimizeObjectiveCalmar,
+	OptimizeObjectiveProfitFactor,
+	OptimizeObjectiveMaxDrawdown,
+	OptimizeObjectiveNetProfit,
+	OptimizeObjectiveReturn,
+	OptimizeObjectiveWinRate,
+}
+
+// optimizeWindow 是一个样本内/样本外窗口；没有样本外区间时 OutOfSampleEnd 为零值。
+type optimizeWindow struct {
+	Index            int       `json:"index"`
+	InSampleStart    time.Time `json:"in_sample_start"`
+	InSampleEnd      time.Time `json:"in_sample_end"`
+	OutOfSampleStart time.Time `json:"out_of_sample_start"`
+	OutOfSampleEnd   time.Time `json:"out_of_sample_end"`
+}
+
+func (w optimizeWindow) hasOutOfSample() bool {
+	return !w.OutOfSampleEnd.IsZero()
+}
+
+// optimizationTrial 是一次试验的结果，也是 optimize_trial 运行记录的 summary 结构。
+type optimizationTrial struct {
+	RunID   string         `json:"run_id"`
+	Window  int            `json:"window"`
+	Segment string         `json:"segment"`
+	Indices []int          `json:"indices"`
+	Params  map[string]any `json:"params"`
+	Key     string         `json:"key"`
+	Score   float64        `json:"score"`
+	Metrics map[string]any `json:"metrics,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// optimizationWindowResult 是一个窗口的优化结论。
+type optimizationWindowResult struct {
+	optimizeWindow
+	Trials             int            `json:"trials"`
+	BestParams         map[string]any `json:"best_params"`
+	InSampleScore      float64        `json:"in_sample_score"`
+	InSampleMetrics    map[string]any `json:"in_sample_metrics,omitempty"`
+	OutOfSampleScore   *float64       `json:"out_of_sample_score,omitempty"`
+	OutOfSampleMetrics map[string]any `json:"out_of_sample_metrics,omitempty"`
+	OutOfSampleError   string         `json:"out_of_sample_error,omitempty"`
+}
+
+// OptimizationHeatmap 是两个参数的稳健性热力图：每格是该参数组合在全部样本内窗口、
+// 其余参数任意取值下的平均目标值，相邻格子变化平缓说明参数不敏感。
+type OptimizationHeatmap struct {
+	X       string       `json:"x"`
+	Y       string       `json:"y,omitempty"`
+	XValues []any        `json:"x_values"`
+	YValues []any        `json:"y_values"`
+	Cells   [][]*float64 `json:"cells"`
+	Counts  [][]int      `json:"counts"`
+}
+
+// optimizeJob 是一次优化任务的运行状态。
+type optimizeJob struct {
+	req        ParameterSweepRequest
+	space      paramSpace
+	windows    []optimizeWindow
+	symbols    []string
+	backtester PortfolioBacktester
+	rt         BacktestRuntime
+	cached     map[string]optimizationTrial
+
+	mu     sync.Mutex
+	run    StrategyRun
+	trials []optimizationTrial
+}
+
+// RunParameterSweep 启动参数优化。engine=python 时沿用 Python 服务的同步网格扫描，
+// 否则在 Go 组合回测引擎上异步执行，立即返回 running 状态的任务记录。
+func (m *Manager) RunParameterSweep(req ParameterSweepRequest) (StrategyRun, error) {
+	if strings.EqualFold(strings.TrimSpace(req.Engine), OptimizeEnginePython) {
+		return m.runPythonParameterSweep(req)
+	}
+	return m.startOptimization(req, time.Now())
+}
+
+// ResumeOptimization 续跑未完成的优化任务：沿用保存的请求（含随机种子），已完成的试验直接复用。
+func (m *Manager) ResumeOptimization(runID string) (StrategyRun, error) {
+	run, err := m.store.GetRun(runID)
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	if run.RunType != RunTypeOptimize {
+		return StrategyRun{}, fmt.Errorf("run %s is not an optimization", runID)
+	}
+	if run.Status == "done" {
+		return run, nil
+	}
+	req, err := optimizationRequestFromSummary(run.Summary)
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	req.RunID = run.RunID
+	return m.startOptimization(req, run.StartedAt)
+}
+
+// ListOptimizationTrials 返回优化任务已保存的全部试验。
+func (m *Manager) ListOptimizationTrials(runID string) ([]StrategyRun, error) {
+	return m.store.ListRunsByInstance(runID)
+}
+
+func (m *Manager) runPythonParameterSweep(req ParameterSweepRequest) (StrategyRun, error) {
+	m.mu.RLock()
+	client := m.client
+	m.mu.RUnlock()
+	if client == nil {
+		return StrategyRun{}, fmt.Errorf("strategy http client not connected")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	resp, err := client.RunParameterSweep(ctx, req)
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	run := StrategyRun{
+		RunID:      resp.RunID,
+		StrategyID: req.StrategyID,
+		RunType:    RunTypeOptimize,
+		Status:     resp.Status,
+		Symbol:     req.Symbol,
+		Timeframe:  req.Timeframe,
+		Summary:    resp.Summary,
+		StartedAt:  time.Now(),
+	}
+	if err := m.store.SaveRun(run); err != nil {
+		return StrategyRun{}, err
+	}
+	m.broadcast("strategy_backtest_done", run)
+	return run, nil
+}
+
+func (m *Manager) startOptimization(req ParameterSweepRequest, startedAt time.Time) (StrategyRun, error) {
+	space, windows, symbols, err := normalizeOptimizationRequest(&req)
+	if err != nil {
+		return StrategyRun{}, err
+	}
+	m.mu.Lock()
+	backtester := m.portfolioBacktester
+	if m.optimizing == nil {
+		m.optimizing = make(map[string]struct{})
+	}
+	if _, running := m.optimizing[req.RunID]; running {
+		m.mu.Unlock()
+		return StrategyRun{}, fmt.Errorf("optimization %s is already running", req.RunID)
+	}
+	m.optimizing[req.RunID] = struct{}{}
+	m.mu.Unlock()
+	release := func() {
+		m.mu.Lock()
+		delete(m.optimizing, req.RunID)
+		m.mu.Unlock()
+	}
+	if backtester == nil {
+		release()
+		return StrategyRun{}, fmt.Errorf("portfolio backtest engine is not configured")
+	}
+	rt := m.runtimeFor(req.StrategyID)
+	if rt == nil {
+		release()
+		return StrategyRun{}, fmt.Errorf("strategy http client not connected")
+	}
+	run := StrategyRun{
+		RunID:      req.RunID,
+		StrategyID: req.StrategyID,
+		RunType:    RunTypeOptimize,
+		Status:     "running",
+		Symbol:     strings.Join(symbols, ","),
+		Timeframe:  req.Timeframe,
+		StartedAt:  startedAt,
+		Summary: map[string]any{
+			"request":          req,
+			"method":           req.Method,
+			"objective":        req.Objective,
+			"windows":          windows,
+			"completed_trials": 0,
+		},
+	}
+	if err := m.store.SaveRun(run); err != nil {
+		release()
+		return StrategyRun{}, err
+	}
+	job := &optimizeJob{
+		req:        req,
+		space:      space,
+		windows:    windows,
+		symbols:    symbols,
+		backtester: backtester,
+		rt:         rt,
+		run:        run,
+	}
+	go m.runOptimization(job)
+	return run, nil
+}
+
+func (m *Manager) runOptimization(job *optimizeJob) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.optimizing, job.req.RunID)
+		m.mu.Unlock()
+	}()
+	job.cached = make(map[string]optimizationTrial)
+	if prior, err := m.store.ListRunsByInstance(job.req.RunID); err == nil {
+		for _, run := range prior {
+			if run.RunType != RunTypeOptimizeTrial || run.Status != "done" {
+				continue
+			}
+			if trial, ok := optimizationTrialFromSummary(run.Summary); ok {
+				job.cached[trialCacheKey(trial.Window, trial.Segment, trial.Key)] = trial
+			}
+		}
+	}
+	ctx := context.Background()
+	results := make([]optimizationWindowResult, 0, len(job.windows))
+	for _, w := range job.windows {
+		trials := m.searchWindow(ctx, job, w)
+		best, ok := bestOptimizationTrial(trials)
+		if !ok {
+			m.failOptimization(job, fmt.Errorf("window %d: no successful trial", w.Index))
+			return
+		}
+		res := optimizationWindowResult{
+			optimizeWindow:  w,
+			Trials:          len(trials),
+			BestParams:      best.Params,
+			InSampleScore:   best.Score,
+			InSampleMetrics: best.Metrics,
+		}
+		if w.hasOutOfSample() {
+			oos := m.evaluateTrials(ctx, job, w, optimizeSegmentOutOfSample, [][]int{best.Indices})[0]
+			if oos.Error != "" {
+				res.OutOfSampleError = oos.Error
+			} else {
+				score := oos.Score
+				res.OutOfSampleScore = &score
+				res.OutOfSampleMetrics = oos.Metrics
+			}
+		}
+		results = append(results, res)
+		job.mu.Lock()
+		job.run.Summary["window_results"] = results
+		run := job.run
+		job.mu.Unlock()
+		_ = m.store.SaveRun(run)
+	}
+	m.finishOptimization(job, results)
+}
+
+// searchWindow 在一个样本内窗口上按指定方式搜索参数。
+func (m *Manager) searchWindow(ctx context.Context, job *optimizeJob, w optimizeWindow) []optimizationTrial {
+	rng := rand.New(rand.NewSource(job.req.Seed + int64(w.Index)))
+	switch job.req.Method {
+	case OptimizeMethodRandom:
+		return m.evaluateTrials(ctx, job, w, optimizeSegmentInSample, job.space.randomCandidates(rng, job.req.MaxTrials, nil))
+	case OptimizeMethodBayesian:
+		search := newBayesianSearch(job.space, rng)
+		tried := make(map[string]bool)
+		var trials []optimizationTrial
+		var observed []searchObservation
+		for len(trials) < job.req.MaxTrials {
+			batch := search.propose(observed, min(job.req.Workers, job.req.MaxTrials-len(trials)), tried)
+			if len(batch) == 0 {
+				break
+			}
+			for _, cand := range batch {
+				tried[indicesKey(cand)] = true
+			}
+			for _, trial := range m.evaluateTrials(ctx, job, w, optimizeSegmentInSample, batch) {
+				trials = append(trials, trial)
+				if trial.Error == "" {
+					observed = append(observed, searchObservation{indices: trial.Indices, score: trial.Score})
+				}
+			}
+		}
+		return trials
+	default:
+		return m.evaluateTrials(ctx, job, w, optimizeSegmentInSample, job.space.gridCandidates())
+	}
+}
+
+// evaluateTrials 用 Workers 个并行回测评估候选，结果顺序与候选一致。
+func (m *Manager) evaluateTrials(ctx context.Context, job *optimizeJob, w optimizeWindow, segment string, candidates [][]int) []optimizationTrial {
+	out := make([]optimizationTrial, len(candidates))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < min(job.req.Workers, len(candidates)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				out[idx] = m.runTrial(ctx, job, w, segment, candidates[idx])
+			}
+		}()
+	}
+	for i := range candidates {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out
+}
+
+func (m *Manager) runTrial(ctx context.Context, job *optimizeJob, w optimizeWindow, segment string, indices []int) optimizationTrial {
+	params := job.space.params(job.req.BaseParams, indices)
+	key := paramsKey(params)
+	if cached, ok := job.cached[trialCacheKey(w.Index, segment, key)]; ok {
+		cached.Indices = indices
+		job.recordTrial(m, cached)
+		return cached
+	}
+	sum := sha1.Sum([]byte(key))
+	trial := optimizationTrial{
+		RunID:   fmt.Sprintf("%s-w%d-%s-%s", job.req.RunID, w.Index, segment, hex.EncodeToString(sum[:5])),
+		Window:  w.Index,
+		Segment: segment,
+		Indices: indices,
+		Params:  params,
+		Key:     key,
+	}
+	start, end := w.InSampleStart, w.InSampleEnd
+	if segment == optimizeSegmentOutOfSample {
+		start, end = w.OutOfSampleStart, w.OutOfSampleEnd
+	}
+	startedAt := time.Now()
+	resp, err := job.backtester(ctx, job.rt, PortfolioBacktestConfig{
+		Instance: StrategyInstance{
+			InstanceID: trial.RunID,
+			StrategyID: job.req.StrategyID,
+			Mode:       RunTypeBacktest,
+			Symbols:    job.symbols,
+			Timeframe:  job.req.Timeframe,
+			Params:     params,
+		},
+		Symbols:        job.symbols,
+		Timeframe:      job.req.Timeframe,
+		Start:          start,
+		End:            end,
+		InitialBalance: job.req.InitialBalance,
+	})
+	if err == nil {
+		trial.Metrics = resp.Summary
+		score, ok := optimizationObjectiveScore(resp.Summary, job.req.Objective)
+		if ok {
+			trial.Score = score
+		} else {
+			err = fmt.Errorf("objective %s missing from backtest summary", job.req.Objective)
+		}
+	}
+	status := "done"
+	if err != nil {
+		trial.Error = err.Error()
+		status = InstanceStatusError
+	}
+	finished := time.Now()
+	_ = m.store.SaveRun(StrategyRun{
+		RunID:      trial.RunID,
+		InstanceID: job.req.RunID,
+		StrategyID: job.req.StrategyID,
+		RunType:    RunTypeOptimizeTrial,
+		Status:     status,
+		Symbol:     strings.Join(job.symbols, ","),
+		Timeframe:  job.req.Timeframe,
+		Summary:    optimizationTrialSummary(trial),
+		StartedAt:  startedAt,
+		FinishedAt: &finished,
+		LastError:  trial.Error,
+	})
+	job.recordTrial(m, trial)
+	return trial
+}
+
+func (job *optimizeJob) recordTrial(m *Manager, trial optimizationTrial) {
+	job.mu.Lock()
+	job.trials = append(job.trials, trial)
+	job.run.Summary["completed_trials"] = len(job.trials)
+	progress := map[string]any{
+		"run_id":           job.req.RunID,
+		"window":           trial.Window,
+		"segment":          trial.Segment,
+		"completed_trials": len(job.trials),
+		"trial":            trial,
+	}
+	job.mu.Unlock()
+	m.broadcast("strategy_optimize_progress", progress)
+}
+
+func (m *Manager) failOptimization(job *optimizeJob, err error) {
+	job.mu.Lock()
+	job.run.Status = InstanceStatusError
+	job.run.LastError = err.Error()
+	finished := time.Now()
+	job.run.FinishedAt = &finished
+	run := job.run
+	job.mu.Unlock()
+	_ = m.store.SaveRun(run)
+	m.broadcast("strategy_backtest_done", run)
+}
+
+func (m *Manager) finishOptimization(job *optimizeJob, results []optimizationWindowResult) {
+	job.mu.Lock()
+	trials := append([]optimizationTrial(nil), job.trials...)
+	job.mu.Unlock()
+	heatmap := buildOptimizationHeatmap(job.space, trials, job.req.HeatmapParams)
+	summary := map[string]any{
+		"request":          job.req,
+		"method":           job.req.Method,
+		"objective":        job.req.Objective,
+		"windows":          job.windows,
+		"window_results":   results,
+		"completed_trials": len(trials),
+		"best_params":      results[len(results)-1].BestParams,
+		"heatmap":          heatmap,
+	}
+	var isSum, oosSum float64
+	oosCount := 0
+	for _, res := range results {
+		isSum += res.InSampleScore
+		if res.OutOfSampleScore != nil {
+			oosSum += *res.OutOfSampleScore
+			oosCount++
+		}
+	}
+	isMean := isSum / float64(len(results))
+	summary["in_sample_score"] = isMean
+	if oosCount > 0 {
+		oosMean := oosSum / float64(oosCount)
+		summary["out_of_sample_score"] = oosMean
+		if isMean != 0 {
+			summary["walk_forward_efficiency"] = oosMean / isMean
+		}
+	}
+	job.mu.Lock()
+	job.run.Status = "done"
+	job.run.Summary = summary
+	finished := time.Now()
+	job.run.FinishedAt = &finished
+	run := job.run
+	job.mu.Unlock()
+	resp := BacktestResponse{
+		RunID:   run.RunID,
+		Status:  run.Status,
+		Summary: summary,
+		Result: map[string]any{
+			"window_results": results,
+			"heatmap":        heatmap,
+			"trials":         trials,
+		},
+	}
+	if outputPath, err := m.writeBacktestOutput(run, job.req, resp); err == nil {
+		run.OutputPath = outputPath
+	} else {
+		run.LastError = err.Error()
+	}
+	_ = m.store.SaveRun(run)
+	m.broadcast("strategy_backtest_done", run)
+}
+
+// normalizeOptimizationRequest 补齐默认值并校验请求，返回搜索空间、窗口和合约列表。
+func normalizeOptimizationRequest(req *ParameterSweepRequest) (paramSpace, []optimizeWindow, []string, error) {
+	req.StrategyID = strings.TrimSpace(req.StrategyID)
+	if req.StrategyID == "" {
+		return paramSpace{}, nil, nil, fmt.Errorf("strategy_id is required")
+	}
+	symbols := NormalizeBacktestSymbols(append(append([]string(nil), req.Symbols...), strings.Split(req.Symbol, ",")...))
+	if len(symbols) == 0 {
+		return paramSpace{}, nil, nil, fmt.Errorf("symbol is required")
+	}
+	space, err := newParamSpace(req.Grid)
+	if err != nil {
+		return paramSpace{}, nil, nil, err
+	}
+	req.Timeframe = firstNonEmpty(strings.TrimSpace(req.Timeframe), "1m")
+	req.Method = strings.ToLower(firstNonEmpty(strings.TrimSpace(req.Method), OptimizeMethodGrid))
+	switch req.Method {
+	case OptimizeMethodGrid:
+		if size := space.size(); size > maxGridTrials || (req.MaxTrials > 0 && size > req.MaxTrials) {
+			return paramSpace{}, nil, nil, fmt.Errorf("grid has %d combinations, exceeds limit; use random or bayesian search", size)
+		}
+	case OptimizeMethodRandom, OptimizeMethodBayesian:
+		if req.MaxTrials <= 0 {
+			req.MaxTrials = defaultRandomTrials
+		}
+		req.MaxTrials = min(req.MaxTrials, space.size(), maxGridTrials)
+		if req.Method == OptimizeMethodBayesian {
+			req.MaxTrials = min(req.MaxTrials, maxBayesianTrials)
+		}
+	default:
+		return paramSpace{}, nil, nil, fmt.Errorf("invalid optimize method: %s", req.Method)
+	}
+	req.Objective = strings.ToLower(firstNonEmpty(strings.TrimSpace(req.Objective), OptimizeObjectiveSharpe))
+	if !slices.Contains(optimizeObjectives, req.Objective) {
+		return paramSpace{}, nil, nil, fmt.Errorf("invalid optimize objective: %s", req.Objective)
+	}
+	for _, name := range req.HeatmapParams {
+		if space.indexOf(name) < 0 {
+			return paramSpace{}, nil, nil, fmt.Errorf("heatmap parameter %s is not in grid", name)
+		}
+	}
+	req.Workers = min(max(req.Workers, 1), maxOptimizeWorkers)
+	if req.Seed == 0 {
+		req.Seed = time.Now().UnixNano()
+	}
+	if strings.TrimSpace(req.RunID) == "" {
+		req.RunID = mustRunID("optimize")
+	}
+	start, _, err := parseBacktestOptionalTime(req.StartTime)
+	if err != nil {
+		return paramSpace{}, nil, nil, err
+	}
+	end, _, err := parseBacktestOptionalTime(req.EndTime)
+	if err != nil {
+		return paramSpace{}, nil, nil, err
+	}
+	windows, err := walkForwardWindows(start, end, req.WalkForward)
+	if err != nil {
+		return paramSpace{}, nil, nil, err
+	}
+	return space, windows, symbols, nil
+}
+
+// walkForwardWindows 把 (start,end] 切成 N 个滚动窗口。设窗口长度为 L、样本外占比为 r，
+// 相邻窗口错开 L·r，于是 N 个样本外区间首尾相接地铺满样本内之后的全部时间：L = D / (1+(N-1)·r)。
+// anchored 时样本内起点固定为 start。未配置滚动窗口时整个区间作为唯一的样本内窗口。
+func walkForwardWindows(start time.Time, end time.Time, cfg *WalkForwardConfig) ([]optimizeWindow, error) {
+	if cfg == nil {
+		return []optimizeWindow{{InSampleStart: start, InSampleEnd: end}}, nil
+	}
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return nil, fmt.Errorf("walk_forward requires start_time before end_time")
+	}
+	if cfg.Windows <= 0 {
+		return nil, fmt.Errorf("walk_forward.windows must be positive")
+	}
+	ratio := cfg.OutOfSampleRatio
+	if ratio == 0 {
+		ratio = defaultOutOfSampleRatio
+	}
+	if ratio <= 0 || ratio >= 1 {
+		return nil, fmt.Errorf("walk_forward.out_of_sample_ratio must be in (0,1)")
+	}
+	n := cfg.Windows
+	length := time.Duration(float64(end.Sub(start)) / (1 + float64(n-1)*ratio))
+	step := time.Duration(float64(length) * ratio)
+	if step <= 0 {
+		return nil, fmt.Errorf("walk_forward window is too short")
+	}
+	out := make([]optimizeWindow, 0, n)
+	for i := 0; i < n; i++ {
+		windowStart := start.Add(time.Duration(i) * step)
+		w := optimizeWindow{
+			Index:         i,
+			InSampleStart: windowStart,
+			InSampleEnd:   windowStart.Add(length - step),
+		}
+		if cfg.Anchored {
+			w.InSampleStart = start
+		}
+		w.OutOfSampleStart = w.InSampleEnd
+		w.OutOfSampleEnd = w.InSampleEnd.Add(step)
+		if i == n-1 {
+			w.OutOfSampleEnd = end
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+// optimizationObjectiveScore 从回测摘要中读取目标值，统一为“越大越好”：
+// max_drawdown 本身是非正数，取最大即回撤最小。
+func optimizationObjectiveScore(summary map[string]any, objective string) (float64, bool) {
+	raw, ok := summary[objective]
+	if !ok {
+		return 0, false
+	}
+	score, ok := numericValue(raw)
+	if !ok || math.IsNaN(score) || math.IsInf(score, 0) {
+		return 0, false
+	}
+	return score, true
+}
+
+// bestOptimizationTrial 返回得分最高的成功试验，同分时保留候选顺序靠前的一个。
+func bestOptimizationTrial(trials []optimizationTrial) (optimizationTrial, bool) {
+	var best optimizationTrial
+	found := false
+	for _, trial := range trials {
+		if trial.Error != "" {
+			continue
+		}
+		if !found || trial.Score > best.Score {
+			best = trial
+			found = true
+		}
+	}
+	return best, found
+}
+
+// buildOptimizationHeatmap 汇总样本内试验的平均得分；只有一个参数时退化为单行。
+func buildOptimizationHeatmap(space paramSpace, trials []optimizationTrial, names []string) OptimizationHeatmap {
+	if len(names) == 0 {
+		names = space.names[:min(2, len(space.names))]
+	}
+	xi := space.indexOf(names[0])
+	yi := -1
+	if len(names) > 1 {
+		yi = space.indexOf(names[1])
+	}
+	out := OptimizationHeatmap{X: names[0], XValues: space.values[xi], YValues: []any{nil}}
+	if yi >= 0 {
+		out.Y = names[1]
+		out.YValues = space.values[yi]
+	}
+	sums := make([][]float64, len(out.YValues))
+	out.Counts = make([][]int, len(out.YValues))
+	out.Cells = make([][]*float64, len(out.YValues))
+	for row := range sums {
+		sums[row] = make([]float64, len(out.XValues))
+		out.Counts[row] = make([]int, len(out.XValues))
+		out.Cells[row] = make([]*float64, len(out.XValues))
+	}
+	for _, trial := range trials {
+		if trial.Segment != optimizeSegmentInSample || trial.Error != "" || len(trial.Indices) != len(space.names) {
+			continue
+		}
+		row := 0
+		if yi >= 0 {
+			row = trial.Indices[yi]
+		}
+		col := trial.Indices[xi]
+		sums[row][col] += trial.Score
+		out.Counts[row][col]++
+	}
+	for row := range sums {
+		for col := range sums[row] {
+			if n := out.Counts[row][col]; n > 0 {
+				mean := sums[row][col] / float64(n)
+				out.Cells[row][col] = &mean
+			}
+		}
+	}
+	return out
+}
+
+func trialCacheKey(window int, segment string, key string) string {
+	return fmt.Sprintf("%d|%s|%s", window, segment, key)
+}
+
+func optimizationTrialSummary(trial optimizationTrial) map[string]any {
+	raw, _ := json.Marshal(trial)
+	out := map[string]any{}
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+func optimizationTrialFromSummary(summary map[string]any) (optimizationTrial, bool) {
+	raw, err := json.Marshal(summary)
+	if err != nil {
+		return optimizationTrial{}, false
+	}
+	var trial optimizationTrial
+	if err := json.Unmarshal(raw, &trial); err != nil || trial.Key == "" || trial.Segment == "" {
+		return optimizationTrial{}, false
+	}
+	return trial, true
+}
+
+func optimizationRequestFromSummary(summary map[string]any) (ParameterSweepRequest, error) {
+	var req ParameterSweepRequest
+	raw, err := json.Marshal(summary["request"])
+	if err != nil {
+		return req, err
+	}
+	if err := json.Unmarshal(raw, &req); err != nil || req.StrategyID == "" {
+		return req, fmt.Errorf("optimization request is missing from run summary")
+	}
+	return req, nil
+}
@@ -0,0 +1,399 @@
+// optimizer_search.go 负责参数优化的候选生成：网格穷举、带种子的随机抽样和贝叶斯搜索。
+// 候选统一用“每个参数在网格中的下标”表示，贝叶斯搜索在归一化后的下标空间上拟合高斯过程，
+// 用期望提升（EI）挑选下一批候选，因此三种方式都只会产生网格内的取值。
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxSpaceSize 是参数空间组合数的计数上限，超过后按上限处理，避免乘法溢出。
+	maxSpaceSize = 1 << 30
+	// maxPermutedSpace 是随机抽样直接打乱全部组合的空间上限，更大的空间改用拒绝采样。
+	maxPermutedSpace = 1 << 16
+	// maxBayesianPool 是贝叶斯搜索逐个评估 EI 的候选池上限，更大的空间随机抽取候选池。
+	maxBayesianPool = 4096
+	// bayesianPoolSample 是大空间下每轮抽取的候选池大小。
+	bayesianPoolSample = 1024
+	// gpLengthScale 是 RBF 核在归一化下标空间上的长度尺度。
+	gpLengthScale = 0.25
+	// gpNoise 是核矩阵对角线上的观测噪声，同时保证 Cholesky 分解数值稳定。
+	gpNoise = 1e-6
+	// eiExploration 是 EI 在标准化得分上的探索裕量。
+	eiExploration = 0.01
+)
+
+// paramSpace 是按参数名排序后的离散搜索空间。
+type paramSpace struct {
+	names  []string
+	values [][]any
+}
+
+// searchObservation 是贝叶斯搜索使用的一次已知结果。
+type searchObservation struct {
+	indices []int
+	score   float64
+}
+
+func newParamSpace(grid map[string][]any) (paramSpace, error) {
+	if len(grid) == 0 {
+		return paramSpace{}, fmt.Errorf("grid is required")
+	}
+	names := make([]string, 0, len(grid))
+	for name := range grid {
+		if strings.TrimSpace(name) == "" {
+			return paramSpace{}, fmt.Errorf("grid parameter name is empty")
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([][]any, 0, len(names))
+	for _, name := range names {
+		if len(grid[name]) == 0 {
+			return paramSpace{}, fmt.Errorf("grid parameter %s has no values", name)
+		}
+		values = append(values, grid[name])
+	}
+	return paramSpace{names: names, values: values}, nil
+}
+
+// size 返回组合数，超过 maxSpaceSize 时返回 maxSpaceSize。
+func (s paramSpace) size() int {
+	total := 1
+	for _, vals := range s.values {
+		if total > maxSpaceSize/len(vals) {
+			return maxSpaceSize
+		}
+		total *= len(vals)
+	}
+	return total
+}
+
+func (s paramSpace) indexOf(name string) int {
+	for i, item := range s.names {
+		if item == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// params 把下标组合展开成参数表，base 中的固定参数会被同名网格参数覆盖。
+func (s paramSpace) params(base map[string]any, indices []int) map[string]any {
+	out := cloneStrategyParams(base)
+	for i, name := range s.names {
+		out[name] = s.values[i][indices[i]]
+	}
+	return out
+}
+
+// decode 把线性序号按“最后一个参数变化最快”的顺序还原为下标组合。
+func (s paramSpace) decode(linear int) []int {
+	out := make([]int, len(s.values))
+	for i := len(s.values) - 1; i >= 0; i-- {
+		out[i] = linear % len(s.values[i])
+		linear /= len(s.values[i])
+	}
+	return out
+}
+
+// point 把下标组合映射到 [0,1] 超立方体，单值参数固定为 0。
+func (s paramSpace) point(indices []int) []float64 {
+	out := make([]float64, len(indices))
+	for i, idx := range indices {
+		if n := len(s.values[i]); n > 1 {
+			out[i] = float64(idx) / float64(n-1)
+		}
+	}
+	return out
+}
+
+func (s paramSpace) randomIndices(rng *rand.Rand) []int {
+	out := make([]int, len(s.values))
+	for i, vals := range s.values {
+		out[i] = rng.Intn(len(vals))
+	}
+	return out
+}
+
+// gridCandidates 按字典序返回全部组合。
+func (s paramSpace) gridCandidates() [][]int {
+	total := s.size()
+	out := make([][]int, 0, total)
+	for i := 0; i < total; i++ {
+		out = append(out, s.decode(i))
+	}
+	return out
+}
+
+// randomCandidates 不放回地抽取最多 n 个组合，跳过 skip 中已经试过的组合。
+func (s paramSpace) randomCandidates(rng *rand.Rand, n int, skip map[string]bool) [][]int {
+	total := s.size()
+	var out [][]int
+	if total <= maxPermutedSpace {
+		for _, linear := range rng.Perm(total) {
+			if len(out) >= n {
+				break
+			}
+			indices := s.decode(linear)
+			if skip[indicesKey(indices)] {
+				continue
+			}
+			out = append(out, indices)
+		}
+		return out
+	}
+	seen := make(map[string]bool, n)
+	for attempts := 0; len(out) < n && attempts < n*20; attempts++ {
+		indices := s.randomIndices(rng)
+		key := indicesKey(indices)
+		if skip[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, indices)
+	}
+	return out
+}
+
+func indicesKey(indices []int) string {
+	parts := make([]string, len(indices))
+	for i, idx := range indices {
+		parts[i] = strconv.Itoa(idx)
+	}
+	return strings.Join(parts, ",")
+}
+
+// paramsKey 返回参数表的规范化 JSON（键有序），用于试验去重和续跑匹配。
+func paramsKey(params map[string]any) string {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprint(params)
+	}
+	return string(raw)
+}
+
+// bayesianSearch 用高斯过程代理模型在离散空间中挑选候选。
+type bayesianSearch struct {
+	space paramSpace
+	rng   *rand.Rand
+	// initial 是开始建模前的随机探索次数。
+	initial int
+}
+
+func newBayesianSearch(space paramSpace, rng *rand.Rand) bayesianSearch {
+	return bayesianSearch{space: space, rng: rng, initial: max(5, 2*len(space.names))}
+}
+
+// propose 返回最多 n 个未试过的候选。观测不足时随机探索；否则按 EI 逐个挑选，
+// 每挑一个就把它以预测均值作为“假想观测”加入模型（kriging believer），让同批候选彼此分散，便于并行评估。
+func (b bayesianSearch) propose(observed []searchObservation, n int, tried map[string]bool) [][]int {
+	if n <= 0 {
+		return nil
+	}
+	if len(observed) < b.initial {
+		return b.space.randomCandidates(b.rng, min(n, b.initial-len(observed)), tried)
+	}
+	pool := b.candidatePool(tried)
+	if len(pool) == 0 {
+		return nil
+	}
+	xs := make([][]float64, 0, len(observed)+n)
+	ys := make([]float64, 0, len(observed)+n)
+	for _, obs := range observed {
+		xs = append(xs, b.space.point(obs.indices))
+		ys = append(ys, obs.score)
+	}
+	picked := make([]bool, len(pool))
+	var out [][]int
+	for len(out) < n {
+		gp := fitGaussianProcess(xs, ys)
+		best := -1
+		bestEI := math.Inf(-1)
+		var bestMu float64
+		for i, cand := range pool {
+			if picked[i] {
+				continue
+			}
+			mu, sigma := gp.predict(b.space.point(cand))
+			if ei := expectedImprovement(mu, sigma, gp.best); ei > bestEI {
+				best, bestEI, bestMu = i, ei, mu
+			}
+		}
+		if best < 0 {
+			break
+		}
+		picked[best] = true
+		out = append(out, pool[best])
+		xs = append(xs, b.space.point(pool[best]))
+		ys = append(ys, gp.denormalize(bestMu))
+	}
+	return out
+}
+
+func (b bayesianSearch) candidatePool(tried map[string]bool) [][]int {
+	if b.space.size() <= maxBayesianPool {
+		var out [][]int
+		for _, cand := range b.space.gridCandidates() {
+			if !tried[indicesKey(cand)] {
+				out = append(out, cand)
+			}
+		}
+		return out
+	}
+	return b.space.randomCandidates(b.rng, bayesianPoolSample, tried)
+}
+
+// gaussianProcess 是零均值 RBF 核高斯过程，得分先标准化再拟合。
+type gaussianProcess struct {
+	xs    [][]float64
+	chol  [][]float64
+	alpha []float64
+	mean  float64
+	scale float64
+	// best 是标准化后的最优观测得分。
+	best float64
+}
+
+func fitGaussianProcess(xs [][]float64, ys []float64) gaussianProcess {
+	gp := gaussianProcess{xs: xs, scale: 1}
+	for _, y := range ys {
+		gp.mean += y
+	}
+	gp.mean /= float64(len(ys))
+	variance := 0.0
+	for _, y := range ys {
+		variance += (y - gp.mean) * (y - gp.mean)
+	}
+	if variance > 0 {
+		gp.scale = math.Sqrt(variance / float64(len(ys)))
+	}
+	norm := make([]float64, len(ys))
+	gp.best = math.Inf(-1)
+	for i, y := range ys {
+		norm[i] = (y - gp.mean) / gp.scale
+		gp.best = math.Max(gp.best, norm[i])
+	}
+	n := len(xs)
+	kernel := make([][]float64, n)
+	for i := range kernel {
+		kernel[i] = make([]float64, n)
+		for j := range kernel[i] {
+			kernel[i][j] = rbfKernel(xs[i], xs[j])
+		}
+	}
+	for jitter := gpNoise; ; jitter *= 10 {
+		if chol, ok := cholesky(kernel, jitter); ok {
+			gp.chol = chol
+			break
+		}
+	}
+	gp.alpha = choleskySolve(gp.chol, norm)
+	return gp
+}
+
+// predict 返回标准化尺度上的预测均值和标准差。
+func (gp gaussianProcess) predict(x []float64) (float64, float64) {
+	kstar := make([]float64, len(gp.xs))
+	for i, xi := range gp.xs {
+		kstar[i] = rbfKernel(x, xi)
+	}
+	mu := 0.0
+	for i := range kstar {
+		mu += kstar[i] * gp.alpha[i]
+	}
+	v := forwardSubstitute(gp.chol, kstar)
+	variance := 1.0
+	for _, vi := range v {
+		variance -= vi * vi
+	}
+	return mu, math.Sqrt(math.Max(variance, 1e-12))
+}
+
+func (gp gaussianProcess) denormalize(v float64) float64 {
+	return v*gp.scale + gp.mean
+}
+
+func rbfKernel(a []float64, b []float64) float64 {
+	dist := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		dist += d * d
+	}
+	return math.Exp(-dist / (2 * gpLengthScale * gpLengthScale))
+}
+
+// expectedImprovement 计算最大化目标下的期望提升。
+func expectedImprovement(mu float64, sigma float64, best float64) float64 {
+	improve := mu - best - eiExploration
+	if sigma <= 0 {
+		return math.Max(improve, 0)
+	}
+	z := improve / sigma
+	cdf := 0.5 * (1 + math.Erf(z/math.Sqrt2))
+	pdf := math.Exp(-0.5*z*z) / math.Sqrt(2*math.Pi)
+	return improve*cdf + sigma*pdf
+}
+
+// cholesky 对 a+jitter*I 做 Cholesky 分解，矩阵非正定时返回 false。
+func cholesky(a [][]float64, jitter float64) ([][]float64, bool) {
+	n := len(a)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			sum := a[i][j]
+			if i == j {
+				sum += jitter
+			}
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, false
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, true
+}
+
+// forwardSubstitute 解 L·x = b。
+func forwardSubstitute(l [][]float64, b []float64) []float64 {
+	x := make([]float64, len(b))
+	for i := range b {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
+
+// choleskySolve 解 L·Lᵀ·x = b。
+func choleskySolve(l [][]float64, b []float64) []float64 {
+	y := forwardSubstitute(l, b)
+	x := make([]float64, len(y))
+	for i := len(y) - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < len(y); k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
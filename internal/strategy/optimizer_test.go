@@ -0,0 +1,231 @@
+package strategy
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/perf"
+)
+
+func TestWalkForwardWindowsTileOutOfSample(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.Local)
+	end := start.Add(100 * time.Hour)
+	windows, err := walkForwardWindows(start, end, &WalkForwardConfig{Windows: 4, OutOfSampleRatio: 0.25})
+	if err != nil {
+		t.Fatalf("walkForwardWindows() error = %v", err)
+	}
+	if len(windows) != 4 {
+		t.Fatalf("windows = %+v", windows)
+	}
+	// L = 100h / (1+3*0.25) ≈ 57.14h，样本外每段约 14.29h，首段样本外从 42.86h 开始。
+	for i, w := range windows {
+		if !w.OutOfSampleStart.Equal(w.InSampleEnd) || !w.OutOfSampleEnd.After(w.OutOfSampleStart) {
+			t.Fatalf("window %d = %+v", i, w)
+		}
+		if i > 0 && !w.OutOfSampleStart.Equal(windows[i-1].OutOfSampleEnd) {
+			t.Fatalf("out-of-sample gap between %d and %d", i-1, i)
+		}
+	}
+	if !windows[0].InSampleStart.Equal(start) || !windows[3].OutOfSampleEnd.Equal(end) {
+		t.Fatalf("first=%+v last=%+v", windows[0], windows[3])
+	}
+	if !windows[1].InSampleStart.After(start) {
+		t.Fatalf("rolling window should move in-sample start: %+v", windows[1])
+	}
+
+	anchored, err := walkForwardWindows(start, end, &WalkForwardConfig{Windows: 3, Anchored: true})
+	if err != nil {
+		t.Fatalf("anchored error = %v", err)
+	}
+	for _, w := range anchored {
+		if !w.InSampleStart.Equal(start) {
+			t.Fatalf("anchored in-sample start = %v", w.InSampleStart)
+		}
+	}
+
+	single, err := walkForwardWindows(time.Time{}, time.Time{}, nil)
+	if err != nil || len(single) != 1 || single[0].hasOutOfSample() {
+		t.Fatalf("single window = %+v err=%v", single, err)
+	}
+	if _, err := walkForwardWindows(start, end, &WalkForwardConfig{Windows: 2, OutOfSampleRatio: 1}); err == nil {
+		t.Fatal("ratio 1 should be rejected")
+	}
+}
+
+func TestParamSpaceGridAndRandomCandidates(t *testing.T) {
+	t.Parallel()
+
+	space, err := newParamSpace(map[string][]any{"slow": {20, 30, 40}, "fast": {5, 10}})
+	if err != nil {
+		t.Fatalf("newParamSpace() error = %v", err)
+	}
+	if space.size() != 6 || space.names[0] != "fast" {
+		t.Fatalf("space = %+v", space)
+	}
+	grid := space.gridCandidates()
+	if len(grid) != 6 || indicesKey(grid[0]) != "0,0" || indicesKey(grid[1]) != "0,1" || indicesKey(grid[5]) != "1,2" {
+		t.Fatalf("grid = %v", grid)
+	}
+	params := space.params(map[string]any{"fast": 1, "size": 2}, grid[5])
+	if params["fast"] != 10 || params["slow"] != 40 || params["size"] != 2 {
+		t.Fatalf("params = %v", params)
+	}
+
+	first := space.randomCandidates(rand.New(rand.NewSource(7)), 4, map[string]bool{"0,0": true})
+	again := space.randomCandidates(rand.New(rand.NewSource(7)), 4, map[string]bool{"0,0": true})
+	if len(first) != 4 {
+		t.Fatalf("random = %v", first)
+	}
+	seen := map[string]bool{}
+	for i := range first {
+		key := indicesKey(first[i])
+		if key != indicesKey(again[i]) {
+			t.Fatalf("same seed produced %v and %v", first, again)
+		}
+		if key == "0,0" || seen[key] {
+			t.Fatalf("random candidates should skip tried and be unique: %v", first)
+		}
+		seen[key] = true
+	}
+	if got := space.randomCandidates(rand.New(rand.NewSource(1)), 10, nil); len(got) != 6 {
+		t.Fatalf("sampling beyond space size = %d, want 6", len(got))
+	}
+	if _, err := newParamSpace(map[string][]any{"fast": {}}); err == nil {
+		t.Fatal("empty parameter values should be rejected")
+	}
+}
+
+func TestBayesianSearchFindsPeak(t *testing.T) {
+	t.Parallel()
+
+	values := make([]any, 21)
+	for i := range values {
+		values[i] = i
+	}
+	space, err := newParamSpace(map[string][]any{"x": values, "y": values})
+	if err != nil {
+		t.Fatalf("newParamSpace() error = %v", err)
+	}
+	score := func(idx []int) float64 {
+		dx, dy := float64(idx[0]-14), float64(idx[1]-6)
+		return -(dx*dx + dy*dy)
+	}
+	search := newBayesianSearch(space, rand.New(rand.NewSource(3)))
+	tried := map[string]bool{}
+	var observed []searchObservation
+	for len(observed) < 40 {
+		batch := search.propose(observed, 4, tried)
+		if len(batch) == 0 {
+			t.Fatal("propose returned no candidates")
+		}
+		for _, cand := range batch {
+			key := indicesKey(cand)
+			if tried[key] {
+				t.Fatalf("candidate %s proposed twice", key)
+			}
+			tried[key] = true
+			observed = append(observed, searchObservation{indices: cand, score: score(cand)})
+		}
+	}
+	best := math.Inf(-1)
+	for _, obs := range observed {
+		best = math.Max(best, obs.score)
+	}
+	// 441 个组合里只试 40 个，要求找到距峰值不超过 2 格的点。
+	if best < -4 {
+		t.Fatalf("best score after 40 trials = %v", best)
+	}
+}
+
+func TestOptimizationRankingAndHeatmap(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := optimizationObjectiveScore(map[string]any{"sharpe": 1.2}, OptimizeObjectiveMaxDrawdown); ok {
+		t.Fatal("missing objective should not score")
+	}
+	if score, ok := optimizationObjectiveScore(map[string]any{"max_drawdown": -300.0}, OptimizeObjectiveMaxDrawdown); !ok || score != -300 {
+		t.Fatalf("max_drawdown score = %v ok=%v", score, ok)
+	}
+	// Go 组合回测的摘要来自 perf 绩效分析，每个可选目标都必须能从中取到分数。
+	summary := perf.Report{Sortino: 1.5, Calmar: 0.8}.Summary()
+	for _, objective := range optimizeObjectives {
+		if _, ok := optimizationObjectiveScore(summary, objective); !ok {
+			t.Fatalf("objective %s missing from perf summary", objective)
+		}
+	}
+
+	space, _ := newParamSpace(map[string][]any{"fast": {5, 10}, "slow": {20, 30}, "stop": {1}})
+	trials := []optimizationTrial{
+		{Segment: optimizeSegmentInSample, Indices: []int{0, 0, 0}, Score: 1},
+		{Segment: optimizeSegmentInSample, Indices: []int{0, 0, 0}, Score: 3},
+		{Segment: optimizeSegmentInSample, Indices: []int{1, 1, 0}, Score: 5},
+		{Segment: optimizeSegmentInSample, Indices: []int{1, 0, 0}, Score: 9, Error: "boom"},
+		{Segment: optimizeSegmentOutOfSample, Indices: []int{0, 1, 0}, Score: 7},
+	}
+	best, ok := bestOptimizationTrial(trials)
+	if !ok || best.Score != 7 {
+		t.Fatalf("best = %+v", best)
+	}
+	heatmap := buildOptimizationHeatmap(space, trials, nil)
+	if heatmap.X != "fast" || heatmap.Y != "slow" || len(heatmap.Cells) != 2 {
+		t.Fatalf("heatmap = %+v", heatmap)
+	}
+	if heatmap.Cells[0][0] == nil || *heatmap.Cells[0][0] != 2 || heatmap.Counts[0][0] != 2 {
+		t.Fatalf("cell[slow=20][fast=5] = %v", heatmap.Cells[0][0])
+	}
+	if heatmap.Cells[1][1] == nil || *heatmap.Cells[1][1] != 5 || heatmap.Cells[0][1] != nil || heatmap.Cells[1][0] != nil {
+		t.Fatalf("cells = %+v", heatmap.Counts)
+	}
+	single := buildOptimizationHeatmap(space, trials, []string{"slow"})
+	if single.Y != "" || len(single.Cells) != 1 || single.Counts[0][0] != 2 || single.Counts[0][1] != 1 {
+		t.Fatalf("single heatmap = %+v", single)
+	}
+}
+
+func TestNormalizeOptimizationRequest(t *testing.T) {
+	t.Parallel()
+
+	req := ParameterSweepRequest{
+		StrategyID: NativeSampleMomentumID,
+		Symbol:     "RB2405,i2405",
+		Grid:       map[string][]any{"threshold": {0.1, 0.2}},
+		Method:     "Bayesian",
+		StartTime:  "2026-03-02 09:00:00",
+		EndTime:    "2026-03-12 15:00:00",
+		Workers:    100,
+		WalkForward: &WalkForwardConfig{
+			Windows: 2,
+		},
+	}
+	_, windows, symbols, err := normalizeOptimizationRequest(&req)
+	if err != nil {
+		t.Fatalf("normalizeOptimizationRequest() error = %v", err)
+	}
+	if req.Method != OptimizeMethodBayesian || req.Objective != OptimizeObjectiveSharpe || req.MaxTrials != 2 || req.Workers != maxOptimizeWorkers {
+		t.Fatalf("req = %+v", req)
+	}
+	if req.Seed == 0 || req.RunID == "" || req.Timeframe != "1m" {
+		t.Fatalf("defaults not filled: %+v", req)
+	}
+	if len(symbols) != 2 || symbols[0] != "i2405" || len(windows) != 2 {
+		t.Fatalf("symbols=%v windows=%d", symbols, len(windows))
+	}
+
+	bad := []ParameterSweepRequest{
+		{Symbol: "rb2405", Grid: map[string][]any{"a": {1}}},
+		{StrategyID: "s", Grid: map[string][]any{"a": {1}}},
+		{StrategyID: "s", Symbol: "rb2405", Grid: map[string][]any{"a": {1, 2, 3}}, MaxTrials: 2},
+		{StrategyID: "s", Symbol: "rb2405", Grid: map[string][]any{"a": {1}}, Objective: "omega"},
+		{StrategyID: "s", Symbol: "rb2405", Grid: map[string][]any{"a": {1}}, HeatmapParams: []string{"b"}},
+		{StrategyID: "s", Symbol: "rb2405", Grid: map[string][]any{"a": {1}}, WalkForward: &WalkForwardConfig{Windows: 2}},
+	}
+	for i := range bad {
+		if _, _, _, err := normalizeOptimizationRequest(&bad[i]); err == nil {
+			t.Fatalf("case %d should fail: %+v", i, bad[i])
+		}
+	}
+}
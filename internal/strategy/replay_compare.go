@@ -0,0 +1,880 @@
+// replay_compare.go 负责 A/B 回放：同一条回放行情同时驱动两个以上策略实例，每个实例各自持有独立的内存模拟账本，
+// 回放结束后按模拟时间对齐生成并排对比报告（信号分歧、委托差异、权益曲线和逐笔交易差异）。
+// 各实例在同一次 bar 分发里依次决策，对比只按行情时间对齐，不受两次回放之间的调度时序影响。
+// 账本由 web 层通过 SetReplayLedgerFactory 注入（trade.PaperBook 实现，与回测撮合口径一致）；
+// 每个实例的信号、委托和绩效沿用 ReplayReport 的结构，分歧处附上当根 bar 的 StrategyTraceRecord 说明原因。
+// 回放中途 seek 不会回滚对比账本。
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/perf"
+)
+
+const (
+	// maxReplayCompareArms 限制一次对比的实例数。
+	maxReplayCompareArms = 8
+	// maxReplayComparisons 是内存中保留的对比数，超出时丢弃最早结束的对比。
+	maxReplayComparisons = 16
+	// maxReplayCompareTraces 是每个实例保留的决策 trace 数，超出后不再记录。
+	maxReplayCompareTraces = 50000
+)
+
+// ReplayLedger 是 A/B 回放中每个实例独立持有的模拟账本，由调用方串行访问。
+type ReplayLedger interface {
+	// OnBar 用一根回放 K 线撮合挂单并返回成交回报。
+	OnBar(bar BarEvent) []FillEvent
+	// Rebalance 撤掉该合约未成交挂单，以 K 线收盘价挂出把净持仓调到 target 的委托，跨零时拆成先平后开。
+	Rebalance(symbol string, target int, bar BarEvent) ([]ReplayLedgerOrder, error)
+	// NetPosition 返回合约净持仓。
+	NetPosition(symbol string) int
+}
+
+// ReplayLedgerFactory 为参与对比的实例创建空仓账本。
+type ReplayLedgerFactory func(inst StrategyInstance, initialBalance float64) ReplayLedger
+
+// ReplayLedgerOrder 是对比账本挂出的一笔委托。
+type ReplayLedgerOrder struct {
+	EventTime  time.Time `json:"event_time"`
+	Symbol     string    `json:"symbol"`
+	Direction  string    `json:"direction"`
+	OffsetFlag string    `json:"offset_flag"`
+	Volume     int       `json:"volume"`
+	LimitPrice float64   `json:"limit_price"`
+}
+
+// ReplayCompareRequest 是创建 A/B 回放对比的请求；对比创建后从同一会话的下一根回放 bar 开始记录。
+type ReplayCompareRequest struct {
+	// InstanceIDs 是参与对比的回放实例，第一个作为基准，其余实例的差值都相对它计算。
+	InstanceIDs []string `json:"instance_ids"`
+	// Label 是对比名称。
+	Label string `json:"label,omitempty"`
+	// InitialBalance 是每个账本的初始权益，<=0 时按实例参数 initial_balance 或默认初始资金。
+	InitialBalance float64 `json:"initial_balance,omitempty"`
+}
+
+// ReplayComparison 是对比任务的状态。
+type ReplayComparison struct {
+	CompareID     string     `json:"compare_id"`
+	Label         string     `json:"label,omitempty"`
+	InstanceIDs   []string   `json:"instance_ids"`
+	ReplaySession string     `json:"replay_session,omitempty"`
+	ReplayTaskID  string     `json:"replay_task_id,omitempty"`
+	Status        string     `json:"status"`
+	RunID         string     `json:"run_id,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// ReplayCompareArmReport 是单个实例在对比中的结果，信号、委托和绩效与该实例的 ReplayReport 同结构。
+type ReplayCompareArmReport struct {
+	InstanceID        string                  `json:"instance_id"`
+	StrategyID        string                  `json:"strategy_id"`
+	DisplayName       string                  `json:"display_name"`
+	Params            map[string]any          `json:"params,omitempty"`
+	DefinitionVersion string                  `json:"definition_version,omitempty"`
+	Analysis          ReplayAnalysisReportRow `json:"analysis"`
+	Performance       perf.Report             `json:"performance"`
+	SignalTable       []ReplaySignalReportRow `json:"signal_table"`
+	OrderTable        []ReplayOrderReportRow  `json:"order_table"`
+	LedgerOrders      []ReplayLedgerOrder     `json:"ledger_orders"`
+	LedgerErrors      int                     `json:"ledger_errors"`
+}
+
+// ReplayCompareSignalCell 是某个实例在一根 bar 上的信号和决策 trace。
+type ReplayCompareSignalCell struct {
+	Signaled       bool    `json:"signaled"`
+	TargetPosition float64 `json:"target_position"`
+	Reason         string  `json:"reason,omitempty"`
+	TraceStep      string  `json:"trace_step,omitempty"`
+	TraceStatus    string  `json:"trace_status,omitempty"`
+	TraceReason    string  `json:"trace_reason,omitempty"`
+}
+
+// ReplayCompareSignalRow 是一处信号分歧：有实例发出信号而其它实例没有，或目标仓位不同。
+type ReplayCompareSignalRow struct {
+	EventTime time.Time                          `json:"event_time"`
+	Symbol    string                             `json:"symbol"`
+	Arms      map[string]ReplayCompareSignalCell `json:"arms"`
+}
+
+// ReplayCompareOrderRow 是一处委托差异，SignedVolume 买为正、卖为负。
+type ReplayCompareOrderRow struct {
+	EventTime    time.Time                      `json:"event_time"`
+	Symbol       string                         `json:"symbol"`
+	SignedVolume map[string]int                 `json:"signed_volume"`
+	Orders       map[string][]ReplayLedgerOrder `json:"orders"`
+}
+
+// ReplayComparePnLPoint 是对齐后的权益曲线点，Delta 是相对基准实例的权益差。
+type ReplayComparePnLPoint struct {
+	Time   time.Time          `json:"time"`
+	Equity map[string]float64 `json:"equity"`
+	Delta  map[string]float64 `json:"delta"`
+}
+
+// ReplayCompareTradeRow 按合约内的平仓序号对齐各实例的逐笔交易，PnLDelta 相对基准实例。
+type ReplayCompareTradeRow struct {
+	Symbol   string                      `json:"symbol"`
+	Index    int                         `json:"index"`
+	Trades   map[string]perf.ClosedTrade `json:"trades"`
+	PnLDelta map[string]float64          `json:"pnl_delta"`
+}
+
+// ReplayCompareReport 是 A/B 回放的并排对比报告。
+type ReplayCompareReport struct {
+	ReplayComparison
+	Baseline         string                   `json:"baseline"`
+	Arms             []ReplayCompareArmReport `json:"arms"`
+	FirstDivergence  *ReplayCompareSignalRow  `json:"first_divergence,omitempty"`
+	DivergentSignals []ReplayCompareSignalRow `json:"divergent_signals"`
+	OrderDiffs       []ReplayCompareOrderRow  `json:"order_diffs"`
+	PnLCurves        []ReplayComparePnLPoint  `json:"pnl_curves"`
+	TradeDeltas      []ReplayCompareTradeRow  `json:"trade_deltas"`
+}
+
+type replayComparison struct {
+	ReplayComparison
+	arms []*replayCompareArm
+}
+
+// replayCompareArm 是对比中的一个实例：独立账本、按 ReplayReport 组织的信号/委托/绩效，以及按 bar 记录的决策 trace。
+type replayCompareArm struct {
+	inst         StrategyInstance
+	ledger       ReplayLedger
+	report       *ReplayReport
+	orders       []ReplayLedgerOrder
+	ledgerErrors int
+	traces       map[string]StrategyTraceRecord
+}
+
+// SetReplayLedgerFactory 注入 A/B 回放使用的模拟账本实现。
+func (m *Manager) SetReplayLedgerFactory(fn ReplayLedgerFactory) {
+	m.mu.Lock()
+	m.replayLedgerFactory = fn
+	m.mu.Unlock()
+}
+
+// StartReplayComparison 登记一次 A/B 回放对比；实例必须是同一回放会话下的 replay 实例，且不在其它进行中的对比里。
+func (m *Manager) StartReplayComparison(req ReplayCompareRequest) (ReplayComparison, error) {
+	ids := make([]string, 0, len(req.InstanceIDs))
+	seen := make(map[string]struct{}, len(req.InstanceIDs))
+	for _, id := range req.InstanceIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, dup := seen[id]; dup {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		return ReplayComparison{}, fmt.Errorf("replay comparison requires at least 2 instances")
+	}
+	if len(ids) > maxReplayCompareArms {
+		return ReplayComparison{}, fmt.Errorf("replay comparison supports at most %d instances", maxReplayCompareArms)
+	}
+	m.mu.RLock()
+	factory := m.replayLedgerFactory
+	insts := make([]StrategyInstance, 0, len(ids))
+	var missing string
+	for _, id := range ids {
+		inst, ok := m.instances[id]
+		if !ok {
+			missing = id
+			break
+		}
+		insts = append(insts, inst)
+	}
+	m.mu.RUnlock()
+	if factory == nil {
+		return ReplayComparison{}, fmt.Errorf("replay ledger is not configured")
+	}
+	if missing != "" {
+		return ReplayComparison{}, fmt.Errorf("strategy instance %s not found", missing)
+	}
+	session := normalizeReplaySession(insts[0].ReplaySession)
+	for _, inst := range insts {
+		if strings.ToLower(strings.TrimSpace(inst.Mode)) != RunTypeReplay {
+			return ReplayComparison{}, fmt.Errorf("strategy instance %s is not a replay instance", inst.InstanceID)
+		}
+		if normalizeReplaySession(inst.ReplaySession) != session {
+			return ReplayComparison{}, fmt.Errorf("strategy instances must share one replay_session, %s uses %q", inst.InstanceID, inst.ReplaySession)
+		}
+	}
+
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	running := 0
+	for _, cmp := range m.comparisons {
+		if cmp.Status != "running" {
+			continue
+		}
+		running++
+		for _, arm := range cmp.arms {
+			if _, ok := seen[arm.inst.InstanceID]; ok {
+				return ReplayComparison{}, fmt.Errorf("strategy instance %s is already in replay comparison %s", arm.inst.InstanceID, cmp.CompareID)
+			}
+		}
+	}
+	if running >= maxReplayComparisons {
+		return ReplayComparison{}, fmt.Errorf("too many running replay comparisons (max %d)", maxReplayComparisons)
+	}
+	cmp := &replayComparison{ReplayComparison: ReplayComparison{
+		CompareID:     mustRunID("replay-compare"),
+		Label:         strings.TrimSpace(req.Label),
+		InstanceIDs:   ids,
+		ReplaySession: session,
+		Status:        "running",
+		StartedAt:     time.Now(),
+	}}
+	for _, inst := range insts {
+		balance := req.InitialBalance
+		if balance <= 0 {
+			balance = ma20ParamFloat(inst.Params, "initial_balance", perf.DefaultInitialBalance)
+		}
+		cmp.arms = append(cmp.arms, &replayCompareArm{
+			inst:   inst,
+			ledger: factory(inst, balance),
+			report: &ReplayReport{
+				InstanceID:        inst.InstanceID,
+				StrategyID:        inst.StrategyID,
+				DisplayName:       firstNonEmpty(inst.DisplayName, inst.InstanceID),
+				Symbol:            firstSymbol(inst.Symbols),
+				Timeframe:         inst.Timeframe,
+				Status:            "running",
+				StartedAt:         cmp.StartedAt,
+				DefinitionVersion: inst.DefinitionVersion,
+				CodeHash:          inst.CodeHash,
+				tracker:           perf.NewTracker(balance),
+			},
+			traces: make(map[string]StrategyTraceRecord),
+		})
+	}
+	if m.comparisons == nil {
+		m.comparisons = make(map[string]*replayComparison)
+	}
+	m.comparisons[cmp.CompareID] = cmp
+	m.pruneReplayComparisonsLocked()
+	logger.Info("replay comparison started", "compare_id", cmp.CompareID, "instances", ids, "replay_session", session)
+	return cmp.snapshot(), nil
+}
+
+// StopReplayComparison 提前结束对比并保存报告。
+func (m *Manager) StopReplayComparison(compareID string) (ReplayCompareReport, error) {
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	cmp := m.comparisons[strings.TrimSpace(compareID)]
+	if cmp == nil {
+		return ReplayCompareReport{}, fmt.Errorf("replay comparison %s not found", compareID)
+	}
+	if cmp.Status == "running" {
+		m.finishReplayComparisonLocked(cmp, "stopped")
+	}
+	return cmp.report(), nil
+}
+
+// ListReplayComparisons 返回内存中的对比任务，按开始时间倒序。
+func (m *Manager) ListReplayComparisons() []ReplayComparison {
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	out := make([]ReplayComparison, 0, len(m.comparisons))
+	for _, cmp := range m.comparisons {
+		out = append(out, cmp.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.After(out[j].StartedAt) })
+	return out
+}
+
+// ReplayComparisonReport 返回对比报告；进行中的对比返回截至当前的报告。
+func (m *Manager) ReplayComparisonReport(compareID string) (ReplayCompareReport, error) {
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	cmp := m.comparisons[strings.TrimSpace(compareID)]
+	if cmp == nil {
+		return ReplayCompareReport{}, fmt.Errorf("replay comparison %s not found", compareID)
+	}
+	return cmp.report(), nil
+}
+
+// applyReplayComparisonBar 在策略决策前用回放 bar 撮合各对比账本的挂单并盯市；
+// 成交同时走 HandleFill，记入实例自己的回放报告并推给 Go 策略。
+func (m *Manager) applyReplayComparisonBar(ev BarEvent) {
+	if m == nil {
+		return
+	}
+	var fills []FillEvent
+	m.compareMu.Lock()
+	for _, cmp := range m.comparisons {
+		if cmp.Status != "running" || cmp.ReplaySession != normalizeReplaySession(ev.ReplaySession) {
+			continue
+		}
+		taskID := strings.TrimSpace(ev.ReplayTaskID)
+		if cmp.ReplayTaskID == "" {
+			cmp.ReplayTaskID = taskID
+		} else if taskID != "" && taskID != cmp.ReplayTaskID {
+			continue
+		}
+		for _, arm := range cmp.arms {
+			if !arm.matchesBar(ev) {
+				continue
+			}
+			for _, fill := range arm.ledger.OnBar(ev) {
+				arm.report.tracker.Fill(perf.Fill{
+					Symbol:         fill.Symbol,
+					Direction:      fill.Direction,
+					Price:          fill.Price,
+					Volume:         fill.Volume,
+					VolumeMultiple: fill.VolumeMultiple,
+					Commission:     fill.Commission,
+					Time:           fill.TradeTime,
+				})
+				fills = append(fills, fill)
+			}
+			if ev.Close > 0 {
+				arm.report.tracker.Mark(ev.InstrumentID, ev.Close, strategyBarEventTime(ev))
+			}
+		}
+	}
+	m.compareMu.Unlock()
+	for _, fill := range fills {
+		m.HandleFill(fill)
+	}
+}
+
+// replayComparePosition 返回对比实例在自己账本里的净持仓，实例不在进行中的对比里时 ok 为 false。
+func (m *Manager) replayComparePosition(inst StrategyInstance, symbol string, mode string) (float64, bool) {
+	if m == nil || mode != RunTypeReplay {
+		return 0, false
+	}
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	arm := m.replayCompareArmLocked(inst.InstanceID)
+	if arm == nil {
+		return 0, false
+	}
+	return float64(arm.ledger.NetPosition(symbol)), true
+}
+
+// rebalanceReplayComparison 把对比实例的目标仓位直接下到它自己的账本：计划里的当前仓位和变化量取自该账本，
+// 不经过执行引擎的同合约净持仓合并，也不提交给共享的 paper_replay 执行器。实例不在进行中的对比里时 ok 为 false。
+func (m *Manager) rebalanceReplayComparison(inst StrategyInstance, symbol string, mode string, target float64, bar *BarEvent) (ExecutionPlan, []ReplayLedgerOrder, bool) {
+	if m == nil || mode != RunTypeReplay {
+		return ExecutionPlan{}, nil, false
+	}
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	arm := m.replayCompareArmLocked(inst.InstanceID)
+	if arm == nil {
+		return ExecutionPlan{}, nil, false
+	}
+	current := arm.ledger.NetPosition(symbol)
+	lots := int(math.Round(target))
+	plan := ExecutionPlan{
+		CurrentPosition: float64(current),
+		TargetPosition:  float64(lots),
+		PlannedDelta:    float64(lots - current),
+		RiskStatus:      RiskStatusAllowed,
+		OrderStatus:     OrderStatusSimulated,
+	}
+	if bar == nil {
+		if lots == current {
+			plan.OrderStatus = OrderStatusNoop
+		} else {
+			plan.RiskStatus = RiskStatusBlocked
+			plan.RiskReason = "replay comparison ledger needs a bar price"
+			plan.OrderStatus = OrderStatusBlocked
+		}
+		return plan, nil, true
+	}
+	// 目标不变时也调用 Rebalance，让账本撤掉上一根 bar 未成交的挂单。
+	orders, err := arm.ledger.Rebalance(symbol, lots, *bar)
+	arm.orders = append(arm.orders, orders...)
+	if err != nil {
+		arm.ledgerErrors++
+		logger.Warn("replay comparison ledger rebalance failed", "instance_id", inst.InstanceID, "symbol", symbol, "target_position", target, "error", err)
+		plan.RiskStatus = RiskStatusBlocked
+		plan.RiskReason = err.Error()
+		plan.OrderStatus = OrderStatusBlocked
+		return plan, orders, true
+	}
+	if len(orders) == 0 {
+		plan.OrderStatus = OrderStatusNoop
+	}
+	return plan, orders, true
+}
+
+// executeReplayComparisonSignal 是对比实例的 executeSignal：按实例账本生成计划并下单，写订单计划、审计和结果 trace。
+func (m *Manager) executeReplayComparisonSignal(inst StrategyInstance, symbol string, mode string, eventTime time.Time, decision SignalDecision, bar *BarEvent) (OrderAuditRecord, bool) {
+	plan, orders, ok := m.rebalanceReplayComparison(inst, symbol, mode, decision.TargetPosition, bar)
+	if !ok {
+		return OrderAuditRecord{}, false
+	}
+	m.appendSignalEventLog(inst, symbol, mode, eventTime, decision, plan, bar)
+	metrics := map[string]any{
+		"current_position": plan.CurrentPosition,
+		"target_position":  plan.TargetPosition,
+		"planned_delta":    plan.PlannedDelta,
+		"order_status":     plan.OrderStatus,
+		"ledger_orders":    orders,
+	}
+	m.persistTrace(inst, symbol, mode, eventTime, StrategyTraceRecord{
+		EventType: "order_plan",
+		StepKey:   "order_plan",
+		StepLabel: "生成订单计划",
+		StepIndex: 5,
+		StepTotal: 5,
+		Status:    plan.RiskStatus,
+		Reason:    plan.RiskReason,
+		Metrics:   metrics,
+	})
+	audit := OrderAuditRecord{
+		InstanceID:      inst.InstanceID,
+		StrategyID:      inst.StrategyID,
+		Symbol:          symbol,
+		Mode:            mode,
+		EventTime:       eventTime,
+		TargetPosition:  plan.TargetPosition,
+		CurrentPosition: plan.CurrentPosition,
+		PlannedDelta:    plan.PlannedDelta,
+		RiskStatus:      plan.RiskStatus,
+		RiskReason:      plan.RiskReason,
+		OrderStatus:     plan.OrderStatus,
+		Audit: map[string]any{
+			"reason":                    decision.Reason,
+			"confidence":                decision.Confidence,
+			"metrics":                   decision.Metrics,
+			"ledger_orders":             orders,
+			"replay_compare":            true,
+			"requested_target_position": decision.TargetPosition,
+		},
+		CreatedAt: time.Now(),
+	}
+	if m.store != nil {
+		audit.ID, _ = m.store.AppendOrderAudit(audit)
+	}
+	m.persistTrace(inst, symbol, mode, eventTime, StrategyTraceRecord{
+		EventType: "order_result",
+		StepKey:   "order_result",
+		StepLabel: "订单执行结果",
+		StepIndex: 5,
+		StepTotal: 5,
+		Status:    plan.OrderStatus,
+		Reason:    plan.RiskReason,
+		Metrics: map[string]any{
+			"risk_status":      plan.RiskStatus,
+			"risk_reason":      plan.RiskReason,
+			"order_status":     plan.OrderStatus,
+			"current_position": plan.CurrentPosition,
+			"target_position":  plan.TargetPosition,
+			"planned_delta":    plan.PlannedDelta,
+			"ledger_orders":    orders,
+		},
+	})
+	return audit, true
+}
+
+// recordReplayComparisonSignal 把回放信号和按实例账本生成的审计记入对比实例的报告。
+func (m *Manager) recordReplayComparisonSignal(inst StrategyInstance, mode string, sig SignalRecord, audit OrderAuditRecord) {
+	if m == nil || mode != RunTypeReplay {
+		return
+	}
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	arm := m.replayCompareArmLocked(inst.InstanceID)
+	if arm == nil {
+		return
+	}
+	arm.report.SignalTable = append(arm.report.SignalTable, ReplaySignalReportRow{
+		ID:             sig.ID,
+		EventTime:      sig.EventTime,
+		Symbol:         sig.Symbol,
+		Timeframe:      sig.Timeframe,
+		TargetPosition: sig.TargetPosition,
+		Confidence:     sig.Confidence,
+		Reason:         sig.Reason,
+		Metrics:        sig.Metrics,
+	})
+	arm.report.OrderTable = append(arm.report.OrderTable, ReplayOrderReportRow{
+		ID:              audit.ID,
+		EventTime:       audit.EventTime,
+		Symbol:          audit.Symbol,
+		CurrentPosition: audit.CurrentPosition,
+		TargetPosition:  audit.TargetPosition,
+		PlannedDelta:    audit.PlannedDelta,
+		RiskStatus:      audit.RiskStatus,
+		RiskReason:      audit.RiskReason,
+		OrderStatus:     audit.OrderStatus,
+		Audit:           audit.Audit,
+	})
+}
+
+// recordReplayComparisonTrace 保留对比实例每根 bar 最后一条决策 trace，用来解释信号分歧。
+func (m *Manager) recordReplayComparisonTrace(trace StrategyTraceRecord) {
+	if m == nil || trace.Mode != RunTypeReplay {
+		return
+	}
+	switch trace.EventType {
+	case "bar", "key_tick", "signal":
+	default:
+		return
+	}
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	arm := m.replayCompareArmLocked(trace.InstanceID)
+	if arm == nil {
+		return
+	}
+	key := replayCompareKey(trace.EventTime, trace.Symbol)
+	if _, ok := arm.traces[key]; !ok && len(arm.traces) >= maxReplayCompareTraces {
+		return
+	}
+	arm.traces[key] = trace
+}
+
+// finalizeReplayComparisons 在回放任务结束时结束绑定该任务的对比。
+func (m *Manager) finalizeReplayComparisons(replayTaskID string, status string) {
+	m.compareMu.Lock()
+	defer m.compareMu.Unlock()
+	for _, cmp := range m.comparisons {
+		if cmp.Status == "running" && cmp.ReplayTaskID == replayTaskID {
+			m.finishReplayComparisonLocked(cmp, status)
+		}
+	}
+}
+
+func (m *Manager) finishReplayComparisonLocked(cmp *replayComparison, status string) {
+	finished := time.Now()
+	cmp.Status = status
+	cmp.FinishedAt = &finished
+	for _, arm := range cmp.arms {
+		arm.report.Status = status
+		arm.report.FinishedAt = &finished
+	}
+	if err := m.saveReplayComparisonLocked(cmp); err != nil {
+		m.setError(err)
+	}
+	m.pruneReplayComparisonsLocked()
+}
+
+func (m *Manager) saveReplayComparisonLocked(cmp *replayComparison) error {
+	if m.store == nil {
+		return nil
+	}
+	report := cmp.report()
+	netProfit := make(map[string]float64, len(report.Arms))
+	for _, arm := range report.Arms {
+		netProfit[arm.InstanceID] = arm.Performance.NetProfit
+	}
+	summary := map[string]any{
+		"kind":              RunTypeReplayCompare,
+		"compare_id":        cmp.CompareID,
+		"replay_task_id":    cmp.ReplayTaskID,
+		"instance_ids":      cmp.InstanceIDs,
+		"baseline":          report.Baseline,
+		"divergent_signals": len(report.DivergentSignals),
+		"order_diffs":       len(report.OrderDiffs),
+		"net_profit":        netProfit,
+	}
+	if report.FirstDivergence != nil {
+		summary["first_divergence_at"] = report.FirstDivergence.EventTime
+	}
+	base := cmp.arms[0].inst
+	run := StrategyRun{
+		RunID:      "replay-compare-" + safeRunIDPart(strings.TrimPrefix(cmp.CompareID, "replay-compare-")),
+		InstanceID: base.InstanceID,
+		StrategyID: base.StrategyID,
+		RunType:    RunTypeReplayCompare,
+		Status:     cmp.Status,
+		Symbol:     firstSymbol(base.Symbols),
+		Timeframe:  base.Timeframe,
+		Summary:    summary,
+		StartedAt:  cmp.StartedAt,
+		FinishedAt: cmp.FinishedAt,
+	}
+	outputPath, err := m.writeBacktestOutput(run, ReplayCompareRequest{InstanceIDs: cmp.InstanceIDs, Label: cmp.Label}, BacktestResponse{
+		RunID:   run.RunID,
+		Status:  run.Status,
+		Summary: summary,
+		Result:  map[string]any{"report": report},
+	})
+	if err != nil {
+		return err
+	}
+	run.OutputPath = outputPath
+	if err := m.store.SaveRun(run); err != nil {
+		return err
+	}
+	cmp.RunID = run.RunID
+	m.broadcast("strategy_backtest_done", run)
+	return nil
+}
+
+// pruneReplayComparisonsLocked 只保留最近的 maxReplayComparisons 个对比，优先丢弃最早结束的。
+func (m *Manager) pruneReplayComparisonsLocked() {
+	for len(m.comparisons) > maxReplayComparisons {
+		var oldest *replayComparison
+		for _, cmp := range m.comparisons {
+			if cmp.FinishedAt == nil {
+				continue
+			}
+			if oldest == nil || cmp.FinishedAt.Before(*oldest.FinishedAt) {
+				oldest = cmp
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		delete(m.comparisons, oldest.CompareID)
+	}
+}
+
+func (m *Manager) replayCompareArmLocked(instanceID string) *replayCompareArm {
+	for _, cmp := range m.comparisons {
+		if cmp.Status != "running" {
+			continue
+		}
+		for _, arm := range cmp.arms {
+			if arm.inst.InstanceID == instanceID {
+				return arm
+			}
+		}
+	}
+	return nil
+}
+
+// matchesBar 与 matchingInstances 的规则一致，避免同一合约的其它周期 bar 重复撮合。
+func (a *replayCompareArm) matchesBar(ev BarEvent) bool {
+	if a.inst.Timeframe != "" && ev.Period != "" && a.inst.Timeframe != ev.Period {
+		return false
+	}
+	return len(a.inst.Symbols) == 0 || containsFold(a.inst.Symbols, ev.InstrumentID)
+}
+
+func (c *replayComparison) snapshot() ReplayComparison {
+	out := c.ReplayComparison
+	out.InstanceIDs = append([]string(nil), c.InstanceIDs...)
+	return out
+}
+
+// report 按模拟时间对齐各实例，第一个实例作为基准。
+func (c *replayComparison) report() ReplayCompareReport {
+	out := ReplayCompareReport{
+		ReplayComparison: c.snapshot(),
+		Baseline:         c.arms[0].inst.InstanceID,
+		DivergentSignals: []ReplayCompareSignalRow{},
+		OrderDiffs:       []ReplayCompareOrderRow{},
+		PnLCurves:        []ReplayComparePnLPoint{},
+		TradeDeltas:      []ReplayCompareTradeRow{},
+	}
+	performances := make([]perf.Report, len(c.arms))
+	for i, arm := range c.arms {
+		performances[i] = arm.report.performance()
+		analysis := arm.report.analysis(performances[i])
+		analysis.ReplayTaskID = c.ReplayTaskID
+		out.Arms = append(out.Arms, ReplayCompareArmReport{
+			InstanceID:        arm.inst.InstanceID,
+			StrategyID:        arm.inst.StrategyID,
+			DisplayName:       arm.report.DisplayName,
+			Params:            arm.inst.Params,
+			DefinitionVersion: arm.inst.DefinitionVersion,
+			Analysis:          analysis,
+			Performance:       performances[i],
+			SignalTable:       append([]ReplaySignalReportRow{}, arm.report.SignalTable...),
+			OrderTable:        append([]ReplayOrderReportRow{}, arm.report.OrderTable...),
+			LedgerOrders:      append([]ReplayLedgerOrder{}, arm.orders...),
+			LedgerErrors:      arm.ledgerErrors,
+		})
+	}
+	out.DivergentSignals = c.divergentSignals()
+	if len(out.DivergentSignals) > 0 {
+		first := out.DivergentSignals[0]
+		out.FirstDivergence = &first
+	}
+	out.OrderDiffs = c.orderDiffs()
+	out.PnLCurves = c.pnlCurves(performances)
+	out.TradeDeltas = c.tradeDeltas(performances)
+	return out
+}
+
+func (c *replayComparison) divergentSignals() []ReplayCompareSignalRow {
+	type slot struct {
+		at     time.Time
+		symbol string
+		cells  map[string]ReplayCompareSignalCell
+	}
+	slots := make(map[string]*slot)
+	for _, arm := range c.arms {
+		for _, row := range arm.report.SignalTable {
+			key := replayCompareKey(row.EventTime, row.Symbol)
+			s := slots[key]
+			if s == nil {
+				s = &slot{at: row.EventTime, symbol: row.Symbol, cells: make(map[string]ReplayCompareSignalCell)}
+				slots[key] = s
+			}
+			s.cells[arm.inst.InstanceID] = ReplayCompareSignalCell{Signaled: true, TargetPosition: row.TargetPosition, Reason: row.Reason}
+		}
+	}
+	out := []ReplayCompareSignalRow{}
+	for key, s := range slots {
+		divergent := len(s.cells) != len(c.arms)
+		var first *ReplayCompareSignalCell
+		for _, cell := range s.cells {
+			if first == nil {
+				first = &cell
+				continue
+			}
+			if math.Abs(cell.TargetPosition-first.TargetPosition) > 1e-9 {
+				divergent = true
+			}
+		}
+		if !divergent {
+			continue
+		}
+		row := ReplayCompareSignalRow{EventTime: s.at, Symbol: s.symbol, Arms: make(map[string]ReplayCompareSignalCell, len(c.arms))}
+		for _, arm := range c.arms {
+			cell := s.cells[arm.inst.InstanceID]
+			if trace, ok := arm.traces[key]; ok {
+				cell.TraceStep = firstNonEmpty(trace.StepLabel, trace.StepKey)
+				cell.TraceStatus = trace.Status
+				cell.TraceReason = trace.Reason
+			}
+			row.Arms[arm.inst.InstanceID] = cell
+		}
+		out = append(out, row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].EventTime.Equal(out[j].EventTime) {
+			return out[i].EventTime.Before(out[j].EventTime)
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+func (c *replayComparison) orderDiffs() []ReplayCompareOrderRow {
+	rows := make(map[string]*ReplayCompareOrderRow)
+	for _, arm := range c.arms {
+		for _, order := range arm.orders {
+			key := replayCompareKey(order.EventTime, order.Symbol)
+			row := rows[key]
+			if row == nil {
+				row = &ReplayCompareOrderRow{EventTime: order.EventTime, Symbol: order.Symbol, SignedVolume: make(map[string]int), Orders: make(map[string][]ReplayLedgerOrder)}
+				rows[key] = row
+			}
+			volume := order.Volume
+			if order.Direction == "sell" {
+				volume = -volume
+			}
+			row.SignedVolume[arm.inst.InstanceID] += volume
+			row.Orders[arm.inst.InstanceID] = append(row.Orders[arm.inst.InstanceID], order)
+		}
+	}
+	out := []ReplayCompareOrderRow{}
+	for _, row := range rows {
+		same := len(row.Orders) == len(c.arms)
+		base := row.SignedVolume[c.arms[0].inst.InstanceID]
+		for _, arm := range c.arms[1:] {
+			if row.SignedVolume[arm.inst.InstanceID] != base {
+				same = false
+			}
+		}
+		if same {
+			continue
+		}
+		for _, arm := range c.arms {
+			if _, ok := row.SignedVolume[arm.inst.InstanceID]; !ok {
+				row.SignedVolume[arm.inst.InstanceID] = 0
+			}
+		}
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].EventTime.Equal(out[j].EventTime) {
+			return out[i].EventTime.Before(out[j].EventTime)
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+// pnlCurves 把各实例的权益采样按时间合并，某实例在该时间没有采样时沿用它上一个权益。
+func (c *replayComparison) pnlCurves(performances []perf.Report) []ReplayComparePnLPoint {
+	var times []time.Time
+	seen := make(map[int64]struct{})
+	for _, report := range performances {
+		for _, point := range report.Equity {
+			if _, ok := seen[point.Time.UnixNano()]; ok {
+				continue
+			}
+			seen[point.Time.UnixNano()] = struct{}{}
+			times = append(times, point.Time)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	cursors := make([]int, len(performances))
+	last := make([]float64, len(performances))
+	for i, report := range performances {
+		last[i] = report.InitialBalance
+	}
+	out := make([]ReplayComparePnLPoint, 0, len(times))
+	for _, at := range times {
+		point := ReplayComparePnLPoint{Time: at, Equity: make(map[string]float64, len(c.arms)), Delta: make(map[string]float64, len(c.arms))}
+		for i, report := range performances {
+			for cursors[i] < len(report.Equity) && !report.Equity[cursors[i]].Time.After(at) {
+				last[i] = report.Equity[cursors[i]].Equity
+				cursors[i]++
+			}
+			point.Equity[c.arms[i].inst.InstanceID] = last[i]
+		}
+		for i := range performances {
+			point.Delta[c.arms[i].inst.InstanceID] = normalizeZero(last[i] - last[0])
+		}
+		out = append(out, point)
+	}
+	return out
+}
+
+func (c *replayComparison) tradeDeltas(performances []perf.Report) []ReplayCompareTradeRow {
+	rows := make(map[string]*ReplayCompareTradeRow)
+	var keys []string
+	for i, report := range performances {
+		counts := make(map[string]int)
+		for _, trade := range report.ClosedTrades {
+			symbol := strings.ToLower(trade.Symbol)
+			counts[symbol]++
+			key := fmt.Sprintf("%s#%06d", symbol, counts[symbol])
+			row := rows[key]
+			if row == nil {
+				row = &ReplayCompareTradeRow{Symbol: symbol, Index: counts[symbol], Trades: make(map[string]perf.ClosedTrade), PnLDelta: make(map[string]float64)}
+				rows[key] = row
+				keys = append(keys, key)
+			}
+			row.Trades[c.arms[i].inst.InstanceID] = trade
+		}
+	}
+	sort.Strings(keys)
+	base := c.arms[0].inst.InstanceID
+	out := make([]ReplayCompareTradeRow, 0, len(keys))
+	for _, key := range keys {
+		row := rows[key]
+		for _, arm := range c.arms {
+			id := arm.inst.InstanceID
+			row.PnLDelta[id] = normalizeZero(row.Trades[id].PnL - row.Trades[base].PnL)
+		}
+		out = append(out, *row)
+	}
+	return out
+}
+
+func replayCompareKey(at time.Time, symbol string) string {
+	return fmt.Sprintf("%d|%s", at.UnixNano(), strings.ToLower(strings.TrimSpace(symbol)))
+}
@@ -0,0 +1,239 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeReplayLedger 把挂单在下一根 bar 的开盘价全部成交。
+type fakeReplayLedger struct {
+	instanceID string
+	positions  map[string]int
+	pending    map[string]int
+}
+
+func (l *fakeReplayLedger) OnBar(bar BarEvent) []FillEvent {
+	delta := l.pending[bar.InstrumentID]
+	if delta == 0 {
+		return nil
+	}
+	delete(l.pending, bar.InstrumentID)
+	l.positions[bar.InstrumentID] += delta
+	direction, volume := "buy", delta
+	if delta < 0 {
+		direction, volume = "sell", -delta
+	}
+	return []FillEvent{{InstanceID: l.instanceID, Symbol: bar.InstrumentID, Direction: direction, Price: bar.Open, Volume: volume, VolumeMultiple: 10, TradeTime: bar.AdjustedTime}}
+}
+
+func (l *fakeReplayLedger) Rebalance(symbol string, target int, bar BarEvent) ([]ReplayLedgerOrder, error) {
+	delta := target - l.positions[symbol]
+	l.pending[symbol] = delta
+	if delta == 0 {
+		return nil, nil
+	}
+	direction, volume := "buy", delta
+	if delta < 0 {
+		direction, volume = "sell", -delta
+	}
+	return []ReplayLedgerOrder{{EventTime: bar.AdjustedTime, Symbol: symbol, Direction: direction, Volume: volume, LimitPrice: bar.Close}}, nil
+}
+
+func (l *fakeReplayLedger) NetPosition(symbol string) int {
+	return l.positions[symbol]
+}
+
+func TestReplayComparisonAlignsArmsOnReplayTime(t *testing.T) {
+	t.Parallel()
+
+	instA := StrategyInstance{InstanceID: "a", StrategyID: "demo", Mode: RunTypeReplay, Status: InstanceStatusStopped, Symbols: []string{"rb2601"}, Timeframe: "1m"}
+	instB := instA
+	instB.InstanceID = "b"
+	realtime := instA
+	realtime.InstanceID, realtime.Mode = "rt", RunTypeRealtime
+	m := &Manager{
+		exec:      NewExecutionEngine(),
+		events:    make(map[chan EventEnvelope]struct{}),
+		reports:   make(map[string]*ReplayReport),
+		instances: map[string]StrategyInstance{"a": instA, "b": instB, "rt": realtime},
+	}
+	if _, err := m.StartReplayComparison(ReplayCompareRequest{InstanceIDs: []string{"a", "b"}}); err == nil {
+		t.Fatal("comparison without ledger factory should fail")
+	}
+	m.SetReplayLedgerFactory(func(inst StrategyInstance, _ float64) ReplayLedger {
+		return &fakeReplayLedger{instanceID: inst.InstanceID, positions: map[string]int{}, pending: map[string]int{}}
+	})
+	for _, ids := range [][]string{{"a"}, {"a", "a"}, {"a", "rt"}, {"a", "missing"}} {
+		if _, err := m.StartReplayComparison(ReplayCompareRequest{InstanceIDs: ids}); err == nil {
+			t.Fatalf("StartReplayComparison(%v) should fail", ids)
+		}
+	}
+	cmp, err := m.StartReplayComparison(ReplayCompareRequest{InstanceIDs: []string{"a", "b"}, InitialBalance: 10_000})
+	if err != nil {
+		t.Fatalf("StartReplayComparison error: %v", err)
+	}
+	if _, err := m.StartReplayComparison(ReplayCompareRequest{InstanceIDs: []string{"b", "a"}}); err == nil {
+		t.Fatal("instance already in a running comparison should be rejected")
+	}
+
+	base := time.Date(2026, 5, 19, 21, 0, 0, 0, time.Local)
+	bar := func(minute int, open, close float64) BarEvent {
+		return BarEvent{ReplayTaskID: "task-1", InstrumentID: "rb2601", Period: "1m", AdjustedTime: base.Add(time.Duration(minute) * time.Minute), Open: open, Close: close}
+	}
+	signal := func(inst StrategyInstance, ev BarEvent, target float64) {
+		plan, _, ok := m.rebalanceReplayComparison(inst, "rb2601", RunTypeReplay, target, &ev)
+		if !ok {
+			t.Fatalf("instance %s is not a comparison arm", inst.InstanceID)
+		}
+		audit := OrderAuditRecord{EventTime: ev.AdjustedTime, Symbol: "rb2601", CurrentPosition: plan.CurrentPosition, TargetPosition: plan.TargetPosition, PlannedDelta: plan.PlannedDelta}
+		m.recordReplayComparisonSignal(inst, RunTypeReplay, SignalRecord{InstanceID: inst.InstanceID, Symbol: "rb2601", EventTime: ev.AdjustedTime, TargetPosition: target}, audit)
+	}
+
+	bar0 := bar(0, 100, 100)
+	m.HandleReplayBar(bar0)
+	signal(instA, bar0, 1)
+	m.persistTrace(instB, "rb2601", RunTypeReplay, bar0.AdjustedTime, StrategyTraceRecord{EventType: "bar", StepLabel: "等待突破", Status: "waiting", Reason: "no breakout"})
+
+	bar1 := bar(1, 105, 110)
+	m.HandleReplayBar(bar1)
+	if pos, ok := m.replayComparePosition(instA, "rb2601", RunTypeReplay); !ok || pos != 1 {
+		t.Fatalf("arm a position = %v/%v, want 1", pos, ok)
+	}
+	signal(instA, bar1, 0)
+	signal(instB, bar1, 1)
+
+	m.HandleReplayBar(bar(2, 120, 120))
+	// 其它回放任务的 bar 不应进入对比账本。
+	other := bar(3, 200, 200)
+	other.ReplayTaskID = "task-2"
+	m.HandleReplayBar(other)
+	m.FinalizeReplayReports("task-1", "done")
+
+	report, err := m.ReplayComparisonReport(cmp.CompareID)
+	if err != nil {
+		t.Fatalf("ReplayComparisonReport error: %v", err)
+	}
+	if report.Status != "done" || report.ReplayTaskID != "task-1" || report.Baseline != "a" || len(report.Arms) != 2 {
+		t.Fatalf("report header = %+v", report.ReplayComparison)
+	}
+	if len(report.DivergentSignals) != 2 || report.FirstDivergence == nil || !report.FirstDivergence.EventTime.Equal(bar0.AdjustedTime) {
+		t.Fatalf("divergent signals = %+v", report.DivergentSignals)
+	}
+	if cell := report.FirstDivergence.Arms["b"]; cell.Signaled || cell.TraceReason != "no breakout" || cell.TraceStep != "等待突破" {
+		t.Fatalf("first divergence arm b = %+v", cell)
+	}
+	if len(report.OrderDiffs) != 2 || report.OrderDiffs[1].SignedVolume["a"] != -1 || report.OrderDiffs[1].SignedVolume["b"] != 1 {
+		t.Fatalf("order diffs = %+v", report.OrderDiffs)
+	}
+	if got := report.Arms[0].Performance.NetProfit; got != 150 {
+		t.Fatalf("arm a net profit = %v, want 150", got)
+	}
+	if len(report.TradeDeltas) != 1 || report.TradeDeltas[0].PnLDelta["b"] != -150 {
+		t.Fatalf("trade deltas = %+v", report.TradeDeltas)
+	}
+	last := report.PnLCurves[len(report.PnLCurves)-1]
+	if !last.Time.Equal(base.Add(2*time.Minute)) || last.Equity["a"] != 10_150 || last.Delta["b"] != -150 {
+		t.Fatalf("last pnl point = %+v", last)
+	}
+	if _, ok := m.replayComparePosition(instA, "rb2601", RunTypeReplay); ok {
+		t.Fatal("finished comparison should release its instances")
+	}
+}
+
+// sharedReplayExecutor 模拟共享的 paper_replay 执行器，对比实例不应查询或提交到这里。
+type sharedReplayExecutor struct {
+	submitted []StrategyOrderRequest
+}
+
+func (e *sharedReplayExecutor) CurrentPosition(string, string) (float64, error) {
+	return 5, nil
+}
+
+func (e *sharedReplayExecutor) SubmitStrategyOrder(_ context.Context, req StrategyOrderRequest) (StrategyOrderResult, error) {
+	e.submitted = append(e.submitted, req)
+	return StrategyOrderResult{}, nil
+}
+
+func TestReplayComparisonArmsTradeOwnLedgersOnly(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	instA := StrategyInstance{InstanceID: "long", StrategyID: "demo", AccountID: "paper_replay", Mode: RunTypeReplay, Status: InstanceStatusStopped, Symbols: []string{"rb2601"}, Timeframe: "1m"}
+	instB := instA
+	instB.InstanceID = "short"
+	executor := &sharedReplayExecutor{}
+	m := &Manager{
+		exec:          NewExecutionEngine(),
+		orderExecutor: executor,
+		events:        make(map[chan EventEnvelope]struct{}),
+		reports:       make(map[string]*ReplayReport),
+		instances:     map[string]StrategyInstance{"long": instA, "short": instB},
+	}
+	ledgers := make(map[string]*fakeReplayLedger)
+	m.SetReplayLedgerFactory(func(inst StrategyInstance, _ float64) ReplayLedger {
+		l := &fakeReplayLedger{instanceID: inst.InstanceID, positions: map[string]int{}, pending: map[string]int{}}
+		ledgers[inst.InstanceID] = l
+		return l
+	})
+	cmp, err := m.StartReplayComparison(ReplayCompareRequest{InstanceIDs: []string{"long", "short"}, InitialBalance: 10_000})
+	if err != nil {
+		t.Fatalf("StartReplayComparison error: %v", err)
+	}
+
+	base := time.Date(2026, 5, 19, 21, 0, 0, 0, time.Local)
+	bar := func(minute int, price float64) BarEvent {
+		return BarEvent{ReplayTaskID: "task-1", InstrumentID: "rb2601", Period: "1m", AdjustedTime: base.Add(time.Duration(minute) * time.Minute), Open: price, High: price, Low: price, Close: price}
+	}
+	execute := func(inst StrategyInstance, ev BarEvent, target float64) OrderAuditRecord {
+		audit := m.executeSignal(inst, "rb2601", RunTypeReplay, ev.AdjustedTime, SignalDecision{TargetPosition: target}, &ev, "", nil)
+		m.recordReplayComparisonSignal(inst, RunTypeReplay, SignalRecord{InstanceID: inst.InstanceID, Symbol: "rb2601", EventTime: ev.AdjustedTime, TargetPosition: target}, audit)
+		return audit
+	}
+
+	bar0 := bar(0, 100)
+	m.HandleReplayBar(bar0)
+	longOpen := execute(instA, bar0, 2)
+	shortOpen := execute(instB, bar0, -2)
+	if longOpen.CurrentPosition != 0 || longOpen.PlannedDelta != 2 || shortOpen.CurrentPosition != 0 || shortOpen.PlannedDelta != -2 {
+		t.Fatalf("open audits = %+v / %+v, want plans from empty arm ledgers", longOpen, shortOpen)
+	}
+
+	bar1 := bar(1, 110)
+	m.HandleReplayBar(bar1)
+	if ledgers["long"].positions["rb2601"] != 2 || ledgers["short"].positions["rb2601"] != -2 {
+		t.Fatalf("ledger positions long=%d short=%d, want 2/-2", ledgers["long"].positions["rb2601"], ledgers["short"].positions["rb2601"])
+	}
+	longClose := execute(instA, bar1, 0)
+	shortHold := execute(instB, bar1, -2)
+	if longClose.CurrentPosition != 2 || longClose.PlannedDelta != -2 || longClose.OrderStatus != OrderStatusSimulated {
+		t.Fatalf("long close audit = %+v", longClose)
+	}
+	if shortHold.CurrentPosition != -2 || shortHold.PlannedDelta != 0 || shortHold.OrderStatus != OrderStatusNoop {
+		t.Fatalf("short hold audit = %+v", shortHold)
+	}
+
+	m.HandleReplayBar(bar(2, 120))
+	m.FinalizeReplayReports("task-1", "done")
+
+	if len(executor.submitted) != 0 {
+		t.Fatalf("shared executor received %d orders, want none", len(executor.submitted))
+	}
+	if got := m.exec.CurrentPosition("rb2601"); got != 0 {
+		t.Fatalf("shared execution position = %v, want 0", got)
+	}
+	report, err := m.ReplayComparisonReport(cmp.CompareID)
+	if err != nil {
+		t.Fatalf("ReplayComparisonReport error: %v", err)
+	}
+	long, short := report.Arms[0], report.Arms[1]
+	if len(long.LedgerOrders) != 2 || long.LedgerOrders[1].Direction != "sell" || len(short.LedgerOrders) != 1 || short.LedgerOrders[0].Direction != "sell" {
+		t.Fatalf("ledger orders long=%+v short=%+v", long.LedgerOrders, short.LedgerOrders)
+	}
+	// 挂单在下一根 bar 开盘成交：多头 110 开 2 手、120 平；空头 110 开 2 手、120 盯市，各自只计自己的成交。
+	if long.Performance.NetProfit != 200 || long.Performance.Trades != 1 {
+		t.Fatalf("long performance = %+v, want net 200 from one round trip", long.Performance)
+	}
+	if short.Performance.NetProfit != -200 || short.Performance.Trades != 0 {
+		t.Fatalf("short performance = %+v, want -200 open loss and no closed trades", short.Performance)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"ctp-future-kline/internal/perf"
 )
 
 type ReplaySignalReportRow struct {
@@ -49,6 +51,12 @@ type ReplayAnalysisReportRow struct {
 	LatestOrderStatus string     `json:"latest_order_status"`
 	LatestRiskStatus  string     `json:"latest_risk_status"`
 	LatestRiskReason  string     `json:"latest_risk_reason"`
+	NetProfit         float64    `json:"net_profit"`
+	Return            float64    `json:"return"`
+	MaxDrawdown       float64    `json:"max_drawdown"`
+	Sharpe            float64    `json:"sharpe"`
+	WinRate           float64    `json:"win_rate"`
+	Trades            int        `json:"trades"`
 	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
@@ -65,6 +73,20 @@ type ReplayReport struct {
 	FinishedAt   *time.Time              `json:"finished_at,omitempty"`
 	SignalTable  []ReplaySignalReportRow `json:"signal_table"`
 	OrderTable   []ReplayOrderReportRow  `json:"order_table"`
+
+	// DefinitionVersion 和 CodeHash 取自实例启动时固定的策略版本。
+	DefinitionVersion string `json:"definition_version,omitempty"`
+	CodeHash          string `json:"code_hash,omitempty"`
+
+	// tracker 由实例成交和回放 K 线盯市驱动，计算报告的绩效指标。
+	tracker *perf.Tracker
+}
+
+func (r *ReplayReport) performance() perf.Report {
+	if r.tracker == nil {
+		r.tracker = perf.NewTracker(0)
+	}
+	return r.tracker.Report()
 }
 
 func (r *ReplayReport) result() map[string]any {
@@ -76,6 +98,7 @@ func (r *ReplayReport) result() map[string]any {
 	if orders == nil {
 		orders = []ReplayOrderReportRow{}
 	}
+	performance := r.performance()
 	return map[string]any{
 		"replay_task_id":          r.ReplayTaskID,
 		"instance_id":             r.InstanceID,
@@ -85,13 +108,16 @@ func (r *ReplayReport) result() map[string]any {
 		"status":                  r.Status,
 		"signal_table":            signals,
 		"order_table":             orders,
-		"strategy_analysis_table": []ReplayAnalysisReportRow{r.analysis()},
+		"strategy_analysis_table": []ReplayAnalysisReportRow{r.analysis(performance)},
+		"performance":             performance,
 	}
 }
 
 func (r *ReplayReport) summary() map[string]any {
-	analysis := r.analysis()
-	return map[string]any{
+	performance := r.performance()
+	analysis := r.analysis(performance)
+	out := performance.Summary()
+	for key, value := range map[string]any{
 		"kind":                       RunTypeReplayReport,
 		"replay_task_id":             r.ReplayTaskID,
 		"instance_id":                r.InstanceID,
@@ -104,10 +130,13 @@ func (r *ReplayReport) summary() map[string]any {
 		"net_target_position_change": analysis.NetTargetChange,
 		"latest_order_status":        analysis.LatestOrderStatus,
 		"latest_risk_status":         analysis.LatestRiskStatus,
+	} {
+		out[key] = value
 	}
+	return out
 }
 
-func (r *ReplayReport) analysis() ReplayAnalysisReportRow {
+func (r *ReplayReport) analysis(performance perf.Report) ReplayAnalysisReportRow {
 	var firstSignal *time.Time
 	var lastSignal *time.Time
 	var firstTarget float64
@@ -134,6 +163,12 @@ func (r *ReplayReport) analysis() ReplayAnalysisReportRow {
 		FirstSignalAt:   firstSignal,
 		LastSignalAt:    lastSignal,
 		NetTargetChange: normalizeZero(lastTarget - firstTarget),
+		NetProfit:       performance.NetProfit,
+		Return:          performance.Return,
+		MaxDrawdown:     performance.MaxDrawdown,
+		Sharpe:          performance.Sharpe,
+		WinRate:         performance.WinRate,
+		Trades:          performance.Trades,
 		UpdatedAt:       time.Now(),
 	}
 	if len(r.OrderTable) > 0 {
@@ -216,7 +251,7 @@ func (m *Manager) replayReportLocked(replayTaskID string, inst StrategyInstance)
 	}
 	now := time.Now()
 	report := &ReplayReport{
-		RunID:        replayReportRunID(replayTaskID, inst.InstanceID),
+		RunID:        ReplayReportRunID(replayTaskID, inst.InstanceID),
 		ReplayTaskID: strings.TrimSpace(replayTaskID),
 		InstanceID:   inst.InstanceID,
 		StrategyID:   inst.StrategyID,
@@ -225,7 +260,9 @@ func (m *Manager) replayReportLocked(replayTaskID string, inst StrategyInstance)
 		Timeframe:    inst.Timeframe,
 		Status:       "running",
 		StartedAt:    now,
+		tracker:      perf.NewTracker(ma20ParamFloat(inst.Params, "initial_balance", perf.DefaultInitialBalance)),
 	}
+	report.DefinitionVersion, report.CodeHash = inst.DefinitionVersion, inst.CodeHash
 	m.reports[key] = report
 	return report
 }
@@ -243,6 +280,7 @@ func (m *Manager) saveReplayReportLocked(report *ReplayReport) error {
 		StartedAt:  report.StartedAt,
 		FinishedAt: report.FinishedAt,
 	}
+	run.DefinitionVersion, run.CodeHash = report.DefinitionVersion, report.CodeHash
 	outputPath, err := m.writeBacktestOutput(run, map[string]any{
 		"replay_task_id": report.ReplayTaskID,
 		"instance_id":    report.InstanceID,
@@ -261,14 +299,62 @@ func (m *Manager) saveReplayReportLocked(report *ReplayReport) error {
 	return nil
 }
 
+// recordReplayFill 把回放实例的成交记入其运行中的回放报告。
+func (m *Manager) recordReplayFill(fill FillEvent) {
+	if m == nil || strings.TrimSpace(fill.InstanceID) == "" {
+		return
+	}
+	m.reportMu.Lock()
+	defer m.reportMu.Unlock()
+	for _, report := range m.reports {
+		if report == nil || report.InstanceID != fill.InstanceID || report.Status != "running" || report.tracker == nil {
+			continue
+		}
+		report.tracker.Fill(perf.Fill{
+			Symbol:         fill.Symbol,
+			Direction:      fill.Direction,
+			Price:          fill.Price,
+			Volume:         fill.Volume,
+			VolumeMultiple: fill.VolumeMultiple,
+			Commission:     fill.Commission,
+			Time:           fill.TradeTime,
+		})
+	}
+}
+
+// markReplayReports 用回放 K 线收盘价给同一回放任务下运行中的报告盯市；
+// 标记只更新内存中的权益采样，随下一次信号或结束时落盘。
+func (m *Manager) markReplayReports(ev BarEvent) {
+	if m == nil || ev.Close <= 0 {
+		return
+	}
+	taskID := strings.TrimSpace(ev.ReplayTaskID)
+	at := strategyBarEventTime(ev)
+	m.reportMu.Lock()
+	defer m.reportMu.Unlock()
+	for _, report := range m.reports {
+		if report == nil || report.Status != "running" || report.tracker == nil {
+			continue
+		}
+		if taskID != "" && report.ReplayTaskID != taskID {
+			continue
+		}
+		report.tracker.Mark(ev.InstrumentID, ev.Close, at)
+	}
+}
+
 func (m *Manager) FinalizeReplayReports(replayTaskID string, status string) {
-	if m == nil || m.store == nil || strings.TrimSpace(replayTaskID) == "" {
+	if m == nil || strings.TrimSpace(replayTaskID) == "" {
 		return
 	}
 	finalStatus := "done"
 	if strings.EqualFold(strings.TrimSpace(status), "stopped") {
 		finalStatus = "stopped"
 	}
+	m.finalizeReplayComparisons(replayTaskID, finalStatus)
+	if m.store == nil {
+		return
+	}
 	m.reportMu.Lock()
 	defer m.reportMu.Unlock()
 	finished := time.Now()
@@ -319,7 +405,8 @@ func replayReportKey(taskID string, instanceID string) string {
 	return strings.TrimSpace(taskID) + "|" + strings.TrimSpace(instanceID)
 }
 
-func replayReportRunID(taskID string, instanceID string) string {
+// ReplayReportRunID 返回回放任务结束时为某个实例归档的报告运行 ID。
+func ReplayReportRunID(taskID string, instanceID string) string {
 	return fmt.Sprintf("replay-report-%s-%s", safeRunIDPart(taskID), safeRunIDPart(instanceID))
 }
 
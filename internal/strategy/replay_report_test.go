@@ -227,3 +227,42 @@ func readReplayReportResult(t *testing.T, path string) map[string]any {
 	}
 	return out
 }
+
+func TestReplayReportPerformanceFromFillsAndMarks(t *testing.T) {
+	t.Parallel()
+
+	m := &Manager{reports: make(map[string]*ReplayReport)}
+	inst := StrategyInstance{InstanceID: "inst-1", StrategyID: "demo", Mode: RunTypeReplay, Symbols: []string{"rb2601"}, Params: map[string]any{"initial_balance": 50_000.0}}
+	m.reportMu.Lock()
+	report := m.replayReportLocked("task-1", inst)
+	other := m.replayReportLocked("task-2", StrategyInstance{InstanceID: "inst-2", Mode: RunTypeReplay})
+	m.reportMu.Unlock()
+
+	base := time.Date(2026, 5, 19, 21, 0, 0, 0, time.Local)
+	m.HandleFill(FillEvent{InstanceID: "inst-1", Symbol: "rb2601", Direction: "buy", Price: 3500, Volume: 1, VolumeMultiple: 10, Commission: 0.35, TradeTime: base})
+	m.HandleReplayBar(BarEvent{InstrumentID: "rb2601", ReplayTaskID: "task-1", AdjustedTime: base.Add(time.Minute), Close: 3510})
+	m.HandleReplayBar(BarEvent{InstrumentID: "rb2601", ReplayTaskID: "task-2", AdjustedTime: base.Add(2 * time.Minute), Close: 3400})
+	m.HandleFill(FillEvent{InstanceID: "inst-1", Symbol: "rb2601", Direction: "sell", Price: 3520, Volume: 1, VolumeMultiple: 10, Commission: 0.352, TradeTime: base.Add(3 * time.Minute)})
+
+	summary := report.summary()
+	if summary["trades"] != 1 || summary["initial_balance"] != 50_000.0 || summary["signal_count"] != 0 {
+		t.Fatalf("summary = %v", summary)
+	}
+	netProfit, _ := summary["net_profit"].(float64)
+	if want := 200 - 0.35 - 0.352; netProfit < want-1e-9 || netProfit > want+1e-9 {
+		t.Fatalf("net_profit = %v, want %v", netProfit, want)
+	}
+	result := report.result()
+	perfReport := result["performance"]
+	rows := result["strategy_analysis_table"].([]ReplayAnalysisReportRow)
+	if perfReport == nil || rows[0].Trades != 1 || rows[0].WinRate != 1 || rows[0].NetProfit != netProfit {
+		t.Fatalf("analysis = %+v", rows[0])
+	}
+	// 其他回放任务的 K 线不应标记到本报告上。
+	if got := report.performance().Equity; len(got) != 3 || got[1].Equity != 50_000-0.35+100 {
+		t.Fatalf("equity = %+v", got)
+	}
+	if other.performance().Trades != 0 || len(other.performance().Equity) != 1 {
+		t.Fatalf("other report = %+v", other.performance())
+	}
+}
@@ -0,0 +1,49 @@
+// replay_session.go 负责回放策略实例与回放会话的绑定。
+// 回放行情按会话分发：实例的 replay_session 为空表示默认会话，只接收默认会话的回放 tick/bar；
+// 其它会话的实例只接收本会话的行情，互不串扰。实时模式不受会话影响。
+package strategy
+
+import (
+	"fmt"
+	"strings"
+
+	"ctp-future-kline/internal/replay"
+)
+
+// normalizeReplaySession 把会话标识转成小写，default 归一为空。
+func normalizeReplaySession(raw string) string {
+	session := strings.ToLower(strings.TrimSpace(raw))
+	if session == replay.DefaultSessionID {
+		return ""
+	}
+	return session
+}
+
+// validateReplaySession 复用回放会话的标识规则，保证实例绑定的会话能被会话管理器识别。
+func validateReplaySession(raw string) error {
+	if _, err := replay.NormalizeSessionID(raw); err != nil {
+		return fmt.Errorf("invalid replay_session: %w", err)
+	}
+	return nil
+}
+
+// replaySessionMatches 判断回放事件是否属于实例绑定的会话；非回放模式总是匹配。
+func replaySessionMatches(inst StrategyInstance, mode string, eventSession string) bool {
+	if mode != RunTypeReplay {
+		return true
+	}
+	return normalizeReplaySession(inst.ReplaySession) == normalizeReplaySession(eventSession)
+}
+
+func filterReplaySession(items []StrategyInstance, mode string, eventSession string) []StrategyInstance {
+	if mode != RunTypeReplay {
+		return items
+	}
+	out := items[:0]
+	for _, item := range items {
+		if replaySessionMatches(item, mode, eventSession) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package strategy
+
+import "testing"
+
+func TestReplaySessionRoutesReplayEventsOnly(t *testing.T) {
+	t.Parallel()
+
+	defaultInst := StrategyInstance{InstanceID: "a"}
+	aliceInst := StrategyInstance{InstanceID: "b", ReplaySession: "Alice"}
+
+	if !replaySessionMatches(defaultInst, RunTypeReplay, "") || !replaySessionMatches(defaultInst, RunTypeReplay, "default") {
+		t.Fatal("default instance should receive default session events")
+	}
+	if replaySessionMatches(defaultInst, RunTypeReplay, "alice") {
+		t.Fatal("default instance should not receive alice session events")
+	}
+	if !replaySessionMatches(aliceInst, RunTypeReplay, "alice") || replaySessionMatches(aliceInst, RunTypeReplay, "") {
+		t.Fatal("alice instance should only receive alice session events")
+	}
+	if !replaySessionMatches(aliceInst, RunTypeRealtime, "") {
+		t.Fatal("realtime events should ignore replay sessions")
+	}
+
+	got := filterReplaySession([]StrategyInstance{defaultInst, aliceInst}, RunTypeReplay, "alice")
+	if len(got) != 1 || got[0].InstanceID != "b" {
+		t.Fatalf("filterReplaySession = %+v, want only alice instance", got)
+	}
+}
+
+func TestValidateReplaySession(t *testing.T) {
+	t.Parallel()
+
+	for _, ok := range []string{"", "default", "alice_01"} {
+		if err := validateReplaySession(ok); err != nil {
+			t.Fatalf("validateReplaySession(%q) error: %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"a-b", "abcdefghijklmnopqrstuvwxyz"} {
+		if err := validateReplaySession(bad); err == nil {
+			t.Fatalf("validateReplaySession(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+// risk_budget.go 负责实例级风险预算与账户级组合分配。
+// 预算写在实例参数 risk_budget 里，突破后实例进入只减仓的暂停状态，需人工 resume；
+// 账户限额来自 strategy.account_limits，同账户同合约的实例目标合计超限时按比例缩放。
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/config"
+	"ctp-future-kline/internal/klineclock"
+	"ctp-future-kline/internal/logger"
+)
+
+// RiskBudgetParamKey 是实例参数中风险预算的键。
+const RiskBudgetParamKey = "risk_budget"
+
+const (
+	RiskBreachMaxLots        = "max_lots_per_symbol"
+	RiskBreachMaxNotional    = "max_notional"
+	RiskBreachMaxDailyLoss   = "max_daily_loss"
+	RiskBreachMaxOrdersDaily = "max_orders_per_day"
+)
+
+// InstanceRiskBudget 是单个实例的风险预算，字段为 0 表示不限制。
+type InstanceRiskBudget struct {
+	// MaxLotsPerSymbol 是单合约目标持仓的最大手数（绝对值）。
+	MaxLotsPerSymbol float64 `json:"max_lots_per_symbol,omitempty"`
+	// MaxNotional 是单合约目标持仓的最大名义价值，按最新 K 线收盘价和合约乘数计算。
+	MaxNotional float64 `json:"max_notional,omitempty"`
+	// MaxDailyLoss 是当日最大亏损（含浮动盈亏和手续费），取正数。
+	MaxDailyLoss float64 `json:"max_daily_loss,omitempty"`
+	// MaxOrdersPerDay 是当日最多允许产生的调仓次数。
+	MaxOrdersPerDay int `json:"max_orders_per_day,omitempty"`
+}
+
+func (b InstanceRiskBudget) empty() bool {
+	return b.MaxLotsPerSymbol <= 0 && b.MaxNotional <= 0 && b.MaxDailyLoss <= 0 && b.MaxOrdersPerDay <= 0
+}
+
+// ParseInstanceRiskBudget 从实例参数解析风险预算，缺省或格式错误时返回零值。
+func ParseInstanceRiskBudget(params map[string]any) InstanceRiskBudget {
+	raw, ok := params[RiskBudgetParamKey]
+	if !ok || raw == nil {
+		return InstanceRiskBudget{}
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return InstanceRiskBudget{}
+	}
+	var budget InstanceRiskBudget
+	if err := json.Unmarshal(data, &budget); err != nil {
+		return InstanceRiskBudget{}
+	}
+	return budget
+}
+
+// RiskBreach 描述一次风险预算突破，写入 trace 并使实例自动暂停。
+type RiskBreach struct {
+	InstanceID string    `json:"instance_id"`
+	Symbol     string    `json:"symbol"`
+	Kind       string    `json:"kind"`
+	Value      float64   `json:"value"`
+	Limit      float64   `json:"limit"`
+	Reason     string    `json:"reason"`
+	At         time.Time `json:"at"`
+}
+
+func newRiskBreach(instanceID string, symbol string, kind string, value float64, limit float64) *RiskBreach {
+	return &RiskBreach{
+		InstanceID: instanceID,
+		Symbol:     symbol,
+		Kind:       kind,
+		Value:      value,
+		Limit:      limit,
+		Reason:     fmt.Sprintf("risk budget %s breached: %.2f > %.2f", kind, value, limit),
+		At:         time.Now(),
+	}
+}
+
+// riskPosition 是实例在单个合约上的均价持仓，用于估算当日盈亏。
+type riskPosition struct {
+	lots     float64
+	avgPrice float64
+	mark     float64
+	multiple float64
+}
+
+// instanceRiskState 是实例的风险账本：当日调仓次数、已实现/浮动盈亏和暂停原因。
+type instanceRiskState struct {
+	budget      InstanceRiskBudget
+	day         string
+	orders      int
+	realized    float64
+	dayStartPnL float64
+	positions   map[string]*riskPosition
+	paused      *RiskBreach
+}
+
+func (s *instanceRiskState) totalPnL() float64 {
+	total := s.realized
+	for _, pos := range s.positions {
+		if pos.mark > 0 {
+			total += (pos.mark - pos.avgPrice) * pos.lots * pos.multiple
+		}
+	}
+	return total
+}
+
+func (s *instanceRiskState) dailyPnL() float64 {
+	return s.totalPnL() - s.dayStartPnL
+}
+
+// rollDay 在交易日切换时清零调仓计数，并以当前盈亏作为新一天的起点。
+func (s *instanceRiskState) rollDay(day string) {
+	if day == "" || s.day == day {
+		return
+	}
+	s.day = day
+	s.orders = 0
+	s.dayStartPnL = s.totalPnL()
+}
+
+// fill 按均价法记一笔成交，lots 为带方向的手数。
+func (s *instanceRiskState) fill(symbol string, lots float64, price float64, multiple float64, commission float64) {
+	if math.Abs(lots) < 1e-9 || price <= 0 {
+		return
+	}
+	if s.positions == nil {
+		s.positions = make(map[string]*riskPosition)
+	}
+	pos := s.positions[symbol]
+	if pos == nil {
+		pos = &riskPosition{}
+		s.positions[symbol] = pos
+	}
+	if multiple > 0 {
+		pos.multiple = multiple
+	}
+	if pos.multiple <= 0 {
+		pos.multiple = 1
+	}
+	s.realized -= commission
+	if pos.lots == 0 || (pos.lots > 0) == (lots > 0) {
+		total := pos.lots + lots
+		pos.avgPrice = (pos.avgPrice*math.Abs(pos.lots) + price*math.Abs(lots)) / math.Abs(total)
+		pos.lots = total
+	} else {
+		closed := math.Min(math.Abs(lots), math.Abs(pos.lots))
+		sign := 1.0
+		if pos.lots < 0 {
+			sign = -1
+		}
+		s.realized += (price - pos.avgPrice) * closed * sign * pos.multiple
+		pos.lots += lots
+		if math.Abs(pos.lots) < 1e-9 {
+			pos.lots = 0
+		} else if (pos.lots > 0) != (sign > 0) {
+			pos.avgPrice = price
+		}
+	}
+	pos.mark = price
+}
+
+// SetAccountLimits 设置账户级单合约限额，AccountID 为空或 * 的条目对所有账户生效。
+func (e *ExecutionEngine) SetAccountLimits(items []config.StrategyAccountLimit) {
+	limits := make(map[string]config.StrategyAccountLimit, len(items))
+	for _, item := range items {
+		account := normalizePositionPart(item.AccountID)
+		if account == "*" {
+			account = ""
+		}
+		limits[account] = item
+	}
+	e.mu.Lock()
+	e.accountLimits = limits
+	e.mu.Unlock()
+}
+
+// SetContractMultiplier 注入合约乘数查询，名义价值和盈亏按它换算；未注入时按 1 计算。
+func (e *ExecutionEngine) SetContractMultiplier(fn func(symbol string) float64) {
+	e.mu.Lock()
+	e.multiplier = fn
+	e.multiples = nil
+	e.mu.Unlock()
+}
+
+// MarkPrice 用最新收盘价给各实例盯市，返回本次新触发当日亏损预算的实例。
+func (e *ExecutionEngine) MarkPrice(symbol string, price float64, at time.Time) []RiskBreach {
+	if price <= 0 {
+		return nil
+	}
+	symbol = normalizePositionPart(symbol)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.marks == nil {
+		e.marks = make(map[string]float64)
+	}
+	e.marks[symbol] = price
+	if !at.IsZero() {
+		e.riskDay = riskTradingDay(at)
+	}
+	var breaches []RiskBreach
+	for instanceID, state := range e.risk {
+		pos := state.positions[symbol]
+		if pos == nil {
+			continue
+		}
+		pos.mark = price
+		state.rollDay(e.riskDay)
+		if breach := dailyLossBreach(instanceID, symbol, state); breach != nil && state.paused == nil {
+			state.paused = breach
+			breaches = append(breaches, *breach)
+		}
+	}
+	return breaches
+}
+
+// RecordInstanceFill 把真实成交计入实例风险账本。
+func (e *ExecutionEngine) RecordInstanceFill(fill FillEvent) {
+	lots := float64(fill.Volume)
+	if strings.EqualFold(strings.TrimSpace(fill.Direction), "sell") {
+		lots = -lots
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	symbol := normalizePositionPart(fill.Symbol)
+	multiple := fill.VolumeMultiple
+	if multiple <= 0 {
+		multiple = e.multipleLocked(symbol)
+	}
+	state := e.riskStateLocked(fill.InstanceID)
+	if !fill.TradeTime.IsZero() {
+		state.rollDay(riskTradingDay(fill.TradeTime))
+	}
+	state.fill(symbol, lots, fill.Price, multiple, fill.Commission)
+}
+
+// FillSimulated 在没有外部下单执行器时，按最新价把分配结果的持仓变化记为模拟成交。
+func (e *ExecutionEngine) FillSimulated(symbol string, deltas map[string]float64) {
+	if len(deltas) == 0 {
+		return
+	}
+	symbol = normalizePositionPart(symbol)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	price := e.marks[symbol]
+	multiple := e.multipleLocked(symbol)
+	for instanceID, delta := range deltas {
+		e.riskStateLocked(instanceID).fill(symbol, delta, price, multiple, 0)
+	}
+}
+
+// ResumeInstance 解除实例的风险暂停，返回实例此前是否处于暂停状态。
+func (e *ExecutionEngine) ResumeInstance(instanceID string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state := e.risk[strings.TrimSpace(instanceID)]
+	if state == nil || state.paused == nil {
+		return false
+	}
+	state.paused = nil
+	state.dayStartPnL = state.totalPnL()
+	return true
+}
+
+// RestoreInstancePause 在重启后恢复实例已保存的风险暂停状态。
+func (e *ExecutionEngine) RestoreInstancePause(instanceID string, breach RiskBreach) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.riskStateLocked(instanceID).paused = &breach
+}
+
+// PausedInstances 返回当前因风险预算暂停的实例及其突破原因。
+func (e *ExecutionEngine) PausedInstances() map[string]RiskBreach {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.pausedInstancesLocked()
+}
+
+func (e *ExecutionEngine) pausedInstancesLocked() map[string]RiskBreach {
+	out := make(map[string]RiskBreach)
+	for instanceID, state := range e.risk {
+		if state.paused != nil {
+			out[instanceID] = *state.paused
+		}
+	}
+	return out
+}
+
+// checkInstanceBudgetLocked 检查实例新目标是否满足预算；已暂停的实例只允许向零减仓。
+// 返回的 breach 非空表示本次新触发了预算，调用方需要阻断并记录；blockedReason 非空表示应阻断。
+func (e *ExecutionEngine) checkInstanceBudgetLocked(instance StrategyInstance, symbol string, target float64, currentInstance float64) (*RiskBreach, string) {
+	budget := ParseInstanceRiskBudget(instance.Params)
+	state := e.risk[strings.TrimSpace(instance.InstanceID)]
+	if budget.empty() && state == nil {
+		return nil, ""
+	}
+	state = e.riskStateLocked(instance.InstanceID)
+	state.budget = budget
+	state.rollDay(e.currentRiskDayLocked())
+	reducing := math.Abs(target) <= math.Abs(currentInstance) && target*currentInstance >= 0
+	if state.paused != nil {
+		if reducing {
+			return nil, ""
+		}
+		return nil, "instance paused by risk budget: " + state.paused.Reason
+	}
+	if reducing {
+		return nil, ""
+	}
+	var breach *RiskBreach
+	if budget.MaxLotsPerSymbol > 0 && math.Abs(target) > budget.MaxLotsPerSymbol {
+		breach = newRiskBreach(instance.InstanceID, symbol, RiskBreachMaxLots, math.Abs(target), budget.MaxLotsPerSymbol)
+	} else if price := e.marks[normalizePositionPart(symbol)]; budget.MaxNotional > 0 && price > 0 && math.Abs(target)*price*e.multipleLocked(symbol) > budget.MaxNotional {
+		breach = newRiskBreach(instance.InstanceID, symbol, RiskBreachMaxNotional, math.Abs(target)*price*e.multipleLocked(symbol), budget.MaxNotional)
+	} else if b := dailyLossBreach(instance.InstanceID, symbol, state); b != nil {
+		breach = b
+	} else if budget.MaxOrdersPerDay > 0 && target != currentInstance && state.orders >= budget.MaxOrdersPerDay {
+		breach = newRiskBreach(instance.InstanceID, symbol, RiskBreachMaxOrdersDaily, float64(state.orders+1), float64(budget.MaxOrdersPerDay))
+	}
+	if breach == nil {
+		return nil, ""
+	}
+	state.paused = breach
+	return breach, breach.Reason
+}
+
+func dailyLossBreach(instanceID string, symbol string, state *instanceRiskState) *RiskBreach {
+	if state.budget.MaxDailyLoss <= 0 {
+		return nil
+	}
+	if loss := -state.dailyPnL(); loss > state.budget.MaxDailyLoss {
+		return newRiskBreach(instanceID, symbol, RiskBreachMaxDailyLoss, loss, state.budget.MaxDailyLoss)
+	}
+	return nil
+}
+
+// allocateLocked 按账户限额缩放同账户同合约下各实例的原始目标，override 用于计划阶段代入尚未生效的新目标。
+// 缩放后向零取整，返回各子持仓的分配目标和缩放比例。
+func (e *ExecutionEngine) allocateLocked(netKey strategyNetPositionKey, override *strategySubPositionKey, overrideTarget float64) (map[strategySubPositionKey]float64, float64) {
+	raw := make(map[strategySubPositionKey]float64)
+	for key, value := range e.rawTargets {
+		if subPositionNetKey(key) == netKey {
+			raw[key] = value
+		}
+	}
+	if override != nil {
+		raw[*override] = overrideTarget
+	}
+	var gross float64
+	for _, value := range raw {
+		gross += math.Abs(value)
+	}
+	scale := 1.0
+	if limit, ok := e.accountLimitLocked(netKey.AccountID); ok && gross > 0 {
+		if limit.MaxLotsPerSymbol > 0 && gross > limit.MaxLotsPerSymbol {
+			scale = limit.MaxLotsPerSymbol / gross
+		}
+		if price := e.marks[netKey.Symbol]; limit.MaxNotionalPerSymbol > 0 && price > 0 {
+			if notional := gross * price * e.multipleLocked(netKey.Symbol); notional > limit.MaxNotionalPerSymbol {
+				scale = math.Min(scale, limit.MaxNotionalPerSymbol/notional)
+			}
+		}
+	}
+	out := make(map[strategySubPositionKey]float64, len(raw))
+	for key, value := range raw {
+		out[key] = math.Trunc(value * scale)
+	}
+	return out, scale
+}
+
+func (e *ExecutionEngine) accountLimitLocked(accountID string) (config.StrategyAccountLimit, bool) {
+	if limit, ok := e.accountLimits[accountID]; ok {
+		return limit, true
+	}
+	limit, ok := e.accountLimits[""]
+	return limit, ok
+}
+
+func (e *ExecutionEngine) riskStateLocked(instanceID string) *instanceRiskState {
+	instanceID = strings.TrimSpace(instanceID)
+	if e.risk == nil {
+		e.risk = make(map[string]*instanceRiskState)
+	}
+	state := e.risk[instanceID]
+	if state == nil {
+		state = &instanceRiskState{day: e.currentRiskDayLocked()}
+		e.risk[instanceID] = state
+	}
+	return state
+}
+
+func (e *ExecutionEngine) currentRiskDayLocked() string {
+	if e.riskDay != "" {
+		return e.riskDay
+	}
+	return riskTradingDay(time.Now())
+}
+
+// riskTradingDay 返回当日亏损和调仓次数使用的交易日，夜盘计入下一交易日。
+func riskTradingDay(at time.Time) string {
+	return klineclock.TradingDayOf(at).Format("2006-01-02")
+}
+
+// multipleLocked 按合约缓存乘数，避免在持锁盯市时反复查询合约元数据。
+func (e *ExecutionEngine) multipleLocked(symbol string) float64 {
+	symbol = normalizePositionPart(symbol)
+	if v, ok := e.multiples[symbol]; ok {
+		return v
+	}
+	v := 1.0
+	if e.multiplier != nil {
+		if resolved := e.multiplier(symbol); resolved > 0 {
+			v = resolved
+		}
+	}
+	if e.multiples == nil {
+		e.multiples = make(map[string]float64)
+	}
+	e.multiples[symbol] = v
+	return v
+}
+
+// SetContractMultiplier 注入合约乘数查询，供风险预算计算名义价值和盈亏。
+func (m *Manager) SetContractMultiplier(fn func(symbol string) float64) {
+	if m == nil || m.exec == nil {
+		return
+	}
+	m.exec.SetContractMultiplier(fn)
+}
+
+// ResumeInstanceRisk 人工解除实例的风险预算暂停，并以当前盈亏重新计算当日亏损。
+func (m *Manager) ResumeInstanceRisk(instanceID string) (OrdersStatus, error) {
+	if m == nil || m.exec == nil {
+		return OrdersStatus{}, fmt.Errorf("strategy manager not ready")
+	}
+	if !m.exec.ResumeInstance(instanceID) {
+		return OrdersStatus{}, fmt.Errorf("instance %s is not paused by risk budget", instanceID)
+	}
+	m.saveRiskPause(instanceID, nil)
+	status := m.exec.Status()
+	m.broadcast("strategy_status_update", m.Status())
+	m.broadcast("orders_status_update", status)
+	return status, nil
+}
+
+// markRiskBudgets 用实盘 K 线收盘价盯市，并记录本次新触发当日亏损预算的实例。
+func (m *Manager) markRiskBudgets(ev BarEvent) {
+	if m == nil || m.exec == nil {
+		return
+	}
+	eventTime := strategyBarEventTime(ev)
+	for _, breach := range m.exec.MarkPrice(ev.InstrumentID, ev.Close, eventTime) {
+		m.mu.RLock()
+		inst, ok := m.instances[breach.InstanceID]
+		m.mu.RUnlock()
+		if ok {
+			m.persistRiskBreach(inst, ev.InstrumentID, inst.Mode, eventTime, breach)
+		}
+	}
+}
+
+// recordRiskFill 把非回放实例的真实成交计入风险账本。
+func (m *Manager) recordRiskFill(fill FillEvent) {
+	if m == nil || m.exec == nil {
+		return
+	}
+	m.mu.RLock()
+	inst, ok := m.instances[fill.InstanceID]
+	m.mu.RUnlock()
+	if !ok || strings.ToLower(strings.TrimSpace(inst.Mode)) == RunTypeReplay {
+		return
+	}
+	m.exec.RecordInstanceFill(fill)
+}
+
+func (m *Manager) persistRiskBreach(inst StrategyInstance, symbol string, mode string, eventTime time.Time, breach RiskBreach) {
+	logger.Warn("strategy risk budget breached, instance paused",
+		"instance_id", inst.InstanceID,
+		"symbol", symbol,
+		"kind", breach.Kind,
+		"value", breach.Value,
+		"limit", breach.Limit,
+	)
+	m.persistTrace(inst, symbol, mode, eventTime, StrategyTraceRecord{
+		EventType: "risk_budget",
+		StepKey:   "risk_budget_breach",
+		StepLabel: "风险预算突破",
+		StepIndex: 5,
+		StepTotal: 5,
+		Status:    RiskStatusBlocked,
+		Reason:    breach.Reason,
+		Metrics: map[string]any{
+			"kind":        breach.Kind,
+			"value":       breach.Value,
+			"limit":       breach.Limit,
+			"risk_budget": ParseInstanceRiskBudget(inst.Params),
+			"paused":      true,
+		},
+	})
+	m.saveRiskPause(inst.InstanceID, &breach)
+	m.broadcast("orders_status_update", m.exec.Status())
+}
+
+// saveRiskPause 把实例的风险暂停状态随实例记录保存，breach 为 nil 表示已解除。
+func (m *Manager) saveRiskPause(instanceID string, breach *RiskBreach) {
+	m.mu.Lock()
+	if inst, ok := m.instances[instanceID]; ok {
+		inst.RiskPause = breach
+		m.instances[instanceID] = inst
+	}
+	m.mu.Unlock()
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveInstanceRiskPause(instanceID, breach); err != nil {
+		logger.Warn("strategy risk pause save failed", "instance_id", instanceID, "error", err)
+	}
+}
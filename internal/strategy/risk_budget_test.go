@@ -0,0 +1,183 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/config"
+)
+
+func TestAccountLimitScalesInstanceTargets(t *testing.T) {
+	t.Parallel()
+
+	engine := NewExecutionEngine()
+	engine.SetAccountLimits([]config.StrategyAccountLimit{{AccountID: "paper", MaxLotsPerSymbol: 4}})
+	instA := StrategyInstance{InstanceID: "alloc-a", AccountID: "paper", Timeframe: "1m"}
+	instB := StrategyInstance{InstanceID: "alloc-b", AccountID: "paper", Timeframe: "5m"}
+
+	openA := engine.PlanInstanceTarget(instA, "rb2601", 4, RunTypeRealtime, 0)
+	if openA.AllocationScale != 1 || openA.Plan.TargetPosition != 4 {
+		t.Fatalf("openA = %+v, want unscaled target 4", openA)
+	}
+	engine.ApplyInstanceTarget(instA, "rb2601", 4, RunTypeRealtime, openA.Plan)
+
+	openB := engine.PlanInstanceTarget(instB, "rb2601", 4, RunTypeRealtime, engine.CurrentPosition("rb2601"))
+	if openB.AllocationScale != 0.5 || openB.InstanceTargetPosition != 2 || openB.RequestedTargetPosition != 4 {
+		t.Fatalf("openB = %+v, want scale 0.5 and allocated target 2", openB)
+	}
+	if openB.Plan.TargetPosition != 4 || openB.Plan.PlannedDelta != 0 {
+		t.Fatalf("openB plan = %+v, want net target capped at 4", openB.Plan)
+	}
+	deltas := engine.ApplyInstanceTarget(instB, "rb2601", 4, RunTypeRealtime, openB.Plan)
+	if deltas["alloc-a"] != -2 || deltas["alloc-b"] != 2 {
+		t.Fatalf("deltas = %v, want A -2 and B +2", deltas)
+	}
+
+	closeA := engine.PlanInstanceTarget(instA, "rb2601", 0, RunTypeRealtime, engine.CurrentPosition("rb2601"))
+	if closeA.Plan.TargetPosition != 4 || closeA.AllocationScale != 1 {
+		t.Fatalf("closeA = %+v, want B restored to its raw target 4", closeA)
+	}
+}
+
+func TestInstanceBudgetBreachPausesUntilResume(t *testing.T) {
+	t.Parallel()
+
+	engine := NewExecutionEngine()
+	inst := StrategyInstance{
+		InstanceID: "budget-lots",
+		AccountID:  "paper",
+		Timeframe:  "1m",
+		Params:     map[string]any{RiskBudgetParamKey: map[string]any{"max_lots_per_symbol": 2}},
+	}
+	open := engine.PlanInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, 0)
+	if open.Breach != nil || open.Plan.RiskStatus != RiskStatusAllowed {
+		t.Fatalf("open = %+v, want allowed", open)
+	}
+	engine.ApplyInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, open.Plan)
+
+	over := engine.PlanInstanceTarget(inst, "rb2601", 3, RunTypeRealtime, 2)
+	if over.Breach == nil || over.Breach.Kind != RiskBreachMaxLots || over.Plan.RiskStatus != RiskStatusBlocked {
+		t.Fatalf("over = %+v, want max lots breach", over)
+	}
+	if _, ok := engine.Status().PausedInstances[inst.InstanceID]; !ok {
+		t.Fatal("breached instance should be reported as paused")
+	}
+	again := engine.PlanInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, 2)
+	if again.Breach != nil || again.Plan.RiskStatus != RiskStatusAllowed {
+		t.Fatalf("paused instance holding its position should stay allowed: %+v", again)
+	}
+	flip := engine.PlanInstanceTarget(inst, "rb2601", -1, RunTypeRealtime, 2)
+	if flip.Plan.RiskStatus != RiskStatusBlocked || flip.Breach != nil {
+		t.Fatalf("paused instance should not reverse: %+v", flip)
+	}
+	reduce := engine.PlanInstanceTarget(inst, "rb2601", 1, RunTypeRealtime, 2)
+	if reduce.Plan.RiskStatus != RiskStatusAllowed {
+		t.Fatalf("paused instance should be allowed to reduce: %+v", reduce)
+	}
+
+	if !engine.ResumeInstance(inst.InstanceID) {
+		t.Fatal("ResumeInstance() = false, want true")
+	}
+	if engine.ResumeInstance(inst.InstanceID) {
+		t.Fatal("second ResumeInstance() should report not paused")
+	}
+	if replay := engine.PlanInstanceTarget(inst, "rb2601", 5, RunTypeReplay, 0); replay.Breach != nil {
+		t.Fatalf("replay should not evaluate budgets: %+v", replay)
+	}
+}
+
+func TestDailyLossAndOrderBudgets(t *testing.T) {
+	t.Parallel()
+
+	engine := NewExecutionEngine()
+	engine.SetContractMultiplier(func(string) float64 { return 10 })
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	engine.MarkPrice("rb2601", 100, day)
+	inst := StrategyInstance{
+		InstanceID: "budget-loss",
+		AccountID:  "paper",
+		Timeframe:  "1m",
+		Params:     map[string]any{RiskBudgetParamKey: map[string]any{"max_daily_loss": 100, "max_notional": 5000}},
+	}
+	if big := engine.PlanInstanceTarget(inst, "rb2601", 6, RunTypeRealtime, 0); big.Breach == nil || big.Breach.Kind != RiskBreachMaxNotional {
+		t.Fatalf("big = %+v, want notional breach", big)
+	}
+	engine.ResumeInstance(inst.InstanceID)
+
+	open := engine.PlanInstanceTarget(inst, "rb2601", 1, RunTypeRealtime, 0)
+	engine.FillSimulated("rb2601", engine.ApplyInstanceTarget(inst, "rb2601", 1, RunTypeRealtime, open.Plan))
+	if breaches := engine.MarkPrice("rb2601", 95, day.Add(time.Minute)); len(breaches) != 0 {
+		t.Fatalf("loss 50 should not breach: %+v", breaches)
+	}
+	breaches := engine.MarkPrice("rb2601", 85, day.Add(2*time.Minute))
+	if len(breaches) != 1 || breaches[0].Kind != RiskBreachMaxDailyLoss || breaches[0].Value != 150 {
+		t.Fatalf("breaches = %+v, want daily loss 150", breaches)
+	}
+	if again := engine.MarkPrice("rb2601", 80, day.Add(3*time.Minute)); len(again) != 0 {
+		t.Fatalf("already paused instance should not breach again: %+v", again)
+	}
+
+	orders := StrategyInstance{
+		InstanceID: "budget-orders",
+		AccountID:  "paper",
+		Timeframe:  "1m",
+		Params:     map[string]any{RiskBudgetParamKey: map[string]any{"max_orders_per_day": 2}},
+	}
+	current := 0.0
+	for i, target := range []float64{1, 2} {
+		plan := engine.PlanInstanceTarget(orders, "ag2606", target, RunTypeRealtime, current)
+		if plan.Breach != nil {
+			t.Fatalf("order %d breached early: %+v", i, plan.Breach)
+		}
+		engine.ApplyInstanceTarget(orders, "ag2606", target, RunTypeRealtime, plan.Plan)
+		current = target
+	}
+	if third := engine.PlanInstanceTarget(orders, "ag2606", 3, RunTypeRealtime, current); third.Breach == nil || third.Breach.Kind != RiskBreachMaxOrdersDaily {
+		t.Fatalf("third = %+v, want max orders breach", third)
+	}
+}
+
+func TestOrderBudgetCountsNightSessionInNextTradingDay(t *testing.T) {
+	t.Parallel()
+
+	engine := NewExecutionEngine()
+	inst := StrategyInstance{
+		InstanceID: "budget-night",
+		AccountID:  "paper",
+		Timeframe:  "1m",
+		Params:     map[string]any{RiskBudgetParamKey: map[string]any{"max_orders_per_day": 1}},
+	}
+	// 周五夜盘与下周一日盘属于同一交易日，调仓次数不应在自然日切换时清零。
+	engine.MarkPrice("rb2601", 100, time.Date(2026, 3, 6, 21, 5, 0, 0, time.Local))
+	open := engine.PlanInstanceTarget(inst, "rb2601", 1, RunTypeRealtime, 0)
+	if open.Breach != nil {
+		t.Fatalf("night open breached: %+v", open.Breach)
+	}
+	engine.ApplyInstanceTarget(inst, "rb2601", 1, RunTypeRealtime, open.Plan)
+	engine.MarkPrice("rb2601", 100, time.Date(2026, 3, 9, 9, 5, 0, 0, time.Local))
+	if add := engine.PlanInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, 1); add.Breach == nil || add.Breach.Kind != RiskBreachMaxOrdersDaily {
+		t.Fatalf("monday add = %+v, want max orders breach in the same trading day", add)
+	}
+	engine.MarkPrice("rb2601", 100, time.Date(2026, 3, 9, 21, 5, 0, 0, time.Local))
+	engine.ResumeInstance(inst.InstanceID)
+	if next := engine.PlanInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, 1); next.Breach != nil {
+		t.Fatalf("next trading day add breached: %+v", next.Breach)
+	}
+}
+
+func TestRestoredRiskPauseBlocksIncreases(t *testing.T) {
+	t.Parallel()
+
+	engine := NewExecutionEngine()
+	inst := StrategyInstance{InstanceID: "budget-restored", AccountID: "paper", Timeframe: "1m"}
+	engine.RestoreInstancePause(inst.InstanceID, RiskBreach{InstanceID: inst.InstanceID, Kind: RiskBreachMaxDailyLoss, Reason: "restored"})
+	if _, ok := engine.PausedInstances()[inst.InstanceID]; !ok {
+		t.Fatal("restored pause should be reported")
+	}
+	if add := engine.PlanInstanceTarget(inst, "rb2601", 2, RunTypeRealtime, 0); add.Plan.RiskStatus != RiskStatusBlocked {
+		t.Fatalf("restored pause should block increases: %+v", add.Plan)
+	}
+	if reduce := engine.PlanInstanceTarget(inst, "rb2601", 0, RunTypeRealtime, 0); reduce.Plan.RiskStatus != RiskStatusAllowed {
+		t.Fatalf("restored pause should allow flattening: %+v", reduce.Plan)
+	}
+}
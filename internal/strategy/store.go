@@ -34,17 +34,37 @@ func (s *Store) Close() error {
 	return s.db.Close()
 }
 
+// strategyColumnMigrations 是旧库需要补齐的列，按表内顺序追加。
+var strategyColumnMigrations = []struct {
+	table  string
+	column string
+	ddl    string
+}{
+	{"strategy_instances", "last_started_at", `ALTER TABLE strategy_instances ADD COLUMN last_started_at DATETIME NULL AFTER last_signal_at`},
+	{"strategy_instances", "definition_version", `ALTER TABLE strategy_instances ADD COLUMN definition_version VARCHAR(64) NOT NULL DEFAULT '' AFTER last_error`},
+	{"strategy_instances", "code_hash", `ALTER TABLE strategy_instances ADD COLUMN code_hash VARCHAR(64) NOT NULL DEFAULT '' AFTER definition_version`},
+	{"strategy_instances", "execution_mode", `ALTER TABLE strategy_instances ADD COLUMN execution_mode VARCHAR(16) NOT NULL DEFAULT 'auto' AFTER code_hash`},
+	{"strategy_instances", "replay_session", `ALTER TABLE strategy_instances ADD COLUMN replay_session VARCHAR(32) NOT NULL DEFAULT '' AFTER execution_mode`},
+	{"strategy_instances", "risk_pause_json", `ALTER TABLE strategy_instances ADD COLUMN risk_pause_json JSON NULL AFTER replay_session`},
+	{"strategy_definitions", "code_hash", `ALTER TABLE strategy_definitions ADD COLUMN code_hash VARCHAR(64) NOT NULL DEFAULT '' AFTER version`},
+	{"strategy_runs", "definition_version", `ALTER TABLE strategy_runs ADD COLUMN definition_version VARCHAR(64) NOT NULL DEFAULT '' AFTER last_error`},
+	{"strategy_runs", "code_hash", `ALTER TABLE strategy_runs ADD COLUMN code_hash VARCHAR(64) NOT NULL DEFAULT '' AFTER definition_version`},
+}
+
 func (s *Store) ensureStrategyInstanceColumns() error {
 	if s == nil || s.db == nil {
 		return nil
 	}
-	hasLastStartedAt, err := dbx.TableHasColumn(s.db, "strategy_instances", "last_started_at")
-	if err != nil {
-		return err
-	}
-	if !hasLastStartedAt {
-		if _, err := s.db.Exec(`ALTER TABLE strategy_instances ADD COLUMN last_started_at DATETIME NULL AFTER last_signal_at`); err != nil {
-			return fmt.Errorf("add strategy_instances.last_started_at failed: %w", err)
+	for _, item := range strategyColumnMigrations {
+		exists, err := dbx.TableHasColumn(s.db, item.table, item.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if _, err := s.db.Exec(item.ddl); err != nil {
+			return fmt.Errorf("add %s.%s failed: %w", item.table, item.column, err)
 		}
 	}
 	return nil
@@ -59,15 +79,16 @@ func (s *Store) UpsertDefinition(def StrategyDefinition) error {
 		def.UpdatedAt = time.Now()
 	}
 	_, err = s.db.Exec(`
-INSERT INTO strategy_definitions(strategy_id,display_name,entry_script,version,default_params_json,updated_at)
-VALUES(?,?,?,?,?,?)
+INSERT INTO strategy_definitions(strategy_id,display_name,entry_script,version,code_hash,default_params_json,updated_at)
+VALUES(?,?,?,?,?,?,?)
 ON DUPLICATE KEY UPDATE
 display_name=VALUES(display_name),
 entry_script=VALUES(entry_script),
 version=VALUES(version),
+code_hash=VALUES(code_hash),
 default_params_json=VALUES(default_params_json),
 updated_at=VALUES(updated_at)
-`, def.StrategyID, def.DisplayName, def.EntryScript, def.Version, string(params), def.UpdatedAt)
+`, def.StrategyID, def.DisplayName, def.EntryScript, def.Version, def.CodeHash, string(params), def.UpdatedAt)
 	return err
 }
 
@@ -90,8 +111,8 @@ func (s *Store) ReplaceDefinitions(defs []StrategyDefinition) error {
 		return err
 	}
 	stmt, err := tx.Prepare(`
-INSERT INTO strategy_definitions(strategy_id,display_name,entry_script,version,default_params_json,updated_at)
-VALUES(?,?,?,?,?,?)
+INSERT INTO strategy_definitions(strategy_id,display_name,entry_script,version,code_hash,default_params_json,updated_at)
+VALUES(?,?,?,?,?,?,?)
 `)
 	if err != nil {
 		return err
@@ -107,7 +128,7 @@ VALUES(?,?,?,?,?,?)
 			err = marshalErr
 			return err
 		}
-		if _, err = stmt.Exec(def.StrategyID, def.DisplayName, def.EntryScript, def.Version, string(params), def.UpdatedAt); err != nil {
+		if _, err = stmt.Exec(def.StrategyID, def.DisplayName, def.EntryScript, def.Version, def.CodeHash, string(params), def.UpdatedAt); err != nil {
 			return err
 		}
 	}
@@ -115,7 +136,7 @@ VALUES(?,?,?,?,?,?)
 }
 
 func (s *Store) ListDefinitions() ([]StrategyDefinition, error) {
-	rows, err := s.db.Query(`SELECT strategy_id,display_name,entry_script,version,default_params_json,updated_at FROM strategy_definitions ORDER BY strategy_id ASC`)
+	rows, err := s.db.Query(`SELECT strategy_id,display_name,entry_script,version,code_hash,default_params_json,updated_at FROM strategy_definitions ORDER BY strategy_id ASC`)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +145,7 @@ func (s *Store) ListDefinitions() ([]StrategyDefinition, error) {
 	for rows.Next() {
 		var def StrategyDefinition
 		var raw string
-		if err := rows.Scan(&def.StrategyID, &def.DisplayName, &def.EntryScript, &def.Version, &raw, &def.UpdatedAt); err != nil {
+		if err := rows.Scan(&def.StrategyID, &def.DisplayName, &def.EntryScript, &def.Version, &def.CodeHash, &raw, &def.UpdatedAt); err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal([]byte(raw), &def.DefaultParams)
@@ -133,6 +154,53 @@ func (s *Store) ListDefinitions() ([]StrategyDefinition, error) {
 	return out, rows.Err()
 }
 
+// RecordDefinitionVersions 把本次同步到的定义写入版本历史；同一 (策略, 版本号, 代码摘要) 只记第一次出现。
+func (s *Store) RecordDefinitionVersions(defs []StrategyDefinition) error {
+	now := time.Now()
+	for _, def := range defs {
+		params, err := json.Marshal(def.DefaultParams)
+		if err != nil {
+			return err
+		}
+		if _, err := s.db.Exec(`
+INSERT IGNORE INTO strategy_definition_versions(strategy_id,version,code_hash,display_name,entry_script,default_params_json,first_seen_at)
+VALUES(?,?,?,?,?,?,?)
+`, def.StrategyID, def.Version, def.CodeHash, def.DisplayName, def.EntryScript, string(params), now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDefinitionVersions 返回策略的版本历史，按首次出现时间升序。
+func (s *Store) ListDefinitionVersions(strategyID string) ([]StrategyDefinitionVersion, error) {
+	rows, err := s.db.Query(`SELECT strategy_id,version,code_hash,display_name,entry_script,default_params_json,first_seen_at FROM strategy_definition_versions WHERE strategy_id=? ORDER BY first_seen_at ASC, version ASC`, strategyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []StrategyDefinitionVersion
+	for rows.Next() {
+		var item StrategyDefinitionVersion
+		var raw string
+		if err := rows.Scan(&item.StrategyID, &item.Version, &item.CodeHash, &item.DisplayName, &item.EntryScript, &raw, &item.FirstSeenAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(raw), &item.DefaultParams)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// definitionPin 返回策略当前同步到的版本号和代码摘要，查不到时返回空值。
+func (s *Store) definitionPin(strategyID string) (string, string) {
+	var version, codeHash string
+	if err := s.db.QueryRow(`SELECT version,code_hash FROM strategy_definitions WHERE strategy_id=?`, strategyID).Scan(&version, &codeHash); err != nil {
+		return "", ""
+	}
+	return version, codeHash
+}
+
 func (s *Store) SaveInstance(inst StrategyInstance) error {
 	symbols, err := json.Marshal(inst.Symbols)
 	if err != nil {
@@ -148,8 +216,8 @@ func (s *Store) SaveInstance(inst StrategyInstance) error {
 	}
 	inst.UpdatedAt = now
 	_, err = s.db.Exec(`
-INSERT INTO strategy_instances(instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,updated_at,created_at)
-VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+INSERT INTO strategy_instances(instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,definition_version,code_hash,execution_mode,replay_session,updated_at,created_at)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 ON DUPLICATE KEY UPDATE
 strategy_id=VALUES(strategy_id),
 display_name=VALUES(display_name),
@@ -163,13 +231,17 @@ last_signal_at=VALUES(last_signal_at),
 last_started_at=VALUES(last_started_at),
 last_target_position=VALUES(last_target_position),
 last_error=VALUES(last_error),
+definition_version=VALUES(definition_version),
+code_hash=VALUES(code_hash),
+execution_mode=VALUES(execution_mode),
+replay_session=VALUES(replay_session),
 updated_at=VALUES(updated_at)
-`, inst.InstanceID, inst.StrategyID, inst.DisplayName, inst.Mode, inst.Status, inst.AccountID, string(symbols), inst.Timeframe, string(params), inst.LastSignalAt, inst.LastStartedAt, inst.LastTargetPosition, inst.LastError, inst.UpdatedAt, inst.CreatedAt)
+`, inst.InstanceID, inst.StrategyID, inst.DisplayName, inst.Mode, inst.Status, inst.AccountID, string(symbols), inst.Timeframe, string(params), inst.LastSignalAt, inst.LastStartedAt, inst.LastTargetPosition, inst.LastError, inst.DefinitionVersion, inst.CodeHash, normalizeExecutionMode(inst.ExecutionMode), normalizeReplaySession(inst.ReplaySession), inst.UpdatedAt, inst.CreatedAt)
 	return err
 }
 
 func (s *Store) ListInstances() ([]StrategyInstance, error) {
-	rows, err := s.db.Query(`SELECT instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,updated_at,created_at FROM strategy_instances ORDER BY COALESCE(last_started_at, created_at) DESC, created_at DESC`)
+	rows, err := s.db.Query(`SELECT instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,definition_version,code_hash,execution_mode,replay_session,risk_pause_json,updated_at,created_at FROM strategy_instances ORDER BY COALESCE(last_started_at, created_at) DESC, created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +254,8 @@ func (s *Store) ListInstances() ([]StrategyInstance, error) {
 		var lastSignal sql.NullTime
 		var lastStarted sql.NullTime
 		var lastError sql.NullString
-		if err := rows.Scan(&item.InstanceID, &item.StrategyID, &item.DisplayName, &item.Mode, &item.Status, &item.AccountID, &symbolsRaw, &item.Timeframe, &paramsRaw, &lastSignal, &lastStarted, &item.LastTargetPosition, &lastError, &item.UpdatedAt, &item.CreatedAt); err != nil {
+		var riskPause sql.NullString
+		if err := rows.Scan(&item.InstanceID, &item.StrategyID, &item.DisplayName, &item.Mode, &item.Status, &item.AccountID, &symbolsRaw, &item.Timeframe, &paramsRaw, &lastSignal, &lastStarted, &item.LastTargetPosition, &lastError, &item.DefinitionVersion, &item.CodeHash, &item.ExecutionMode, &item.ReplaySession, &riskPause, &item.UpdatedAt, &item.CreatedAt); err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal([]byte(symbolsRaw), &item.Symbols)
@@ -198,6 +271,7 @@ func (s *Store) ListInstances() ([]StrategyInstance, error) {
 		if lastError.Valid {
 			item.LastError = lastError.String
 		}
+		item.RiskPause = decodeRiskPause(riskPause)
 		out = append(out, item)
 	}
 	return out, rows.Err()
@@ -210,8 +284,9 @@ func (s *Store) GetInstance(instanceID string) (StrategyInstance, error) {
 	var lastSignal sql.NullTime
 	var lastStarted sql.NullTime
 	var lastError sql.NullString
-	err := s.db.QueryRow(`SELECT instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,updated_at,created_at FROM strategy_instances WHERE instance_id=?`, instanceID).
-		Scan(&item.InstanceID, &item.StrategyID, &item.DisplayName, &item.Mode, &item.Status, &item.AccountID, &symbolsRaw, &item.Timeframe, &paramsRaw, &lastSignal, &lastStarted, &item.LastTargetPosition, &lastError, &item.UpdatedAt, &item.CreatedAt)
+	var riskPause sql.NullString
+	err := s.db.QueryRow(`SELECT instance_id,strategy_id,display_name,mode,status,account_id,symbols_json,timeframe,params_json,last_signal_at,last_started_at,last_target_position,last_error,definition_version,code_hash,execution_mode,replay_session,risk_pause_json,updated_at,created_at FROM strategy_instances WHERE instance_id=?`, instanceID).
+		Scan(&item.InstanceID, &item.StrategyID, &item.DisplayName, &item.Mode, &item.Status, &item.AccountID, &symbolsRaw, &item.Timeframe, &paramsRaw, &lastSignal, &lastStarted, &item.LastTargetPosition, &lastError, &item.DefinitionVersion, &item.CodeHash, &item.ExecutionMode, &item.ReplaySession, &riskPause, &item.UpdatedAt, &item.CreatedAt)
 	if err != nil {
 		return item, err
 	}
@@ -228,9 +303,36 @@ func (s *Store) GetInstance(instanceID string) (StrategyInstance, error) {
 	if lastError.Valid {
 		item.LastError = lastError.String
 	}
+	item.RiskPause = decodeRiskPause(riskPause)
 	return item, nil
 }
 
+// SaveInstanceRiskPause 单独更新实例的风险暂停状态，breach 为 nil 表示已解除；
+// SaveInstance 不写这一列，避免持有旧实例副本的保存覆盖暂停状态。
+func (s *Store) SaveInstanceRiskPause(instanceID string, breach *RiskBreach) error {
+	var raw any
+	if breach != nil {
+		data, err := json.Marshal(breach)
+		if err != nil {
+			return err
+		}
+		raw = string(data)
+	}
+	_, err := s.db.Exec(`UPDATE strategy_instances SET risk_pause_json=? WHERE instance_id=?`, raw, instanceID)
+	return err
+}
+
+func decodeRiskPause(raw sql.NullString) *RiskBreach {
+	if !raw.Valid || strings.TrimSpace(raw.String) == "" || raw.String == "null" {
+		return nil
+	}
+	var breach RiskBreach
+	if err := json.Unmarshal([]byte(raw.String), &breach); err != nil {
+		return nil
+	}
+	return &breach
+}
+
 func (s *Store) AppendSignal(sig SignalRecord) (int64, error) {
 	metrics, err := json.Marshal(sig.Metrics)
 	if err != nil {
@@ -385,29 +487,209 @@ LIMIT ?`, args...)
 	return out, rows.Err()
 }
 
+// SaveLatencySpan 按 (追踪 ID, 实例) 写入或覆盖一条决策链路延迟 span；收到柜台回报时同一条记录会被更新。
+func (s *Store) SaveLatencySpan(span LatencySpan) error {
+	stages, err := json.Marshal(span.Stages)
+	if err != nil {
+		return err
+	}
+	segments, err := json.Marshal(span.SegmentsMS)
+	if err != nil {
+		return err
+	}
+	if span.UpdatedAt.IsZero() {
+		span.UpdatedAt = time.Now()
+	}
+	_, err = s.db.Exec(`
+INSERT INTO strategy_latency_spans(latency_trace_id,instance_id,strategy_id,symbol,mode,event_time,order_ref,stages_json,segments_json,total_ms,complete,updated_at)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+order_ref=VALUES(order_ref),
+stages_json=VALUES(stages_json),
+segments_json=VALUES(segments_json),
+total_ms=VALUES(total_ms),
+complete=VALUES(complete),
+updated_at=VALUES(updated_at)
+`, span.TraceID, span.InstanceID, span.StrategyID, span.Symbol, span.Mode, span.EventTime, span.OrderRef, string(stages), string(segments), span.TotalMS, span.Complete, span.UpdatedAt)
+	return err
+}
+
+// ListLatencySpans 按 bar 时间倒序返回延迟 span，instanceID 为空时返回全部实例。
+func (s *Store) ListLatencySpans(instanceID string, limit int) ([]LatencySpan, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	where := []string{"1=1"}
+	args := make([]any, 0, 2)
+	if instanceID != "" {
+		where = append(where, "instance_id=?")
+		args = append(args, instanceID)
+	}
+	args = append(args, limit)
+	rows, err := s.db.Query(`
+SELECT latency_trace_id,instance_id,strategy_id,symbol,mode,event_time,order_ref,stages_json,segments_json,total_ms,complete,updated_at
+FROM strategy_latency_spans
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY event_time DESC, updated_at DESC
+LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []LatencySpan
+	for rows.Next() {
+		var span LatencySpan
+		var stagesRaw, segmentsRaw string
+		if err := rows.Scan(&span.TraceID, &span.InstanceID, &span.StrategyID, &span.Symbol, &span.Mode, &span.EventTime, &span.OrderRef, &stagesRaw, &segmentsRaw, &span.TotalMS, &span.Complete, &span.UpdatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(stagesRaw), &span.Stages)
+		_ = json.Unmarshal([]byte(segmentsRaw), &span.SegmentsMS)
+		out = append(out, span)
+	}
+	return out, rows.Err()
+}
+
+// SaveApproval 写入或更新一条信号审批请求；创建后只有状态、处理人、批准参数和执行结果会变化。
+func (s *Store) SaveApproval(approval SignalApproval) error {
+	decision, err := json.Marshal(approval.Decision)
+	if err != nil {
+		return err
+	}
+	var result any
+	if approval.Result != nil {
+		body, err := json.Marshal(approval.Result)
+		if err != nil {
+			return err
+		}
+		result = string(body)
+	}
+	if approval.CreatedAt.IsZero() {
+		approval.CreatedAt = time.Now()
+	}
+	_, err = s.db.Exec(`
+INSERT INTO strategy_signal_approvals(approval_id,instance_id,strategy_id,symbol,timeframe,mode,signal_id,event_time,target_position,current_position,planned_delta,decision_json,latency_trace_id,status,expires_at,decided_at,decided_by,note,approved_volume,approved_price,result_json,created_at)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+status=VALUES(status),
+decided_at=VALUES(decided_at),
+decided_by=VALUES(decided_by),
+note=VALUES(note),
+approved_volume=VALUES(approved_volume),
+approved_price=VALUES(approved_price),
+result_json=VALUES(result_json)
+`, approval.ApprovalID, approval.InstanceID, approval.StrategyID, approval.Symbol, approval.Timeframe, approval.Mode, approval.SignalID, approval.EventTime, approval.TargetPosition, approval.CurrentPosition, approval.PlannedDelta, string(decision), approval.LatencyTraceID, approval.Status, approval.ExpiresAt, approval.DecidedAt, approval.DecidedBy, approval.Note, approval.ApprovedVolume, approval.ApprovedPrice, result, approval.CreatedAt)
+	return err
+}
+
+const approvalColumns = `approval_id,instance_id,strategy_id,symbol,timeframe,mode,signal_id,event_time,target_position,current_position,planned_delta,decision_json,latency_trace_id,status,expires_at,decided_at,decided_by,note,approved_volume,approved_price,result_json,created_at`
+
+func (s *Store) GetApproval(approvalID string) (SignalApproval, error) {
+	return scanApproval(s.db.QueryRow(`SELECT `+approvalColumns+` FROM strategy_signal_approvals WHERE approval_id=?`, approvalID))
+}
+
+// ListApprovals 按创建时间倒序返回审批请求，instanceID、status 为空时不过滤。
+func (s *Store) ListApprovals(instanceID string, status string, limit int) ([]SignalApproval, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	where := []string{"1=1"}
+	args := make([]any, 0, 3)
+	if instanceID != "" {
+		where = append(where, "instance_id=?")
+		args = append(args, instanceID)
+	}
+	if status != "" {
+		where = append(where, "status=?")
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	rows, err := s.db.Query(`SELECT `+approvalColumns+`
+FROM strategy_signal_approvals
+WHERE `+strings.Join(where, " AND ")+`
+ORDER BY created_at DESC
+LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SignalApproval
+	for rows.Next() {
+		item, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func scanApproval(row interface{ Scan(...any) error }) (SignalApproval, error) {
+	var item SignalApproval
+	var decisionRaw string
+	var decidedAt sql.NullTime
+	var note, resultRaw sql.NullString
+	if err := row.Scan(&item.ApprovalID, &item.InstanceID, &item.StrategyID, &item.Symbol, &item.Timeframe, &item.Mode, &item.SignalID, &item.EventTime, &item.TargetPosition, &item.CurrentPosition, &item.PlannedDelta, &decisionRaw, &item.LatencyTraceID, &item.Status, &item.ExpiresAt, &decidedAt, &item.DecidedBy, &note, &item.ApprovedVolume, &item.ApprovedPrice, &resultRaw, &item.CreatedAt); err != nil {
+		return item, err
+	}
+	_ = json.Unmarshal([]byte(decisionRaw), &item.Decision)
+	if decidedAt.Valid {
+		ts := decidedAt.Time
+		item.DecidedAt = &ts
+	}
+	item.Note = note.String
+	if resultRaw.Valid && resultRaw.String != "" {
+		_ = json.Unmarshal([]byte(resultRaw.String), &item.Result)
+	}
+	return item, nil
+}
+
+// SaveRun 写入或更新运行记录；版本号和代码摘要只在首次插入时写入，调用方未指定时取当前策略定义。
 func (s *Store) SaveRun(run StrategyRun) error {
 	summary, err := json.Marshal(run.Summary)
 	if err != nil {
 		return err
 	}
+	if run.DefinitionVersion == "" && run.CodeHash == "" {
+		run.DefinitionVersion, run.CodeHash = s.definitionPin(run.StrategyID)
+	}
 	_, err = s.db.Exec(`
-INSERT INTO strategy_runs(run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error)
-VALUES(?,?,?,?,?,?,?,?,?,?,?,?)
+INSERT INTO strategy_runs(run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error,definition_version,code_hash)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 ON DUPLICATE KEY UPDATE
 status=VALUES(status),
 output_path=VALUES(output_path),
 summary_json=VALUES(summary_json),
 finished_at=VALUES(finished_at),
 last_error=VALUES(last_error)
-`, run.RunID, run.InstanceID, run.StrategyID, run.RunType, run.Status, run.Symbol, run.Timeframe, run.OutputPath, string(summary), run.StartedAt, run.FinishedAt, run.LastError)
+`, run.RunID, run.InstanceID, run.StrategyID, run.RunType, run.Status, run.Symbol, run.Timeframe, run.OutputPath, string(summary), run.StartedAt, run.FinishedAt, run.LastError, run.DefinitionVersion, run.CodeHash)
 	return err
 }
 
 func (s *Store) ListRuns(limit int) ([]StrategyRun, error) {
-	rows, err := s.db.Query(`SELECT run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error FROM strategy_runs ORDER BY started_at DESC LIMIT ?`, limit)
+	rows, err := s.db.Query(`SELECT run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error,definition_version,code_hash FROM strategy_runs ORDER BY started_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRuns(rows)
+}
+
+// ListRunsByInstance 返回某个实例（或优化任务）名下的全部运行记录，按开始时间升序。
+func (s *Store) ListRunsByInstance(instanceID string) ([]StrategyRun, error) {
+	rows, err := s.db.Query(`SELECT run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error,definition_version,code_hash FROM strategy_runs WHERE instance_id=? ORDER BY started_at ASC`, instanceID)
 	if err != nil {
 		return nil, err
 	}
+	return scanRuns(rows)
+}
+
+func scanRuns(rows *sql.Rows) ([]StrategyRun, error) {
 	defer rows.Close()
 	var out []StrategyRun
 	for rows.Next() {
@@ -415,7 +697,7 @@ func (s *Store) ListRuns(limit int) ([]StrategyRun, error) {
 		var raw string
 		var finished sql.NullTime
 		var lastError sql.NullString
-		if err := rows.Scan(&run.RunID, &run.InstanceID, &run.StrategyID, &run.RunType, &run.Status, &run.Symbol, &run.Timeframe, &run.OutputPath, &raw, &run.StartedAt, &finished, &lastError); err != nil {
+		if err := rows.Scan(&run.RunID, &run.InstanceID, &run.StrategyID, &run.RunType, &run.Status, &run.Symbol, &run.Timeframe, &run.OutputPath, &raw, &run.StartedAt, &finished, &lastError, &run.DefinitionVersion, &run.CodeHash); err != nil {
 			return nil, err
 		}
 		_ = json.Unmarshal([]byte(raw), &run.Summary)
@@ -436,8 +718,8 @@ func (s *Store) GetRun(runID string) (StrategyRun, error) {
 	var raw string
 	var finished sql.NullTime
 	var lastError sql.NullString
-	err := s.db.QueryRow(`SELECT run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error FROM strategy_runs WHERE run_id=?`, runID).
-		Scan(&run.RunID, &run.InstanceID, &run.StrategyID, &run.RunType, &run.Status, &run.Symbol, &run.Timeframe, &run.OutputPath, &raw, &run.StartedAt, &finished, &lastError)
+	err := s.db.QueryRow(`SELECT run_id,instance_id,strategy_id,run_type,status,symbol,timeframe,output_path,summary_json,started_at,finished_at,last_error,definition_version,code_hash FROM strategy_runs WHERE run_id=?`, runID).
+		Scan(&run.RunID, &run.InstanceID, &run.StrategyID, &run.RunType, &run.Status, &run.Symbol, &run.Timeframe, &run.OutputPath, &raw, &run.StartedAt, &finished, &lastError, &run.DefinitionVersion, &run.CodeHash)
 	if err != nil {
 		return run, err
 	}
@@ -452,6 +734,49 @@ func (s *Store) GetRun(runID string) (StrategyRun, error) {
 	return run, nil
 }
 
+// SaveCheckpoint 覆盖保存实例最近一次状态检查点。
+func (s *Store) SaveCheckpoint(cp StrategyCheckpoint) error {
+	symbolTimes, err := json.Marshal(cp.SymbolBarTimes)
+	if err != nil {
+		return err
+	}
+	if cp.UpdatedAt.IsZero() {
+		cp.UpdatedAt = time.Now()
+	}
+	_, err = s.db.Exec(`
+INSERT INTO strategy_checkpoints(instance_id,strategy_id,mode,fingerprint,last_bar_time,symbol_bar_times_json,state_blob,updated_at)
+VALUES(?,?,?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+strategy_id=VALUES(strategy_id),
+mode=VALUES(mode),
+fingerprint=VALUES(fingerprint),
+last_bar_time=VALUES(last_bar_time),
+symbol_bar_times_json=VALUES(symbol_bar_times_json),
+state_blob=VALUES(state_blob),
+updated_at=VALUES(updated_at)
+`, cp.InstanceID, cp.StrategyID, cp.Mode, cp.Fingerprint, cp.LastBarTime, string(symbolTimes), cp.State, cp.UpdatedAt)
+	return err
+}
+
+// GetCheckpoint 读取实例的状态检查点，不存在时返回 sql.ErrNoRows。
+func (s *Store) GetCheckpoint(instanceID string) (StrategyCheckpoint, error) {
+	var cp StrategyCheckpoint
+	var symbolTimes string
+	err := s.db.QueryRow(`SELECT instance_id,strategy_id,mode,fingerprint,last_bar_time,symbol_bar_times_json,state_blob,updated_at FROM strategy_checkpoints WHERE instance_id=?`, instanceID).
+		Scan(&cp.InstanceID, &cp.StrategyID, &cp.Mode, &cp.Fingerprint, &cp.LastBarTime, &symbolTimes, &cp.State, &cp.UpdatedAt)
+	if err != nil {
+		return cp, err
+	}
+	_ = json.Unmarshal([]byte(symbolTimes), &cp.SymbolBarTimes)
+	return cp, nil
+}
+
+// DeleteCheckpoint 删除实例的状态检查点；实例被手动启停后旧状态不能再用于恢复。
+func (s *Store) DeleteCheckpoint(instanceID string) error {
+	_, err := s.db.Exec(`DELETE FROM strategy_checkpoints WHERE instance_id=?`, instanceID)
+	return err
+}
+
 func (s *Store) AppendOrderAudit(rec OrderAuditRecord) (int64, error) {
 	raw, err := json.Marshal(rec.Audit)
 	if err != nil {
@@ -0,0 +1,361 @@
+// stream.go 负责 Go 与 Python 策略服务之间的 WebSocket 流式通道。
+// 行情事件带递增序号推给 Python，Python 先回 ack 再异步回推决策（含 trace 与特征载荷）；
+// 未收到结果的事件在重连后按序重放，由 Python 按 session_id+seq 去重；ping/pong 心跳替代健康检查轮询。
+// 流不可用时调用方回落到 HTTP push/poll。
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	// defaultStreamWindow 是未收到结果的在途事件上限，超过后调用方阻塞等待，形成背压。
+	defaultStreamWindow = 256
+	// streamHeartbeatMisses 是连续缺失多少个心跳周期后判定连接失效。
+	streamHeartbeatMisses = 3
+	streamMaxBackoff      = 5 * time.Second
+)
+
+// errStreamUnavailable 表示流通道当前未连接，调用方应回落到 HTTP。
+var errStreamUnavailable = errors.New("strategy stream unavailable")
+
+// errStreamClosed 表示流通道被主动关闭；事件可能已发出，不能再回落到 HTTP 重发。
+var errStreamClosed = errors.New("strategy stream closed")
+
+// errStreamAckTimeout 表示等待超时时连 ack 都没收到：事件可能没送达 Python，重连后仍会重放。
+var errStreamAckTimeout = errors.New("strategy stream event not acknowledged")
+
+// errStreamResultTimeout 表示 Python 已 ack 但结果没有按时回推：事件已在 Python 侧执行或排队。
+var errStreamResultTimeout = errors.New("strategy stream result not received after ack")
+
+// streamFrame 是流通道上双向传输的 JSON 帧。
+type streamFrame struct {
+	Type      string          `json:"type"`
+	SessionID string          `json:"session_id,omitempty"`
+	Seq       uint64          `json:"seq,omitempty"`
+	Method    string          `json:"method,omitempty"`
+	Request   json.RawMessage `json:"request,omitempty"`
+	Status    string          `json:"status,omitempty"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	TS        int64           `json:"ts,omitempty"`
+}
+
+type streamResult struct {
+	decision SignalDecision
+	err      error
+}
+
+// streamCall 是一个尚未收到结果的在途事件；重连后按 seq 顺序重放。
+type streamCall struct {
+	frame streamFrame
+	// acked 记录 Python 是否已确认收到事件，等待超时时据此区分丢 ack 和丢结果。
+	acked bool
+	done  chan streamResult
+}
+
+// StrategyStream 维护到 Python 策略服务的单条 WebSocket 长连接。
+type StrategyStream struct {
+	url       string
+	sessionID string
+	heartbeat time.Duration
+	window    chan struct{}
+	dialer    *websocket.Dialer
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	connected bool
+	nextSeq   uint64
+	pending   map[uint64]*streamCall
+	lastPong  time.Time
+
+	writeMu sync.Mutex
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// NewStrategyStream 创建流通道并在后台持续连接；heartbeat 为心跳周期。
+func NewStrategyStream(addr string, heartbeat time.Duration) *StrategyStream {
+	if heartbeat <= 0 {
+		heartbeat = 2 * time.Second
+	}
+	s := &StrategyStream{
+		url:       strategyStreamURL(addr),
+		sessionID: mustRunID("stream"),
+		heartbeat: heartbeat,
+		window:    make(chan struct{}, defaultStreamWindow),
+		dialer:    &websocket.Dialer{HandshakeTimeout: heartbeat},
+		pending:   make(map[uint64]*streamCall),
+		closed:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// strategyStreamURL 把策略 HTTP 地址转换为 ws://host/runtime/stream。
+func strategyStreamURL(addr string) string {
+	base := normalizeStrategyHTTPBaseURL(addr)
+	u, err := url.Parse(base)
+	if err != nil {
+		return "ws://" + strings.TrimPrefix(base, "http://") + "/runtime/stream"
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/runtime/stream"
+	return u.String()
+}
+
+// Close 关闭连接并让所有在途调用失败。
+func (s *StrategyStream) Close() error {
+	s.once.Do(func() {
+		close(s.closed)
+		s.mu.Lock()
+		conn := s.conn
+		s.conn = nil
+		s.connected = false
+		pending := s.pending
+		s.pending = make(map[uint64]*streamCall)
+		s.mu.Unlock()
+		if conn != nil {
+			_ = conn.Close()
+		}
+		for _, call := range pending {
+			s.finish(call, streamResult{err: errStreamClosed})
+		}
+	})
+	return nil
+}
+
+// Healthy 判断连接已建立且最近心跳未超时。
+func (s *StrategyStream) Healthy() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected && time.Since(s.lastPong) <= streamHeartbeatMisses*s.heartbeat
+}
+
+// LastHeartbeat 返回最近一次收到 pong 的时间。
+func (s *StrategyStream) LastHeartbeat() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastPong
+}
+
+// Decide 通过流通道发送一个行情事件并等待决策。未连接时立即返回 errStreamUnavailable；
+// 在途事件达到窗口上限时阻塞直到有结果返回或 ctx 结束。
+func (s *StrategyStream) Decide(ctx context.Context, method string, req DecisionRequest) (SignalDecision, error) {
+	if !s.Healthy() {
+		return SignalDecision{}, errStreamUnavailable
+	}
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return SignalDecision{}, err
+	}
+	select {
+	case s.window <- struct{}{}:
+	case <-ctx.Done():
+		return SignalDecision{}, fmt.Errorf("strategy stream backpressure: %w", ctx.Err())
+	case <-s.closed:
+		return SignalDecision{}, errStreamUnavailable
+	}
+	call := &streamCall{done: make(chan streamResult, 1)}
+	s.mu.Lock()
+	s.nextSeq++
+	call.frame = streamFrame{Type: "event", Seq: s.nextSeq, Method: method, Request: raw}
+	s.pending[call.frame.Seq] = call
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		// 写失败不在这里处理：读循环会发现断线并重连，重连后该事件随 pending 一起重放。
+		_ = s.write(conn, call.frame)
+	}
+	select {
+	case res := <-call.done:
+		return res.decision, res.err
+	case <-ctx.Done():
+		s.mu.Lock()
+		if _, ok := s.pending[call.frame.Seq]; ok {
+			delete(s.pending, call.frame.Seq)
+			<-s.window
+		}
+		acked := call.acked
+		s.mu.Unlock()
+		lost := errStreamAckTimeout
+		if acked {
+			lost = errStreamResultTimeout
+		}
+		logger.Warn("strategy stream decision timed out", "method", method, "seq", call.frame.Seq, "acked", acked, "error", ctx.Err())
+		return SignalDecision{}, fmt.Errorf("%w (seq=%d): %w", lost, call.frame.Seq, ctx.Err())
+	}
+}
+
+// finish 投递结果并释放窗口；调用前 call 必须已从 pending 移除。
+func (s *StrategyStream) finish(call *streamCall, res streamResult) {
+	<-s.window
+	call.done <- res
+}
+
+func (s *StrategyStream) write(conn *websocket.Conn, frame streamFrame) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(s.heartbeat))
+	return conn.WriteJSON(frame)
+}
+
+func (s *StrategyStream) run() {
+	backoff := 200 * time.Millisecond
+	for {
+		select {
+		case <-s.closed:
+			return
+		default:
+		}
+		conn, _, err := s.dialer.Dial(s.url, nil)
+		if err != nil {
+			select {
+			case <-s.closed:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, streamMaxBackoff)
+			continue
+		}
+		backoff = 200 * time.Millisecond
+		if err := s.serve(conn); err != nil {
+			logger.Warn("strategy stream disconnected; events fall back to http until reconnect", "url", s.url, "error", err)
+		}
+	}
+}
+
+// serve 完成握手、重放未决事件，然后运行读循环与心跳直到连接断开。
+func (s *StrategyStream) serve(conn *websocket.Conn) error {
+	defer conn.Close()
+	if err := s.write(conn, streamFrame{Type: "hello", SessionID: s.sessionID}); err != nil {
+		return err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(streamHeartbeatMisses * s.heartbeat))
+	var welcome streamFrame
+	if err := conn.ReadJSON(&welcome); err != nil {
+		return err
+	}
+	if welcome.Type != "welcome" {
+		return fmt.Errorf("unexpected stream handshake frame %q", welcome.Type)
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.connected = true
+	s.lastPong = time.Now()
+	replay := make([]streamFrame, 0, len(s.pending))
+	for _, call := range s.pending {
+		replay = append(replay, call.frame)
+	}
+	s.mu.Unlock()
+	sort.Slice(replay, func(i, j int) bool { return replay[i].Seq < replay[j].Seq })
+	for _, frame := range replay {
+		if err := s.write(conn, frame); err != nil {
+			s.markDisconnected(conn)
+			return err
+		}
+	}
+	if len(replay) > 0 {
+		logger.Info("strategy stream replayed unresolved events", "count", len(replay), "first_seq", replay[0].Seq)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.heartbeatLoop(conn, stop)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(streamHeartbeatMisses * s.heartbeat))
+		var frame streamFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			s.markDisconnected(conn)
+			return err
+		}
+		s.handleFrame(frame)
+	}
+}
+
+func (s *StrategyStream) heartbeatLoop(conn *websocket.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.closed:
+			return
+		case now := <-ticker.C:
+			if err := s.write(conn, streamFrame{Type: "ping", TS: now.UnixMilli()}); err != nil {
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+func (s *StrategyStream) markDisconnected(conn *websocket.Conn) {
+	s.mu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+		s.connected = false
+	}
+	s.mu.Unlock()
+}
+
+func (s *StrategyStream) handleFrame(frame streamFrame) {
+	switch frame.Type {
+	case "pong":
+		s.mu.Lock()
+		s.lastPong = time.Now()
+		s.mu.Unlock()
+	case "ack":
+		s.mu.Lock()
+		if call, ok := s.pending[frame.Seq]; ok {
+			call.acked = true
+		}
+		s.mu.Unlock()
+	case "result":
+		s.mu.Lock()
+		call, ok := s.pending[frame.Seq]
+		delete(s.pending, frame.Seq)
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		s.finish(call, decodeStreamResult(frame))
+	}
+}
+
+func decodeStreamResult(frame streamFrame) streamResult {
+	switch strings.ToLower(strings.TrimSpace(frame.Status)) {
+	case "done":
+		var decision SignalDecision
+		if len(frame.Result) > 0 && string(frame.Result) != "null" {
+			if err := json.Unmarshal(frame.Result, &decision); err != nil {
+				return streamResult{err: fmt.Errorf("decode strategy stream result failed: %w", err)}
+			}
+		}
+		return streamResult{decision: decision}
+	case "error":
+		return streamResult{err: fmt.Errorf("strategy stream task failed: %s", strings.TrimSpace(frame.Error))}
+	default:
+		return streamResult{err: fmt.Errorf("strategy stream returned unknown status %q", frame.Status)}
+	}
+}
@@ -0,0 +1,181 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeStreamServer 模拟 Python 流通道：首个连接收到事件后直接断开，之后的连接正常回结果。
+// reply 为 "ack" 时只回 ack 不回结果，为 "none" 时什么都不回，用于模拟超时。
+type fakeStreamServer struct {
+	mu       sync.Mutex
+	conns    int
+	sessions []string
+	events   []uint64
+	reply    string
+}
+
+func (f *fakeStreamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/runtime/stream" {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	f.mu.Lock()
+	f.conns++
+	dropFirstEvent := f.conns == 1 && f.reply == ""
+	reply := f.reply
+	f.mu.Unlock()
+	for {
+		var frame streamFrame
+		if err := conn.ReadJSON(&frame); err != nil {
+			return
+		}
+		switch frame.Type {
+		case "hello":
+			f.mu.Lock()
+			f.sessions = append(f.sessions, frame.SessionID)
+			f.mu.Unlock()
+			_ = conn.WriteJSON(streamFrame{Type: "welcome", SessionID: frame.SessionID})
+		case "ping":
+			_ = conn.WriteJSON(streamFrame{Type: "pong", TS: frame.TS})
+		case "event":
+			f.mu.Lock()
+			f.events = append(f.events, frame.Seq)
+			f.mu.Unlock()
+			if dropFirstEvent {
+				return
+			}
+			if reply == "ack" {
+				_ = conn.WriteJSON(streamFrame{Type: "ack", Seq: frame.Seq})
+			}
+			if reply != "" {
+				continue
+			}
+			var req DecisionRequest
+			_ = json.Unmarshal(frame.Request, &req)
+			result, _ := json.Marshal(SignalDecision{InstanceID: req.Instance.InstanceID, TargetPosition: 1, Reason: frame.Method})
+			_ = conn.WriteJSON(streamFrame{Type: "ack", Seq: frame.Seq})
+			_ = conn.WriteJSON(streamFrame{Type: "result", Seq: frame.Seq, Status: "done", Result: result})
+		}
+	}
+}
+
+func waitStreamHealthy(t *testing.T, stream *StrategyStream) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !stream.Healthy() {
+		if time.Now().After(deadline) {
+			t.Fatal("stream did not become healthy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStrategyStreamReplaysUnresolvedEventsAfterReconnect(t *testing.T) {
+	fake := &fakeStreamServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	stream := NewStrategyStream(srv.URL, 100*time.Millisecond)
+	defer stream.Close()
+	waitStreamHealthy(t, stream)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	decision, err := stream.Decide(ctx, "OnBar", DecisionRequest{Instance: StrategyInstance{InstanceID: "inst-1"}})
+	if err != nil {
+		t.Fatalf("Decide() error = %v", err)
+	}
+	if decision.InstanceID != "inst-1" || decision.TargetPosition != 1 || decision.Reason != "OnBar" {
+		t.Fatalf("decision = %+v", decision)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.conns < 2 || len(fake.events) != 2 || fake.events[0] != fake.events[1] {
+		t.Fatalf("conns=%d events=%v, want one event replayed with same seq after reconnect", fake.conns, fake.events)
+	}
+	if fake.sessions[0] != fake.sessions[1] {
+		t.Fatalf("sessions = %v, want same session id across reconnect", fake.sessions)
+	}
+}
+
+func TestStrategyServiceClientFallsBackToHTTPWithoutStream(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/runtime/on_tick", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(asyncPushResponse{OK: true, PushID: "p1", Status: "queued"})
+	})
+	mux.HandleFunc("/runtime/result", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"push_id": "p1", "status": "done", "result": map[string]any{"target_position": -1}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := NewStrategyServiceClient(srv.URL, nil)
+	client.EnableStream(50 * time.Millisecond)
+	defer client.Close()
+	if _, err := client.Stream().Decide(context.Background(), "OnTick", DecisionRequest{}); !errors.Is(err, errStreamUnavailable) {
+		t.Fatalf("Decide() without stream endpoint error = %v, want unavailable", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	decision, err := client.OnTick(ctx, DecisionRequest{})
+	if err != nil {
+		t.Fatalf("OnTick() error = %v", err)
+	}
+	if decision.TargetPosition != -1 {
+		t.Fatalf("decision = %+v, want http fallback result", decision)
+	}
+}
+
+func TestStrategyStreamURL(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]string{
+		"127.0.0.1:50051":        "ws://127.0.0.1:50051/runtime/stream",
+		"https://strategy.local": "wss://strategy.local/runtime/stream",
+	}
+	for addr, want := range cases {
+		if got := strategyStreamURL(addr); got != want {
+			t.Fatalf("strategyStreamURL(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestStrategyStreamTimeoutDistinguishesLostAckFromLostResult(t *testing.T) {
+	for _, tc := range []struct {
+		reply string
+		want  error
+	}{
+		{reply: "none", want: errStreamAckTimeout},
+		{reply: "ack", want: errStreamResultTimeout},
+	} {
+		fake := &fakeStreamServer{reply: tc.reply}
+		srv := httptest.NewServer(fake)
+		stream := NewStrategyStream(srv.URL, 100*time.Millisecond)
+		waitStreamHealthy(t, stream)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		_, err := stream.Decide(ctx, "OnBar", DecisionRequest{})
+		cancel()
+		if !errors.Is(err, tc.want) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("reply=%s: Decide() error = %v, want %v wrapping deadline", tc.reply, err, tc.want)
+		}
+		_ = stream.Close()
+		srv.Close()
+	}
+}
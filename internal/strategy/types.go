@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"ctp-future-kline/internal/order"
 )
 
 const (
@@ -13,10 +15,14 @@ const (
 	InstanceStatusRunning = "running"
 	InstanceStatusError   = "error"
 
-	RunTypeRealtime     = "realtime"
-	RunTypeReplay       = "replay"
-	RunTypeBacktest     = "backtest"
-	RunTypeReplayReport = "replay_report"
+	RunTypeRealtime      = "realtime"
+	RunTypeReplay        = "replay"
+	RunTypeBacktest      = "backtest"
+	RunTypeReplayReport  = "replay_report"
+	RunTypeOptimize      = "optimize"
+	RunTypeOptimizeTrial = "optimize_trial"
+	RunTypeMonteCarlo    = "monte_carlo"
+	RunTypeReplayCompare = "replay_compare"
 
 	OrderStatusSimulated = "simulated_submitted"
 	OrderStatusBlocked   = "blocked"
@@ -24,6 +30,14 @@ const (
 	OrderStatusNoop      = "noop"
 	RiskStatusAllowed    = "allowed"
 	RiskStatusBlocked    = "blocked"
+
+	// OrderStatusPendingApproval 表示确认模式下信号已转成待审批请求，尚未下单。
+	OrderStatusPendingApproval = "pending_approval"
+
+	// ExecutionModeAuto 是默认执行方式：信号经风控后直接下单。
+	ExecutionModeAuto = "auto"
+	// ExecutionModeConfirm 是人工确认方式：实盘和纸面信号先生成待审批请求，批准后才下单。
+	ExecutionModeConfirm = "confirm"
 )
 
 type ManagerStatus struct {
@@ -63,6 +77,10 @@ type ManagerStatus struct {
 	BacktestRunCount int64 `json:"backtest_run_count"`
 	// AutoExecutionPaused 表示策略信号到订单执行这一步是否被人工暂停。
 	AutoExecutionPaused bool `json:"auto_execution_paused"`
+	// Transport 是当前行情事件实际使用的通道：stream 或 http。
+	Transport string `json:"transport"`
+	// Latency 是实盘决策链路各区间最近耗时的分位数，键为区间名。
+	Latency map[string]LatencyPercentiles `json:"latency,omitempty"`
 }
 
 type StrategyDefinition struct {
@@ -71,6 +89,7 @@ type StrategyDefinition struct {
 	Kind          string         `json:"kind,omitempty"`
 	EntryScript   string         `json:"entry_script"`
 	Version       string         `json:"version"`
+	CodeHash      string         `json:"code_hash,omitempty"`
 	DefaultParams map[string]any `json:"default_params"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 }
@@ -82,6 +101,7 @@ func (d *StrategyDefinition) UnmarshalJSON(data []byte) error {
 		Kind          string          `json:"kind"`
 		EntryScript   string          `json:"entry_script"`
 		Version       string          `json:"version"`
+		CodeHash      string          `json:"code_hash"`
 		DefaultParams map[string]any  `json:"default_params"`
 		UpdatedAt     json.RawMessage `json:"updated_at"`
 	}
@@ -96,6 +116,7 @@ func (d *StrategyDefinition) UnmarshalJSON(data []byte) error {
 	d.Kind = raw.Kind
 	d.EntryScript = raw.EntryScript
 	d.Version = raw.Version
+	d.CodeHash = raw.CodeHash
 	d.DefaultParams = raw.DefaultParams
 
 	if len(raw.UpdatedAt) == 0 || string(raw.UpdatedAt) == "null" {
@@ -147,8 +168,15 @@ type StrategyInstance struct {
 	LastStartedAt      *time.Time     `json:"last_started_at,omitempty"`
 	LastTargetPosition float64        `json:"last_target_position"`
 	LastError          string         `json:"last_error,omitempty"`
-	UpdatedAt          time.Time      `json:"updated_at"`
-	CreatedAt          time.Time      `json:"created_at"`
+	DefinitionVersion  string         `json:"definition_version,omitempty"`
+	CodeHash           string         `json:"code_hash,omitempty"`
+	ReloadPending      bool           `json:"reload_pending,omitempty"`
+	ExecutionMode      string         `json:"execution_mode,omitempty"`
+	ReplaySession      string         `json:"replay_session,omitempty"`
+	// RiskPause 是实例因风险预算暂停的原因，只由 SaveInstanceRiskPause 写入，重启后据此恢复暂停。
+	RiskPause *RiskBreach `json:"risk_pause,omitempty"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	CreatedAt time.Time   `json:"created_at"`
 }
 
 type SignalRecord struct {
@@ -209,6 +237,73 @@ type StrategyRun struct {
 	StartedAt  time.Time      `json:"started_at"`
 	FinishedAt *time.Time     `json:"finished_at,omitempty"`
 	LastError  string         `json:"last_error,omitempty"`
+
+	// DefinitionVersion 和 CodeHash 在运行记录首次保存时固定，之后策略升级不会改写。
+	DefinitionVersion string `json:"definition_version,omitempty"`
+	CodeHash          string `json:"code_hash,omitempty"`
+}
+
+// MonteCarloRequest 是对一次运行的逐笔交易做蒙特卡洛稳健性分析的请求。
+type MonteCarloRequest struct {
+	// Methods 是重抽样方法 bootstrap / block_bootstrap，为空时两种都跑。
+	Methods []string `json:"methods,omitempty"`
+	// Iterations 是每种方法的模拟路径数，默认 1000。
+	Iterations int `json:"iterations,omitempty"`
+	// BlockSize 是块重抽样的块长，默认取交易数的立方根。
+	BlockSize int `json:"block_size,omitempty"`
+	// SlippagePoints 是每次成交的最大不利滑点（价格点），0 表示不扰动成交价。
+	SlippagePoints float64 `json:"slippage_points,omitempty"`
+	// Confidence 是置信区间水平，默认 0.95。
+	Confidence float64 `json:"confidence,omitempty"`
+	// Seed 是随机数种子，为 0 时按当前时间生成并写回运行记录，便于复现。
+	Seed int64 `json:"seed,omitempty"`
+}
+
+// StrategyDefinitionVersion 是同步时见到的一个策略定义版本，按 (策略, 版本号, 代码摘要) 去重。
+type StrategyDefinitionVersion struct {
+	StrategyID    string         `json:"strategy_id"`
+	Version       string         `json:"version"`
+	CodeHash      string         `json:"code_hash"`
+	DisplayName   string         `json:"display_name"`
+	EntryScript   string         `json:"entry_script"`
+	DefaultParams map[string]any `json:"default_params"`
+	FirstSeenAt   time.Time      `json:"first_seen_at"`
+}
+
+// ParamSchemaChange 是两个版本之间一个参数的变化；嵌套参数用点号展开成 Key。
+type ParamSchemaChange struct {
+	Key      string `json:"key"`
+	From     any    `json:"from,omitempty"`
+	To       any    `json:"to,omitempty"`
+	FromType string `json:"from_type,omitempty"`
+	ToType   string `json:"to_type,omitempty"`
+}
+
+// DefinitionDiff 是两个策略定义版本之间的参数结构差异。
+type DefinitionDiff struct {
+	StrategyID  string                    `json:"strategy_id"`
+	From        StrategyDefinitionVersion `json:"from"`
+	To          StrategyDefinitionVersion `json:"to"`
+	CodeChanged bool                      `json:"code_changed"`
+	Added       []ParamSchemaChange       `json:"added"`
+	Removed     []ParamSchemaChange       `json:"removed"`
+	Changed     []ParamSchemaChange       `json:"changed"`
+}
+
+// StrategyCheckpoint 是实例运行状态的检查点：State 由运行时生成，Go 侧只透传不解析。
+type StrategyCheckpoint struct {
+	InstanceID string `json:"instance_id"`
+	StrategyID string `json:"strategy_id"`
+	Mode       string `json:"mode"`
+	// Fingerprint 是策略、合约、周期和参数的摘要，配置变化后检查点作废。
+	Fingerprint string `json:"fingerprint"`
+	// LastBarTime 是检查点包含的最后一根 K 线时间。
+	LastBarTime time.Time `json:"last_bar_time"`
+	// SymbolBarTimes 是各合约已处理的最后一根 K 线时间，恢复时按合约补放之后的 K 线。
+	SymbolBarTimes map[string]time.Time `json:"symbol_bar_times,omitempty"`
+	// State 是运行时导出的不透明状态。
+	State     []byte    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type OrderAuditRecord struct {
@@ -248,17 +343,84 @@ type StrategyOrderRequest struct {
 	Reason          string           `json:"reason"`
 	Confidence      float64          `json:"confidence"`
 	Metrics         map[string]any   `json:"metrics"`
+	// Execution 是策略要求的算法执行方式，为空时直接下单。
+	Execution *ExecutionStyle `json:"execution,omitempty"`
+	// LatencyTraceID 是触发本次下单的实盘 bar 延迟追踪 ID，非 bar 驱动时为空。
+	LatencyTraceID string `json:"latency_trace_id,omitempty"`
+	// LimitPrice 是人工审批时指定的委托价，<=0 时由执行器按行情定价。
+	LimitPrice float64 `json:"limit_price,omitempty"`
 }
 
+// ExecutionStyle 是策略信号可携带的执行方式，与交易侧算法母单共用 order.ExecutionStyle。
+type ExecutionStyle = order.ExecutionStyle
+
 type StrategyOrderResult struct {
 	OrderID string         `json:"order_id,omitempty"`
 	Status  string         `json:"status"`
 	Reason  string         `json:"reason,omitempty"`
 	Details map[string]any `json:"details,omitempty"`
+	// OrderRef 是直接下单时柜台的本地报单引用，用于把报单和成交回报关联回延迟追踪；算法母单为空。
+	OrderRef string `json:"order_ref,omitempty"`
+	// AccountID 是实际下单的交易账户，报单引用只在账户内唯一。
+	AccountID string `json:"account_id,omitempty"`
+	// LegOrderRefs 是 auto_close 拆成多笔时各笔的报单引用，第一笔与 OrderRef 相同；延迟追踪会全部绑定。
+	LegOrderRefs []string `json:"leg_order_refs,omitempty"`
+}
+
+// OrderUpdateEvent 是柜台报单回报（OnRtnOrder）推给策略管理器的摘要。
+type OrderUpdateEvent struct {
+	// InstanceID 是委托所属策略实例。
+	InstanceID string `json:"instance_id"`
+	// AccountID 是委托所属交易账户。
+	AccountID string `json:"account_id"`
+	// OrderRef 是本地报单引用。
+	OrderRef string `json:"order_ref"`
+	// OrderStatus 是回报中的委托状态。
+	OrderStatus string `json:"order_status"`
+	// ReceivedAt 是系统收到这条回报的时间。
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// LatencySpan 是一根实盘 bar 触发的一次决策在各链路阶段的时间戳和区间耗时。
+type LatencySpan struct {
+	// TraceID 是 bar 分发时分配的延迟追踪 ID，同一根 bar 触发的多个实例共用。
+	TraceID string `json:"latency_trace_id"`
+	// InstanceID 是做出决策的策略实例。
+	InstanceID string `json:"instance_id"`
+	// StrategyID 是实例对应的策略。
+	StrategyID string `json:"strategy_id"`
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// Mode 是运行模式。
+	Mode string `json:"mode"`
+	// EventTime 是触发决策的 bar 时间。
+	EventTime time.Time `json:"event_time"`
+	// OrderRef 是决策下单后的本地报单引用，没有下单时为空。
+	OrderRef string `json:"order_ref,omitempty"`
+	// Stages 是各阶段的发生时间，键为 LatencyStage* 常量。
+	Stages map[string]time.Time `json:"stages"`
+	// SegmentsMS 是相邻阶段之间的耗时，单位毫秒。
+	SegmentsMS map[string]float64 `json:"segments_ms"`
+	// TotalMS 是从最早阶段到最晚阶段的总耗时，单位毫秒。
+	TotalMS float64 `json:"total_ms"`
+	// Complete 表示链路已经结束：没有下单、下单失败或已收到成交回报。
+	Complete bool `json:"complete"`
+	// UpdatedAt 是最近一次打点时间。
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LatencyPercentiles 是某个链路区间最近若干次耗时的分位数，单位毫秒。
+type LatencyPercentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
 }
 
+// StrategyOrderExecutor 是策略下单的外部执行器；accountID 为实例绑定的交易账户，空值表示当前模式的主账户。
 type StrategyOrderExecutor interface {
-	CurrentPosition(symbol string) (float64, error)
+	CurrentPosition(accountID string, symbol string) (float64, error)
 	SubmitStrategyOrder(context.Context, StrategyOrderRequest) (StrategyOrderResult, error)
 }
 
@@ -271,13 +433,32 @@ type OrdersStatus struct {
 	Positions map[string]float64 `json:"positions"`
 	// LastAuditAt 是最近一次订单审计时间。
 	LastAuditAt *time.Time `json:"last_audit_at,omitempty"`
+	// PausedInstances 是因风险预算突破而暂停开仓的实例及其突破原因。
+	PausedInstances map[string]RiskBreach `json:"paused_instances,omitempty"`
 	// UpdatedAt 是状态更新时间。
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+type StrategySubPosition struct {
+	// Mode 是持仓所属运行模式，例如 realtime、replay。
+	Mode string `json:"mode"`
+	// AccountID 是持仓所属交易账户。
+	AccountID string `json:"account_id"`
+	// Symbol 是合约代码（已小写归一）。
+	Symbol string `json:"symbol"`
+	// Timeframe 是实例运行周期。
+	Timeframe string `json:"timeframe"`
+	// InstanceID 是持有该子持仓的策略实例。
+	InstanceID string `json:"instance_id"`
+	// Position 是带符号的子持仓，多头为正、空头为负。
+	Position float64 `json:"position"`
+}
+
 type TickEvent struct {
 	// ReplayTaskID 是复盘训练/回放任务 ID，仅 replay 模式下有值。
 	ReplayTaskID string `json:"replay_task_id,omitempty"`
+	// ReplaySession 是回放会话标识，默认会话为空，仅 replay 模式下使用。
+	ReplaySession string `json:"replay_session,omitempty"`
 	// InstrumentID 是合约代码。
 	InstrumentID string `json:"instrument_id"`
 	// ExchangeID 是交易所代码。
@@ -309,6 +490,8 @@ type TickEvent struct {
 type BarEvent struct {
 	// ReplayTaskID 是复盘训练/回放任务 ID，仅 replay 模式下有值。
 	ReplayTaskID string `json:"replay_task_id,omitempty"`
+	// ReplaySession 是回放会话标识，默认会话为空，仅 replay 模式下使用。
+	ReplaySession string `json:"replay_session,omitempty"`
 	// Variety 是品种代码。
 	Variety string `json:"variety"`
 	// InstrumentID 是合约代码。
@@ -335,6 +518,12 @@ type BarEvent struct {
 	OpenInterest float64 `json:"open_interest"`
 	// SettlementPrice 是 bar 对应的结算价字段。
 	SettlementPrice float64 `json:"settlement_price"`
+	// LatencyTraceID 是实盘 bar 从封口到成交回报整条链路的追踪 ID，由分发入口分配并随决策请求传给策略。
+	LatencyTraceID string `json:"latency_trace_id,omitempty"`
+	// ClosedAt 是行情分片封口这根 bar 的时间。
+	ClosedAt time.Time `json:"-"`
+	// PublishedAt 是 bar 进入策略分发的时间。
+	PublishedAt time.Time `json:"-"`
 }
 
 type EventEnvelope struct {
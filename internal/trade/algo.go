@@ -0,0 +1,669 @@
+// algo.go 负责母单/子单算法执行：TWAP 按时间窗口均匀切片，VWAP 按历史 1m 成交量分布切片，冰山单只显示部分手数。
+// 子单统一经 Service.SubmitOrder 下发，仍受风控约束；盘口移动时撤掉旧价子单，剩余量按新价补挂。
+package trade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/order"
+)
+
+const (
+	AlgoStyleTWAP    = "twap"
+	AlgoStyleVWAP    = "vwap"
+	AlgoStyleIceberg = "iceberg"
+
+	ParentStatusWorking   = "working"
+	ParentStatusCompleted = "completed"
+	ParentStatusCanceled  = "canceled"
+	ParentStatusFailed    = "failed"
+	// ParentStatusExpired 表示 TWAP/VWAP 执行窗口结束时仍有未成交手数，母单不再补单。
+	ParentStatusExpired = "expired"
+
+	// algoCancelReason 是算法撤单/改价时使用的撤单原因。
+	algoCancelReason = "algo_replace"
+	// algoTickInterval 是母单调度轮询间隔。
+	algoTickInterval = time.Second
+	// defaultAlgoDuration 是 TWAP/VWAP 未指定窗口时的执行时长。
+	defaultAlgoDuration = 5 * time.Minute
+	// defaultAlgoRepriceAfter 是子单挂出后允许偏离最优价的最短时间，避免每个报价都撤单。
+	defaultAlgoRepriceAfter = 3 * time.Second
+	// maxAlgoSubmitFailures 是连续下单失败多少次后母单置为失败。
+	maxAlgoSubmitFailures = 3
+)
+
+var (
+	ErrParentOrderNotFound = errors.New("parent order not found")
+	ErrParentOrderFinal    = errors.New("parent order already final")
+)
+
+// ParentOrderRequest 是母单下单请求；Order.Volume 为母单总手数，Order.LimitPrice 为可选价格上限（买）或下限（卖）。
+type ParentOrderRequest struct {
+	Order     SubmitOrderRequest   `json:"order"`
+	Execution order.ExecutionStyle `json:"execution"`
+}
+
+// AlgoSlice 是执行计划中的一个时间片：到 DueAt 时累计应下达的手数增加 Volume。
+type AlgoSlice struct {
+	DueAt  time.Time `json:"due_at"`
+	Volume int       `json:"volume"`
+}
+
+// ChildOrderState 是母单下某个子单的最新状态。
+type ChildOrderState struct {
+	// CommandID 是子单的系统指令 ID。
+	CommandID string `json:"command_id"`
+	// Volume 是子单委托手数。
+	Volume int `json:"volume"`
+	// LimitPrice 是子单委托价。
+	LimitPrice float64 `json:"limit_price"`
+	// VolumeTraded 是子单已成交手数。
+	VolumeTraded int `json:"volume_traded"`
+	// OrderStatus 是子单最新委托状态。
+	OrderStatus string `json:"order_status"`
+	// CancelRequested 表示已为改价或撤母单发出撤单。
+	CancelRequested bool `json:"cancel_requested,omitempty"`
+	// SubmittedAt 是子单下达时间。
+	SubmittedAt time.Time `json:"submitted_at"`
+}
+
+// ParentOrder 是算法母单及其执行进度。
+type ParentOrder struct {
+	ParentID     string  `json:"parent_id"`
+	AccountID    string  `json:"account_id"`
+	Symbol       string  `json:"symbol"`
+	ExchangeID   string  `json:"exchange_id"`
+	Direction    string  `json:"direction"`
+	OffsetFlag   string  `json:"offset_flag"`
+	LimitPrice   float64 `json:"limit_price,omitempty"`
+	TotalVolume  int     `json:"total_volume"`
+	FilledVolume int     `json:"filled_volume"`
+	// UnfilledVolume 是母单以 expired 结束时未成交的手数。
+	UnfilledVolume int                  `json:"unfilled_volume,omitempty"`
+	Execution      order.ExecutionStyle `json:"execution"`
+	Status         string               `json:"status"`
+	Reason         string               `json:"reason"`
+	ClientTag      string               `json:"client_tag,omitempty"`
+	Schedule       []AlgoSlice          `json:"schedule,omitempty"`
+	Children       []ChildOrderState    `json:"children"`
+	LastError      string               `json:"last_error,omitempty"`
+	StartedAt      time.Time            `json:"started_at"`
+	EndAt          time.Time            `json:"end_at"`
+	UpdatedAt      time.Time            `json:"updated_at"`
+	// submitFailures 是连续下单失败次数，不对外输出。
+	submitFailures int
+	// cancelRequested 表示母单已被要求撤销。
+	cancelRequested bool
+}
+
+// AlgoQuote 是算法定价使用的盘口快照。
+type AlgoQuote struct {
+	LastPrice float64
+	BidPrice1 float64
+	AskPrice1 float64
+}
+
+// AlgoQuoteFunc 返回合约最新盘口；ok 为 false 表示暂无行情，此时不下新子单。
+type AlgoQuoteFunc func(symbol string) (AlgoQuote, bool)
+
+// VolumeBar 是构建成交量分布使用的 1m K 线成交量。
+type VolumeBar struct {
+	Time   time.Time
+	Volume float64
+}
+
+// VolumeProfile 是按分钟（距 0 点的分钟数）统计的平均成交量。
+type VolumeProfile map[int]float64
+
+// VolumeProfileFunc 返回合约的历史成交量分布，供 VWAP 切片使用。
+type VolumeProfileFunc func(symbol string) (VolumeProfile, error)
+
+// algoStep 是某一时刻母单需要执行的动作。
+type algoStep struct {
+	cancel       []string
+	submitVolume int
+	submitPrice  float64
+	completed    bool
+	// expired 表示执行窗口已结束，本轮只撤在途子单不再补单。
+	expired bool
+}
+
+// BuildVolumeProfile 把多日 1m K 线按分钟聚合为平均成交量。
+func BuildVolumeProfile(bars []VolumeBar) VolumeProfile {
+	sum := make(map[int]float64)
+	days := make(map[int]map[string]struct{})
+	for _, bar := range bars {
+		if bar.Volume <= 0 || bar.Time.IsZero() {
+			continue
+		}
+		minute := bar.Time.Hour()*60 + bar.Time.Minute()
+		sum[minute] += bar.Volume
+		if days[minute] == nil {
+			days[minute] = make(map[string]struct{})
+		}
+		days[minute][bar.Time.Format("20060102")] = struct{}{}
+	}
+	out := make(VolumeProfile, len(sum))
+	for minute, total := range sum {
+		out[minute] = total / float64(len(days[minute]))
+	}
+	return out
+}
+
+// BuildTWAPSchedule 在 [start, start+duration) 内均匀切出 slices 片，余数分给靠前的切片。
+func BuildTWAPSchedule(total int, start time.Time, duration time.Duration, slices int) []AlgoSlice {
+	if total <= 0 {
+		return nil
+	}
+	if duration <= 0 {
+		duration = defaultAlgoDuration
+	}
+	if slices <= 0 {
+		slices = int(duration / time.Minute)
+	}
+	slices = max(1, min(slices, total))
+	step := duration / time.Duration(slices)
+	out := make([]AlgoSlice, 0, slices)
+	for i, volume := range splitEvenly(total, slices) {
+		out = append(out, AlgoSlice{DueAt: start.Add(time.Duration(i) * step), Volume: volume})
+	}
+	return out
+}
+
+// BuildVWAPSchedule 按历史分钟成交量占比把 total 分配到窗口内每分钟；分布缺失时退化为 TWAP。
+func BuildVWAPSchedule(total int, start time.Time, duration time.Duration, profile VolumeProfile) []AlgoSlice {
+	if total <= 0 {
+		return nil
+	}
+	if duration <= 0 {
+		duration = defaultAlgoDuration
+	}
+	minutes := max(1, int(math.Ceil(duration.Minutes())))
+	weights := make([]float64, minutes)
+	var weightSum float64
+	for i := range weights {
+		t := start.Add(time.Duration(i) * time.Minute)
+		weights[i] = profile[t.Hour()*60+t.Minute()]
+		weightSum += weights[i]
+	}
+	if weightSum <= 0 {
+		return BuildTWAPSchedule(total, start, duration, 0)
+	}
+	volumes := allocateByWeight(total, weights, weightSum)
+	out := make([]AlgoSlice, 0, minutes)
+	for i, volume := range volumes {
+		if volume <= 0 {
+			continue
+		}
+		out = append(out, AlgoSlice{DueAt: start.Add(time.Duration(i) * time.Minute), Volume: volume})
+	}
+	return out
+}
+
+func splitEvenly(total int, n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = total / n
+		if i < total%n {
+			out[i]++
+		}
+	}
+	return out
+}
+
+// allocateByWeight 用最大余数法按权重分配整数手数，保证合计等于 total。
+func allocateByWeight(total int, weights []float64, weightSum float64) []int {
+	out := make([]int, len(weights))
+	type remainder struct {
+		index int
+		frac  float64
+	}
+	rems := make([]remainder, 0, len(weights))
+	assigned := 0
+	for i, w := range weights {
+		exact := float64(total) * w / weightSum
+		out[i] = int(math.Floor(exact))
+		assigned += out[i]
+		rems = append(rems, remainder{index: i, frac: exact - float64(out[i])})
+	}
+	sort.SliceStable(rems, func(i, j int) bool { return rems[i].frac > rems[j].frac })
+	for i := 0; assigned < total; i++ {
+		out[rems[i%len(rems)].index]++
+		assigned++
+	}
+	return out
+}
+
+// scheduledVolume 返回截至 now 计划累计应下达的手数。
+func scheduledVolume(schedule []AlgoSlice, now time.Time) int {
+	total := 0
+	for _, slice := range schedule {
+		if !slice.DueAt.After(now) {
+			total += slice.Volume
+		}
+	}
+	return total
+}
+
+// algoChildPrice 返回子单的对价：买用卖一、卖用买一，并受母单限价约束。
+func algoChildPrice(direction string, limit float64, quote AlgoQuote) (float64, bool) {
+	price := quote.LastPrice
+	if direction == "buy" && quote.AskPrice1 > 0 {
+		price = quote.AskPrice1
+	}
+	if direction == "sell" && quote.BidPrice1 > 0 {
+		price = quote.BidPrice1
+	}
+	if price <= 0 {
+		return 0, false
+	}
+	if limit > 0 {
+		if direction == "buy" {
+			price = math.Min(price, limit)
+		} else {
+			price = math.Max(price, limit)
+		}
+	}
+	return price, true
+}
+
+func isChildFinal(status string) bool {
+	switch strings.TrimSpace(status) {
+	case "all_traded", "canceled", "rejected", "part_traded_not_queueing", "no_trade_not_queueing":
+		return true
+	default:
+		return false
+	}
+}
+
+func (p ParentOrder) filledVolume() int {
+	total := 0
+	for _, child := range p.Children {
+		total += child.VolumeTraded
+	}
+	return total
+}
+
+func (p ParentOrder) workingVolume() int {
+	total := 0
+	for _, child := range p.Children {
+		if !isChildFinal(child.OrderStatus) {
+			total += max(0, child.Volume-child.VolumeTraded)
+		}
+	}
+	return total
+}
+
+func (p ParentOrder) repriceAfter() time.Duration {
+	if p.Execution.RepriceAfterSec > 0 {
+		return time.Duration(p.Execution.RepriceAfterSec) * time.Second
+	}
+	return defaultAlgoRepriceAfter
+}
+
+// planAlgoStep 根据母单当前子单状态和盘口决定本轮动作；maxChild 是单笔子单上限，0 表示不限。
+func planAlgoStep(p ParentOrder, now time.Time, quote AlgoQuote, hasQuote bool, maxChild int) algoStep {
+	var step algoStep
+	filled := p.filledVolume()
+	remaining := p.TotalVolume - filled
+	if remaining <= 0 || p.cancelRequested {
+		for _, child := range p.Children {
+			if !isChildFinal(child.OrderStatus) && !child.CancelRequested {
+				step.cancel = append(step.cancel, child.CommandID)
+			}
+		}
+		step.completed = remaining <= 0
+		return step
+	}
+	// TWAP/VWAP 窗口结束后撤掉在途子单、不再补单，未成交的切片由母单以 expired 状态报告。
+	if !p.EndAt.IsZero() && !now.Before(p.EndAt) {
+		for _, child := range p.Children {
+			if !isChildFinal(child.OrderStatus) && !child.CancelRequested {
+				step.cancel = append(step.cancel, child.CommandID)
+			}
+		}
+		step.expired = true
+		return step
+	}
+	price, priceOK := algoChildPrice(p.Direction, p.LimitPrice, quote)
+	priceOK = priceOK && hasQuote
+	cancelPending := false
+	for _, child := range p.Children {
+		if isChildFinal(child.OrderStatus) {
+			continue
+		}
+		if child.CancelRequested {
+			cancelPending = true
+			continue
+		}
+		if priceOK && child.LimitPrice != price && now.Sub(child.SubmittedAt) >= p.repriceAfter() {
+			step.cancel = append(step.cancel, child.CommandID)
+			cancelPending = true
+		}
+	}
+	// 撤单回报确认前不补单，防止旧子单随后成交造成超量。
+	if cancelPending || !priceOK {
+		return step
+	}
+	working := p.workingVolume()
+	want := 0
+	switch p.Execution.Style {
+	case AlgoStyleIceberg:
+		if working == 0 {
+			want = min(max(1, p.Execution.DisplayVolume), remaining)
+		}
+	default:
+		want = min(scheduledVolume(p.Schedule, now), p.TotalVolume) - filled - working
+		want = min(want, remaining-working)
+	}
+	if maxChild > 0 {
+		want = min(want, maxChild)
+	}
+	if want > 0 {
+		step.submitVolume = want
+		step.submitPrice = price
+	}
+	return step
+}
+
+// SetAlgoMarketProvider 注册算法执行使用的盘口与历史成交量分布来源。
+func (s *Service) SetAlgoMarketProvider(quote AlgoQuoteFunc, profile VolumeProfileFunc) {
+	s.algoMu.Lock()
+	s.algoQuote = quote
+	s.volumeProfile = profile
+	s.algoMu.Unlock()
+}
+
+// SubmitParentOrder 创建算法母单并在后台按执行方式拆分子单。
+func (s *Service) SubmitParentOrder(ctx context.Context, req ParentOrderRequest) (ParentOrder, error) {
+	order, err := s.normalizeSubmitRequest(req.Order)
+	if err != nil {
+		return ParentOrder{}, err
+	}
+	if order.Volume <= 0 {
+		return ParentOrder{}, errors.New("volume must be > 0")
+	}
+	if err := ctx.Err(); err != nil {
+		return ParentOrder{}, err
+	}
+	exec := req.Execution
+	exec.Style = strings.ToLower(strings.TrimSpace(exec.Style))
+	now := s.algoNow()
+	duration := time.Duration(exec.DurationSec) * time.Second
+	if duration <= 0 {
+		duration = defaultAlgoDuration
+	}
+	parent := ParentOrder{
+		ParentID:    mustCommandID("algo"),
+		AccountID:   s.accountID,
+		Symbol:      order.Symbol,
+		ExchangeID:  order.ExchangeID,
+		Direction:   order.Direction,
+		OffsetFlag:  order.OffsetFlag,
+		LimitPrice:  order.LimitPrice,
+		TotalVolume: order.Volume,
+		Execution:   exec,
+		Status:      ParentStatusWorking,
+		Reason:      order.Reason,
+		ClientTag:   order.ClientTag,
+		Children:    []ChildOrderState{},
+		StartedAt:   now,
+		EndAt:       now.Add(duration),
+		UpdatedAt:   now,
+	}
+	switch exec.Style {
+	case AlgoStyleTWAP:
+		parent.Schedule = BuildTWAPSchedule(order.Volume, now, duration, exec.Slices)
+	case AlgoStyleVWAP:
+		s.algoMu.Lock()
+		profileFn := s.volumeProfile
+		s.algoMu.Unlock()
+		var profile VolumeProfile
+		if profileFn != nil {
+			if profile, err = profileFn(order.Symbol); err != nil {
+				logger.Warn("load vwap volume profile failed; fallback to twap slicing", "symbol", order.Symbol, "error", err)
+			}
+		}
+		parent.Schedule = BuildVWAPSchedule(order.Volume, now, duration, profile)
+	case AlgoStyleIceberg:
+		if exec.DisplayVolume <= 0 {
+			return ParentOrder{}, errors.New("display_volume must be > 0 for iceberg")
+		}
+		parent.EndAt = time.Time{}
+	default:
+		return ParentOrder{}, fmt.Errorf("unsupported execution style %q", req.Execution.Style)
+	}
+	if err := s.store.SaveParentOrder(parent); err != nil {
+		return ParentOrder{}, err
+	}
+	s.algoMu.Lock()
+	if s.parents == nil {
+		s.parents = make(map[string]*ParentOrder)
+	}
+	s.parents[parent.ParentID] = &parent
+	s.algoMu.Unlock()
+	s.broadcast("trade_parent_order_update", parent)
+	go s.runParentOrder(parent.ParentID)
+	return parent, nil
+}
+
+// CancelParentOrder 撤销母单：撤掉在途子单，已成交部分保留。
+func (s *Service) CancelParentOrder(parentID string) (ParentOrder, error) {
+	s.algoMu.Lock()
+	defer s.algoMu.Unlock()
+	p, ok := s.parents[parentID]
+	if !ok {
+		return ParentOrder{}, ErrParentOrderNotFound
+	}
+	if p.Status != ParentStatusWorking {
+		return *p, ErrParentOrderFinal
+	}
+	p.cancelRequested = true
+	return *p, nil
+}
+
+// ParentOrders 返回最近的母单，进行中的母单使用内存中的最新进度。
+func (s *Service) ParentOrders(limit int) ([]ParentOrder, error) {
+	items, err := s.store.ListParentOrders(s.accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	s.algoMu.Lock()
+	defer s.algoMu.Unlock()
+	for i, item := range items {
+		if p, ok := s.parents[item.ParentID]; ok {
+			items[i] = *p
+		}
+	}
+	return items, nil
+}
+
+func (s *Service) ParentOrder(parentID string) (ParentOrder, error) {
+	s.algoMu.Lock()
+	if p, ok := s.parents[parentID]; ok {
+		out := *p
+		s.algoMu.Unlock()
+		return out, nil
+	}
+	s.algoMu.Unlock()
+	return s.store.GetParentOrder(parentID)
+}
+
+func (s *Service) runParentOrder(parentID string) {
+	ticker := time.NewTicker(algoTickInterval)
+	defer ticker.Stop()
+	for {
+		if done := s.stepParentOrder(parentID); done {
+			return
+		}
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// stepParentOrder 同步子单状态并执行一轮调度，母单结束时返回 true。
+func (s *Service) stepParentOrder(parentID string) bool {
+	s.algoMu.Lock()
+	ptr, ok := s.parents[parentID]
+	if !ok {
+		s.algoMu.Unlock()
+		return true
+	}
+	parent := *ptr
+	parent.Children = append([]ChildOrderState(nil), ptr.Children...)
+	quoteFn := s.algoQuote
+	s.algoMu.Unlock()
+
+	changed := s.refreshChildOrders(&parent)
+	quote, hasQuote := s.algoQuoteFor(quoteFn, parent.Symbol)
+	now := s.algoNow()
+	step := planAlgoStep(parent, now, quote, hasQuote, s.cfg.MaxOrderVolume)
+	for _, commandID := range step.cancel {
+		_, err := s.CancelOrder(s.ctx, CancelOrderRequest{AccountID: s.accountID, CommandID: commandID, Reason: algoCancelReason})
+		for i := range parent.Children {
+			if parent.Children[i].CommandID != commandID {
+				continue
+			}
+			if err == nil || errors.Is(err, ErrOrderAlreadyFinal) {
+				parent.Children[i].CancelRequested = true
+			} else {
+				parent.LastError = err.Error()
+			}
+		}
+		changed = true
+	}
+	if step.submitVolume > 0 {
+		recs, err := s.submitOrderLegs(s.ctx, SubmitOrderRequest{
+			AccountID:  s.accountID,
+			Symbol:     parent.Symbol,
+			ExchangeID: parent.ExchangeID,
+			Direction:  parent.Direction,
+			OffsetFlag: parent.OffsetFlag,
+			LimitPrice: step.submitPrice,
+			Volume:     step.submitVolume,
+			ClientTag:  firstNonEmpty(parent.ClientTag, "algo:"+parent.ParentID),
+			Reason:     parent.Reason,
+		})
+		// auto_close 可能拆成平今/平昨多笔，已下达的每一笔都要跟踪，避免漏算成交。
+		for _, rec := range recs {
+			parent.Children = append(parent.Children, ChildOrderState{
+				CommandID:    rec.CommandID,
+				Volume:       rec.VolumeTotalOriginal,
+				LimitPrice:   step.submitPrice,
+				VolumeTraded: rec.VolumeTraded,
+				OrderStatus:  rec.OrderStatus,
+				SubmittedAt:  now,
+			})
+		}
+		if err != nil {
+			parent.submitFailures++
+			parent.LastError = err.Error()
+			logger.Warn("algo child order submit failed", "parent_id", parent.ParentID, "error", err)
+		} else {
+			parent.submitFailures = 0
+		}
+		changed = true
+	}
+	parent.settleStatus(step)
+	done := parent.Status != ParentStatusWorking
+	if done {
+		changed = true
+	}
+
+	s.algoMu.Lock()
+	// 运行期间只有撤母单会修改 cancelRequested，这里保留外部写入。
+	parent.cancelRequested = parent.cancelRequested || ptr.cancelRequested
+	if changed {
+		parent.UpdatedAt = now
+	}
+	*ptr = parent
+	if done {
+		delete(s.parents, parentID)
+	}
+	s.algoMu.Unlock()
+	if changed {
+		if err := s.store.SaveParentOrder(parent); err != nil {
+			logger.Warn("save parent order failed", "parent_id", parent.ParentID, "error", err)
+		}
+		s.broadcast("trade_parent_order_update", parent)
+	}
+	return done
+}
+
+// settleStatus 汇总本轮成交并判断母单是否结束；撤单、失败和窗口到期都要等在途子单全部终结。
+func (p *ParentOrder) settleStatus(step algoStep) {
+	p.FilledVolume = p.filledVolume()
+	working := p.workingVolume()
+	switch {
+	case step.completed:
+		p.Status = ParentStatusCompleted
+	case p.submitFailures >= maxAlgoSubmitFailures && working == 0:
+		p.Status = ParentStatusFailed
+	case p.cancelRequested && working == 0:
+		p.Status = ParentStatusCanceled
+	case step.expired && working == 0:
+		p.Status = ParentStatusExpired
+		p.UnfilledVolume = p.TotalVolume - p.FilledVolume
+		p.LastError = fmt.Sprintf("execution window ended with %d of %d lots unfilled", p.UnfilledVolume, p.TotalVolume)
+		logger.Warn("algo parent order expired with unfilled volume", "parent_id", p.ParentID, "symbol", p.Symbol, "unfilled_volume", p.UnfilledVolume)
+	}
+}
+
+// refreshChildOrders 从委托表读取未终结子单的最新成交与状态。
+func (s *Service) refreshChildOrders(parent *ParentOrder) bool {
+	changed := false
+	for i := range parent.Children {
+		child := &parent.Children[i]
+		if isChildFinal(child.OrderStatus) {
+			continue
+		}
+		rec, err := s.store.GetOrder(child.CommandID)
+		if err != nil {
+			continue
+		}
+		if rec.VolumeTraded != child.VolumeTraded || rec.OrderStatus != child.OrderStatus {
+			child.VolumeTraded = rec.VolumeTraded
+			child.OrderStatus = rec.OrderStatus
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *Service) algoQuoteFor(fn AlgoQuoteFunc, symbol string) (AlgoQuote, bool) {
+	if fn != nil {
+		if quote, ok := fn(symbol); ok {
+			return quote, true
+		}
+	}
+	if !s.paper {
+		return AlgoQuote{}, false
+	}
+	s.paperMu.Lock()
+	q := s.replayQuoteForSymbol(symbol)
+	s.paperMu.Unlock()
+	if q.LastPrice <= 0 && q.BidPrice1 <= 0 && q.AskPrice1 <= 0 {
+		return AlgoQuote{}, false
+	}
+	return AlgoQuote{LastPrice: q.LastPrice, BidPrice1: q.BidPrice1, AskPrice1: q.AskPrice1}, true
+}
+
+// algoNow 返回调度使用的当前时间；回放模拟盘跟随回放时钟。
+func (s *Service) algoNow() time.Time {
+	if !s.replayPaper {
+		return time.Now()
+	}
+	s.paperMu.Lock()
+	defer s.paperMu.Unlock()
+	return s.replayNowLocked()
+}
@@ -0,0 +1,188 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/order"
+)
+
+func TestBuildTWAPScheduleSplitsEvenly(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	got := BuildTWAPSchedule(10, start, 4*time.Minute, 4)
+	want := []int{3, 3, 2, 2}
+	if len(got) != len(want) {
+		t.Fatalf("len(schedule) = %d, want %d: %+v", len(got), len(want), got)
+	}
+	for i, slice := range got {
+		if slice.Volume != want[i] || !slice.DueAt.Equal(start.Add(time.Duration(i)*time.Minute)) {
+			t.Fatalf("schedule[%d] = %+v", i, slice)
+		}
+	}
+	if n := len(BuildTWAPSchedule(2, start, 10*time.Minute, 0)); n != 2 {
+		t.Fatalf("slices capped by volume = %d, want 2", n)
+	}
+}
+
+func TestBuildVWAPScheduleFollowsVolumeProfile(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2026, 2, 26, 0, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	bars := []VolumeBar{
+		{Time: day1.Add(9 * time.Hour), Volume: 600},
+		{Time: day1.Add(9*time.Hour + time.Minute), Volume: 200},
+		{Time: day1.Add(9*time.Hour + 2*time.Minute), Volume: 200},
+		{Time: day2.Add(9 * time.Hour), Volume: 600},
+		{Time: day2.Add(9*time.Hour + time.Minute), Volume: 200},
+		{Time: day2.Add(9*time.Hour + 2*time.Minute), Volume: 200},
+	}
+	profile := BuildVolumeProfile(bars)
+	if profile[9*60] != 600 {
+		t.Fatalf("profile[09:00] = %v, want 600", profile[9*60])
+	}
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	got := BuildVWAPSchedule(10, start, 3*time.Minute, profile)
+	want := []int{6, 2, 2}
+	if len(got) != len(want) {
+		t.Fatalf("schedule = %+v", got)
+	}
+	for i, slice := range got {
+		if slice.Volume != want[i] {
+			t.Fatalf("schedule[%d].Volume = %d, want %d", i, slice.Volume, want[i])
+		}
+	}
+	fallback := BuildVWAPSchedule(3, start.Add(time.Hour), 3*time.Minute, profile)
+	if len(fallback) != 3 || fallback[0].Volume != 1 {
+		t.Fatalf("fallback schedule = %+v, want twap", fallback)
+	}
+}
+
+func TestPlanAlgoStepTWAPFollowsSchedule(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	parent := ParentOrder{
+		Direction:   "buy",
+		TotalVolume: 4,
+		Execution:   order.ExecutionStyle{Style: AlgoStyleTWAP},
+		Schedule:    BuildTWAPSchedule(4, start, 2*time.Minute, 2),
+	}
+	quote := AlgoQuote{BidPrice1: 3899, AskPrice1: 3900}
+
+	step := planAlgoStep(parent, start, quote, true, 0)
+	if step.submitVolume != 2 || step.submitPrice != 3900 {
+		t.Fatalf("first step = %+v, want 2 @ 3900", step)
+	}
+	parent.Children = []ChildOrderState{{CommandID: "c1", Volume: 2, LimitPrice: 3900, OrderStatus: "no_trade_queueing", SubmittedAt: start}}
+	if step := planAlgoStep(parent, start.Add(30*time.Second), quote, true, 0); step.submitVolume != 0 || len(step.cancel) != 0 {
+		t.Fatalf("mid-slice step = %+v, want idle", step)
+	}
+	parent.Children[0].VolumeTraded = 2
+	parent.Children[0].OrderStatus = "all_traded"
+	step = planAlgoStep(parent, start.Add(time.Minute), quote, true, 1)
+	if step.submitVolume != 1 {
+		t.Fatalf("second slice step = %+v, want 1 capped by max child volume", step)
+	}
+	parent.Children = append(parent.Children, ChildOrderState{CommandID: "c2", Volume: 2, VolumeTraded: 2, OrderStatus: "all_traded"})
+	if step := planAlgoStep(parent, start.Add(time.Minute), quote, true, 0); !step.completed {
+		t.Fatalf("filled parent step = %+v, want completed", step)
+	}
+}
+
+func TestTWAPWindowEndCancelsWorkingChildAndReportsUnfilled(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	parent := ParentOrder{
+		Direction:   "buy",
+		TotalVolume: 4,
+		Execution:   order.ExecutionStyle{Style: AlgoStyleTWAP, DurationSec: 120},
+		Schedule:    BuildTWAPSchedule(4, start, 2*time.Minute, 2),
+		Status:      ParentStatusWorking,
+		StartedAt:   start,
+		EndAt:       start.Add(2 * time.Minute),
+		Children: []ChildOrderState{
+			{CommandID: "c1", Volume: 2, VolumeTraded: 2, LimitPrice: 3900, OrderStatus: "all_traded", SubmittedAt: start},
+			{CommandID: "c2", Volume: 2, VolumeTraded: 1, LimitPrice: 3900, OrderStatus: "part_traded_queueing", SubmittedAt: start.Add(time.Minute)},
+		},
+	}
+	quote := AlgoQuote{BidPrice1: 3899, AskPrice1: 3900}
+
+	if step := planAlgoStep(parent, start.Add(110*time.Second), quote, true, 0); step.expired || len(step.cancel) != 0 {
+		t.Fatalf("step inside window = %+v, want still working", step)
+	}
+	step := planAlgoStep(parent, parent.EndAt, quote, true, 0)
+	if !step.expired || step.submitVolume != 0 || len(step.cancel) != 1 || step.cancel[0] != "c2" {
+		t.Fatalf("window end step = %+v, want cancel c2 and no new child", step)
+	}
+	parent.Children[1].CancelRequested = true
+	parent.settleStatus(step)
+	if parent.Status != ParentStatusWorking {
+		t.Fatalf("status while cancel pending = %s, want working", parent.Status)
+	}
+	parent.Children[1].OrderStatus = "canceled"
+	step = planAlgoStep(parent, parent.EndAt.Add(time.Second), quote, true, 0)
+	parent.settleStatus(step)
+	if parent.Status != ParentStatusExpired || parent.FilledVolume != 3 || parent.UnfilledVolume != 1 || parent.LastError == "" {
+		t.Fatalf("parent after window = %+v, want expired with 1 unfilled", parent)
+	}
+}
+
+func TestPlanAlgoStepRepricesWhenBookMoves(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 2, 9, 0, 10, 0, time.Local)
+	parent := ParentOrder{
+		Direction:   "sell",
+		TotalVolume: 5,
+		Execution:   order.ExecutionStyle{Style: AlgoStyleIceberg, DisplayVolume: 2},
+		Children: []ChildOrderState{
+			{CommandID: "c1", Volume: 2, VolumeTraded: 1, LimitPrice: 3900, OrderStatus: "part_traded_queueing", SubmittedAt: now.Add(-time.Second)},
+		},
+	}
+	moved := AlgoQuote{BidPrice1: 3895, AskPrice1: 3896}
+	if step := planAlgoStep(parent, now, moved, true, 0); len(step.cancel) != 0 || step.submitVolume != 0 {
+		t.Fatalf("step before reprice delay = %+v, want idle", step)
+	}
+	step := planAlgoStep(parent, now.Add(5*time.Second), moved, true, 0)
+	if len(step.cancel) != 1 || step.cancel[0] != "c1" || step.submitVolume != 0 {
+		t.Fatalf("reprice step = %+v, want cancel c1 only", step)
+	}
+	parent.Children[0].CancelRequested = true
+	if step := planAlgoStep(parent, now.Add(6*time.Second), moved, true, 0); step.submitVolume != 0 {
+		t.Fatalf("step while cancel pending = %+v, want no submit", step)
+	}
+	parent.Children[0].OrderStatus = "canceled"
+	step = planAlgoStep(parent, now.Add(7*time.Second), moved, true, 0)
+	if step.submitVolume != 2 || step.submitPrice != 3895 {
+		t.Fatalf("replace step = %+v, want 2 @ 3895", step)
+	}
+}
+
+func TestPlanAlgoStepRespectsParentLimitAndQuote(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	parent := ParentOrder{
+		Direction:   "buy",
+		LimitPrice:  3890,
+		TotalVolume: 3,
+		Execution:   order.ExecutionStyle{Style: AlgoStyleIceberg, DisplayVolume: 5},
+	}
+	if step := planAlgoStep(parent, now, AlgoQuote{}, false, 0); step.submitVolume != 0 {
+		t.Fatalf("step without quote = %+v, want idle", step)
+	}
+	step := planAlgoStep(parent, now, AlgoQuote{BidPrice1: 3899, AskPrice1: 3900}, true, 0)
+	if step.submitVolume != 3 || step.submitPrice != 3890 {
+		t.Fatalf("step = %+v, want 3 @ capped 3890", step)
+	}
+	parent.cancelRequested = true
+	parent.Children = []ChildOrderState{{CommandID: "c1", Volume: 3, OrderStatus: "no_trade_queueing"}}
+	step = planAlgoStep(parent, now, AlgoQuote{AskPrice1: 3900}, true, 0)
+	if len(step.cancel) != 1 || step.submitVolume != 0 || step.completed {
+		t.Fatalf("canceled parent step = %+v", step)
+	}
+}
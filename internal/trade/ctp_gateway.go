@@ -38,8 +38,13 @@ type CTPGateway struct {
 	started bool
 	// registry 提供统一的队列监控注册表。
 	registry *queuewatch.Registry
+	// newAPI 创建底层 Trader API，默认使用 ctp-go；测试时可替换为 MockTraderFront。
+	newAPI TraderAPIFactory
 }
 
+// TraderAPIFactory 按流文件目录创建 Trader API 实例。
+type TraderAPIFactory func(flowPath string) ctp.CThostFtdcTraderApi
+
 func NewCTPGateway(cfg config.CTPConfig, tradeCfg config.TradeConfig, registry *queuewatch.Registry) *CTPGateway {
 	g := &CTPGateway{cfg: cfg, tradeCfg: tradeCfg, registry: registry}
 	st := TradeStatus{Enabled: tradeCfg.IsEnabled(), AccountID: tradeCfg.AccountID, UpdatedAt: time.Now()}
@@ -47,6 +52,13 @@ func NewCTPGateway(cfg config.CTPConfig, tradeCfg config.TradeConfig, registry *
 	return g
 }
 
+// SetTraderAPIFactory 替换底层 Trader API 的创建方式，需在 Start 之前调用。
+func (g *CTPGateway) SetTraderAPIFactory(fn TraderAPIFactory) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.newAPI = fn
+}
+
 func (g *CTPGateway) Start() error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -58,7 +70,13 @@ func (g *CTPGateway) Start() error {
 	}
 
 	spi := newCTPTradeSpi(g.tradeCfg.AccountID, g.registry)
-	api := ctp.CThostFtdcTraderApiCreateFtdcTraderApi(filepath.Clean(g.cfg.FlowPath))
+	newAPI := g.newAPI
+	if newAPI == nil {
+		newAPI = func(flowPath string) ctp.CThostFtdcTraderApi {
+			return ctp.CThostFtdcTraderApiCreateFtdcTraderApi(flowPath)
+		}
+	}
+	api := newAPI(filepath.Clean(g.cfg.FlowPath))
 	api.RegisterSpi(ctp.NewDirectorCThostFtdcTraderSpi(spi))
 	api.RegisterFront(g.cfg.TraderFrontAddr)
 	api.SubscribePrivateTopic(ctp.THOST_TERT_RESTART)
@@ -291,6 +309,34 @@ func (g *CTPGateway) QueryMarginRate(instrumentID string, exchangeID string) ([]
 	return res.marginRates, nil
 }
 
+// QuerySettlementInfo 查询指定交易日的结算单正文；tradingDay 为空时由柜台返回最近一个结算日。
+// 返回值为解码后的 UTF-8 文本以及结算单实际所属交易日。
+func (g *CTPGateway) QuerySettlementInfo(tradingDay string) (string, string, error) {
+	field := ctp.NewCThostFtdcQrySettlementInfoField()
+	defer ctp.DeleteCThostFtdcQrySettlementInfoField(field)
+	field.SetBrokerID(g.cfg.BrokerID)
+	field.SetInvestorID(g.cfg.UserID)
+	field.SetTradingDay(strings.TrimSpace(tradingDay))
+	reqID := g.spi.nextReqID()
+	resCh := g.spi.beginQuery(reqID)
+	if ret := g.api.ReqQrySettlementInfo(field, resCh.reqID); ret != 0 {
+		return "", "", fmt.Errorf("req_id=%d ReqQrySettlementInfo failed: %d", reqID, ret)
+	}
+	res, err := g.waitQueryResult(resCh, "settlement_info")
+	if err != nil {
+		return "", "", err
+	}
+	if res.err != nil {
+		return "", "", fmt.Errorf("req_id=%d %w", reqID, res.err)
+	}
+	g.touchQuery()
+	text, day, err := joinSettlementChunks(res.settlementChunks)
+	if err != nil {
+		return "", "", err
+	}
+	return text, firstNonEmpty(day, strings.TrimSpace(tradingDay)), nil
+}
+
 func (g *CTPGateway) waitQueryResult(resCh *queryResult, kind string) (queryResult, error) {
 	timeout := time.Duration(g.tradeCfg.QueryTimeoutMS) * time.Millisecond
 	if timeout <= 0 {
@@ -465,6 +511,15 @@ type queryResult struct {
 	trades          []TradeRecord
 	commissionRates []CommissionRateSnapshot
 	marginRates     []MarginRateSnapshot
+	// settlementChunks 保存结算单分片，按 SequenceNo 拼接后才是完整正文。
+	settlementChunks []settlementChunk
+}
+
+// settlementChunk 是 OnRspQrySettlementInfo 返回的一段结算单原文（GBK 编码）。
+type settlementChunk struct {
+	tradingDay string
+	sequenceNo int
+	content    string
 }
 
 type ctpTradeSpi struct {
@@ -599,6 +654,15 @@ func (p *ctpTradeSpi) OnFrontConnected() {
 	logger.Info("trade gateway front connected")
 }
 
+func (p *ctpTradeSpi) OnFrontDisconnected(reason int) {
+	p.mu.Lock()
+	p.connected = false
+	p.loggedIn = false
+	p.lastErr = fmt.Sprintf("trade front disconnected: reason=%d", reason)
+	p.mu.Unlock()
+	logger.Warn("trade gateway front disconnected", "reason", reason)
+}
+
 func (p *ctpTradeSpi) OnRspAuthenticate(_ ctp.CThostFtdcRspAuthenticateField, rsp ctp.CThostFtdcRspInfoField, _ int, _ bool) {
 	err := rspError(rsp)
 	p.setError(err)
@@ -748,6 +812,19 @@ func (p *ctpTradeSpi) OnRspQryInstrumentMarginRate(field ctp.CThostFtdcInstrumen
 	})
 }
 
+func (p *ctpTradeSpi) OnRspQrySettlementInfo(field ctp.CThostFtdcSettlementInfoField, rsp ctp.CThostFtdcRspInfoField, reqID int, last bool) {
+	p.handleQuery(reqID, rspError(rsp), last, func(q *queryResult) {
+		if isNilCTPObject(field) {
+			return
+		}
+		q.settlementChunks = append(q.settlementChunks, settlementChunk{
+			tradingDay: strings.TrimSpace(field.GetTradingDay()),
+			sequenceNo: field.GetSequenceNo(),
+			content:    field.GetContent(),
+		})
+	})
+}
+
 func (p *ctpTradeSpi) OnRtnOrder(field ctp.CThostFtdcOrderField) {
 	if isNilCTPObject(field) {
 		return
@@ -1030,7 +1107,7 @@ func mapDirectionText(v byte) string {
 }
 
 func mapPosDirection(v byte) string {
-	if v == '2' {
+	if v == ctp.THOST_FTDC_PD_Short {
 		return "short"
 	}
 	return "long"
@@ -1118,3 +1195,10 @@ func maxInt(a int, b int) int {
 	}
 	return b
 }
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
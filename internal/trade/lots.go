@@ -0,0 +1,475 @@
+// lots.go 负责开仓批次账本：按成交回放出每个开仓批次，平仓按 FIFO/LIFO 与批次配对，
+// 计算逐批次的已实现盈亏、手续费、持仓时长以及基于 1m K 线的 MAE/MFE，并导出交易日志。
+package trade
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	LotMatchFIFO = "fifo"
+	LotMatchLIFO = "lifo"
+
+	// strategyClientTagPrefix 是策略自动下单的 ClientTag 前缀，后接实例 ID。
+	strategyClientTagPrefix = "strategy-auto-"
+)
+
+// JournalTrade 是参与批次回放的一笔成交及其委托标识。
+type JournalTrade struct {
+	TradeRecord
+	// ClientTag 是成交所属委托的调用方标识。
+	ClientTag string `json:"client_tag,omitempty"`
+	// Commission 是该笔成交的手续费。
+	Commission float64 `json:"commission"`
+}
+
+// PositionLot 是一个尚未平完的开仓批次。
+type PositionLot struct {
+	AccountID   string    `json:"account_id"`
+	InstanceID  string    `json:"instance_id,omitempty"`
+	Symbol      string    `json:"symbol"`
+	ExchangeID  string    `json:"exchange_id"`
+	Direction   string    `json:"direction"`
+	OpenTradeID string    `json:"open_trade_id"`
+	OpenTime    time.Time `json:"open_time"`
+	TradingDay  string    `json:"trading_day"`
+	OpenPrice   float64   `json:"open_price"`
+	Volume      int       `json:"volume"`
+	Remaining   int       `json:"remaining"`
+	// OpenFee 是剩余手数分摊的开仓手续费。
+	OpenFee float64 `json:"open_fee"`
+	// feePerLot 是开仓手续费的每手分摊额。
+	feePerLot float64
+}
+
+// JournalEntry 是一次开平配对后的交易日志记录。
+type JournalEntry struct {
+	AccountID    string    `json:"account_id"`
+	InstanceID   string    `json:"instance_id,omitempty"`
+	Symbol       string    `json:"symbol"`
+	ExchangeID   string    `json:"exchange_id"`
+	Direction    string    `json:"direction"`
+	Volume       int       `json:"volume"`
+	OpenTradeID  string    `json:"open_trade_id"`
+	CloseTradeID string    `json:"close_trade_id"`
+	OpenTime     time.Time `json:"open_time"`
+	CloseTime    time.Time `json:"close_time"`
+	OpenPrice    float64   `json:"open_price"`
+	ClosePrice   float64   `json:"close_price"`
+	RealizedPnL  float64   `json:"realized_pnl"`
+	OpenFee      float64   `json:"open_fee"`
+	CloseFee     float64   `json:"close_fee"`
+	NetPnL       float64   `json:"net_pnl"`
+	// HoldingSeconds 是批次从开仓到平仓的持有秒数。
+	HoldingSeconds int64 `json:"holding_seconds"`
+	// MAE 是持仓期间最大不利波动金额（非负）。
+	MAE float64 `json:"mae"`
+	// MFE 是持仓期间最大有利波动金额（非负）。
+	MFE float64 `json:"mfe"`
+	// Unmatched 表示平仓找不到对应开仓批次（例如历史成交缺失），此时不计算盈亏。
+	Unmatched bool `json:"unmatched,omitempty"`
+}
+
+// JournalQuery 是交易日志的筛选条件；From/To 按平仓时间过滤，零值表示不限。
+type JournalQuery struct {
+	InstanceID string
+	Symbol     string
+	From       time.Time
+	To         time.Time
+}
+
+// ExcursionBar 是计算 MAE/MFE 使用的 K 线高低价。
+type ExcursionBar struct {
+	Time time.Time
+	High float64
+	Low  float64
+}
+
+// LotBarFunc 返回合约在 [from, to] 内的 1m K 线。
+type LotBarFunc func(symbol string, from time.Time, to time.Time) ([]ExcursionBar, error)
+
+// LotBook 按成交顺序回放开平仓并产出批次配对结果。
+type LotBook struct {
+	method         string
+	volumeMultiple func(symbol string, exchangeID string) float64
+	open           map[string][]PositionLot
+	entries        []JournalEntry
+}
+
+// NewLotBook 创建批次账本；method 为 fifo 或 lifo，volumeMultiple 为空时按 1 计算。
+func NewLotBook(method string, volumeMultiple func(symbol string, exchangeID string) float64) *LotBook {
+	if method != LotMatchLIFO {
+		method = LotMatchFIFO
+	}
+	return &LotBook{method: method, volumeMultiple: volumeMultiple, open: make(map[string][]PositionLot)}
+}
+
+// StrategyClientTag 返回实例自动下单使用的 ClientTag。
+func StrategyClientTag(instanceID string) string {
+	return strategyClientTagPrefix + strings.TrimSpace(instanceID)
+}
+
+// InstanceIDFromClientTag 从策略 ClientTag 中取出实例 ID，非策略单返回空。
+func InstanceIDFromClientTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, strategyClientTagPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(tag, strategyClientTagPrefix)
+}
+
+func lotKey(symbol string, direction string) string {
+	return strings.ToLower(strings.TrimSpace(symbol)) + "|" + direction
+}
+
+func (b *LotBook) multiple(symbol string, exchangeID string) float64 {
+	if b.volumeMultiple == nil {
+		return 1
+	}
+	if v := b.volumeMultiple(symbol, exchangeID); v > 0 {
+		return v
+	}
+	return 1
+}
+
+// Apply 回放一笔成交：开仓新增批次，平仓按匹配顺序消耗批次并生成日志。
+func (b *LotBook) Apply(tr JournalTrade) {
+	if tr.Volume <= 0 {
+		return
+	}
+	if tr.OffsetFlag == "open" {
+		direction := "long"
+		if tr.Direction == "sell" {
+			direction = "short"
+		}
+		key := lotKey(tr.Symbol, direction)
+		b.open[key] = append(b.open[key], PositionLot{
+			AccountID:   tr.AccountID,
+			InstanceID:  InstanceIDFromClientTag(tr.ClientTag),
+			Symbol:      tr.Symbol,
+			ExchangeID:  tr.ExchangeID,
+			Direction:   direction,
+			OpenTradeID: strings.TrimSpace(tr.TradeID),
+			OpenTime:    tr.TradeTime,
+			TradingDay:  tr.TradingDay,
+			OpenPrice:   tr.Price,
+			Volume:      tr.Volume,
+			Remaining:   tr.Volume,
+			OpenFee:     tr.Commission,
+			feePerLot:   tr.Commission / float64(tr.Volume),
+		})
+		return
+	}
+	direction := "long"
+	if tr.Direction == "buy" {
+		direction = "short"
+	}
+	key := lotKey(tr.Symbol, direction)
+	lots := b.open[key]
+	closeFeePerLot := tr.Commission / float64(tr.Volume)
+	multiple := b.multiple(tr.Symbol, tr.ExchangeID)
+	remaining := tr.Volume
+	for _, idx := range b.matchOrder(lots, tr) {
+		if remaining <= 0 {
+			break
+		}
+		lot := &lots[idx]
+		used := min(remaining, lot.Remaining)
+		if used <= 0 {
+			continue
+		}
+		pnl := (tr.Price - lot.OpenPrice) * float64(used) * multiple
+		if direction == "short" {
+			pnl = -pnl
+		}
+		openFee := lot.feePerLot * float64(used)
+		closeFee := closeFeePerLot * float64(used)
+		b.entries = append(b.entries, JournalEntry{
+			AccountID:      tr.AccountID,
+			InstanceID:     lot.InstanceID,
+			Symbol:         lot.Symbol,
+			ExchangeID:     firstNonEmpty(lot.ExchangeID, tr.ExchangeID),
+			Direction:      direction,
+			Volume:         used,
+			OpenTradeID:    lot.OpenTradeID,
+			CloseTradeID:   strings.TrimSpace(tr.TradeID),
+			OpenTime:       lot.OpenTime,
+			CloseTime:      tr.TradeTime,
+			OpenPrice:      lot.OpenPrice,
+			ClosePrice:     tr.Price,
+			RealizedPnL:    pnl,
+			OpenFee:        openFee,
+			CloseFee:       closeFee,
+			NetPnL:         pnl - openFee - closeFee,
+			HoldingSeconds: int64(tr.TradeTime.Sub(lot.OpenTime) / time.Second),
+		})
+		lot.Remaining -= used
+		lot.OpenFee = lot.feePerLot * float64(lot.Remaining)
+		remaining -= used
+	}
+	if remaining > 0 {
+		b.entries = append(b.entries, JournalEntry{
+			AccountID:    tr.AccountID,
+			InstanceID:   InstanceIDFromClientTag(tr.ClientTag),
+			Symbol:       tr.Symbol,
+			ExchangeID:   tr.ExchangeID,
+			Direction:    direction,
+			Volume:       remaining,
+			CloseTradeID: strings.TrimSpace(tr.TradeID),
+			CloseTime:    tr.TradeTime,
+			ClosePrice:   tr.Price,
+			CloseFee:     closeFeePerLot * float64(remaining),
+			NetPnL:       -closeFeePerLot * float64(remaining),
+			Unmatched:    true,
+		})
+	}
+	kept := lots[:0]
+	for _, lot := range lots {
+		if lot.Remaining > 0 {
+			kept = append(kept, lot)
+		}
+	}
+	b.open[key] = kept
+}
+
+// matchOrder 返回平仓消耗批次的下标顺序：平今只配当日批次、平昨只配往日批次，其余按 FIFO/LIFO；
+// 指定的今昨批次不足时再回落到其余批次，避免历史成交缺失导致整笔无法配对。
+func (b *LotBook) matchOrder(lots []PositionLot, tr JournalTrade) []int {
+	preferred := make([]int, 0, len(lots))
+	fallback := make([]int, 0, len(lots))
+	for i, lot := range lots {
+		switch {
+		case tr.OffsetFlag == "close_today" && tr.TradingDay != "" && lot.TradingDay != tr.TradingDay,
+			tr.OffsetFlag == "close_yesterday" && tr.TradingDay != "" && lot.TradingDay == tr.TradingDay:
+			fallback = append(fallback, i)
+		default:
+			preferred = append(preferred, i)
+		}
+	}
+	if b.method == LotMatchLIFO {
+		reverseInts(preferred)
+		reverseInts(fallback)
+	}
+	return append(preferred, fallback...)
+}
+
+func reverseInts(items []int) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// Entries 返回已产生的配对日志。
+func (b *LotBook) Entries() []JournalEntry {
+	return append([]JournalEntry(nil), b.entries...)
+}
+
+// OpenLots 返回尚未平完的批次，按开仓时间排序。
+func (b *LotBook) OpenLots() []PositionLot {
+	out := make([]PositionLot, 0)
+	for _, lots := range b.open {
+		out = append(out, lots...)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].OpenTime.Equal(out[j].OpenTime) {
+			return out[i].OpenTime.Before(out[j].OpenTime)
+		}
+		return out[i].OpenTradeID < out[j].OpenTradeID
+	})
+	return out
+}
+
+// ApplyExcursions 用持仓期间的 K 线高低价计算 MAE/MFE；开平价本身也计入极值。
+func ApplyExcursions(entry *JournalEntry, bars []ExcursionBar, volumeMultiple float64) {
+	if entry.Unmatched || entry.OpenTime.IsZero() {
+		return
+	}
+	if volumeMultiple <= 0 {
+		volumeMultiple = 1
+	}
+	high := math.Max(entry.OpenPrice, entry.ClosePrice)
+	low := math.Min(entry.OpenPrice, entry.ClosePrice)
+	from := entry.OpenTime.Truncate(time.Minute)
+	for _, bar := range bars {
+		if bar.Time.Before(from) || bar.Time.After(entry.CloseTime) {
+			continue
+		}
+		if bar.High > 0 {
+			high = math.Max(high, bar.High)
+		}
+		if bar.Low > 0 {
+			low = math.Min(low, bar.Low)
+		}
+	}
+	scale := float64(entry.Volume) * volumeMultiple
+	if entry.Direction == "short" {
+		entry.MFE = (entry.OpenPrice - low) * scale
+		entry.MAE = (high - entry.OpenPrice) * scale
+		return
+	}
+	entry.MFE = (high - entry.OpenPrice) * scale
+	entry.MAE = (entry.OpenPrice - low) * scale
+}
+
+// FilterJournal 按实例、合约和平仓时间筛选日志。
+func FilterJournal(entries []JournalEntry, q JournalQuery) []JournalEntry {
+	out := make([]JournalEntry, 0, len(entries))
+	for _, item := range entries {
+		if q.InstanceID != "" && item.InstanceID != q.InstanceID {
+			continue
+		}
+		if q.Symbol != "" && !strings.EqualFold(item.Symbol, q.Symbol) {
+			continue
+		}
+		if !q.From.IsZero() && item.CloseTime.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && item.CloseTime.After(q.To) {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+var journalCSVHeader = []string{
+	"account_id", "instance_id", "symbol", "exchange_id", "direction", "volume",
+	"open_trade_id", "close_trade_id", "open_time", "close_time", "open_price", "close_price",
+	"realized_pnl", "open_fee", "close_fee", "net_pnl", "holding_seconds", "mae", "mfe", "unmatched",
+}
+
+// JournalCSV 把交易日志编码为 CSV。
+func JournalCSV(entries []JournalEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(journalCSVHeader); err != nil {
+		return nil, err
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05")
+	}
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, item := range entries {
+		if err := w.Write([]string{
+			item.AccountID, item.InstanceID, item.Symbol, item.ExchangeID, item.Direction, strconv.Itoa(item.Volume),
+			item.OpenTradeID, item.CloseTradeID, formatTime(item.OpenTime), formatTime(item.CloseTime),
+			formatFloat(item.OpenPrice), formatFloat(item.ClosePrice),
+			formatFloat(item.RealizedPnL), formatFloat(item.OpenFee), formatFloat(item.CloseFee), formatFloat(item.NetPnL),
+			strconv.FormatInt(item.HoldingSeconds, 10), formatFloat(item.MAE), formatFloat(item.MFE), strconv.FormatBool(item.Unmatched),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// SetLotBarProvider 注册计算 MAE/MFE 使用的 1m K 线来源。
+func (s *Service) SetLotBarProvider(fn LotBarFunc) {
+	s.algoMu.Lock()
+	s.lotBars = fn
+	s.algoMu.Unlock()
+}
+
+// buildLotBook 从账户全部成交回放批次账本；to 非零时只回放 to 之前的成交。
+func (s *Service) buildLotBook(to time.Time) (*LotBook, error) {
+	trades, err := s.store.ListJournalTrades(s.accountID, to)
+	if err != nil {
+		return nil, err
+	}
+	book := NewLotBook(s.cfg.LotMatching, s.contractVolumeMultiple)
+	for _, tr := range trades {
+		tr.Commission = s.tradeCommission(tr.TradeRecord)
+		book.Apply(tr)
+	}
+	return book, nil
+}
+
+// OpenLots 返回当前未平的开仓批次。
+func (s *Service) OpenLots() ([]PositionLot, error) {
+	book, err := s.buildLotBook(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return book.OpenLots(), nil
+}
+
+// Journal 返回按条件筛选的交易日志，并在有 K 线来源时补充 MAE/MFE。
+func (s *Service) Journal(q JournalQuery) ([]JournalEntry, error) {
+	book, err := s.buildLotBook(q.To)
+	if err != nil {
+		return nil, err
+	}
+	entries := FilterJournal(book.Entries(), q)
+	s.algoMu.Lock()
+	barsFn := s.lotBars
+	s.algoMu.Unlock()
+	if barsFn == nil {
+		return entries, nil
+	}
+	bySymbol := make(map[string][]int)
+	for i, item := range entries {
+		if !item.Unmatched {
+			bySymbol[item.Symbol] = append(bySymbol[item.Symbol], i)
+		}
+	}
+	for symbol, idxs := range bySymbol {
+		from, to := entries[idxs[0]].OpenTime, entries[idxs[0]].CloseTime
+		for _, i := range idxs {
+			if entries[i].OpenTime.Before(from) {
+				from = entries[i].OpenTime
+			}
+			if entries[i].CloseTime.After(to) {
+				to = entries[i].CloseTime
+			}
+		}
+		bars, err := barsFn(symbol, from, to)
+		if err != nil {
+			logger.Warn("load bars for trade journal excursions failed", "symbol", symbol, "error", err)
+			continue
+		}
+		for _, i := range idxs {
+			ApplyExcursions(&entries[i], bars, s.contractVolumeMultiple(entries[i].Symbol, entries[i].ExchangeID))
+		}
+	}
+	return entries, nil
+}
+
+// TradeCommission 返回单笔成交的手续费，口径与交易日志一致：模拟盘按模拟费率，实盘按合约费率。
+func (s *Service) TradeCommission(tr TradeRecord) float64 {
+	return s.tradeCommission(tr)
+}
+
+// tradeCommission 估算单笔成交手续费：模拟盘沿用模拟费率，实盘按已同步的合约费率计算。
+func (s *Service) tradeCommission(tr TradeRecord) float64 {
+	if s.paper {
+		return PaperCommission(tr)
+	}
+	if s.rateCatalog == nil {
+		return 0
+	}
+	rate, ok, err := s.rateCatalog.commissionRate(tr.Symbol, tr.ExchangeID)
+	if err != nil || !ok {
+		return 0
+	}
+	notional := tr.Price * float64(tr.Volume) * s.contractVolumeMultiple(tr.Symbol, tr.ExchangeID)
+	switch tr.OffsetFlag {
+	case "open":
+		return notional*rate.OpenRatioByMoney + float64(tr.Volume)*rate.OpenRatioByVolume
+	case "close_today":
+		return notional*rate.CloseTodayRatioByMoney + float64(tr.Volume)*rate.CloseTodayRatioByVolume
+	default:
+		return notional*rate.CloseRatioByMoney + float64(tr.Volume)*rate.CloseRatioByVolume
+	}
+}
@@ -0,0 +1,136 @@
+package trade
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func journalTrade(id string, direction string, offset string, price float64, volume int, at time.Time, tradingDay string, tag string) JournalTrade {
+	return JournalTrade{
+		TradeRecord: TradeRecord{
+			AccountID:  "acc",
+			TradeID:    id,
+			ExchangeID: "SHFE",
+			Symbol:     "rb2405",
+			Direction:  direction,
+			OffsetFlag: offset,
+			Price:      price,
+			Volume:     volume,
+			TradeTime:  at,
+			TradingDay: tradingDay,
+		},
+		ClientTag:  tag,
+		Commission: float64(volume),
+	}
+}
+
+func TestLotBookMatchesFIFOAndLIFO(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	trades := []JournalTrade{
+		journalTrade("o1", "buy", "open", 3900, 2, start, "20260302", "strategy-auto-inst1"),
+		journalTrade("o2", "buy", "open", 3910, 2, start.Add(time.Minute), "20260302", "strategy-auto-inst1"),
+		journalTrade("c1", "sell", "close", 3920, 3, start.Add(5*time.Minute), "20260302", "strategy-auto-inst1"),
+	}
+	multiple := func(string, string) float64 { return 10 }
+
+	fifo := NewLotBook(LotMatchFIFO, multiple)
+	for _, tr := range trades {
+		fifo.Apply(tr)
+	}
+	entries := fifo.Entries()
+	if len(entries) != 2 || entries[0].OpenTradeID != "o1" || entries[0].Volume != 2 || entries[1].OpenTradeID != "o2" || entries[1].Volume != 1 {
+		t.Fatalf("fifo entries = %+v", entries)
+	}
+	if entries[0].RealizedPnL != 400 || entries[0].OpenFee != 2 || entries[0].CloseFee != 2 || entries[0].NetPnL != 396 {
+		t.Fatalf("fifo first entry pnl/fees = %+v", entries[0])
+	}
+	if entries[0].HoldingSeconds != 300 || entries[0].InstanceID != "inst1" {
+		t.Fatalf("fifo first entry holding/instance = %+v", entries[0])
+	}
+	lots := fifo.OpenLots()
+	if len(lots) != 1 || lots[0].OpenTradeID != "o2" || lots[0].Remaining != 1 || lots[0].OpenFee != 1 {
+		t.Fatalf("fifo open lots = %+v", lots)
+	}
+
+	lifo := NewLotBook(LotMatchLIFO, multiple)
+	for _, tr := range trades {
+		lifo.Apply(tr)
+	}
+	entries = lifo.Entries()
+	if len(entries) != 2 || entries[0].OpenTradeID != "o2" || entries[0].Volume != 2 || entries[1].OpenTradeID != "o1" || entries[1].Volume != 1 {
+		t.Fatalf("lifo entries = %+v", entries)
+	}
+	if entries[0].RealizedPnL != 200 {
+		t.Fatalf("lifo first entry pnl = %v, want 200", entries[0].RealizedPnL)
+	}
+}
+
+func TestLotBookCloseTodayPrefersSameTradingDay(t *testing.T) {
+	t.Parallel()
+
+	day1 := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	book := NewLotBook(LotMatchFIFO, nil)
+	book.Apply(journalTrade("y1", "sell", "open", 3950, 1, day1, "20260302", ""))
+	book.Apply(journalTrade("t1", "sell", "open", 3940, 1, day2, "20260303", ""))
+	book.Apply(journalTrade("c1", "buy", "close_today", 3930, 1, day2.Add(time.Hour), "20260303", ""))
+	entries := book.Entries()
+	if len(entries) != 1 || entries[0].OpenTradeID != "t1" || entries[0].Direction != "short" || entries[0].RealizedPnL != 10 {
+		t.Fatalf("close_today entries = %+v", entries)
+	}
+	book.Apply(journalTrade("c2", "buy", "close_yesterday", 3930, 2, day2.Add(2*time.Hour), "20260303", ""))
+	entries = book.Entries()
+	if len(entries) != 3 || entries[1].OpenTradeID != "y1" || !entries[2].Unmatched || entries[2].Volume != 1 {
+		t.Fatalf("close_yesterday entries = %+v", entries)
+	}
+	if len(book.OpenLots()) != 0 {
+		t.Fatalf("open lots = %+v, want none", book.OpenLots())
+	}
+}
+
+func TestApplyExcursionsUsesBarsWithinHolding(t *testing.T) {
+	t.Parallel()
+
+	open := time.Date(2026, 3, 2, 9, 0, 30, 0, time.Local)
+	entry := JournalEntry{Direction: "long", Volume: 2, OpenTime: open, CloseTime: open.Add(3 * time.Minute), OpenPrice: 3900, ClosePrice: 3905}
+	bars := []ExcursionBar{
+		{Time: open.Add(-time.Hour), High: 4000, Low: 3800},
+		{Time: open.Truncate(time.Minute), High: 3903, Low: 3895},
+		{Time: open.Add(90 * time.Second), High: 3912, Low: 3898},
+	}
+	ApplyExcursions(&entry, bars, 10)
+	if entry.MFE != 240 || entry.MAE != 100 {
+		t.Fatalf("long excursions = mae %v mfe %v, want 100/240", entry.MAE, entry.MFE)
+	}
+	entry.Direction = "short"
+	ApplyExcursions(&entry, bars, 10)
+	if entry.MFE != 100 || entry.MAE != 240 {
+		t.Fatalf("short excursions = mae %v mfe %v, want 240/100", entry.MAE, entry.MFE)
+	}
+}
+
+func TestFilterJournalAndCSV(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, time.Local)
+	entries := []JournalEntry{
+		{AccountID: "acc", InstanceID: "inst1", Symbol: "rb2405", Volume: 1, CloseTime: at, NetPnL: 12.5},
+		{AccountID: "acc", InstanceID: "inst2", Symbol: "rb2405", Volume: 1, CloseTime: at},
+		{AccountID: "acc", InstanceID: "inst1", Symbol: "rb2405", Volume: 1, CloseTime: at.AddDate(0, 0, 1)},
+	}
+	got := FilterJournal(entries, JournalQuery{InstanceID: "inst1", To: at.Add(time.Hour)})
+	if len(got) != 1 {
+		t.Fatalf("filtered = %+v", got)
+	}
+	body, err := JournalCSV(got)
+	if err != nil {
+		t.Fatalf("JournalCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "account_id,instance_id,") || !strings.Contains(lines[1], ",12.5,") {
+		t.Fatalf("csv = %q", body)
+	}
+}
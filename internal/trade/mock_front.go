@@ -0,0 +1,943 @@
+// mock_front.go 提供进程内的模拟 CTP 交易前置。
+// MockTraderFront 实现 ctp-go 的 CThostFtdcTraderApi 接口，直接替换在 ctp-go 绑定层之下，
+// 因此 CTPGateway、回调适配器和各查询 lane 走的都是真实代码路径；
+// 报单受理、部分成交、拒单、流控、断线和结算确认都可以按测试脚本编排。
+package trade
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	ctp "github.com/kkqy/ctp-go"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const (
+	// MockFlowControlRet 是 CTP 查询流控时 Req* 的返回值（每秒请求数超限）。
+	MockFlowControlRet = -3
+	// MockNotConnectedRet 是断线期间 Req* 的返回值（网络连接失败）。
+	MockNotConnectedRet = -1
+
+	// mockSettlementChunkSize 是结算单单条回报的最大字节数，与 CTP Content 字段长度一致。
+	mockSettlementChunkSize = 500
+)
+
+// MockFrontConfig 是模拟前置的登录阶段行为。
+type MockFrontConfig struct {
+	// TradingDay 是登录返回的交易日，默认取当天。
+	TradingDay string
+	// AuthError 非空时认证失败。
+	AuthError string
+	// LoginError 非空时登录失败。
+	LoginError string
+	// SettlementConfirmError 非空时结算确认失败。
+	SettlementConfirmError string
+}
+
+// MockOrderRequest 是柜台收到的报单，供 MockOrderPlanner 决定处理方式。
+type MockOrderRequest struct {
+	FrontID    int
+	SessionID  int
+	OrderRef   string
+	Symbol     string
+	ExchangeID string
+	Direction  string
+	OffsetFlag string
+	LimitPrice float64
+	Volume     int
+}
+
+// MockOrderPlan 描述一笔报单到达柜台后的处理脚本。
+type MockOrderPlan struct {
+	// RejectMsg 非空时柜台拒单，走 OnErrRtnOrderInsert。
+	RejectMsg string
+	// Fills 是报单受理后依次回报的成交手数，合计小于委托量时剩余部分继续挂单。
+	Fills []int
+	// FillPrice 是成交价，0 表示按委托价成交。
+	FillPrice float64
+	// CancelRest 表示成交回报后撤销剩余挂单，模拟 FAK。
+	CancelRest bool
+}
+
+// MockOrderPlanner 按报单内容返回处理脚本；未设置时报单只受理挂单，不自动成交。
+type MockOrderPlanner func(MockOrderRequest) MockOrderPlan
+
+type mockOrder struct {
+	req        MockOrderRequest
+	orderSysID string
+	traded     int
+	canceled   bool
+	status     byte
+	statusMsg  string
+	insertedAt time.Time
+	updatedAt  time.Time
+}
+
+type mockQueryFailure struct {
+	ret      int
+	errorID  int
+	errorMsg string
+}
+
+type MockTraderFront struct {
+	mu  sync.Mutex
+	cfg MockFrontConfig
+
+	sessions    []*mockTraderSession
+	sessionSeq  int
+	orderSysSeq int
+	tradeSeq    int
+
+	orders    []*mockOrder
+	trades    []TradeRecord
+	account   TradingAccountSnapshot
+	positions map[string]*PositionSnapshot
+
+	commissionRates []CommissionRateSnapshot
+	marginRates     []MarginRateSnapshot
+	// settlements 按交易日保存 GBK 编码的结算单原文。
+	settlements map[string]string
+
+	planner      MockOrderPlanner
+	failures     map[string][]mockQueryFailure
+	requests     map[string]int
+	confirmCount int
+	disconnected bool
+}
+
+func NewMockTraderFront(cfg MockFrontConfig) *MockTraderFront {
+	if strings.TrimSpace(cfg.TradingDay) == "" {
+		cfg.TradingDay = time.Now().Format("20060102")
+	}
+	return &MockTraderFront{
+		cfg:         cfg,
+		positions:   make(map[string]*PositionSnapshot),
+		settlements: make(map[string]string),
+		failures:    make(map[string][]mockQueryFailure),
+		requests:    make(map[string]int),
+	}
+}
+
+// Factory 返回可注入 CTPGateway/Service 的 Trader API 工厂，每次调用对应一个独立会话。
+func (f *MockTraderFront) Factory() TraderAPIFactory {
+	return func(string) ctp.CThostFtdcTraderApi {
+		f.mu.Lock()
+		f.sessionSeq++
+		s := &mockTraderSession{
+			front:     f,
+			frontID:   1,
+			sessionID: f.sessionSeq,
+			events:    make(chan func(), 256),
+			done:      make(chan struct{}),
+		}
+		f.sessions = append(f.sessions, s)
+		f.mu.Unlock()
+		go s.run()
+		return s
+	}
+}
+
+// SetOrderPlanner 设置报单处理脚本。
+func (f *MockTraderFront) SetOrderPlanner(fn MockOrderPlanner) {
+	f.mu.Lock()
+	f.planner = fn
+	f.mu.Unlock()
+}
+
+func (f *MockTraderFront) SetAccount(item TradingAccountSnapshot) {
+	f.mu.Lock()
+	f.account = item
+	f.mu.Unlock()
+}
+
+// SetPositions 覆盖柜台持仓，之后的成交在此基础上增减。
+func (f *MockTraderFront) SetPositions(items []PositionSnapshot) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.positions = make(map[string]*PositionSnapshot, len(items))
+	for _, item := range items {
+		cp := item
+		f.positions[mockPositionKey(cp.Symbol, cp.Direction)] = &cp
+	}
+}
+
+func (f *MockTraderFront) SetCommissionRates(items []CommissionRateSnapshot) {
+	f.mu.Lock()
+	f.commissionRates = append([]CommissionRateSnapshot(nil), items...)
+	f.mu.Unlock()
+}
+
+func (f *MockTraderFront) SetMarginRates(items []MarginRateSnapshot) {
+	f.mu.Lock()
+	f.marginRates = append([]MarginRateSnapshot(nil), items...)
+	f.mu.Unlock()
+}
+
+// SetSettlement 设置某交易日的结算单正文（UTF-8），查询时按 CTP 习惯以 GBK 分片回报。
+func (f *MockTraderFront) SetSettlement(tradingDay string, content string) error {
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String(content)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.settlements[strings.TrimSpace(tradingDay)] = encoded
+	f.mu.Unlock()
+	return nil
+}
+
+// FailQueryReturn 让接下来 times 次 kind 查询在 Req* 阶段直接返回 ret，如 MockFlowControlRet。
+// kind 与网关等待查询时使用的名称一致：account、positions、orders、trades、commission_rates、margin_rates、settlement_info。
+func (f *MockTraderFront) FailQueryReturn(kind string, ret int, times int) {
+	f.addFailure(kind, mockQueryFailure{ret: ret}, times)
+}
+
+// FailQueryResponse 让接下来 times 次 kind 查询在回报中携带柜台错误。
+func (f *MockTraderFront) FailQueryResponse(kind string, errorID int, errorMsg string, times int) {
+	f.addFailure(kind, mockQueryFailure{errorID: errorID, errorMsg: errorMsg}, times)
+}
+
+func (f *MockTraderFront) addFailure(kind string, item mockQueryFailure, times int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := 0; i < times; i++ {
+		f.failures[kind] = append(f.failures[kind], item)
+	}
+}
+
+// Disconnect 模拟前置断线：所有会话收到 OnFrontDisconnected，此后请求返回 MockNotConnectedRet。
+func (f *MockTraderFront) Disconnect(reason int) {
+	f.mu.Lock()
+	f.disconnected = true
+	sessions := append([]*mockTraderSession(nil), f.sessions...)
+	f.mu.Unlock()
+	for _, s := range sessions {
+		s.post(func() { s.spi.OnFrontDisconnected(reason) })
+	}
+}
+
+// Reconnect 模拟前置恢复，所有会话重新收到 OnFrontConnected；是否重新登录由上层决定。
+func (f *MockTraderFront) Reconnect() {
+	f.mu.Lock()
+	f.disconnected = false
+	sessions := append([]*mockTraderSession(nil), f.sessions...)
+	f.mu.Unlock()
+	for _, s := range sessions {
+		s.post(func() { s.spi.OnFrontConnected() })
+	}
+}
+
+// FillOrder 让挂单按 OrderRef 成交 volume 手，price 为 0 时按委托价。
+func (f *MockTraderFront) FillOrder(orderRef string, volume int, price float64) error {
+	f.mu.Lock()
+	order := f.findOrderLocked(strings.TrimSpace(orderRef))
+	if order == nil {
+		f.mu.Unlock()
+		return fmt.Errorf("mock order %s not found", orderRef)
+	}
+	session := f.sessionLocked(order.req.SessionID)
+	f.mu.Unlock()
+	if session == nil {
+		return fmt.Errorf("mock session %d not found", order.req.SessionID)
+	}
+	session.post(func() { session.fill(order, volume, price) })
+	return nil
+}
+
+// SettlementConfirmCount 返回累计收到的结算确认次数。
+func (f *MockTraderFront) SettlementConfirmCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.confirmCount
+}
+
+// RequestCount 返回某类请求累计次数，kind 与 FailQueryReturn 一致，另有 order_insert、order_action。
+func (f *MockTraderFront) RequestCount(kind string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[kind]
+}
+
+// Trades 返回柜台已回报的全部成交。
+func (f *MockTraderFront) Trades() []TradeRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]TradeRecord(nil), f.trades...)
+}
+
+// Positions 返回柜台当前持仓，按合约和方向排序。
+func (f *MockTraderFront) Positions() []PositionSnapshot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.positionsLocked()
+}
+
+func (f *MockTraderFront) positionsLocked() []PositionSnapshot {
+	out := make([]PositionSnapshot, 0, len(f.positions))
+	for _, item := range f.positions {
+		if item.Position <= 0 {
+			continue
+		}
+		out = append(out, *item)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Direction < out[j].Direction
+	})
+	return out
+}
+
+func (f *MockTraderFront) findOrderLocked(orderRef string) *mockOrder {
+	for i := len(f.orders) - 1; i >= 0; i-- {
+		if strings.TrimSpace(f.orders[i].req.OrderRef) == orderRef {
+			return f.orders[i]
+		}
+	}
+	return nil
+}
+
+func (f *MockTraderFront) findOrderBySysIDLocked(orderSysID string) *mockOrder {
+	for _, item := range f.orders {
+		if item.orderSysID == orderSysID {
+			return item
+		}
+	}
+	return nil
+}
+
+func (f *MockTraderFront) sessionLocked(sessionID int) *mockTraderSession {
+	for _, s := range f.sessions {
+		if s.sessionID == sessionID {
+			return s
+		}
+	}
+	return nil
+}
+
+// beginRequest 记录请求并返回本次应注入的故障；断线时直接返回网络错误。
+func (f *MockTraderFront) beginRequest(kind string) (mockQueryFailure, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[kind]++
+	if f.disconnected {
+		return mockQueryFailure{ret: MockNotConnectedRet}, true
+	}
+	queue := f.failures[kind]
+	if len(queue) == 0 {
+		return mockQueryFailure{}, false
+	}
+	f.failures[kind] = queue[1:]
+	return queue[0], true
+}
+
+func (f *MockTraderFront) applyFillLocked(req MockOrderRequest, volume int) {
+	switch {
+	case req.OffsetFlag == "open":
+		direction := "long"
+		if req.Direction == "sell" {
+			direction = "short"
+		}
+		key := mockPositionKey(req.Symbol, direction)
+		item := f.positions[key]
+		if item == nil {
+			item = &PositionSnapshot{Symbol: req.Symbol, Exchange: req.ExchangeID, Direction: direction, HedgeFlag: "speculation"}
+			f.positions[key] = item
+		}
+		item.Position += volume
+		item.TodayPosition += volume
+		item.OpenCost += req.LimitPrice * float64(volume)
+		item.PositionCost += req.LimitPrice * float64(volume)
+	default:
+		direction := "short"
+		if req.Direction == "sell" {
+			direction = "long"
+		}
+		item := f.positions[mockPositionKey(req.Symbol, direction)]
+		if item == nil {
+			return
+		}
+		remaining := volume
+		if req.OffsetFlag != "close_today" {
+			fromYd := minInt(remaining, item.YdPosition)
+			item.YdPosition -= fromYd
+			remaining -= fromYd
+		}
+		item.TodayPosition -= minInt(remaining, item.TodayPosition)
+		item.Position = maxInt(0, item.Position-volume)
+	}
+}
+
+func mockPositionKey(symbol string, direction string) string {
+	return strings.ToLower(strings.TrimSpace(symbol)) + "|" + strings.ToLower(strings.TrimSpace(direction))
+}
+
+// mockTraderSession 是一次 CreateFtdcTraderApi 对应的会话。未覆盖的接口方法保持未实现，被调用时直接 panic，
+// 以便测试尽早发现网关用到了模拟前置尚不支持的请求。
+type mockTraderSession struct {
+	ctp.CThostFtdcTraderApi
+
+	front     *MockTraderFront
+	frontID   int
+	sessionID int
+	spi       ctp.CThostFtdcTraderSpi
+	// events 串行投递回调，模拟 CTP 回调线程。
+	events    chan func()
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *mockTraderSession) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case fn := <-s.events:
+			fn()
+		}
+	}
+}
+
+func (s *mockTraderSession) post(fn func()) {
+	select {
+	case <-s.done:
+	case s.events <- fn:
+	}
+}
+
+func (s *mockTraderSession) Release() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *mockTraderSession) Init() {
+	s.front.mu.Lock()
+	disconnected := s.front.disconnected
+	s.front.mu.Unlock()
+	if disconnected {
+		return
+	}
+	s.post(func() { s.spi.OnFrontConnected() })
+}
+
+func (s *mockTraderSession) RegisterSpi(spi ctp.CThostFtdcTraderSpi) {
+	s.spi = spi
+}
+
+func (s *mockTraderSession) RegisterFront(string) {}
+
+func (s *mockTraderSession) SubscribePrivateTopic(ctp.THOST_TE_RESUME_TYPE) {}
+
+func (s *mockTraderSession) SubscribePublicTopic(ctp.THOST_TE_RESUME_TYPE) {}
+
+func (s *mockTraderSession) GetTradingDay() string {
+	return s.front.cfg.TradingDay
+}
+
+func (s *mockTraderSession) ReqAuthenticate(_ ctp.CThostFtdcReqAuthenticateField, reqID int) int {
+	if fail, ok := s.front.beginRequest("authenticate"); ok && fail.ret != 0 {
+		return fail.ret
+	}
+	s.post(func() {
+		rsp := newMockRspInfo(s.front.cfg.AuthError)
+		defer deleteMockRspInfo(rsp)
+		s.spi.OnRspAuthenticate(nil, rsp, reqID, true)
+	})
+	return 0
+}
+
+func (s *mockTraderSession) ReqUserLogin(_ ctp.CThostFtdcReqUserLoginField, reqID int) int {
+	if fail, ok := s.front.beginRequest("login"); ok && fail.ret != 0 {
+		return fail.ret
+	}
+	s.post(func() {
+		field := ctp.NewCThostFtdcRspUserLoginField()
+		defer ctp.DeleteCThostFtdcRspUserLoginField(field)
+		field.SetTradingDay(s.front.cfg.TradingDay)
+		field.SetFrontID(s.frontID)
+		field.SetSessionID(s.sessionID)
+		rsp := newMockRspInfo(s.front.cfg.LoginError)
+		defer deleteMockRspInfo(rsp)
+		s.spi.OnRspUserLogin(field, rsp, reqID, true)
+	})
+	return 0
+}
+
+func (s *mockTraderSession) ReqSettlementInfoConfirm(_ ctp.CThostFtdcSettlementInfoConfirmField, reqID int) int {
+	if fail, ok := s.front.beginRequest("settlement_confirm"); ok && fail.ret != 0 {
+		return fail.ret
+	}
+	s.post(func() {
+		msg := s.front.cfg.SettlementConfirmError
+		if msg == "" {
+			s.front.mu.Lock()
+			s.front.confirmCount++
+			s.front.mu.Unlock()
+		}
+		rsp := newMockRspInfo(msg)
+		defer deleteMockRspInfo(rsp)
+		s.spi.OnRspSettlementInfoConfirm(nil, rsp, reqID, true)
+	})
+	return 0
+}
+
+// query 统一处理查询类请求：先看是否注入了故障，再异步逐条回报，空结果时回报一条空记录并标记 last。
+func (s *mockTraderSession) query(kind string, reqID int, count func() int, emit func(i int, rsp ctp.CThostFtdcRspInfoField, last bool)) int {
+	fail, ok := s.front.beginRequest(kind)
+	if ok && fail.ret != 0 {
+		return fail.ret
+	}
+	s.post(func() {
+		if ok && fail.errorID != 0 {
+			rsp := ctp.NewCThostFtdcRspInfoField()
+			defer ctp.DeleteCThostFtdcRspInfoField(rsp)
+			rsp.SetErrorID(fail.errorID)
+			rsp.SetErrorMsg(fail.errorMsg)
+			emit(-1, rsp, true)
+			return
+		}
+		n := count()
+		if n == 0 {
+			emit(-1, nil, true)
+			return
+		}
+		for i := 0; i < n; i++ {
+			emit(i, nil, i == n-1)
+		}
+	})
+	return 0
+}
+
+func (s *mockTraderSession) ReqQryTradingAccount(_ ctp.CThostFtdcQryTradingAccountField, reqID int) int {
+	var item TradingAccountSnapshot
+	return s.query("account", reqID, func() int {
+		s.front.mu.Lock()
+		item = s.front.account
+		s.front.mu.Unlock()
+		return 1
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryTradingAccount(nil, rsp, reqID, last)
+			return
+		}
+		field := ctp.NewCThostFtdcTradingAccountField()
+		defer ctp.DeleteCThostFtdcTradingAccountField(field)
+		field.SetBalance(item.Balance)
+		field.SetAvailable(item.Available)
+		field.SetCurrMargin(item.Margin)
+		field.SetFrozenCash(item.FrozenCash)
+		field.SetCommission(item.Commission)
+		field.SetCloseProfit(item.CloseProfit)
+		field.SetPositionProfit(item.PositionProfit)
+		field.SetTradingDay(s.front.cfg.TradingDay)
+		s.spi.OnRspQryTradingAccount(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQryInvestorPosition(_ ctp.CThostFtdcQryInvestorPositionField, reqID int) int {
+	var items []PositionSnapshot
+	return s.query("positions", reqID, func() int {
+		s.front.mu.Lock()
+		items = s.front.positionsLocked()
+		s.front.mu.Unlock()
+		return len(items)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryInvestorPosition(nil, rsp, reqID, last)
+			return
+		}
+		item := items[i]
+		field := ctp.NewCThostFtdcInvestorPositionField()
+		defer ctp.DeleteCThostFtdcInvestorPositionField(field)
+		field.SetInstrumentID(item.Symbol)
+		field.SetExchangeID(item.Exchange)
+		posDirection := ctp.THOST_FTDC_PD_Long
+		if item.Direction == "short" {
+			posDirection = ctp.THOST_FTDC_PD_Short
+		}
+		field.SetPosiDirection(posDirection)
+		field.SetHedgeFlag(ctp.THOST_FTDC_HF_Speculation)
+		field.SetYdPosition(item.YdPosition)
+		field.SetTodayPosition(item.TodayPosition)
+		field.SetPosition(item.Position)
+		field.SetOpenCost(item.OpenCost)
+		field.SetPositionCost(item.PositionCost)
+		field.SetUseMargin(item.UseMargin)
+		s.spi.OnRspQryInvestorPosition(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQryOrder(_ ctp.CThostFtdcQryOrderField, reqID int) int {
+	var items []mockOrder
+	return s.query("orders", reqID, func() int {
+		s.front.mu.Lock()
+		items = make([]mockOrder, 0, len(s.front.orders))
+		for _, item := range s.front.orders {
+			items = append(items, *item)
+		}
+		s.front.mu.Unlock()
+		return len(items)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryOrder(nil, rsp, reqID, last)
+			return
+		}
+		field := s.newOrderField(&items[i])
+		defer ctp.DeleteCThostFtdcOrderField(field)
+		s.spi.OnRspQryOrder(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQryTrade(_ ctp.CThostFtdcQryTradeField, reqID int) int {
+	var items []TradeRecord
+	return s.query("trades", reqID, func() int {
+		items = s.front.Trades()
+		return len(items)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryTrade(nil, rsp, reqID, last)
+			return
+		}
+		field := newMockTradeField(items[i])
+		defer ctp.DeleteCThostFtdcTradeField(field)
+		s.spi.OnRspQryTrade(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQryInstrumentCommissionRate(req ctp.CThostFtdcQryInstrumentCommissionRateField, reqID int) int {
+	instrumentID := strings.TrimSpace(req.GetInstrumentID())
+	var items []CommissionRateSnapshot
+	return s.query("commission_rates", reqID, func() int {
+		s.front.mu.Lock()
+		for _, item := range s.front.commissionRates {
+			if instrumentID == "" || strings.EqualFold(item.InstrumentID, instrumentID) {
+				items = append(items, item)
+			}
+		}
+		s.front.mu.Unlock()
+		return len(items)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryInstrumentCommissionRate(nil, rsp, reqID, last)
+			return
+		}
+		item := items[i]
+		field := ctp.NewCThostFtdcInstrumentCommissionRateField()
+		defer ctp.DeleteCThostFtdcInstrumentCommissionRateField(field)
+		field.SetInstrumentID(item.InstrumentID)
+		field.SetExchangeID(item.ExchangeID)
+		field.SetOpenRatioByMoney(item.OpenRatioByMoney)
+		field.SetOpenRatioByVolume(item.OpenRatioByVolume)
+		field.SetCloseRatioByMoney(item.CloseRatioByMoney)
+		field.SetCloseRatioByVolume(item.CloseRatioByVolume)
+		field.SetCloseTodayRatioByMoney(item.CloseTodayRatioByMoney)
+		field.SetCloseTodayRatioByVolume(item.CloseTodayRatioByVolume)
+		s.spi.OnRspQryInstrumentCommissionRate(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQryInstrumentMarginRate(req ctp.CThostFtdcQryInstrumentMarginRateField, reqID int) int {
+	instrumentID := strings.TrimSpace(req.GetInstrumentID())
+	var items []MarginRateSnapshot
+	return s.query("margin_rates", reqID, func() int {
+		s.front.mu.Lock()
+		for _, item := range s.front.marginRates {
+			if instrumentID == "" || strings.EqualFold(item.InstrumentID, instrumentID) {
+				items = append(items, item)
+			}
+		}
+		s.front.mu.Unlock()
+		return len(items)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQryInstrumentMarginRate(nil, rsp, reqID, last)
+			return
+		}
+		item := items[i]
+		field := ctp.NewCThostFtdcInstrumentMarginRateField()
+		defer ctp.DeleteCThostFtdcInstrumentMarginRateField(field)
+		field.SetInstrumentID(item.InstrumentID)
+		field.SetExchangeID(item.ExchangeID)
+		field.SetHedgeFlag(ctp.THOST_FTDC_HF_Speculation)
+		field.SetLongMarginRatioByMoney(item.LongMarginRatioByMoney)
+		field.SetLongMarginRatioByVolume(item.LongMarginRatioByVolume)
+		field.SetShortMarginRatioByMoney(item.ShortMarginRatioByMoney)
+		field.SetShortMarginRatioByVolume(item.ShortMarginRatioByVolume)
+		field.SetIsRelative(boolToInt(item.IsRelative))
+		s.spi.OnRspQryInstrumentMarginRate(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqQrySettlementInfo(req ctp.CThostFtdcQrySettlementInfoField, reqID int) int {
+	tradingDay := strings.TrimSpace(req.GetTradingDay())
+	var chunks []string
+	return s.query("settlement_info", reqID, func() int {
+		s.front.mu.Lock()
+		if tradingDay == "" {
+			// 未指定交易日时取最近一个结算日。
+			for day := range s.front.settlements {
+				if day > tradingDay {
+					tradingDay = day
+				}
+			}
+		}
+		content := s.front.settlements[tradingDay]
+		s.front.mu.Unlock()
+		for len(content) > 0 {
+			n := minInt(mockSettlementChunkSize, len(content))
+			chunks = append(chunks, content[:n])
+			content = content[n:]
+		}
+		return len(chunks)
+	}, func(i int, rsp ctp.CThostFtdcRspInfoField, last bool) {
+		if i < 0 {
+			s.spi.OnRspQrySettlementInfo(nil, rsp, reqID, last)
+			return
+		}
+		field := ctp.NewCThostFtdcSettlementInfoField()
+		defer ctp.DeleteCThostFtdcSettlementInfoField(field)
+		field.SetTradingDay(tradingDay)
+		field.SetSequenceNo(i + 1)
+		field.SetContent(chunks[i])
+		s.spi.OnRspQrySettlementInfo(field, rsp, reqID, last)
+	})
+}
+
+func (s *mockTraderSession) ReqOrderInsert(field ctp.CThostFtdcInputOrderField, reqID int) int {
+	if fail, ok := s.front.beginRequest("order_insert"); ok && fail.ret != 0 {
+		return fail.ret
+	}
+	// 网关在 Req* 返回后即释放请求结构，必须在这里同步拷贝。
+	req := MockOrderRequest{
+		FrontID:    s.frontID,
+		SessionID:  s.sessionID,
+		OrderRef:   strings.TrimSpace(field.GetOrderRef()),
+		Symbol:     strings.TrimSpace(field.GetInstrumentID()),
+		ExchangeID: strings.TrimSpace(field.GetExchangeID()),
+		Direction:  mapDirectionText(field.GetDirection()),
+		OffsetFlag: mapOffsetFlagText(firstByte(field.GetCombOffsetFlag())),
+		LimitPrice: field.GetLimitPrice(),
+		Volume:     field.GetVolumeTotalOriginal(),
+	}
+	s.front.mu.Lock()
+	planner := s.front.planner
+	s.front.mu.Unlock()
+	var plan MockOrderPlan
+	if planner != nil {
+		plan = planner(req)
+	}
+	s.post(func() { s.handleOrderInsert(req, plan, reqID) })
+	return 0
+}
+
+func (s *mockTraderSession) handleOrderInsert(req MockOrderRequest, plan MockOrderPlan, reqID int) {
+	if plan.RejectMsg != "" {
+		input := ctp.NewCThostFtdcInputOrderField()
+		defer ctp.DeleteCThostFtdcInputOrderField(input)
+		input.SetOrderRef(req.OrderRef)
+		input.SetInstrumentID(req.Symbol)
+		input.SetExchangeID(req.ExchangeID)
+		input.SetDirection(mapDirection(req.Direction))
+		input.SetCombOffsetFlag(string(mapOffsetFlag(req.OffsetFlag)))
+		input.SetLimitPrice(req.LimitPrice)
+		input.SetVolumeTotalOriginal(req.Volume)
+		rsp := newMockRspInfo(plan.RejectMsg)
+		defer deleteMockRspInfo(rsp)
+		s.spi.OnRspOrderInsert(input, rsp, reqID, true)
+		s.spi.OnErrRtnOrderInsert(input, rsp)
+		return
+	}
+	now := time.Now()
+	s.front.mu.Lock()
+	s.front.orderSysSeq++
+	order := &mockOrder{
+		req:        req,
+		orderSysID: strconv.Itoa(s.front.orderSysSeq),
+		status:     ctp.THOST_FTDC_OST_NoTradeQueueing,
+		statusMsg:  "未成交",
+		insertedAt: now,
+		updatedAt:  now,
+	}
+	s.front.orders = append(s.front.orders, order)
+	s.front.mu.Unlock()
+	s.emitOrder(order)
+	for _, volume := range plan.Fills {
+		s.fill(order, volume, plan.FillPrice)
+	}
+	if plan.CancelRest {
+		s.cancel(order)
+	}
+}
+
+// fill 在会话回调线程内执行一次成交回报：先 OnRtnOrder 更新状态，再 OnRtnTrade，与 CTP 实际顺序一致。
+func (s *mockTraderSession) fill(order *mockOrder, volume int, price float64) {
+	s.front.mu.Lock()
+	if order.canceled || order.traded >= order.req.Volume {
+		s.front.mu.Unlock()
+		return
+	}
+	volume = minInt(volume, order.req.Volume-order.traded)
+	if volume <= 0 {
+		s.front.mu.Unlock()
+		return
+	}
+	if price <= 0 {
+		price = order.req.LimitPrice
+	}
+	order.traded += volume
+	order.updatedAt = time.Now()
+	if order.traded >= order.req.Volume {
+		order.status = ctp.THOST_FTDC_OST_AllTraded
+		order.statusMsg = "全部成交"
+	} else {
+		order.status = ctp.THOST_FTDC_OST_PartTradedQueueing
+		order.statusMsg = "部分成交"
+	}
+	s.front.tradeSeq++
+	trade := TradeRecord{
+		TradeID:    strconv.Itoa(s.front.tradeSeq),
+		OrderRef:   order.req.OrderRef,
+		OrderSysID: order.orderSysID,
+		ExchangeID: order.req.ExchangeID,
+		Symbol:     order.req.Symbol,
+		Direction:  order.req.Direction,
+		OffsetFlag: order.req.OffsetFlag,
+		Price:      price,
+		Volume:     volume,
+		TradeTime:  order.updatedAt,
+		TradingDay: s.front.cfg.TradingDay,
+	}
+	s.front.trades = append(s.front.trades, trade)
+	s.front.applyFillLocked(order.req, volume)
+	s.front.mu.Unlock()
+	s.emitOrder(order)
+	field := newMockTradeField(trade)
+	defer ctp.DeleteCThostFtdcTradeField(field)
+	s.spi.OnRtnTrade(field)
+}
+
+func (s *mockTraderSession) cancel(order *mockOrder) bool {
+	s.front.mu.Lock()
+	if order.canceled || order.traded >= order.req.Volume {
+		s.front.mu.Unlock()
+		return false
+	}
+	order.canceled = true
+	order.status = ctp.THOST_FTDC_OST_Canceled
+	order.statusMsg = "已撤单"
+	order.updatedAt = time.Now()
+	s.front.mu.Unlock()
+	s.emitOrder(order)
+	return true
+}
+
+func (s *mockTraderSession) ReqOrderAction(field ctp.CThostFtdcInputOrderActionField, reqID int) int {
+	if fail, ok := s.front.beginRequest("order_action"); ok && fail.ret != 0 {
+		return fail.ret
+	}
+	orderRef := strings.TrimSpace(field.GetOrderRef())
+	orderSysID := strings.TrimSpace(field.GetOrderSysID())
+	exchangeID := strings.TrimSpace(field.GetExchangeID())
+	s.post(func() {
+		s.front.mu.Lock()
+		var order *mockOrder
+		if orderSysID != "" {
+			order = s.front.findOrderBySysIDLocked(orderSysID)
+		}
+		if order == nil && orderRef != "" {
+			order = s.front.findOrderLocked(orderRef)
+		}
+		s.front.mu.Unlock()
+		if order != nil && s.cancel(order) {
+			return
+		}
+		msg := "CTP:报单已全部成交或已撤销，不能再撤"
+		if order == nil {
+			msg = "CTP:撤单找不到相应报单"
+		}
+		action := ctp.NewCThostFtdcOrderActionField()
+		defer ctp.DeleteCThostFtdcOrderActionField(action)
+		action.SetOrderRef(orderRef)
+		action.SetOrderSysID(orderSysID)
+		action.SetExchangeID(exchangeID)
+		rsp := newMockRspInfo(msg)
+		defer deleteMockRspInfo(rsp)
+		s.spi.OnRspOrderAction(nil, rsp, reqID, true)
+		s.spi.OnErrRtnOrderAction(action, rsp)
+	})
+	return 0
+}
+
+func (s *mockTraderSession) emitOrder(order *mockOrder) {
+	s.front.mu.Lock()
+	snapshot := *order
+	s.front.mu.Unlock()
+	field := s.newOrderField(&snapshot)
+	defer ctp.DeleteCThostFtdcOrderField(field)
+	s.spi.OnRtnOrder(field)
+}
+
+func (s *mockTraderSession) newOrderField(order *mockOrder) ctp.CThostFtdcOrderField {
+	field := ctp.NewCThostFtdcOrderField()
+	field.SetOrderRef(order.req.OrderRef)
+	field.SetFrontID(order.req.FrontID)
+	field.SetSessionID(order.req.SessionID)
+	field.SetExchangeID(order.req.ExchangeID)
+	field.SetOrderSysID(order.orderSysID)
+	field.SetInstrumentID(order.req.Symbol)
+	field.SetDirection(mapDirection(order.req.Direction))
+	field.SetCombOffsetFlag(string(mapOffsetFlag(order.req.OffsetFlag)))
+	field.SetLimitPrice(order.req.LimitPrice)
+	field.SetVolumeTotalOriginal(order.req.Volume)
+	field.SetVolumeTraded(order.traded)
+	remaining := order.req.Volume - order.traded
+	if order.canceled {
+		remaining = 0
+	}
+	field.SetVolumeTotal(remaining)
+	field.SetOrderStatus(order.status)
+	field.SetOrderSubmitStatus(ctp.THOST_FTDC_OSS_Accepted)
+	field.SetStatusMsg(order.statusMsg)
+	field.SetInsertDate(order.insertedAt.Format("20060102"))
+	field.SetInsertTime(order.insertedAt.Format("15:04:05"))
+	field.SetUpdateTime(order.updatedAt.Format("15:04:05"))
+	return field
+}
+
+func newMockTradeField(item TradeRecord) ctp.CThostFtdcTradeField {
+	field := ctp.NewCThostFtdcTradeField()
+	field.SetTradeID(item.TradeID)
+	field.SetOrderRef(item.OrderRef)
+	field.SetOrderSysID(item.OrderSysID)
+	field.SetExchangeID(item.ExchangeID)
+	field.SetInstrumentID(item.Symbol)
+	field.SetDirection(mapDirection(item.Direction))
+	field.SetOffsetFlag(mapOffsetFlag(item.OffsetFlag))
+	field.SetHedgeFlag(ctp.THOST_FTDC_HF_Speculation)
+	field.SetPrice(item.Price)
+	field.SetVolume(item.Volume)
+	field.SetTradingDay(item.TradingDay)
+	field.SetTradeDate(item.TradeTime.Format("20060102"))
+	field.SetTradeTime(item.TradeTime.Format("15:04:05"))
+	return field
+}
+
+// newMockRspInfo 为非空错误信息构造 RspInfo；msg 为空时返回 nil，表示成功。
+func newMockRspInfo(msg string) ctp.CThostFtdcRspInfoField {
+	if msg == "" {
+		return nil
+	}
+	rsp := ctp.NewCThostFtdcRspInfoField()
+	rsp.SetErrorID(1)
+	rsp.SetErrorMsg(msg)
+	return rsp
+}
+
+func deleteMockRspInfo(rsp ctp.CThostFtdcRspInfoField) {
+	if rsp != nil {
+		ctp.DeleteCThostFtdcRspInfoField(rsp)
+	}
+}
@@ -0,0 +1,278 @@
+package trade
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/config"
+	"ctp-future-kline/internal/testmysql"
+)
+
+func startMockGateway(t *testing.T, front *MockTraderFront) *CTPGateway {
+	t.Helper()
+	gw := newMockGateway(t, front)
+	if err := gw.Start(); err != nil {
+		t.Fatalf("gateway Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = gw.Close() })
+	return gw
+}
+
+func newMockGateway(t *testing.T, front *MockTraderFront) *CTPGateway {
+	t.Helper()
+	gw := NewCTPGateway(
+		config.CTPConfig{FlowPath: t.TempDir(), ConnectWaitSeconds: 2, AuthenticateWaitSeconds: 2, LoginWaitSeconds: 2},
+		config.TradeConfig{AccountID: "mock", QueryTimeoutMS: 2000},
+		nil,
+	)
+	gw.SetTraderAPIFactory(front.Factory())
+	return gw
+}
+
+func waitGatewayEvent(t *testing.T, ch <-chan GatewayEvent, match func(GatewayEvent) bool) GatewayEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-ch:
+			if match(ev) {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for gateway event")
+			return GatewayEvent{}
+		}
+	}
+}
+
+func orderStatusIs(status string) func(GatewayEvent) bool {
+	return func(ev GatewayEvent) bool { return ev.Order != nil && ev.Order.OrderStatus == status }
+}
+
+func TestMockFrontLoginConfirmsSettlement(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{TradingDay: "20260302"})
+	gw := startMockGateway(t, front)
+
+	st := gw.Status()
+	if !st.TraderFront || !st.TraderLogin || !st.SettlementConfirmed || st.TradingDay != "20260302" {
+		t.Fatalf("Status() = %+v, want connected, logged in and confirmed on 20260302", st)
+	}
+	if got := front.SettlementConfirmCount(); got != 1 {
+		t.Fatalf("SettlementConfirmCount() = %d, want 1", got)
+	}
+
+	rejecting := NewMockTraderFront(MockFrontConfig{SettlementConfirmError: "CTP:结算单未生成"})
+	if err := newMockGateway(t, rejecting).Start(); err == nil {
+		t.Fatalf("Start() error = nil, want settlement confirm failure")
+	}
+}
+
+func TestMockFrontOrderLifecycleWithPartialFills(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{TradingDay: "20260302"})
+	front.SetOrderPlanner(func(req MockOrderRequest) MockOrderPlan {
+		return MockOrderPlan{Fills: []int{1}}
+	})
+	gw := startMockGateway(t, front)
+	events, cancel := gw.Subscribe()
+	defer cancel()
+
+	order, err := gw.SubmitOrder("cmd-1", SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "buy", OffsetFlag: "open", LimitPrice: 3900, Volume: 3})
+	if err != nil {
+		t.Fatalf("SubmitOrder() error = %v", err)
+	}
+	ev := waitGatewayEvent(t, events, orderStatusIs("part_traded_queueing"))
+	if ev.Order.CommandID != "cmd-1" || ev.Order.VolumeTraded != 1 {
+		t.Fatalf("partial order update = %+v", ev.Order)
+	}
+	ev = waitGatewayEvent(t, events, func(ev GatewayEvent) bool { return ev.Trade != nil })
+	if ev.Trade.Volume != 1 || ev.Trade.Price != 3900 || ev.Trade.OffsetFlag != "open" {
+		t.Fatalf("first trade = %+v", ev.Trade)
+	}
+
+	if err := front.FillOrder(order.OrderRef, 2, 3901); err != nil {
+		t.Fatalf("FillOrder() error = %v", err)
+	}
+	ev = waitGatewayEvent(t, events, orderStatusIs("all_traded"))
+	if ev.Order.VolumeTraded != 3 || ev.Order.VolumeCanceled != 0 {
+		t.Fatalf("final order update = %+v", ev.Order)
+	}
+
+	positions, err := gw.RefreshPositions()
+	if err != nil {
+		t.Fatalf("RefreshPositions() error = %v", err)
+	}
+	if len(positions) != 1 || positions[0].Direction != "long" || positions[0].Position != 3 || positions[0].TodayPosition != 3 {
+		t.Fatalf("positions = %+v, want long 3 today 3", positions)
+	}
+	trades, err := gw.RefreshTrades()
+	if err != nil {
+		t.Fatalf("RefreshTrades() error = %v", err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("len(trades) = %d, want 2", len(trades))
+	}
+}
+
+func TestMockFrontRejectAndCancel(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{})
+	front.SetOrderPlanner(func(req MockOrderRequest) MockOrderPlan {
+		if req.Volume > 10 {
+			return MockOrderPlan{RejectMsg: "CTP:资金不足"}
+		}
+		return MockOrderPlan{}
+	})
+	gw := startMockGateway(t, front)
+	events, cancel := gw.Subscribe()
+	defer cancel()
+
+	if _, err := gw.SubmitOrder("cmd-big", SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "buy", OffsetFlag: "open", LimitPrice: 3900, Volume: 20}); err != nil {
+		t.Fatalf("SubmitOrder() error = %v", err)
+	}
+	ev := waitGatewayEvent(t, events, orderStatusIs("rejected"))
+	if ev.Order.CommandID != "cmd-big" || ev.Err == nil {
+		t.Fatalf("reject event = %+v err=%v", ev.Order, ev.Err)
+	}
+
+	order, err := gw.SubmitOrder("cmd-rest", SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "sell", OffsetFlag: "open", LimitPrice: 3950, Volume: 1})
+	if err != nil {
+		t.Fatalf("SubmitOrder() error = %v", err)
+	}
+	ev = waitGatewayEvent(t, events, orderStatusIs("no_trade_queueing"))
+	cancelReq := CancelOrderRequest{CommandID: "cmd-rest", OrderRef: order.OrderRef, ExchangeID: "SHFE", OrderSysID: ev.Order.OrderSysID, FrontID: ev.Order.FrontID, SessionID: ev.Order.SessionID}
+	if _, err := gw.CancelOrder(cancelReq); err != nil {
+		t.Fatalf("CancelOrder() error = %v", err)
+	}
+	ev = waitGatewayEvent(t, events, orderStatusIs("canceled"))
+	if ev.Order.VolumeCanceled != 1 {
+		t.Fatalf("cancel event = %+v", ev.Order)
+	}
+	if _, err := gw.CancelOrder(cancelReq); err != nil {
+		t.Fatalf("second CancelOrder() error = %v", err)
+	}
+	waitGatewayEvent(t, events, orderStatusIs("cancel_rejected"))
+}
+
+func TestMockFrontFlowControlBacksOffLane(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{})
+	front.FailQueryReturn("positions", MockFlowControlRet, 1)
+	front.FailQueryResponse("orders", 90, "CTP:查询未就绪，请稍后重试", 1)
+	gw := startMockGateway(t, front)
+	lane := newLaneThrottle("auto_pos", "positions", config.TradeConfig{QueryBaseIntervalMS: 1000, QueryBackoffStepMS: 200, QueryMaxIntervalMS: 3000})
+
+	_, err := gw.RefreshPositions()
+	if !isFlowControlError(err) {
+		t.Fatalf("RefreshPositions() error = %v, want flow control", err)
+	}
+	lane.onFlowControl(err, "", "")
+	_, err = gw.RefreshOrders()
+	if !isFlowControlError(err) {
+		t.Fatalf("RefreshOrders() error = %v, want flow control", err)
+	}
+	lane.onFlowControl(err, "", "")
+	if lane.interval != 1400*time.Millisecond {
+		t.Fatalf("lane interval = %v, want 1.4s", lane.interval)
+	}
+	if _, err := gw.RefreshPositions(); err != nil {
+		t.Fatalf("RefreshPositions() after flow control error = %v", err)
+	}
+	if got := front.RequestCount("positions"); got != 2 {
+		t.Fatalf("RequestCount(positions) = %d, want 2", got)
+	}
+}
+
+func TestMockFrontDisconnectAndReconnect(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{})
+	gw := startMockGateway(t, front)
+
+	front.Disconnect(0x1001)
+	deadline := time.Now().Add(2 * time.Second)
+	for gw.Status().TraderFront && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	st := gw.Status()
+	if st.TraderFront || st.TraderLogin || st.LastError == "" {
+		t.Fatalf("Status() after disconnect = %+v", st)
+	}
+	if _, err := gw.RefreshAccount(); err == nil {
+		t.Fatalf("RefreshAccount() while disconnected error = nil")
+	}
+
+	front.Reconnect()
+	deadline = time.Now().Add(2 * time.Second)
+	for !gw.Status().TraderFront && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !gw.Status().TraderFront {
+		t.Fatalf("Status().TraderFront after reconnect = false")
+	}
+}
+
+func TestMockFrontSettlementQuery(t *testing.T) {
+	front := NewMockTraderFront(MockFrontConfig{TradingDay: "20260303"})
+	if err := front.SetSettlement("20260302", sampleSettlement); err != nil {
+		t.Fatalf("SetSettlement() error = %v", err)
+	}
+	gw := startMockGateway(t, front)
+
+	text, day, err := gw.QuerySettlementInfo("")
+	if err != nil {
+		t.Fatalf("QuerySettlementInfo() error = %v", err)
+	}
+	if day != "20260302" || text != sampleSettlement {
+		t.Fatalf("QuerySettlementInfo() day = %q, text equal = %v", day, text == sampleSettlement)
+	}
+}
+
+func TestMockFrontServiceOrderLifecycleAndRisk(t *testing.T) {
+	dsn := testmysql.NewDatabase(t)
+	front := NewMockTraderFront(MockFrontConfig{TradingDay: "20260302"})
+	front.SetAccount(TradingAccountSnapshot{Balance: 1_000_000, Available: 1_000_000})
+	front.SetOrderPlanner(func(req MockOrderRequest) MockOrderPlan {
+		return MockOrderPlan{Fills: []int{req.Volume}}
+	})
+	cfg := configForTradeTest()
+	cfg.AccountID = "mock_live"
+	cfg.QueryTimeoutMS = 2000
+	svc, err := NewService(cfg, config.CTPConfig{FlowPath: t.TempDir(), ConnectWaitSeconds: 2, AuthenticateWaitSeconds: 2, LoginWaitSeconds: 2}, dsn, nil)
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+	svc.SetTraderAPIFactory(front.Factory())
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if err := svc.RefreshAll(); err != nil {
+		t.Fatalf("RefreshAll() error = %v", err)
+	}
+	events, cancel := svc.Subscribe()
+	defer cancel()
+
+	if _, err := svc.SubmitOrder(context.Background(), SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "buy", OffsetFlag: "open", LimitPrice: 3900, Volume: 11}); err == nil {
+		t.Fatalf("SubmitOrder() over max_order_volume error = nil")
+	}
+	if got := front.RequestCount("order_insert"); got != 0 {
+		t.Fatalf("blocked order reached front: order_insert count = %d", got)
+	}
+
+	if _, err := svc.SubmitOrder(context.Background(), SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "buy", OffsetFlag: "open", LimitPrice: 3900, Volume: 2}); err != nil {
+		t.Fatalf("SubmitOrder() error = %v", err)
+	}
+	timeout := time.After(3 * time.Second)
+	for done := false; !done; {
+		select {
+		case ev := <-events:
+			done = ev.Type == "trade_trade_update"
+		case <-timeout:
+			t.Fatalf("timed out waiting for trade_trade_update")
+		}
+	}
+	trades, err := svc.Trades(10)
+	if err != nil {
+		t.Fatalf("Trades() error = %v", err)
+	}
+	if len(trades) != 1 || trades[0].Volume != 2 || trades[0].TradingDay != "20260302" {
+		t.Fatalf("trades = %+v, want one 2-lot fill on 20260302", trades)
+	}
+}
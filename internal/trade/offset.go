@@ -0,0 +1,219 @@
+// offset.go 负责平仓开平标志的解析：上期所/能源中心区分平今与平昨，其余交易所只有平仓。
+// auto_close 根据今昨仓明细和手续费把一笔平仓拆成对应的子委托。
+package trade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OffsetAutoClose 表示由系统按今昨仓和交易所规则自动决定平今/平昨。
+const OffsetAutoClose = "auto_close"
+
+// OrderStatusPartiallySubmitted 是 auto_close 拆单中途下单失败、只有部分子委托送到柜台时合并记录的状态。
+const OrderStatusPartiallySubmitted = "partially_submitted"
+
+// exchangeClosesYesterdayFirst 表示交易所对普通平仓指令按先开先平处理，先平昨仓再平今仓。
+func exchangeClosesYesterdayFirst(exchangeID string) bool {
+	switch strings.ToUpper(strings.TrimSpace(exchangeID)) {
+	case "DCE", "CZCE", "GFEX":
+		return true
+	default:
+		return false
+	}
+}
+
+// exchangeSplitsTodayPosition 判断交易所是否区分平今与平昨。
+func exchangeSplitsTodayPosition(exchangeID string) bool {
+	switch strings.ToUpper(strings.TrimSpace(exchangeID)) {
+	case "SHFE", "INE":
+		return true
+	default:
+		return false
+	}
+}
+
+// closablePositionSplit 返回 direction 方向下单可平的今仓与昨仓数量。
+// 实盘 YdPosition 是日初静态值，昨仓剩余统一按 Position-TodayPosition 计算。
+func closablePositionSplit(items []PositionSnapshot, symbol string, direction string) (int, int) {
+	wantDir := "long"
+	if direction == "buy" {
+		wantDir = "short"
+	}
+	today, yd := 0, 0
+	for _, item := range items {
+		if !strings.EqualFold(item.Symbol, symbol) || item.Direction != wantDir {
+			continue
+		}
+		t := min(max(item.TodayPosition, 0), item.Position)
+		today += t
+		yd += max(item.Position-t, 0)
+	}
+	return today, yd
+}
+
+// closableVolumeForOffset 返回指定开平标志可平数量；只有区分今昨的交易所才按平今/平昨拆开校验。
+func closableVolumeForOffset(items []PositionSnapshot, symbol string, exchangeID string, direction string, offsetFlag string) int {
+	if !exchangeSplitsTodayPosition(exchangeID) {
+		return closableVolume(items, symbol, direction)
+	}
+	today, yd := closablePositionSplit(items, symbol, direction)
+	switch offsetFlag {
+	case "close_today":
+		return today
+	case "close_yesterday":
+		return yd
+	default:
+		return today + yd
+	}
+}
+
+// closeTodayCheaper 判断按当前价格平今手续费是否严格低于平昨；费率缺失时视为不更便宜。
+func closeTodayCheaper(rate CommissionRateSnapshot, price float64, volumeMultiple float64) bool {
+	notional := price * volumeMultiple
+	today := notional*rate.CloseTodayRatioByMoney + rate.CloseTodayRatioByVolume
+	yesterday := notional*rate.CloseRatioByMoney + rate.CloseRatioByVolume
+	return today < yesterday
+}
+
+// ResolveAutoClose 把 auto_close 请求拆成实际报单：区分今昨的交易所按 todayFirst 决定先平今还是先平昨，
+// 其余交易所直接转为一笔 close。可平数量不足时返回错误。
+func ResolveAutoClose(req SubmitOrderRequest, positions []PositionSnapshot, todayFirst bool) ([]SubmitOrderRequest, error) {
+	if req.Volume <= 0 {
+		return nil, errors.New("volume must be > 0")
+	}
+	if !exchangeSplitsTodayPosition(req.ExchangeID) {
+		if closableVolume(positions, req.Symbol, req.Direction) < req.Volume {
+			return nil, errors.New("close volume exceeds available position")
+		}
+		leg := req
+		leg.OffsetFlag = "close"
+		return []SubmitOrderRequest{leg}, nil
+	}
+	today, yd := closablePositionSplit(positions, req.Symbol, req.Direction)
+	if today+yd < req.Volume {
+		return nil, fmt.Errorf("close volume exceeds available position: today %d, yesterday %d", today, yd)
+	}
+	type bucket struct {
+		offset string
+		volume int
+	}
+	order := []bucket{{"close_yesterday", yd}, {"close_today", today}}
+	if todayFirst {
+		order[0], order[1] = order[1], order[0]
+	}
+	remaining := req.Volume
+	out := make([]SubmitOrderRequest, 0, 2)
+	for _, b := range order {
+		used := min(remaining, b.volume)
+		if used <= 0 {
+			continue
+		}
+		leg := req
+		leg.OffsetFlag = b.offset
+		leg.Volume = used
+		out = append(out, leg)
+		remaining -= used
+	}
+	return out, nil
+}
+
+// SubmitAutoClose 解析 auto_close 并依次下达拆分后的平仓委托；任一笔失败即停止，返回已下达的委托。
+func (s *Service) SubmitAutoClose(ctx context.Context, req SubmitOrderRequest) ([]OrderRecord, error) {
+	req, err := s.normalizeSubmitRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	legs, err := s.resolveAutoClose(req)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]OrderRecord, 0, len(legs))
+	for _, leg := range legs {
+		rec, err := s.SubmitOrder(ctx, leg)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// mergeAutoCloseLegs 把 auto_close 拆出的各笔委托合并成一条记录：
+// 标识沿用第一笔，手数累加，OffsetFlag 保持 auto_close，状态取最差的一笔，原始各笔放在 Legs。
+func mergeAutoCloseLegs(recs []OrderRecord) OrderRecord {
+	if len(recs) == 1 {
+		return recs[0]
+	}
+	out := recs[0]
+	out.OffsetFlag = OffsetAutoClose
+	out.VolumeTotalOriginal, out.VolumeTraded, out.VolumeCanceled = 0, 0, 0
+	worst := recs[0]
+	for _, rec := range recs {
+		out.VolumeTotalOriginal += rec.VolumeTotalOriginal
+		out.VolumeTraded += rec.VolumeTraded
+		out.VolumeCanceled += rec.VolumeCanceled
+		if rec.UpdatedAt.After(out.UpdatedAt) {
+			out.UpdatedAt = rec.UpdatedAt
+		}
+		if autoCloseStatusRank(rec.OrderStatus) > autoCloseStatusRank(worst.OrderStatus) {
+			worst = rec
+		}
+	}
+	out.OrderStatus, out.SubmitStatus, out.StatusMsg = worst.OrderStatus, worst.SubmitStatus, worst.StatusMsg
+	out.Legs = append([]OrderRecord(nil), recs...)
+	return out
+}
+
+// autoCloseStatusRank 给委托状态排序：拒单最差，其次是撤单和不再排队的终态，然后是仍在途，全部成交最好。
+func autoCloseStatusRank(status string) int {
+	switch status {
+	case "rejected":
+		return 3
+	case "canceled", "part_traded_not_queueing", "no_trade_not_queueing":
+		return 2
+	case "all_traded":
+		return 0
+	default:
+		return 1
+	}
+}
+
+// submitOrderLegs 下达一笔委托，auto_close 会被拆成多笔。
+func (s *Service) submitOrderLegs(ctx context.Context, req SubmitOrderRequest) ([]OrderRecord, error) {
+	if req.OffsetFlag == OffsetAutoClose {
+		return s.SubmitAutoClose(ctx, req)
+	}
+	rec, err := s.SubmitOrder(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return []OrderRecord{rec}, nil
+}
+
+func (s *Service) resolveAutoClose(req SubmitOrderRequest) ([]SubmitOrderRequest, error) {
+	positions, err := s.Positions()
+	if s.replayPaper || s.livePaper {
+		if _, items, perr := s.paperRiskState(); perr == nil {
+			positions, err = items, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ResolveAutoClose(req, positions, s.closeTodayFirst(req))
+}
+
+// closeTodayFirst 根据已同步的手续费率决定先平今还是先平昨，默认先平昨。
+func (s *Service) closeTodayFirst(req SubmitOrderRequest) bool {
+	if s.rateCatalog == nil || !exchangeSplitsTodayPosition(req.ExchangeID) {
+		return false
+	}
+	rate, ok, err := s.rateCatalog.commissionRate(req.Symbol, req.ExchangeID)
+	if err != nil || !ok {
+		return false
+	}
+	return closeTodayCheaper(rate, req.LimitPrice, s.contractVolumeMultiple(req.Symbol, req.ExchangeID))
+}
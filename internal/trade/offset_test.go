@@ -0,0 +1,123 @@
+package trade
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveAutoCloseSplitsTodayAndYesterdayOnSHFE(t *testing.T) {
+	t.Parallel()
+
+	positions := []PositionSnapshot{
+		{Symbol: "rb2405", Exchange: "SHFE", Direction: "long", YdPosition: 3, TodayPosition: 2, Position: 4},
+		{Symbol: "rb2405", Exchange: "SHFE", Direction: "short", TodayPosition: 5, Position: 5},
+	}
+	req := SubmitOrderRequest{Symbol: "rb2405", ExchangeID: "SHFE", Direction: "sell", OffsetFlag: OffsetAutoClose, LimitPrice: 3900, Volume: 3}
+
+	legs, err := ResolveAutoClose(req, positions, false)
+	if err != nil {
+		t.Fatalf("ResolveAutoClose() error = %v", err)
+	}
+	if len(legs) != 2 || legs[0].OffsetFlag != "close_yesterday" || legs[0].Volume != 2 || legs[1].OffsetFlag != "close_today" || legs[1].Volume != 1 {
+		t.Fatalf("yesterday-first legs = %+v", legs)
+	}
+	legs, err = ResolveAutoClose(req, positions, true)
+	if err != nil {
+		t.Fatalf("ResolveAutoClose(todayFirst) error = %v", err)
+	}
+	if len(legs) != 2 || legs[0].OffsetFlag != "close_today" || legs[0].Volume != 2 || legs[1].OffsetFlag != "close_yesterday" || legs[1].Volume != 1 {
+		t.Fatalf("today-first legs = %+v", legs)
+	}
+	req.Volume = 5
+	if _, err := ResolveAutoClose(req, positions, false); err == nil {
+		t.Fatal("expected error when close volume exceeds long position")
+	}
+}
+
+func TestResolveAutoCloseUsesPlainCloseOutsideSHFE(t *testing.T) {
+	t.Parallel()
+
+	positions := []PositionSnapshot{{Symbol: "m2405", Exchange: "DCE", Direction: "short", YdPosition: 1, TodayPosition: 1, Position: 2}}
+	legs, err := ResolveAutoClose(SubmitOrderRequest{Symbol: "m2405", ExchangeID: "DCE", Direction: "buy", OffsetFlag: OffsetAutoClose, Volume: 2}, positions, true)
+	if err != nil {
+		t.Fatalf("ResolveAutoClose() error = %v", err)
+	}
+	if len(legs) != 1 || legs[0].OffsetFlag != "close" || legs[0].Volume != 2 {
+		t.Fatalf("legs = %+v, want single close", legs)
+	}
+}
+
+func TestClosableVolumeForOffsetRespectsTodaySplit(t *testing.T) {
+	t.Parallel()
+
+	positions := []PositionSnapshot{{Symbol: "au2406", Direction: "long", TodayPosition: 1, Position: 3}}
+	cases := []struct {
+		exchange string
+		offset   string
+		want     int
+	}{
+		{"INE", "close_today", 1},
+		{"INE", "close_yesterday", 2},
+		{"INE", "close", 3},
+		{"CZCE", "close_today", 3},
+	}
+	for _, tc := range cases {
+		if got := closableVolumeForOffset(positions, "au2406", tc.exchange, "sell", tc.offset); got != tc.want {
+			t.Fatalf("closableVolumeForOffset(%s, %s) = %d, want %d", tc.exchange, tc.offset, got, tc.want)
+		}
+	}
+}
+
+func TestCloseTodayCheaperComparesCommission(t *testing.T) {
+	t.Parallel()
+
+	rate := CommissionRateSnapshot{CloseRatioByMoney: 0.0001, CloseTodayRatioByMoney: 0.0003}
+	if closeTodayCheaper(rate, 3900, 10) {
+		t.Fatal("close today should be costlier with higher close-today ratio")
+	}
+	rate = CommissionRateSnapshot{CloseRatioByVolume: 3, CloseTodayRatioByVolume: 0}
+	if !closeTodayCheaper(rate, 3900, 10) {
+		t.Fatal("close today should be cheaper when waived")
+	}
+}
+
+func TestTakeClosePositionCloseYesterdayConsumesYesterdayFirst(t *testing.T) {
+	t.Parallel()
+
+	item := PositionSnapshot{YdPosition: 2, TodayPosition: 2, Position: 4}
+	takeClosePosition(&item, 3, "close_yesterday")
+	if item.Position != 1 || item.YdPosition != 0 || item.TodayPosition != 1 {
+		t.Fatalf("after close_yesterday = %+v", item)
+	}
+	item = PositionSnapshot{YdPosition: 2, TodayPosition: 2, Position: 4}
+	takeClosePosition(&item, 3, "close_today")
+	if item.Position != 1 || item.YdPosition != 1 || item.TodayPosition != 0 {
+		t.Fatalf("after close_today = %+v", item)
+	}
+}
+
+func TestMergeAutoCloseLegsKeepsEveryLeg(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Date(2026, 4, 7, 9, 0, 0, 0, time.Local)
+	recs := []OrderRecord{
+		{CommandID: "cmd-yd", OrderRef: "1", Symbol: "rb2405", OffsetFlag: "close_yesterday", VolumeTotalOriginal: 2, VolumeTraded: 2, OrderStatus: "all_traded", StatusMsg: "filled", UpdatedAt: t0},
+		{CommandID: "cmd-td", OrderRef: "2", Symbol: "rb2405", OffsetFlag: "close_today", VolumeTotalOriginal: 1, VolumeCanceled: 1, OrderStatus: "canceled", StatusMsg: "canceled by exchange", UpdatedAt: t0.Add(time.Second)},
+	}
+	got := mergeAutoCloseLegs(recs)
+	if got.CommandID != "cmd-yd" || got.OffsetFlag != OffsetAutoClose {
+		t.Fatalf("merged id/offset = %s/%s", got.CommandID, got.OffsetFlag)
+	}
+	if got.VolumeTotalOriginal != 3 || got.VolumeTraded != 2 || got.VolumeCanceled != 1 || !got.UpdatedAt.Equal(t0.Add(time.Second)) {
+		t.Fatalf("merged volumes = %+v", got)
+	}
+	if got.OrderStatus != "canceled" || got.StatusMsg != "canceled by exchange" {
+		t.Fatalf("merged status = %s/%q, want the canceled leg", got.OrderStatus, got.StatusMsg)
+	}
+	if len(got.Legs) != 2 || got.Legs[0].CommandID != "cmd-yd" || got.Legs[1].CommandID != "cmd-td" {
+		t.Fatalf("merged legs = %+v", got.Legs)
+	}
+	if single := mergeAutoCloseLegs(recs[:1]); single.OffsetFlag != "close_yesterday" || single.Legs != nil {
+		t.Fatalf("single leg should pass through, got %+v", single)
+	}
+}
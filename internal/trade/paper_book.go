@@ -0,0 +1,217 @@
+// paper_book.go 负责不落库的内存模拟账本。
+// 回测用它承接策略委托：撮合、持仓、平仓盈亏、手续费和浮动盈亏都复用 replay_paper 的同一套函数，
+// 因此 backtest、replay_paper 与 live_paper 的成交口径一致、结果可以直接对比。
+package trade
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PaperBookConfig 是内存模拟账本的初始化参数。
+type PaperBookConfig struct {
+	// AccountID 是写入委托、成交和持仓记录的账户标识。
+	AccountID string
+	// InitialBalance 是初始静态权益，<=0 时使用 replay_paper 默认初始资金。
+	InitialBalance float64
+	// VolumeMultiple 返回合约乘数；为空或返回 <=0 时按 1 计算。
+	VolumeMultiple func(symbol string, exchangeID string) float64
+}
+
+// PaperBook 是单线程使用的内存模拟账本，不加锁，由调用方保证串行访问。
+type PaperBook struct {
+	cfg         PaperBookConfig
+	seq         int
+	now         time.Time
+	pending     []OrderRecord
+	trades      []TradeRecord
+	clientTags  map[string]string
+	positions   []PositionSnapshot
+	quotes      map[string]replayQuote
+	closeProfit float64
+	commission  float64
+}
+
+// NewPaperBook 创建空仓的内存模拟账本。
+func NewPaperBook(cfg PaperBookConfig) *PaperBook {
+	if cfg.InitialBalance <= 0 {
+		cfg.InitialBalance = replayPaperInitialBalance
+	}
+	return &PaperBook{cfg: cfg, quotes: make(map[string]replayQuote), clientTags: make(map[string]string)}
+}
+
+// Now 返回账本最近一次看到的行情时间。
+func (b *PaperBook) Now() time.Time {
+	return b.now
+}
+
+// Submit 挂出一笔限价委托，等待后续 K 线撮合；与 replay_paper 一样，委托不会在提交的那根 K 线内成交。
+func (b *PaperBook) Submit(req SubmitOrderRequest) (OrderRecord, error) {
+	if strings.TrimSpace(req.Symbol) == "" {
+		return OrderRecord{}, fmt.Errorf("symbol is required")
+	}
+	if req.Volume <= 0 {
+		return OrderRecord{}, fmt.Errorf("volume must be positive")
+	}
+	if req.Direction != "buy" && req.Direction != "sell" {
+		return OrderRecord{}, fmt.Errorf("invalid direction: %s", req.Direction)
+	}
+	if req.LimitPrice <= 0 {
+		return OrderRecord{}, fmt.Errorf("limit_price must be positive")
+	}
+	b.seq++
+	commandID := fmt.Sprintf("bt-%06d", b.seq)
+	rec := OrderRecord{
+		AccountID:           b.cfg.AccountID,
+		CommandID:           commandID,
+		OrderRef:            commandID,
+		ExchangeID:          req.ExchangeID,
+		OrderSysID:          commandID,
+		Symbol:              req.Symbol,
+		Direction:           req.Direction,
+		OffsetFlag:          firstNonEmpty(req.OffsetFlag, "open"),
+		LimitPrice:          req.LimitPrice,
+		VolumeTotalOriginal: req.Volume,
+		OrderStatus:         "queued",
+		SubmitStatus:        "accepted",
+		StatusMsg:           "paper order accepted and waiting for market",
+		ClientTag:           req.ClientTag,
+		InsertedAt:          b.now,
+		UpdatedAt:           b.now,
+	}
+	b.pending = append(b.pending, rec)
+	b.clientTags[rec.OrderRef] = rec.ClientTag
+	return rec, nil
+}
+
+// CancelPending 撤销指定合约的全部挂单；symbol 为空时撤销所有挂单。
+func (b *PaperBook) CancelPending(symbol string) []OrderRecord {
+	var canceled []OrderRecord
+	kept := b.pending[:0]
+	for _, order := range b.pending {
+		if symbol != "" && !strings.EqualFold(order.Symbol, symbol) {
+			kept = append(kept, order)
+			continue
+		}
+		order.VolumeCanceled = order.VolumeTotalOriginal - order.VolumeTraded
+		order.OrderStatus = "canceled"
+		order.StatusMsg = "paper order canceled"
+		order.UpdatedAt = b.now
+		canceled = append(canceled, order)
+	}
+	b.pending = kept
+	return canceled
+}
+
+// OnBar 用一根 K 线撮合挂单并按收盘价更新盯市行情，返回本根 K 线产生的成交。
+func (b *PaperBook) OnBar(bar PaperMarketBar) []TradeRecord {
+	now := bar.AdjustedTime
+	if now.IsZero() {
+		now = bar.DataTime
+	}
+	if now.After(b.now) {
+		b.now = now
+	}
+	b.quotes[strings.ToLower(strings.TrimSpace(bar.Symbol))] = replayQuote{LastPrice: bar.Close, LastTickAt: now}
+	var fills []TradeRecord
+	kept := b.pending[:0]
+	for _, order := range b.pending {
+		_, tr, ok := fillOrderOnBar(b.cfg.AccountID, order, bar, now)
+		if !ok {
+			kept = append(kept, order)
+			continue
+		}
+		b.applyFill(tr)
+		fills = append(fills, tr)
+	}
+	b.pending = kept
+	return fills
+}
+
+func (b *PaperBook) applyFill(tr TradeRecord) {
+	b.positions, b.closeProfit = applyFilledTradeToPositionsWithProfit(b.positions, tr, b.closeProfit, b.volumeMultiple(tr.Symbol, tr.ExchangeID))
+	b.commission += PaperCommission(tr)
+	b.trades = append(b.trades, tr)
+}
+
+func (b *PaperBook) volumeMultiple(symbol string, exchangeID string) float64 {
+	if b.cfg.VolumeMultiple == nil {
+		return 1
+	}
+	if vm := b.cfg.VolumeMultiple(symbol, exchangeID); vm > 0 {
+		return vm
+	}
+	return 1
+}
+
+// Account 返回按当前行情盯市后的账户快照，资金口径与 replay_paper 相同。
+func (b *PaperBook) Account() TradingAccountSnapshot {
+	account := TradingAccountSnapshot{
+		AccountID:     b.cfg.AccountID,
+		StaticBalance: b.cfg.InitialBalance,
+		CloseProfit:   b.closeProfit,
+		Commission:    b.commission,
+		UpdatedAt:     b.now,
+	}
+	for _, pos := range b.positions {
+		account.Margin += pos.UseMargin
+		quote := b.quotes[strings.ToLower(strings.TrimSpace(pos.Symbol))]
+		account.PositionProfit += markPositionProfit(pos, quote, b.volumeMultiple(pos.Symbol, pos.Exchange))
+	}
+	for _, order := range b.pending {
+		if order.OffsetFlag == "open" {
+			account.FrozenMargin += openOrderReserve(order)
+		}
+	}
+	computePaperAccountTotals(&account)
+	return account
+}
+
+// NetPosition 返回合约的净持仓手数，多头为正、空头为负。
+func (b *PaperBook) NetPosition(symbol string) int {
+	net := 0
+	for _, pos := range b.positions {
+		if !strings.EqualFold(pos.Symbol, symbol) {
+			continue
+		}
+		if pos.Direction == "short" {
+			net -= pos.Position
+		} else {
+			net += pos.Position
+		}
+	}
+	return net
+}
+
+// Positions 返回当前持仓副本，按合约和方向排序。
+func (b *PaperBook) Positions() []PositionSnapshot {
+	out := append([]PositionSnapshot(nil), b.positions...)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol == out[j].Symbol {
+			return out[i].Direction < out[j].Direction
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+// PendingOrders 返回尚未成交的挂单副本。
+func (b *PaperBook) PendingOrders() []OrderRecord {
+	return append([]OrderRecord(nil), b.pending...)
+}
+
+// Trades 返回全部成交副本，按成交先后排列。
+func (b *PaperBook) Trades() []TradeRecord {
+	return append([]TradeRecord(nil), b.trades...)
+}
+
+// Journal 用 FIFO 把全部成交配成逐笔交易日志，手续费口径与账户一致；未平仓批次不出现在日志中。
+func (b *PaperBook) Journal() []JournalEntry {
+	lots := NewLotBook(LotMatchFIFO, b.volumeMultiple)
+	for _, tr := range b.trades {
+		lots.Apply(JournalTrade{TradeRecord: tr, ClientTag: b.clientTags[tr.OrderRef], Commission: PaperCommission(tr)})
+	}
+	return lots.Entries()
+}
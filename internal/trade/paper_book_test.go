@@ -0,0 +1,91 @@
+package trade
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPaperBookMatchesOnLaterBarsAndMarksToMarket(t *testing.T) {
+	t.Parallel()
+
+	book := NewPaperBook(PaperBookConfig{
+		AccountID:      "bt",
+		InitialBalance: 50_000,
+		VolumeMultiple: func(string, string) float64 { return 10 },
+	})
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	bar := func(minute int, open, high, low, close float64) PaperMarketBar {
+		return PaperMarketBar{Symbol: "rb2405", AdjustedTime: base.Add(time.Duration(minute) * time.Minute), Open: open, High: high, Low: low, Close: close}
+	}
+
+	if fills := book.OnBar(bar(0, 3500, 3505, 3495, 3500)); len(fills) != 0 {
+		t.Fatalf("fills without orders = %+v", fills)
+	}
+	if _, err := book.Submit(SubmitOrderRequest{Symbol: "rb2405", Direction: "buy", OffsetFlag: "open", LimitPrice: 3500, Volume: 2}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if fills := book.OnBar(bar(1, 3510, 3520, 3505, 3515)); len(fills) != 0 {
+		t.Fatalf("buy limit 3500 filled above bar low: %+v", fills)
+	}
+	fills := book.OnBar(bar(2, 3502, 3504, 3498, 3503))
+	if len(fills) != 1 || fills[0].Price != 3500 || fills[0].Volume != 2 || !fills[0].TradeTime.Equal(base.Add(2*time.Minute)) {
+		t.Fatalf("fills = %+v", fills)
+	}
+	if got := book.NetPosition("rb2405"); got != 2 {
+		t.Fatalf("NetPosition() = %d, want 2", got)
+	}
+	book.OnBar(bar(3, 3503, 3512, 3503, 3510))
+	account := book.Account()
+	if math.Abs(account.PositionProfit-200) > 1e-9 {
+		t.Fatalf("PositionProfit = %v, want (3510-3500)*2*10", account.PositionProfit)
+	}
+
+	if _, err := book.Submit(SubmitOrderRequest{Symbol: "rb2405", Direction: "sell", OffsetFlag: "close", LimitPrice: 3520, Volume: 2}); err != nil {
+		t.Fatalf("Submit(close) error = %v", err)
+	}
+	if canceled := book.CancelPending("i2405"); len(canceled) != 0 {
+		t.Fatalf("CancelPending(other symbol) = %+v", canceled)
+	}
+	book.OnBar(bar(4, 3515, 3525, 3514, 3522))
+	account = book.Account()
+	if book.NetPosition("rb2405") != 0 || len(book.Positions()) != 0 {
+		t.Fatalf("positions after close = %+v", book.Positions())
+	}
+	wantCommission := PaperCommission(TradeRecord{Price: 3500, Volume: 2}) + PaperCommission(TradeRecord{Price: 3520, Volume: 2})
+	if math.Abs(account.CloseProfit-400) > 1e-9 || math.Abs(account.Commission-wantCommission) > 1e-9 {
+		t.Fatalf("account = %+v", account)
+	}
+	if math.Abs(account.Balance-(50_000+400-wantCommission)) > 1e-9 {
+		t.Fatalf("Balance = %v", account.Balance)
+	}
+	if len(book.Trades()) != 2 || len(book.PendingOrders()) != 0 {
+		t.Fatalf("trades=%d pending=%d", len(book.Trades()), len(book.PendingOrders()))
+	}
+	journal := book.Journal()
+	if len(journal) != 1 || journal[0].Volume != 2 || math.Abs(journal[0].NetPnL-(400-wantCommission)) > 1e-9 {
+		t.Fatalf("journal = %+v", journal)
+	}
+}
+
+func TestPaperBookCancelPendingReleasesFrozenMargin(t *testing.T) {
+	t.Parallel()
+
+	book := NewPaperBook(PaperBookConfig{})
+	if _, err := book.Submit(SubmitOrderRequest{Symbol: "rb2405", Direction: "sell", LimitPrice: 3600, Volume: 1}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if got := book.Account().FrozenMargin; got != 3600 {
+		t.Fatalf("FrozenMargin = %v, want 3600", got)
+	}
+	canceled := book.CancelPending("")
+	if len(canceled) != 1 || canceled[0].OrderStatus != "canceled" || canceled[0].VolumeCanceled != 1 {
+		t.Fatalf("canceled = %+v", canceled)
+	}
+	if account := book.Account(); account.FrozenMargin != 0 || account.Balance != replayPaperInitialBalance {
+		t.Fatalf("account after cancel = %+v", account)
+	}
+	if _, err := book.Submit(SubmitOrderRequest{Symbol: "rb2405", Direction: "buy", LimitPrice: 0, Volume: 1}); err == nil {
+		t.Fatal("Submit() without price error = nil")
+	}
+}
@@ -0,0 +1,195 @@
+// paper_settlement.go 负责回放模拟账户的日终结算。
+// 多交易日回放在每个交易日结束时发出 trading_day_settle 事件：当日未成交挂单按当日有效失效撤销，
+// 持仓按结算价盯市后今仓转为昨仓，并生成一张与柜台结算单同结构的日结单落库，
+// 使回放账户也能通过日结单和每日盈亏接口查看逐日结果。账本本身跨日延续，不做资金划转。
+package trade
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/bus"
+	"ctp-future-kline/internal/logger"
+)
+
+// paperSettleMark 是上一次日终结算时的累计口径，用来把累计盈亏拆成逐日增量。
+type paperSettleMark struct {
+	tradingDay     string
+	balance        float64
+	closeProfit    float64
+	positionProfit float64
+	commission     float64
+}
+
+func (s *Service) consumeTradingDaySettle(ev bus.BusEvent) error {
+	var payload bus.TradingDaySettlePayload
+	if err := json.Unmarshal(ev.Payload, &payload); err != nil {
+		return fmt.Errorf("decode trading day settle event failed: %w", err)
+	}
+	_, err := s.SettlePaperTradingDay(payload.TradingDay, payload.Prices, ev.OccurredAt)
+	return err
+}
+
+// SettlePaperTradingDay 对回放模拟账户做日终结算并保存日结单；prices 是按小写合约代码给出的结算价。
+func (s *Service) SettlePaperTradingDay(tradingDay string, prices map[string]float64, at time.Time) (SettlementStatement, error) {
+	if !s.replayPaper {
+		return SettlementStatement{}, fmt.Errorf("trading day settlement is only available for replay paper service")
+	}
+	tradingDay = strings.TrimSpace(tradingDay)
+	if tradingDay == "" {
+		return SettlementStatement{}, fmt.Errorf("settlement trading day is required")
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s.paperMu.Lock()
+	for symbol, price := range prices {
+		if price <= 0 {
+			continue
+		}
+		symbol = strings.ToLower(strings.TrimSpace(symbol))
+		// 只保留结算价，盯市不再使用收盘前的盘口。
+		s.replayQuotes[symbol] = replayQuote{LastPrice: price, LastTickAt: at}
+	}
+	expired := make([]OrderRecord, 0, len(s.pending))
+	for _, order := range pendingOrderSlice(s.pending) {
+		order.VolumeCanceled = order.VolumeTotalOriginal - order.VolumeTraded
+		order.OrderStatus = "canceled"
+		order.StatusMsg = "paper order expired at trading day settlement"
+		order.UpdatedAt = at
+		if err := s.store.UpsertOrder(order); err != nil {
+			s.paperMu.Unlock()
+			return SettlementStatement{}, err
+		}
+		delete(s.pending, order.CommandID)
+		expired = append(expired, order)
+	}
+	s.paperSettledDay = tradingDay
+	account, positions, err := s.recalculateReplayPaperStateLocked(at)
+	if err != nil {
+		s.paperMu.Unlock()
+		return SettlementStatement{}, err
+	}
+	trades, err := s.store.ListTradesByTradingDay(s.accountID, tradingDay)
+	if err != nil {
+		s.paperMu.Unlock()
+		return SettlementStatement{}, err
+	}
+	statement := s.buildPaperSettlementLocked(tradingDay, account, positions, trades, prices, at)
+	if err := s.store.SaveSettlement(statement); err != nil {
+		s.paperMu.Unlock()
+		return SettlementStatement{}, err
+	}
+	s.paperSettle = paperSettleMark{
+		tradingDay:     tradingDay,
+		balance:        account.Balance,
+		closeProfit:    account.CloseProfit,
+		positionProfit: account.PositionProfit,
+		commission:     account.Commission,
+	}
+	s.paperMu.Unlock()
+
+	for _, order := range expired {
+		s.broadcast("trade_order_update", order)
+	}
+	s.broadcast("trade_settlement_update", DailyPnLFromSettlement(statement))
+	logger.Info("paper trading day settled",
+		"account_id", s.accountID,
+		"trading_day", tradingDay,
+		"expired_orders", len(expired),
+		"positions", len(positions),
+		"balance", account.Balance,
+	)
+	return statement, nil
+}
+
+// buildPaperSettlementLocked 用账本当前状态生成日结单，资金项取相对上一次结算的增量。
+func (s *Service) buildPaperSettlementLocked(tradingDay string, account TradingAccountSnapshot, positions []PositionSnapshot, trades []TradeRecord, prices map[string]float64, at time.Time) SettlementStatement {
+	mark := s.paperSettle
+	if mark.tradingDay == "" {
+		mark.balance = account.StaticBalance
+	}
+	statement := SettlementStatement{
+		AccountID:  s.accountID,
+		TradingDay: tradingDay,
+		Summary: SettlementAccountSummary{
+			PreBalance:        mark.balance,
+			DepositWithdrawal: 0,
+			CloseProfit:       account.CloseProfit - mark.closeProfit,
+			PositionProfit:    account.PositionProfit - mark.positionProfit,
+			Commission:        account.Commission - mark.commission,
+			Balance:           account.Balance,
+			Equity:            account.Balance,
+			Margin:            account.Margin,
+			Available:         account.Available,
+		},
+		ReconcileStatus: ReconcileStatusClean,
+		FetchedAt:       at,
+	}
+	for _, tr := range trades {
+		multiplier := s.contractVolumeMultiple(tr.Symbol, tr.ExchangeID)
+		statement.Trades = append(statement.Trades, SettlementTradeLine{
+			TradeDate:  tr.TradingDay,
+			Exchange:   tr.ExchangeID,
+			Symbol:     tr.Symbol,
+			Direction:  tr.Direction,
+			OffsetFlag: tr.OffsetFlag,
+			Price:      tr.Price,
+			Volume:     tr.Volume,
+			Turnover:   tr.Price * float64(tr.Volume) * multiplier,
+			Commission: PaperCommission(tr),
+			TradeID:    tr.TradeID,
+		})
+	}
+	statement.Fees = summarizeSettlementFees(statement.Trades)
+	statement.Positions = paperSettlementPositions(positions, prices, func(pos PositionSnapshot) float64 {
+		return s.replayPositionProfit(pos, s.replayQuoteForSymbol(pos.Symbol))
+	})
+	return statement
+}
+
+// paperSettlementPositions 把多空持仓按合约合并成日结单持仓行。
+func paperSettlementPositions(positions []PositionSnapshot, prices map[string]float64, profit func(PositionSnapshot) float64) []SettlementPositionLine {
+	bySymbol := make(map[string]*SettlementPositionLine)
+	order := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		if pos.Position <= 0 {
+			continue
+		}
+		key := strings.ToLower(pos.Symbol)
+		line := bySymbol[key]
+		if line == nil {
+			line = &SettlementPositionLine{Symbol: pos.Symbol, SettlementPrice: prices[key]}
+			bySymbol[key] = line
+			order = append(order, key)
+		}
+		avg := pos.PositionCost / float64(pos.Position)
+		if pos.Direction == "short" {
+			line.ShortPosition += pos.Position
+			line.ShortAvgPrice = avg
+		} else {
+			line.LongPosition += pos.Position
+			line.LongAvgPrice = avg
+		}
+		line.PositionProfit += profit(pos)
+		line.Margin += pos.UseMargin
+	}
+	sort.Strings(order)
+	out := make([]SettlementPositionLine, 0, len(order))
+	for _, key := range order {
+		out = append(out, *bySymbol[key])
+	}
+	return out
+}
+
+// rollPaperPositionsToYesterday 在交易日切换时把今仓全部转为昨仓。
+func rollPaperPositionsToYesterday(items []PositionSnapshot) []PositionSnapshot {
+	for i := range items {
+		items[i].YdPosition = items[i].Position
+		items[i].TodayPosition = 0
+	}
+	return items
+}
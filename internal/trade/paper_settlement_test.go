@@ -0,0 +1,36 @@
+package trade
+
+import "testing"
+
+func TestPaperSettlementPositionsMergesDirections(t *testing.T) {
+	t.Parallel()
+
+	positions := []PositionSnapshot{
+		{Symbol: "rb2405", Direction: "long", Position: 2, PositionCost: 7000, UseMargin: 700},
+		{Symbol: "ag2606", Direction: "long", Position: 0},
+		{Symbol: "rb2405", Direction: "short", Position: 1, PositionCost: 3600, UseMargin: 360},
+	}
+	prices := map[string]float64{"rb2405": 3550}
+	lines := paperSettlementPositions(positions, prices, func(pos PositionSnapshot) float64 {
+		return float64(pos.Position) * 10
+	})
+	if len(lines) != 1 {
+		t.Fatalf("lines = %+v, want one merged rb2405 line", lines)
+	}
+	line := lines[0]
+	if line.LongPosition != 2 || line.ShortPosition != 1 || line.LongAvgPrice != 3500 || line.ShortAvgPrice != 3600 {
+		t.Fatalf("position line = %+v", line)
+	}
+	if line.SettlementPrice != 3550 || line.PositionProfit != 30 || line.Margin != 1060 {
+		t.Fatalf("position line totals = %+v", line)
+	}
+}
+
+func TestRollPaperPositionsToYesterday(t *testing.T) {
+	t.Parallel()
+
+	items := rollPaperPositionsToYesterday([]PositionSnapshot{{Symbol: "rb2405", Position: 3, YdPosition: 1, TodayPosition: 2}})
+	if items[0].YdPosition != 3 || items[0].TodayPosition != 0 || items[0].Position != 3 {
+		t.Fatalf("rolled position = %+v", items[0])
+	}
+}
@@ -358,6 +358,11 @@ func prioritizeProbeSymbol(items []instrumentKey, probe string) []instrumentKey
 }
 
 func (s *Service) applyPositions(items []PositionSnapshot) {
+	if report, err := s.reconcileWithBroker(items, "position_sync", false); err != nil {
+		logger.Warn("trade position reconcile failed", "account_id", s.accountID, "error", err)
+	} else if s.holdPositionsForReconcile(report) {
+		return
+	}
 	if err := s.store.ReplacePositions(s.accountID, items); err != nil {
 		s.logLaneError(newLaneThrottle("auto_pos", "positions", s.cfg), err, "", "")
 		return
@@ -292,6 +292,33 @@ LIMIT 1`,
 	return params, nil
 }
 
+// commissionRate 返回合约最近一次同步的手续费率。
+func (c *rateCatalog) commissionRate(instrumentID string, exchangeID string) (CommissionRateSnapshot, bool, error) {
+	if c == nil || c.db == nil {
+		return CommissionRateSnapshot{}, false, nil
+	}
+	out := CommissionRateSnapshot{InstrumentID: strings.TrimSpace(instrumentID), ExchangeID: strings.TrimSpace(exchangeID)}
+	if out.InstrumentID == "" || out.ExchangeID == "" {
+		return out, false, nil
+	}
+	err := c.db.QueryRow(
+		`SELECT open_ratio_by_money,open_ratio_by_volume,close_ratio_by_money,close_ratio_by_volume,close_today_ratio_by_money,close_today_ratio_by_volume,updated_at
+FROM ctp_commission_rates
+WHERE instrument_id=? AND exchange_id=?
+ORDER BY sync_trading_day DESC, updated_at DESC
+LIMIT 1`,
+		out.InstrumentID,
+		out.ExchangeID,
+	).Scan(&out.OpenRatioByMoney, &out.OpenRatioByVolume, &out.CloseRatioByMoney, &out.CloseRatioByVolume, &out.CloseTodayRatioByMoney, &out.CloseTodayRatioByVolume, &out.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return out, false, nil
+		}
+		return out, false, err
+	}
+	return out, true, nil
+}
+
 func (c *rateCatalog) instrumentVolumeMultiple(instrumentID string, exchangeID string) (int, error) {
 	if c == nil || c.db == nil {
 		return 0, nil
@@ -0,0 +1,511 @@
+// reconcile.go 负责持仓三方对账。
+// 它把券商查询持仓、本地成交推导持仓和策略子持仓放在一起比较，生成带严重级别的差异报告；
+// 出现差异时不再静默覆盖本地持仓，而是挂起报告并告警，等待人工选择“采用券商”或“采用本地”。
+package trade
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+)
+
+const (
+	ReconcileSeverityOK       = "ok"
+	ReconcileSeverityWarning  = "warning"
+	ReconcileSeverityCritical = "critical"
+
+	ReconcileStatusClean    = "clean"
+	ReconcileStatusOpen     = "open"
+	ReconcileStatusResolved = "resolved"
+
+	ReconcileActionAdoptBroker = "adopt_broker"
+	ReconcileActionAdoptLocal  = "adopt_local"
+
+	ReconcileCheckBrokerLocal    = "broker_vs_local"
+	ReconcileCheckStrategyBroker = "strategy_vs_broker"
+)
+
+var ErrReconcileReportNotOpen = errors.New("reconcile report is not open")
+
+// StrategyPositionView 是策略侧某个实例在某合约上的带符号子持仓，多头为正、空头为负。
+type StrategyPositionView struct {
+	InstanceID string  `json:"instance_id"`
+	Symbol     string  `json:"symbol"`
+	Position   float64 `json:"position"`
+}
+
+type PositionReconcileDiff struct {
+	// Check 表示差异来自哪一组比较，如 broker_vs_local 或 strategy_vs_broker。
+	Check string `json:"check"`
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// Direction 是持仓方向；策略比较使用 net 表示净持仓。
+	Direction string `json:"direction"`
+	// BrokerPosition 是券商口径持仓；strategy_vs_broker 时为净持仓。
+	BrokerPosition int `json:"broker_position"`
+	// LocalPosition 是本地成交推导持仓。
+	LocalPosition int `json:"local_position"`
+	// BrokerTodayPosition 是券商口径今仓。
+	BrokerTodayPosition int `json:"broker_today_position"`
+	// LocalTodayPosition 是本地推导今仓。
+	LocalTodayPosition int `json:"local_today_position"`
+	// StrategyPosition 是策略子持仓合计的净持仓。
+	StrategyPosition float64 `json:"strategy_position"`
+	// Severity 是该条差异的严重级别。
+	Severity string `json:"severity"`
+	// Reason 是差异说明。
+	Reason string `json:"reason"`
+}
+
+type PositionReconcileReport struct {
+	ReportID          string                  `json:"report_id"`
+	AccountID         string                  `json:"account_id"`
+	TradingDay        string                  `json:"trading_day"`
+	Trigger           string                  `json:"trigger"`
+	Status            string                  `json:"status"`
+	Severity          string                  `json:"severity"`
+	Diffs             []PositionReconcileDiff `json:"diffs"`
+	BrokerPositions   []PositionSnapshot      `json:"broker_positions"`
+	LocalPositions    []PositionSnapshot      `json:"local_positions"`
+	StrategyPositions []StrategyPositionView  `json:"strategy_positions"`
+	Resolution        string                  `json:"resolution,omitempty"`
+	ResolvedAt        *time.Time              `json:"resolved_at,omitempty"`
+	CreatedAt         time.Time               `json:"created_at"`
+}
+
+// ReconcilePositions 比较三方持仓并返回差异列表；没有差异时返回空切片。
+func ReconcilePositions(broker []PositionSnapshot, local []PositionSnapshot, strategy []StrategyPositionView) []PositionReconcileDiff {
+	type sideKey struct {
+		Symbol    string
+		Direction string
+	}
+	type sideValue struct {
+		Symbol string
+		Total  int
+		Today  int
+	}
+	collect := func(items []PositionSnapshot) map[sideKey]sideValue {
+		out := make(map[sideKey]sideValue, len(items))
+		for _, item := range items {
+			key := sideKey{Symbol: strings.ToLower(strings.TrimSpace(item.Symbol)), Direction: strings.TrimSpace(item.Direction)}
+			if key.Symbol == "" {
+				continue
+			}
+			cur := out[key]
+			cur.Symbol = firstNonEmpty(cur.Symbol, strings.TrimSpace(item.Symbol))
+			cur.Total += item.Position
+			cur.Today += item.TodayPosition
+			out[key] = cur
+		}
+		return out
+	}
+	brokerSides := collect(broker)
+	localSides := collect(local)
+	keys := make([]sideKey, 0, len(brokerSides)+len(localSides))
+	seen := make(map[sideKey]struct{}, len(brokerSides)+len(localSides))
+	for _, src := range []map[sideKey]sideValue{brokerSides, localSides} {
+		for key := range src {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Symbol == keys[j].Symbol {
+			return keys[i].Direction < keys[j].Direction
+		}
+		return keys[i].Symbol < keys[j].Symbol
+	})
+	out := make([]PositionReconcileDiff, 0)
+	for _, key := range keys {
+		b := brokerSides[key]
+		l := localSides[key]
+		if b.Total == l.Total && b.Today == l.Today {
+			continue
+		}
+		diff := PositionReconcileDiff{
+			Check:               ReconcileCheckBrokerLocal,
+			Symbol:              firstNonEmpty(b.Symbol, l.Symbol),
+			Direction:           key.Direction,
+			BrokerPosition:      b.Total,
+			LocalPosition:       l.Total,
+			BrokerTodayPosition: b.Today,
+			LocalTodayPosition:  l.Today,
+		}
+		if b.Total != l.Total {
+			diff.Severity = ReconcileSeverityCritical
+			diff.Reason = fmt.Sprintf("position mismatch: broker %d, local %d", b.Total, l.Total)
+		} else {
+			diff.Severity = ReconcileSeverityWarning
+			diff.Reason = fmt.Sprintf("today/yesterday split mismatch: broker today %d, local today %d", b.Today, l.Today)
+		}
+		out = append(out, diff)
+	}
+
+	strategyNet := make(map[string]float64, len(strategy))
+	strategySymbol := make(map[string]string, len(strategy))
+	for _, item := range strategy {
+		key := strings.ToLower(strings.TrimSpace(item.Symbol))
+		if key == "" {
+			continue
+		}
+		strategyNet[key] += item.Position
+		if _, ok := strategySymbol[key]; !ok {
+			strategySymbol[key] = strings.TrimSpace(item.Symbol)
+		}
+	}
+	symbols := make([]string, 0, len(strategyNet))
+	for key := range strategyNet {
+		symbols = append(symbols, key)
+	}
+	sort.Strings(symbols)
+	for _, key := range symbols {
+		net := strategyNet[key]
+		brokerNet := brokerSides[sideKey{Symbol: key, Direction: "long"}].Total - brokerSides[sideKey{Symbol: key, Direction: "short"}].Total
+		if math.Abs(net-float64(brokerNet)) < 1e-9 {
+			continue
+		}
+		diff := PositionReconcileDiff{
+			Check:            ReconcileCheckStrategyBroker,
+			Symbol:           strategySymbol[key],
+			Direction:        "net",
+			BrokerPosition:   brokerNet,
+			StrategyPosition: net,
+		}
+		// 账户里可以同时存在手工仓位，所以策略净持仓小于账户净持仓只提示；
+		// 策略认为自己持有的比账户实际更多或方向相反时，后续调仓一定会算错，按严重处理。
+		if math.Abs(net) > math.Abs(float64(brokerNet))+1e-9 || net*float64(brokerNet) < 0 {
+			diff.Severity = ReconcileSeverityCritical
+			diff.Reason = fmt.Sprintf("strategy net %.4g exceeds broker net %d", net, brokerNet)
+		} else {
+			diff.Severity = ReconcileSeverityWarning
+			diff.Reason = fmt.Sprintf("broker net %d includes %.4g lots not owned by strategies", brokerNet, float64(brokerNet)-net)
+		}
+		out = append(out, diff)
+	}
+	return out
+}
+
+// ReconcileSeverity 返回差异列表中的最高严重级别。
+func ReconcileSeverity(diffs []PositionReconcileDiff) string {
+	severity := ReconcileSeverityOK
+	for _, item := range diffs {
+		switch item.Severity {
+		case ReconcileSeverityCritical:
+			return ReconcileSeverityCritical
+		case ReconcileSeverityWarning:
+			severity = ReconcileSeverityWarning
+		}
+	}
+	return severity
+}
+
+// DerivePositionsFromTrades 以 startOfDay 的昨仓为起点，按时间顺序回放成交推导出本地持仓。
+// 普通平仓按交易所规则扣减今昨仓：大商所、郑商所、广期所先平昨仓，其余先平今仓。
+func DerivePositionsFromTrades(startOfDay []PositionSnapshot, trades []TradeRecord) []PositionSnapshot {
+	positions := make([]PositionSnapshot, 0, len(startOfDay))
+	for _, item := range startOfDay {
+		if item.YdPosition <= 0 {
+			continue
+		}
+		base := item
+		if item.Position > 0 {
+			ratio := float64(item.YdPosition) / float64(item.Position)
+			base.OpenCost = item.OpenCost * ratio
+			base.PositionCost = item.PositionCost * ratio
+			base.UseMargin = item.UseMargin * ratio
+		}
+		base.Position = item.YdPosition
+		base.TodayPosition = 0
+		positions = append(positions, base)
+	}
+	ordered := append([]TradeRecord(nil), trades...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].TradeTime.Equal(ordered[j].TradeTime) {
+			return ordered[i].TradeID < ordered[j].TradeID
+		}
+		return ordered[i].TradeTime.Before(ordered[j].TradeTime)
+	})
+	for _, tr := range ordered {
+		positions = applyFilledTradeToPositions(positions, tr)
+	}
+	return positions
+}
+
+// SetStrategyPositionProvider 注册策略子持仓来源，供对账时读取。
+func (s *Service) SetStrategyPositionProvider(fn func() []StrategyPositionView) {
+	s.reconcileMu.Lock()
+	s.strategyPositions = fn
+	s.reconcileMu.Unlock()
+}
+
+// ReconcilePositions 立即执行一次三方对账。实盘会单独查询券商持仓，但不会用结果覆盖本地持仓。
+func (s *Service) ReconcilePositions(_ context.Context, trigger string) (PositionReconcileReport, error) {
+	broker, err := s.brokerPositionsForReconcile()
+	if err != nil {
+		return PositionReconcileReport{}, err
+	}
+	return s.reconcileWithBroker(broker, firstNonEmpty(strings.TrimSpace(trigger), "manual"), true)
+}
+
+func (s *Service) ReconcileReports(limit int) ([]PositionReconcileReport, error) {
+	return s.store.ListReconcileReports(s.accountID, limit)
+}
+
+// ResolveReconcile 按 adopt_broker 或 adopt_local 处理一份未关闭的对账报告，并写回本地持仓。
+func (s *Service) ResolveReconcile(reportID string, action string) (PositionReconcileReport, error) {
+	report, err := s.store.GetReconcileReport(strings.TrimSpace(reportID))
+	if err != nil {
+		return PositionReconcileReport{}, err
+	}
+	if report.AccountID != s.accountID {
+		return report, sql.ErrNoRows
+	}
+	if report.Status != ReconcileStatusOpen {
+		return report, ErrReconcileReportNotOpen
+	}
+	var adopted []PositionSnapshot
+	switch strings.TrimSpace(action) {
+	case ReconcileActionAdoptBroker:
+		adopted = report.BrokerPositions
+	case ReconcileActionAdoptLocal:
+		adopted = report.LocalPositions
+	default:
+		return report, fmt.Errorf("unsupported reconcile action %q", action)
+	}
+	now := time.Now()
+	for i := range adopted {
+		adopted[i].AccountID = s.accountID
+		adopted[i].UpdatedAt = now
+	}
+	if err := s.store.ReplacePositions(s.accountID, adopted); err != nil {
+		return report, err
+	}
+	report.Status = ReconcileStatusResolved
+	report.Resolution = strings.TrimSpace(action)
+	report.ResolvedAt = &now
+	if err := s.store.ResolveReconcileReport(report.ReportID, report.Resolution, now); err != nil {
+		return report, err
+	}
+	s.mu.Lock()
+	s.positions = adopted
+	s.mu.Unlock()
+	s.reconcileMu.Lock()
+	if s.openReconcileID == report.ReportID {
+		s.openReconcileID = ""
+		s.openReconcileFingerprint = ""
+		s.openReconcileHolds = false
+	}
+	if report.Resolution == ReconcileActionAdoptLocal {
+		// 采用本地后券商侧差异依旧存在，记住指纹避免下一轮同步立刻重新挂起同一份差异。
+		s.acceptedReconcileFingerprint = reconcileFingerprint(report.Diffs)
+	} else {
+		s.acceptedReconcileFingerprint = ""
+	}
+	s.reconcileMu.Unlock()
+	s.setStatus(func(st *TradeStatus) {
+		st.ReconcileStatus = ReconcileStatusResolved
+		st.ReconcileSeverity = ReconcileSeverityOK
+	})
+	logger.Info("trade position reconcile resolved", "account_id", s.accountID, "report_id", report.ReportID, "action", report.Resolution)
+	s.broadcast("trade_reconcile_update", report)
+	s.broadcast("trade_position_update", map[string]any{"items": adopted})
+	return report, nil
+}
+
+func (s *Service) brokerPositionsForReconcile() ([]PositionSnapshot, error) {
+	if s.paper {
+		return s.store.ListPositions(s.accountID)
+	}
+	gw := s.gateway
+	if s.autoPosGateway != nil {
+		gw = s.autoPosGateway
+	}
+	if gw == nil {
+		return nil, ErrTradeServiceOffline
+	}
+	items, err := gw.RefreshPositions()
+	s.auditQuery("positions_reconcile", err)
+	return items, err
+}
+
+func (s *Service) localPositionsForReconcile(broker []PositionSnapshot) ([]PositionSnapshot, string, error) {
+	trades, err := s.store.ListTrades(s.accountID, 100000)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.paper {
+		return DerivePositionsFromTrades(nil, trades), "", nil
+	}
+	// 实盘昨仓以券商返回的 YdPosition 为准（日内不变），本地只负责核对当日成交是否齐全。
+	tradingDay := s.currentTradingDay()
+	todays := make([]TradeRecord, 0, len(trades))
+	for _, tr := range trades {
+		if tradingDay == "" || strings.TrimSpace(tr.TradingDay) == tradingDay {
+			todays = append(todays, tr)
+		}
+	}
+	return DerivePositionsFromTrades(broker, todays), tradingDay, nil
+}
+
+func (s *Service) strategyPositionsForReconcile() []StrategyPositionView {
+	s.reconcileMu.Lock()
+	fn := s.strategyPositions
+	s.reconcileMu.Unlock()
+	if fn == nil {
+		return nil
+	}
+	return fn()
+}
+
+// reconcileWithBroker 用给定的券商持仓做一次对账。persistClean 为 false 时只在出现新差异时落库，
+// 供持仓同步 lane 高频调用。
+func (s *Service) reconcileWithBroker(broker []PositionSnapshot, trigger string, persistClean bool) (PositionReconcileReport, error) {
+	local, tradingDay, err := s.localPositionsForReconcile(broker)
+	if err != nil {
+		return PositionReconcileReport{}, err
+	}
+	strategyItems := s.strategyPositionsForReconcile()
+	diffs := ReconcilePositions(broker, local, strategyItems)
+	now := time.Now()
+	report := PositionReconcileReport{
+		ReportID:          mustCommandID("rec"),
+		AccountID:         s.accountID,
+		TradingDay:        tradingDay,
+		Trigger:           trigger,
+		Status:            ReconcileStatusClean,
+		Severity:          ReconcileSeverity(diffs),
+		Diffs:             diffs,
+		BrokerPositions:   broker,
+		LocalPositions:    local,
+		StrategyPositions: strategyItems,
+		CreatedAt:         now,
+	}
+	fingerprint := reconcileFingerprint(diffs)
+	s.reconcileMu.Lock()
+	if len(diffs) == 0 {
+		// 券商与本地重新一致后，之前接受的差异不再适用，之后的同步照常覆盖。
+		s.acceptedReconcileFingerprint = ""
+	}
+	openID := s.openReconcileID
+	openFingerprint := s.openReconcileFingerprint
+	accepted := s.acceptedReconcileFingerprint
+	s.reconcileMu.Unlock()
+	if len(diffs) > 0 && fingerprint != accepted {
+		report.Status = ReconcileStatusOpen
+	}
+	s.setStatus(func(st *TradeStatus) {
+		st.LastReconcileAt = now
+		st.ReconcileSeverity = report.Severity
+		if report.Status == ReconcileStatusOpen || openID != "" {
+			st.ReconcileStatus = ReconcileStatusOpen
+		} else {
+			st.ReconcileStatus = ReconcileStatusClean
+			st.ReconcileSeverity = ReconcileSeverityOK
+		}
+	})
+	if report.Status != ReconcileStatusOpen {
+		s.reconcileMu.Lock()
+		s.pendingReconcileFingerprint = ""
+		s.reconcileMu.Unlock()
+		if persistClean {
+			if err := s.store.SaveReconcileReport(report); err != nil {
+				return report, err
+			}
+			s.broadcast("trade_reconcile_update", report)
+		}
+		return report, nil
+	}
+	if openID != "" && openFingerprint == fingerprint {
+		report.ReportID = openID
+		return report, nil
+	}
+	if !persistClean {
+		s.reconcileMu.Lock()
+		pending := s.pendingReconcileFingerprint
+		s.pendingReconcileFingerprint = fingerprint
+		s.reconcileMu.Unlock()
+		if pending != fingerprint {
+			// 首次观察到的差异先暂缓覆盖，下一轮同步仍一致时再落库告警。
+			report.ReportID = ""
+			return report, nil
+		}
+	}
+	if err := s.store.SaveReconcileReport(report); err != nil {
+		return report, err
+	}
+	s.reconcileMu.Lock()
+	s.openReconcileID = report.ReportID
+	s.openReconcileFingerprint = fingerprint
+	s.openReconcileHolds = hasBrokerLocalQuantityDiff(diffs)
+	s.reconcileMu.Unlock()
+	logger.Warn(
+		"trade position reconcile mismatch",
+		"account_id", s.accountID,
+		"report_id", report.ReportID,
+		"trigger", trigger,
+		"severity", report.Severity,
+		"diff_count", len(diffs),
+	)
+	s.broadcast("trade_reconcile_alert", report)
+	return report, nil
+}
+
+// holdPositionsForReconcile 表示本轮券商持仓是否应暂缓写入本地。
+// 只有券商与本地总持仓数量不一致时才暂缓；仅今昨仓拆分或策略子持仓不一致时照常同步，只告警。
+// 人工“采用本地”后，被接受的那份数量差异还在时也继续暂缓，否则下一轮同步又会用券商持仓覆盖采用的本地持仓。
+func (s *Service) holdPositionsForReconcile(report PositionReconcileReport) bool {
+	quantity := hasBrokerLocalQuantityDiff(report.Diffs)
+	if report.Status == ReconcileStatusOpen && quantity {
+		return true
+	}
+	s.reconcileMu.Lock()
+	defer s.reconcileMu.Unlock()
+	if s.openReconcileID != "" && s.openReconcileHolds {
+		return true
+	}
+	return quantity && s.acceptedReconcileFingerprint != "" && reconcileFingerprint(report.Diffs) == s.acceptedReconcileFingerprint
+}
+
+func hasBrokerLocalQuantityDiff(diffs []PositionReconcileDiff) bool {
+	for _, item := range diffs {
+		if item.Check == ReconcileCheckBrokerLocal && item.BrokerPosition != item.LocalPosition {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) runReconcileLoop() {
+	interval := time.Duration(s.cfg.ReconcileIntervalMS) * time.Millisecond
+	if interval <= 0 {
+		return
+	}
+	for sleepOrDone(s.ctx, interval) {
+		if _, err := s.ReconcilePositions(s.ctx, "scheduled"); err != nil {
+			logger.Warn("trade position reconcile failed", "account_id", s.accountID, "error", err)
+		}
+	}
+}
+
+func reconcileFingerprint(diffs []PositionReconcileDiff) string {
+	if len(diffs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(diffs))
+	for _, item := range diffs {
+		parts = append(parts, fmt.Sprintf("%s|%s|%s|%d|%d|%d|%d|%.4f",
+			item.Check, strings.ToLower(item.Symbol), item.Direction,
+			item.BrokerPosition, item.LocalPosition, item.BrokerTodayPosition, item.LocalTodayPosition, item.StrategyPosition))
+	}
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,206 @@
+package trade
+
+import (
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/testmysql"
+)
+
+func TestReconcilePositionsCleanWhenSidesMatch(t *testing.T) {
+	t.Parallel()
+
+	broker := []PositionSnapshot{{Symbol: "rb2405", Direction: "long", Position: 3, TodayPosition: 1, YdPosition: 2}}
+	local := []PositionSnapshot{{Symbol: "RB2405", Direction: "long", Position: 3, TodayPosition: 1}}
+	strategy := []StrategyPositionView{{InstanceID: "a", Symbol: "rb2405", Position: 2}, {InstanceID: "b", Symbol: "rb2405", Position: 1}}
+
+	diffs := ReconcilePositions(broker, local, strategy)
+	if len(diffs) != 0 {
+		t.Fatalf("diffs = %+v, want none", diffs)
+	}
+	if got := ReconcileSeverity(diffs); got != ReconcileSeverityOK {
+		t.Fatalf("ReconcileSeverity() = %q, want %q", got, ReconcileSeverityOK)
+	}
+}
+
+func TestReconcilePositionsClassifiesSeverity(t *testing.T) {
+	t.Parallel()
+
+	broker := []PositionSnapshot{
+		{Symbol: "rb2405", Direction: "long", Position: 3, TodayPosition: 2},
+		{Symbol: "ag2406", Direction: "short", Position: 2, TodayPosition: 2},
+	}
+	local := []PositionSnapshot{
+		{Symbol: "rb2405", Direction: "long", Position: 3, TodayPosition: 1},
+		{Symbol: "ag2406", Direction: "short", Position: 1, TodayPosition: 1},
+	}
+	strategy := []StrategyPositionView{
+		{InstanceID: "a", Symbol: "rb2405", Position: 1},
+		{InstanceID: "b", Symbol: "ag2406", Position: 1},
+	}
+
+	diffs := ReconcilePositions(broker, local, strategy)
+	want := map[string]string{
+		ReconcileCheckBrokerLocal + "|ag2406":    ReconcileSeverityCritical,
+		ReconcileCheckBrokerLocal + "|rb2405":    ReconcileSeverityWarning,
+		ReconcileCheckStrategyBroker + "|ag2406": ReconcileSeverityCritical,
+		ReconcileCheckStrategyBroker + "|rb2405": ReconcileSeverityWarning,
+	}
+	if len(diffs) != len(want) {
+		t.Fatalf("len(diffs) = %d, want %d: %+v", len(diffs), len(want), diffs)
+	}
+	for _, item := range diffs {
+		key := item.Check + "|" + item.Symbol
+		if want[key] != item.Severity {
+			t.Fatalf("diff %s severity = %q, want %q", key, item.Severity, want[key])
+		}
+	}
+	if got := ReconcileSeverity(diffs); got != ReconcileSeverityCritical {
+		t.Fatalf("ReconcileSeverity() = %q, want %q", got, ReconcileSeverityCritical)
+	}
+}
+
+func TestDerivePositionsFromTradesStartsFromYesterday(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	startOfDay := []PositionSnapshot{{Symbol: "rb2405", Direction: "long", Position: 5, TodayPosition: 3, YdPosition: 2}}
+	trades := []TradeRecord{
+		{TradeID: "2", Symbol: "rb2405", Direction: "sell", OffsetFlag: "close", Volume: 1, Price: 100, TradeTime: base.Add(2 * time.Minute)},
+		{TradeID: "1", Symbol: "rb2405", Direction: "buy", OffsetFlag: "open", Volume: 3, Price: 100, TradeTime: base.Add(time.Minute)},
+	}
+
+	got := DerivePositionsFromTrades(startOfDay, trades)
+	if len(got) != 1 {
+		t.Fatalf("len(got) = %d, want 1: %+v", len(got), got)
+	}
+	if got[0].Position != 4 || got[0].TodayPosition != 2 || got[0].YdPosition != 2 {
+		t.Fatalf("position = %+v, want total 4 today 2 yd 2", got[0])
+	}
+}
+
+func TestDerivePositionsFromTradesClosesByExchangeRule(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	cases := []struct {
+		exchange  string
+		wantToday int
+		wantYd    int
+	}{
+		{exchange: "SHFE", wantToday: 1, wantYd: 2},
+		{exchange: "CFFEX", wantToday: 1, wantYd: 2},
+		{exchange: "DCE", wantToday: 3, wantYd: 0},
+		{exchange: "CZCE", wantToday: 3, wantYd: 0},
+	}
+	for _, tc := range cases {
+		startOfDay := []PositionSnapshot{{Symbol: "m2405", Exchange: tc.exchange, Direction: "long", Position: 2, YdPosition: 2}}
+		trades := []TradeRecord{
+			{TradeID: "1", Symbol: "m2405", ExchangeID: tc.exchange, Direction: "buy", OffsetFlag: "open", Volume: 3, Price: 100, TradeTime: base},
+			{TradeID: "2", Symbol: "m2405", ExchangeID: tc.exchange, Direction: "sell", OffsetFlag: "close", Volume: 2, Price: 101, TradeTime: base.Add(time.Minute)},
+		}
+		got := DerivePositionsFromTrades(startOfDay, trades)
+		if len(got) != 1 || got[0].Position != 3 || got[0].TodayPosition != tc.wantToday || got[0].YdPosition != tc.wantYd {
+			t.Fatalf("%s positions = %+v, want total 3 today %d yd %d", tc.exchange, got, tc.wantToday, tc.wantYd)
+		}
+	}
+}
+
+func TestReconcileHoldsOnlyOnQuantityMismatch(t *testing.T) {
+	t.Parallel()
+
+	split := ReconcilePositions(
+		[]PositionSnapshot{{Symbol: "m2405", Direction: "long", Position: 3, TodayPosition: 3}},
+		[]PositionSnapshot{{Symbol: "m2405", Direction: "long", Position: 3, TodayPosition: 1}},
+		nil,
+	)
+	if len(split) != 1 || hasBrokerLocalQuantityDiff(split) {
+		t.Fatalf("today/yesterday split should warn without holding sync: %+v", split)
+	}
+	quantity := ReconcilePositions(
+		[]PositionSnapshot{{Symbol: "m2405", Direction: "long", Position: 3}},
+		[]PositionSnapshot{{Symbol: "m2405", Direction: "long", Position: 2}},
+		nil,
+	)
+	if !hasBrokerLocalQuantityDiff(quantity) {
+		t.Fatalf("quantity mismatch should hold sync: %+v", quantity)
+	}
+}
+
+func TestApplyPositionsKeepsAdoptedLocalWhileAcceptedDiffPersists(t *testing.T) {
+	dsn := testmysql.NewDatabase(t)
+	svc := newReplayPaperServiceForTest(t, dsn)
+
+	now := time.Now()
+	if err := svc.store.AppendTrade(TradeRecord{
+		AccountID:  svc.accountID,
+		TradeID:    "trade-local",
+		OrderRef:   "cmd-local",
+		ExchangeID: "SHFE",
+		Symbol:     "rb2505",
+		Direction:  "buy",
+		OffsetFlag: "open",
+		Price:      100,
+		Volume:     2,
+		TradeTime:  now,
+		TradingDay: now.Format("20060102"),
+		ReceivedAt: now,
+	}); err != nil {
+		t.Fatalf("append local trade failed: %v", err)
+	}
+	broker := func(volume int) []PositionSnapshot {
+		return []PositionSnapshot{{AccountID: svc.accountID, Symbol: "rb2505", Exchange: "SHFE", Direction: "long", TodayPosition: volume, Position: volume, UpdatedAt: now}}
+	}
+	storedLong := func() int {
+		t.Helper()
+		items, err := svc.store.ListPositions(svc.accountID)
+		if err != nil {
+			t.Fatalf("list positions failed: %v", err)
+		}
+		total := 0
+		for _, item := range items {
+			if item.Symbol == "rb2505" && item.Direction == "long" {
+				total += item.Position
+			}
+		}
+		return total
+	}
+
+	// 连续两轮同一差异才挂起报告。
+	svc.applyPositions(broker(3))
+	svc.applyPositions(broker(3))
+	svc.reconcileMu.Lock()
+	reportID := svc.openReconcileID
+	svc.reconcileMu.Unlock()
+	if reportID == "" {
+		t.Fatal("quantity mismatch should open a reconcile report")
+	}
+	if _, err := svc.ResolveReconcile(reportID, ReconcileActionAdoptLocal); err != nil {
+		t.Fatalf("ResolveReconcile(adopt_local) error = %v", err)
+	}
+	if got := storedLong(); got != 2 {
+		t.Fatalf("position after adopt_local = %d, want local 2", got)
+	}
+
+	svc.applyPositions(broker(3))
+	if got := storedLong(); got != 2 {
+		t.Fatalf("position sync after adopt_local = %d, want adopted local 2 kept", got)
+	}
+	svc.reconcileMu.Lock()
+	reopened := svc.openReconcileID
+	svc.reconcileMu.Unlock()
+	if reopened != "" {
+		t.Fatalf("accepted diff should not reopen a report, got %s", reopened)
+	}
+
+	svc.applyPositions(broker(2))
+	if got := storedLong(); got != 2 {
+		t.Fatalf("position after broker converges = %d, want 2", got)
+	}
+	svc.reconcileMu.Lock()
+	accepted := svc.acceptedReconcileFingerprint
+	svc.reconcileMu.Unlock()
+	if accepted != "" {
+		t.Fatalf("accepted fingerprint should clear once broker and local agree, got %q", accepted)
+	}
+}
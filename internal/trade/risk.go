@@ -54,7 +54,7 @@ func ValidateSubmit(ctx context.Context, status TradeStatus, cfg config.TradeCon
 	default:
 		return commandID, errors.New("offset_flag must be open/close/close_today/close_yesterday")
 	}
-	if req.OffsetFlag != "open" && closableVolume(positions, req.Symbol, req.Direction) < req.Volume {
+	if req.OffsetFlag != "open" && closableVolumeForOffset(positions, req.Symbol, req.ExchangeID, req.Direction, req.OffsetFlag) < req.Volume {
 		return commandID, errors.New("close volume exceeds available position")
 	}
 	if req.OffsetFlag == "open" && account.Available <= 0 {
@@ -67,8 +67,10 @@ func ValidateCancel(ctx context.Context, req CancelOrderRequest, orderRec OrderR
 	if err := order.EnsureReplaySafe(ctx, req.CommandID); err != nil {
 		return err
 	}
-	if strings.TrimSpace(req.Reason) != "manual_cancel" {
-		return errors.New("only manual cancel is allowed")
+	switch strings.TrimSpace(req.Reason) {
+	case "manual_cancel", algoCancelReason:
+	default:
+		return errors.New("only manual or algo cancel is allowed")
 	}
 	switch strings.TrimSpace(orderRec.OrderStatus) {
 	case "all_traded", "canceled", "rejected":
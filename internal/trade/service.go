@@ -78,7 +78,35 @@ type Service struct {
 	metaSyncTradingDay string
 	feeThrottle        laneThrottle
 	marginThrottle     laneThrottle
-}
+	// reconcileMu 保护持仓对账相关状态。
+	reconcileMu sync.Mutex
+	// strategyPositions 返回策略子持仓，用于策略与账户净持仓核对。
+	strategyPositions func() []StrategyPositionView
+	// openReconcileID 是当前未处理的对账报告，非空时持仓同步不再覆盖本地持仓。
+	openReconcileID          string
+	openReconcileFingerprint string
+	openReconcileHolds       bool
+	// pendingReconcileFingerprint 是持仓同步首次观察到的差异，连续两次一致才挂起报告，避免成交回报未到时误报。
+	pendingReconcileFingerprint string
+	// acceptedReconcileFingerprint 是人工“采用本地”后接受的差异，不再重复告警。
+	acceptedReconcileFingerprint string
+	// algoMu 保护进行中的算法母单和算法行情来源。
+	algoMu sync.Mutex
+	// parents 是进行中的算法母单，结束后移出内存，只保留在存储中。
+	parents       map[string]*ParentOrder
+	algoQuote     AlgoQuoteFunc
+	volumeProfile VolumeProfileFunc
+	lotBars       LotBarFunc
+	// paperSettledDay 是回放模拟账户最近一次日终结算的交易日，该日及之前的成交形成的持仓记为昨仓。
+	paperSettledDay string
+	// paperSettle 是最近一次日终结算时的累计资金口径。
+	paperSettle paperSettleMark
+}
+
+const (
+	PaperLiveAccountID   = "paper_live"
+	PaperReplayAccountID = "paper_replay"
+)
 
 const replayPaperInitialBalance = 100_000
 const legacyPaperInitialBalance = 1_000_000
@@ -206,8 +234,8 @@ func NewPaperService(cfg config.TradeConfig, accountID string, dsn string, regis
 		store:        store,
 		accountID:    accountID,
 		paper:        true,
-		replayPaper:  strings.EqualFold(accountID, "paper_replay"),
-		livePaper:    strings.EqualFold(accountID, "paper_live"),
+		replayPaper:  isPaperLedgerOf(accountID, PaperReplayAccountID),
+		livePaper:    isPaperLedgerOf(accountID, PaperLiveAccountID),
 		subs:         make(map[chan EventEnvelope]struct{}),
 		queueCap:     queueCfg.TradeEventCapacity,
 		pending:      make(map[string]OrderRecord),
@@ -256,6 +284,17 @@ func NewPaperServiceWithMeta(cfg config.TradeConfig, ctpCfg config.CTPConfig, ac
 	if !s.livePaper {
 		return s, nil
 	}
+	if isPaperSubLedger(accountID) {
+		// 子账户账本只借用共享费率目录计算保证金和手续费，元数据同步由主账本负责。
+		if strings.TrimSpace(ctpCfg.SharedMetaDSN) != "" {
+			catalog, catalogErr := newRateCatalog(ctpCfg.SharedMetaDSN)
+			if catalogErr != nil {
+				return nil, catalogErr
+			}
+			s.rateCatalog = catalog
+		}
+		return s, nil
+	}
 	if strings.TrimSpace(ctpCfg.TraderFrontAddr) == "" {
 		return s, nil
 	}
@@ -275,6 +314,38 @@ func NewPaperServiceWithMeta(cfg config.TradeConfig, ctpCfg config.CTPConfig, ac
 	return s, nil
 }
 
+// PaperAccountID 返回某个交易账户在模拟后端中的账本标识；主账户沿用 base 本身，子账户为 base:accountID。
+func PaperAccountID(base string, accountID string) string {
+	accountID = strings.TrimSpace(accountID)
+	if accountID == "" {
+		return base
+	}
+	return base + ":" + accountID
+}
+
+func isPaperLedgerOf(accountID string, base string) bool {
+	return strings.EqualFold(accountID, base) || strings.HasPrefix(strings.ToLower(accountID), base+":")
+}
+
+func isPaperSubLedger(accountID string) bool {
+	return strings.Contains(accountID, ":")
+}
+
+// SetTraderAPIFactory 让实盘服务的全部交易网关改用指定的 Trader API，需在 Start 之前调用；
+// 集成测试借此接入 MockTraderFront。
+func (s *Service) SetTraderAPIFactory(fn TraderAPIFactory) {
+	for _, gw := range []*CTPGateway{s.tradeOpGateway, s.autoPosGateway, s.feeGateway, s.marginGateway} {
+		if gw != nil {
+			gw.SetTraderAPIFactory(fn)
+		}
+	}
+}
+
+// AccountID 返回服务绑定的交易账户标识。
+func (s *Service) AccountID() string {
+	return s.accountID
+}
+
 func (s *Service) Close() error {
 	var err error
 	s.closeOnce.Do(func() {
@@ -354,6 +425,7 @@ func (s *Service) Start() error {
 	ch, _ := s.gateway.Subscribe()
 	go s.forwardGatewayEvents(ch)
 	go s.pollQueries()
+	go s.fetchSettlementAfterLogin()
 	return nil
 }
 
@@ -368,6 +440,8 @@ func (s *Service) ResetPaperReplay() error {
 	}
 	s.pending = make(map[string]OrderRecord)
 	s.replayQuotes = make(map[string]replayQuote)
+	s.paperSettledDay = ""
+	s.paperSettle = paperSettleMark{}
 	if err := s.ensurePaperAccount(); err != nil {
 		return err
 	}
@@ -377,6 +451,16 @@ func (s *Service) ResetPaperReplay() error {
 	return nil
 }
 
+// PurgePaperReplay 删除回放模拟账户在账本库中的全部记录且不重建账户，用于回放会话删除时清理会话账本。
+func (s *Service) PurgePaperReplay() error {
+	if !s.replayPaper {
+		return fmt.Errorf("paper replay purge is only available for replay paper service")
+	}
+	s.paperMu.Lock()
+	defer s.paperMu.Unlock()
+	return s.store.ResetPaperAccount(s.accountID)
+}
+
 func (s *Service) Status() TradeStatus {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -404,6 +488,11 @@ func (s *Service) Order(commandID string) (OrderRecord, error) {
 	return s.store.GetOrder(commandID)
 }
 
+// OrderByRef 按本地报单引用查询本账户委托。
+func (s *Service) OrderByRef(orderRef string) (OrderRecord, error) {
+	return s.store.GetOrderByRef(s.accountID, orderRef)
+}
+
 func (s *Service) Trades(limit int) ([]TradeRecord, error) {
 	return s.store.ListTrades(s.accountID, limit)
 }
@@ -461,6 +550,14 @@ func (s *Service) RefreshPositions() ([]PositionSnapshot, error) {
 	if err != nil {
 		return nil, err
 	}
+	if report, err := s.reconcileWithBroker(items, "refresh", false); err != nil {
+		logger.Warn("trade position reconcile failed", "account_id", s.accountID, "error", err)
+	} else if s.holdPositionsForReconcile(report) {
+		s.mu.RLock()
+		held := append([]PositionSnapshot(nil), s.positions...)
+		s.mu.RUnlock()
+		return held, nil
+	}
 	if err := s.store.ReplacePositions(s.accountID, items); err != nil {
 		return nil, err
 	}
@@ -559,11 +656,28 @@ func (s *Service) RequestMetaSync() {
 	s.metaSyncMu.Unlock()
 }
 
+// SubmitOrder 下达单笔委托；auto_close 拆成多笔时返回合并记录，各笔明细见 Legs。
+// 拆单中途失败但已有子委托送出时不返回错误，而是返回状态为 partially_submitted 的合并记录，
+// 让调用方记下已在柜台的委托；需要原始错误时使用 SubmitAutoClose。
 func (s *Service) SubmitOrder(ctx context.Context, req SubmitOrderRequest) (OrderRecord, error) {
 	req, err := s.normalizeSubmitRequest(req)
 	if err != nil {
 		return OrderRecord{AccountID: s.accountID, Symbol: strings.TrimSpace(req.Symbol), ExchangeID: strings.TrimSpace(req.ExchangeID), UpdatedAt: time.Now()}, err
 	}
+	if req.OffsetFlag == OffsetAutoClose {
+		recs, err := s.SubmitAutoClose(ctx, req)
+		if len(recs) == 0 {
+			return OrderRecord{AccountID: s.accountID, Symbol: req.Symbol, ExchangeID: req.ExchangeID, UpdatedAt: time.Now()}, err
+		}
+		rec := mergeAutoCloseLegs(recs)
+		if err != nil {
+			rec.Legs = append([]OrderRecord(nil), recs...)
+			rec.OrderStatus = OrderStatusPartiallySubmitted
+			rec.StatusMsg = fmt.Sprintf("auto_close submitted %d of %d lots: %v", rec.VolumeTotalOriginal, req.Volume, err)
+			logger.Warn("auto close partially submitted", "account_id", s.accountID, "symbol", req.Symbol, "command_id", rec.CommandID, "submitted", rec.VolumeTotalOriginal, "volume", req.Volume, "error", err)
+		}
+		return rec, nil
+	}
 	status := s.Status()
 	account, _ := s.Account()
 	positions, _ := s.Positions()
@@ -628,6 +742,7 @@ func (s *Service) SubmitOrder(ctx context.Context, req SubmitOrderRequest) (Orde
 	}
 	rec.CommandID = commandID
 	rec.AccountID = s.accountID
+	rec.ClientTag = req.ClientTag
 	if err := s.store.UpsertOrder(rec); err != nil {
 		return rec, err
 	}
@@ -847,6 +962,7 @@ func (s *Service) pollQueries() {
 	go s.runAutoPosLane()
 	go s.runFeeLane()
 	go s.runMarginLane()
+	go s.runReconcileLoop()
 	statusTicker := time.NewTicker(time.Duration(s.cfg.QueryPollIntervalMS) * time.Millisecond)
 	defer statusTicker.Stop()
 	for {
@@ -873,6 +989,9 @@ func (s *Service) setStatusFromGateway() {
 	}
 	gw := s.gateway.Status()
 	s.setStatus(func(st *TradeStatus) {
+		gw.ReconcileStatus = st.ReconcileStatus
+		gw.ReconcileSeverity = st.ReconcileSeverity
+		gw.LastReconcileAt = st.LastReconcileAt
 		*st = gw
 	})
 }
@@ -966,7 +1085,7 @@ func (s *Service) normalizeCancelRequest(req CancelOrderRequest, orderRec OrderR
 }
 
 func (s *Service) broadcast(eventType string, data any) {
-	payload := EventEnvelope{Type: eventType, Data: data}
+	payload := EventEnvelope{Type: eventType, AccountID: s.accountID, Data: data}
 	s.subsMu.Lock()
 	subs := make([]chan EventEnvelope, 0, len(s.subs))
 	for ch := range s.subs {
@@ -1125,6 +1244,7 @@ func (s *Service) submitPaperOrder(commandID string, req SubmitOrderRequest) (Or
 		OrderStatus:         "queued",
 		SubmitStatus:        "accepted",
 		StatusMsg:           "paper order accepted and waiting for market",
+		ClientTag:           req.ClientTag,
 		InsertedAt:          now,
 		UpdatedAt:           now,
 	}
@@ -1160,6 +1280,7 @@ func (s *Service) submitImmediatePaperOrder(commandID string, req SubmitOrderReq
 		OrderStatus:         "all_traded",
 		SubmitStatus:        "paper_filled",
 		StatusMsg:           "paper order filled immediately",
+		ClientTag:           req.ClientTag,
 		InsertedAt:          now,
 		UpdatedAt:           now,
 	}
@@ -1201,7 +1322,7 @@ func (s *Service) applyImmediatePaperFill(tr TradeRecord) error {
 	if err != nil {
 		return err
 	}
-	commission := paperCommission(tr)
+	commission := PaperCommission(tr)
 	account.Commission += commission
 	positions = applyFilledTradeToPositions(positions, tr)
 	margin := 0.0
@@ -1272,7 +1393,13 @@ func (s *Service) cancelPaperOrder(req CancelOrderRequest, orderRec OrderRecord)
 }
 
 func (s *Service) ConsumeBusEvent(_ context.Context, ev bus.BusEvent) error {
-	if !s.replayPaper || ev.Topic != bus.TopicTick {
+	if !s.replayPaper {
+		return nil
+	}
+	if ev.Topic == bus.TopicTradingDaySettle {
+		return s.consumeTradingDaySettle(ev)
+	}
+	if ev.Topic != bus.TopicTick {
 		return nil
 	}
 	var tick quotes.TickEvent
@@ -1313,6 +1440,21 @@ func (s *Service) ConsumePaperMarketTick(tick PaperMarketTick) error {
 	})
 }
 
+// ConsumeReplayKlineBar 实现 quotes.ReplayKlineConsumer，用 K 线回放的 bar 撮合模拟挂单。
+func (s *Service) ConsumeReplayKlineBar(bar quotes.ReplayKlineBar) error {
+	return s.ConsumePaperMarketBar(PaperMarketBar{
+		Symbol:       bar.Symbol,
+		ExchangeID:   bar.Exchange,
+		Timeframe:    bar.Timeframe,
+		AdjustedTime: bar.AdjustedTime,
+		DataTime:     bar.DataTime,
+		Open:         bar.Open,
+		High:         bar.High,
+		Low:          bar.Low,
+		Close:        bar.Close,
+	})
+}
+
 func (s *Service) ConsumePaperMarketBar(bar PaperMarketBar) error {
 	if !s.paper {
 		return nil
@@ -1453,36 +1595,10 @@ func (s *Service) matchReplayBar(bar PaperMarketBar, now time.Time) error {
 	var filledOrders []OrderRecord
 	var trades []TradeRecord
 	for _, order := range orders {
-		if !strings.EqualFold(order.Symbol, bar.Symbol) {
-			continue
-		}
-		price, ok := marketableReplayBarPrice(order, bar)
+		order, tradeRec, ok := fillOrderOnBar(s.accountID, order, bar, now)
 		if !ok {
 			continue
 		}
-		order.VolumeTraded = order.VolumeTotalOriginal - order.VolumeCanceled
-		if order.VolumeTraded < 0 {
-			order.VolumeTraded = 0
-		}
-		order.OrderStatus = "all_traded"
-		order.SubmitStatus = "accepted"
-		order.StatusMsg = "paper replay order filled by kline replay"
-		order.UpdatedAt = now
-		tradeRec := TradeRecord{
-			AccountID:  s.accountID,
-			TradeID:    order.CommandID + "-fill",
-			OrderRef:   order.OrderRef,
-			OrderSysID: order.OrderSysID,
-			ExchangeID: firstNonEmpty(order.ExchangeID, bar.ExchangeID),
-			Symbol:     order.Symbol,
-			Direction:  order.Direction,
-			OffsetFlag: order.OffsetFlag,
-			Price:      price,
-			Volume:     order.VolumeTraded,
-			TradeTime:  now,
-			TradingDay: now.Format("20060102"),
-			ReceivedAt: now,
-		}
 		if err := s.store.UpsertOrder(order); err != nil {
 			return err
 		}
@@ -1506,6 +1622,41 @@ func (s *Service) matchReplayBar(bar PaperMarketBar, now time.Time) error {
 	return nil
 }
 
+// fillOrderOnBar 用一根 K 线撮合挂单，可成交时返回全部成交后的委托与成交记录。
+// replay_paper 与内存回测账本共用这一撮合口径，保证两者结果可比。
+func fillOrderOnBar(accountID string, order OrderRecord, bar PaperMarketBar, now time.Time) (OrderRecord, TradeRecord, bool) {
+	if !strings.EqualFold(order.Symbol, bar.Symbol) {
+		return order, TradeRecord{}, false
+	}
+	price, ok := marketableReplayBarPrice(order, bar)
+	if !ok {
+		return order, TradeRecord{}, false
+	}
+	order.VolumeTraded = order.VolumeTotalOriginal - order.VolumeCanceled
+	if order.VolumeTraded < 0 {
+		order.VolumeTraded = 0
+	}
+	order.OrderStatus = "all_traded"
+	order.SubmitStatus = "accepted"
+	order.StatusMsg = "paper replay order filled by kline replay"
+	order.UpdatedAt = now
+	return order, TradeRecord{
+		AccountID:  accountID,
+		TradeID:    order.CommandID + "-fill",
+		OrderRef:   order.OrderRef,
+		OrderSysID: order.OrderSysID,
+		ExchangeID: firstNonEmpty(order.ExchangeID, bar.ExchangeID),
+		Symbol:     order.Symbol,
+		Direction:  order.Direction,
+		OffsetFlag: order.OffsetFlag,
+		Price:      price,
+		Volume:     order.VolumeTraded,
+		TradeTime:  now,
+		TradingDay: now.Format("20060102"),
+		ReceivedAt: now,
+	}, true
+}
+
 func (s *Service) recalculateReplayPaperState(now time.Time) error {
 	s.paperMu.Lock()
 	defer s.paperMu.Unlock()
@@ -1569,12 +1720,23 @@ func (s *Service) recalculateReplayPaperStateLocked(now time.Time) (TradingAccou
 		account.FrozenPremium = latest.FrozenPremium
 	}
 	positions := make([]PositionSnapshot, 0)
+	tradeDay := ""
 	for _, tr := range trades {
+		// 成交跨入新交易日时，之前的持仓都已隔夜，转为昨仓。
+		if tr.TradingDay != "" && tradeDay != "" && tr.TradingDay > tradeDay {
+			positions = rollPaperPositionsToYesterday(positions)
+		}
+		if tr.TradingDay != "" {
+			tradeDay = tr.TradingDay
+		}
 		multiplier := s.contractVolumeMultiple(tr.Symbol, tr.ExchangeID)
 		positions, account.CloseProfit = applyFilledTradeToPositionsWithProfit(positions, tr, account.CloseProfit, multiplier)
-		commission := paperCommission(tr)
+		commission := PaperCommission(tr)
 		account.Commission += commission
 	}
+	if s.paperSettledDay != "" && tradeDay <= s.paperSettledDay {
+		positions = rollPaperPositionsToYesterday(positions)
+	}
 	for i := range positions {
 		avgPrice := 0.0
 		if positions[i].Position > 0 && positions[i].PositionCost > 0 {
@@ -1699,13 +1861,7 @@ func applyFilledTradeToPositionsWithProfit(items []PositionSnapshot, tr TradeRec
 			if item.Position < used {
 				used = item.Position
 			}
-			item.Position -= used
-			if item.TodayPosition >= used {
-				item.TodayPosition -= used
-			} else {
-				item.YdPosition = paperMaxInt(item.YdPosition-(used-item.TodayPosition), 0)
-				item.TodayPosition = 0
-			}
+			// 均价必须按扣减前的持仓计算，否则全部平仓时均价为 0、平仓盈亏被算成整笔成交额。
 			avgOpenCost := 0.0
 			avgPositionCost := 0.0
 			avgMargin := 0.0
@@ -1714,6 +1870,7 @@ func applyFilledTradeToPositionsWithProfit(items []PositionSnapshot, tr TradeRec
 				avgPositionCost = item.PositionCost / float64(item.Position)
 				avgMargin = item.UseMargin / float64(item.Position)
 			}
+			takeClosePosition(&item, used, tr.OffsetFlag)
 			item.OpenCost = paperMaxFloat(item.OpenCost-avgOpenCost*float64(used), 0)
 			item.PositionCost = paperMaxFloat(item.PositionCost-avgPositionCost*float64(used), 0)
 			item.UseMargin = paperMaxFloat(item.UseMargin-avgMargin*float64(used), 0)
@@ -1766,6 +1923,23 @@ func applyFilledTradeToPositionsWithProfit(items []PositionSnapshot, tr TradeRec
 	return out, closeProfit
 }
 
+// takeClosePosition 从持仓中扣减 used 手：平昨先扣昨仓，其余先扣今仓。
+func takeClosePosition(item *PositionSnapshot, used int, offsetFlag string) {
+	item.Position -= used
+	if offsetFlag == "close_yesterday" || (offsetFlag == "close" && exchangeClosesYesterdayFirst(item.Exchange)) {
+		fromYd := min(used, paperMaxInt(item.Position+used-item.TodayPosition, 0))
+		item.YdPosition = paperMaxInt(item.YdPosition-fromYd, 0)
+		item.TodayPosition = paperMaxInt(item.TodayPosition-(used-fromYd), 0)
+		return
+	}
+	if item.TodayPosition >= used {
+		item.TodayPosition -= used
+	} else {
+		item.YdPosition = paperMaxInt(item.YdPosition-(used-item.TodayPosition), 0)
+		item.TodayPosition = 0
+	}
+}
+
 func pendingOrderSlice(items map[string]OrderRecord) []OrderRecord {
 	out := make([]OrderRecord, 0, len(items))
 	for _, item := range items {
@@ -1797,13 +1971,7 @@ func applyPendingCloseReservations(items []PositionSnapshot, orders []OrderRecor
 			if out[i].Position < used {
 				used = out[i].Position
 			}
-			out[i].Position -= used
-			if out[i].TodayPosition >= used {
-				out[i].TodayPosition -= used
-			} else {
-				out[i].YdPosition = paperMaxInt(out[i].YdPosition-(used-out[i].TodayPosition), 0)
-				out[i].TodayPosition = 0
-			}
+			takeClosePosition(&out[i], used, order.OffsetFlag)
 			remaining -= used
 		}
 	}
@@ -1820,7 +1988,14 @@ func (s *Service) replayPositionProfit(pos PositionSnapshot, quote replayQuote)
 	if pos.Position <= 0 || pos.PositionCost <= 0 {
 		return 0
 	}
-	volumeMultiple := s.contractVolumeMultiple(pos.Symbol, pos.Exchange)
+	return markPositionProfit(pos, quote, s.contractVolumeMultiple(pos.Symbol, pos.Exchange))
+}
+
+// markPositionProfit 按盘口（无盘口时用最新价）计算持仓浮动盈亏。
+func markPositionProfit(pos PositionSnapshot, quote replayQuote, volumeMultiple float64) float64 {
+	if pos.Position <= 0 || pos.PositionCost <= 0 {
+		return 0
+	}
 	cost := pos.PositionCost / float64(pos.Position)
 	mark := replayMarkPrice(pos.Direction, quote, cost)
 	switch pos.Direction {
@@ -1954,7 +2129,8 @@ func (s *Service) marginVolumeMultiple(symbol string, exchangeID string) (float6
 	return 1, false
 }
 
-func paperCommission(tr TradeRecord) float64 {
+// PaperCommission 是模拟盘的手续费估算：成交金额的万分之一，回测、回放与模拟盘共用。
+func PaperCommission(tr TradeRecord) float64 {
 	return tr.Price * float64(tr.Volume) * 0.0001
 }
 
@@ -1974,6 +2150,12 @@ func (s *Service) recomputePaperAccountTotals(account *TradingAccountSnapshot) {
 		staticBalance = s.paperStaticBalance()
 		account.StaticBalance = staticBalance
 	}
+	computePaperAccountTotals(account)
+}
+
+// computePaperAccountTotals 由静态权益、盈亏、手续费和保证金推导动态权益与可用资金。
+func computePaperAccountTotals(account *TradingAccountSnapshot) {
+	staticBalance := account.StaticBalance
 	account.FrozenCash = account.FrozenMargin + account.FrozenCommission + account.FrozenPremium
 	account.Balance = staticBalance + account.Deposit - account.Withdraw + account.CloseProfit + account.PositionProfit + account.Premium - account.Commission - account.OtherFee
 	account.Available = account.Balance - account.Margin - account.FrozenMargin - account.FrozenCommission - account.FrozenPremium
@@ -1982,6 +2164,11 @@ func (s *Service) recomputePaperAccountTotals(account *TradingAccountSnapshot) {
 	}
 }
 
+// ContractVolumeMultiple 返回合约乘数，查不到时为 1；与模拟盘盈亏计算使用同一来源。
+func (s *Service) ContractVolumeMultiple(symbol string, exchangeID string) float64 {
+	return s.contractVolumeMultiple(symbol, exchangeID)
+}
+
 func (s *Service) contractVolumeMultiple(symbol string, exchangeID string) float64 {
 	if s != nil && s.rateCatalog != nil {
 		if vm, err := s.rateCatalog.instrumentVolumeMultiple(symbol, exchangeID); err == nil && vm > 0 {
@@ -425,3 +425,40 @@ func assertFloatEqual(t *testing.T, got float64, want float64) {
 		t.Fatalf("float mismatch: got %.10f want %.10f", got, want)
 	}
 }
+
+func TestApplyFilledTradeCloseProfitUsesPreCloseAverageCost(t *testing.T) {
+	t.Parallel()
+
+	// 旧实现先扣减持仓再算均价：平 2/4 手时均价变成 400/2=200，盈亏为 -1800；全部平仓时均价为 0，盈亏为 4400。
+	long := PositionSnapshot{Symbol: "rb2405", Direction: "long", Position: 4, TodayPosition: 4, OpenCost: 400, PositionCost: 400, UseMargin: 400}
+	cases := []struct {
+		name       string
+		volume     int
+		wantProfit float64
+		wantLeft   int
+		wantCost   float64
+	}{
+		{name: "partial close", volume: 2, wantProfit: 200, wantLeft: 2, wantCost: 200},
+		{name: "full close", volume: 4, wantProfit: 400, wantLeft: 0},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tr := TradeRecord{Symbol: "rb2405", Direction: "sell", OffsetFlag: "close", Price: 110, Volume: tc.volume}
+			items, profit := applyFilledTradeToPositionsWithProfit([]PositionSnapshot{long}, tr, 0, 10)
+			assertFloatEqual(t, profit, tc.wantProfit)
+			if tc.wantLeft == 0 {
+				if len(items) != 0 {
+					t.Fatalf("positions after full close = %+v, want none", items)
+				}
+				return
+			}
+			if len(items) != 1 || items[0].Position != tc.wantLeft {
+				t.Fatalf("positions = %+v, want %d left", items, tc.wantLeft)
+			}
+			assertFloatEqual(t, items[0].PositionCost, tc.wantCost)
+			assertFloatEqual(t, items[0].OpenCost, tc.wantCost)
+		})
+	}
+}
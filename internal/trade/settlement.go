@@ -0,0 +1,696 @@
+// settlement.go 负责柜台结算单的获取、解析与日终核对。
+// 实盘登录后拉取上一交易日结算单原文落库，解析资金状况、成交记录、持仓汇总和手续费，
+// 再与本地成交记录、账户快照逐项核对，并据此提供按交易日的盈亏与费用报表。
+package trade
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/logger"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+const (
+	SettlementCheckTrade   = "trade"
+	SettlementCheckAccount = "account"
+	SettlementCheckFee     = "fee"
+
+	// settlementAmountTolerance 是资金类字段比较的容差，结算单金额保留两位小数。
+	settlementAmountTolerance = 0.01
+	// settlementSnapshotCutoff 是交易日日终快照的截止时刻：日盘收盘后、夜盘开盘前，
+	// 此前最后一笔账户快照仍属于该交易日。
+	settlementSnapshotCutoff = 20*time.Hour + 30*time.Minute
+)
+
+var ErrSettlementUnavailable = errors.New("settlement statement is only available for live trading accounts")
+
+// SettlementAccountSummary 是结算单“资金状况”段的关键字段。
+type SettlementAccountSummary struct {
+	// PreBalance 是上日结存。
+	PreBalance float64 `json:"pre_balance"`
+	// DepositWithdrawal 是当日出入金净额。
+	DepositWithdrawal float64 `json:"deposit_withdrawal"`
+	// CloseProfit 是平仓盈亏。
+	CloseProfit float64 `json:"close_profit"`
+	// PositionProfit 是持仓盯市盈亏。
+	PositionProfit float64 `json:"position_profit"`
+	// Commission 是手续费合计。
+	Commission float64 `json:"commission"`
+	// Balance 是期末结存。
+	Balance float64 `json:"balance"`
+	// Equity 是客户权益。
+	Equity float64 `json:"equity"`
+	// Margin 是保证金占用。
+	Margin float64 `json:"margin"`
+	// Available 是可用资金。
+	Available float64 `json:"available"`
+}
+
+type SettlementTradeLine struct {
+	// TradeDate 是成交日期。
+	TradeDate string `json:"trade_date"`
+	// Exchange 是结算单上的交易所名称。
+	Exchange string `json:"exchange"`
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// Direction 是买卖方向，统一为 buy 或 sell。
+	Direction string `json:"direction"`
+	// OffsetFlag 是开平标志，与 TradeRecord 取值一致。
+	OffsetFlag string `json:"offset_flag"`
+	// Price 是成交价。
+	Price float64 `json:"price"`
+	// Volume 是成交手数。
+	Volume int `json:"volume"`
+	// Turnover 是成交额。
+	Turnover float64 `json:"turnover"`
+	// Commission 是该笔成交手续费。
+	Commission float64 `json:"commission"`
+	// CloseProfit 是该笔成交的平仓盈亏。
+	CloseProfit float64 `json:"close_profit"`
+	// TradeID 是成交序号，对应 CTP 成交编号。
+	TradeID string `json:"trade_id"`
+}
+
+type SettlementPositionLine struct {
+	// Symbol 是合约代码。
+	Symbol string `json:"symbol"`
+	// LongPosition 是买持仓。
+	LongPosition int `json:"long_position"`
+	// LongAvgPrice 是买均价。
+	LongAvgPrice float64 `json:"long_avg_price"`
+	// ShortPosition 是卖持仓。
+	ShortPosition int `json:"short_position"`
+	// ShortAvgPrice 是卖均价。
+	ShortAvgPrice float64 `json:"short_avg_price"`
+	// PreSettlementPrice 是昨结算价。
+	PreSettlementPrice float64 `json:"pre_settlement_price"`
+	// SettlementPrice 是今结算价。
+	SettlementPrice float64 `json:"settlement_price"`
+	// PositionProfit 是持仓盯市盈亏。
+	PositionProfit float64 `json:"position_profit"`
+	// Margin 是保证金占用。
+	Margin float64 `json:"margin"`
+}
+
+// SettlementFeeLine 是按合约汇总的成交与手续费。
+type SettlementFeeLine struct {
+	Symbol      string  `json:"symbol"`
+	Volume      int     `json:"volume"`
+	Turnover    float64 `json:"turnover"`
+	Commission  float64 `json:"commission"`
+	CloseProfit float64 `json:"close_profit"`
+}
+
+type SettlementReconcileDiff struct {
+	// Check 是差异类别：trade、account 或 fee。
+	Check string `json:"check"`
+	// Key 是差异定位键，成交为成交编号，资金为字段名。
+	Key string `json:"key"`
+	// Symbol 是相关合约，资金类差异为空。
+	Symbol string `json:"symbol,omitempty"`
+	// SettlementValue 是结算单口径数值。
+	SettlementValue float64 `json:"settlement_value"`
+	// LocalValue 是本地口径数值。
+	LocalValue float64 `json:"local_value"`
+	// Severity 是差异严重级别，沿用持仓对账的级别定义。
+	Severity string `json:"severity"`
+	// Reason 是差异说明。
+	Reason string `json:"reason"`
+}
+
+type SettlementStatement struct {
+	// AccountID 是账户标识。
+	AccountID string `json:"account_id"`
+	// TradingDay 是结算单所属交易日。
+	TradingDay string `json:"trading_day"`
+	// Content 是解码后的结算单原文，列表接口不返回。
+	Content string `json:"content,omitempty"`
+	// Summary 是资金状况。
+	Summary SettlementAccountSummary `json:"summary"`
+	// Trades 是成交记录明细。
+	Trades []SettlementTradeLine `json:"trades"`
+	// Positions 是持仓汇总。
+	Positions []SettlementPositionLine `json:"positions"`
+	// Fees 是按合约汇总的手续费。
+	Fees []SettlementFeeLine `json:"fees"`
+	// ReconcileStatus 是与本地记录核对的结果，clean 或 open。
+	ReconcileStatus string `json:"reconcile_status"`
+	// ReconcileSeverity 是核对差异的最高严重级别。
+	ReconcileSeverity string `json:"reconcile_severity"`
+	// Diffs 是核对差异明细。
+	Diffs []SettlementReconcileDiff `json:"diffs"`
+	// FetchedAt 是结算单拉取时间。
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// DailyPnL 是某交易日的盈亏与费用报表行，以结算单为准。
+type DailyPnL struct {
+	TradingDay        string              `json:"trading_day"`
+	PreBalance        float64             `json:"pre_balance"`
+	DepositWithdrawal float64             `json:"deposit_withdrawal"`
+	CloseProfit       float64             `json:"close_profit"`
+	PositionProfit    float64             `json:"position_profit"`
+	Commission        float64             `json:"commission"`
+	NetProfit         float64             `json:"net_profit"`
+	Balance           float64             `json:"balance"`
+	TradeCount        int                 `json:"trade_count"`
+	Fees              []SettlementFeeLine `json:"fees"`
+	ReconcileStatus   string              `json:"reconcile_status"`
+	ReconcileSeverity string              `json:"reconcile_severity"`
+}
+
+// settlementSummaryFieldRE 匹配资金状况里“中文 English：数值”形式的键值对，只取英文标签，避免各家柜台中文措辞差异。
+var settlementSummaryFieldRE = regexp.MustCompile(`([A-Za-z][A-Za-z .&/()'-]*?)\s*[：:]\s*(-?[\d,]+(?:\.\d+)?)`)
+
+var settlementDateRE = regexp.MustCompile(`(?:日期|Date)\s*[：:]\s*(\d{8})`)
+
+// joinSettlementChunks 按序号拼接结算单分片并从 GBK 解码。分片可能截断在多字节字符中间，必须先拼接再解码。
+func joinSettlementChunks(chunks []settlementChunk) (string, string, error) {
+	if len(chunks) == 0 {
+		return "", "", nil
+	}
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].sequenceNo < chunks[j].sequenceNo })
+	var raw bytes.Buffer
+	tradingDay := ""
+	for _, item := range chunks {
+		raw.WriteString(item.content)
+		if tradingDay == "" {
+			tradingDay = item.tradingDay
+		}
+	}
+	decoded, err := io.ReadAll(transform.NewReader(bytes.NewReader(raw.Bytes()), simplifiedchinese.GBK.NewDecoder()))
+	if err != nil {
+		return "", "", fmt.Errorf("decode settlement content failed: %w", err)
+	}
+	return string(decoded), tradingDay, nil
+}
+
+// ParseSettlementStatement 解析 CTP 标准结算单正文。缺失的段落保持为空，不视为错误；
+// 只有既没有资金状况也没有任何表格时才返回错误。
+func ParseSettlementStatement(content string) (SettlementStatement, error) {
+	var out SettlementStatement
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	summaryFound := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case out.TradingDay == "" && (strings.Contains(line, "日期") || strings.Contains(line, "Date")) && !strings.HasPrefix(line, "|"):
+			if m := settlementDateRE.FindStringSubmatch(line); m != nil {
+				out.TradingDay = m[1]
+			}
+		case strings.Contains(line, "资金状况") || strings.Contains(line, "Account Summary"):
+			i = parseSettlementSummary(lines, i+1, &out.Summary)
+			summaryFound = true
+		case strings.Contains(line, "成交记录") || strings.Contains(line, "Transaction Record"):
+			header, rows, next := collectSettlementTable(lines, i+1)
+			out.Trades = parseSettlementTrades(header, rows)
+			i = next
+		case strings.Contains(line, "持仓汇总") || (strings.Contains(line, "Positions") && !strings.Contains(line, "Detail") && !strings.Contains(line, "持仓明细") && !strings.HasPrefix(line, "|")):
+			header, rows, next := collectSettlementTable(lines, i+1)
+			out.Positions = parseSettlementPositions(header, rows)
+			i = next
+		}
+	}
+	if !summaryFound && len(out.Trades) == 0 && len(out.Positions) == 0 {
+		return out, fmt.Errorf("settlement content has no recognizable section")
+	}
+	out.Fees = summarizeSettlementFees(out.Trades)
+	return out, nil
+}
+
+func parseSettlementSummary(lines []string, start int, out *SettlementAccountSummary) int {
+	i := start
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, "|") {
+			break
+		}
+		if line == "" || strings.Trim(line, "-") == "" {
+			continue
+		}
+		matches := settlementSummaryFieldRE.FindAllStringSubmatch(line, -1)
+		if len(matches) == 0 {
+			// 下一个段落标题没有键值对，说明资金状况已经结束。
+			break
+		}
+		for _, m := range matches {
+			v := parseSettlementNumber(m[2])
+			switch strings.ToLower(strings.TrimSpace(m[1])) {
+			case "balance b/f":
+				out.PreBalance = v
+			case "deposit/withdrawal":
+				out.DepositWithdrawal = v
+			case "realized p/l":
+				out.CloseProfit = v
+			case "mtm p/l":
+				out.PositionProfit = v
+			case "commission":
+				out.Commission = v
+			case "balance c/f":
+				out.Balance = v
+			case "client equity":
+				out.Equity = v
+			case "margin occupied":
+				out.Margin = v
+			case "fund avail.", "fund available":
+				out.Available = v
+			}
+		}
+	}
+	return i - 1
+}
+
+// collectSettlementTable 读取标题之后的表格，返回按英文表头小写化后的列索引、数据行和表格结束位置。
+// 合计行（以“共”开头）和表头行不计入数据行。
+func collectSettlementTable(lines []string, start int) (map[string]int, [][]string, int) {
+	header := make(map[string]int)
+	var rows [][]string
+	seenRow := false
+	i := start
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			if seenRow {
+				break
+			}
+			continue
+		}
+		if strings.Trim(line, "-") == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "|") {
+			break
+		}
+		seenRow = true
+		cells := strings.Split(strings.Trim(line, "|"), "|")
+		for k := range cells {
+			cells[k] = strings.TrimSpace(cells[k])
+		}
+		if isSettlementHeaderRow(cells) {
+			for k, cell := range cells {
+				if key := strings.ToLower(cell); key != "" && isASCII(key) {
+					header[key] = k
+				}
+			}
+			continue
+		}
+		if len(cells) == 0 || strings.HasPrefix(cells[0], "共") {
+			continue
+		}
+		rows = append(rows, cells)
+	}
+	return header, rows, i - 1
+}
+
+func isSettlementHeaderRow(cells []string) bool {
+	for _, cell := range cells {
+		switch strings.ToLower(cell) {
+		case "instrument", "合约", "product", "品种":
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if v[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+func settlementCell(row []string, header map[string]int, key string) string {
+	idx, ok := header[key]
+	if !ok || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+func parseSettlementTrades(header map[string]int, rows [][]string) []SettlementTradeLine {
+	out := make([]SettlementTradeLine, 0, len(rows))
+	for _, row := range rows {
+		item := SettlementTradeLine{
+			TradeDate:   settlementCell(row, header, "date"),
+			Exchange:    settlementCell(row, header, "exchange"),
+			Symbol:      settlementCell(row, header, "instrument"),
+			Direction:   mapSettlementDirection(settlementCell(row, header, "b/s")),
+			OffsetFlag:  mapSettlementOffset(settlementCell(row, header, "o/c")),
+			Price:       parseSettlementNumber(settlementCell(row, header, "price")),
+			Volume:      int(parseSettlementNumber(settlementCell(row, header, "lots"))),
+			Turnover:    parseSettlementNumber(settlementCell(row, header, "turnover")),
+			Commission:  parseSettlementNumber(settlementCell(row, header, "fee")),
+			CloseProfit: parseSettlementNumber(settlementCell(row, header, "realized p/l")),
+			TradeID:     settlementCell(row, header, "trans.no."),
+		}
+		if item.Symbol == "" {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func parseSettlementPositions(header map[string]int, rows [][]string) []SettlementPositionLine {
+	out := make([]SettlementPositionLine, 0, len(rows))
+	for _, row := range rows {
+		item := SettlementPositionLine{
+			Symbol:             settlementCell(row, header, "instrument"),
+			LongPosition:       int(parseSettlementNumber(settlementCell(row, header, "long pos."))),
+			LongAvgPrice:       parseSettlementNumber(settlementCell(row, header, "avg buy price")),
+			ShortPosition:      int(parseSettlementNumber(settlementCell(row, header, "short pos."))),
+			ShortAvgPrice:      parseSettlementNumber(settlementCell(row, header, "avg sell price")),
+			PreSettlementPrice: parseSettlementNumber(settlementCell(row, header, "prev. sttl")),
+			SettlementPrice:    parseSettlementNumber(settlementCell(row, header, "sttl today")),
+			PositionProfit:     parseSettlementNumber(settlementCell(row, header, "mtm p/l")),
+			Margin:             parseSettlementNumber(settlementCell(row, header, "margin occupied")),
+		}
+		if item.Symbol == "" {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func summarizeSettlementFees(trades []SettlementTradeLine) []SettlementFeeLine {
+	bySymbol := make(map[string]*SettlementFeeLine)
+	for _, tr := range trades {
+		item := bySymbol[tr.Symbol]
+		if item == nil {
+			item = &SettlementFeeLine{Symbol: tr.Symbol}
+			bySymbol[tr.Symbol] = item
+		}
+		item.Volume += tr.Volume
+		item.Turnover += tr.Turnover
+		item.Commission += tr.Commission
+		item.CloseProfit += tr.CloseProfit
+	}
+	out := make([]SettlementFeeLine, 0, len(bySymbol))
+	for _, item := range bySymbol {
+		out = append(out, *item)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+func mapSettlementDirection(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.Contains(v, "卖") || strings.EqualFold(v, "s") || strings.EqualFold(v, "sell") {
+		return "sell"
+	}
+	return "buy"
+}
+
+func mapSettlementOffset(v string) string {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.Contains(v, "平今"):
+		return "close_today"
+	case strings.Contains(v, "平昨"):
+		return "close_yesterday"
+	case strings.Contains(v, "平"):
+		return "close"
+	default:
+		return "open"
+	}
+}
+
+func parseSettlementNumber(v string) float64 {
+	v = strings.ReplaceAll(strings.TrimSpace(v), ",", "")
+	if v == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// ReconcileSettlement 把结算单与本地成交、交易日日终账户快照逐项核对。
+// 成交按成交编号匹配，编号缺失时退化为按合约、方向、开平、价格、手数匹配；snapshot 为 nil 时跳过资金核对。
+func ReconcileSettlement(statement SettlementStatement, trades []TradeRecord, snapshot *TradingAccountSnapshot) []SettlementReconcileDiff {
+	var diffs []SettlementReconcileDiff
+	unmatched := make([]TradeRecord, 0, len(trades))
+	byID := make(map[string]int, len(trades))
+	for _, tr := range trades {
+		if id := strings.TrimSpace(tr.TradeID); id != "" {
+			byID[id] = len(unmatched)
+		}
+		unmatched = append(unmatched, tr)
+	}
+	used := make([]bool, len(unmatched))
+	for _, line := range statement.Trades {
+		idx, ok := byID[strings.TrimSpace(line.TradeID)]
+		if !ok || used[idx] {
+			idx = findSettlementTradeFallback(line, unmatched, used)
+		}
+		if idx < 0 {
+			diffs = append(diffs, SettlementReconcileDiff{
+				Check:           SettlementCheckTrade,
+				Key:             line.TradeID,
+				Symbol:          line.Symbol,
+				SettlementValue: float64(line.Volume),
+				Severity:        ReconcileSeverityCritical,
+				Reason:          "trade in settlement is missing locally",
+			})
+			continue
+		}
+		used[idx] = true
+		local := unmatched[idx]
+		if local.Volume != line.Volume || !strings.EqualFold(local.Direction, line.Direction) || math.Abs(local.Price-line.Price) > 1e-6 {
+			diffs = append(diffs, SettlementReconcileDiff{
+				Check:           SettlementCheckTrade,
+				Key:             line.TradeID,
+				Symbol:          line.Symbol,
+				SettlementValue: float64(line.Volume),
+				LocalValue:      float64(local.Volume),
+				Severity:        ReconcileSeverityCritical,
+				Reason:          fmt.Sprintf("trade mismatch: settlement %s %d@%.4f, local %s %d@%.4f", line.Direction, line.Volume, line.Price, local.Direction, local.Volume, local.Price),
+			})
+		}
+	}
+	for i, tr := range unmatched {
+		if used[i] {
+			continue
+		}
+		diffs = append(diffs, SettlementReconcileDiff{
+			Check:      SettlementCheckTrade,
+			Key:        strings.TrimSpace(tr.TradeID),
+			Symbol:     tr.Symbol,
+			LocalValue: float64(tr.Volume),
+			Severity:   ReconcileSeverityCritical,
+			Reason:     "local trade is missing in settlement",
+		})
+	}
+	var feeTotal float64
+	for _, item := range statement.Fees {
+		feeTotal += item.Commission
+	}
+	if len(statement.Trades) > 0 && math.Abs(feeTotal-statement.Summary.Commission) > settlementAmountTolerance {
+		diffs = append(diffs, SettlementReconcileDiff{
+			Check:           SettlementCheckFee,
+			Key:             "commission",
+			SettlementValue: statement.Summary.Commission,
+			LocalValue:      feeTotal,
+			Severity:        ReconcileSeverityWarning,
+			Reason:          "sum of trade fees differs from account summary commission",
+		})
+	}
+	if snapshot != nil {
+		for _, field := range []struct {
+			key        string
+			settlement float64
+			local      float64
+		}{
+			{"commission", statement.Summary.Commission, snapshot.Commission},
+			{"close_profit", statement.Summary.CloseProfit, snapshot.CloseProfit},
+			{"deposit_withdrawal", statement.Summary.DepositWithdrawal, snapshot.Deposit - snapshot.Withdraw},
+		} {
+			if math.Abs(field.settlement-field.local) <= settlementAmountTolerance {
+				continue
+			}
+			diffs = append(diffs, SettlementReconcileDiff{
+				Check:           SettlementCheckAccount,
+				Key:             field.key,
+				SettlementValue: field.settlement,
+				LocalValue:      field.local,
+				Severity:        ReconcileSeverityWarning,
+				Reason:          "account field differs from end-of-day snapshot",
+			})
+		}
+	}
+	return diffs
+}
+
+func findSettlementTradeFallback(line SettlementTradeLine, trades []TradeRecord, used []bool) int {
+	for i, tr := range trades {
+		if used[i] {
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(tr.Symbol), line.Symbol) || !strings.EqualFold(tr.Direction, line.Direction) {
+			continue
+		}
+		// 上期所/能源中心平今、平昨在本地回报和结算单上写法可能不同，只区分开仓和平仓。
+		if (tr.OffsetFlag == "open") != (line.OffsetFlag == "open") {
+			continue
+		}
+		if tr.Volume == line.Volume && math.Abs(tr.Price-line.Price) <= 1e-6 {
+			return i
+		}
+	}
+	return -1
+}
+
+func settlementReconcileSeverity(diffs []SettlementReconcileDiff) string {
+	out := ReconcileSeverityOK
+	for _, item := range diffs {
+		if item.Severity == ReconcileSeverityCritical {
+			return ReconcileSeverityCritical
+		}
+		if item.Severity == ReconcileSeverityWarning {
+			out = ReconcileSeverityWarning
+		}
+	}
+	return out
+}
+
+// DailyPnLFromSettlement 由结算单生成当日盈亏报表行。
+func DailyPnLFromSettlement(item SettlementStatement) DailyPnL {
+	return DailyPnL{
+		TradingDay:        item.TradingDay,
+		PreBalance:        item.Summary.PreBalance,
+		DepositWithdrawal: item.Summary.DepositWithdrawal,
+		CloseProfit:       item.Summary.CloseProfit,
+		PositionProfit:    item.Summary.PositionProfit,
+		Commission:        item.Summary.Commission,
+		NetProfit:         item.Summary.CloseProfit + item.Summary.PositionProfit - item.Summary.Commission,
+		Balance:           item.Summary.Balance,
+		TradeCount:        len(item.Trades),
+		Fees:              item.Fees,
+		ReconcileStatus:   item.ReconcileStatus,
+		ReconcileSeverity: item.ReconcileSeverity,
+	}
+}
+
+// FetchSettlement 从柜台拉取结算单，解析、核对后落库。tradingDay 为空时取柜台最近一个结算日。
+func (s *Service) FetchSettlement(tradingDay string) (SettlementStatement, error) {
+	if s.paper {
+		return SettlementStatement{}, ErrSettlementUnavailable
+	}
+	if s.tradeOpGateway == nil {
+		return SettlementStatement{}, ErrTradeServiceOffline
+	}
+	content, day, err := s.tradeOpGateway.QuerySettlementInfo(tradingDay)
+	s.auditQuery("settlement_info", err)
+	if err != nil {
+		return SettlementStatement{}, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return SettlementStatement{}, fmt.Errorf("settlement for trading day %q is empty", tradingDay)
+	}
+	return s.ingestSettlement(content, day)
+}
+
+// ingestSettlement 解析结算单原文，与本地记录核对后保存。
+func (s *Service) ingestSettlement(content string, tradingDay string) (SettlementStatement, error) {
+	statement, err := ParseSettlementStatement(content)
+	if err != nil {
+		return SettlementStatement{}, err
+	}
+	statement.AccountID = s.accountID
+	statement.TradingDay = firstNonEmpty(strings.TrimSpace(tradingDay), statement.TradingDay)
+	statement.Content = content
+	statement.FetchedAt = time.Now()
+	if statement.TradingDay == "" {
+		return SettlementStatement{}, fmt.Errorf("settlement trading day is unknown")
+	}
+	trades, err := s.store.ListTradesByTradingDay(s.accountID, statement.TradingDay)
+	if err != nil {
+		return SettlementStatement{}, err
+	}
+	var snapshot *TradingAccountSnapshot
+	if cutoff, perr := time.ParseInLocation("20060102", statement.TradingDay, time.Local); perr == nil {
+		item, serr := s.store.AccountSnapshotBefore(s.accountID, cutoff.Add(settlementSnapshotCutoff))
+		switch {
+		case serr == nil:
+			snapshot = &item
+		case !errors.Is(serr, sql.ErrNoRows):
+			return SettlementStatement{}, serr
+		}
+	}
+	statement.Diffs = ReconcileSettlement(statement, trades, snapshot)
+	statement.ReconcileSeverity = settlementReconcileSeverity(statement.Diffs)
+	statement.ReconcileStatus = ReconcileStatusClean
+	if len(statement.Diffs) > 0 {
+		statement.ReconcileStatus = ReconcileStatusOpen
+	}
+	if err := s.store.SaveSettlement(statement); err != nil {
+		return SettlementStatement{}, err
+	}
+	if statement.ReconcileStatus == ReconcileStatusOpen {
+		logger.Warn(
+			"trade settlement reconcile mismatch",
+			"account_id", s.accountID,
+			"trading_day", statement.TradingDay,
+			"severity", statement.ReconcileSeverity,
+			"diff_count", len(statement.Diffs),
+		)
+	}
+	s.broadcast("trade_settlement_update", DailyPnLFromSettlement(statement))
+	return statement, nil
+}
+
+func (s *Service) Settlements(limit int) ([]SettlementStatement, error) {
+	return s.store.ListSettlements(s.accountID, limit)
+}
+
+func (s *Service) Settlement(tradingDay string) (SettlementStatement, error) {
+	return s.store.GetSettlement(s.accountID, strings.TrimSpace(tradingDay))
+}
+
+// DailyPnL 返回最近 limit 个交易日的盈亏与费用报表，按交易日倒序。
+func (s *Service) DailyPnL(limit int) ([]DailyPnL, error) {
+	items, err := s.store.ListSettlements(s.accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]DailyPnL, 0, len(items))
+	for _, item := range items {
+		out = append(out, DailyPnLFromSettlement(item))
+	}
+	return out, nil
+}
+
+// fetchSettlementAfterLogin 在实盘登录后拉取最近结算单；柜台查询流控或结算未出时稍后重试，全部失败只记日志。
+func (s *Service) fetchSettlementAfterLogin() {
+	const attempts = 3
+	for i := 0; i < attempts; i++ {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(time.Duration(i*5+2) * time.Second):
+		}
+		statement, err := s.FetchSettlement("")
+		if err == nil {
+			logger.Info("trade settlement fetched", "account_id", s.accountID, "trading_day", statement.TradingDay, "reconcile_status", statement.ReconcileStatus)
+			return
+		}
+		logger.Warn("trade settlement fetch failed", "account_id", s.accountID, "attempt", i+1, "error", err)
+	}
+}
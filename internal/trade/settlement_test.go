@@ -0,0 +1,136 @@
+package trade
+
+import (
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const sampleSettlement = `                                            中国期货市场监控中心投资者查询服务系统
+                                              交易结算单(盯市)  Settlement Statement(MTM)
+客户号 Client ID：  00001       客户名称 Client Name：测试
+日期 Date：20260302
+
+                     资金状况  币种：人民币  Account Summary  Currency：CNY
+----------------------------------------------------------------------------------------------------
+上日结存 Balance b/f：                  100000.00  基础保证金 Initial Margin：                0.00
+出 入 金 Deposit/Withdrawal：               0.00  期末结存 Balance c/f：                  99586.10
+平仓盈亏 Realized P/L：                    20.00  质 押 金 Pledge Amount：                    0.00
+持仓盯市盈亏 MTM P/L：                  -400.00  客户权益 Client Equity：               99586.10
+手 续 费 Commission：                      33.90  保证金占用 Margin Occupied：            3860.00
+可用资金 Fund Avail.：                  95726.10  风 险 度 Risk Degree：                    3.88%
+
+                                              成交记录 Transaction Record
+----------------------------------------------------------------------------------------------------------------------------------------
+|成交日期| 交易所 |       品种       |      合约      |买/卖|   投/保    |  成交价  | 手数 |   成交额   |  开平  |  手续费  |  平仓盈亏  |  成交序号  |
+|  Date  |Exchange|     Product      |   Instrument   | B/S |    S/H     |   Price  | Lots |  Turnover  |  O/C   |   Fee    |Realized P/L|  Trans.No. |
+----------------------------------------------------------------------------------------------------------------------------------------
+|20260302|上期所  |螺纹钢            |     rb2405     | 买  |投机        | 3900.000 |     1|    39000.00|开      |      3.90|        0.00|       10001|
+|20260302|上期所  |白银              |     ag2406     | 卖  |投机        | 7000.000 |     2|   210000.00|平今    |     30.00|       20.00|       10002|
+----------------------------------------------------------------------------------------------------------------------------------------
+|共   2条|        |                  |                |     |            |          |     3|   249000.00|        |     33.90|       20.00|            |
+
+                                              持仓汇总 Positions
+-------------------------------------------------------------------------------------------------------------------------------
+|       品种       |      合约      |    买持     |    买均价   |     卖持     |    卖均价    |  昨结算  |  今结算  |持仓盯市盈亏|  保证金占用   |
+|      Product     |   Instrument   |  Long Pos.  |Avg Buy Price|  Short Pos.  |Avg Sell Price|Prev. Sttl|Sttl Today|  MTM P/L   |Margin Occupied|
+-------------------------------------------------------------------------------------------------------------------------------
+|螺纹钢            |     rb2405     |            1|     3900.000|             0|         0.000|     0.000|  3860.000|      -400.00|       3860.00|
+-------------------------------------------------------------------------------------------------------------------------------
+|共   1条|                |            1|             |             0|              |          |          |      -400.00|       3860.00|
+`
+
+func TestParseSettlementStatement(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseSettlementStatement(sampleSettlement)
+	if err != nil {
+		t.Fatalf("ParseSettlementStatement() error = %v", err)
+	}
+	if got.TradingDay != "20260302" {
+		t.Fatalf("TradingDay = %q, want 20260302", got.TradingDay)
+	}
+	want := SettlementAccountSummary{
+		PreBalance:     100000,
+		CloseProfit:    20,
+		PositionProfit: -400,
+		Commission:     33.9,
+		Balance:        99586.1,
+		Equity:         99586.1,
+		Margin:         3860,
+		Available:      95726.1,
+	}
+	if got.Summary != want {
+		t.Fatalf("Summary = %+v, want %+v", got.Summary, want)
+	}
+	if len(got.Trades) != 2 {
+		t.Fatalf("len(Trades) = %d, want 2: %+v", len(got.Trades), got.Trades)
+	}
+	ag := got.Trades[1]
+	if ag.Symbol != "ag2406" || ag.Direction != "sell" || ag.OffsetFlag != "close_today" || ag.Volume != 2 || ag.Commission != 30 || ag.TradeID != "10002" {
+		t.Fatalf("Trades[1] = %+v", ag)
+	}
+	if len(got.Positions) != 1 || got.Positions[0].Symbol != "rb2405" || got.Positions[0].LongPosition != 1 || got.Positions[0].SettlementPrice != 3860 {
+		t.Fatalf("Positions = %+v", got.Positions)
+	}
+	if len(got.Fees) != 2 || got.Fees[0].Symbol != "ag2406" || got.Fees[0].Commission != 30 {
+		t.Fatalf("Fees = %+v", got.Fees)
+	}
+}
+
+func TestReconcileSettlementReportsTradeAndAccountDiffs(t *testing.T) {
+	t.Parallel()
+
+	statement, err := ParseSettlementStatement(sampleSettlement)
+	if err != nil {
+		t.Fatalf("ParseSettlementStatement() error = %v", err)
+	}
+	trades := []TradeRecord{
+		{TradeID: "       10001", Symbol: "rb2405", Direction: "buy", OffsetFlag: "open", Price: 3900, Volume: 1},
+		{TradeID: "       10003", Symbol: "rb2405", Direction: "sell", OffsetFlag: "close", Price: 3910, Volume: 1},
+	}
+	snapshot := &TradingAccountSnapshot{Commission: 33.9, CloseProfit: 10}
+
+	diffs := ReconcileSettlement(statement, trades, snapshot)
+	got := make(map[string]string)
+	for _, item := range diffs {
+		got[item.Check+"|"+item.Key] = item.Severity
+	}
+	want := map[string]string{
+		SettlementCheckTrade + "|10002":          ReconcileSeverityCritical,
+		SettlementCheckTrade + "|10003":          ReconcileSeverityCritical,
+		SettlementCheckAccount + "|close_profit": ReconcileSeverityWarning,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("diffs = %+v, want keys %v", diffs, want)
+	}
+	for key, severity := range want {
+		if got[key] != severity {
+			t.Fatalf("diff %s severity = %q, want %q (all: %+v)", key, got[key], severity, diffs)
+		}
+	}
+	if sev := settlementReconcileSeverity(diffs); sev != ReconcileSeverityCritical {
+		t.Fatalf("settlementReconcileSeverity() = %q, want critical", sev)
+	}
+}
+
+func TestJoinSettlementChunksDecodesAcrossSplitCharacters(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := simplifiedchinese.GBK.NewEncoder().String("资金状况 Account Summary")
+	if err != nil {
+		t.Fatalf("encode failed: %v", err)
+	}
+	// 第一个汉字的两个字节被拆到两个分片里，并且分片乱序到达。
+	chunks := []settlementChunk{
+		{tradingDay: "20260302", sequenceNo: 2, content: encoded[1:]},
+		{tradingDay: "20260302", sequenceNo: 1, content: encoded[:1]},
+	}
+	text, day, err := joinSettlementChunks(chunks)
+	if err != nil {
+		t.Fatalf("joinSettlementChunks() error = %v", err)
+	}
+	if text != "资金状况 Account Summary" || day != "20260302" {
+		t.Fatalf("joinSettlementChunks() = (%q, %q)", text, day)
+	}
+}
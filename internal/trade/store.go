@@ -18,7 +18,26 @@ func NewStore(dsn string) (*Store, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Store{db: db}, nil
+	store := &Store{db: db}
+	if err := store.ensureTradeOrderColumns(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// ensureTradeOrderColumns 为旧库补齐 trade_orders 新增列。
+func (s *Store) ensureTradeOrderColumns() error {
+	hasClientTag, err := dbx.TableHasColumn(s.db, "trade_orders", "client_tag")
+	if err != nil {
+		return err
+	}
+	if !hasClientTag {
+		if _, err := s.db.Exec(`ALTER TABLE trade_orders ADD COLUMN client_tag VARCHAR(128) NOT NULL DEFAULT '' AFTER status_msg`); err != nil {
+			return fmt.Errorf("add trade_orders.client_tag failed: %w", err)
+		}
+	}
+	return nil
 }
 
 func (s *Store) Close() error {
@@ -81,6 +100,27 @@ LIMIT 1
 	return out, err
 }
 
+// AccountSnapshotBefore 返回 before 之前最后一笔账户快照，用于取某交易日的日终资金。
+func (s *Store) AccountSnapshotBefore(accountID string, before time.Time) (TradingAccountSnapshot, error) {
+	var out TradingAccountSnapshot
+	err := s.db.QueryRow(`
+SELECT account_id,static_balance,balance,available,margin_value,
+       frozen_margin,frozen_commission,frozen_premium,frozen_cash,
+       deposit,withdraw,premium,other_fee,
+       commission,close_profit,position_profit,updated_at
+FROM trade_account_snapshots
+WHERE account_id=? AND updated_at<=?
+ORDER BY updated_at DESC,id DESC
+LIMIT 1
+`, accountID, before).Scan(
+		&out.AccountID, &out.StaticBalance, &out.Balance, &out.Available, &out.Margin,
+		&out.FrozenMargin, &out.FrozenCommission, &out.FrozenPremium, &out.FrozenCash,
+		&out.Deposit, &out.Withdraw, &out.Premium, &out.OtherFee,
+		&out.Commission, &out.CloseProfit, &out.PositionProfit, &out.UpdatedAt,
+	)
+	return out, err
+}
+
 func (s *Store) ResetPaperAccount(accountID string) (err error) {
 	tx, err := s.db.Begin()
 	if err != nil {
@@ -99,6 +139,8 @@ func (s *Store) ResetPaperAccount(accountID string) (err error) {
 		`DELETE FROM trade_command_audits WHERE account_id=?`,
 		`DELETE FROM trade_query_audits WHERE account_id=?`,
 		`DELETE FROM trade_session_state WHERE account_id=?`,
+		`DELETE FROM trade_reconcile_reports WHERE account_id=?`,
+		`DELETE FROM trade_settlement_statements WHERE account_id=?`,
 	}
 	for _, stmt := range statements {
 		if _, err = tx.Exec(stmt, accountID); err != nil {
@@ -165,8 +207,8 @@ func (s *Store) UpsertOrder(item OrderRecord) error {
 		item.UpdatedAt = item.InsertedAt
 	}
 	_, err := s.db.Exec(`
-INSERT INTO trade_orders(command_id,account_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,inserted_at,updated_at)
-VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
+INSERT INTO trade_orders(command_id,account_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,client_tag,inserted_at,updated_at)
+VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 ON DUPLICATE KEY UPDATE
 account_id=VALUES(account_id),
 order_ref=VALUES(order_ref),
@@ -184,23 +226,34 @@ volume_canceled=VALUES(volume_canceled),
 order_status=VALUES(order_status),
 submit_status=VALUES(submit_status),
 status_msg=VALUES(status_msg),
+client_tag=IF(VALUES(client_tag)='',client_tag,VALUES(client_tag)),
 updated_at=VALUES(updated_at)
-`, item.CommandID, item.AccountID, item.OrderRef, item.FrontID, item.SessionID, item.ExchangeID, item.OrderSysID, item.Symbol, item.Direction, item.OffsetFlag, item.LimitPrice, item.VolumeTotalOriginal, item.VolumeTraded, item.VolumeCanceled, item.OrderStatus, item.SubmitStatus, item.StatusMsg, item.InsertedAt, item.UpdatedAt)
+`, item.CommandID, item.AccountID, item.OrderRef, item.FrontID, item.SessionID, item.ExchangeID, item.OrderSysID, item.Symbol, item.Direction, item.OffsetFlag, item.LimitPrice, item.VolumeTotalOriginal, item.VolumeTraded, item.VolumeCanceled, item.OrderStatus, item.SubmitStatus, item.StatusMsg, item.ClientTag, item.InsertedAt, item.UpdatedAt)
 	return err
 }
 
 func (s *Store) GetOrder(commandID string) (OrderRecord, error) {
 	var out OrderRecord
 	err := s.db.QueryRow(`
-SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,inserted_at,updated_at
+SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,client_tag,inserted_at,updated_at
 FROM trade_orders WHERE command_id=?
-`, commandID).Scan(&out.AccountID, &out.CommandID, &out.OrderRef, &out.FrontID, &out.SessionID, &out.ExchangeID, &out.OrderSysID, &out.Symbol, &out.Direction, &out.OffsetFlag, &out.LimitPrice, &out.VolumeTotalOriginal, &out.VolumeTraded, &out.VolumeCanceled, &out.OrderStatus, &out.SubmitStatus, &out.StatusMsg, &out.InsertedAt, &out.UpdatedAt)
+`, commandID).Scan(&out.AccountID, &out.CommandID, &out.OrderRef, &out.FrontID, &out.SessionID, &out.ExchangeID, &out.OrderSysID, &out.Symbol, &out.Direction, &out.OffsetFlag, &out.LimitPrice, &out.VolumeTotalOriginal, &out.VolumeTraded, &out.VolumeCanceled, &out.OrderStatus, &out.SubmitStatus, &out.StatusMsg, &out.ClientTag, &out.InsertedAt, &out.UpdatedAt)
+	return out, err
+}
+
+// GetOrderByRef 按账户和本地报单引用查询委托，用于把成交回报关联回委托。
+func (s *Store) GetOrderByRef(accountID string, orderRef string) (OrderRecord, error) {
+	var out OrderRecord
+	err := s.db.QueryRow(`
+SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,client_tag,inserted_at,updated_at
+FROM trade_orders WHERE account_id=? AND order_ref=?
+`, accountID, orderRef).Scan(&out.AccountID, &out.CommandID, &out.OrderRef, &out.FrontID, &out.SessionID, &out.ExchangeID, &out.OrderSysID, &out.Symbol, &out.Direction, &out.OffsetFlag, &out.LimitPrice, &out.VolumeTotalOriginal, &out.VolumeTraded, &out.VolumeCanceled, &out.OrderStatus, &out.SubmitStatus, &out.StatusMsg, &out.ClientTag, &out.InsertedAt, &out.UpdatedAt)
 	return out, err
 }
 
 func (s *Store) ListOrders(accountID string, limit int) ([]OrderRecord, error) {
 	rows, err := s.db.Query(`
-SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,inserted_at,updated_at
+SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,client_tag,inserted_at,updated_at
 FROM trade_orders
 WHERE account_id=?
 ORDER BY updated_at DESC
@@ -213,7 +266,7 @@ LIMIT ?
 	var out []OrderRecord
 	for rows.Next() {
 		var item OrderRecord
-		if err := rows.Scan(&item.AccountID, &item.CommandID, &item.OrderRef, &item.FrontID, &item.SessionID, &item.ExchangeID, &item.OrderSysID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.LimitPrice, &item.VolumeTotalOriginal, &item.VolumeTraded, &item.VolumeCanceled, &item.OrderStatus, &item.SubmitStatus, &item.StatusMsg, &item.InsertedAt, &item.UpdatedAt); err != nil {
+		if err := rows.Scan(&item.AccountID, &item.CommandID, &item.OrderRef, &item.FrontID, &item.SessionID, &item.ExchangeID, &item.OrderSysID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.LimitPrice, &item.VolumeTotalOriginal, &item.VolumeTraded, &item.VolumeCanceled, &item.OrderStatus, &item.SubmitStatus, &item.StatusMsg, &item.ClientTag, &item.InsertedAt, &item.UpdatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, item)
@@ -223,7 +276,7 @@ LIMIT ?
 
 func (s *Store) ListOpenOrders(accountID string) ([]OrderRecord, error) {
 	rows, err := s.db.Query(`
-SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,inserted_at,updated_at
+SELECT account_id,command_id,order_ref,front_id,session_id,exchange_id,order_sys_id,symbol,direction,offset_flag,limit_price,volume_total_original,volume_traded,volume_canceled,order_status,submit_status,status_msg,client_tag,inserted_at,updated_at
 FROM trade_orders
 WHERE account_id=?
   AND order_status NOT IN ('all_traded','canceled','rejected')
@@ -236,7 +289,7 @@ ORDER BY inserted_at ASC, command_id ASC
 	var out []OrderRecord
 	for rows.Next() {
 		var item OrderRecord
-		if err := rows.Scan(&item.AccountID, &item.CommandID, &item.OrderRef, &item.FrontID, &item.SessionID, &item.ExchangeID, &item.OrderSysID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.LimitPrice, &item.VolumeTotalOriginal, &item.VolumeTraded, &item.VolumeCanceled, &item.OrderStatus, &item.SubmitStatus, &item.StatusMsg, &item.InsertedAt, &item.UpdatedAt); err != nil {
+		if err := rows.Scan(&item.AccountID, &item.CommandID, &item.OrderRef, &item.FrontID, &item.SessionID, &item.ExchangeID, &item.OrderSysID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.LimitPrice, &item.VolumeTotalOriginal, &item.VolumeTraded, &item.VolumeCanceled, &item.OrderStatus, &item.SubmitStatus, &item.StatusMsg, &item.ClientTag, &item.InsertedAt, &item.UpdatedAt); err != nil {
 			return nil, err
 		}
 		out = append(out, item)
@@ -289,6 +342,55 @@ LIMIT ?
 	return out, rows.Err()
 }
 
+// ListJournalTrades 按成交时间顺序返回账户成交，并带出所属委托的 ClientTag；to 非零时只取 to 之前的成交。
+func (s *Store) ListJournalTrades(accountID string, to time.Time) ([]JournalTrade, error) {
+	if to.IsZero() {
+		to = time.Date(9999, 12, 31, 0, 0, 0, 0, time.Local)
+	}
+	rows, err := s.db.Query(`
+SELECT t.account_id,t.trade_id,t.order_ref,t.order_sys_id,t.exchange_id,t.symbol,t.direction,t.offset_flag,t.price,t.volume,t.trade_time,t.trading_day,t.received_at,COALESCE(o.client_tag,'')
+FROM trade_trades t
+LEFT JOIN trade_orders o ON o.account_id=t.account_id AND o.order_ref=t.order_ref
+WHERE t.account_id=? AND t.trade_time<=?
+ORDER BY t.trade_time,t.id
+`, accountID, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []JournalTrade
+	for rows.Next() {
+		var item JournalTrade
+		if err := rows.Scan(&item.AccountID, &item.TradeID, &item.OrderRef, &item.OrderSysID, &item.ExchangeID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.Price, &item.Volume, &item.TradeTime, &item.TradingDay, &item.ReceivedAt, &item.ClientTag); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) ListTradesByTradingDay(accountID string, tradingDay string) ([]TradeRecord, error) {
+	rows, err := s.db.Query(`
+SELECT account_id,trade_id,order_ref,order_sys_id,exchange_id,symbol,direction,offset_flag,price,volume,trade_time,trading_day,received_at
+FROM trade_trades
+WHERE account_id=? AND trading_day=?
+ORDER BY trade_time,id
+`, accountID, tradingDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []TradeRecord
+	for rows.Next() {
+		var item TradeRecord
+		if err := rows.Scan(&item.AccountID, &item.TradeID, &item.OrderRef, &item.OrderSysID, &item.ExchangeID, &item.Symbol, &item.Direction, &item.OffsetFlag, &item.Price, &item.Volume, &item.TradeTime, &item.TradingDay, &item.ReceivedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
 func (s *Store) AppendCommandAudit(item OrderCommandAudit) (int64, error) {
 	reqRaw, err := json.Marshal(item.Request)
 	if err != nil {
@@ -390,6 +492,210 @@ FROM trade_session_state WHERE account_id=?
 	return out, nil
 }
 
+func (s *Store) SaveReconcileReport(item PositionReconcileReport) error {
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = time.Now()
+	}
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+INSERT INTO trade_reconcile_reports(report_id,account_id,trading_day,trigger_source,status,severity,report_json,resolution,resolved_at,created_at)
+VALUES(?,?,?,?,?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+status=VALUES(status),
+severity=VALUES(severity),
+report_json=VALUES(report_json),
+resolution=VALUES(resolution),
+resolved_at=VALUES(resolved_at)
+`, item.ReportID, item.AccountID, item.TradingDay, item.Trigger, item.Status, item.Severity, string(raw), item.Resolution, item.ResolvedAt, item.CreatedAt)
+	return err
+}
+
+func (s *Store) GetReconcileReport(reportID string) (PositionReconcileReport, error) {
+	var raw string
+	var out PositionReconcileReport
+	var resolvedAt sql.NullTime
+	err := s.db.QueryRow(`
+SELECT report_json,status,resolution,resolved_at FROM trade_reconcile_reports WHERE report_id=?
+`, reportID).Scan(&raw, &out.Status, &out.Resolution, &resolvedAt)
+	if err != nil {
+		return out, err
+	}
+	status, resolution := out.Status, out.Resolution
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return out, err
+	}
+	out.Status = status
+	out.Resolution = resolution
+	if resolvedAt.Valid {
+		t := resolvedAt.Time
+		out.ResolvedAt = &t
+	}
+	return out, nil
+}
+
+func (s *Store) ListReconcileReports(accountID string, limit int) ([]PositionReconcileReport, error) {
+	rows, err := s.db.Query(`
+SELECT report_json,status,resolution,resolved_at
+FROM trade_reconcile_reports
+WHERE account_id=?
+ORDER BY created_at DESC,report_id DESC
+LIMIT ?
+`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PositionReconcileReport
+	for rows.Next() {
+		var raw string
+		var status string
+		var resolution string
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&raw, &status, &resolution, &resolvedAt); err != nil {
+			return nil, err
+		}
+		var item PositionReconcileReport
+		_ = json.Unmarshal([]byte(raw), &item)
+		item.Status = status
+		item.Resolution = resolution
+		if resolvedAt.Valid {
+			t := resolvedAt.Time
+			item.ResolvedAt = &t
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) ResolveReconcileReport(reportID string, resolution string, resolvedAt time.Time) error {
+	_, err := s.db.Exec(`
+UPDATE trade_reconcile_reports SET status=?,resolution=?,resolved_at=? WHERE report_id=?
+`, ReconcileStatusResolved, resolution, resolvedAt, reportID)
+	return err
+}
+
+// SaveSettlement 按账户和交易日覆盖保存结算单；原文单独成列，解析结果不含原文。
+func (s *Store) SaveSettlement(item SettlementStatement) error {
+	if item.FetchedAt.IsZero() {
+		item.FetchedAt = time.Now()
+	}
+	content := item.Content
+	item.Content = ""
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+INSERT INTO trade_settlement_statements(account_id,trading_day,content,statement_json,reconcile_status,fetched_at)
+VALUES(?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+content=VALUES(content),
+statement_json=VALUES(statement_json),
+reconcile_status=VALUES(reconcile_status),
+fetched_at=VALUES(fetched_at)
+`, item.AccountID, item.TradingDay, content, string(raw), item.ReconcileStatus, item.FetchedAt)
+	return err
+}
+
+func (s *Store) GetSettlement(accountID string, tradingDay string) (SettlementStatement, error) {
+	var out SettlementStatement
+	var raw string
+	var content string
+	err := s.db.QueryRow(`
+SELECT content,statement_json FROM trade_settlement_statements WHERE account_id=? AND trading_day=?
+`, accountID, tradingDay).Scan(&content, &raw)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return out, err
+	}
+	out.Content = content
+	return out, nil
+}
+
+// ListSettlements 按交易日倒序返回结算单解析结果，不含原文。
+func (s *Store) ListSettlements(accountID string, limit int) ([]SettlementStatement, error) {
+	rows, err := s.db.Query(`
+SELECT statement_json
+FROM trade_settlement_statements
+WHERE account_id=?
+ORDER BY trading_day DESC
+LIMIT ?
+`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SettlementStatement
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var item SettlementStatement
+		_ = json.Unmarshal([]byte(raw), &item)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// SaveParentOrder 保存算法母单及其子单进度。
+func (s *Store) SaveParentOrder(item ParentOrder) error {
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`
+INSERT INTO trade_parent_orders(parent_id,account_id,symbol,style,status,parent_json,created_at,updated_at)
+VALUES(?,?,?,?,?,?,?,?)
+ON DUPLICATE KEY UPDATE
+status=VALUES(status),
+parent_json=VALUES(parent_json),
+updated_at=VALUES(updated_at)
+`, item.ParentID, item.AccountID, item.Symbol, item.Execution.Style, item.Status, string(raw), item.StartedAt, item.UpdatedAt)
+	return err
+}
+
+func (s *Store) GetParentOrder(parentID string) (ParentOrder, error) {
+	var out ParentOrder
+	var raw string
+	if err := s.db.QueryRow(`SELECT parent_json FROM trade_parent_orders WHERE parent_id=?`, parentID).Scan(&raw); err != nil {
+		return out, err
+	}
+	err := json.Unmarshal([]byte(raw), &out)
+	return out, err
+}
+
+// ListParentOrders 按创建时间倒序返回母单。
+func (s *Store) ListParentOrders(accountID string, limit int) ([]ParentOrder, error) {
+	rows, err := s.db.Query(`
+SELECT parent_json
+FROM trade_parent_orders
+WHERE account_id=?
+ORDER BY created_at DESC
+LIMIT ?
+`, accountID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ParentOrder
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var item ParentOrder
+		_ = json.Unmarshal([]byte(raw), &item)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
 func boolToInt(v bool) int {
 	if v {
 		return 1
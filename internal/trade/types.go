@@ -48,6 +48,12 @@ type TradeStatus struct {
 	FeeThrottleMS int64 `json:"fee_throttle_ms,omitempty"`
 	// MarginThrottleMS 是保证金补齐 lane 当前节流毫秒。
 	MarginThrottleMS int64 `json:"margin_throttle_ms,omitempty"`
+	// ReconcileStatus 是最近一次持仓对账状态：clean、open 或 resolved。
+	ReconcileStatus string `json:"reconcile_status,omitempty"`
+	// ReconcileSeverity 是未处理对账差异的最高严重级别。
+	ReconcileSeverity string `json:"reconcile_severity,omitempty"`
+	// LastReconcileAt 是最近一次持仓对账时间。
+	LastReconcileAt time.Time `json:"last_reconcile_at,omitempty"`
 	// UpdatedAt 是状态对象最后更新时间。
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -151,10 +157,14 @@ type OrderRecord struct {
 	SubmitStatus string `json:"submit_status"`
 	// StatusMsg 是交易所或柜台返回的状态描述。
 	StatusMsg string `json:"status_msg"`
+	// ClientTag 是下单时附带的调用方标识，策略单为 strategy-auto-<instance_id>。
+	ClientTag string `json:"client_tag,omitempty"`
 	// InsertedAt 是系统首次记录该委托的时间。
 	InsertedAt time.Time `json:"inserted_at"`
 	// UpdatedAt 是最近一次状态更新时间。
 	UpdatedAt time.Time `json:"updated_at"`
+	// Legs 是 auto_close 拆单后的各笔平仓委托，仅在合并返回时出现。
+	Legs []OrderRecord `json:"legs,omitempty"`
 }
 
 type TradeRecord struct {
@@ -334,6 +344,8 @@ type SessionState struct {
 type EventEnvelope struct {
 	// Type 是广播事件类型，例如 trade_account_update。
 	Type string `json:"type"`
+	// AccountID 是产生事件的交易账户，供多账户订阅方按账户过滤。
+	AccountID string `json:"account_id"`
 	// Data 是事件负载，具体结构随 Type 变化。
 	Data any `json:"data"`
 }
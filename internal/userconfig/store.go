@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"ctp-future-kline/internal/bus"
@@ -22,6 +24,7 @@ const (
 	keyEnabled            = "enabled"
 	keyCurrentMode        = "current_mode"
 	keyReplayResumeCursor = "replay_resume_cursor"
+	keyReplayBookmarks    = "replay_bookmarks"
 	keyKlineSettings      = "settings"
 	keyCompositions       = "compositions"
 )
@@ -45,6 +48,19 @@ type StrategyComposition struct {
 	UpdatedAt              time.Time                 `json:"updated_at"`
 }
 
+// ReplayBookmark 是用户为回放保存的命名位置，跳转时按 SimTime 定位。
+type ReplayBookmark struct {
+	Name string `json:"name"`
+	// SimTime 是书签对应的模拟时间。
+	SimTime time.Time `json:"sim_time"`
+	// Cursor 是保存书签时 bus 回放的游标，仅作参考。
+	Cursor *bus.FileCursor `json:"cursor,omitempty"`
+	// Mode 是保存书签时的回放模式。
+	Mode      string    `json:"mode,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // TradeOverrides 描述当前支持的实盘交易覆盖项。
 type TradeOverrides struct {
 	Enabled *bool
@@ -165,6 +181,46 @@ func (s *Store) LoadReplayResumeCursor(owner string) (*bus.FileCursor, bool, err
 	return &cursor, true, nil
 }
 
+// SaveReplayBookmarks 覆盖保存用户的全部回放书签。
+func (s *Store) SaveReplayBookmarks(owner string, items []ReplayBookmark) error {
+	return s.saveValue(owner, scopeAppMode, keyReplayBookmarks, NormalizeReplayBookmarks(items))
+}
+
+func (s *Store) LoadReplayBookmarks(owner string) ([]ReplayBookmark, bool, error) {
+	raw, ok, err := s.LoadRawValue(owner, scopeAppMode, keyReplayBookmarks)
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	var items []ReplayBookmark
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, false, err
+	}
+	return NormalizeReplayBookmarks(items), true, nil
+}
+
+// NormalizeReplayBookmarks 去掉无名或无时间的书签，同名书签保留最后一个，结果按模拟时间排序。
+func NormalizeReplayBookmarks(items []ReplayBookmark) []ReplayBookmark {
+	byName := make(map[string]int, len(items))
+	out := make([]ReplayBookmark, 0, len(items))
+	for _, item := range items {
+		item.Name = strings.TrimSpace(item.Name)
+		if item.Name == "" || item.SimTime.IsZero() {
+			continue
+		}
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = time.Now()
+		}
+		if idx, ok := byName[item.Name]; ok {
+			out[idx] = item
+			continue
+		}
+		byName[item.Name] = len(out)
+		out = append(out, item)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].SimTime.Before(out[j].SimTime) })
+	return out
+}
+
 func (s *Store) SaveKlineGenerationSettings(owner string, settings klinesettings.Settings) error {
 	return s.saveValue(owner, scopeKlineGeneration, keyKlineSettings, klinesettings.Normalize(settings))
 }
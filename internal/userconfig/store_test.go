@@ -2,6 +2,7 @@ package userconfig
 
 import (
 	"testing"
+	"time"
 
 	"ctp-future-kline/internal/config"
 )
@@ -18,3 +19,20 @@ func TestApplyAppOverridesTradeEnabled(t *testing.T) {
 		t.Fatalf("trade enabled override not applied: %#v", out.Trade.Enabled)
 	}
 }
+
+func TestNormalizeReplayBookmarks(t *testing.T) {
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	out := NormalizeReplayBookmarks([]ReplayBookmark{
+		{Name: " breakout ", SimTime: base.Add(time.Hour)},
+		{Name: "open", SimTime: base},
+		{Name: "", SimTime: base},
+		{Name: "no-time"},
+		{Name: "breakout", SimTime: base.Add(2 * time.Hour), Note: "retest"},
+	})
+	if len(out) != 2 || out[0].Name != "open" || out[1].Name != "breakout" || out[1].Note != "retest" {
+		t.Fatalf("bookmarks = %+v", out)
+	}
+	if out[0].CreatedAt.IsZero() {
+		t.Fatal("created_at should be filled")
+	}
+}
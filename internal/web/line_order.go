@@ -228,6 +228,9 @@ func (e *lineOrderEngine) evaluate(update quotes.ChartQuoteUpdate, mode string,
 				current.Status = lineOrderStatusTriggered
 				current.LastCommandID = rec.CommandID
 				current.LastError = ""
+				if rec.OrderStatus == trade.OrderStatusPartiallySubmitted {
+					current.LastError = rec.StatusMsg
+				}
 			}
 			current.UpdatedAt = time.Now()
 			fired = append(fired, *current)
@@ -0,0 +1,158 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/userconfig"
+)
+
+// handleReplayStep 在暂停状态下放行 count 条事件（tick 或 bar）。
+func (s *Server) handleReplayStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
+		return
+	}
+	var req replay.StepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	task, err := sess.Service.Step(req.Count)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, replay.ActionResponse{OK: true, Task: task})
+}
+
+// handleReplaySeek 把当前回放任务跳转到指定时间或书签位置。
+func (s *Server) handleReplaySeek(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
+		return
+	}
+	var req replay.SeekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	target, err := s.replaySeekTarget(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task, err := sess.Service.Seek(target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, replay.ActionResponse{OK: true, Task: task})
+}
+
+func (s *Server) replaySeekTarget(req replay.SeekRequest) (time.Time, error) {
+	if req.Time != nil && !req.Time.IsZero() {
+		return *req.Time, nil
+	}
+	name := strings.TrimSpace(req.Bookmark)
+	if name == "" {
+		return time.Time{}, fmt.Errorf("time or bookmark is required")
+	}
+	if s.userConfig == nil {
+		return time.Time{}, fmt.Errorf("user config store unavailable")
+	}
+	items, _, err := s.userConfig.LoadReplayBookmarks(s.currentOwner())
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, item := range items {
+		if item.Name == name {
+			return item.SimTime, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("replay bookmark %q not found", name)
+}
+
+// handleReplayBookmarks 管理当前用户的回放书签：GET 列出，POST 新增或覆盖同名书签，DELETE ?name= 删除。
+// POST 未给出 sim_time 时取 session_id 指定会话当前回放任务的模拟时间和游标。
+func (s *Server) handleReplayBookmarks(w http.ResponseWriter, r *http.Request) {
+	if s.userConfig == nil {
+		http.Error(w, "user config store unavailable", http.StatusInternalServerError)
+		return
+	}
+	owner := s.currentOwner()
+	items, _, err := s.userConfig.LoadReplayBookmarks(owner)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+		return
+	case http.MethodPost:
+		var req userconfig.ReplayBookmark
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.Name) == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if req.SimTime.IsZero() {
+			sess := s.requireReplaySession(w, r)
+			if sess == nil {
+				return
+			}
+			task := sess.Service.Status()
+			if task.CurrentSimTime != nil {
+				req.SimTime = *task.CurrentSimTime
+				req.Cursor = task.LastCursor
+				req.Mode = task.Mode
+			}
+		}
+		if req.SimTime.IsZero() {
+			http.Error(w, "sim_time is required when no replay position is available", http.StatusBadRequest)
+			return
+		}
+		req.CreatedAt = time.Now()
+		items = append(items, req)
+	case http.MethodDelete:
+		name := strings.TrimSpace(r.URL.Query().Get("name"))
+		kept := items[:0]
+		for _, item := range items {
+			if item.Name != name {
+				kept = append(kept, item)
+			}
+		}
+		if len(kept) == len(items) {
+			http.Error(w, "bookmark not found", http.StatusNotFound)
+			return
+		}
+		items = kept
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.userConfig.SaveReplayBookmarks(owner, items); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	items = userconfig.NormalizeReplayBookmarks(items)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "items": items})
+}
@@ -2,27 +2,16 @@ package web
 
 import (
 	"context"
-	"database/sql"
-	"errors"
-	"strings"
 	"time"
 
 	"ctp-future-kline/internal/appmode"
 	"ctp-future-kline/internal/logger"
 	"ctp-future-kline/internal/quotes"
 	"ctp-future-kline/internal/replay"
-	"ctp-future-kline/internal/strategy"
-	"ctp-future-kline/internal/trade"
 )
 
 func (s *Server) LoadKlineChunk(ctx context.Context, req replay.KlineStartRequest, afterAdjusted time.Time, limit int) (replay.KlineChunk, error) {
 	_ = ctx
-	if limit <= 0 {
-		limit = 300
-	}
-	if limit > 300 {
-		limit = 300
-	}
 	logger.Info("kline replay load chunk from realtime database",
 		"symbol", req.Symbol,
 		"type", req.Type,
@@ -33,107 +22,40 @@ func (s *Server) LoadKlineChunk(ctx context.Context, req replay.KlineStartReques
 		"source_data_mode", "realtime",
 		"market_database", s.marketDatabaseForMode(appmode.LiveReal),
 	)
-	resp, err := s.queryRealtime.BarsFrom(req.Symbol, req.Type, req.Variety, req.Timeframe, afterAdjusted, limit)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return replay.KlineChunk{Exhausted: true}, nil
-		}
-		return replay.KlineChunk{}, err
-	}
-	out := make([]replay.KlineBar, 0, len(resp.Bars))
-	for _, bar := range resp.Bars {
-		adjusted := time.Unix(bar.AdjustedTime, 0)
-		dataTime := time.Unix(bar.DataTime, 0)
-		out = append(out, replay.KlineBar{
-			Symbol:       strings.ToLower(strings.TrimSpace(resp.Meta.Symbol)),
-			Type:         strings.ToLower(strings.TrimSpace(resp.Meta.Type)),
-			Variety:      strings.ToLower(strings.TrimSpace(resp.Meta.Variety)),
-			Timeframe:    strings.ToLower(strings.TrimSpace(req.Timeframe)),
-			AdjustedTime: adjusted,
-			DataTime:     dataTime,
-			Open:         bar.Open,
-			High:         bar.High,
-			Low:          bar.Low,
-			Close:        bar.Close,
-			Volume:       bar.Volume,
-			OpenInterest: bar.OpenInterest,
-		})
+	chunk, err := quotes.LoadKlineReplayChunk(s.queryRealtime, req, afterAdjusted, limit)
+	if err == nil {
+		logger.Info("kline replay chunk loaded", "symbol", req.Symbol, "timeframe", req.Timeframe, "after_adjusted", afterAdjusted, "rows", len(chunk.Bars), "limit", limit)
 	}
-	logger.Info("kline replay chunk loaded", "symbol", req.Symbol, "timeframe", req.Timeframe, "after_adjusted", afterAdjusted, "rows", len(out), "limit", limit)
-	return replay.KlineChunk{Bars: out, Exhausted: len(out) < limit}, nil
+	return chunk, err
 }
 
 func (s *Server) ConsumeKlineBar(ctx context.Context, taskID string, req replay.KlineStartRequest, bar replay.KlineBar) error {
 	_ = ctx
-	sub := quotes.ChartSubscription{
-		Symbol:    firstNonEmpty(strings.TrimSpace(bar.Symbol), req.Symbol),
-		Type:      firstNonEmpty(strings.TrimSpace(bar.Type), req.Type),
-		Variety:   firstNonEmpty(strings.TrimSpace(bar.Variety), req.Variety),
-		Timeframe: firstNonEmpty(strings.TrimSpace(bar.Timeframe), req.Timeframe),
-		DataMode:  "realtime",
-	}
-	if s.replaySink != nil {
-		if err := s.replaySink.PublishKlineReplayBar(sub, quotes.ReplayKlineBar{
-			ReplayTaskID: taskID,
-			Symbol:       sub.Symbol,
-			Type:         sub.Type,
-			Variety:      sub.Variety,
-			Exchange:     bar.Exchange,
-			Timeframe:    sub.Timeframe,
-			AdjustedTime: bar.AdjustedTime,
-			DataTime:     bar.DataTime,
-			Open:         bar.Open,
-			High:         bar.High,
-			Low:          bar.Low,
-			Close:        bar.Close,
-			Volume:       bar.Volume,
-			OpenInterest: bar.OpenInterest,
-		}); err != nil {
-			return err
-		}
-	} else {
-		strategy.PublishReplayBar(strategy.BarEvent{
-			ReplayTaskID: taskID,
-			Variety:      sub.Variety,
-			InstrumentID: sub.Symbol,
-			Exchange:     bar.Exchange,
-			DataTime:     bar.DataTime,
-			AdjustedTime: bar.AdjustedTime,
-			Period:       sub.Timeframe,
-			Open:         bar.Open,
-			High:         bar.High,
-			Low:          bar.Low,
-			Close:        bar.Close,
-			Volume:       bar.Volume,
-			OpenInterest: bar.OpenInterest,
-		})
-	}
-	if s.tradePaperReplay != nil {
-		if err := s.tradePaperReplay.ConsumePaperMarketBar(trade.PaperMarketBar{
-			Symbol:       sub.Symbol,
-			ExchangeID:   bar.Exchange,
-			Timeframe:    sub.Timeframe,
-			AdjustedTime: bar.AdjustedTime,
-			DataTime:     bar.DataTime,
-			Open:         bar.Open,
-			High:         bar.High,
-			Low:          bar.Low,
-			Close:        bar.Close,
-		}); err != nil {
-			return err
-		}
+	return s.consumeKlineBar(s.defaultReplayEnv(), taskID, req, bar)
+}
+
+// consumeKlineBar 把一根回放 K 线发布到会话的图表、策略和回放模拟账户。
+func (s *Server) consumeKlineBar(env *replaySessionEnv, taskID string, req replay.KlineStartRequest, bar replay.KlineBar) error {
+	papers := s.replayPaperServices(env)
+	ledgers := make([]quotes.ReplayKlineConsumer, 0, len(papers))
+	for _, svc := range papers {
+		ledgers = append(ledgers, svc)
 	}
-	// logger.Debug("kline replay bar consumed", "task_id", taskID, "symbol", sub.Symbol, "timeframe", sub.Timeframe, "adjusted_time", bar.AdjustedTime, "close", bar.Close)
-	return nil
+	return quotes.ConsumeKlineReplayBar(env.sink, env.session, taskID, req, bar, ledgers)
 }
 
 func (s *Server) OnTaskFinished(_ context.Context, snap replay.TaskSnapshot) error {
 	if s == nil {
 		return nil
 	}
+	return s.finishReplayTask(s.defaultReplayEnv(), snap)
+}
+
+// finishReplayTask 在会话任务结束时补落最后一根 bar，并结算该任务的策略回放报告。
+func (s *Server) finishReplayTask(env *replaySessionEnv, snap replay.TaskSnapshot) error {
 	var firstErr error
-	if s.replaySink != nil {
-		firstErr = s.replaySink.OnTaskFinished(context.Background(), snap)
+	if env.sink != nil {
+		firstErr = env.sink.OnTaskFinished(context.Background(), snap)
 	}
 	if s.strategy != nil {
 		s.strategy.FinalizeReplayReports(snap.TaskID, snap.Status)
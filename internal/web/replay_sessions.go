@@ -0,0 +1,329 @@
+// replay_sessions.go 负责回放会话的资源编排、路由和 HTTP 接口。
+// default 会话沿用启动时创建的全局回放服务、回放行情库、图表流和回放模拟账户，旧接口不带 session_id 时都落到它上面。
+// 新建会话各自持有独立的 replay.Service、<market_replay>_s_<id> 回放行情库（含去重记录）、
+// 挂在全局图表流之下的图表命名空间（订阅带 replay_session）以及 paper_replay:session_<id> 模拟账户；
+// 删除会话时会话回放行情库整库删除，模拟账户的账本记录也一并清理。
+// 策略实例通过 replay_session 绑定会话，只接收本会话的回放行情。
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/appmode"
+	"ctp-future-kline/internal/bus"
+	dbx "ctp-future-kline/internal/db"
+	"ctp-future-kline/internal/klinequery"
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/quotes"
+	"ctp-future-kline/internal/replay"
+	"ctp-future-kline/internal/searchindex"
+	"ctp-future-kline/internal/trade"
+)
+
+// replaySessionAccountPrefix 是会话模拟账户在 paper_replay: 之后的前缀。
+const replaySessionAccountPrefix = "session_"
+
+// replaySessionEnv 是一个回放会话挂载的下游资源；default 会话的 session 为空、paper 为空（使用主账户和子账户的回放账本）。
+type replaySessionEnv struct {
+	session string
+	sink    *quotes.ReplaySink
+	chart   *quotes.ChartStream
+	paper   *trade.Service
+	query   *klinequery.Service
+	db      *sql.DB
+	// stops 停止图表事件转发。
+	stops []func()
+	// dropDB 删除会话回放行情库，只有新建会话设置。
+	dropDB func() error
+}
+
+func (e *replaySessionEnv) close() error {
+	for _, stop := range e.stops {
+		stop()
+	}
+	var firstErr error
+	if e.paper != nil {
+		// 会话账本只属于本会话，删除会话时一并清掉 paper_replay:session_<id> 的账本记录。
+		if e.session != "" {
+			if err := e.paper.PurgePaperReplay(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if err := e.paper.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if e.sink != nil {
+		if err := e.sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if e.db != nil {
+		if err := e.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if e.dropDB != nil {
+		if err := e.dropDB(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// replaySessionHooks 让会话自有的回放服务把 K 线回放和任务结束回调落到本会话的资源上。
+type replaySessionHooks struct {
+	server *Server
+	env    *replaySessionEnv
+}
+
+func (h *replaySessionHooks) LoadKlineChunk(ctx context.Context, req replay.KlineStartRequest, afterAdjusted time.Time, limit int) (replay.KlineChunk, error) {
+	return h.server.LoadKlineChunk(ctx, req, afterAdjusted, limit)
+}
+
+func (h *replaySessionHooks) ConsumeKlineBar(ctx context.Context, taskID string, req replay.KlineStartRequest, bar replay.KlineBar) error {
+	return h.server.consumeKlineBar(h.env, taskID, req, bar)
+}
+
+func (h *replaySessionHooks) OnTaskFinished(_ context.Context, snap replay.TaskSnapshot) error {
+	return h.server.finishReplayTask(h.env, snap)
+}
+
+// ReplaySessionPaperAccountID 返回会话模拟账户标识，例如 paper_replay:session_a1。
+func ReplaySessionPaperAccountID(sessionID string) string {
+	return trade.PaperAccountID(trade.PaperReplayAccountID, replaySessionAccountPrefix+sessionID)
+}
+
+// initReplaySessions 在全局回放服务就绪后登记 default 会话。
+func (s *Server) initReplaySessions() {
+	if s.replay == nil {
+		return
+	}
+	s.replaySessions = replay.NewSessionManager(s.replay, s.defaultReplayEnv(), s.newReplaySession, replay.DefaultMaxSessions)
+}
+
+func (s *Server) defaultReplayEnv() *replaySessionEnv {
+	return &replaySessionEnv{sink: s.replaySink, chart: s.chartStream, query: s.queryReplay}
+}
+
+// newReplaySession 是 replay.SessionFactory：建会话回放行情库、去重记录、sink、图表命名空间和模拟账户。
+func (s *Server) newReplaySession(id string) (*replay.Service, any, func() error, error) {
+	if s.replayBusLog == nil {
+		return nil, nil, nil, fmt.Errorf("replay bus log is not enabled")
+	}
+	marketDSN, err := dbx.EnsureReplaySessionDatabase(s.cfg.DB, dbx.RoleMarketReplay, id)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("prepare replay session market db failed: %w", err)
+	}
+	env := &replaySessionEnv{
+		session: id,
+		chart:   quotes.NewReplaySessionChartStream(s.chartStream),
+		dropDB: func() error {
+			return dbx.DropReplaySessionDatabase(s.cfg.DB, dbx.RoleMarketReplay, id)
+		},
+	}
+	fail := func(err error) (*replay.Service, any, func() error, error) {
+		_ = env.close()
+		return nil, nil, nil, err
+	}
+	if env.db, err = dbx.Open(marketDSN); err != nil {
+		return fail(err)
+	}
+	dedup, err := bus.NewConsumerStore(env.db)
+	if err != nil {
+		return fail(fmt.Errorf("init replay session dedup store failed: %w", err))
+	}
+	svc := replay.NewService(s.replayBusLog, dedup, s.cfg.CTP.IsReplayAllowOrderCommandDispatch())
+	replayCfg := s.cfg.CTP
+	replayCfg.DBDSN = marketDSN
+	if env.sink, err = quotes.NewReplaySessionSink(replayCfg, s.status, id, env.chart); err != nil {
+		return fail(fmt.Errorf("init replay session quotes sink failed: %w", err))
+	}
+	svc.RegisterConsumer("quotes.replay_sink", env.sink.ConsumeBusEvent)
+	if env.paper, err = trade.NewPaperService(s.cfg.Trade, ReplaySessionPaperAccountID(id), s.tradePaperReplayDSN, s.status.QueueRegistry()); err != nil {
+		return fail(fmt.Errorf("init replay session paper account failed: %w", err))
+	}
+	if err := env.paper.Start(); err != nil {
+		return fail(err)
+	}
+	go s.forwardTradeEventsAs(env.paper, replaySessionAccountPrefix+id)
+	svc.RegisterConsumer("trade.paper_replay", env.paper.ConsumeBusEvent)
+	env.query = klinequery.NewServiceWithSessionDB(marketDSN, s.sharedDSN, searchindex.NewManager(marketDSN, 30*time.Second))
+	hooks := &replaySessionHooks{server: s, env: env}
+	svc.RegisterKlineReplayHandler(hooks)
+	svc.RegisterTaskLifecycle("web.replay_lifecycle", hooks)
+	s.forwardReplaySessionCharts(env)
+	return svc, env, env.close, nil
+}
+
+// forwardReplaySessionCharts 把会话图表命名空间的更新带上 replay_session 后推给 websocket 客户端。
+func (s *Server) forwardReplaySessionCharts(env *replaySessionEnv) {
+	if env.chart == nil {
+		return
+	}
+	bars, stopBars := env.chart.Subscribe()
+	quotesCh, stopQuotes := env.chart.SubscribeQuotes()
+	env.stops = append(env.stops, stopBars, stopQuotes)
+	go func() {
+		for update := range bars {
+			update.Subscription.ReplaySession = env.session
+			s.broadcastChartUpdate(update)
+		}
+	}()
+	go func() {
+		for update := range quotesCh {
+			update.Subscription.ReplaySession = env.session
+			s.broadcastQuoteUpdate(update)
+		}
+	}()
+}
+
+func replayEnvOf(sess *replay.Session) *replaySessionEnv {
+	if env, ok := sess.Env.(*replaySessionEnv); ok && env != nil {
+		return env
+	}
+	return &replaySessionEnv{}
+}
+
+// replaySession 按 session_id 取会话，找不到返回 nil。
+func (s *Server) replaySession(sessionID string) *replay.Session {
+	if s.replaySessions == nil {
+		return nil
+	}
+	sess, err := s.replaySessions.Get(sessionID)
+	if err != nil {
+		return nil
+	}
+	return sess
+}
+
+// requireReplaySession 按查询参数 session_id 取会话，缺省为 default 会话。
+func (s *Server) requireReplaySession(w http.ResponseWriter, r *http.Request) *replay.Session {
+	if s.replaySessions == nil {
+		http.Error(w, "replay is disabled", http.StatusBadRequest)
+		return nil
+	}
+	sess, err := s.replaySessions.Get(r.URL.Query().Get("session_id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	return sess
+}
+
+// replayPaperServices 返回会话的回放模拟账户：default 会话为主账户和各子账户的回放账本。
+func (s *Server) replayPaperServices(env *replaySessionEnv) []*trade.Service {
+	if env.session == "" {
+		return s.tradeServicesForMode(appmode.ReplayPaper)
+	}
+	if env.paper == nil {
+		return nil
+	}
+	return []*trade.Service{env.paper}
+}
+
+// replaySessionPaper 把 resolveTradeAccountID 归一后的 session_<id> 还原成会话模拟账户。
+func (s *Server) replaySessionPaper(subID string) (*trade.Service, bool) {
+	if !strings.HasPrefix(subID, replaySessionAccountPrefix) {
+		return nil, false
+	}
+	sess := s.replaySession(strings.TrimPrefix(subID, replaySessionAccountPrefix))
+	if sess == nil || sess.ID == replay.DefaultSessionID {
+		return nil, false
+	}
+	env := replayEnvOf(sess)
+	return env.paper, env.paper != nil
+}
+
+// chartStreamFor 按订阅的 replay_session 返回对应的图表流和去掉会话段的订阅；会话不存在时返回 nil。
+func (s *Server) chartStreamFor(sub quotes.ChartSubscription) (*quotes.ChartStream, quotes.ChartSubscription) {
+	session := strings.ToLower(strings.TrimSpace(sub.ReplaySession))
+	sub.ReplaySession = ""
+	if session == "" || session == replay.DefaultSessionID {
+		return s.chartStream, sub
+	}
+	sess := s.replaySession(session)
+	if sess == nil {
+		return nil, sub
+	}
+	return replayEnvOf(sess).chart, sub
+}
+
+// klineQueryForRequest 在回放数据域下按查询参数 replay_session 选择会话回放行情库。
+func (s *Server) klineQueryForRequest(r *http.Request, mode string) (*klinequery.Service, error) {
+	query := s.queryForMode(mode)
+	session := strings.TrimSpace(r.URL.Query().Get("replay_session"))
+	if session == "" || query != s.queryReplay {
+		return query, nil
+	}
+	return s.replayQueryForSession(session)
+}
+
+func (s *Server) replayQueryForSession(session string) (*klinequery.Service, error) {
+	session = strings.ToLower(strings.TrimSpace(session))
+	if session == "" || session == replay.DefaultSessionID {
+		return s.queryReplay, nil
+	}
+	sess := s.replaySession(session)
+	if sess == nil {
+		return nil, fmt.Errorf("replay session %q not found", session)
+	}
+	return replayEnvOf(sess).query, nil
+}
+
+// handleReplaySessions 管理回放会话：GET 列出，POST 创建，DELETE ?session_id= 停止任务并删除会话。
+func (s *Server) handleReplaySessions(w http.ResponseWriter, r *http.Request) {
+	if s.replaySessions == nil {
+		http.Error(w, "replay is disabled", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, replay.SessionListResponse{Items: s.replaySessions.List()})
+	case http.MethodPost:
+		var req replay.SessionCreateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		snap, err := s.replaySessions.Create(req.SessionID, req.Label, s.currentOwner())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, replay.SessionResponse{OK: true, Session: snap})
+	case http.MethodDelete:
+		snap, err := s.replaySessions.Remove(r.URL.Query().Get("session_id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Info("replay session deleted", "session_id", snap.SessionID, "paper_account_id", ReplaySessionPaperAccountID(snap.SessionID))
+		writeJSON(w, http.StatusOK, replay.SessionResponse{OK: true, Session: snap})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// stopAllReplaySessions 在离开 replay_paper 模式时停止所有非默认会话的任务；default 会话由调用方单独处理以保存续播游标。
+func (s *Server) stopAllReplaySessions() {
+	if s.replaySessions == nil {
+		return
+	}
+	for _, item := range s.replaySessions.List() {
+		if item.Default {
+			continue
+		}
+		if sess := s.replaySession(item.SessionID); sess != nil {
+			_, _ = sess.Service.Stop()
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"testing"
+
+	"ctp-future-kline/internal/quotes"
+	"ctp-future-kline/internal/replay"
+)
+
+func TestChartStreamForRoutesReplaySessions(t *testing.T) {
+	s := newMultiAccountTestServer()
+	s.chartStream = &quotes.ChartStream{}
+	s.replay = replay.NewService(nil, nil, false)
+	aliceChart := &quotes.ChartStream{}
+	s.replaySessions = replay.NewSessionManager(s.replay, s.defaultReplayEnv(), func(id string) (*replay.Service, any, func() error, error) {
+		return replay.NewService(nil, nil, false), &replaySessionEnv{session: id, chart: aliceChart}, nil, nil
+	}, 0)
+	if _, err := s.replaySessions.Create("alice", "", ""); err != nil {
+		t.Fatalf("Create(alice) error: %v", err)
+	}
+
+	sub := quotes.ChartSubscription{Symbol: "ag2606", DataMode: "replay"}
+	if stream, inner := s.chartStreamFor(sub); stream != s.chartStream || inner.ReplaySession != "" {
+		t.Fatalf("default subscription routed to %p (%+v), want global stream", stream, inner)
+	}
+	sub.ReplaySession = "Alice"
+	stream, inner := s.chartStreamFor(sub)
+	if stream != aliceChart || inner.ReplaySession != "" {
+		t.Fatalf("alice subscription routed to %p (%+v), want session stream", stream, inner)
+	}
+	if got := withReplaySession(inner, sub.ReplaySession); got.ReplaySession != "alice" {
+		t.Fatalf("withReplaySession = %q, want alice", got.ReplaySession)
+	}
+	sub.ReplaySession = "bob"
+	if stream, _ := s.chartStreamFor(sub); stream != nil {
+		t.Fatal("unknown session should not resolve a chart stream")
+	}
+
+	// 会话没有挂载模拟账户时，账户标识不可解析。
+	if _, ok := s.resolveTradeAccountID(ReplaySessionPaperAccountID("alice")); ok {
+		t.Fatal("session without paper account should not resolve")
+	}
+}
+
+func TestRemoveReplaySessionDropsSessionDatabase(t *testing.T) {
+	s := newMultiAccountTestServer()
+	s.chartStream = &quotes.ChartStream{}
+	s.replay = replay.NewService(nil, nil, false)
+	dropped := map[string]int{}
+	s.replaySessions = replay.NewSessionManager(s.replay, s.defaultReplayEnv(), func(id string) (*replay.Service, any, func() error, error) {
+		env := &replaySessionEnv{session: id, chart: &quotes.ChartStream{}, dropDB: func() error {
+			dropped[id]++
+			return nil
+		}}
+		return replay.NewService(nil, nil, false), env, env.close, nil
+	}, 0)
+	if _, err := s.replaySessions.Create("alice", "", ""); err != nil {
+		t.Fatalf("Create(alice) error: %v", err)
+	}
+	if _, err := s.replaySessions.Remove("alice"); err != nil {
+		t.Fatalf("Remove(alice) error: %v", err)
+	}
+	if dropped["alice"] != 1 {
+		t.Fatalf("session database drops = %v, want alice dropped once", dropped)
+	}
+}
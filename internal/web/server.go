@@ -23,15 +23,18 @@ import (
 	"time"
 
 	"ctp-future-kline/internal/appmode"
+	"ctp-future-kline/internal/backtest"
 	"ctp-future-kline/internal/bus"
 	"ctp-future-kline/internal/calendar"
 	"ctp-future-kline/internal/chartlayout"
 	"ctp-future-kline/internal/config"
 	dbx "ctp-future-kline/internal/db"
 	"ctp-future-kline/internal/importer"
+	"ctp-future-kline/internal/indicators"
 	"ctp-future-kline/internal/klinequery"
 	"ctp-future-kline/internal/klinesettings"
 	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/perf"
 	"ctp-future-kline/internal/quotes"
 	"ctp-future-kline/internal/replay"
 	"ctp-future-kline/internal/searchindex"
@@ -57,8 +60,11 @@ type Server struct {
 	queryReplay   *klinequery.Service
 	// calendar 管理交易日历查询与导入。
 	calendar *calendar.Manager
-	// replay 是回放任务调度服务，可为空表示未启用。
+	// replay 是回放任务调度服务，可为空表示未启用；它同时是 default 回放会话的服务。
 	replay *replay.Service
+	// replaySessions 管理并行的回放会话，replayBusLog 是各会话共享的只读 bus 日志。
+	replaySessions *replay.SessionManager
+	replayBusLog   *bus.FileLog
 	// chartRealtime/chartReplay 是不同数据域的图表布局服务。
 	chartRealtime *chartlayout.Service
 	chartReplay   *chartlayout.Service
@@ -68,6 +74,9 @@ type Server struct {
 	tradeLive        *trade.Service
 	tradePaperLive   *trade.Service
 	tradePaperReplay *trade.Service
+	// tradeSubAccounts 按小写账户标识保存 trade.accounts 子账户的三套交易服务，tradeSubAccountIDs 保留配置顺序。
+	tradeSubAccounts   map[string]*tradeAccountServices
+	tradeSubAccountIDs []string
 	// dsn 是兼容保留的默认 DSN。
 	dsn string
 	// 各逻辑库 DSN。
@@ -107,6 +116,8 @@ type Server struct {
 type wsClient struct {
 	subs      map[string]quotes.ChartSubscription
 	quoteSubs map[string]quotes.ChartSubscription
+	// tradeAccounts 是客户端订阅的交易账户（主账户为空串）；nil 表示接收全部账户的交易事件。
+	tradeAccounts map[string]struct{}
 }
 
 type runtimeStarter interface {
@@ -171,6 +182,7 @@ func NewServer(cfg config.AppConfig) *Server {
 				logger.Error("init replay dedup store failed", "error", err)
 			} else {
 				s.replay = replay.NewService(busLog, store, cfg.CTP.IsReplayAllowOrderCommandDispatch())
+				s.replayBusLog = busLog
 				replayCfg := cfg.CTP
 				replayCfg.DBDSN = replayDSN
 				replaySink, sinkErr := quotes.NewReplaySink(replayCfg, status)
@@ -241,6 +253,8 @@ func NewServer(cfg config.AppConfig) *Server {
 	if svc, err := trade.NewPaperServiceWithMeta(cfg.Trade, cfg.CTP, "paper_live", tradePaperLiveDSN, status.QueueRegistry()); err != nil {
 		logger.Error("init paper live trade service failed", "error", err)
 	} else {
+		svc.SetStrategyPositionProvider(s.strategyPositionProvider("", strategy.RunTypeRealtime))
+		s.attachAlgoMarketProvider(svc)
 		s.tradePaperLive = svc
 	}
 	if svc, err := trade.NewPaperService(cfg.Trade, "paper_replay", tradePaperReplayDSN, status.QueueRegistry()); err != nil {
@@ -251,15 +265,32 @@ func NewServer(cfg config.AppConfig) *Server {
 			s.replay.RegisterConsumer("trade.paper_replay", svc.ConsumeBusEvent)
 		}
 	}
+	s.initTradeSubAccounts(status.QueueRegistry())
 	if s.replay != nil {
 		s.replay.RegisterKlineReplayHandler(s)
 	}
 	if s.strategy != nil {
 		s.strategy.SetOrderExecutor(s)
+		s.strategy.SetPortfolioBacktester(backtest.NewEngine(s.queryRealtime, s.backtestContract).RunBacktest)
+		s.strategy.SetReplayLedgerFactory(backtest.NewReplayLedgerFactory(s.backtestContract))
+		s.strategy.SetContractMultiplier(func(symbol string) float64 {
+			_, multiple := s.backtestContract(symbol)
+			return multiple
+		})
 	}
+	s.initReplaySessions()
 	return s
 }
 
+// backtestContract 为组合回测推断交易所并取合约乘数，口径与实盘策略下单和模拟盘盈亏一致。
+func (s *Server) backtestContract(symbol string) (string, float64) {
+	exchangeID := s.inferExchangeIDForSymbol(symbol, nil, nil)
+	if s.tradePaperLive == nil {
+		return exchangeID, 1
+	}
+	return exchangeID, s.tradePaperLive.ContractVolumeMultiple(symbol, exchangeID)
+}
+
 func (s *Server) ListenAddr() string {
 	return s.cfg.Web.ListenAddr
 }
@@ -354,6 +385,9 @@ func (s *Server) startBackgroundServices() {
 			return nil
 		})
 	}
+	if len(s.tradeSubAccountIDs) > 0 {
+		s.runStartupTask("trade_sub_accounts", "子账户模拟交易", "后台准备 trade.accounts 子账户的模拟账本。", s.startTradeSubAccountPapers)
+	}
 }
 
 func (s *Server) Handler() http.Handler {
@@ -371,6 +405,7 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/api/kline/index/rebuild-all", s.handleKlineIndexRebuildAll)
 	mux.HandleFunc("/api/kline/index/rebuild-one", s.handleKlineIndexRebuildOne)
 	mux.HandleFunc("/api/kline/bars", s.handleKlineBars)
+	mux.HandleFunc("/api/kline/indicators", s.handleKlineIndicators)
 	mux.HandleFunc("/api/kline/generation-settings", s.handleKlineGenerationSettings)
 	mux.HandleFunc("/api/instruments", s.handleInstruments)
 	mux.HandleFunc("/api/commission-rates", s.handleCommissionRates)
@@ -385,6 +420,10 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/api/replay/stop", s.handleReplayStop)
 	mux.HandleFunc("/api/replay/speed", s.handleReplaySpeed)
 	mux.HandleFunc("/api/replay/status", s.handleReplayStatus)
+	mux.HandleFunc("/api/replay/step", s.handleReplayStep)
+	mux.HandleFunc("/api/replay/seek", s.handleReplaySeek)
+	mux.HandleFunc("/api/replay/bookmarks", s.handleReplayBookmarks)
+	mux.HandleFunc("/api/replay/sessions", s.handleReplaySessions)
 	mux.HandleFunc("/api/chart/layout", s.handleChartLayout)
 	mux.HandleFunc("/api/chart/drawings", s.handleChartDrawings)
 	mux.HandleFunc("/api/chart/drawings/", s.handleChartDrawingsByID)
@@ -394,19 +433,27 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/api/strategy/restart", s.handleStrategyRestart)
 	mux.HandleFunc("/api/strategy/compositions", s.handleStrategyCompositions)
 	mux.HandleFunc("/api/strategy/definitions", s.handleStrategyDefinitions)
+	mux.HandleFunc("/api/strategy/definitions/", s.handleStrategyDefinitionAction)
 	mux.HandleFunc("/api/strategy/instances", s.handleStrategyInstances)
 	mux.HandleFunc("/api/strategy/instances/", s.handleStrategyInstanceAction)
 	mux.HandleFunc("/api/strategy/signals", s.handleStrategySignals)
 	mux.HandleFunc("/api/strategy/traces", s.handleStrategyTraces)
+	mux.HandleFunc("/api/strategy/latency", s.handleStrategyLatency)
+	mux.HandleFunc("/api/strategy/approvals", s.handleStrategyApprovals)
+	mux.HandleFunc("/api/strategy/approvals/", s.handleStrategyApprovalAction)
+	mux.HandleFunc("/api/strategy/replay-compare", s.handleStrategyReplayCompare)
+	mux.HandleFunc("/api/strategy/replay-compare/", s.handleStrategyReplayCompareAction)
 	mux.HandleFunc("/api/strategy/backtests", s.handleStrategyBacktests)
 	mux.HandleFunc("/api/strategy/backtests/", s.handleStrategyBacktestByID)
 	mux.HandleFunc("/api/strategy/optimize", s.handleStrategyOptimize)
+	mux.HandleFunc("/api/strategy/optimize/", s.handleStrategyOptimizeDetail)
 	mux.HandleFunc("/api/orders/status", s.handleOrdersStatus)
 	mux.HandleFunc("/api/orders/pause", s.handleOrdersPause)
 	mux.HandleFunc("/api/orders/resume", s.handleOrdersResume)
 	mux.HandleFunc("/api/orders/audit", s.handleOrdersAudit)
 	mux.HandleFunc("/api/trade/status", s.handleTradeStatus)
 	mux.HandleFunc("/api/trade/config", s.handleTradeConfig)
+	mux.HandleFunc("/api/trade/accounts", s.handleTradeAccounts)
 	mux.HandleFunc("/api/trade/terminal", s.handleTradeTerminal)
 	mux.HandleFunc("/api/trade/account", s.handleTradeAccount)
 	mux.HandleFunc("/api/trade/account/adjust", s.handleTradeAccountAdjust)
@@ -418,6 +465,15 @@ func (s *Server) Handler() http.Handler {
 	mux.HandleFunc("/api/trade/line-orders/", s.handleTradeLineOrderAction)
 	mux.HandleFunc("/api/trade/trades", s.handleTradeTrades)
 	mux.HandleFunc("/api/trade/query/refresh", s.handleTradeRefresh)
+	mux.HandleFunc("/api/trade/reconcile", s.handleTradeReconcile)
+	mux.HandleFunc("/api/trade/reconcile/", s.handleTradeReconcileAction)
+	mux.HandleFunc("/api/trade/settlements", s.handleTradeSettlements)
+	mux.HandleFunc("/api/trade/settlements/", s.handleTradeSettlementDetail)
+	mux.HandleFunc("/api/trade/pnl/daily", s.handleTradeDailyPnL)
+	mux.HandleFunc("/api/trade/parent-orders", s.handleTradeParentOrders)
+	mux.HandleFunc("/api/trade/parent-orders/", s.handleTradeParentOrderAction)
+	mux.HandleFunc("/api/trade/journal", s.handleTradeJournal)
+	mux.HandleFunc("/api/trade/lots", s.handleTradeLots)
 	mux.HandleFunc("/api/client-log", s.handleClientLog)
 	mux.HandleFunc("/ws", s.handleWS)
 	mux.Handle("/", s.handleFrontend())
@@ -662,6 +718,9 @@ func (s *Server) handleAppMode(w http.ResponseWriter, r *http.Request) {
 				_ = s.userConfig.SaveReplayResumeCursor(s.currentOwner(), cursor)
 			}
 		}
+		if prevMode == appmode.ReplayPaper && nextMode != appmode.ReplayPaper {
+			s.stopAllReplaySessions()
+		}
 		if err := s.userConfig.SaveAppMode(s.currentOwner(), nextMode); err != nil {
 			http.Error(w, "save app mode failed: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -962,12 +1021,14 @@ func (s *Server) removeWSConn(conn *websocket.Conn) {
 	}
 	delete(s.wsConns, conn)
 	s.mu.Unlock()
-	if s.chartStream != nil {
-		for _, sub := range subs {
-			s.chartStream.RemoveInterest(sub)
+	for _, sub := range subs {
+		if stream, inner := s.chartStreamFor(sub); stream != nil {
+			stream.RemoveInterest(inner)
 		}
-		for _, sub := range quoteSubs {
-			s.chartStream.RemoveQuoteInterest(sub)
+	}
+	for _, sub := range quoteSubs {
+		if stream, inner := s.chartStreamFor(sub); stream != nil {
+			stream.RemoveQuoteInterest(inner)
 		}
 	}
 }
@@ -989,6 +1050,8 @@ func (s *Server) handleWSMessage(conn *websocket.Conn, raw []byte) {
 		s.handleQuoteSubscribe(conn, msg.Data)
 	case "quote_unsubscribe":
 		s.handleQuoteUnsubscribe(conn, msg.Data)
+	case "trade_subscribe":
+		s.handleTradeSubscribe(conn, msg.Data)
 	case "chart_ping":
 		_ = s.writeConnJSON(conn, map[string]any{"type": "chart_pong", "data": map[string]any{}})
 	}
@@ -1000,15 +1063,17 @@ func (s *Server) handleChartSubscribe(conn *websocket.Conn, raw json.RawMessage)
 		s.writeChartSubscriptionError(conn, req, "invalid subscribe payload")
 		return
 	}
-	if s.chartStream == nil {
+	stream, inner := s.chartStreamFor(req)
+	if stream == nil {
 		s.writeChartSubscriptionError(conn, req, "chart stream is not available")
 		return
 	}
-	sub, err := s.chartStream.AddInterest(req)
+	inner, err := stream.AddInterest(inner)
 	if err != nil {
 		s.writeChartSubscriptionError(conn, req, err.Error())
 		return
 	}
+	sub := withReplaySession(inner, req.ReplaySession)
 	key := quotes.ChartSubscriptionKey(sub)
 	var added bool
 	s.mu.Lock()
@@ -1029,11 +1094,13 @@ func (s *Server) handleChartSubscribe(conn *websocket.Conn, raw json.RawMessage)
 		"type", sub.Type,
 		"variety", sub.Variety,
 		"timeframe", sub.Timeframe,
+		"replay_session", sub.ReplaySession,
 	)
 	if !added {
-		s.chartStream.RemoveInterest(sub)
+		stream.RemoveInterest(inner)
 		return
 	}
+	s.seedChartIndicators(stream, sub)
 	subPayload := map[string]any{
 		"symbol":    sub.Symbol,
 		"type":      sub.Type,
@@ -1041,6 +1108,9 @@ func (s *Server) handleChartSubscribe(conn *websocket.Conn, raw json.RawMessage)
 		"timeframe": sub.Timeframe,
 		"data_mode": sub.DataMode,
 	}
+	if sub.ReplaySession != "" {
+		subPayload["replay_session"] = sub.ReplaySession
+	}
 	if s.subscriptionTickets != nil {
 		ack := s.subscriptionTickets.Resolve(s.currentOwner(), subPayload, sub.Variety, s.currentAppMode())
 		_ = s.writeConnJSON(conn, map[string]any{
@@ -1048,17 +1118,70 @@ func (s *Server) handleChartSubscribe(conn *websocket.Conn, raw json.RawMessage)
 			"data": ack,
 		})
 	}
-	if update, ok := s.chartStream.SnapshotBar(sub); ok {
+	if update, ok := stream.SnapshotBar(inner); ok {
+		update.Subscription.ReplaySession = sub.ReplaySession
 		_ = s.writeConnJSON(conn, map[string]any{
 			"type": "chart_bar_update",
 			"data": update,
 		})
 	}
-	if update, ok := s.chartStream.SnapshotQuote(sub); ok {
+	if update, ok := stream.SnapshotQuote(inner); ok {
+		update.Subscription.ReplaySession = sub.ReplaySession
 		s.broadcastQuoteUpdate(update)
 	}
 }
 
+// withReplaySession 把图表流返回的订阅重新打上回放会话标识，default 会话保持为空。
+func withReplaySession(sub quotes.ChartSubscription, session string) quotes.ChartSubscription {
+	session = strings.ToLower(strings.TrimSpace(session))
+	if session == replay.DefaultSessionID {
+		session = ""
+	}
+	sub.ReplaySession = session
+	return sub
+}
+
+// seedChartIndicators 用最近的历史 K 线预热订阅附带的实时指标；会话订阅从会话回放行情库取数。
+func (s *Server) seedChartIndicators(stream *quotes.ChartStream, sub quotes.ChartSubscription) {
+	if len(sub.Indicators) == 0 {
+		return
+	}
+	warmup := 1
+	for _, raw := range sub.Indicators {
+		if spec, err := indicators.ParseSpec(raw); err == nil && spec.Warmup() > warmup {
+			warmup = spec.Warmup()
+		}
+	}
+	query := s.queryRealtime
+	if sub.DataMode == "replay" {
+		query, _ = s.replayQueryForSession(sub.ReplaySession)
+	}
+	if query == nil {
+		return
+	}
+	resp, err := query.BarsByEnd(sub.Symbol, sub.Type, sub.Variety, sub.Timeframe, time.Now(), warmup)
+	if err != nil {
+		logger.Info("chart indicators warmup skipped", "symbol", sub.Symbol, "timeframe", sub.Timeframe, "error", err)
+		return
+	}
+	bars := make([]quotes.ChartBar, 0, len(resp.Bars))
+	for _, bar := range resp.Bars {
+		bars = append(bars, quotes.ChartBar{
+			AdjustedTime: bar.AdjustedTime,
+			DataTime:     bar.DataTime,
+			Open:         bar.Open,
+			High:         bar.High,
+			Low:          bar.Low,
+			Close:        bar.Close,
+			Volume:       bar.Volume,
+			OpenInterest: bar.OpenInterest,
+		})
+	}
+	inner := sub
+	inner.ReplaySession = ""
+	stream.SeedIndicators(inner, bars)
+}
+
 func (s *Server) handleChartUnsubscribe(conn *websocket.Conn, raw json.RawMessage) {
 	var req quotes.ChartSubscription
 	if err := json.Unmarshal(raw, &req); err != nil {
@@ -1072,8 +1195,9 @@ func (s *Server) handleChartUnsubscribe(conn *websocket.Conn, raw json.RawMessag
 	var removed bool
 	s.mu.Lock()
 	if client := s.wsConns[conn]; client != nil {
-		if _, exists := client.subs[key]; exists {
+		if stored, exists := client.subs[key]; exists {
 			delete(client.subs, key)
+			sub = stored
 			removed = true
 		}
 	}
@@ -1085,9 +1209,13 @@ func (s *Server) handleChartUnsubscribe(conn *websocket.Conn, raw json.RawMessag
 		"type", sub.Type,
 		"variety", sub.Variety,
 		"timeframe", sub.Timeframe,
+		"replay_session", sub.ReplaySession,
 	)
-	if removed && s.chartStream != nil {
-		s.chartStream.RemoveInterest(sub)
+	if !removed {
+		return
+	}
+	if stream, inner := s.chartStreamFor(sub); stream != nil {
+		stream.RemoveInterest(inner)
 	}
 }
 
@@ -1097,15 +1225,17 @@ func (s *Server) handleQuoteSubscribe(conn *websocket.Conn, raw json.RawMessage)
 		s.writeChartSubscriptionError(conn, req, "invalid subscribe payload")
 		return
 	}
-	if s.chartStream == nil {
+	stream, inner := s.chartStreamFor(req)
+	if stream == nil {
 		s.writeChartSubscriptionError(conn, req, "chart stream is not available")
 		return
 	}
-	sub, err := s.chartStream.AddQuoteInterest(req)
+	inner, err := stream.AddQuoteInterest(inner)
 	if err != nil {
 		s.writeChartSubscriptionError(conn, req, err.Error())
 		return
 	}
+	sub := withReplaySession(inner, req.ReplaySession)
 	key := quotes.ChartSubscriptionKey(sub)
 	var added bool
 	s.mu.Lock()
@@ -1128,10 +1258,11 @@ func (s *Server) handleQuoteSubscribe(conn *websocket.Conn, raw json.RawMessage)
 		"timeframe", sub.Timeframe,
 	)
 	if !added {
-		s.chartStream.RemoveQuoteInterest(sub)
+		stream.RemoveQuoteInterest(inner)
 		return
 	}
-	if update, ok := s.chartStream.SnapshotQuote(sub); ok {
+	if update, ok := stream.SnapshotQuote(inner); ok {
+		update.Subscription.ReplaySession = sub.ReplaySession
 		s.broadcastQuoteUpdate(update)
 	}
 }
@@ -1149,8 +1280,9 @@ func (s *Server) handleQuoteUnsubscribe(conn *websocket.Conn, raw json.RawMessag
 	var removed bool
 	s.mu.Lock()
 	if client := s.wsConns[conn]; client != nil {
-		if _, exists := client.quoteSubs[key]; exists {
+		if stored, exists := client.quoteSubs[key]; exists {
 			delete(client.quoteSubs, key)
+			sub = stored
 			removed = true
 		}
 	}
@@ -1163,8 +1295,11 @@ func (s *Server) handleQuoteUnsubscribe(conn *websocket.Conn, raw json.RawMessag
 		"variety", sub.Variety,
 		"timeframe", sub.Timeframe,
 	)
-	if removed && s.chartStream != nil {
-		s.chartStream.RemoveQuoteInterest(sub)
+	if !removed {
+		return
+	}
+	if stream, inner := s.chartStreamFor(sub); stream != nil {
+		stream.RemoveQuoteInterest(inner)
 	}
 }
 
@@ -1436,7 +1571,12 @@ func (s *Server) handleKlineBars(w http.ResponseWriter, r *http.Request) {
 		"resolved_mode", mode,
 		"market_database", s.marketDatabaseForMode(mode),
 	)
-	resp, err := s.queryForMode(mode).BarsByEnd(symbol, kind, variety, timeframe, end, limit)
+	querySvc, err := s.klineQueryForRequest(r, mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := querySvc.BarsByEnd(symbol, kind, variety, timeframe, end, limit)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			logger.Info("api kline bars no rows", "symbol", symbol, "type", kind, "variety", variety)
@@ -1466,6 +1606,66 @@ func (s *Server) handleKlineBars(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// handleKlineIndicators 按 indicators=ma(20),macd(12,26,9) 这样的列表批量计算指标，K 线口径与 /api/kline/bars 一致。
+func (s *Server) handleKlineIndicators(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	symbol := strings.TrimSpace(q.Get("symbol"))
+	kind := strings.TrimSpace(q.Get("type"))
+	if kind == "" {
+		kind = inferKlineTypeBySymbol(symbol)
+	}
+	variety := strings.TrimSpace(q.Get("variety"))
+	timeframe := strings.TrimSpace(q.Get("timeframe"))
+	if timeframe == "" {
+		timeframe = "1m"
+	}
+	if symbol == "" {
+		http.Error(w, "symbol is required", http.StatusBadRequest)
+		return
+	}
+	specs, err := indicators.ParseSpecs(q.Get("indicators"))
+	if err != nil {
+		http.Error(w, "invalid indicators: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(specs) == 0 {
+		http.Error(w, "indicators is required, supported: "+strings.Join(indicators.Names(), ","), http.StatusBadRequest)
+		return
+	}
+	end, err := parseOptionalEndTime(q.Get("end"))
+	if err != nil {
+		http.Error(w, "invalid end: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit := parseLimitArg(q.Get("limit"), 2000, 5000)
+	mode := s.currentKlineQueryMode(r)
+	querySvc, err := s.klineQueryForRequest(r, mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := querySvc.IndicatorsByEnd(symbol, kind, variety, timeframe, end, limit, specs)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "symbol not found", http.StatusNotFound)
+		case errors.Is(err, klinequery.ErrInvalidTimeframe):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, klinequery.ErrTradingSessionNotReady):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			logger.Error("api kline indicators failed", "symbol", symbol, "type", kind, "variety", variety, "timeframe", timeframe, "indicators", q.Get("indicators"), "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
 func (s *Server) handleInstruments(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -1775,40 +1975,31 @@ func (s *Server) handleReplayStart(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
 	if s.currentAppMode() != appmode.ReplayPaper {
 		http.Error(w, "replay is only available in replay_paper mode", http.StatusBadRequest)
 		return
 	}
+	env := replayEnvOf(sess)
 	var req replay.StartRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
-	if strings.TrimSpace(req.Mode) == "" {
-		req.Mode = s.cfg.CTP.ReplayDefaultMode
-	}
-	if strings.TrimSpace(req.Mode) == "" {
-		req.Mode = "kline"
-	}
-	if strings.EqualFold(strings.TrimSpace(req.Mode), "fast") {
-		req.Mode = "kline"
-	}
-	if req.Speed == 0 {
-		req.Speed = s.cfg.CTP.ReplayDefaultSpeed
-	}
-	if req.Mode != "kline" && strings.TrimSpace(req.TickDir) == "" {
-		req.TickDir = filepath.Join(s.cfg.CTP.FlowPath, "ticks")
-	}
-	req.SharedMetaDSN = strings.TrimSpace(s.sharedDSN)
+	req = req.WithDefaults(s.replayStartDefaults())
 	logger.Info(
 		"replay start request normalized",
+		"session_id", sess.ID,
 		"mode", req.Mode,
 		"speed", req.Speed,
 		"tick_dir", req.TickDir,
+		"trading_day_from", req.TradingDayFrom,
+		"trading_day_to", req.TradingDayTo,
+		"tick_archive_dir", req.TickArchiveDir,
+		"fast_forward_gaps", req.FastForwardGaps,
 		"full_replay", req.FullReplay,
 		"topics", strings.Join(req.Topics, ","),
 		"sources", strings.Join(req.Sources, ","),
@@ -1816,37 +2007,39 @@ func (s *Server) handleReplayStart(w http.ResponseWriter, r *http.Request) {
 		"has_end_time", req.EndTime != nil,
 	)
 	prepares := make([]replay.StartPrepareFunc, 0, 3)
-	if s.tradePaperReplay != nil {
+	if paperReplays := s.replayPaperServices(env); len(paperReplays) > 0 {
 		prepares = append(prepares, func(ctx context.Context, req replay.StartRequest) error {
 			_ = ctx
 			stepStartedAt := time.Now()
 			logger.Info("replay prepare begin", "step", "reset_paper_replay_account")
-			if err := s.tradePaperReplay.ResetPaperReplay(); err != nil {
-				return fmt.Errorf("reset replay paper account failed: %w", err)
+			for _, svc := range paperReplays {
+				if err := svc.ResetPaperReplay(); err != nil {
+					return fmt.Errorf("reset replay paper account %s failed: %w", svc.AccountID(), err)
+				}
 			}
 			logger.Info("replay prepare done", "step", "reset_paper_replay_account", "elapsed_ms", time.Since(stepStartedAt).Milliseconds())
 			return nil
 		})
 	}
-	if s.replaySink != nil {
+	if env.sink != nil {
 		prepares = append(prepares, func(ctx context.Context, req replay.StartRequest) error {
 			_ = ctx
 			stepStartedAt := time.Now()
 			logger.Info("replay prepare begin", "step", "prepare_replay_window")
-			if err := s.replaySink.PrepareReplayWindow(req); err != nil {
+			if err := env.sink.PrepareReplayWindow(req); err != nil {
 				return fmt.Errorf("prepare replay window failed: %w", err)
 			}
 			logger.Info("replay prepare done", "step", "prepare_replay_window", "elapsed_ms", time.Since(stepStartedAt).Milliseconds())
 			return nil
 		})
 	}
-	if s.chartStream != nil {
+	if env.chart != nil {
 		prepares = append(prepares, func(ctx context.Context, req replay.StartRequest) error {
 			_ = ctx
 			_ = req
 			stepStartedAt := time.Now()
 			logger.Info("replay prepare begin", "step", "reset_chart_replay_state")
-			s.chartStream.ResetReplayState()
+			env.chart.ResetReplayState()
 			logger.Info("replay prepare done", "step", "reset_chart_replay_state", "elapsed_ms", time.Since(stepStartedAt).Milliseconds())
 			return nil
 		})
@@ -1854,7 +2047,7 @@ func (s *Server) handleReplayStart(w http.ResponseWriter, r *http.Request) {
 	if !req.FullReplay {
 		logger.Info("replay start requested without full replay", "tick_dir", req.TickDir)
 	}
-	task, err := s.replay.StartWithPrepare(req, prepares)
+	task, err := sess.Service.StartWithPrepare(req, prepares)
 	if err != nil {
 		logger.Info("replay start request rejected", "error", err, "elapsed_ms", time.Since(startedAt).Milliseconds())
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -1862,6 +2055,7 @@ func (s *Server) handleReplayStart(w http.ResponseWriter, r *http.Request) {
 	}
 	logger.Info(
 		"replay start response ready",
+		"session_id", sess.ID,
 		"task_id", task.TaskID,
 		"status", task.Status,
 		"mode", task.Mode,
@@ -1872,6 +2066,16 @@ func (s *Server) handleReplayStart(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, replay.StartResponse{OK: true, Task: task})
 }
 
+// replayStartDefaults 返回回放启动请求的默认值。
+func (s *Server) replayStartDefaults() replay.StartDefaults {
+	return replay.StartDefaults{
+		Mode:          s.cfg.CTP.ReplayDefaultMode,
+		Speed:         s.cfg.CTP.ReplayDefaultSpeed,
+		FlowPath:      s.cfg.CTP.FlowPath,
+		SharedMetaDSN: s.sharedDSN,
+	}
+}
+
 func (s *Server) handleReplayPause(w http.ResponseWriter, r *http.Request) {
 	startedAt := time.Now()
 	logger.Info("replay pause button request received", "method", r.Method, "path", r.URL.Path)
@@ -1879,11 +2083,11 @@ func (s *Server) handleReplayPause(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
-	task, err := s.replay.Pause()
+	task, err := sess.Service.Pause()
 	if err != nil {
 		logger.Info("replay pause request rejected", "error", err, "elapsed_ms", time.Since(startedAt).Milliseconds())
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -1900,11 +2104,11 @@ func (s *Server) handleReplayResume(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
-	task, err := s.replay.Resume()
+	task, err := sess.Service.Resume()
 	if err != nil {
 		logger.Info("replay resume request rejected", "error", err, "elapsed_ms", time.Since(startedAt).Milliseconds())
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -1921,11 +2125,11 @@ func (s *Server) handleReplayStop(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
-	task, err := s.replay.Stop()
+	task, err := sess.Service.Stop()
 	if err != nil {
 		logger.Info("replay stop request rejected", "error", err, "elapsed_ms", time.Since(startedAt).Milliseconds())
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -1940,8 +2144,8 @@ func (s *Server) handleReplaySpeed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
 	var req replay.SpeedUpdateRequest
@@ -1949,7 +2153,7 @@ func (s *Server) handleReplaySpeed(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
-	task, err := s.replay.UpdateSpeed(req.Speed)
+	task, err := sess.Service.UpdateSpeed(req.Speed)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -1962,11 +2166,11 @@ func (s *Server) handleReplayStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.replay == nil {
-		http.Error(w, "replay is disabled", http.StatusBadRequest)
+	sess := s.requireReplaySession(w, r)
+	if sess == nil {
 		return
 	}
-	writeJSON(w, http.StatusOK, replay.StatusResponse{Task: s.replay.Status()})
+	writeJSON(w, http.StatusOK, replay.StatusResponse{Task: sess.Service.Status()})
 }
 
 func (s *Server) handleChartLayout(w http.ResponseWriter, r *http.Request) {
@@ -2226,6 +2430,42 @@ func (s *Server) handleStrategyDefinitions(w http.ResponseWriter, r *http.Reques
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
+// handleStrategyDefinitionAction 提供策略定义的版本历史（/versions）和两个版本间的参数结构对比（/diff?from=&to=）。
+func (s *Server) handleStrategyDefinitionAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/strategy/definitions/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	switch parts[1] {
+	case "versions":
+		items, err := manager.ListDefinitionVersions(parts[0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case "diff":
+		q := r.URL.Query()
+		diff, err := manager.DiffDefinitionVersions(parts[0], q.Get("from"), q.Get("to"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, diff)
+	default:
+		http.Error(w, "invalid action", http.StatusBadRequest)
+	}
+}
+
 func (s *Server) handleStrategyCompositions(w http.ResponseWriter, r *http.Request) {
 	if s.userConfig == nil {
 		http.Error(w, "user config store unavailable", http.StatusInternalServerError)
@@ -2443,6 +2683,22 @@ func (s *Server) handleStrategyInstanceAction(w http.ResponseWriter, r *http.Req
 		err = manager.StartInstance(parts[0])
 	case "stop":
 		err = manager.StopInstance(parts[0])
+	case "resume":
+		status, resumeErr := manager.ResumeInstanceRisk(parts[0])
+		if resumeErr != nil {
+			http.Error(w, resumeErr.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	case "reload":
+		inst, reloadErr := manager.ReloadInstance(parts[0])
+		if reloadErr != nil {
+			http.Error(w, reloadErr.Error(), http.StatusConflict)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "instance": inst})
+		return
 	default:
 		http.Error(w, "invalid action", http.StatusBadRequest)
 		return
@@ -2499,6 +2755,152 @@ func (s *Server) handleStrategyTraces(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
+// handleStrategyLatency 返回实盘决策链路的延迟 span；分位数汇总在 /api/strategy/status 的 latency 字段。
+func (s *Server) handleStrategyLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	q := r.URL.Query()
+	items, err := manager.ListLatencySpans(q.Get("instance_id"), parseLimitArg(q.Get("limit"), 100, 500))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleStrategyApprovals 返回确认模式下的信号审批请求，可按 instance_id 和 status 过滤。
+func (s *Server) handleStrategyApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	q := r.URL.Query()
+	items, err := manager.ListApprovals(q.Get("instance_id"), q.Get("status"), parseLimitArg(q.Get("limit"), 100, 500))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// handleStrategyApprovalAction 处理 POST /api/strategy/approvals/{id}/approve|reject。
+func (s *Server) handleStrategyApprovalAction(w http.ResponseWriter, r *http.Request) {
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/strategy/approvals/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var action strategy.ApprovalAction
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&action); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+	}
+	if strings.TrimSpace(action.Operator) == "" {
+		action.Operator = s.currentOwner()
+	}
+	var (
+		approval strategy.SignalApproval
+		err      error
+	)
+	switch parts[1] {
+	case "approve":
+		approval, err = manager.ApproveSignal(parts[0], action)
+	case "reject":
+		approval, err = manager.RejectSignal(parts[0], action)
+	default:
+		http.Error(w, "invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusOK, approval)
+}
+
+// handleStrategyReplayCompare 列出（GET）或创建（POST）A/B 回放对比。
+func (s *Server) handleStrategyReplayCompare(w http.ResponseWriter, r *http.Request) {
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"items": manager.ListReplayComparisons()})
+	case http.MethodPost:
+		var req strategy.ReplayCompareRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		cmp, err := manager.StartReplayComparison(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, cmp)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStrategyReplayCompareAction 处理 GET /api/strategy/replay-compare/{id}（对比报告）
+// 和 POST /api/strategy/replay-compare/{id}/stop（提前结束并保存报告）。
+func (s *Server) handleStrategyReplayCompareAction(w http.ResponseWriter, r *http.Request) {
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/strategy/replay-compare/"))
+	if compareID, ok := strings.CutSuffix(path, "/stop"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report, err := manager.StopReplayComparison(compareID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+	if path == "" || strings.Contains(path, "/") {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	report, err := manager.ReplayComparisonReport(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
 func (s *Server) handleStrategyBacktests(w http.ResponseWriter, r *http.Request) {
 	manager := s.requireStrategy(w)
 	if manager == nil {
@@ -2530,6 +2932,10 @@ func (s *Server) handleStrategyBacktests(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *Server) handleStrategyBacktestByID(w http.ResponseWriter, r *http.Request) {
+	if runID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/strategy/backtests/"), "/montecarlo"); ok {
+		s.handleStrategyMonteCarlo(w, r, strings.TrimSpace(runID))
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -2539,6 +2945,10 @@ func (s *Server) handleStrategyBacktestByID(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/strategy/backtests/"))
+	if runID, ok := strings.CutSuffix(path, "/performance"); ok {
+		s.writeStrategyPerformance(w, r, manager, strings.TrimSpace(runID))
+		return
+	}
 	wantResult := false
 	if strings.HasSuffix(path, "/result") {
 		wantResult = true
@@ -2566,6 +2976,85 @@ func (s *Server) handleStrategyBacktestByID(w http.ResponseWriter, r *http.Reque
 	writeJSON(w, http.StatusOK, run)
 }
 
+// handleStrategyMonteCarlo 处理运行的蒙特卡洛分析：GET 列出已有分析，POST 按请求参数启动一次新分析。
+func (s *Server) handleStrategyMonteCarlo(w http.ResponseWriter, r *http.Request, runID string) {
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	if runID == "" {
+		http.Error(w, "run id is required", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := manager.ListMonteCarloRuns(runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var req strategy.MonteCarloRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		run, err := manager.RunMonteCarlo(runID, req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeStrategyPerformance 导出运行记录的绩效报告：默认返回 JSON，table=equity|daily 时返回对应 CSV。
+func (s *Server) writeStrategyPerformance(w http.ResponseWriter, r *http.Request, manager *strategy.Manager, runID string) {
+	run, err := manager.GetRun(runID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	result, err := loadStrategyBacktestResultFile(run.OutputPath, run.RunID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	raw, ok := result["performance"]
+	if !ok {
+		http.Error(w, "run has no performance report", http.StatusNotFound)
+		return
+	}
+	var report perf.Report
+	body, _ := json.Marshal(raw)
+	if err := json.Unmarshal(body, &report); err != nil {
+		http.Error(w, "parse performance report failed", http.StatusInternalServerError)
+		return
+	}
+	table := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("table")))
+	var write func(io.Writer) error
+	switch table {
+	case "":
+		writeJSON(w, http.StatusOK, map[string]any{"run": run, "performance": report})
+		return
+	case "equity":
+		write = report.WriteEquityCSV
+	case "daily":
+		write = report.WriteDailyCSV
+	default:
+		http.Error(w, "table must be equity or daily", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", run.RunID+"_"+table+".csv"))
+	_ = write(w)
+}
+
 func loadStrategyBacktestResultFile(path string, runID string) (map[string]any, error) {
 	path = strings.TrimSpace(path)
 	if path == "" {
@@ -2618,6 +3107,48 @@ func (s *Server) handleStrategyOptimize(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, run)
 }
 
+// handleStrategyOptimizeDetail 处理 GET /api/strategy/optimize/{run_id}（任务及全部试验）
+// 和 POST /api/strategy/optimize/{run_id}/resume（续跑中断的任务）。
+func (s *Server) handleStrategyOptimizeDetail(w http.ResponseWriter, r *http.Request) {
+	manager := s.requireStrategy(w)
+	if manager == nil {
+		return
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/strategy/optimize/"))
+	if runID, ok := strings.CutSuffix(path, "/resume"); ok {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		run, err := manager.ResumeOptimization(strings.TrimSpace(runID))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if path == "" {
+		http.Error(w, "run id is required", http.StatusBadRequest)
+		return
+	}
+	run, err := manager.GetRun(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	trials, err := manager.ListOptimizationTrials(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"run": run, "trials": trials})
+}
+
 func (s *Server) handleOrdersStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -2671,8 +3202,14 @@ func (s *Server) handleOrdersAudit(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"items": items})
 }
 
-func (s *Server) requireTrade(w http.ResponseWriter) *trade.Service {
-	svc := s.getTradeService()
+// requireTrade 按查询参数 account_id 选出当前模式下的交易服务，未指定时使用主账户。
+func (s *Server) requireTrade(w http.ResponseWriter, r *http.Request) *trade.Service {
+	subID, ok := s.resolveTradeAccountID(r.URL.Query().Get("account_id"))
+	if !ok {
+		http.Error(w, "unknown trade account: "+strings.TrimSpace(r.URL.Query().Get("account_id")), http.StatusNotFound)
+		return nil
+	}
+	svc := s.tradeServiceForAccount(subID)
 	if svc == nil {
 		http.Error(w, "trade service unavailable", http.StatusNotFound)
 		return nil
@@ -2724,10 +3261,21 @@ func (s *Server) tradeStatusSnapshot() trade.TradeStatus {
 	}
 }
 
+// startTradeService 启动主账户和各子账户的实盘交易服务，账户之间互不影响：
+// 某个账户启动失败只记录错误，其它账户照常启动，最后合并返回所有失败。
 func (s *Server) startTradeService() error {
 	if s.currentAppMode() != appmode.LiveReal {
 		return nil
 	}
+	primaryErr := s.startTradePrimaryLive()
+	if primaryErr != nil {
+		logger.Error("start primary trade service failed", "account_id", s.cfg.Trade.AccountID, "error", primaryErr)
+		primaryErr = fmt.Errorf("account %s: %w", s.cfg.Trade.AccountID, primaryErr)
+	}
+	return errors.Join(primaryErr, s.startTradeSubAccountLive())
+}
+
+func (s *Server) startTradePrimaryLive() error {
 	s.mu.Lock()
 	if s.tradeLive != nil {
 		s.mu.Unlock()
@@ -2738,6 +3286,8 @@ func (s *Server) startTradeService() error {
 	if err != nil {
 		return err
 	}
+	svc.SetStrategyPositionProvider(s.strategyPositionProvider("", strategy.RunTypeRealtime))
+	s.attachAlgoMarketProvider(svc)
 	if err := svc.Start(); err != nil {
 		_ = svc.Close()
 		return err
@@ -2755,14 +3305,18 @@ func (s *Server) startTradeService() error {
 }
 
 func (s *Server) stopTradeService() error {
+	subErr := s.stopTradeSubAccountLive()
 	s.mu.Lock()
 	svc := s.tradeLive
 	s.tradeLive = nil
 	s.mu.Unlock()
 	if svc == nil {
-		return nil
+		return subErr
+	}
+	if err := svc.Close(); err != nil {
+		return err
 	}
-	return svc.Close()
+	return subErr
 }
 
 func (s *Server) handleTradeStatus(w http.ResponseWriter, r *http.Request) {
@@ -2770,7 +3324,12 @@ func (s *Server) handleTradeStatus(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{"status": s.tradeStatusSnapshot()})
+	status, ok := s.tradeStatusSnapshotForRequest(r)
+	if !ok {
+		http.Error(w, "unknown trade account: "+strings.TrimSpace(r.URL.Query().Get("account_id")), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": status})
 }
 
 func (s *Server) handleTradeConfig(w http.ResponseWriter, r *http.Request) {
@@ -2830,7 +3389,7 @@ func (s *Server) handleTradeTerminal(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -2865,8 +3424,14 @@ func (s *Server) buildTradeTerminalSnapshot(r *http.Request, svc *trade.Service)
 	quote := s.chartQuoteSnapshotForSymbol(symbol)
 	mode := appmode.Normalize(s.currentAppMode())
 	replayTime := ""
-	if mode == appmode.ReplayPaper && s.replay != nil {
-		if ts := s.replay.Status().CurrentSimTime; ts != nil && !ts.IsZero() {
+	replaySvc := s.replay
+	if subID, ok := s.resolveTradeAccountID(r.URL.Query().Get("account_id")); ok && strings.HasPrefix(subID, replaySessionAccountPrefix) {
+		if sess := s.replaySession(strings.TrimPrefix(subID, replaySessionAccountPrefix)); sess != nil {
+			replaySvc = sess.Service
+		}
+	}
+	if mode == appmode.ReplayPaper && replaySvc != nil {
+		if ts := replaySvc.Status().CurrentSimTime; ts != nil && !ts.IsZero() {
 			replayTime = ts.Format(time.RFC3339)
 		}
 	}
@@ -3128,10 +3693,10 @@ func (s *Server) contractVolumeMultiple(symbol string, exchangeID string) float6
 	return float64(resolved.Product.VolumeMultiple)
 }
 
-func (s *Server) CurrentPosition(symbol string) (float64, error) {
-	svc := s.strategyOrderTradeService()
-	if svc == nil {
-		return 0, fmt.Errorf("strategy order trade service unavailable for mode=%s", s.currentAppMode())
+func (s *Server) CurrentPosition(accountID string, symbol string) (float64, error) {
+	svc, err := s.strategyOrderTradeService(accountID)
+	if err != nil {
+		return 0, err
 	}
 	positions, err := svc.Positions()
 	if err != nil {
@@ -3151,33 +3716,50 @@ func (s *Server) SubmitStrategyOrder(ctx context.Context, req strategy.StrategyO
 	default:
 		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: "strategy auto order only runs in live_paper/live_real"}, fmt.Errorf("strategy auto order only runs in live_paper/live_real, current mode=%s", mode)
 	}
-	svc := s.strategyOrderTradeService()
-	if svc == nil {
-		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: "trade service unavailable"}, fmt.Errorf("trade service unavailable")
+	svc, err := s.strategyOrderTradeService(req.Instance.AccountID)
+	if err != nil {
+		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: err.Error()}, err
 	}
-	submit, err := s.buildStrategySubmitOrder(req)
+	submit, err := s.buildStrategySubmitOrder(req, svc.AccountID())
 	if err != nil {
 		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: err.Error()}, err
 	}
 	if err := s.validateStrategyAccountGuard(svc, submit); err != nil {
 		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: err.Error()}, err
 	}
+	if req.Execution != nil && strings.TrimSpace(req.Execution.Style) != "" {
+		return submitStrategyParentOrder(ctx, svc, submit, *req.Execution)
+	}
 	rec, err := svc.SubmitOrder(ctx, submit)
 	if err != nil {
 		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: err.Error()}, err
 	}
+	details := map[string]any{
+		"direction":   rec.Direction,
+		"offset_flag": rec.OffsetFlag,
+		"limit_price": rec.LimitPrice,
+		"volume":      rec.VolumeTotalOriginal,
+		"account_id":  rec.AccountID,
+		"exchange_id": rec.ExchangeID,
+	}
+	var legRefs []string
+	if len(rec.Legs) > 0 {
+		legIDs := make([]string, 0, len(rec.Legs))
+		legRefs = make([]string, 0, len(rec.Legs))
+		for _, leg := range rec.Legs {
+			legIDs = append(legIDs, leg.CommandID)
+			legRefs = append(legRefs, leg.OrderRef)
+		}
+		details["leg_order_ids"] = legIDs
+	}
 	return strategy.StrategyOrderResult{
-		OrderID: rec.CommandID,
-		Status:  rec.OrderStatus,
-		Reason:  rec.StatusMsg,
-		Details: map[string]any{
-			"direction":   rec.Direction,
-			"offset_flag": rec.OffsetFlag,
-			"limit_price": rec.LimitPrice,
-			"volume":      rec.VolumeTotalOriginal,
-			"account_id":  rec.AccountID,
-			"exchange_id": rec.ExchangeID,
-		},
+		OrderID:      rec.CommandID,
+		OrderRef:     rec.OrderRef,
+		AccountID:    rec.AccountID,
+		Status:       rec.OrderStatus,
+		Reason:       rec.StatusMsg,
+		Details:      details,
+		LegOrderRefs: legRefs,
 	}, nil
 }
 
@@ -3205,24 +3787,28 @@ func (s *Server) validateStrategyAccountGuard(svc *trade.Service, req trade.Subm
 	return nil
 }
 
-func (s *Server) strategyOrderTradeService() *trade.Service {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	switch appmode.Normalize(s.currentMode) {
-	case appmode.LivePaper:
-		return s.tradePaperLive
-	case appmode.LiveReal:
-		return s.tradeLive
-	default:
-		return nil
+// strategyOrderTradeService 按策略实例绑定的账户选出下单服务；未绑定账户时使用当前模式的主账户。
+func (s *Server) strategyOrderTradeService(accountID string) (*trade.Service, error) {
+	mode := appmode.Normalize(s.currentAppMode())
+	if mode != appmode.LivePaper && mode != appmode.LiveReal {
+		return nil, fmt.Errorf("strategy order trade service unavailable for mode=%s", mode)
+	}
+	subID, ok := s.resolveTradeAccountID(accountID)
+	if !ok {
+		return nil, fmt.Errorf("strategy instance targets unknown trade account %s", strings.TrimSpace(accountID))
+	}
+	svc := s.tradeServiceForAccount(subID)
+	if svc == nil {
+		return nil, fmt.Errorf("trade service unavailable for account=%s mode=%s", firstNonEmpty(strings.TrimSpace(accountID), s.cfg.Trade.AccountID), mode)
 	}
+	return svc, nil
 }
 
-func (s *Server) buildStrategySubmitOrder(req strategy.StrategyOrderRequest) (trade.SubmitOrderRequest, error) {
+func (s *Server) buildStrategySubmitOrder(req strategy.StrategyOrderRequest, accountID string) (trade.SubmitOrderRequest, error) {
 	symbol := strings.TrimSpace(req.Symbol)
 	quote := s.chartQuoteSnapshotForSymbol(symbol)
 	exchangeID := s.inferExchangeIDForSymbol(symbol, nil, nil)
-	return buildStrategySubmitOrderRequest(req, quote, exchangeID, s.tradeAccountIDForMode(s.currentAppMode()))
+	return buildStrategySubmitOrderRequest(req, quote, exchangeID, accountID)
 }
 
 func buildStrategySubmitOrderRequest(req strategy.StrategyOrderRequest, quote quotes.ChartQuoteSnapshot, exchangeID string, accountID string) (trade.SubmitOrderRequest, error) {
@@ -3247,6 +3833,9 @@ func buildStrategySubmitOrderRequest(req strategy.StrategyOrderRequest, quote qu
 		return trade.SubmitOrderRequest{}, fmt.Errorf("cannot derive order direction from current=%.4f target=%.4f", req.CurrentPosition, req.TargetPosition)
 	}
 	limitPrice := strategyOrderLimitPrice(direction, quote)
+	if req.LimitPrice > 0 {
+		limitPrice = req.LimitPrice
+	}
 	if limitPrice <= 0 {
 		return trade.SubmitOrderRequest{}, fmt.Errorf("no valid quote price for strategy order: symbol=%s", symbol)
 	}
@@ -3263,7 +3852,7 @@ func buildStrategySubmitOrderRequest(req strategy.StrategyOrderRequest, quote qu
 		LimitPrice: limitPrice,
 		Volume:     volume,
 		Reason:     "strategy",
-		ClientTag:  "strategy-auto-" + strings.TrimSpace(req.Instance.InstanceID),
+		ClientTag:  trade.StrategyClientTag(req.Instance.InstanceID),
 	}, nil
 }
 
@@ -3351,7 +3940,7 @@ func (s *Server) handleTradeAccount(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3368,7 +3957,7 @@ func (s *Server) handleTradeAccountAdjust(w http.ResponseWriter, r *http.Request
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3377,9 +3966,10 @@ func (s *Server) handleTradeAccountAdjust(w http.ResponseWriter, r *http.Request
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
-	if strings.TrimSpace(req.AccountID) == "" {
-		req.AccountID = s.tradeAccountIDForMode(s.currentAppMode())
+	if svc = s.requireTradeForBody(w, r, svc, req.AccountID); svc == nil {
+		return
 	}
+	req.AccountID = svc.AccountID()
 	account, err := svc.AdjustAccount(req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -3393,7 +3983,7 @@ func (s *Server) handleTradePositions(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3406,7 +3996,7 @@ func (s *Server) handleTradePositions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleTradePositionAction(w http.ResponseWriter, r *http.Request) {
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3445,12 +4035,11 @@ func (s *Server) handleTradePositionAction(w http.ResponseWriter, r *http.Reques
 	if offsetFlag == "" {
 		offsetFlag = "close"
 	}
-	accountID := strings.TrimSpace(req.AccountID)
-	if accountID == "" {
-		accountID = s.tradeAccountIDForMode(s.currentAppMode())
+	if svc = s.requireTradeForBody(w, r, svc, req.AccountID); svc == nil {
+		return
 	}
 	rec, err := svc.SubmitOrder(r.Context(), trade.SubmitOrderRequest{
-		AccountID:  accountID,
+		AccountID:  svc.AccountID(),
 		Symbol:     symbol,
 		ExchangeID: strings.TrimSpace(req.ExchangeID),
 		Direction:  direction,
@@ -3468,7 +4057,7 @@ func (s *Server) handleTradePositionAction(w http.ResponseWriter, r *http.Reques
 }
 
 func (s *Server) handleTradeOrders(w http.ResponseWriter, r *http.Request) {
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3486,9 +4075,10 @@ func (s *Server) handleTradeOrders(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "invalid json body", http.StatusBadRequest)
 			return
 		}
-		if strings.TrimSpace(req.AccountID) == "" {
-			req.AccountID = s.tradeAccountIDForMode(s.currentAppMode())
+		if svc = s.requireTradeForBody(w, r, svc, req.AccountID); svc == nil {
+			return
 		}
+		req.AccountID = svc.AccountID()
 		if strings.TrimSpace(req.ExchangeID) == "" {
 			req.ExchangeID = s.inferExchangeIDForSymbol(req.Symbol, nil, nil)
 		}
@@ -3504,7 +4094,7 @@ func (s *Server) handleTradeOrders(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleTradeOrderAction(w http.ResponseWriter, r *http.Request) {
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3531,9 +4121,10 @@ func (s *Server) handleTradeOrderAction(w http.ResponseWriter, r *http.Request)
 			return
 		}
 		req.CommandID = commandID
-		if strings.TrimSpace(req.AccountID) == "" {
-			req.AccountID = s.tradeAccountIDForMode(s.currentAppMode())
+		if svc = s.requireTradeForBody(w, r, svc, req.AccountID); svc == nil {
+			return
 		}
+		req.AccountID = svc.AccountID()
 		rec, err := svc.CancelOrder(r.Context(), req)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -3631,7 +4222,7 @@ func (s *Server) handleTradeTrades(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3648,7 +4239,7 @@ func (s *Server) handleTradeRefresh(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	svc := s.requireTrade(w)
+	svc := s.requireTrade(w, r)
 	if svc == nil {
 		return
 	}
@@ -3662,6 +4253,170 @@ func (s *Server) handleTradeRefresh(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
+func (s *Server) handleTradeReconcile(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := svc.ReconcileReports(parseLimitArg(r.URL.Query().Get("limit"), 20, 200))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		report, err := svc.ReconcilePositions(r.Context(), "manual")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTradeReconcileAction(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/trade/reconcile/")
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || parts[1] != "resolve" {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+	report, err := svc.ResolveReconcile(strings.TrimSpace(parts[0]), req.Action)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "reconcile report not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) handleTradeSettlements(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := svc.Settlements(parseLimitArg(r.URL.Query().Get("limit"), 30, 366))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var req struct {
+			TradingDay string `json:"trading_day"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid json body", http.StatusBadRequest)
+				return
+			}
+		}
+		item, err := svc.FetchSettlement(req.TradingDay)
+		if err != nil {
+			if errors.Is(err, trade.ErrSettlementUnavailable) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTradeSettlementDetail(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tradingDay := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/trade/settlements/"))
+	if tradingDay == "" || strings.Contains(tradingDay, "/") {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	item, err := svc.Settlement(tradingDay)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "settlement not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) handleTradeDailyPnL(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	items, err := svc.DailyPnL(parseLimitArg(r.URL.Query().Get("limit"), 30, 366))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// strategyPositionProvider 把策略实例子持仓中属于指定账户、运行模式的部分提供给交易对账；subID 为空表示主账户。
+func (s *Server) strategyPositionProvider(subID string, runType string) func() []trade.StrategyPositionView {
+	return func() []trade.StrategyPositionView {
+		if s.strategy == nil {
+			return nil
+		}
+		var out []trade.StrategyPositionView
+		for _, item := range s.strategy.SubPositions() {
+			if item.Mode != runType {
+				continue
+			}
+			if owner, ok := s.resolveTradeAccountID(item.AccountID); !ok || owner != strings.ToLower(subID) {
+				continue
+			}
+			out = append(out, trade.StrategyPositionView{
+				InstanceID: item.InstanceID,
+				Symbol:     item.Symbol,
+				Position:   item.Position,
+			})
+		}
+		return out
+	}
+}
+
 func (s *Server) forwardStrategyEvents() {
 	if s.strategy == nil {
 		return
@@ -3677,13 +4432,94 @@ func (s *Server) forwardTradeEvents(svc *trade.Service) {
 	if svc == nil {
 		return
 	}
+	subID, _ := s.resolveTradeAccountID(svc.AccountID())
+	s.forwardTradeEventsAs(svc, subID)
+}
+
+// forwardTradeEventsAs 以给定子账户标识转发交易事件；回放会话账户在会话登记前就开始转发，不能靠 resolveTradeAccountID 反查。
+func (s *Server) forwardTradeEventsAs(svc *trade.Service, subID string) {
 	ch, cancel := svc.Subscribe()
 	defer cancel()
 	for ev := range ch {
-		s.broadcastEvent(ev.Type, ev.Data)
+		s.notifyStrategyOrder(svc, ev)
+		s.notifyStrategyFill(svc, ev)
+		s.broadcastTradeEvent(subID, ev)
 	}
 }
 
+// notifyStrategyFill 把策略委托的成交回报推给策略管理器（回放报告绩效与 Go 策略运行时）；实例通过委托的 ClientTag 识别。
+func (s *Server) notifyStrategyFill(svc *trade.Service, ev trade.EventEnvelope) {
+	if s.strategy == nil || ev.Type != "trade_trade_update" {
+		return
+	}
+	var tr trade.TradeRecord
+	switch data := ev.Data.(type) {
+	case trade.TradeRecord:
+		tr = data
+	case *trade.TradeRecord:
+		if data == nil {
+			return
+		}
+		tr = *data
+	default:
+		return
+	}
+	order, err := svc.OrderByRef(tr.OrderRef)
+	if err != nil {
+		return
+	}
+	instanceID := trade.InstanceIDFromClientTag(order.ClientTag)
+	if instanceID == "" {
+		return
+	}
+	s.strategy.HandleFill(strategy.FillEvent{
+		InstanceID:     instanceID,
+		AccountID:      tr.AccountID,
+		Symbol:         tr.Symbol,
+		ExchangeID:     tr.ExchangeID,
+		TradeID:        tr.TradeID,
+		OrderRef:       tr.OrderRef,
+		Direction:      tr.Direction,
+		OffsetFlag:     tr.OffsetFlag,
+		Price:          tr.Price,
+		Volume:         tr.Volume,
+		TradeTime:      tr.TradeTime,
+		Commission:     svc.TradeCommission(tr),
+		VolumeMultiple: svc.ContractVolumeMultiple(tr.Symbol, tr.ExchangeID),
+		ReceivedAt:     tr.ReceivedAt,
+	})
+}
+
+// notifyStrategyOrder 把策略委托的柜台报单回报推给策略管理器，用于决策链路延迟追踪。
+// 只转发柜台回调产生的 *trade.OrderRecord；下单受理时广播的是值类型记录，不是柜台回报。
+func (s *Server) notifyStrategyOrder(svc *trade.Service, ev trade.EventEnvelope) {
+	if s.strategy == nil || ev.Type != "trade_order_update" {
+		return
+	}
+	rec, ok := ev.Data.(*trade.OrderRecord)
+	if !ok || rec == nil || strings.TrimSpace(rec.OrderRef) == "" {
+		return
+	}
+	instanceID := trade.InstanceIDFromClientTag(rec.ClientTag)
+	if instanceID == "" {
+		order, err := svc.OrderByRef(rec.OrderRef)
+		if err != nil {
+			return
+		}
+		instanceID = trade.InstanceIDFromClientTag(order.ClientTag)
+	}
+	if instanceID == "" {
+		return
+	}
+	s.strategy.HandleOrderUpdate(strategy.OrderUpdateEvent{
+		InstanceID:  instanceID,
+		AccountID:   rec.AccountID,
+		OrderRef:    rec.OrderRef,
+		OrderStatus: rec.OrderStatus,
+		ReceivedAt:  rec.UpdatedAt,
+	})
+}
+
 func (s *Server) forwardChartEvents() {
 	if s.chartStream == nil {
 		return
@@ -3702,7 +4538,7 @@ func (s *Server) forwardQuoteEvents() {
 	ch, cancel := s.chartStream.SubscribeQuotes()
 	defer cancel()
 	for update := range ch {
-		if s.currentAppMode() == appmode.LivePaper && s.tradePaperLive != nil {
+		if s.currentAppMode() == appmode.LivePaper {
 			s.feedLivePaperTrade(update)
 		}
 		if s.lineOrders != nil {
@@ -3716,7 +4552,8 @@ func (s *Server) forwardQuoteEvents() {
 }
 
 func (s *Server) feedLivePaperTrade(update quotes.ChartQuoteUpdate) {
-	if s.tradePaperLive == nil {
+	paperLives := s.tradeServicesForMode(appmode.LivePaper)
+	if len(paperLives) == 0 {
 		return
 	}
 	symbol := strings.TrimSpace(update.Subscription.Symbol)
@@ -3738,7 +4575,7 @@ func (s *Server) feedLivePaperTrade(update quotes.ChartQuoteUpdate) {
 	if update.Snapshot.LatestPrice != nil {
 		last = *update.Snapshot.LatestPrice
 	}
-	_ = s.tradePaperLive.ConsumePaperMarketTick(trade.PaperMarketTick{
+	tick := trade.PaperMarketTick{
 		Symbol:         symbol,
 		TradingDay:     strings.TrimSpace(update.Snapshot.TradingDay),
 		ActionDay:      strings.TrimSpace(update.Snapshot.ActionDay),
@@ -3747,7 +4584,10 @@ func (s *Server) feedLivePaperTrade(update quotes.ChartQuoteUpdate) {
 		LastPrice:      last,
 		BidPrice1:      bid,
 		AskPrice1:      ask,
-	})
+	}
+	for _, svc := range paperLives {
+		_ = svc.ConsumePaperMarketTick(tick)
+	}
 }
 
 func (s *Server) broadcastStatusTicker() {
@@ -3777,6 +4617,9 @@ func (s *Server) statusPayload() map[string]any {
 	if s.replay != nil {
 		out["replay"] = s.replay.Status()
 	}
+	if s.replaySessions != nil {
+		out["replay_sessions"] = s.replaySessions.List()
+	}
 	if s.strategy != nil {
 		out["strategy"] = s.strategy.Status()
 	}
@@ -53,6 +53,27 @@ func TestBuildStrategySubmitOrderRequestClosesShortAtAsk(t *testing.T) {
 	}
 }
 
+func TestBuildStrategySubmitOrderRequestUsesApprovedLimitPrice(t *testing.T) {
+	t.Parallel()
+
+	bid := 3510.0
+	ask := 3511.0
+	req, err := buildStrategySubmitOrderRequest(strategy.StrategyOrderRequest{
+		Instance:        strategy.StrategyInstance{InstanceID: "inst-1"},
+		Symbol:          "rb2601",
+		CurrentPosition: 0,
+		TargetPosition:  2,
+		PlannedDelta:    2,
+		LimitPrice:      3505,
+	}, quotes.ChartQuoteSnapshot{BidPrice1: &bid, AskPrice1: &ask}, "SHFE", "paper_live")
+	if err != nil {
+		t.Fatalf("buildStrategySubmitOrderRequest() error = %v", err)
+	}
+	if req.Direction != "buy" || req.Volume != 2 || req.LimitPrice != 3505 {
+		t.Fatalf("request = %+v, want buy 2 at approved price", req)
+	}
+}
+
 func TestBuildStrategySubmitOrderRequestRejectsUnsafeDeltas(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,338 @@
+// trade_accounts.go 负责同一进程内多交易账户的服务编排与路由。
+// 主账户沿用 tradeLive/tradePaperLive/tradePaperReplay；trade.accounts 中的每个子账户各自持有
+// 实盘、实时模拟、回放模拟三套服务（独立网关、查询 lane、风控限额和模拟账本），REST 与 WebSocket 按 account_id 选择。
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ctp-future-kline/internal/appmode"
+	"ctp-future-kline/internal/logger"
+	"ctp-future-kline/internal/queuewatch"
+	"ctp-future-kline/internal/strategy"
+	"ctp-future-kline/internal/trade"
+
+	"github.com/gorilla/websocket"
+)
+
+// tradeAccountServices 是一个子账户在三种交易模式下各自的服务。
+type tradeAccountServices struct {
+	// accountID 是配置中的子账户标识。
+	accountID   string
+	live        *trade.Service
+	paperLive   *trade.Service
+	paperReplay *trade.Service
+}
+
+func (a *tradeAccountServices) forMode(mode string) *trade.Service {
+	if a == nil {
+		return nil
+	}
+	switch appmode.Normalize(mode) {
+	case appmode.LivePaper:
+		return a.paperLive
+	case appmode.ReplayPaper:
+		return a.paperReplay
+	default:
+		return a.live
+	}
+}
+
+// initTradeSubAccounts 为每个启用的子账户准备模拟账本；实盘服务在切到 live_real 时再连接。
+func (s *Server) initTradeSubAccounts(registry *queuewatch.Registry) {
+	s.tradeSubAccounts = make(map[string]*tradeAccountServices)
+	for _, item := range s.cfg.Trade.Accounts {
+		if !item.IsEnabled() {
+			continue
+		}
+		accountCfg := s.cfg.Trade.ForAccount(item)
+		set := &tradeAccountServices{accountID: item.AccountID}
+		paperLiveID := trade.PaperAccountID(trade.PaperLiveAccountID, item.AccountID)
+		if svc, err := trade.NewPaperServiceWithMeta(accountCfg, s.cfg.CTP, paperLiveID, s.tradePaperLiveDSN, registry); err != nil {
+			logger.Error("init paper live trade service for sub account failed", "account_id", item.AccountID, "error", err)
+		} else {
+			svc.SetStrategyPositionProvider(s.strategyPositionProvider(item.AccountID, strategy.RunTypeRealtime))
+			s.attachAlgoMarketProvider(svc)
+			set.paperLive = svc
+		}
+		paperReplayID := trade.PaperAccountID(trade.PaperReplayAccountID, item.AccountID)
+		if svc, err := trade.NewPaperService(accountCfg, paperReplayID, s.tradePaperReplayDSN, registry); err != nil {
+			logger.Error("init paper replay trade service for sub account failed", "account_id", item.AccountID, "error", err)
+		} else {
+			set.paperReplay = svc
+			if s.replay != nil {
+				s.replay.RegisterConsumer("trade."+paperReplayID, svc.ConsumeBusEvent)
+			}
+		}
+		s.tradeSubAccounts[strings.ToLower(item.AccountID)] = set
+		s.tradeSubAccountIDs = append(s.tradeSubAccountIDs, item.AccountID)
+	}
+}
+
+// startTradeSubAccountPapers 启动子账户模拟账本并转发事件，随主账户模拟服务一起在启动任务中执行。
+func (s *Server) startTradeSubAccountPapers() error {
+	var firstErr error
+	for _, id := range s.tradeSubAccountIDs {
+		set := s.tradeSubAccounts[strings.ToLower(id)]
+		for _, svc := range []*trade.Service{set.paperLive, set.paperReplay} {
+			if svc == nil {
+				continue
+			}
+			if err := svc.Start(); err != nil {
+				logger.Error("start sub account paper trade service failed", "account_id", id, "error", err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			go s.forwardTradeEvents(svc)
+		}
+	}
+	return firstErr
+}
+
+// startTradeSubAccountLive 连接子账户实盘服务；单个子账户失败不影响其他账户，返回合并后的全部错误。
+func (s *Server) startTradeSubAccountLive() error {
+	var errs []error
+	for _, id := range s.tradeSubAccountIDs {
+		item, ok := s.cfg.Trade.Account(id)
+		if !ok {
+			continue
+		}
+		s.mu.Lock()
+		set := s.tradeSubAccounts[strings.ToLower(id)]
+		running := set.live != nil
+		s.mu.Unlock()
+		if running {
+			continue
+		}
+		svc, err := trade.NewService(s.cfg.Trade.ForAccount(item), s.cfg.CTP.ForTradeAccount(item), s.tradeLiveDSN, s.status.QueueRegistry())
+		if err == nil {
+			svc.SetStrategyPositionProvider(s.strategyPositionProvider(item.AccountID, strategy.RunTypeRealtime))
+			s.attachAlgoMarketProvider(svc)
+			if err = svc.Start(); err != nil {
+				_ = svc.Close()
+			}
+		}
+		if err != nil {
+			logger.Error("start sub account trade service failed", "account_id", id, "error", err)
+			errs = append(errs, fmt.Errorf("account %s: %w", id, err))
+			continue
+		}
+		s.mu.Lock()
+		set.live = svc
+		s.mu.Unlock()
+		go s.forwardTradeEvents(svc)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) stopTradeSubAccountLive() error {
+	var firstErr error
+	for _, id := range s.tradeSubAccountIDs {
+		s.mu.Lock()
+		set := s.tradeSubAccounts[strings.ToLower(id)]
+		svc := set.live
+		set.live = nil
+		s.mu.Unlock()
+		if svc == nil {
+			continue
+		}
+		if err := svc.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// resolveTradeAccountID 把请求中的 account_id 归一成配置账户标识：空值、主账户标识和主账户模拟账本标识都指向主账户，
+// paper_live:sub1 这类模拟账本标识会还原成 sub1。第二个返回值表示账户是否存在。
+func (s *Server) resolveTradeAccountID(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.EqualFold(raw, s.cfg.Trade.AccountID) ||
+		strings.EqualFold(raw, trade.PaperLiveAccountID) || strings.EqualFold(raw, trade.PaperReplayAccountID) {
+		return "", true
+	}
+	lower := strings.ToLower(raw)
+	for _, base := range []string{trade.PaperLiveAccountID, trade.PaperReplayAccountID} {
+		if strings.HasPrefix(lower, base+":") {
+			raw = raw[len(base)+1:]
+			lower = strings.ToLower(raw)
+			break
+		}
+	}
+	if _, ok := s.tradeSubAccounts[lower]; ok {
+		return lower, true
+	}
+	if _, ok := s.replaySessionPaper(lower); ok {
+		return lower, true
+	}
+	return "", false
+}
+
+// tradeServiceForAccount 返回当前模式下某账户的交易服务；subID 为空表示主账户。
+func (s *Server) tradeServiceForAccount(subID string) *trade.Service {
+	if subID == "" {
+		return s.getTradeService()
+	}
+	if svc, ok := s.replaySessionPaper(subID); ok {
+		return svc
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tradeSubAccounts[subID].forMode(s.currentMode)
+}
+
+// tradeServicesForMode 返回某模式下所有账户的交易服务，主账户在前。
+func (s *Server) tradeServicesForMode(mode string) []*trade.Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*trade.Service
+	switch appmode.Normalize(mode) {
+	case appmode.LivePaper:
+		out = append(out, s.tradePaperLive)
+	case appmode.ReplayPaper:
+		out = append(out, s.tradePaperReplay)
+	default:
+		out = append(out, s.tradeLive)
+	}
+	for _, id := range s.tradeSubAccountIDs {
+		out = append(out, s.tradeSubAccounts[strings.ToLower(id)].forMode(mode))
+	}
+	items := out[:0]
+	for _, svc := range out {
+		if svc != nil {
+			items = append(items, svc)
+		}
+	}
+	return items
+}
+
+// requireTradeForBody 在请求体带 account_id 时复核路由：查询参数未指定则改按请求体账户路由，两者冲突时报错。
+func (s *Server) requireTradeForBody(w http.ResponseWriter, r *http.Request, svc *trade.Service, bodyAccountID string) *trade.Service {
+	if strings.TrimSpace(bodyAccountID) == "" {
+		return svc
+	}
+	subID, ok := s.resolveTradeAccountID(bodyAccountID)
+	if !ok {
+		http.Error(w, "unknown trade account: "+strings.TrimSpace(bodyAccountID), http.StatusNotFound)
+		return nil
+	}
+	target := s.tradeServiceForAccount(subID)
+	if target == svc {
+		return svc
+	}
+	if strings.TrimSpace(r.URL.Query().Get("account_id")) != "" {
+		http.Error(w, "account_id in query and body do not match", http.StatusBadRequest)
+		return nil
+	}
+	if target == nil {
+		http.Error(w, "trade service unavailable", http.StatusNotFound)
+		return nil
+	}
+	return target
+}
+
+func (s *Server) tradeStatusSnapshotForRequest(r *http.Request) (trade.TradeStatus, bool) {
+	subID, ok := s.resolveTradeAccountID(r.URL.Query().Get("account_id"))
+	if !ok {
+		return trade.TradeStatus{}, false
+	}
+	if subID == "" {
+		return s.tradeStatusSnapshot(), true
+	}
+	if svc := s.tradeServiceForAccount(subID); svc != nil {
+		return svc.Status(), true
+	}
+	return trade.TradeStatus{
+		Enabled:   appmode.Normalize(s.currentAppMode()) != appmode.LiveReal || s.cfg.Trade.IsEnabled(),
+		AccountID: s.tradeSubAccounts[subID].accountID,
+	}, true
+}
+
+func (s *Server) handleTradeAccounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type accountItem struct {
+		AccountID string            `json:"account_id"`
+		Primary   bool              `json:"primary"`
+		Status    trade.TradeStatus `json:"status"`
+	}
+	items := []accountItem{{AccountID: s.cfg.Trade.AccountID, Primary: true, Status: s.tradeStatusSnapshot()}}
+	for _, id := range s.tradeSubAccountIDs {
+		item := accountItem{AccountID: id}
+		if svc := s.tradeServiceForAccount(strings.ToLower(id)); svc != nil {
+			item.Status = svc.Status()
+		} else {
+			item.Status = trade.TradeStatus{AccountID: id}
+		}
+		items = append(items, item)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"mode": s.currentAppMode(), "items": items})
+}
+
+// handleTradeSubscribe 设置 websocket 客户端关注的交易账户；account_ids 为空时恢复接收全部账户事件。
+func (s *Server) handleTradeSubscribe(conn *websocket.Conn, raw json.RawMessage) {
+	var req struct {
+		AccountIDs []string `json:"account_ids"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &req); err != nil {
+			_ = s.writeConnJSON(conn, map[string]any{"type": "trade_subscribe_error", "data": map[string]any{"error": "invalid subscribe payload"}})
+			return
+		}
+	}
+	var filter map[string]struct{}
+	if len(req.AccountIDs) > 0 {
+		filter = make(map[string]struct{}, len(req.AccountIDs))
+		for _, id := range req.AccountIDs {
+			subID, ok := s.resolveTradeAccountID(id)
+			if !ok {
+				_ = s.writeConnJSON(conn, map[string]any{"type": "trade_subscribe_error", "data": map[string]any{"error": "unknown trade account: " + strings.TrimSpace(id)}})
+				return
+			}
+			filter[subID] = struct{}{}
+		}
+	}
+	s.mu.Lock()
+	if client := s.wsConns[conn]; client != nil {
+		client.tradeAccounts = filter
+	}
+	s.mu.Unlock()
+	_ = s.writeConnJSON(conn, map[string]any{"type": "trade_subscribed", "data": map[string]any{"account_ids": req.AccountIDs}})
+}
+
+// broadcastTradeEvent 把交易事件带上 account_id 推给订阅了该账户的 websocket 客户端。
+func (s *Server) broadcastTradeEvent(subID string, ev trade.EventEnvelope) {
+	payload := map[string]any{
+		"type":       ev.Type,
+		"account_id": ev.AccountID,
+		"data":       ev.Data,
+	}
+	s.mu.Lock()
+	conns := make([]*websocket.Conn, 0, len(s.wsConns))
+	for conn, client := range s.wsConns {
+		if client != nil && client.tradeAccounts != nil {
+			if _, ok := client.tradeAccounts[subID]; !ok {
+				continue
+			}
+		}
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	s.wsWriteMu.Lock()
+	defer s.wsWriteMu.Unlock()
+	for _, conn := range conns {
+		if err := s.writeConnJSONLocked(conn, payload); err != nil {
+			s.removeWSConn(conn)
+			_ = conn.Close()
+		}
+	}
+}
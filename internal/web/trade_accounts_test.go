@@ -0,0 +1,94 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ctp-future-kline/internal/config"
+	"ctp-future-kline/internal/quotes"
+	"ctp-future-kline/internal/trade"
+
+	"github.com/gorilla/websocket"
+)
+
+func newMultiAccountTestServer() *Server {
+	return &Server{
+		cfg:     config.AppConfig{Trade: config.TradeConfig{AccountID: "main"}},
+		status:  quotes.NewRuntimeStatusCenter(time.Minute),
+		wsConns: make(map[*websocket.Conn]*wsClient),
+		tradeSubAccounts: map[string]*tradeAccountServices{
+			"sub1": {accountID: "Sub1"},
+		},
+		tradeSubAccountIDs: []string{"Sub1"},
+	}
+}
+
+func TestResolveTradeAccountID(t *testing.T) {
+	s := newMultiAccountTestServer()
+	cases := []struct {
+		raw    string
+		want   string
+		wantOK bool
+	}{
+		{"", "", true},
+		{"MAIN", "", true},
+		{"paper_live", "", true},
+		{"sub1", "sub1", true},
+		{"paper_replay:Sub1", "sub1", true},
+		{"sub2", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := s.resolveTradeAccountID(tc.raw)
+		if got != tc.want || ok != tc.wantOK {
+			t.Fatalf("resolveTradeAccountID(%q) = (%q, %v), want (%q, %v)", tc.raw, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestBroadcastTradeEventRespectsAccountSubscription(t *testing.T) {
+	s := newMultiAccountTestServer()
+	ts := httptest.NewServer(http.HandlerFunc(s.handleWS))
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+	subConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial sub conn failed: %v", err)
+	}
+	defer subConn.Close()
+	_, _, _ = subConn.ReadMessage()
+	allConn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("dial all conn failed: %v", err)
+	}
+	defer allConn.Close()
+	_, _, _ = allConn.ReadMessage()
+
+	if err := subConn.WriteJSON(map[string]any{"type": "trade_subscribe", "data": map[string]any{"account_ids": []string{"sub1"}}}); err != nil {
+		t.Fatalf("write trade_subscribe failed: %v", err)
+	}
+	_ = subConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack map[string]any
+	if err := subConn.ReadJSON(&ack); err != nil || ack["type"] != "trade_subscribed" {
+		t.Fatalf("trade_subscribe ack = %#v err=%v", ack, err)
+	}
+
+	s.broadcastTradeEvent("", trade.EventEnvelope{Type: "trade_order_update", AccountID: "main", Data: map[string]any{}})
+	s.broadcastTradeEvent("sub1", trade.EventEnvelope{Type: "trade_order_update", AccountID: "Sub1", Data: map[string]any{}})
+
+	var got map[string]any
+	_ = subConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := subConn.ReadJSON(&got); err != nil || got["account_id"] != "Sub1" {
+		t.Fatalf("sub conn first event = %#v err=%v, want Sub1 only", got, err)
+	}
+	for _, want := range []string{"main", "Sub1"} {
+		got = nil
+		_ = allConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := allConn.ReadJSON(&got); err != nil || got["account_id"] != want {
+			t.Fatalf("all conn event = %#v err=%v, want %s", got, err, want)
+		}
+	}
+}
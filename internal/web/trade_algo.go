@@ -0,0 +1,163 @@
+// trade_algo.go 负责算法母单的 HTTP 接口，以及给交易服务注入算法执行需要的盘口和历史 1m 成交量分布。
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/strategy"
+	"ctp-future-kline/internal/trade"
+)
+
+// algoVolumeProfileBars 是构建 VWAP 成交量分布时回看的 1m K 线根数，约覆盖最近一周的交易时段。
+const algoVolumeProfileBars = 3000
+
+// attachAlgoMarketProvider 为交易服务注入算法执行和交易日志 MAE/MFE 使用的行情来源。
+func (s *Server) attachAlgoMarketProvider(svc *trade.Service) {
+	if svc == nil {
+		return
+	}
+	svc.SetAlgoMarketProvider(s.algoQuote, s.algoVolumeProfile)
+	svc.SetLotBarProvider(s.lotExcursionBars)
+}
+
+func (s *Server) algoQuote(symbol string) (trade.AlgoQuote, bool) {
+	snapshot := s.chartQuoteSnapshotForSymbol(symbol)
+	var quote trade.AlgoQuote
+	if snapshot.LatestPrice != nil {
+		quote.LastPrice = *snapshot.LatestPrice
+	}
+	if snapshot.BidPrice1 != nil {
+		quote.BidPrice1 = *snapshot.BidPrice1
+	}
+	if snapshot.AskPrice1 != nil {
+		quote.AskPrice1 = *snapshot.AskPrice1
+	}
+	if quote.LastPrice <= 0 && quote.BidPrice1 <= 0 && quote.AskPrice1 <= 0 {
+		return trade.AlgoQuote{}, false
+	}
+	return quote, true
+}
+
+func (s *Server) algoVolumeProfile(symbol string) (trade.VolumeProfile, error) {
+	if s.queryRealtime == nil {
+		return nil, errors.New("realtime kline query unavailable")
+	}
+	resp, err := s.queryRealtime.BarsByEnd(strings.ToLower(strings.TrimSpace(symbol)), "contract", "", "1m", time.Now(), algoVolumeProfileBars)
+	if err != nil {
+		return nil, err
+	}
+	bars := make([]trade.VolumeBar, 0, len(resp.Bars))
+	for _, bar := range resp.Bars {
+		bars = append(bars, trade.VolumeBar{Time: time.Unix(bar.DataTime, 0), Volume: float64(bar.Volume)})
+	}
+	return trade.BuildVolumeProfile(bars), nil
+}
+
+// submitStrategyParentOrder 把策略调仓转为算法母单；母单不设限价，由子单按盘口追价。
+func submitStrategyParentOrder(ctx context.Context, svc *trade.Service, submit trade.SubmitOrderRequest, style strategy.ExecutionStyle) (strategy.StrategyOrderResult, error) {
+	submit.LimitPrice = 0
+	parent, err := svc.SubmitParentOrder(ctx, trade.ParentOrderRequest{Order: submit, Execution: style})
+	if err != nil {
+		return strategy.StrategyOrderResult{Status: strategy.OrderStatusBlocked, Reason: err.Error()}, err
+	}
+	return strategy.StrategyOrderResult{
+		OrderID: parent.ParentID,
+		Status:  parent.Status,
+		Details: map[string]any{
+			"direction":    parent.Direction,
+			"offset_flag":  parent.OffsetFlag,
+			"volume":       parent.TotalVolume,
+			"account_id":   parent.AccountID,
+			"exchange_id":  parent.ExchangeID,
+			"execution":    parent.Execution,
+			"parent_order": true,
+		},
+	}, nil
+}
+
+func (s *Server) handleTradeParentOrders(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := svc.ParentOrders(parseLimitArg(r.URL.Query().Get("limit"), 100, 500))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"items": items})
+	case http.MethodPost:
+		var req trade.ParentOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid json body", http.StatusBadRequest)
+			return
+		}
+		if svc = s.requireTradeForBody(w, r, svc, req.Order.AccountID); svc == nil {
+			return
+		}
+		req.Order.AccountID = svc.AccountID()
+		if strings.TrimSpace(req.Order.ExchangeID) == "" {
+			req.Order.ExchangeID = s.inferExchangeIDForSymbol(req.Order.Symbol, nil, nil)
+		}
+		if strings.TrimSpace(req.Order.Reason) == "" {
+			req.Order.Reason = "manual"
+		}
+		item, err := svc.SubmitParentOrder(r.Context(), req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) handleTradeParentOrderAction(w http.ResponseWriter, r *http.Request) {
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/trade/parent-orders/"), "/")
+	parentID := strings.TrimSpace(parts[0])
+	if parentID == "" {
+		http.Error(w, "invalid path", http.StatusBadRequest)
+		return
+	}
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		item, err := svc.ParentOrder(parentID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "parent order not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "cancel" && r.Method == http.MethodPost {
+		item, err := svc.CancelParentOrder(parentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, trade.ErrParentOrderNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			default:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+		return
+	}
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,119 @@
+// trade_journal.go 负责开仓批次与交易日志的 HTTP 接口，以及为 MAE/MFE 计算提供持仓期间的 1m K 线。
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ctp-future-kline/internal/trade"
+)
+
+// lotExcursionMaxBars 是单个合约计算 MAE/MFE 时最多加载的 1m K 线根数。
+const lotExcursionMaxBars = 5000
+
+func (s *Server) lotExcursionBars(symbol string, from time.Time, to time.Time) ([]trade.ExcursionBar, error) {
+	if s.queryRealtime == nil {
+		return nil, errors.New("realtime kline query unavailable")
+	}
+	resp, err := s.queryRealtime.BarsByEnd(strings.ToLower(strings.TrimSpace(symbol)), "contract", "", "1m", to, lotExcursionMaxBars)
+	if err != nil {
+		return nil, err
+	}
+	start := from.Truncate(time.Minute)
+	bars := make([]trade.ExcursionBar, 0, len(resp.Bars))
+	for _, bar := range resp.Bars {
+		ts := time.Unix(bar.DataTime, 0)
+		if ts.Before(start) {
+			continue
+		}
+		bars = append(bars, trade.ExcursionBar{Time: ts, High: bar.High, Low: bar.Low})
+	}
+	return bars, nil
+}
+
+// parseJournalDate 解析日志筛选时间，支持日期或分钟精度；isEnd 时纯日期取当天结束。
+func parseJournalDate(raw string, isEnd bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.ParseInLocation("2006-01-02", raw, time.Local); err == nil {
+		if isEnd {
+			return day.AddDate(0, 0, 1).Add(-time.Second), nil
+		}
+		return day, nil
+	}
+	return parseMinuteTime(raw)
+}
+
+func (s *Server) handleTradeJournal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	query := r.URL.Query()
+	from, err := parseJournalDate(query.Get("from"), false)
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseJournalDate(query.Get("to"), true)
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		http.Error(w, "to before from", http.StatusBadRequest)
+		return
+	}
+	entries, err := svc.Journal(trade.JournalQuery{
+		InstanceID: strings.TrimSpace(query.Get("instance_id")),
+		Symbol:     strings.TrimSpace(query.Get("symbol")),
+		From:       from,
+		To:         to,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	switch strings.ToLower(strings.TrimSpace(query.Get("format"))) {
+	case "", "json":
+		writeJSON(w, http.StatusOK, map[string]any{"account_id": svc.AccountID(), "items": entries})
+	case "csv":
+		body, err := trade.JournalCSV(entries)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "trade_journal_"+svc.AccountID()+".csv"))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	default:
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+	}
+}
+
+func (s *Server) handleTradeLots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	svc := s.requireTrade(w, r)
+	if svc == nil {
+		return
+	}
+	items, err := svc.OpenLots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"account_id": svc.AccountID(), "items": items})
+}
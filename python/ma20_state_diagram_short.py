@@ -11,7 +11,7 @@ from __future__ import annotations
 
 import json
 import logging
-from dataclasses import dataclass, field
+from dataclasses import asdict, dataclass, field
 from threading import RLock
 from typing import Any
 
@@ -195,6 +195,27 @@ class MA20StateDiagramShortStrategy(Strategy):  # MA20状态图做空策略实
                 if key[1] == instance_id:
                     del self.states[key]
 
+    def snapshot_state(self, instance_id: str, mode: str) -> Any:
+        # 导出实例各合约的状态机和指标缓存，供 Go 侧保存检查点。
+        with self._lock:
+            return {
+                key[2]: asdict(state)
+                for key, state in self.states.items()
+                if key[0] == mode and key[1] == instance_id
+            }
+
+    def restore_state(self, instance: JSONObject, state: Any) -> None:
+        # 用检查点恢复各合约状态，跳过warmup推导；缺少任一订阅合约时拒绝恢复。
+        instance_id = str(instance.get("instance_id") or "")
+        symbols = [str(symbol) for symbol in (instance.get("symbols") or [""])]
+        mode = _instance_mode(instance)
+        if not isinstance(state, dict) or any(not isinstance(state.get(symbol), dict) for symbol in symbols):
+            raise ValueError(f"checkpoint state does not cover symbols: instance_id={instance_id} symbols={','.join(symbols)}")
+        restored = {symbol: StateDiagramShortState(**state[symbol]) for symbol in symbols}
+        with self._lock:
+            for symbol, item in restored.items():
+                self.states[(mode, instance_id, symbol)] = item
+
     def validate_warmup(self, instance: JSONObject, applied_counts: dict[str, int] | None = None) -> None:
         # 校验预热数据是否足以计算MA，避免运行期第一批K线因样本不足误判。
         target = self.required_warmup_bars(instance)
@@ -527,6 +527,14 @@ class Strategy:
         """停止策略实例时调用。默认无状态策略不需要清理。"""
         return None
 
+    def snapshot_state(self, instance_id: str, mode: str) -> Any:
+        """导出实例状态检查点，返回可 JSON 序列化的对象；返回 None 表示不支持检查点。"""
+        return None
+
+    def restore_state(self, instance: JSONObject, state: Any) -> None:
+        """用检查点状态代替 warmup 启动实例。默认不支持，Go 侧会回落到完整 warmup 启动。"""
+        raise ValueError(f"strategy does not support checkpoint restore: {self.definition.get('strategy_id', '')}")
+
     def required_warmup_bars(self, instance: JSONObject) -> int:
         """返回启动该策略实例前至少需要预热的 K 线数量。"""
         return 0
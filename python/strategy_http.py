@@ -4,11 +4,20 @@
 The strategy algorithms still live behind ``StrategyService``.  This module only
 replaces the transport layer: Go sends JSON POST requests, market events are
 queued with a push id, and Go polls the push id until a decision is available.
+
+``/runtime/stream`` is a WebSocket alternative for market events.  Go sends
+``event`` frames carrying a sequence number, the service answers with ``ack``
+as soon as the event is queued and pushes a ``result`` frame when the strategy
+finishes.  Go replays unresolved events after a reconnect; events are
+deduplicated by ``(session_id, seq)`` so a replay never runs a strategy twice.
+``ping``/``pong`` frames act as heartbeats.  The HTTP endpoints stay available
+as the fallback transport.
 """
 
 from __future__ import annotations
 
 import argparse
+import asyncio
 import logging
 import os
 import queue
@@ -16,7 +25,7 @@ import threading
 import time
 import uuid
 from dataclasses import dataclass, field
-from typing import Any
+from typing import Any, Callable
 from urllib.parse import urlsplit
 
 import falcon.asgi
@@ -30,6 +39,7 @@ logger = logging.getLogger(__name__)
 
 TASK_TTL_SECONDS = 10 * 60
 WORKER_IDLE_SECONDS = 60
+STREAM_METHODS = {"OnTick", "OnBar", "OnReplayBar"}
 
 
 class PushIDNotFound(KeyError):
@@ -48,6 +58,7 @@ class AsyncTask:
     created_at: float = field(default_factory=time.time)
     updated_at: float = field(default_factory=time.time)
     done: threading.Event = field(default_factory=threading.Event)
+    listeners: list[Callable[["AsyncTask"], None]] = field(default_factory=list)
 
 
 class AsyncStrategyRunner:
@@ -60,6 +71,7 @@ class AsyncStrategyRunner:
         self._tasks: dict[str, AsyncTask] = {}
         self._queues: dict[RuntimeKey, queue.Queue[AsyncTask]] = {}
         self._workers: dict[RuntimeKey, threading.Thread] = {}
+        self._stream_seqs: dict[tuple[str, int], str] = {}
 
     def enqueue(self, method_name: str, request: RequestDict) -> str:
         instance_id = _instance_id(request)
@@ -84,6 +96,34 @@ class AsyncStrategyRunner:
         # logger.info("strategy event queued: push_id=%s key=%s method=%s", push_id, key, method_name)
         return push_id
 
+    def enqueue_stream(
+        self,
+        session_id: str,
+        seq: int,
+        method_name: str,
+        request: RequestDict,
+        on_done: Callable[[AsyncTask], None],
+    ) -> str:
+        """Queue a stream event once per ``(session_id, seq)`` and call ``on_done`` when it finishes.
+
+        A replayed event that is still running only gets another listener; one that
+        already finished calls ``on_done`` immediately with the cached result.
+        """
+        with self._lock:
+            key = (session_id, int(seq))
+            push_id = self._stream_seqs.get(key, "")
+            task = self._tasks.get(push_id) if push_id else None
+            if task is None:
+                push_id = self.enqueue(method_name, request)
+                task = self._tasks[push_id]
+                self._stream_seqs[key] = push_id
+            finished = task.done.is_set()
+            if not finished:
+                task.listeners.append(on_done)
+        if finished:
+            on_done(task)
+        return push_id
+
     def result(self, push_id: str, wait_ms: int = 0) -> dict[str, Any]:
         push_id = str(push_id or "").strip()
         if not push_id:
@@ -143,7 +183,15 @@ class AsyncStrategyRunner:
                 task.error = str(exc)
                 task.updated_at = time.time()
         finally:
-            task.done.set()
+            with self._lock:
+                listeners = list(task.listeners)
+                task.listeners.clear()
+                task.done.set()
+            for listener in listeners:
+                try:
+                    listener(task)
+                except Exception:
+                    logger.exception("strategy stream listener failed: push_id=%s", task.push_id)
 
     def _cleanup_locked(self, now: float) -> None:
         expired = [
@@ -152,6 +200,8 @@ class AsyncStrategyRunner:
         ]
         for push_id in expired:
             del self._tasks[push_id]
+        if expired:
+            self._stream_seqs = {key: push_id for key, push_id in self._stream_seqs.items() if push_id in self._tasks}
 
 
 class StrategyHTTPResource:
@@ -177,6 +227,9 @@ class StrategyHTTPResource:
     async def on_post_stop(self, req: falcon.asgi.Request, resp: falcon.asgi.Response) -> None:
         await self._direct(req, resp, self.service.StopInstance)
 
+    async def on_post_snapshot(self, req: falcon.asgi.Request, resp: falcon.asgi.Response) -> None:
+        await self._direct(req, resp, self.service.SnapshotInstance)
+
     async def on_post_on_tick(self, req: falcon.asgi.Request, resp: falcon.asgi.Response) -> None:
         await self._enqueue(req, resp, "OnTick")
 
@@ -197,6 +250,63 @@ class StrategyHTTPResource:
             resp.status = falcon.HTTP_400
             resp.media = {"status": "error", "error": str(exc)}
 
+    async def on_websocket_stream(self, req: falcon.asgi.Request, ws: falcon.asgi.WebSocket) -> None:
+        try:
+            await ws.accept()
+        except falcon.WebSocketDisconnected:
+            return
+        loop = asyncio.get_running_loop()
+        outbox: asyncio.Queue[dict[str, Any]] = asyncio.Queue()
+        sender = asyncio.create_task(self._stream_sender(ws, outbox))
+        session_id = ""
+        try:
+            while True:
+                frame = await ws.receive_media()
+                if not isinstance(frame, dict):
+                    continue
+                frame_type = str(frame.get("type") or "")
+                if frame_type == "hello":
+                    session_id = str(frame.get("session_id") or "") or uuid.uuid4().hex
+                    outbox.put_nowait({"type": "welcome", "session_id": session_id})
+                elif frame_type == "ping":
+                    outbox.put_nowait({"type": "pong", "ts": frame.get("ts")})
+                elif frame_type == "event":
+                    self._stream_event(loop, outbox, session_id, frame)
+        except falcon.WebSocketDisconnected:
+            pass
+        finally:
+            sender.cancel()
+
+    def _stream_event(self, loop: asyncio.AbstractEventLoop, outbox: asyncio.Queue[dict[str, Any]], session_id: str, frame: dict[str, Any]) -> None:
+        seq = int(frame.get("seq") or 0)
+        method_name = str(frame.get("method") or "")
+        request = frame.get("request")
+        if method_name not in STREAM_METHODS or not isinstance(request, dict):
+            outbox.put_nowait({"type": "result", "seq": seq, "status": "error", "error": f"invalid stream event method={method_name!r}"})
+            return
+
+        def on_done(task: AsyncTask) -> None:
+            msg: dict[str, Any] = {"type": "result", "seq": seq, "status": task.status}
+            if task.status == "done":
+                msg["result"] = task.result or {}
+            else:
+                msg["error"] = task.error
+            loop.call_soon_threadsafe(outbox.put_nowait, msg)
+
+        try:
+            self.runner.enqueue_stream(session_id, seq, method_name, request, on_done)
+        except ValueError as exc:
+            outbox.put_nowait({"type": "result", "seq": seq, "status": "error", "error": str(exc)})
+            return
+        outbox.put_nowait({"type": "ack", "seq": seq})
+
+    async def _stream_sender(self, ws: falcon.asgi.WebSocket, outbox: asyncio.Queue[dict[str, Any]]) -> None:
+        try:
+            while True:
+                await ws.send_media(await outbox.get())
+        except falcon.WebSocketDisconnected:
+            pass
+
     async def on_post_backtest_run(self, req: falcon.asgi.Request, resp: falcon.asgi.Response) -> None:
         await self._direct(req, resp, self.service.RunBacktest)
 
@@ -247,10 +357,12 @@ def build_app(service: StrategyService | None = None, runner: AsyncStrategyRunne
     app.add_route("/runtime/start-requirements", resource, suffix="start_requirements")
     app.add_route("/runtime/start", resource, suffix="start")
     app.add_route("/runtime/stop", resource, suffix="stop")
+    app.add_route("/runtime/snapshot", resource, suffix="snapshot")
     app.add_route("/runtime/on_tick", resource, suffix="on_tick")
     app.add_route("/runtime/on_bar", resource, suffix="on_bar")
     app.add_route("/runtime/on_replay_bar", resource, suffix="on_replay_bar")
     app.add_route("/runtime/result", resource, suffix="result")
+    app.add_route("/runtime/stream", resource, suffix="stream")
     app.add_route("/backtest/run", resource, suffix="backtest_run")
     app.add_route("/backtest/result", resource, suffix="backtest_result")
     app.add_route("/optimizer/run-sweep", resource, suffix="optimizer_run_sweep")
@@ -15,6 +15,11 @@ HTTP/CLI 等入口都只应该负责协议和错误边界，策略实例的复
 from __future__ import annotations
 
 import copy
+import hashlib
+import importlib
+import os
+import sys
+import sysconfig
 from dataclasses import dataclass
 from threading import RLock
 from typing import Any
@@ -68,6 +73,35 @@ def _clone_definition(definition: StrategyDefinition | None) -> StrategyDefiniti
     return copy.deepcopy(definition or {})
 
 
+def _library_roots() -> tuple[str, ...]:
+    """标准库与第三方包目录；这些模块不随策略发布，不计入策略代码摘要。"""
+    paths = sysconfig.get_paths()
+    return tuple(os.path.abspath(paths[key]) for key in ("stdlib", "platstdlib", "purelib", "platlib") if paths.get(key))
+
+
+def _strategy_code_hash(strategy: Strategy) -> str:
+    """对策略类及其基类所在的本地源文件做 sha256，作为 definition.code_hash。
+
+    只看 version 不够：开发时经常改了代码却没有改版本号。
+    摘要覆盖整条继承链，是因为共享基类（如 strategy_common）的改动同样会改变策略行为。
+    """
+    roots = _library_roots()
+    files: set[str] = set()
+    for cls in type(strategy).__mro__:
+        module_file = getattr(sys.modules.get(cls.__module__), "__file__", None)
+        if not module_file:
+            continue
+        path = os.path.abspath(module_file)
+        if not any(path.startswith(root + os.sep) for root in roots):
+            files.add(path)
+    digest = hashlib.sha256()
+    for path in sorted(files):
+        digest.update(os.path.basename(path).encode("utf-8"))
+        with open(path, "rb") as fh:
+            digest.update(fh.read())
+    return digest.hexdigest()
+
+
 def _clone_strategy_template(strategy: Strategy) -> Strategy:
     """基于注册模板创建一个新的运行策略对象。
 
@@ -117,7 +151,7 @@ class StrategyRegistryEntry:
 class StrategyRuntimeInstance:
     """一次 StartInstance 对应的运行实例。"""
 
-    def __init__(self, entry: StrategyRegistryEntry, instance: JSONObject) -> None:
+    def __init__(self, entry: StrategyRegistryEntry, instance: JSONObject, checkpoint_state: Any = None) -> None:
         self.entry: StrategyRegistryEntry = entry
         self.instance: JSONObject = copy.deepcopy(instance or {})
         self.instance_id: str = str(self.instance.get("instance_id") or "")
@@ -125,7 +159,15 @@ class StrategyRuntimeInstance:
         self.symbols: list[str] = _normalize_symbols(self.instance.get("symbols") or [])
         self.strategy: Strategy = entry.create_runtime()
         # start_instance 放在构造末尾：只有 entry/instance/mode/symbols 都准备好后才允许策略初始化状态。
-        self.strategy.start_instance(self.instance)
+        # 带检查点时由 restore_state 直接恢复状态机，Go 侧随后只补放检查点之后的 K 线。
+        if checkpoint_state is not None:
+            self.strategy.restore_state(self.instance, checkpoint_state)
+        else:
+            self.strategy.start_instance(self.instance)
+
+    def snapshot(self) -> Any:
+        """导出本实例的状态检查点；策略不支持时返回 None。"""
+        return self.strategy.snapshot_state(self.instance_id, self.mode)
 
     def _assert_symbol_allowed(self, request: RequestDict) -> None:
         """防止未订阅品种误打到某个已启动实例。
@@ -178,9 +220,11 @@ class StrategyFactory:
         strategy_id = str(strategy.definition.get("strategy_id") or "")
         if not strategy_id:
             raise ValueError("strategy definition missing strategy_id")
+        definition = _clone_definition(strategy.definition)
+        definition["code_hash"] = _strategy_code_hash(strategy)
         self._strategies[strategy_id] = StrategyRegistryEntry(
             strategy_id=strategy_id,
-            definition=_clone_definition(strategy.definition),
+            definition=definition,
             template=strategy,
         )
 
@@ -203,6 +247,23 @@ class StrategyFactory:
             raise ValueError(f"unknown strategy_id: {strategy_id}")
         return self._strategies[strategy_id]
 
+    def reload_strategy(self, strategy_id: Any) -> StrategyRegistryEntry:
+        """重新导入策略类所在模块并替换注册项，供 Go 侧实例热重载使用。
+
+        已启动的 runtime 仍持有旧模块里的类对象，行为不受影响；Go 侧随后停止旧实例，用导出的状态启动新实例。
+        导入失败时注册项保持不变，异常交给上层入口映射成请求错误，旧实例继续运行。
+        """
+        entry = self.load_strategy(strategy_id)
+        template_cls = type(entry.template)
+        module = importlib.reload(sys.modules[template_cls.__module__])
+        strategy = getattr(module, template_cls.__name__)()
+        reloaded_id = str(strategy.definition.get("strategy_id") or "")
+        if reloaded_id != entry.strategy_id:
+            raise ValueError(f"reloaded strategy_id changed: {entry.strategy_id} -> {reloaded_id}")
+        with self._lock:
+            self.register(strategy)
+        return self._strategies[entry.strategy_id]
+
     def start_requirements(self, instance: JSONObject) -> dict[str, Any]:
         """返回启动前 warmup 需求。
 
@@ -230,14 +291,15 @@ class StrategyFactory:
             raise ValueError("missing required field(s): instance.instance_id")
         return (_mode_key(request), instance_id)
 
-    def start_instance(self, instance: JSONObject) -> StrategyRuntimeInstance:
+    def start_instance(self, instance: JSONObject, checkpoint_state: Any = None) -> StrategyRuntimeInstance:
         """启动一个运行实例，并替换同 key 下的旧实例。
 
         替换语义是有意的：用户修改参数重新启动同一个 instance_id 时，新实例应立即接管后续行情。
+        checkpoint_state 非空时用检查点恢复，不再要求 warmup_bars。
         """
         entry = self.load_strategy((instance or {}).get("strategy_id", ""))
         key = self._key_for_instance(instance)
-        runtime = StrategyRuntimeInstance(entry, instance)
+        runtime = StrategyRuntimeInstance(entry, instance, checkpoint_state)
         with self._lock:
             self._instances[key] = runtime
         return runtime
@@ -255,6 +317,15 @@ class StrategyFactory:
                 removed += 1
         return removed
 
+    def snapshot_instance(self, instance_id: Any, mode: Any) -> Any:
+        """导出指定实例的状态检查点；实例未启动时报错，策略不支持时返回 None。"""
+        key = self._key_for_instance({"instance_id": instance_id, "mode": mode})
+        with self._lock:
+            runtime = self._instances.get(key)
+        if runtime is None:
+            raise ValueError(f"strategy runtime instance not started: instance_id={key[1]} mode={key[0]}")
+        return runtime.snapshot()
+
     def runtime_for_request(self, request: RequestDict) -> StrategyRuntimeInstance:
         """根据行情请求找到已启动 runtime；找不到则说明调用顺序错误。"""
         key = self._key_for_request(request)
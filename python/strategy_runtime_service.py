@@ -3,6 +3,8 @@
 
 from __future__ import annotations
 
+import base64
+import json
 import logging
 import time
 from typing import Any
@@ -34,8 +36,12 @@ class StrategyService:
 
     def LoadStrategy(self, request: RequestDict, context: Any) -> ResponseDict:
         strategy_id = request.get("strategy_id", "")
-        self.factory.load_strategy(strategy_id)
-        logger.info("strategy loaded: %s", strategy_id)
+        if request.get("reload"):
+            definition = self.factory.reload_strategy(strategy_id).definition
+            logger.info("strategy reloaded: %s version=%s code_hash=%s", strategy_id, definition.get("version", ""), definition.get("code_hash", ""))
+        else:
+            self.factory.load_strategy(strategy_id)
+            logger.info("strategy loaded: %s", strategy_id)
         return {"ok": True, "version": "python-sample-v1", "server_time": time.strftime("%Y-%m-%d %H:%M:%S")}
 
     def GetStartRequirements(self, request: RequestDict, context: Any) -> ResponseDict:
@@ -57,10 +63,18 @@ class StrategyService:
         instance_id = instance.get("instance_id", "")
         logger.info("strategy StartInstance begin..., instance_id=%s strategy_id=%s mode=%s timeframe=%s", 
                     instance_id, instance.get("strategy_id", ""), instance.get("mode", ""), instance.get("timeframe", ""))
-        self.factory.start_instance(instance)
-        logger.info("strategy StartInstance end runtime_count=%s", len(self.factory.instances))
+        checkpoint = request.get("checkpoint") or {}
+        checkpoint_state = _decode_checkpoint_state(checkpoint.get("state")) if checkpoint else None
+        self.factory.start_instance(instance, checkpoint_state)
+        logger.info("strategy StartInstance end runtime_count=%s restored_from_checkpoint=%s", len(self.factory.instances), checkpoint_state is not None)
         return {"ok": True, "version": "python-sample-v1", "server_time": time.strftime("%Y-%m-%d %H:%M:%S")}
 
+    def SnapshotInstance(self, request: RequestDict, context: Any) -> ResponseDict:
+        state = self.factory.snapshot_instance(request.get("instance_id", ""), request.get("mode", ""))
+        if state is None:
+            return {"supported": False}
+        return {"supported": True, "state": _encode_checkpoint_state(state)}
+
     def StopInstance(self, request: RequestDict, context: Any) -> ResponseDict:
         instance_id = request.get("instance_id", "")
         removed = self.factory.stop_instance(instance_id)
@@ -110,3 +124,15 @@ class StrategyService:
         out = fn(request)
         _log_strategy_phase(request, out)
         return out
+
+
+def _encode_checkpoint_state(state: Any) -> str:
+    """检查点在 Go 侧是不透明字节，JSON 编码后按 []byte 约定转成 base64。"""
+    return base64.b64encode(json.dumps(state, ensure_ascii=False, sort_keys=True).encode("utf-8")).decode("ascii")
+
+
+def _decode_checkpoint_state(raw: Any) -> Any:
+    """还原 _encode_checkpoint_state 的结果；空状态视为无效检查点。"""
+    if not raw:
+        raise ValueError("checkpoint state is empty")
+    return json.loads(base64.b64decode(str(raw)).decode("utf-8"))
@@ -1,5 +1,8 @@
+import asyncio
+import importlib
 import pathlib
 import sys
+import tempfile
 import unittest
 
 sys.path.insert(0, str(pathlib.Path(__file__).resolve().parent))
@@ -35,6 +38,8 @@ from strategy_service import (  # noqa: E402
     WeakPullbackShortState,
 )
 
+from strategy_runtime_service import _decode_checkpoint_state, _encode_checkpoint_state  # noqa: E402
+
 try:
     from strategy_http import AsyncStrategyRunner, build_app  # noqa: E402
     import falcon.testing  # noqa: E402
@@ -936,6 +941,46 @@ class StrategyServiceRegistryTest(unittest.TestCase):
         with self.assertRaises(ValueError):
             service.StartInstance({"instance": instance}, None)
 
+    def test_checkpoint_restore_replaces_warmup_and_snapshot_round_trips(self):
+        source = MA20StateDiagramShortStrategy()
+        source.states[("live", "ckpt-1", "rb2601")] = StateDiagramShortState()
+        for i in range(1, 21):
+            source.on_bar(state_bar_req(instance_id="ckpt-1", idx=i))
+        source.on_bar(state_bar_req(instance_id="ckpt-1", idx=21, open_=101, high=101.2, low=100.9, close=101))
+        state = source.snapshot_state("ckpt-1", "live")
+        self.assertEqual(state["rb2601"]["state"], ABOVE_MA20)
+
+        service = StrategyService()
+        instance = {
+            "instance_id": "ckpt-1",
+            "strategy_id": MA20_STATE_DIAGRAM_STRATEGY_ID,
+            "mode": "realtime",
+            "symbols": ["rb2601"],
+            "timeframe": "1m",
+            "params": {},
+        }
+        with self.assertRaises(ValueError):
+            service.StartInstance({"instance": instance}, None)
+        service.StartInstance({"instance": instance, "checkpoint": {"state": _encode_checkpoint_state(state)}}, None)
+
+        snapshot = service.SnapshotInstance({"instance_id": "ckpt-1", "mode": "realtime"}, None)
+        self.assertTrue(snapshot["supported"])
+        self.assertEqual(_decode_checkpoint_state(snapshot["state"]), state)
+        next_bar = state_bar_req(instance_id="ckpt-1", idx=22, open_=100.6, high=100.7, low=99.0, close=99.2)
+        restored = service.OnBar(next_bar, None)
+        self.assertEqual(restored["trace"]["step_key"], source.on_bar(next_bar)["trace"]["step_key"])
+
+    def test_checkpoint_unsupported_strategy_reports_and_rejects_restore(self):
+        service = StrategyService()
+        instance = {"instance_id": "ckpt-2", "strategy_id": "indicator.zigzag_atr26", "mode": "live", "symbols": ["rb2601"], "params": {}}
+        service.StartInstance({"instance": instance}, None)
+
+        self.assertEqual(service.SnapshotInstance({"instance_id": "ckpt-2", "mode": "live"}, None), {"supported": False})
+        with self.assertRaises(ValueError):
+            service.StartInstance({"instance": instance, "checkpoint": {"state": _encode_checkpoint_state({"rb2601": {}})}}, None)
+        with self.assertRaises(ValueError):
+            service.SnapshotInstance({"instance_id": "missing", "mode": "live"}, None)
+
     def test_weak_pullback_variants_expose_separate_entry_scripts(self):
         service = StrategyService()
 
@@ -950,6 +995,49 @@ class StrategyServiceRegistryTest(unittest.TestCase):
         self.assertEqual(definitions[MA20_WEAK_SCORE_FILTER_STRATEGY_ID], "python/ma20_weak_pullback_score_filter.py")
 
 
+    def test_reload_strategy_swaps_template_and_keeps_running_instances(self):
+        source = """from strategy_common import Strategy
+
+
+class ReloadProbeStrategy(Strategy):
+    definition = {{
+        "strategy_id": "test.reload_probe",
+        "display_name": "Reload Probe",
+        "version": "{version}",
+        "default_params": {params},
+    }}
+"""
+        with tempfile.TemporaryDirectory() as tmp:
+            path = pathlib.Path(tmp) / "reload_probe_strategy.py"
+            path.write_text(source.format(version="1.0.0", params={"window": 5}), encoding="utf-8")
+            sys.path.insert(0, tmp)
+            self.addCleanup(sys.path.remove, tmp)
+            self.addCleanup(sys.modules.pop, "reload_probe_strategy", None)
+            module = importlib.import_module("reload_probe_strategy")
+
+            service = StrategyService()
+            service.factory.register(module.ReloadProbeStrategy())
+            before = service.factory.load_strategy("test.reload_probe").definition
+            self.assertEqual(len(before["code_hash"]), 64)
+            service.StartInstance({"instance": {"instance_id": "probe-1", "strategy_id": "test.reload_probe", "mode": "live", "symbols": ["rb2601"], "params": {}}}, None)
+            running = service.factory.instances[("live", "probe-1")].strategy
+
+            path.write_text(source.format(version="1.1.0", params={"window": 8, "band": 2.5}), encoding="utf-8")
+            service.LoadStrategy({"strategy_id": "test.reload_probe", "reload": True}, None)
+
+            after = {item["strategy_id"]: item for item in service.ListStrategies({}, None)["strategies"]}["test.reload_probe"]
+            self.assertEqual(after["version"], "1.1.0")
+            self.assertEqual(after["default_params"], {"window": 8, "band": 2.5})
+            self.assertNotEqual(after["code_hash"], before["code_hash"])
+            self.assertIs(service.factory.instances[("live", "probe-1")].strategy, running)
+            self.assertEqual(running.definition["version"], "1.0.0")
+
+            path.write_text("def broken(:\n", encoding="utf-8")
+            with self.assertRaises(SyntaxError):
+                service.LoadStrategy({"strategy_id": "test.reload_probe", "reload": True}, None)
+            self.assertEqual(service.factory.load_strategy("test.reload_probe").definition["version"], "1.1.0")
+
+
 @unittest.skipIf(falcon is None, "falcon is required for HTTP runtime tests")
 class StrategyHTTPRuntimeTest(unittest.TestCase):
     def make_client(self):
@@ -1048,6 +1136,64 @@ class StrategyHTTPRuntimeTest(unittest.TestCase):
 
         self.assertIsNot(first, second)
 
+    def test_http_snapshot_reports_checkpoint_support(self):
+        client, _ = self.make_client()
+        instance = self.sample_instance("http-snapshot")
+        instance["strategy_id"] = "indicator.zigzag_atr26"
+        client.simulate_post("/runtime/start", json={"instance": instance})
+
+        snapshot = client.simulate_post("/runtime/snapshot", json={"instance_id": "http-snapshot", "mode": "live"})
+        self.assertEqual(snapshot.status_code, 200)
+        self.assertFalse(snapshot.json["supported"])
+
+        missing = client.simulate_post("/runtime/snapshot", json={"instance_id": "missing", "mode": "live"})
+        self.assertEqual(missing.status_code, 400)
+
+    def test_stream_event_ack_result_and_replay_is_deduplicated(self):
+        service = StrategyService()
+        runner = AsyncStrategyRunner(service, ttl_seconds=600)
+        app = build_app(service=service, runner=runner)
+        client = falcon.testing.TestClient(app)
+        instance = self.sample_instance("stream-1")
+        started = client.simulate_post("/runtime/start", json={"instance": instance})
+        self.assertEqual(started.status_code, 200)
+        event = {
+            "type": "event",
+            "seq": 1,
+            "method": "OnBar",
+            "request": {
+                "instance": instance,
+                "symbol": "rb2601",
+                "event_time": "2026-01-01T09:01:00+08:00",
+                "mode": "live",
+                "bar": {"adjusted_time": "2026-01-01T09:01:00+08:00", "open": 100, "high": 101, "low": 98, "close": 99},
+            },
+        }
+
+        async def run():
+            async with falcon.testing.ASGIConductor(app) as conductor:
+                async with conductor.simulate_ws("/runtime/stream") as ws:
+                    await ws.send_json({"type": "hello", "session_id": "go-session"})
+                    welcome = await ws.receive_json()
+                    await ws.send_json({"type": "ping", "ts": 42})
+                    pong = await ws.receive_json()
+                    await ws.send_json(event)
+                    first = [await ws.receive_json(), await ws.receive_json()]
+                    await ws.send_json(event)
+                    replay = [await ws.receive_json(), await ws.receive_json()]
+                    return welcome, pong, first, replay
+
+        welcome, pong, first, replay = asyncio.run(run())
+        self.assertEqual(welcome, {"type": "welcome", "session_id": "go-session"})
+        self.assertEqual(pong["type"], "pong")
+        self.assertEqual(pong["ts"], 42)
+        for frames in (first, replay):
+            self.assertEqual([frame["type"] for frame in frames], ["ack", "result"])
+            self.assertEqual(frames[1]["seq"], 1)
+            self.assertEqual(frames[1]["status"], "done")
+            self.assertEqual(frames[1]["result"]["target_position"], -1)
+        self.assertEqual(len(runner._tasks), 1)
+
 
 if __name__ == "__main__":
     unittest.main()
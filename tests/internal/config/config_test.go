@@ -1,6 +1,7 @@
 package config_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -204,6 +205,12 @@ func TestLoadSuccessAndDefaults(t *testing.T) {
 	if cfg.Trade.PositionSyncIntervalMS != 3000 {
 		t.Fatalf("Trade.PositionSyncIntervalMS = %d, want 3000", cfg.Trade.PositionSyncIntervalMS)
 	}
+	if cfg.Trade.ReconcileIntervalMS != 60000 {
+		t.Fatalf("Trade.ReconcileIntervalMS = %d, want 60000", cfg.Trade.ReconcileIntervalMS)
+	}
+	if cfg.Trade.LotMatching != "fifo" {
+		t.Fatalf("Trade.LotMatching = %q, want fifo", cfg.Trade.LotMatching)
+	}
 }
 
 func TestLoadInvalidJSON(t *testing.T) {
@@ -601,6 +608,109 @@ func TestLoadInvalidQueueAlertThresholds(t *testing.T) {
 	}
 }
 
+func TestLoadTradeSubAccounts(t *testing.T) {
+	t.Parallel()
+
+	path := writeTempConfig(t, `{
+  "ctp": {
+    "flow_path": "./flow",
+    "trader_front_addr": "tcp://180.168.146.187:10201",
+    "md_front_addr": "tcp://180.168.146.187:10211",
+    "broker_id": "9999",
+    "app_id": "simnow_client_test",
+    "auth_code": "0000000000000000",
+    "user_id": "888888",
+    "password": "simnowpassword"
+  },
+  "trade": {
+    "account_id": "main",
+    "max_order_volume": 5,
+    "accounts": [
+      {"account_id": " sub1 ", "user_id": "100001", "password": "p1", "max_order_volume": 2},
+      {"account_id": "sub2", "enabled": false}
+    ]
+  }
+}`)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := strings.Join(cfg.Trade.AccountIDs(), ","); got != "main,sub1" {
+		t.Fatalf("AccountIDs() = %q, want main,sub1", got)
+	}
+	item, ok := cfg.Trade.Account("SUB1")
+	if !ok {
+		t.Fatal("Account(SUB1) not found")
+	}
+	if _, ok := cfg.Trade.Account("sub2"); ok {
+		t.Fatal("Account(sub2) found, want disabled account skipped")
+	}
+	sub := cfg.Trade.ForAccount(item)
+	if sub.AccountID != "sub1" || sub.MaxOrderVolume != 2 || sub.QueryTimeoutMS != cfg.Trade.QueryTimeoutMS {
+		t.Fatalf("ForAccount() = %+v, want sub1 with own max volume and shared query settings", sub)
+	}
+	ctp := cfg.CTP.ForTradeAccount(item)
+	if ctp.UserID != "100001" || ctp.BrokerID != "9999" || ctp.FlowPath != filepath.Join("./flow", "accounts", "sub1") {
+		t.Fatalf("ForTradeAccount() user=%q broker=%q flow=%q", ctp.UserID, ctp.BrokerID, ctp.FlowPath)
+	}
+}
+
+func TestLoadTradeSubAccountsRejectDuplicate(t *testing.T) {
+	t.Parallel()
+
+	path := writeTempConfig(t, `{
+  "ctp": {
+    "flow_path": "./flow",
+    "trader_front_addr": "tcp://180.168.146.187:10201",
+    "md_front_addr": "tcp://180.168.146.187:10211",
+    "broker_id": "9999",
+    "app_id": "simnow_client_test",
+    "auth_code": "0000000000000000",
+    "user_id": "888888",
+    "password": "simnowpassword"
+  },
+  "trade": {
+    "account_id": "main",
+    "accounts": [{"account_id": "Main", "user_id": "100001", "password": "p1"}]
+  }
+}`)
+
+	_, err := config.Load(path)
+	if err == nil || !strings.Contains(err.Error(), "duplicated") {
+		t.Fatalf("Load() error = %v, want duplicated account error", err)
+	}
+}
+
+func TestLoadTradeLotMatching(t *testing.T) {
+	t.Parallel()
+
+	base := `{
+  "ctp": {
+    "flow_path": "./flow",
+    "trader_front_addr": "tcp://180.168.146.187:10201",
+    "md_front_addr": "tcp://180.168.146.187:10211",
+    "broker_id": "9999",
+    "app_id": "simnow_client_test",
+    "auth_code": "0000000000000000",
+    "user_id": "888888",
+    "password": "simnowpassword"
+  },
+  "trade": {"lot_matching": %q}
+}`
+	cfg, err := config.Load(writeTempConfig(t, fmt.Sprintf(base, " LIFO ")))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Trade.LotMatching != "lifo" {
+		t.Fatalf("Trade.LotMatching = %q, want lifo", cfg.Trade.LotMatching)
+	}
+	_, err = config.Load(writeTempConfig(t, fmt.Sprintf(base, "average")))
+	if err == nil || !strings.Contains(err.Error(), "lot_matching") {
+		t.Fatalf("Load() error = %v, want lot_matching error", err)
+	}
+}
+
 func writeTempConfig(t *testing.T, content string) string {
 	t.Helper()
 
@@ -610,3 +720,35 @@ func writeTempConfig(t *testing.T, content string) string {
 	}
 	return path
 }
+
+func TestLoadStrategyTransport(t *testing.T) {
+	t.Parallel()
+
+	base := `{
+  "ctp": {
+    "flow_path": "./flow",
+    "trader_front_addr": "tcp://180.168.146.187:10201",
+    "md_front_addr": "tcp://180.168.146.187:10211",
+    "broker_id": "9999",
+    "app_id": "simnow_client_test",
+    "auth_code": "0000000000000000",
+    "user_id": "888888",
+    "password": "simnowpassword"
+  },
+  "strategy": {"transport": %q}
+}`
+	cases := map[string]string{"": config.StrategyTransportStream, " HTTP ": config.StrategyTransportHTTP}
+	for input, want := range cases {
+		cfg, err := config.Load(writeTempConfig(t, fmt.Sprintf(base, input)))
+		if err != nil {
+			t.Fatalf("Load(%q) error = %v", input, err)
+		}
+		if cfg.Strategy.Transport != want {
+			t.Fatalf("Strategy.Transport(%q) = %q, want %q", input, cfg.Strategy.Transport, want)
+		}
+	}
+	_, err := config.Load(writeTempConfig(t, fmt.Sprintf(base, "grpc")))
+	if err == nil || !strings.Contains(err.Error(), "strategy.transport") {
+		t.Fatalf("Load() error = %v, want strategy.transport error", err)
+	}
+}